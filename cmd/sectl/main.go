@@ -0,0 +1,53 @@
+// Command sectl inspects and edits a data directory: it lists tables and
+// indexes, reads and writes rows, runs scans and SQL, dumps the WAL, shows
+// checkpoints and runs vacuum. With a command after the flags it runs
+// that command and exits; without one it reads commands from stdin.
+//
+//	sectl -dir ./data tables
+//	sectl -dir ./data scan users email >= a limit 10
+//	sectl -dir ./data          # interactive shell; "help" lists commands
+//
+// The directory is opened with storage.Open, so it cannot be in use by
+// another engine (a running storaged, for example).
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bobboyms/storage-engine/pkg/storage"
+)
+
+func main() {
+	dir := flag.String("dir", "data", "data directory")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: sectl [-dir path] [command [args...]]\n\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(flag.CommandLine.Output(), "\ncommands:\n%s", usage)
+	}
+	flag.Parse()
+
+	se, err := storage.Open(*dir, storage.Options{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "sectl:", err)
+		os.Exit(1)
+	}
+	sh := &shell{engine: se, out: os.Stdout}
+
+	code := 0
+	if flag.NArg() > 0 {
+		if err := sh.run(strings.Join(flag.Args(), " ")); err != nil {
+			fmt.Fprintln(os.Stderr, "sectl:", err)
+			code = 1
+		}
+	} else {
+		sh.repl(os.Stdin, os.Stderr)
+	}
+	if err := se.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, "sectl:", err)
+		code = 1
+	}
+	os.Exit(code)
+}
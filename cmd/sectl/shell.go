@@ -0,0 +1,466 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/sql"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+const usage = `  tables                                 list tables and their indexes
+  get <table> [<index>] <key>            print the row with key (primary index by default)
+  put <table> <json>                     insert or replace a row
+  del <table> <key>                      delete the row with primary key
+  scan <table> [<index>] [<op> <value> [<value>]] [limit <n>]
+                                         print rows in index order; op is =, !=, <, <=, >, >=
+                                         or between (two values)
+  sql <statement>                        run a statement of pkg/sql
+  wal [--json] [--table <t>] [<from-lsn> [<to-lsn>]]
+                                         list WAL entries with their table, key and CRC
+  checkpoint [create]                    show the last checkpoint, or take one
+  vacuum [<table>]                       vacuum one table, or every table
+  help                                   show this list
+  quit                                   leave the shell
+`
+
+// errQuit ends the shell.
+var errQuit = errors.New("quit")
+
+type shell struct {
+	engine *storage.StorageEngine
+	out    io.Writer
+}
+
+// repl runs the commands read from in until EOF or quit; errors go to
+// errOut and do not stop it.
+func (sh *shell) repl(in io.Reader, errOut io.Writer) {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for {
+		fmt.Fprint(errOut, "sectl> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(errOut)
+			return
+		}
+		err := sh.run(scanner.Text())
+		if errors.Is(err, errQuit) {
+			return
+		}
+		if err != nil {
+			fmt.Fprintln(errOut, "error:", err)
+		}
+	}
+}
+
+// run runs one command line.
+func (sh *shell) run(line string) error {
+	name, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+	rest = strings.TrimSpace(rest)
+	args := strings.Fields(rest)
+	switch strings.ToLower(name) {
+	case "":
+		return nil
+	case "help":
+		fmt.Fprint(sh.out, usage)
+		return nil
+	case "quit", "exit":
+		return errQuit
+	case "tables":
+		return sh.tables()
+	case "get":
+		return sh.get(args)
+	case "put":
+		return sh.put(args, rest)
+	case "del":
+		return sh.del(args)
+	case "scan":
+		return sh.scan(args)
+	case "sql":
+		return sh.sql(rest)
+	case "wal":
+		return sh.wal(args)
+	case "checkpoint":
+		return sh.checkpoint(args)
+	case "vacuum":
+		return sh.vacuum(args)
+	}
+	return fmt.Errorf("unknown command %q (try help)", name)
+}
+
+func (sh *shell) tables() error {
+	names := sh.engine.TableMetaData.ListTables()
+	sort.Strings(names)
+	for _, name := range names {
+		table, err := sh.engine.TableMetaData.GetTableByName(name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(sh.out, name)
+		indices := table.GetIndices()
+		sort.Slice(indices, func(i, j int) bool {
+			if indices[i].Primary != indices[j].Primary {
+				return indices[i].Primary
+			}
+			return indices[i].Name < indices[j].Name
+		})
+		for _, index := range indices {
+			var flags []string
+			if index.Primary {
+				flags = append(flags, "primary")
+			}
+			if index.FieldPath() != index.Name {
+				flags = append(flags, "field="+index.FieldPath())
+			}
+			if index.Multikey {
+				flags = append(flags, "multikey")
+			}
+			if index.Bitmap {
+				flags = append(flags, "bitmap")
+			}
+			if index.Nullable {
+				flags = append(flags, "nullable")
+			}
+			fmt.Fprintf(sh.out, "  %-20s %-8v %s\n", index.Name, index.Type, strings.Join(flags, " "))
+		}
+	}
+	return nil
+}
+
+func (sh *shell) get(args []string) error {
+	if len(args) != 2 && len(args) != 3 {
+		return errors.New("usage: get <table> [<index>] <key>")
+	}
+	table, keyArg := args[0], args[len(args)-1]
+	index, err := sh.index(table, args[1:len(args)-1])
+	if err != nil {
+		return err
+	}
+	key, err := parseKey(index.Type, keyArg)
+	if err != nil {
+		return err
+	}
+	doc, found, err := sh.engine.Get(table, index.Name, key)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("no row with %s = %s", index.Name, keyArg)
+	}
+	fmt.Fprintln(sh.out, doc)
+	return nil
+}
+
+func (sh *shell) put(args []string, rest string) error {
+	if len(args) < 2 {
+		return errors.New("usage: put <table> <json>")
+	}
+	doc := strings.TrimSpace(strings.TrimPrefix(rest, args[0]))
+	return sh.engine.UpsertRow(args[0], doc, nil)
+}
+
+func (sh *shell) del(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: del <table> <key>")
+	}
+	index, err := sh.index(args[0], nil)
+	if err != nil {
+		return err
+	}
+	key, err := parseKey(index.Type, args[1])
+	if err != nil {
+		return err
+	}
+	deleted, err := sh.engine.DeleteRow(args[0], key)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("no row with %s = %s", index.Name, args[1])
+	}
+	return nil
+}
+
+var scanOperators = map[string]func(types.Comparable) *query.ScanCondition{
+	"=": query.Equal, "!=": query.NotEqual,
+	"<": query.LessThan, "<=": query.LessOrEqual,
+	">": query.GreaterThan, ">=": query.GreaterOrEqual,
+}
+
+func (sh *shell) scan(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: scan <table> [<index>] [<op> <value> [<value>]] [limit <n>]")
+	}
+	table, args := args[0], args[1:]
+	var opts storage.ScanOptions
+	if n := len(args); n >= 2 && strings.EqualFold(args[n-2], "limit") {
+		limit, err := strconv.Atoi(args[n-1])
+		if err != nil || limit < 0 {
+			return fmt.Errorf("invalid limit %q", args[n-1])
+		}
+		opts.Limit, args = limit, args[:n-2]
+	}
+	var indexArg []string
+	if len(args) > 0 && scanOperators[args[0]] == nil && !strings.EqualFold(args[0], "between") {
+		indexArg, args = args[:1], args[1:]
+	}
+	index, err := sh.index(table, indexArg)
+	if err != nil {
+		return err
+	}
+
+	var condition *query.ScanCondition
+	switch {
+	case len(args) == 0:
+	case len(args) == 3 && strings.EqualFold(args[0], "between"):
+		start, err := parseKey(index.Type, args[1])
+		if err != nil {
+			return err
+		}
+		end, err := parseKey(index.Type, args[2])
+		if err != nil {
+			return err
+		}
+		condition = query.Between(start, end)
+	case len(args) == 2 && scanOperators[args[0]] != nil:
+		key, err := parseKey(index.Type, args[1])
+		if err != nil {
+			return err
+		}
+		condition = scanOperators[args[0]](key)
+	default:
+		return fmt.Errorf("invalid condition %q", strings.Join(args, " "))
+	}
+
+	rows, err := sh.engine.Scan(table, index.Name, condition, opts)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		fmt.Fprintln(sh.out, row)
+	}
+	fmt.Fprintf(sh.out, "(%d rows)\n", len(rows))
+	return nil
+}
+
+func (sh *shell) sql(statement string) error {
+	if statement == "" {
+		return errors.New("usage: sql <statement>")
+	}
+	stmt, err := sql.Parse(statement)
+	if err != nil {
+		return err
+	}
+	result, err := sql.New(sh.engine).ExecStatement(context.Background(), stmt)
+	if err != nil {
+		return err
+	}
+	if _, isSelect := stmt.(*sql.Select); !isSelect {
+		fmt.Fprintf(sh.out, "(%d rows affected)\n", result.RowsAffected)
+		return nil
+	}
+	for _, row := range result.Rows {
+		fmt.Fprintln(sh.out, row)
+	}
+	fmt.Fprintf(sh.out, "(%d rows)\n", len(result.Rows))
+	return nil
+}
+
+// readWAL calls fn for each entry of the engine's WAL, after syncing it so
+// the entries written so far are on disk.
+func (sh *shell) readWAL(fn func(*wal.WALEntry) error) error {
+	if sh.engine.WAL == nil {
+		return errors.New("the engine has no WAL")
+	}
+	if err := sh.engine.WAL.Sync(); err != nil {
+		return err
+	}
+	reader, err := wal.NewWALReaderWithCipher(sh.engine.WAL.Path(), sh.engine.WAL.Cipher())
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	for {
+		entry, err := reader.ReadEntry()
+		if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+func (sh *shell) wal(args []string) error {
+	if sh.engine.WAL == nil {
+		return errors.New("the engine has no WAL")
+	}
+	opts := wal.DumpOptions{Describe: storage.DescribeWALEntry, Cipher: sh.engine.WAL.Cipher()}
+	var lsns []uint64
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--json":
+			opts.JSON = true
+		case args[i] == "--table" && i+1 < len(args):
+			i++
+			opts.Table = args[i]
+		case len(lsns) < 2 && !strings.HasPrefix(args[i], "-"):
+			lsn, err := strconv.ParseUint(args[i], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid LSN %q", args[i])
+			}
+			lsns = append(lsns, lsn)
+		default:
+			return errors.New("usage: wal [--json] [--table <table>] [<from-lsn> [<to-lsn>]]")
+		}
+	}
+	if len(lsns) > 0 {
+		opts.FromLSN = lsns[0]
+	}
+	if len(lsns) > 1 {
+		opts.ToLSN = lsns[1]
+	}
+	if err := sh.engine.WAL.Sync(); err != nil {
+		return err
+	}
+	_, err := wal.Dump(sh.engine.WAL.Path(), sh.out, opts)
+	return err
+}
+
+func (sh *shell) checkpoint(args []string) error {
+	switch {
+	case len(args) == 1 && args[0] == "create":
+		return sh.engine.CreateCheckpoint()
+	case len(args) != 0:
+		return errors.New("usage: checkpoint [create]")
+	}
+
+	var (
+		last  wal.CheckpointRecord
+		lsn   uint64
+		count int
+	)
+	err := sh.readWAL(func(entry *wal.WALEntry) error {
+		if entry.Header.EntryType != wal.EntryCheckpoint {
+			return nil
+		}
+		rec, err := wal.DecodeCheckpoint(entry.Payload)
+		if err != nil {
+			return fmt.Errorf("checkpoint at LSN %d: %w", entry.Header.LSN, err)
+		}
+		last, lsn = rec, entry.Header.LSN
+		count++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		fmt.Fprintln(sh.out, "no checkpoint in the WAL")
+		return nil
+	}
+	fmt.Fprintf(sh.out, "checkpoints in the WAL: %d\n", count)
+	fmt.Fprintf(sh.out, "last: LSN %d, redo from LSN %d", lsn, last.BeginLSN)
+	if last.EndLSN != 0 {
+		fmt.Fprintf(sh.out, ", pages up to LSN %d", last.EndLSN)
+	}
+	fmt.Fprintln(sh.out)
+	targets := make([]string, 0, len(last.Fences))
+	for target := range last.Fences {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	for _, target := range targets {
+		fmt.Fprintf(sh.out, "  %-30s durable up to LSN %d\n", target, last.Fences[target])
+	}
+	return nil
+}
+
+func (sh *shell) vacuum(args []string) error {
+	tables := args
+	if len(tables) == 0 {
+		tables = sh.engine.TableMetaData.ListTables()
+		sort.Strings(tables)
+	}
+	for _, table := range tables {
+		start := time.Now()
+		if err := sh.engine.Vacuum(table); err != nil {
+			return fmt.Errorf("vacuum %s: %w", table, err)
+		}
+		fmt.Fprintf(sh.out, "vacuumed %s in %v\n", table, time.Since(start).Round(time.Millisecond))
+	}
+	return nil
+}
+
+// index returns the index named by the optional argument, or the primary
+// index of table.
+func (sh *shell) index(tableName string, name []string) (*storage.Index, error) {
+	table, err := sh.engine.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return nil, err
+	}
+	if len(name) == 1 {
+		return table.GetIndex(name[0])
+	}
+	for _, index := range table.GetIndices() {
+		if index.Primary {
+			return index, nil
+		}
+	}
+	return nil, fmt.Errorf("table %s has no primary index", tableName)
+}
+
+// parseKey reads a key of type keyType written on the command line: dates
+// in RFC 3339 and bytes in base64url.
+func parseKey(keyType storage.DataType, s string) (types.Comparable, error) {
+	var key types.Comparable
+	var err error
+	switch keyType {
+	case storage.TypeInt:
+		var v int64
+		v, err = strconv.ParseInt(s, 10, 64)
+		key = types.IntKey(v)
+	case storage.TypeVarchar:
+		key = types.VarcharKey(s)
+	case storage.TypeBoolean:
+		var v bool
+		v, err = strconv.ParseBool(s)
+		key = types.BoolKey(v)
+	case storage.TypeFloat:
+		var v float64
+		v, err = strconv.ParseFloat(s, 64)
+		key = types.FloatKey(v)
+	case storage.TypeDate:
+		var v time.Time
+		v, err = time.Parse(time.RFC3339Nano, s)
+		key = types.DateKey(v)
+	case storage.TypeDecimal:
+		key, err = types.ParseDecimal(s)
+	case storage.TypeUUID:
+		key, err = types.ParseUUID(s)
+	case storage.TypeBytes:
+		var v []byte
+		v, err = base64.RawURLEncoding.DecodeString(s)
+		key = types.BytesKey(v)
+	default:
+		return nil, fmt.Errorf("unsupported key type %v", keyType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %v key %q: %v", keyType, s, err)
+	}
+	return key, nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/storage"
+)
+
+func TestShell_Commands(t *testing.T) {
+	se, err := storage.Open(t.TempDir(), storage.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer se.Close()
+	var out bytes.Buffer
+	sh := &shell{engine: se, out: &out}
+
+	run := func(line string) string {
+		t.Helper()
+		out.Reset()
+		if err := sh.run(line); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+		return out.String()
+	}
+
+	run(`sql CREATE TABLE users (id INT PRIMARY KEY, email VARCHAR)`)
+	run(`put users {"id": 1, "email": "ana@example.com"}`)
+	run(`put users {"id": 2, "email": "bia@example.com"}`)
+	run(`put users {"id": 3, "email": "caio@example.com"}`)
+
+	if got := run("tables"); !strings.Contains(got, "users\n") || !strings.Contains(got, "primary") || !strings.Contains(got, "nullable") {
+		t.Fatalf("tables = %q", got)
+	}
+	if got := run("get users email bia@example.com"); !strings.Contains(got, `"id":2`) {
+		t.Fatalf("get by email = %q", got)
+	}
+	if got := run("scan users >= 2 limit 1"); !strings.Contains(got, `"id":2`) || !strings.Contains(got, "(1 rows)") {
+		t.Fatalf("scan = %q", got)
+	}
+	if got := run("scan users email between b z"); !strings.Contains(got, "(2 rows)") {
+		t.Fatalf("scan between = %q", got)
+	}
+	run("del users 1")
+	if err := sh.run("get users 1"); err == nil {
+		t.Fatal("get of a deleted row should fail")
+	}
+	if got := run("sql SELECT id FROM users WHERE email = 'caio@example.com'"); got != "{\"id\":3}\n(1 rows)\n" {
+		t.Fatalf("sql = %q", got)
+	}
+
+	run("checkpoint create")
+	if got := run("checkpoint"); !strings.Contains(got, "checkpoints in the WAL: 1") || !strings.Contains(got, "users.id") {
+		t.Fatalf("checkpoint = %q", got)
+	}
+	if got := run("wal"); !strings.Contains(got, "MULTI_INSERT") || !strings.Contains(got, "CHECKPOINT") || !strings.Contains(got, "email=bia@example.com,id=2") {
+		t.Fatalf("wal = %q", got)
+	}
+	if got := run("wal --json --table users"); strings.Contains(got, "CHECKPOINT") || !strings.Contains(got, `"table":"users"`) {
+		t.Fatalf("wal --table = %q", got)
+	}
+	if got := run("vacuum"); !strings.Contains(got, "vacuumed users") {
+		t.Fatalf("vacuum = %q", got)
+	}
+
+	for _, line := range []string{"nope", "get users", "get users abc", "scan users ~ 1", "wal x", "wal --table", "put users"} {
+		if err := sh.run(line); err == nil {
+			t.Errorf("%q should fail", line)
+		}
+	}
+}
+
+func TestShell_REPL(t *testing.T) {
+	se, err := storage.Open("", storage.Options{InMemory: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer se.Close()
+	var out, errOut bytes.Buffer
+	sh := &shell{engine: se, out: &out}
+	sh.repl(strings.NewReader("help\nbogus\nquit\ntables\n"), &errOut)
+	if !strings.Contains(out.String(), "checkpoint [create]") {
+		t.Fatalf("help output = %q", out.String())
+	}
+	if !strings.Contains(errOut.String(), `unknown command "bogus"`) {
+		t.Fatalf("errors = %q", errOut.String())
+	}
+}
@@ -0,0 +1,75 @@
+// Command storaged runs a storage engine as a gRPC service (see
+// pkg/server), so several processes share one data directory. With -http
+// it also serves the REST API of pkg/httpapi.
+//
+//	storaged -dir ./data -addr :7070 -http :8080
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/bobboyms/storage-engine/pkg/httpapi"
+	"github.com/bobboyms/storage-engine/pkg/server"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	dir := flag.String("dir", "data", "data directory")
+	addr := flag.String("addr", ":7070", "address to listen on")
+	httpAddr := flag.String("http", "", "address of the REST API; empty disables it")
+	inMemory := flag.Bool("in-memory", false, "keep everything in memory; -dir is ignored")
+	flag.Parse()
+
+	se, err := storage.Open(*dir, storage.Options{InMemory: *inMemory})
+	if err != nil {
+		log.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		se.Close()
+		log.Fatal(err)
+	}
+
+	srv := server.New(se)
+	grpcServer := grpc.NewServer()
+	server.RegisterStorageServer(grpcServer, srv)
+
+	var httpServer *http.Server
+	if *httpAddr != "" {
+		httpServer = &http.Server{Addr: *httpAddr, Handler: httpapi.New(se)}
+		go func() {
+			log.Printf("storaged: REST API on %s", *httpAddr)
+			if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				log.Print(err)
+				grpcServer.GracefulStop()
+			}
+		}()
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-stop
+		grpcServer.GracefulStop()
+	}()
+
+	log.Printf("storaged: serving %s on %s", se.Dir(), lis.Addr())
+	if err := grpcServer.Serve(lis); err != nil {
+		log.Print(err)
+	}
+	if httpServer != nil {
+		httpServer.Close()
+	}
+	srv.Close()
+	if err := se.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
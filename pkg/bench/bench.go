@@ -0,0 +1,293 @@
+// Package bench runs YCSB-style workloads against a StorageEngine and
+// reports throughput and latency percentiles, so performance changes can
+// be measured instead of guessed:
+//
+//	cfg := bench.Config{Records: 100000, Operations: 200000, Concurrency: 8}
+//	load, err := bench.Load(se, cfg)   // creates the table and inserts Records rows
+//	run, err := bench.Run(se, bench.ReadHeavy, cfg)
+//	fmt.Print(load, run)
+//
+// Rows live in one table whose primary key is an INT id; each row carries
+// one string field of Config.ValueSize bytes. Reads are Get by id, updates
+// UpdateFields of that field, inserts new ids after the loaded ones and
+// scans primary-key range scans of up to Config.ScanLength rows.
+package bench
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// Op is one kind of operation of a workload.
+type Op string
+
+const (
+	OpRead   Op = "read"
+	OpUpdate Op = "update"
+	OpInsert Op = "insert"
+	OpScan   Op = "scan"
+)
+
+// Workload is the operation mix of a run phase, as proportions that add
+// up to 1.
+type Workload struct {
+	Name   string
+	Read   float64
+	Update float64
+	Insert float64
+	Scan   float64
+}
+
+// The standard workloads, after YCSB's B, A and E.
+var (
+	ReadHeavy   = Workload{Name: "read-heavy", Read: 0.95, Update: 0.05}
+	UpdateHeavy = Workload{Name: "update-heavy", Read: 0.5, Update: 0.5}
+	ScanHeavy   = Workload{Name: "scan-heavy", Scan: 0.95, Insert: 0.05}
+)
+
+// Distribution picks the keys operations touch.
+type Distribution int
+
+const (
+	// Uniform spreads operations evenly over the loaded keys.
+	Uniform Distribution = iota
+	// Zipfian concentrates them on a few hot keys, the low ids.
+	Zipfian
+)
+
+// Config sizes a workload. Zero fields take the defaults noted.
+type Config struct {
+	Table        string       // default "usertable"
+	Records      int          // rows Load inserts and the key space of Run; default 10000
+	Operations   int          // operations of a Run; default 10000
+	ValueSize    int          // bytes of each row's field; default 100
+	Concurrency  int          // goroutines issuing operations; default 1
+	ScanLength   int          // longest scan, in rows; default 100
+	Distribution Distribution // default Uniform
+	Seed         int64        // seeds the key and value choices
+}
+
+func (c Config) withDefaults() Config {
+	if c.Table == "" {
+		c.Table = "usertable"
+	}
+	if c.Records <= 0 {
+		c.Records = 10000
+	}
+	if c.Operations <= 0 {
+		c.Operations = 10000
+	}
+	if c.ValueSize <= 0 {
+		c.ValueSize = 100
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = 1
+	}
+	if c.ScanLength <= 0 {
+		c.ScanLength = 100
+	}
+	return c
+}
+
+// Latency summarizes the latencies of one kind of operation.
+type Latency struct {
+	Count int
+	Mean  time.Duration
+	P50   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// Result is the outcome of a Load or a Run.
+type Result struct {
+	Workload   string
+	Operations int
+	Duration   time.Duration
+	Throughput float64 // operations per second
+	Latencies  map[Op]Latency
+}
+
+// String formats the result as a small report: one summary line and one
+// line of latencies per kind of operation.
+func (r *Result) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d ops in %v (%.0f ops/s)\n", r.Workload, r.Operations, r.Duration.Round(time.Millisecond), r.Throughput)
+	for _, op := range []Op{OpRead, OpUpdate, OpInsert, OpScan} {
+		l, ok := r.Latencies[op]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&b, "  %-6s %8d  mean %-10v p50 %-10v p95 %-10v p99 %-10v max %v\n",
+			op, l.Count, l.Mean, l.P50, l.P95, l.P99, l.Max)
+	}
+	return b.String()
+}
+
+// Load creates the table, when missing, and inserts cfg.Records rows
+// with ids 0 to Records-1 from cfg.Concurrency goroutines.
+func Load(se *storage.StorageEngine, cfg Config) (*Result, error) {
+	cfg = cfg.withDefaults()
+	if _, err := se.TableMetaData.GetTableByName(cfg.Table); err != nil {
+		if err := se.CreateTable(cfg.Table, []storage.Index{{Name: "id", Primary: true, Type: storage.TypeInt}}); err != nil {
+			return nil, fmt.Errorf("bench: create table %s: %w", cfg.Table, err)
+		}
+	}
+	var next atomic.Int64
+	return execute(cfg, "load", cfg.Records, func(w *worker) (Op, error) {
+		id := next.Add(1) - 1
+		return OpInsert, se.InsertRow(cfg.Table, w.row(id), nil)
+	})
+}
+
+// Run issues cfg.Operations operations of the workload against a table
+// Load filled with the same cfg.Records.
+func Run(se *storage.StorageEngine, workload Workload, cfg Config) (*Result, error) {
+	cfg = cfg.withDefaults()
+	total := workload.Read + workload.Update + workload.Insert + workload.Scan
+	if total <= 0 {
+		return nil, errors.New("bench: workload has no operations")
+	}
+	var inserted atomic.Int64
+	inserted.Store(int64(cfg.Records))
+	return execute(cfg, workload.Name, cfg.Operations, func(w *worker) (Op, error) {
+		p := w.rng.Float64() * total
+		switch {
+		case p < workload.Read:
+			_, found, err := se.Get(cfg.Table, "id", w.key())
+			if err == nil && !found {
+				err = fmt.Errorf("bench: key %v not found", w.lastKey)
+			}
+			return OpRead, err
+		case p < workload.Read+workload.Update:
+			return OpUpdate, se.UpdateFields(cfg.Table, "id", w.key(), map[string]interface{}{"field0": w.value()})
+		case p < workload.Read+workload.Update+workload.Insert:
+			id := inserted.Add(1) - 1
+			return OpInsert, se.InsertRow(cfg.Table, w.row(id), nil)
+		default:
+			limit := 1 + w.rng.Intn(cfg.ScanLength)
+			_, err := se.Scan(cfg.Table, "id", query.GreaterOrEqual(w.key()), storage.ScanOptions{Limit: limit})
+			return OpScan, err
+		}
+	})
+}
+
+// worker is the state of one goroutine of a phase.
+type worker struct {
+	cfg     Config
+	rng     *rand.Rand
+	zipf    *rand.Zipf
+	lastKey types.IntKey
+	samples map[Op][]time.Duration
+}
+
+// key picks an id among the loaded rows.
+func (w *worker) key() types.Comparable {
+	if w.zipf != nil {
+		w.lastKey = types.IntKey(w.zipf.Uint64())
+	} else {
+		w.lastKey = types.IntKey(w.rng.Intn(w.cfg.Records))
+	}
+	return w.lastKey
+}
+
+const valueLetters = "abcdefghijklmnopqrstuvwxyz"
+
+func (w *worker) value() string {
+	b := make([]byte, w.cfg.ValueSize)
+	for i := range b {
+		b[i] = valueLetters[w.rng.Intn(len(valueLetters))]
+	}
+	return string(b)
+}
+
+func (w *worker) row(id int64) string {
+	return fmt.Sprintf(`{"id": %d, "field0": %q}`, id, w.value())
+}
+
+// execute runs ops operations of fn over cfg.Concurrency workers and
+// collects their latencies. The first error stops every worker.
+func execute(cfg Config, name string, ops int, fn func(*worker) (Op, error)) (*Result, error) {
+	workers := make([]*worker, cfg.Concurrency)
+	for i := range workers {
+		rng := rand.New(rand.NewSource(cfg.Seed + int64(i)))
+		w := &worker{cfg: cfg, rng: rng, samples: make(map[Op][]time.Duration)}
+		if cfg.Distribution == Zipfian && cfg.Records > 1 {
+			w.zipf = rand.NewZipf(rng, 1.1, 1, uint64(cfg.Records-1))
+		}
+		workers[i] = w
+	}
+
+	var (
+		issued   atomic.Int64
+		failed   atomic.Bool
+		errOnce  sync.Once
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	start := time.Now()
+	for _, w := range workers {
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			for !failed.Load() && issued.Add(1) <= int64(ops) {
+				began := time.Now()
+				op, err := fn(w)
+				w.samples[op] = append(w.samples[op], time.Since(began))
+				if err != nil {
+					errOnce.Do(func() { firstErr = fmt.Errorf("bench: %s %s: %w", name, op, err) })
+					failed.Store(true)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	merged := make(map[Op][]time.Duration)
+	for _, w := range workers {
+		for op, samples := range w.samples {
+			merged[op] = append(merged[op], samples...)
+		}
+	}
+	result := &Result{Workload: name, Operations: ops, Duration: elapsed, Latencies: make(map[Op]Latency)}
+	if elapsed > 0 {
+		result.Throughput = float64(ops) / elapsed.Seconds()
+	}
+	for op, samples := range merged {
+		result.Latencies[op] = summarize(samples)
+	}
+	return result, nil
+}
+
+func summarize(samples []time.Duration) Latency {
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	var sum time.Duration
+	for _, d := range samples {
+		sum += d
+	}
+	at := func(p float64) time.Duration {
+		return samples[int(p*float64(len(samples)-1))]
+	}
+	return Latency{
+		Count: len(samples),
+		Mean:  sum / time.Duration(len(samples)),
+		P50:   at(0.50),
+		P95:   at(0.95),
+		P99:   at(0.99),
+		Max:   samples[len(samples)-1],
+	}
+}
@@ -0,0 +1,86 @@
+package bench_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/bench"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+)
+
+func openEngine(t testing.TB) *storage.StorageEngine {
+	t.Helper()
+	se, err := storage.Open("", storage.Options{InMemory: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { se.Close() })
+	return se
+}
+
+func TestWorkloads(t *testing.T) {
+	se := openEngine(t)
+	cfg := bench.Config{Records: 500, Operations: 400, ValueSize: 32, Concurrency: 4, ScanLength: 10, Distribution: bench.Zipfian}
+
+	load, err := bench.Load(se, cfg)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if load.Operations != 500 || load.Latencies[bench.OpInsert].Count != 500 || load.Throughput <= 0 {
+		t.Fatalf("load = %+v", load)
+	}
+
+	for _, w := range []bench.Workload{bench.ReadHeavy, bench.UpdateHeavy, bench.ScanHeavy} {
+		res, err := bench.Run(se, w, cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", w.Name, err)
+		}
+		count := 0
+		for _, l := range res.Latencies {
+			count += l.Count
+			if l.P50 > l.P95 || l.P95 > l.P99 || l.P99 > l.Max {
+				t.Fatalf("%s: percentiles out of order: %+v", w.Name, l)
+			}
+		}
+		if count != 400 {
+			t.Fatalf("%s: %d operations timed, want 400", w.Name, count)
+		}
+		if report := res.String(); !strings.HasPrefix(report, w.Name+": 400 ops") {
+			t.Fatalf("report = %q", report)
+		}
+	}
+
+	// ScanHeavy inserted new ids past the loaded ones.
+	rows, err := se.Scan("usertable", "id", nil)
+	if err != nil || len(rows) <= 500 {
+		t.Fatalf("rows after scan-heavy = %d, %v", len(rows), err)
+	}
+
+	if _, err := bench.Run(se, bench.Workload{Name: "empty"}, cfg); err == nil {
+		t.Fatal("a workload without operations should fail")
+	}
+	if _, err := bench.Run(se, bench.ReadHeavy, bench.Config{Table: "missing"}); err == nil {
+		t.Fatal("running against a missing table should fail")
+	}
+}
+
+func benchmarkWorkload(b *testing.B, w bench.Workload) {
+	se := openEngine(b)
+	cfg := bench.Config{Records: 10000, Operations: b.N, Concurrency: 4}
+	if _, err := bench.Load(se, cfg); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	res, err := bench.Run(se, w, cfg)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.StopTimer()
+	for op, l := range res.Latencies {
+		b.ReportMetric(float64(l.P99.Microseconds()), string(op)+"-p99-us")
+	}
+}
+
+func BenchmarkReadHeavy(b *testing.B)   { benchmarkWorkload(b, bench.ReadHeavy) }
+func BenchmarkUpdateHeavy(b *testing.B) { benchmarkWorkload(b, bench.UpdateHeavy) }
+func BenchmarkScanHeavy(b *testing.B)   { benchmarkWorkload(b, bench.ScanHeavy) }
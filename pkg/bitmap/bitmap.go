@@ -0,0 +1,352 @@
+// Package bitmap implements a compressed set of uint64 values in the
+// style of roaring bitmaps. Values are grouped by their high 48 bits into
+// containers of 2^16 values; a container is a sorted array while sparse
+// and a bitset once it holds more than arrayMaxSize values, so both
+// scattered and dense sets stay small and AND/OR work container by
+// container.
+package bitmap
+
+import (
+	"math/bits"
+	"sort"
+)
+
+const (
+	arrayMaxSize = 4096
+	bitsetWords  = 1 << 16 / 64
+)
+
+// Bitmap is a set of uint64 values. The zero value is an empty set ready
+// to use. A Bitmap is not safe for concurrent mutation.
+type Bitmap struct {
+	keys       []uint64 // high 48 bits, ascending
+	containers []*container
+}
+
+type container struct {
+	array []uint16 // sorted, while n <= arrayMaxSize
+	bits  []uint64 // bitsetWords words, once dense
+	n     int
+}
+
+// New returns a bitmap holding values.
+func New(values ...uint64) *Bitmap {
+	b := &Bitmap{}
+	for _, v := range values {
+		b.Add(v)
+	}
+	return b
+}
+
+func split(v uint64) (uint64, uint16) {
+	return v >> 16, uint16(v)
+}
+
+func (b *Bitmap) find(hi uint64) (int, bool) {
+	i := sort.Search(len(b.keys), func(i int) bool { return b.keys[i] >= hi })
+	return i, i < len(b.keys) && b.keys[i] == hi
+}
+
+// Add inserts v and reports whether it was absent.
+func (b *Bitmap) Add(v uint64) bool {
+	hi, lo := split(v)
+	i, ok := b.find(hi)
+	if !ok {
+		b.keys = append(b.keys, 0)
+		copy(b.keys[i+1:], b.keys[i:])
+		b.keys[i] = hi
+		b.containers = append(b.containers, nil)
+		copy(b.containers[i+1:], b.containers[i:])
+		b.containers[i] = &container{}
+	}
+	return b.containers[i].add(lo)
+}
+
+// Remove deletes v and reports whether it was present.
+func (b *Bitmap) Remove(v uint64) bool {
+	hi, lo := split(v)
+	i, ok := b.find(hi)
+	if !ok || !b.containers[i].remove(lo) {
+		return false
+	}
+	if b.containers[i].n == 0 {
+		b.keys = append(b.keys[:i], b.keys[i+1:]...)
+		b.containers = append(b.containers[:i], b.containers[i+1:]...)
+	}
+	return true
+}
+
+// Contains reports whether v is in the set.
+func (b *Bitmap) Contains(v uint64) bool {
+	hi, lo := split(v)
+	i, ok := b.find(hi)
+	return ok && b.containers[i].contains(lo)
+}
+
+// Cardinality returns the number of values in the set.
+func (b *Bitmap) Cardinality() int {
+	n := 0
+	for _, c := range b.containers {
+		n += c.n
+	}
+	return n
+}
+
+// IsEmpty reports whether the set has no values.
+func (b *Bitmap) IsEmpty() bool {
+	return len(b.containers) == 0
+}
+
+// Clone returns an independent copy of b.
+func (b *Bitmap) Clone() *Bitmap {
+	out := &Bitmap{
+		keys:       append([]uint64(nil), b.keys...),
+		containers: make([]*container, len(b.containers)),
+	}
+	for i, c := range b.containers {
+		out.containers[i] = c.clone()
+	}
+	return out
+}
+
+// And returns the values present in both b and o.
+func (b *Bitmap) And(o *Bitmap) *Bitmap {
+	out := &Bitmap{}
+	i, j := 0, 0
+	for i < len(b.keys) && j < len(o.keys) {
+		switch {
+		case b.keys[i] < o.keys[j]:
+			i++
+		case b.keys[i] > o.keys[j]:
+			j++
+		default:
+			if c := b.containers[i].and(o.containers[j]); c.n > 0 {
+				out.keys = append(out.keys, b.keys[i])
+				out.containers = append(out.containers, c)
+			}
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+// Or returns the values present in b or o.
+func (b *Bitmap) Or(o *Bitmap) *Bitmap {
+	out := &Bitmap{}
+	i, j := 0, 0
+	for i < len(b.keys) || j < len(o.keys) {
+		switch {
+		case j == len(o.keys) || (i < len(b.keys) && b.keys[i] < o.keys[j]):
+			out.keys = append(out.keys, b.keys[i])
+			out.containers = append(out.containers, b.containers[i].clone())
+			i++
+		case i == len(b.keys) || b.keys[i] > o.keys[j]:
+			out.keys = append(out.keys, o.keys[j])
+			out.containers = append(out.containers, o.containers[j].clone())
+			j++
+		default:
+			out.keys = append(out.keys, b.keys[i])
+			out.containers = append(out.containers, b.containers[i].or(o.containers[j]))
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+// ForEach calls fn with every value in ascending order until fn returns
+// false.
+func (b *Bitmap) ForEach(fn func(v uint64) bool) {
+	for i, c := range b.containers {
+		base := b.keys[i] << 16
+		if !c.forEach(func(lo uint16) bool { return fn(base | uint64(lo)) }) {
+			return
+		}
+	}
+}
+
+// ToArray returns the values in ascending order.
+func (b *Bitmap) ToArray() []uint64 {
+	out := make([]uint64, 0, b.Cardinality())
+	b.ForEach(func(v uint64) bool {
+		out = append(out, v)
+		return true
+	})
+	return out
+}
+
+// SizeInBytes estimates the memory held by the containers.
+func (b *Bitmap) SizeInBytes() int {
+	size := len(b.keys) * 8
+	for _, c := range b.containers {
+		size += len(c.array)*2 + len(c.bits)*8
+	}
+	return size
+}
+
+func (c *container) add(lo uint16) bool {
+	if c.bits != nil {
+		w, m := lo/64, uint64(1)<<(lo%64)
+		if c.bits[w]&m != 0 {
+			return false
+		}
+		c.bits[w] |= m
+		c.n++
+		return true
+	}
+	i := sort.Search(len(c.array), func(i int) bool { return c.array[i] >= lo })
+	if i < len(c.array) && c.array[i] == lo {
+		return false
+	}
+	c.array = append(c.array, 0)
+	copy(c.array[i+1:], c.array[i:])
+	c.array[i] = lo
+	c.n++
+	if c.n > arrayMaxSize {
+		c.toBitset()
+	}
+	return true
+}
+
+func (c *container) remove(lo uint16) bool {
+	if c.bits != nil {
+		w, m := lo/64, uint64(1)<<(lo%64)
+		if c.bits[w]&m == 0 {
+			return false
+		}
+		c.bits[w] &^= m
+		c.n--
+		if c.n <= arrayMaxSize {
+			c.toArray()
+		}
+		return true
+	}
+	i := sort.Search(len(c.array), func(i int) bool { return c.array[i] >= lo })
+	if i == len(c.array) || c.array[i] != lo {
+		return false
+	}
+	c.array = append(c.array[:i], c.array[i+1:]...)
+	c.n--
+	return true
+}
+
+func (c *container) contains(lo uint16) bool {
+	if c.bits != nil {
+		return c.bits[lo/64]&(uint64(1)<<(lo%64)) != 0
+	}
+	i := sort.Search(len(c.array), func(i int) bool { return c.array[i] >= lo })
+	return i < len(c.array) && c.array[i] == lo
+}
+
+func (c *container) clone() *container {
+	return &container{
+		array: append([]uint16(nil), c.array...),
+		bits:  append([]uint64(nil), c.bits...),
+		n:     c.n,
+	}
+}
+
+func (c *container) forEach(fn func(lo uint16) bool) bool {
+	if c.bits == nil {
+		for _, lo := range c.array {
+			if !fn(lo) {
+				return false
+			}
+		}
+		return true
+	}
+	for w, word := range c.bits {
+		for word != 0 {
+			t := bits.TrailingZeros64(word)
+			if !fn(uint16(w*64 + t)) {
+				return false
+			}
+			word &= word - 1
+		}
+	}
+	return true
+}
+
+func (c *container) and(o *container) *container {
+	if c.bits == nil || o.bits == nil {
+		// At least one side is sparse: probe its values in the other.
+		small, other := c, o
+		if small.bits != nil {
+			small, other = o, c
+		}
+		out := &container{}
+		for _, lo := range small.array {
+			if other.contains(lo) {
+				out.array = append(out.array, lo)
+			}
+		}
+		out.n = len(out.array)
+		return out
+	}
+	out := &container{bits: make([]uint64, bitsetWords)}
+	for w := range out.bits {
+		out.bits[w] = c.bits[w] & o.bits[w]
+		out.n += bits.OnesCount64(out.bits[w])
+	}
+	if out.n <= arrayMaxSize {
+		out.toArray()
+	}
+	return out
+}
+
+func (c *container) or(o *container) *container {
+	if c.bits == nil && o.bits == nil && c.n+o.n <= arrayMaxSize {
+		out := &container{array: make([]uint16, 0, c.n+o.n)}
+		i, j := 0, 0
+		for i < len(c.array) || j < len(o.array) {
+			switch {
+			case j == len(o.array) || (i < len(c.array) && c.array[i] < o.array[j]):
+				out.array = append(out.array, c.array[i])
+				i++
+			case i == len(c.array) || c.array[i] > o.array[j]:
+				out.array = append(out.array, o.array[j])
+				j++
+			default:
+				out.array = append(out.array, c.array[i])
+				i++
+				j++
+			}
+		}
+		out.n = len(out.array)
+		return out
+	}
+	out := c.clone()
+	out.toBitset()
+	o.forEach(func(lo uint16) bool {
+		out.add(lo)
+		return true
+	})
+	if out.n <= arrayMaxSize {
+		out.toArray()
+	}
+	return out
+}
+
+func (c *container) toBitset() {
+	if c.bits != nil {
+		return
+	}
+	c.bits = make([]uint64, bitsetWords)
+	for _, lo := range c.array {
+		c.bits[lo/64] |= uint64(1) << (lo % 64)
+	}
+	c.array = nil
+}
+
+func (c *container) toArray() {
+	if c.bits == nil {
+		return
+	}
+	array := make([]uint16, 0, c.n)
+	c.forEach(func(lo uint16) bool {
+		array = append(array, lo)
+		return true
+	})
+	c.array, c.bits = array, nil
+}
@@ -0,0 +1,71 @@
+package bitmap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBitmap_AddRemoveContains(t *testing.T) {
+	b := New()
+	for _, v := range []uint64{5, 1, 1 << 40, 70000, 5} {
+		b.Add(v)
+	}
+	if got, want := b.ToArray(), []uint64{1, 5, 70000, 1 << 40}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ToArray = %v, want %v", got, want)
+	}
+	if !b.Contains(70000) || b.Contains(70001) {
+		t.Fatal("Contains mismatch")
+	}
+	if !b.Remove(70000) || b.Remove(70000) {
+		t.Fatal("Remove should report presence once")
+	}
+	if b.Cardinality() != 3 || len(b.keys) != 2 {
+		t.Fatalf("empty container left behind: card=%d containers=%d", b.Cardinality(), len(b.keys))
+	}
+}
+
+func TestBitmap_DenseContainersSwitchToBitset(t *testing.T) {
+	b := New()
+	for v := uint64(0); v < 10000; v += 2 {
+		b.Add(v)
+	}
+	if b.containers[0].bits == nil {
+		t.Fatal("expected a bitset container above the array limit")
+	}
+	for v := uint64(0); v < 10000; v += 4 {
+		b.Remove(v)
+	}
+	if b.containers[0].bits != nil || b.Cardinality() != 2500 {
+		t.Fatalf("expected an array container again, card=%d", b.Cardinality())
+	}
+}
+
+func TestBitmap_AndOr(t *testing.T) {
+	evens, threes := New(), New()
+	for v := uint64(0); v < 20000; v++ {
+		if v%2 == 0 {
+			evens.Add(v)
+		}
+		if v%3 == 0 {
+			threes.Add(v)
+		}
+	}
+	threes.Add(1 << 33)
+
+	and := evens.And(threes)
+	or := evens.Or(threes)
+	for v := uint64(0); v < 20000; v++ {
+		if and.Contains(v) != (v%6 == 0) {
+			t.Fatalf("And mismatch at %d", v)
+		}
+		if or.Contains(v) != (v%2 == 0 || v%3 == 0) {
+			t.Fatalf("Or mismatch at %d", v)
+		}
+	}
+	if and.Contains(1<<33) || !or.Contains(1<<33) {
+		t.Fatal("containers present on one side only mishandled")
+	}
+	if evens.Cardinality() != 10000 {
+		t.Fatal("And/Or changed an operand")
+	}
+}
@@ -0,0 +1,48 @@
+package btree
+
+import "github.com/bobboyms/storage-engine/pkg/types"
+
+// MultiValueTree is a Tree whose keys map to a posting list of values
+// instead of a single value. Non-unique secondary indexes use it so that
+// every row sharing a key (e.g. department="Sales") stays reachable.
+//
+// The single-value Tree methods keep working on top of the posting list:
+// Get returns the first posting, Insert adds a posting, Replace collapses
+// the list to one value and Remove drops the whole list.
+type MultiValueTree interface {
+	Tree
+
+	// GetAll returns every value stored under key, in ascending order.
+	// An absent key yields an empty slice and no error.
+	GetAll(key types.Comparable) ([]int64, error)
+
+	// InsertValue adds value to the posting list of key. Adding a value
+	// that is already present is a no-op.
+	InsertValue(key types.Comparable, value int64) error
+
+	// RemoveValue drops value from the posting list of key. Returns
+	// false when the pair was not present.
+	RemoveValue(key types.Comparable, value int64) (bool, error)
+}
+
+// PostingKey is the composite (Key, Value) entry a MultiValueTree stores
+// for each posting. Ordering is by Key first and Value second, so all
+// postings of a key are contiguous in the tree.
+type PostingKey struct {
+	Key   types.Comparable
+	Value int64
+}
+
+func (k PostingKey) Compare(other types.Comparable) int {
+	o := other.(PostingKey)
+	if c := k.Key.Compare(o.Key); c != 0 {
+		return c
+	}
+	if k.Value < o.Value {
+		return -1
+	}
+	if k.Value > o.Value {
+		return 1
+	}
+	return 0
+}
@@ -3,6 +3,7 @@ package v2
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 
 	"github.com/bobboyms/storage-engine/pkg/btree"
 	"github.com/bobboyms/storage-engine/pkg/crypto"
@@ -80,9 +81,24 @@ type BTreeV2 struct {
 	metaMu sync.RWMutex
 
 	rootPageID pagestore.PageID
+	// optRoot mirrors rootPageID without metaMu, so an optimistic read
+	// can validate the root while holding its latch: root splits take
+	// metaMu before the root latch, so reading metaMu there would invert
+	// the order.
+	optRoot atomic.Uint64
 
 	writeMu            sync.Mutex
 	currentMutationLSN uint64
+
+	// optNodes holds the copies of internal nodes for optimistic reads
+	// (PageID → *optNode; see optimistic.go).
+	optNodes    sync.Map
+	optCount    atomic.Int64
+	optRestarts atomic.Uint64
+
+	// onCursorLeaf, set only in tests, runs when a cursor descent
+	// reaches the leaf, before reading it.
+	onCursorLeaf func(leaf pagestore.PageID)
 }
 
 // NewBTreeV2 abre ou cria uma B+ tree page-based em `path` com IntKeyCodec
@@ -167,6 +183,9 @@ func (tr *BTreeV2) Sync() error {
 // Path devolve o caminho do arquivo.
 func (tr *BTreeV2) Path() string { return tr.pf.Path() }
 
+// Cipher returns the cipher of the underlying page file.
+func (tr *BTreeV2) Cipher() crypto.Cipher { return tr.pf.Cipher() }
+
 func (tr *BTreeV2) SetBeforeFlushHook(hook func(pageID pagestore.PageID, page *pagestore.Page) error) {
 	tr.bp.SetBeforeFlushHook(hook)
 }
@@ -175,6 +194,16 @@ func (tr *BTreeV2) DirtyPages() []pagestore.DirtyPageInfo {
 	return tr.bp.DirtyPages()
 }
 
+// MemoryUsage returns how many bytes of pages the tree keeps in RAM. Nodes
+// live only in the buffer pool, so the usage is bounded by the pool
+// capacity (CacheCapacity pages).
+func (tr *BTreeV2) MemoryUsage() int64 {
+	return int64(tr.bp.Size()) * pagestore.PageSize
+}
+
+// CacheCapacity returns the buffer pool capacity, in pages.
+func (tr *BTreeV2) CacheCapacity() int { return tr.bp.Capacity() }
+
 func (tr *BTreeV2) ApplyPageRedo(pageID pagestore.PageID, page *pagestore.Page, lsn uint64) (bool, error) {
 	current, err := tr.pf.ReadPage(pageID)
 	if err == nil {
@@ -301,23 +330,26 @@ func (tr *BTreeV2) loadOrInitMeta() error {
 	}
 	tr.metaMu.Lock()
 	tr.rootPageID = m.rootPageID
+	tr.optRoot.Store(uint64(m.rootPageID))
 	tr.metaMu.Unlock()
 	return nil
 }
 
+// initFreshTree creates the meta page and the root. The meta page is
+// released before the root is created and latched again afterwards: as in
+// root splits, the meta latch always comes last in the order.
 func (tr *BTreeV2) initFreshTree() error {
 	metaH, err := tr.bp.NewPage()
 	if err != nil {
 		return err
 	}
+	metaH.Release()
 	if metaH.ID() != metaPageID {
-		metaH.Release()
 		return fmt.Errorf("btree/v2: expected metaPageID=%d, got %d", metaPageID, metaH.ID())
 	}
 
 	rootH, err := tr.bp.NewPage()
 	if err != nil {
-		metaH.Release()
 		return err
 	}
 	if tr.isVariable {
@@ -329,6 +361,9 @@ func (tr *BTreeV2) initFreshTree() error {
 	rootPageID := rootH.ID()
 	rootH.Release()
 
+	if metaH, err = tr.bp.FetchForWrite(metaPageID); err != nil {
+		return err
+	}
 	m := treeMeta{
 		magic:      treeMetaMagic,
 		version:    treeMetaVersion,
@@ -340,6 +375,7 @@ func (tr *BTreeV2) initFreshTree() error {
 
 	tr.metaMu.Lock()
 	tr.rootPageID = rootPageID
+	tr.optRoot.Store(uint64(rootPageID))
 	tr.metaMu.Unlock()
 	return tr.bp.FlushAll()
 }
@@ -689,6 +725,7 @@ func (tr *BTreeV2) updateRootLocked(newRootPageID pagestore.PageID) error {
 	tr.markDirty(metaH)
 
 	tr.rootPageID = newRootPageID
+	tr.optRoot.Store(uint64(newRootPageID))
 	return nil
 }
 
@@ -763,6 +800,13 @@ func (tr *BTreeV2) scanLocked(start, end *uint64, fn func(key types.Comparable,
 }
 
 func (tr *BTreeV2) findLeafForKey(encKey uint64) (pagestore.PageID, error) {
+	if h, ok, err := tr.optimisticLeaf(tr.routeFixed(encKey)); err != nil {
+		return pagestore.InvalidPageID, err
+	} else if ok {
+		h.Release()
+		return h.ID(), nil
+	}
+
 	pageID := tr.rootPage()
 	for {
 		h, err := tr.bp.Fetch(pageID)
@@ -806,7 +850,9 @@ func (tr *BTreeV2) findLeftmostLeaf() (pagestore.PageID, error) {
 	}
 }
 
-// Get busca `key`. RLock — múltiplos Gets em paralelo.
+// Get looks up `key`. It descends through the internal nodes without
+// latches (see optimistic.go) and reads only the leaf under RLock, so
+// many Gets run in parallel.
 func (tr *BTreeV2) Get(key types.Comparable) (int64, bool, error) {
 	if tr.isVariable {
 		return tr.getLockedVar(tr.varCodec.Encode(key))
@@ -814,9 +860,22 @@ func (tr *BTreeV2) Get(key types.Comparable) (int64, bool, error) {
 	return tr.getLocked(tr.codec.Encode(key))
 }
 
-// getLocked lê a tree usando apenas um snapshot rápido do rootPageID
-// + latch crabbing de read entre pages.
+// getLocked reads the tree through the optimistic descent or, if it gives
+// up, with a quick snapshot of rootPageID + read latch crabbing between
+// pages.
 func (tr *BTreeV2) getLocked(encKey uint64) (int64, bool, error) {
+	if h, ok, err := tr.optimisticLeaf(tr.routeFixed(encKey)); err != nil {
+		return 0, false, err
+	} else if ok {
+		defer h.Release()
+		np, err := OpenNodePage(h.Page(), tr.maxBodySize, tr.codec.Compare)
+		if err != nil {
+			return 0, false, err
+		}
+		v, found := np.LeafGet(encKey)
+		return v, found, nil
+	}
+
 	pageID := tr.rootPage()
 	for {
 		h, err := tr.bp.Fetch(pageID)
@@ -343,9 +343,22 @@ func (tr *BTreeV2) removeCrabbingVar(encKey []byte) (bool, error) {
 	return true, nil
 }
 
-// getLockedVar lê a tree variável com snapshot rápido do rootPageID
-// + latch crabbing de read entre pages.
+// getLockedVar reads the variable tree through the optimistic descent
+// or, if it gives up, with a quick snapshot of rootPageID + read latch
+// crabbing between pages.
 func (tr *BTreeV2) getLockedVar(encKey []byte) (int64, bool, error) {
+	if h, ok, err := tr.optimisticLeaf(tr.routeVar(encKey)); err != nil {
+		return 0, false, err
+	} else if ok {
+		defer h.Release()
+		vp, err := OpenVariableNodePage(h.Page(), tr.maxBodySize, tr.varCodec.Compare)
+		if err != nil {
+			return 0, false, err
+		}
+		v, found := vp.LeafGetVar(encKey)
+		return v, found, nil
+	}
+
 	pageID := tr.rootPage()
 	for {
 		h, err := tr.bp.Fetch(pageID)
@@ -423,6 +436,13 @@ func (tr *BTreeV2) scanLockedVar(start, end []byte, fn func(key types.Comparable
 }
 
 func (tr *BTreeV2) findLeafForKeyVar(encKey []byte) (pagestore.PageID, error) {
+	if h, ok, err := tr.optimisticLeaf(tr.routeVar(encKey)); err != nil {
+		return pagestore.InvalidPageID, err
+	} else if ok {
+		h.Release()
+		return h.ID(), nil
+	}
+
 	pageID := tr.rootPage()
 	for {
 		h, err := tr.bp.Fetch(pageID)
@@ -0,0 +1,295 @@
+package v2
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/bobboyms/storage-engine/pkg/btree"
+	"github.com/bobboyms/storage-engine/pkg/pagestore"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// bulkChild is the first key of a page already written and its PageID;
+// the level above is built from them.
+type bulkChild[K any] struct {
+	first K
+	pid   pagestore.PageID
+}
+
+// BulkLoad fills an empty tree bottom-up. keys must be in strictly
+// ascending order; values[i] belongs to keys[i]. Leaves are packed from
+// left to right and each internal level is built from the first keys of
+// the level below, so no page ever splits.
+//
+// The pages get no LSN (there is no WAL behind them): the caller makes
+// the load durable with Sync.
+func (tr *BTreeV2) BulkLoad(keys []types.Comparable, values []int64) error {
+	if len(keys) != len(values) {
+		return fmt.Errorf("btree/v2: bulk load has %d keys and %d values", len(keys), len(values))
+	}
+
+	tr.writeMu.Lock()
+	defer tr.writeMu.Unlock()
+	tr.metaMu.Lock()
+	defer tr.metaMu.Unlock()
+
+	if err := tr.checkEmptyLocked(); err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	if tr.isVariable {
+		return tr.bulkLoadVar(keys, values)
+	}
+	return tr.bulkLoadFixed(keys, values)
+}
+
+// checkEmptyLocked fails when the root is not an empty leaf.
+func (tr *BTreeV2) checkEmptyLocked() error {
+	h, err := tr.bp.Fetch(tr.rootPageID)
+	if err != nil {
+		return err
+	}
+	defer h.Release()
+
+	var leaf bool
+	var n int
+	if tr.isVariable {
+		vp, err := OpenVariableNodePage(h.Page(), tr.maxBodySize, tr.varCodec.Compare)
+		if err != nil {
+			return err
+		}
+		leaf, n = vp.IsLeaf(), vp.NumKeys()
+	} else {
+		np, err := OpenNodePage(h.Page(), tr.maxBodySize, tr.codec.Compare)
+		if err != nil {
+			return err
+		}
+		leaf, n = np.IsLeaf(), np.NumKeys()
+	}
+	if !leaf || n != 0 {
+		return fmt.Errorf("btree/v2: bulk load requires an empty tree")
+	}
+	return nil
+}
+
+// bulkGroups splits n consecutive items into greedy groups: item i costs
+// cost(i) and a group holds up to budget. On internal levels the first
+// item of each group is the leftmost child and costs nothing; a last
+// group with a single child takes the last child of the group before it,
+// so that no internal node is left without a separator.
+func bulkGroups(n, budget int, internal bool, cost func(i int) int) [][2]int {
+	var groups [][2]int
+	start, used := 0, 0
+	for i := 0; i < n; i++ {
+		c := cost(i)
+		if internal && i == start {
+			c = 0
+		}
+		if i > start && used+c > budget {
+			groups = append(groups, [2]int{start, i})
+			start, used = i, 0
+			if internal {
+				c = 0
+			}
+		}
+		used += c
+	}
+	groups = append(groups, [2]int{start, n})
+
+	last := len(groups) - 1
+	if internal && last > 0 && groups[last][1]-groups[last][0] == 1 {
+		groups[last-1][1]--
+		groups[last][0]--
+	}
+	return groups
+}
+
+func (tr *BTreeV2) bulkLoadFixed(keys []types.Comparable, values []int64) error {
+	encoded := make([]uint64, len(keys))
+	for i, key := range keys {
+		encoded[i] = tr.codec.Encode(key)
+		if i > 0 && tr.codec.Compare(encoded[i-1], encoded[i]) >= 0 {
+			return fmt.Errorf("btree/v2: bulk load keys not strictly ascending at %d", i)
+		}
+	}
+
+	perLeaf := (tr.maxBodySize - NodeHeaderSize) / LeafSlotSize
+	leaves := bulkGroups(len(encoded), perLeaf, false, func(int) int { return 1 })
+
+	level := make([]bulkChild[uint64], 0, len(leaves))
+	var prevH *pagestore.PageHandle
+	var prevNP *NodePage
+	for i, g := range leaves {
+		h, err := tr.bulkPage(i == 0)
+		if err != nil {
+			if prevH != nil {
+				prevH.Release()
+			}
+			return err
+		}
+		np := InitLeafPage(h.Page(), tr.maxBodySize, tr.codec.Compare)
+		for j := g[0]; j < g[1]; j++ {
+			np.writeLeafSlot(j-g[0], encoded[j], values[j])
+		}
+		hdr := np.header()
+		hdr.numKeys = uint16(g[1] - g[0])
+		np.writeHeader(hdr)
+		tr.markDirty(h)
+
+		if prevH != nil {
+			prevNP.setNextLeafPageID(h.ID())
+			prevH.Release()
+		}
+		prevH, prevNP = h, np
+		level = append(level, bulkChild[uint64]{first: encoded[g[0]], pid: h.ID()})
+	}
+	prevH.Release()
+
+	perInternal := (tr.maxBodySize - NodeHeaderSize - LeftmostChildSize) / InternalSlotSize
+	for len(level) > 1 {
+		groups := bulkGroups(len(level), perInternal, true, func(int) int { return 1 })
+		parents := make([]bulkChild[uint64], 0, len(groups))
+		for _, g := range groups {
+			h, err := tr.bp.NewPage()
+			if err != nil {
+				return err
+			}
+			np := InitInternalPage(h.Page(), tr.maxBodySize, level[g[0]].pid, tr.codec.Compare)
+			for j := g[0] + 1; j < g[1]; j++ {
+				np.writeInternalSlot(j-g[0]-1, level[j].first, level[j].pid)
+			}
+			hdr := np.header()
+			hdr.numKeys = uint16(g[1] - g[0] - 1)
+			np.writeHeader(hdr)
+			tr.markDirty(h)
+			parents = append(parents, bulkChild[uint64]{first: level[g[0]].first, pid: h.ID()})
+			h.Release()
+		}
+		level = parents
+	}
+	return tr.bulkSetRootLocked(level[0].pid)
+}
+
+func (tr *BTreeV2) bulkLoadVar(keys []types.Comparable, values []int64) error {
+	encoded := make([][]byte, len(keys))
+	for i, key := range keys {
+		encoded[i] = tr.varCodec.Encode(key)
+		if i > 0 && tr.varCodec.Compare(encoded[i-1], encoded[i]) >= 0 {
+			return fmt.Errorf("btree/v2: bulk load keys not strictly ascending at %d", i)
+		}
+	}
+
+	var scratch pagestore.Page
+	leafBudget := InitLeafPageVar(&scratch, tr.maxBodySize, tr.varCodec.Compare).FreeSpace()
+	leaves := bulkGroups(len(encoded), leafBudget, false, func(i int) int {
+		return VariableSlotSize + len(encoded[i])
+	})
+
+	level := make([]bulkChild[[]byte], 0, len(leaves))
+	var prevH *pagestore.PageHandle
+	var prevVP *VariableNodePage
+	for i, g := range leaves {
+		h, err := tr.bulkPage(i == 0)
+		if err != nil {
+			if prevH != nil {
+				prevH.Release()
+			}
+			return err
+		}
+		entries := make([]varLeafEntry, 0, g[1]-g[0])
+		for j := g[0]; j < g[1]; j++ {
+			entries = append(entries, varLeafEntry{key: encoded[j], value: values[j]})
+		}
+		vp := InitLeafPageVar(h.Page(), tr.maxBodySize, tr.varCodec.Compare)
+		if err := vp.resetLeafVar(entries, pagestore.InvalidPageID); err != nil {
+			h.Release()
+			if prevH != nil {
+				prevH.Release()
+			}
+			return err
+		}
+		tr.markDirty(h)
+
+		if prevH != nil {
+			prevVP.setNextLeafPageID(h.ID())
+			prevH.Release()
+		}
+		prevH, prevVP = h, vp
+		level = append(level, bulkChild[[]byte]{first: encoded[g[0]], pid: h.ID()})
+	}
+	prevH.Release()
+
+	internalBudget := InitInternalPageVar(&scratch, tr.maxBodySize, pagestore.InvalidPageID, tr.varCodec.Compare).FreeSpace()
+	for len(level) > 1 {
+		groups := bulkGroups(len(level), internalBudget, true, func(i int) int {
+			return VariableSlotSize + len(level[i].first)
+		})
+		parents := make([]bulkChild[[]byte], 0, len(groups))
+		for _, g := range groups {
+			h, err := tr.bp.NewPage()
+			if err != nil {
+				return err
+			}
+			vp := InitInternalPageVar(h.Page(), tr.maxBodySize, level[g[0]].pid, tr.varCodec.Compare)
+			for j := g[0] + 1; j < g[1]; j++ {
+				if err := vp.InsertSeparatorVar(level[j].first, level[j].pid); err != nil {
+					h.Release()
+					return err
+				}
+			}
+			tr.markDirty(h)
+			parents = append(parents, bulkChild[[]byte]{first: level[g[0]].first, pid: h.ID()})
+			h.Release()
+		}
+		level = parents
+	}
+	return tr.bulkSetRootLocked(level[0].pid)
+}
+
+// bulkPage returns the page of the next leaf: the first reuses the empty
+// root, the others are allocated in sequence.
+func (tr *BTreeV2) bulkPage(first bool) (*pagestore.PageHandle, error) {
+	if first {
+		return tr.bp.FetchForWrite(tr.rootPageID)
+	}
+	return tr.bp.NewPage()
+}
+
+func (tr *BTreeV2) bulkSetRootLocked(root pagestore.PageID) error {
+	if root == tr.rootPageID {
+		return nil
+	}
+	return tr.updateRootLocked(root)
+}
+
+// BulkLoad fills an empty PostingTree with the pairs (keys[i],
+// values[i]), in any order; repeated pairs are stored once.
+func (pt *PostingTree) BulkLoad(keys []types.Comparable, values []int64) error {
+	if len(keys) != len(values) {
+		return fmt.Errorf("btree/v2: bulk load has %d keys and %d values", len(keys), len(values))
+	}
+	codec := pt.tree.varCodec
+	type posting struct {
+		encoded []byte
+		key     btree.PostingKey
+	}
+	postings := make([]posting, len(keys))
+	for i, key := range keys {
+		pk := btree.PostingKey{Key: key, Value: values[i]}
+		postings[i] = posting{encoded: codec.Encode(pk), key: pk}
+	}
+	slices.SortFunc(postings, func(a, b posting) int { return codec.Compare(a.encoded, b.encoded) })
+
+	sortedKeys := make([]types.Comparable, 0, len(postings))
+	sortedValues := make([]int64, 0, len(postings))
+	for i, p := range postings {
+		if i > 0 && codec.Compare(postings[i-1].encoded, p.encoded) == 0 {
+			continue
+		}
+		sortedKeys = append(sortedKeys, p.key)
+		sortedValues = append(sortedValues, p.key.Value)
+	}
+	return pt.tree.BulkLoad(sortedKeys, sortedValues)
+}
@@ -0,0 +1,135 @@
+package v2
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+func TestBulkLoad_FixedBuildsEveryLevel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bulk.btree.v2")
+	tr, err := NewBTreeV2(path, 16, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Enough keys for two internal levels.
+	const n = 70000
+	keys := make([]types.Comparable, n)
+	values := make([]int64, n)
+	for i := range keys {
+		keys[i] = k(int64(i) * 2)
+		values[i] = int64(i)
+	}
+	if err := tr.BulkLoad(keys, values); err != nil {
+		t.Fatalf("BulkLoad: %v", err)
+	}
+	if err := tr.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tr, err = NewBTreeV2(path, 16, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+
+	count := 0
+	if err := tr.ScanAll(func(key types.Comparable, value int64) error {
+		if key != k(int64(count)*2) || value != int64(count) {
+			return fmt.Errorf("pos %d: got (%v, %d)", count, key, value)
+		}
+		count++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if count != n {
+		t.Fatalf("expected %d keys, got %d", n, count)
+	}
+	for _, i := range []int64{0, 1, 4097, n / 2, n - 1} {
+		if v, found, err := tr.Get(k(i * 2)); err != nil || !found || v != i {
+			t.Fatalf("Get(%d): v=%d found=%v err=%v", i*2, v, found, err)
+		}
+	}
+
+	// The loaded tree keeps working with the usual split/merge paths.
+	for i := int64(0); i < 3000; i++ {
+		if err := tr.Insert(k(i*2+1), -i); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+		if _, err := tr.Delete(k(i * 2)); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+	}
+	if v, found, _ := tr.Get(k(2999*2 + 1)); !found || v != -2999 {
+		t.Fatalf("inserted key after bulk load: v=%d found=%v", v, found)
+	}
+	if _, found, _ := tr.Get(k(0)); found {
+		t.Fatal("deleted key still found")
+	}
+}
+
+func TestBulkLoad_Varchar(t *testing.T) {
+	tr := newVarcharTree(t)
+	const n = 5000
+	keys := make([]types.Comparable, n)
+	values := make([]int64, n)
+	for i := range keys {
+		keys[i] = s(fmt.Sprintf("user-%06d", i))
+		values[i] = int64(i)
+	}
+	if err := tr.BulkLoad(keys, values); err != nil {
+		t.Fatalf("BulkLoad: %v", err)
+	}
+
+	var got []int64
+	if err := tr.Scan(s("user-001000"), s("user-001004"), func(_ types.Comparable, value int64) error {
+		got = append(got, value)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, []int64{1000, 1001, 1002, 1003, 1004}) {
+		t.Fatalf("range scan: got %v", got)
+	}
+	if v, found, _ := tr.Get(s("user-004999")); !found || v != 4999 {
+		t.Fatalf("Get last key: v=%d found=%v", v, found)
+	}
+}
+
+func TestBulkLoad_PostingTreeSortsPairs(t *testing.T) {
+	pt := newPostingTree(t, filepath.Join(t.TempDir(), "posting.v2"), VarcharKeyCodec{})
+	defer pt.Close()
+
+	keys := []types.Comparable{s("b"), s("a"), s("b"), s("a"), s("b")}
+	values := []int64{30, 20, 10, 20, 50}
+	if err := pt.BulkLoad(keys, values); err != nil {
+		t.Fatalf("BulkLoad: %v", err)
+	}
+	if got, _ := pt.GetAll(s("a")); !slices.Equal(got, []int64{20}) {
+		t.Fatalf("postings of a: %v", got)
+	}
+	if got, _ := pt.GetAll(s("b")); !slices.Equal(got, []int64{10, 30, 50}) {
+		t.Fatalf("postings of b: %v", got)
+	}
+}
+
+func TestBulkLoad_Rejects(t *testing.T) {
+	tr := newTree(t, nil)
+	if err := tr.BulkLoad([]types.Comparable{k(2), k(1)}, []int64{0, 0}); err == nil {
+		t.Fatal("expected an error for unsorted keys")
+	}
+	if err := tr.BulkLoad([]types.Comparable{k(1), k(1)}, []int64{0, 0}); err == nil {
+		t.Fatal("expected an error for a repeated key")
+	}
+	if err := tr.Insert(k(1), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.BulkLoad([]types.Comparable{k(5)}, []int64{5}); err == nil {
+		t.Fatal("expected an error for a tree that is not empty")
+	}
+}
@@ -320,3 +320,82 @@ func TestBTreeV2_Varchar_Concurrent_Writers_WithSplits(t *testing.T) {
 		}
 	}
 }
+
+// TestBTreeV2_Optimistic_GetDuringSplits: Gets concurrent with inserts
+// that split internal nodes must always find the keys already inserted.
+func TestBTreeV2_Optimistic_GetDuringSplits(t *testing.T) {
+	tr := newTree(t, nil)
+
+	const total = 20000
+	var inserted atomic.Int64 // keys [0, inserted) are already in the tree
+	var wg sync.WaitGroup
+	var errCount atomic.Int64
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := int64(0); i < total; i++ {
+			if err := tr.Insert(k(i), i*3); err != nil {
+				errCount.Add(1)
+				return
+			}
+			inserted.Store(i + 1)
+		}
+	}()
+
+	for r := 0; r < 8; r++ {
+		wg.Add(1)
+		go func(g int64) {
+			defer wg.Done()
+			for i := g; inserted.Load() < total; i += 7 {
+				n := inserted.Load()
+				if n == 0 {
+					continue
+				}
+				key := i % n
+				v, found, err := tr.Get(k(key))
+				if err != nil || !found || v != key*3 {
+					errCount.Add(1)
+					return
+				}
+			}
+		}(int64(r))
+	}
+	wg.Wait()
+
+	if errCount.Load() != 0 {
+		t.Fatalf("%d errors in Gets during splits (restarts: %d)", errCount.Load(), tr.OptimisticRestarts())
+	}
+}
+
+// TestBTreeV2_Optimistic_GetFetchesOnlyTheLeaf: with the copies of the
+// internal nodes already cached, a Get goes through the buffer pool only
+// for the leaf.
+func TestBTreeV2_Optimistic_GetFetchesOnlyTheLeaf(t *testing.T) {
+	tr := newTree(t, nil)
+	for i := int64(0); i < 5000; i++ {
+		if err := tr.Insert(k(i), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats, err := tr.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Height < 2 {
+		t.Fatalf("height %d, the test needs an internal root", stats.Height)
+	}
+
+	if _, _, err := tr.Get(k(4321)); err != nil {
+		t.Fatal(err)
+	}
+	before := tr.bp.Stats()
+	v, found, err := tr.Get(k(4321))
+	if err != nil || !found || v != 4321 {
+		t.Fatalf("Get = %d, %v, %v", v, found, err)
+	}
+	after := tr.bp.Stats()
+	if fetches := after.Hits + after.Misses - before.Hits - before.Misses; fetches != 1 {
+		t.Fatalf("Get made %d fetches, expected 1 (the leaf only)", fetches)
+	}
+}
@@ -0,0 +1,478 @@
+package v2
+
+import (
+	"sort"
+
+	"github.com/bobboyms/storage-engine/pkg/pagestore"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// Cursor walks the keys of a BTreeV2 in either direction.
+//
+// It buffers the entries of one leaf at a time and holds no latch between
+// calls, so it sees the tree with the same guarantees as Scan: keys
+// inserted or removed while it moves may or may not show up.
+//
+// Leaves only link forward on disk (the variable-key header has no room
+// for a back pointer). Moving back past the first buffered entry descends
+// again from the root to the leaf holding the largest key below it, which
+// costs one root-to-leaf walk per leaf and needs no sibling maintenance on
+// split or merge.
+//
+// Splits running alongside a cursor never make it skip or repeat a key
+// that stays in the tree. A split moves the upper half of a leaf to a new
+// right sibling and links it in under the write latch of the old leaf, so
+// a forward load that finds nothing left in its leaf still reaches those
+// keys through the next pointer. A backward load has no such pointer: the
+// descent keeps the latch of each node until it holds the child's, and the
+// leaf is read under the latch the descent took, so no split can move keys
+// out of it in between.
+type Cursor struct {
+	tr      *BTreeV2
+	entries []cursorEntry
+	pos     int
+}
+
+type cursorEntry struct {
+	key   types.Comparable
+	value int64
+}
+
+// NewCursor returns an unpositioned cursor; call one of the Seek methods
+// before reading it.
+func (tr *BTreeV2) NewCursor() *Cursor {
+	return &Cursor{tr: tr}
+}
+
+// Valid reports whether the cursor is positioned on an entry.
+func (c *Cursor) Valid() bool {
+	return c.pos >= 0 && c.pos < len(c.entries)
+}
+
+// Key returns the key under the cursor. Only meaningful when Valid.
+func (c *Cursor) Key() types.Comparable { return c.entries[c.pos].key }
+
+// Value returns the value under the cursor. Only meaningful when Valid.
+func (c *Cursor) Value() int64 { return c.entries[c.pos].value }
+
+// SeekFirst positions the cursor on the smallest key.
+func (c *Cursor) SeekFirst() error {
+	return c.loadForward(nil, true)
+}
+
+// Seek positions the cursor on the smallest key >= key.
+func (c *Cursor) Seek(key types.Comparable) error {
+	return c.loadForward(key, true)
+}
+
+// SeekLast positions the cursor on the largest key.
+func (c *Cursor) SeekLast() error {
+	return c.loadBackward(nil, true)
+}
+
+// SeekForPrev positions the cursor on the largest key <= key.
+func (c *Cursor) SeekForPrev(key types.Comparable) error {
+	return c.loadBackward(key, true)
+}
+
+// Next moves to the following key. Past the last key the cursor becomes
+// invalid; Next on an invalid cursor does nothing.
+func (c *Cursor) Next() error {
+	if !c.Valid() {
+		return nil
+	}
+	if c.pos+1 < len(c.entries) {
+		c.pos++
+		return nil
+	}
+	return c.loadForward(c.entries[c.pos].key, false)
+}
+
+// Prev moves to the preceding key. Before the first key the cursor becomes
+// invalid; Prev on an invalid cursor does nothing.
+func (c *Cursor) Prev() error {
+	if !c.Valid() {
+		return nil
+	}
+	if c.pos > 0 {
+		c.pos--
+		return nil
+	}
+	return c.loadBackward(c.entries[0].key, false)
+}
+
+func (c *Cursor) loadForward(key types.Comparable, inclusive bool) error {
+	var entries []cursorEntry
+	var err error
+	if c.tr.isVariable {
+		entries, err = c.tr.leafEntriesAfterVar(key, inclusive)
+	} else {
+		entries, err = c.tr.leafEntriesAfter(key, inclusive)
+	}
+	c.entries, c.pos = entries, 0
+	return err
+}
+
+func (c *Cursor) loadBackward(key types.Comparable, inclusive bool) error {
+	var entries []cursorEntry
+	var err error
+	if c.tr.isVariable {
+		entries, err = c.tr.leafEntriesBeforeVar(key, inclusive)
+	} else {
+		entries, err = c.tr.leafEntriesBefore(key, inclusive)
+	}
+	c.entries, c.pos = entries, len(entries)-1
+	return err
+}
+
+// ScanAllReverse walks every key of the tree in descending order.
+func (tr *BTreeV2) ScanAllReverse(fn func(key types.Comparable, value int64) error) error {
+	cur := tr.NewCursor()
+	for err := cur.SeekLast(); ; err = cur.Prev() {
+		if err != nil {
+			return err
+		}
+		if !cur.Valid() {
+			return nil
+		}
+		if err := fn(cur.Key(), cur.Value()); err != nil {
+			return err
+		}
+	}
+}
+
+// ScanReverse percorre [start, end] inclusive em ordem decrescente.
+func (tr *BTreeV2) ScanReverse(start, end types.Comparable, fn func(key types.Comparable, value int64) error) error {
+	below := tr.belowFn(start)
+	cur := tr.NewCursor()
+	for err := cur.SeekForPrev(end); ; err = cur.Prev() {
+		if err != nil {
+			return err
+		}
+		if !cur.Valid() || below(cur.Key()) {
+			return nil
+		}
+		if err := fn(cur.Key(), cur.Value()); err != nil {
+			return err
+		}
+	}
+}
+
+// ScanFrom walks the keys >= start in ascending order, descending from the
+// root straight to start instead of walking from the first leaf.
+func (tr *BTreeV2) ScanFrom(start types.Comparable, fn func(key types.Comparable, value int64) error) error {
+	cur := tr.NewCursor()
+	for err := cur.Seek(start); ; err = cur.Next() {
+		if err != nil {
+			return err
+		}
+		if !cur.Valid() {
+			return nil
+		}
+		if err := fn(cur.Key(), cur.Value()); err != nil {
+			return err
+		}
+	}
+}
+
+// ScanReverseFrom walks the keys <= end in descending order.
+func (tr *BTreeV2) ScanReverseFrom(end types.Comparable, fn func(key types.Comparable, value int64) error) error {
+	cur := tr.NewCursor()
+	for err := cur.SeekForPrev(end); ; err = cur.Prev() {
+		if err != nil {
+			return err
+		}
+		if !cur.Valid() {
+			return nil
+		}
+		if err := fn(cur.Key(), cur.Value()); err != nil {
+			return err
+		}
+	}
+}
+
+// belowFn returns a predicate reporting whether a key sorts before start
+// under the tree's codec.
+func (tr *BTreeV2) belowFn(start types.Comparable) func(types.Comparable) bool {
+	if tr.isVariable {
+		enc := tr.varCodec.Encode(start)
+		return func(k types.Comparable) bool { return tr.varCodec.Compare(tr.varCodec.Encode(k), enc) < 0 }
+	}
+	enc := tr.codec.Encode(start)
+	return func(k types.Comparable) bool { return tr.codec.Compare(tr.codec.Encode(k), enc) < 0 }
+}
+
+// leafEntriesAfter returns the entries of the first leaf holding keys
+// after key (>= when inclusive; nil = from the start).
+func (tr *BTreeV2) leafEntriesAfter(key types.Comparable, inclusive bool) ([]cursorEntry, error) {
+	var enc uint64
+	var leaf pagestore.PageID
+	var err error
+	if key != nil {
+		enc = tr.codec.Encode(key)
+		leaf, err = tr.findLeafForKey(enc)
+	} else {
+		leaf, err = tr.findLeftmostLeaf()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keep := func(k uint64) bool {
+		if key == nil {
+			return true
+		}
+		c := tr.codec.Compare(k, enc)
+		return c > 0 || (inclusive && c == 0)
+	}
+	for leaf != pagestore.InvalidPageID {
+		h, err := tr.bp.Fetch(leaf)
+		if err != nil {
+			return nil, err
+		}
+		entries, next, err := tr.readLeafEntries(h, keep)
+		if err != nil || len(entries) > 0 {
+			return entries, err
+		}
+		leaf = next
+	}
+	return nil, nil
+}
+
+// leafEntriesBefore returns the entries of the last leaf holding keys
+// before key (<= when inclusive; nil = up to the end). When that leaf has
+// none, the search restarts below the separator that bounds it.
+func (tr *BTreeV2) leafEntriesBefore(key types.Comparable, inclusive bool) ([]cursorEntry, error) {
+	var bound *uint64
+	if key != nil {
+		enc := tr.codec.Encode(key)
+		bound = &enc
+	}
+	for {
+		leaf, lower, hasLower, err := tr.findLeafBefore(bound, inclusive)
+		if err != nil {
+			return nil, err
+		}
+		if tr.onCursorLeaf != nil {
+			tr.onCursorLeaf(leaf.ID())
+		}
+		limit, incl := bound, inclusive
+		entries, _, err := tr.readLeafEntries(leaf, func(k uint64) bool {
+			if limit == nil {
+				return true
+			}
+			c := tr.codec.Compare(k, *limit)
+			return c < 0 || (incl && c == 0)
+		})
+		if err != nil || len(entries) > 0 || !hasLower {
+			return entries, err
+		}
+		bound, inclusive = &lower, false
+	}
+}
+
+// findLeafBefore descends to the leaf holding the largest key < bound
+// (<= when inclusive; a nil bound means the rightmost leaf) and returns
+// it with a read latch. It also returns the separator that bounds that
+// leaf on the left, if there is one.
+func (tr *BTreeV2) findLeafBefore(bound *uint64, inclusive bool) (*pagestore.PageHandle, uint64, bool, error) {
+	h, err := tr.fetchRoot()
+	if err != nil {
+		return nil, 0, false, err
+	}
+	var lower uint64
+	hasLower := false
+	for {
+		np, err := OpenNodePage(h.Page(), tr.maxBodySize, tr.codec.Compare)
+		if err != nil {
+			h.Release()
+			return nil, 0, false, err
+		}
+		if np.IsLeaf() {
+			return h, lower, hasLower, nil
+		}
+
+		n := np.NumKeys()
+		j := n
+		if bound != nil {
+			j = sort.Search(n, func(i int) bool {
+				sep, _ := np.InternalAt(i)
+				c := tr.codec.Compare(sep, *bound)
+				return c > 0 || (!inclusive && c == 0)
+			})
+		}
+		nextPageID := np.LeftmostChild()
+		if j > 0 {
+			lower, nextPageID = np.InternalAt(j - 1)
+			hasLower = true
+		}
+		if h, err = tr.fetchChild(h, nextPageID); err != nil {
+			return nil, 0, false, err
+		}
+	}
+}
+
+// fetchRoot returns the root under a read latch. A root split or collapse
+// replaces the root while holding the old one under a write latch, so a
+// latched root that still equals optRoot is the root.
+func (tr *BTreeV2) fetchRoot() (*pagestore.PageHandle, error) {
+	for {
+		root := pagestore.PageID(tr.optRoot.Load())
+		h, err := tr.bp.Fetch(root)
+		if err != nil {
+			return nil, err
+		}
+		if pagestore.PageID(tr.optRoot.Load()) == root {
+			return h, nil
+		}
+		h.Release()
+	}
+}
+
+// fetchChild takes the child's read latch before releasing the parent's
+// (latch coupling): splits and merges of the child hold the parent under
+// a write latch, so the child still covers the range it was chosen for.
+func (tr *BTreeV2) fetchChild(parent *pagestore.PageHandle, child pagestore.PageID) (*pagestore.PageHandle, error) {
+	h, err := tr.bp.Fetch(child)
+	parent.Release()
+	return h, err
+}
+
+// readLeafEntries reads the leaf h holds and releases the latch.
+func (tr *BTreeV2) readLeafEntries(h *pagestore.PageHandle, keep func(k uint64) bool) ([]cursorEntry, pagestore.PageID, error) {
+	defer h.Release()
+	np, err := OpenNodePage(h.Page(), tr.maxBodySize, tr.codec.Compare)
+	if err != nil {
+		return nil, pagestore.InvalidPageID, err
+	}
+
+	var entries []cursorEntry
+	for i := 0; i < np.NumKeys(); i++ {
+		k, v := np.LeafAt(i)
+		if keep(k) {
+			entries = append(entries, cursorEntry{key: tr.codec.Decode(k), value: v})
+		}
+	}
+	return entries, np.NextLeafPageID(), nil
+}
+
+func (tr *BTreeV2) leafEntriesAfterVar(key types.Comparable, inclusive bool) ([]cursorEntry, error) {
+	var enc []byte
+	var leaf pagestore.PageID
+	var err error
+	if key != nil {
+		enc = tr.varCodec.Encode(key)
+		leaf, err = tr.findLeafForKeyVar(enc)
+	} else {
+		leaf, err = tr.findLeftmostLeafVar()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keep := func(k []byte) bool {
+		if enc == nil {
+			return true
+		}
+		c := tr.varCodec.Compare(k, enc)
+		return c > 0 || (inclusive && c == 0)
+	}
+	for leaf != pagestore.InvalidPageID {
+		h, err := tr.bp.Fetch(leaf)
+		if err != nil {
+			return nil, err
+		}
+		entries, next, err := tr.readLeafEntriesVar(h, keep)
+		if err != nil || len(entries) > 0 {
+			return entries, err
+		}
+		leaf = next
+	}
+	return nil, nil
+}
+
+func (tr *BTreeV2) leafEntriesBeforeVar(key types.Comparable, inclusive bool) ([]cursorEntry, error) {
+	var bound []byte
+	if key != nil {
+		bound = tr.varCodec.Encode(key)
+	}
+	for {
+		leaf, lower, err := tr.findLeafBeforeVar(bound, inclusive)
+		if err != nil {
+			return nil, err
+		}
+		if tr.onCursorLeaf != nil {
+			tr.onCursorLeaf(leaf.ID())
+		}
+		limit, incl := bound, inclusive
+		entries, _, err := tr.readLeafEntriesVar(leaf, func(k []byte) bool {
+			if limit == nil {
+				return true
+			}
+			c := tr.varCodec.Compare(k, limit)
+			return c < 0 || (incl && c == 0)
+		})
+		if err != nil || len(entries) > 0 || lower == nil {
+			return entries, err
+		}
+		bound, inclusive = lower, false
+	}
+}
+
+// findLeafBeforeVar is the variable-key counterpart of findLeafBefore;
+// the separator returned is a copy (nil when the leaf has no left bound).
+func (tr *BTreeV2) findLeafBeforeVar(bound []byte, inclusive bool) (*pagestore.PageHandle, []byte, error) {
+	h, err := tr.fetchRoot()
+	if err != nil {
+		return nil, nil, err
+	}
+	var lower []byte
+	for {
+		vp, err := OpenVariableNodePage(h.Page(), tr.maxBodySize, tr.varCodec.Compare)
+		if err != nil {
+			h.Release()
+			return nil, nil, err
+		}
+		if vp.IsLeaf() {
+			return h, lower, nil
+		}
+
+		n := vp.NumKeys()
+		j := n
+		if bound != nil {
+			j = sort.Search(n, func(i int) bool {
+				sep, _ := vp.InternalAtVar(i)
+				c := tr.varCodec.Compare(sep, bound)
+				return c > 0 || (!inclusive && c == 0)
+			})
+		}
+		nextPageID := vp.LeftmostChild()
+		if j > 0 {
+			sep, child := vp.InternalAtVar(j - 1)
+			lower = append([]byte(nil), sep...)
+			nextPageID = child
+		}
+		if h, err = tr.fetchChild(h, nextPageID); err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+func (tr *BTreeV2) readLeafEntriesVar(h *pagestore.PageHandle, keep func(k []byte) bool) ([]cursorEntry, pagestore.PageID, error) {
+	defer h.Release()
+	vp, err := OpenVariableNodePage(h.Page(), tr.maxBodySize, tr.varCodec.Compare)
+	if err != nil {
+		return nil, pagestore.InvalidPageID, err
+	}
+
+	var entries []cursorEntry
+	for i := 0; i < vp.NumKeys(); i++ {
+		k, v := vp.LeafAtVar(i)
+		if keep(k) {
+			// Copy the key: the page body may change after release.
+			keyCopy := append([]byte(nil), k...)
+			entries = append(entries, cursorEntry{key: tr.varCodec.Decode(keyCopy), value: v})
+		}
+	}
+	return entries, vp.NextLeafPageID(), nil
+}
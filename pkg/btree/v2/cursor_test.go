@@ -0,0 +1,337 @@
+package v2
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bobboyms/storage-engine/pkg/pagestore"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// collectBackward walks the cursor with Prev until it becomes invalid.
+func collectBackward(t *testing.T, cur *Cursor) []types.Comparable {
+	t.Helper()
+	var got []types.Comparable
+	for cur.Valid() {
+		got = append(got, cur.Key())
+		if err := cur.Prev(); err != nil {
+			t.Fatalf("Prev: %v", err)
+		}
+	}
+	return got
+}
+
+func TestCursor_SeekLastAndPrev_AcrossLeaves(t *testing.T) {
+	tr := newTree(t, nil)
+	const n = 3000
+	for i := int64(0); i < n; i++ {
+		if err := tr.Insert(k(i*2), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cur := tr.NewCursor()
+	if err := cur.SeekLast(); err != nil {
+		t.Fatalf("SeekLast: %v", err)
+	}
+	got := collectBackward(t, cur)
+	if len(got) != n {
+		t.Fatalf("expected %d keys, got %d", n, len(got))
+	}
+	for i, key := range got {
+		if want := k(int64(n-1-i) * 2); key != want {
+			t.Fatalf("pos %d: expected %v, got %v", i, want, key)
+		}
+	}
+}
+
+func TestCursor_SeekForPrev(t *testing.T) {
+	tr := newTree(t, nil)
+	for i := int64(0); i < 2000; i++ {
+		if err := tr.Insert(k(i*10), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cur := tr.NewCursor()
+	cases := []struct {
+		seek  int64
+		want  int64
+		valid bool
+	}{
+		{seek: 500, want: 500, valid: true},
+		{seek: 505, want: 500, valid: true},
+		{seek: 1_000_000, want: 19990, valid: true},
+		{seek: 0, want: 0, valid: true},
+		{seek: -1, valid: false},
+	}
+	for _, tc := range cases {
+		if err := cur.SeekForPrev(k(tc.seek)); err != nil {
+			t.Fatalf("SeekForPrev(%d): %v", tc.seek, err)
+		}
+		if cur.Valid() != tc.valid {
+			t.Fatalf("SeekForPrev(%d): valid=%v, expected %v", tc.seek, cur.Valid(), tc.valid)
+		}
+		if tc.valid && cur.Key() != k(tc.want) {
+			t.Fatalf("SeekForPrev(%d): expected %d, got %v", tc.seek, tc.want, cur.Key())
+		}
+	}
+
+	// Next and Prev mixed across a leaf boundary land on neighbours.
+	if err := cur.SeekForPrev(k(10_005)); err != nil {
+		t.Fatal(err)
+	}
+	for step := 0; step < 600; step++ {
+		if err := cur.Prev(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cur.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if want := k(10_000 - 599*10); cur.Key() != want {
+		t.Fatalf("expected %v after Prev/Next, got %v", want, cur.Key())
+	}
+}
+
+func TestCursor_PrevAfterDeletes(t *testing.T) {
+	tr := newTree(t, nil)
+	var want []types.Comparable
+	for i := int64(0); i < 4000; i++ {
+		if err := tr.Insert(k(i), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Empties whole runs of leaves, leaving separators without keys.
+	for i := int64(0); i < 4000; i++ {
+		if i%7 == 0 || (i > 1000 && i < 2500) {
+			if _, err := tr.Delete(k(i)); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want = append(want, k(i))
+	}
+	slices.Reverse(want)
+
+	cur := tr.NewCursor()
+	if err := cur.SeekLast(); err != nil {
+		t.Fatal(err)
+	}
+	got := collectBackward(t, cur)
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %d keys in reverse, got %d", len(want), len(got))
+	}
+}
+
+func TestCursor_EmptyTree(t *testing.T) {
+	tr := newTree(t, nil)
+	cur := tr.NewCursor()
+	if err := cur.SeekLast(); err != nil {
+		t.Fatal(err)
+	}
+	if cur.Valid() {
+		t.Fatal("cursor on empty tree should be invalid")
+	}
+	if err := cur.Prev(); err != nil || cur.Valid() {
+		t.Fatalf("Prev on invalid cursor: valid=%v err=%v", cur.Valid(), err)
+	}
+}
+
+func TestCursor_Varchar_Reverse(t *testing.T) {
+	tr := newVarcharTree(t)
+	var want []types.Comparable
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("key-%05d", i)
+		if err := tr.Insert(s(key), int64(i)); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, s(key))
+	}
+
+	cur := tr.NewCursor()
+	if err := cur.SeekForPrev(s("key-01000x")); err != nil {
+		t.Fatal(err)
+	}
+	if !cur.Valid() || cur.Key() != s("key-01000") {
+		t.Fatalf("SeekForPrev: expected key-01000, got valid=%v", cur.Valid())
+	}
+
+	var got []types.Comparable
+	err := tr.ScanReverse(s("key-00500"), s("key-01499"), func(key types.Comparable, _ int64) error {
+		got = append(got, key)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ScanReverse: %v", err)
+	}
+	expected := slices.Clone(want[500:1500])
+	slices.Reverse(expected)
+	if !slices.Equal(got, expected) {
+		t.Fatalf("ScanReverse: expected %d keys, got %d", len(expected), len(got))
+	}
+}
+
+func TestPostingTree_ScanReverse(t *testing.T) {
+	pt := newPostingTree(t, filepath.Join(t.TempDir(), "posting.v2"), IntKeyCodec{})
+	defer pt.Close()
+	for key := int64(1); key <= 300; key++ {
+		for value := int64(0); value < 3; value++ {
+			if err := pt.InsertValue(types.IntKey(key), key*10+value); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var got []int64
+	err := pt.ScanReverse(types.IntKey(100), types.IntKey(101), func(_ types.Comparable, value int64) error {
+		got = append(got, value)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ScanReverse: %v", err)
+	}
+	if want := []int64{1012, 1011, 1010, 1002, 1001, 1000}; !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	count := 0
+	last := int64(1 << 62)
+	err = pt.ScanAllReverse(func(_ types.Comparable, value int64) error {
+		if value >= last {
+			return fmt.Errorf("postings out of order: %d after %d", value, last)
+		}
+		last = value
+		count++
+		return nil
+	})
+	if err != nil || count != 900 {
+		t.Fatalf("ScanAllReverse: count=%d err=%v", count, err)
+	}
+}
+
+func TestScanFrom_BothDirections(t *testing.T) {
+	tr := newTree(t, nil)
+	for i := int64(0); i < 2000; i++ {
+		if err := tr.Insert(k(i*2), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []int64
+	err := tr.ScanFrom(k(3991), func(key types.Comparable, _ int64) error {
+		got = append(got, int64(key.(types.IntKey)))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ScanFrom: %v", err)
+	}
+	if want := []int64{3992, 3994, 3996, 3998}; !slices.Equal(got, want) {
+		t.Fatalf("ScanFrom: expected %v, got %v", want, got)
+	}
+
+	got = got[:0]
+	err = tr.ScanReverseFrom(k(5), func(key types.Comparable, _ int64) error {
+		got = append(got, int64(key.(types.IntKey)))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ScanReverseFrom: %v", err)
+	}
+	if want := []int64{4, 2, 0}; !slices.Equal(got, want) {
+		t.Fatalf("ScanReverseFrom: expected %v, got %v", want, got)
+	}
+}
+
+func TestPostingTree_ScanFrom(t *testing.T) {
+	pt := newPostingTree(t, filepath.Join(t.TempDir(), "posting.v2"), IntKeyCodec{})
+	defer pt.Close()
+	for key := int64(1); key <= 300; key++ {
+		for value := int64(0); value < 3; value++ {
+			if err := pt.InsertValue(types.IntKey(key), key*10+value); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var got []int64
+	err := pt.ScanFrom(types.IntKey(299), func(_ types.Comparable, value int64) error {
+		got = append(got, value)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ScanFrom: %v", err)
+	}
+	if want := []int64{2990, 2991, 2992, 3000, 3001, 3002}; !slices.Equal(got, want) {
+		t.Fatalf("ScanFrom: expected %v, got %v", want, got)
+	}
+
+	got = got[:0]
+	err = pt.ScanReverseFrom(types.IntKey(2), func(_ types.Comparable, value int64) error {
+		got = append(got, value)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ScanReverseFrom: %v", err)
+	}
+	if want := []int64{22, 21, 20, 12, 11, 10}; !slices.Equal(got, want) {
+		t.Fatalf("ScanReverseFrom: expected %v, got %v", want, got)
+	}
+}
+
+// TestCursor_PrevDuringLeafSplit: an insert that splits the leaf between
+// the cursor's descent and its read would move the upper half to a new
+// leaf; the reverse cursor would then skip those keys.
+func TestCursor_PrevDuringLeafSplit(t *testing.T) {
+	tr := newTree(t, nil)
+	const n = 2000
+	for i := int64(0); i < n; i += 2 {
+		if err := tr.Insert(k(i), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var once sync.Once
+	var wg sync.WaitGroup
+	errs := make(chan error, 1)
+	tr.onCursorLeaf = func(pagestore.PageID) {
+		once.Do(func() {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := int64(n/2 + 1); i < n; i += 2 {
+					if err := tr.Insert(k(i), i); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}()
+			time.Sleep(50 * time.Millisecond)
+		})
+	}
+
+	cur := tr.NewCursor()
+	if err := cur.SeekForPrev(k(n - 2)); err != nil {
+		t.Fatal(err)
+	}
+	got := collectBackward(t, cur)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	var evens []types.Comparable
+	for _, key := range got {
+		if key.(types.IntKey)%2 == 0 {
+			evens = append(evens, key)
+		}
+	}
+	if len(evens) != n/2 || evens[0].Compare(k(n-2)) != 0 {
+		t.Fatalf("reverse cursor saw %d of the %d even keys, starting at %v", len(evens), n/2, evens[0])
+	}
+}
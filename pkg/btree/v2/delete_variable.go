@@ -40,12 +40,8 @@ func collectInternalEntriesVar(vp *VariableNodePage) (pagestore.PageID, []varInt
 }
 
 func rebuildLeafVar(vp *VariableNodePage, entries []varLeafEntry, nextLeaf pagestore.PageID) {
-	InitLeafPageVar(vp.page, vp.maxBodySize, vp.cmp)
-	vp.setNextLeafPageID(nextLeaf)
-	for _, entry := range entries {
-		if err := vp.LeafInsertVar(entry.key, entry.value); err != nil {
-			panic(err)
-		}
+	if err := vp.resetLeafVar(entries, nextLeaf); err != nil {
+		panic(err)
 	}
 }
 
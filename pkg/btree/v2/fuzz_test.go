@@ -0,0 +1,187 @@
+package v2
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// Fuzz of the tree operations against an in-memory map. The input is read
+// in groups of 4 bytes [op, a, b, c]; the key comes from (a, b) and c is
+// the value or the length of a run:
+//
+//	0 insert   1 delete   2 upsert   3 insert of c*4 consecutive keys
+//	4 delete of c*4 consecutive keys   5 close and reopen the file
+//
+// After every reopen and at the end, Validate must pass and ScanAll and
+// Get must match the model. Only the first maxFuzzOps operations run, so
+// a large input does not stall the fuzzer.
+
+const maxFuzzOps = 128
+
+type fuzzTree struct {
+	t    *testing.T
+	path string
+	open func(path string) (*BTreeV2, error)
+	key  func(n int64) types.Comparable
+	tr   *BTreeV2
+}
+
+func (f *fuzzTree) reopen() {
+	f.t.Helper()
+	if f.tr != nil {
+		if err := f.tr.Close(); err != nil {
+			f.t.Fatalf("Close: %v", err)
+		}
+	}
+	tr, err := f.open(f.path)
+	if err != nil {
+		f.t.Fatalf("open: %v", err)
+	}
+	f.tr = tr
+}
+
+func (f *fuzzTree) check(model map[int64]int64) {
+	f.t.Helper()
+	if err := f.tr.Validate(); err != nil {
+		f.t.Fatalf("Validate: %v", err)
+	}
+	want := make([]types.Comparable, 0, len(model))
+	byKey := make(map[string]int64, len(model))
+	for n, v := range model {
+		key := f.key(n)
+		want = append(want, key)
+		byKey[fmt.Sprint(key)] = v
+	}
+	sort.Slice(want, func(i, j int) bool { return want[i].Compare(want[j]) < 0 })
+
+	i := 0
+	err := f.tr.ScanAll(func(key types.Comparable, value int64) error {
+		if i >= len(want) || key.Compare(want[i]) != 0 {
+			return fmt.Errorf("scan position %d: key %v, want %v", i, key, want[min(i, len(want)-1)])
+		}
+		if value != byKey[fmt.Sprint(key)] {
+			return fmt.Errorf("scan key %v: value %d, want %d", key, value, byKey[fmt.Sprint(key)])
+		}
+		i++
+		return nil
+	})
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	if i != len(want) {
+		f.t.Fatalf("scan returned %d keys, want %d", i, len(want))
+	}
+	for _, key := range want {
+		value, found, err := f.tr.Get(key)
+		if err != nil || !found || value != byKey[fmt.Sprint(key)] {
+			f.t.Fatalf("Get(%v) = %d, %v, %v; want %d", key, value, found, err, byKey[fmt.Sprint(key)])
+		}
+	}
+}
+
+func (f *fuzzTree) run(data []byte) {
+	f.t.Helper()
+	f.reopen()
+	defer func() { f.tr.Close() }()
+	model := make(map[int64]int64)
+
+	if len(data) > maxFuzzOps*4 {
+		data = data[:maxFuzzOps*4]
+	}
+	for len(data) >= 4 {
+		op, n, c := data[0]%6, int64(int16(uint16(data[1])<<8|uint16(data[2]))), data[3]
+		data = data[4:]
+		switch op {
+		case 0:
+			if err := f.tr.Insert(f.key(n), int64(c)); err != nil {
+				f.t.Fatalf("Insert(%d): %v", n, err)
+			}
+			model[n] = int64(c)
+		case 1:
+			found, err := f.tr.Delete(f.key(n))
+			if err != nil {
+				f.t.Fatalf("Delete(%d): %v", n, err)
+			}
+			if _, ok := model[n]; found != ok {
+				f.t.Fatalf("Delete(%d) found = %v, model has it = %v", n, found, ok)
+			}
+			delete(model, n)
+		case 2:
+			err := f.tr.Upsert(f.key(n), func(old int64, exists bool) (int64, error) {
+				if want, ok := model[n]; exists != ok || (ok && old != want) {
+					return 0, fmt.Errorf("upsert(%d) saw (%d, %v), model has (%d, %v)", n, old, exists, want, ok)
+				}
+				return int64(c) + 1000, nil
+			})
+			if err != nil {
+				f.t.Fatal(err)
+			}
+			model[n] = int64(c) + 1000
+		case 3:
+			for i := int64(0); i < int64(c)*4; i++ {
+				if err := f.tr.Insert(f.key(n+i), n+i); err != nil {
+					f.t.Fatalf("Insert(%d): %v", n+i, err)
+				}
+				model[n+i] = n + i
+			}
+		case 4:
+			for i := int64(0); i < int64(c)*4; i++ {
+				found, err := f.tr.Delete(f.key(n + i))
+				if err != nil {
+					f.t.Fatalf("Delete(%d): %v", n+i, err)
+				}
+				if _, ok := model[n+i]; found != ok {
+					f.t.Fatalf("Delete(%d) found = %v, model has it = %v", n+i, found, ok)
+				}
+				delete(model, n+i)
+			}
+		case 5:
+			f.reopen()
+			f.check(model)
+		}
+	}
+	f.check(model)
+}
+
+func addTreeSeeds(f *testing.F) {
+	f.Add([]byte{0, 0, 1, 7, 1, 0, 1, 0, 2, 0, 2, 3})
+	f.Add([]byte{3, 0, 0, 255, 5, 0, 0, 0, 4, 0, 16, 200, 2, 0, 20, 1})
+	f.Add([]byte{3, 255, 0, 255, 3, 0, 0, 255, 4, 255, 128, 255, 5, 0, 0, 0, 4, 0, 0, 255})
+}
+
+func FuzzBTreeV2_Fixed(f *testing.F) {
+	addTreeSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ft := &fuzzTree{
+			t:    t,
+			path: filepath.Join(t.TempDir(), "fuzz.btree.v2"),
+			open: func(path string) (*BTreeV2, error) { return NewBTreeV2(path, 16, nil) },
+			key:  func(n int64) types.Comparable { return types.IntKey(n) },
+		}
+		ft.run(data)
+	})
+}
+
+// FuzzBTreeV2_Varchar uses keys of varied lengths, which split the pages
+// by bytes rather than by count.
+func FuzzBTreeV2_Varchar(f *testing.F) {
+	addTreeSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ft := &fuzzTree{
+			t:    t,
+			path: filepath.Join(t.TempDir(), "fuzz.varchar.btree.v2"),
+			open: func(path string) (*BTreeV2, error) {
+				return NewBTreeV2Varchar(path, 16, nil, VarcharKeyCodec{})
+			},
+			key: func(n int64) types.Comparable {
+				return types.VarcharKey(fmt.Sprintf("%d%s", n, strings.Repeat("x", int(uint64(n)%97))))
+			},
+		}
+		ft.run(data)
+	})
+}
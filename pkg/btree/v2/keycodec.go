@@ -38,6 +38,40 @@ func (VarcharKeyCodec) Compare(a, b []byte) int {
 	return bytes.Compare(a, b)
 }
 
+// UUIDKeyCodec: the 16 bytes of the UUID. Bytewise comparison (creation
+// order for UUIDv7).
+type UUIDKeyCodec struct{}
+
+func (UUIDKeyCodec) Encode(k types.Comparable) []byte {
+	u := k.(types.UUIDKey)
+	return u[:]
+}
+
+func (UUIDKeyCodec) Decode(b []byte) types.Comparable {
+	var u types.UUIDKey
+	copy(u[:], b)
+	return u
+}
+
+func (UUIDKeyCodec) Compare(a, b []byte) int {
+	return bytes.Compare(a, b)
+}
+
+// BytesKeyCodec: raw bytes, bytewise comparison.
+type BytesKeyCodec struct{}
+
+func (BytesKeyCodec) Encode(k types.Comparable) []byte {
+	return append([]byte(nil), k.(types.BytesKey)...)
+}
+
+func (BytesKeyCodec) Decode(b []byte) types.Comparable {
+	return types.BytesKey(append([]byte(nil), b...))
+}
+
+func (BytesKeyCodec) Compare(a, b []byte) int {
+	return bytes.Compare(a, b)
+}
+
 // KeyCodec abstrai encoding/decoding/comparison de keys para a B+ tree v2.
 //
 // Todas as keys são armazenadas em 8 bytes no page (uint64). O codec é
@@ -165,3 +199,21 @@ func (DateKeyCodec) Compare(a, b uint64) int {
 	}
 	return 0
 }
+
+// ─────────────────────────────────────────────────────────────────────
+// DecimalKeyCodec — DecimalKey ↔ fixed-point units (int64)
+// ─────────────────────────────────────────────────────────────────────
+
+type DecimalKeyCodec struct{}
+
+func (DecimalKeyCodec) Encode(k types.Comparable) uint64 {
+	return uint64(int64(k.(types.DecimalKey)))
+}
+
+func (DecimalKeyCodec) Decode(u uint64) types.Comparable {
+	return types.DecimalKey(int64(u))
+}
+
+func (DecimalKeyCodec) Compare(a, b uint64) int {
+	return IntKeyCodec{}.Compare(a, b)
+}
@@ -0,0 +1,153 @@
+package v2
+
+import "github.com/bobboyms/storage-engine/pkg/pagestore"
+
+// Optimistic reads: Get and Seek descend through the internal nodes
+// without latches, using immutable copies of the pages validated by
+// version (pagestore.PageStamp). Only the leaf is read under a read latch.
+//
+// Protocol (optimistic lock coupling): when moving from a node to its
+// child, the parent's stamp is validated after the child is obtained (a
+// valid copy or a latch). A split or merge that changes the routed child
+// holds the parent under a write latch, which invalidates the stamp, so a
+// parent that is still valid guarantees the route was right while the
+// child was obtained. The root only changes with the old root under a
+// write latch, so a root that validates and still equals optRoot was the
+// root. A failed validation restarts from the root; after
+// optimisticRetries attempts the lookup falls back to the latched path.
+
+// optimisticRetries bounds the restarts before falling back to the
+// latched path.
+const optimisticRetries = 3
+
+// optNode is the copy of an internal page, valid while stamp is.
+type optNode struct {
+	stamp pagestore.PageStamp
+	page  pagestore.Page
+}
+
+// routeFn reads the page of a node and returns the child to follow, or
+// leaf=true when the page is a leaf.
+type routeFn func(page *pagestore.Page) (child pagestore.PageID, leaf bool, err error)
+
+func (tr *BTreeV2) routeFixed(encKey uint64) routeFn {
+	return func(page *pagestore.Page) (pagestore.PageID, bool, error) {
+		np, err := OpenNodePage(page, tr.maxBodySize, tr.codec.Compare)
+		if err != nil {
+			return pagestore.InvalidPageID, false, err
+		}
+		if np.IsLeaf() {
+			return pagestore.InvalidPageID, true, nil
+		}
+		return np.FindChild(encKey), false, nil
+	}
+}
+
+func (tr *BTreeV2) routeVar(encKey []byte) routeFn {
+	return func(page *pagestore.Page) (pagestore.PageID, bool, error) {
+		vp, err := OpenVariableNodePage(page, tr.maxBodySize, tr.varCodec.Compare)
+		if err != nil {
+			return pagestore.InvalidPageID, false, err
+		}
+		if vp.IsLeaf() {
+			return pagestore.InvalidPageID, true, nil
+		}
+		return vp.FindChildVar(encKey), false, nil
+	}
+}
+
+// optimisticLeaf descends to the leaf route picks and returns it under a
+// read latch, or ok=false if validation failed optimisticRetries times
+// (the caller then takes the latched path).
+func (tr *BTreeV2) optimisticLeaf(route routeFn) (leaf *pagestore.PageHandle, ok bool, err error) {
+retry:
+	for attempt := 0; attempt < optimisticRetries; attempt++ {
+		root := pagestore.PageID(tr.optRoot.Load())
+		pageID := root
+		var parent pagestore.PageStamp
+		hasParent := false
+		// valid checks the previous node of the descent: the parent, or
+		// that the root is still the root.
+		valid := func() bool {
+			if hasParent {
+				return parent.Valid()
+			}
+			return pagestore.PageID(tr.optRoot.Load()) == root
+		}
+
+		for {
+			if n := tr.cachedNode(pageID); n != nil {
+				if !valid() {
+					tr.optRestarts.Add(1)
+					continue retry
+				}
+				child, isLeaf, err := route(&n.page)
+				if err != nil || isLeaf {
+					// Copies are always of internal nodes.
+					return nil, false, nil
+				}
+				parent, hasParent = n.stamp, true
+				pageID = child
+				continue
+			}
+
+			h, err := tr.bp.Fetch(pageID)
+			if err != nil {
+				if !valid() {
+					tr.optRestarts.Add(1)
+					continue retry
+				}
+				return nil, false, err
+			}
+			if !valid() {
+				h.Release()
+				tr.optRestarts.Add(1)
+				continue retry
+			}
+			child, isLeaf, err := route(h.Page())
+			if err != nil {
+				h.Release()
+				return nil, false, err
+			}
+			if isLeaf {
+				return h, true, nil
+			}
+			n := tr.cacheNode(pageID, h)
+			h.Release()
+			parent, hasParent = n.stamp, true
+			pageID = child
+		}
+	}
+	return nil, false, nil
+}
+
+// cachedNode returns the valid copy of pageID, if there is one.
+func (tr *BTreeV2) cachedNode(pageID pagestore.PageID) *optNode {
+	v, ok := tr.optNodes.Load(pageID)
+	if !ok {
+		return nil
+	}
+	n := v.(*optNode)
+	if !n.stamp.Valid() {
+		return nil
+	}
+	return n
+}
+
+// cacheNode copies the internal page of h, which the caller holds under a
+// read latch. It keeps at most one copy per buffer pool frame; past that
+// the copy serves only the current descent.
+func (tr *BTreeV2) cacheNode(pageID pagestore.PageID, h *pagestore.PageHandle) *optNode {
+	n := &optNode{stamp: h.Stamp(), page: *h.Page()}
+	if _, known := tr.optNodes.Load(pageID); !known && tr.optCount.Load() >= int64(tr.bp.Capacity()) {
+		return n
+	}
+	if _, loaded := tr.optNodes.Swap(pageID, n); !loaded {
+		tr.optCount.Add(1)
+	}
+	return n
+}
+
+// OptimisticRestarts returns how many optimistic descents restarted from
+// the root because a node changed midway.
+func (tr *BTreeV2) OptimisticRestarts() uint64 { return tr.optRestarts.Load() }
@@ -0,0 +1,438 @@
+package v2
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/bobboyms/storage-engine/pkg/btree"
+	"github.com/bobboyms/storage-engine/pkg/crypto"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// Compile-time assertion: *PartitionedTree satisfies btree.Tree.
+var _ btree.Tree = (*PartitionedTree)(nil)
+
+// PartitionedTree hash-partitions the keys of a unique index over several
+// BTreeV2, each in its own page file. A BTreeV2 serializes its writers,
+// and monotonically increasing keys all land on the rightmost leaf and
+// split the same path up to the root; spreading the keys by hash gives
+// every partition its own writer lock and its own right edge, so up to N
+// writers proceed at once.
+//
+// Point operations go to the partition owning the key. Scans and cursors
+// merge the partitions back into one key order, at the cost of a seek per
+// partition. The number of partitions is fixed when the tree is created:
+// the hash of a key decides its partition, so reopening with another
+// count is refused.
+type PartitionedTree struct {
+	path  string
+	parts []*BTreeV2
+}
+
+// PartitionPath returns the file of partition i of a partitioned tree at
+// path: path itself for the first partition, path + ".p<i>" for the rest.
+func PartitionPath(path string, i int) string {
+	if i == 0 {
+		return path
+	}
+	return fmt.Sprintf("%s.p%d", path, i)
+}
+
+// NewPartitionedTree opens or creates a tree of n partitions at path.
+// open opens or creates one partition file; every partition must use the
+// same key layout. Either all n partition files exist or none does.
+func NewPartitionedTree(path string, n int, open func(path string) (*BTreeV2, error)) (*PartitionedTree, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("btree/v2: %d partitions", n)
+	}
+	existing := 0
+	for i := 0; i < n; i++ {
+		if _, err := os.Stat(PartitionPath(path, i)); err == nil {
+			existing++
+		}
+	}
+	if _, err := os.Stat(PartitionPath(path, n)); err == nil || (existing > 0 && existing < n) {
+		return nil, fmt.Errorf("btree/v2: %s was not created with %d partitions", path, n)
+	}
+
+	pt := &PartitionedTree{path: path, parts: make([]*BTreeV2, 0, n)}
+	for i := 0; i < n; i++ {
+		tr, err := open(PartitionPath(path, i))
+		if err == nil && i > 0 && tr.isVariable != pt.parts[0].isVariable {
+			tr.Close()
+			err = fmt.Errorf("btree/v2: partition %d has another key layout", i)
+		}
+		if err != nil {
+			pt.Close()
+			return nil, err
+		}
+		pt.parts = append(pt.parts, tr)
+	}
+	return pt, nil
+}
+
+// Partitions returns the partition trees, in partition order.
+func (pt *PartitionedTree) Partitions() []*BTreeV2 { return pt.parts }
+
+// Path returns the file path of the first partition.
+func (pt *PartitionedTree) Path() string { return pt.path }
+
+// Cipher returns the cipher of the partition page files.
+func (pt *PartitionedTree) Cipher() crypto.Cipher { return pt.parts[0].Cipher() }
+
+// part returns the partition owning key: FNV-1a of the encoded key,
+// modulo the number of partitions.
+func (pt *PartitionedTree) part(key types.Comparable) *BTreeV2 {
+	if len(pt.parts) == 1 {
+		return pt.parts[0]
+	}
+	tr := pt.parts[0]
+	var enc []byte
+	if tr.isVariable {
+		enc = tr.varCodec.Encode(key)
+	} else {
+		enc = binary.BigEndian.AppendUint64(nil, tr.codec.Encode(key))
+	}
+	h := uint64(14695981039346656037)
+	for _, b := range enc {
+		h ^= uint64(b)
+		h *= 1099511628211
+	}
+	return pt.parts[h%uint64(len(pt.parts))]
+}
+
+func (pt *PartitionedTree) Insert(key types.Comparable, value int64) error {
+	return pt.part(key).Insert(key, value)
+}
+
+func (pt *PartitionedTree) InsertWithLSN(key types.Comparable, value int64, lsn uint64) error {
+	return pt.part(key).InsertWithLSN(key, value, lsn)
+}
+
+func (pt *PartitionedTree) Get(key types.Comparable) (int64, bool, error) {
+	return pt.part(key).Get(key)
+}
+
+func (pt *PartitionedTree) Upsert(key types.Comparable, fn func(oldValue int64, exists bool) (int64, error)) error {
+	return pt.part(key).Upsert(key, fn)
+}
+
+func (pt *PartitionedTree) UpsertWithLSN(key types.Comparable, lsn uint64, fn func(oldValue int64, exists bool) (int64, error)) error {
+	return pt.part(key).UpsertWithLSN(key, lsn, fn)
+}
+
+func (pt *PartitionedTree) Replace(key types.Comparable, value int64) error {
+	return pt.part(key).Replace(key, value)
+}
+
+func (pt *PartitionedTree) ReplaceWithLSN(key types.Comparable, value int64, lsn uint64) error {
+	return pt.part(key).ReplaceWithLSN(key, value, lsn)
+}
+
+func (pt *PartitionedTree) Remove(key types.Comparable) (bool, error) {
+	return pt.part(key).Remove(key)
+}
+
+func (pt *PartitionedTree) DeleteWithLSN(key types.Comparable, lsn uint64) (bool, error) {
+	return pt.part(key).DeleteWithLSN(key, lsn)
+}
+
+// BulkLoad fills an empty tree: keys, in strictly ascending order, are
+// split by partition and each partition is bulk loaded with its share.
+func (pt *PartitionedTree) BulkLoad(keys []types.Comparable, values []int64) error {
+	if len(keys) != len(values) {
+		return fmt.Errorf("btree/v2: bulk load has %d keys and %d values", len(keys), len(values))
+	}
+	index := make(map[*BTreeV2]int, len(pt.parts))
+	for i, tr := range pt.parts {
+		index[tr] = i
+	}
+	partKeys := make([][]types.Comparable, len(pt.parts))
+	partValues := make([][]int64, len(pt.parts))
+	for i, key := range keys {
+		p := index[pt.part(key)]
+		partKeys[p] = append(partKeys[p], key)
+		partValues[p] = append(partValues[p], values[i])
+	}
+	for i, tr := range pt.parts {
+		if err := tr.BulkLoad(partKeys[i], partValues[i]); err != nil {
+			return fmt.Errorf("partition %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Sync flushes the dirty pages of every partition.
+func (pt *PartitionedTree) Sync() error {
+	var errs []error
+	for _, tr := range pt.parts {
+		errs = append(errs, tr.Sync())
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every partition.
+func (pt *PartitionedTree) Close() error {
+	var errs []error
+	for _, tr := range pt.parts {
+		errs = append(errs, tr.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// MemoryUsage sums the in-memory pages of every partition.
+func (pt *PartitionedTree) MemoryUsage() int64 {
+	var total int64
+	for _, tr := range pt.parts {
+		total += tr.MemoryUsage()
+	}
+	return total
+}
+
+// CacheCapacity sums the buffer pool capacities of the partitions.
+func (pt *PartitionedTree) CacheCapacity() int {
+	total := 0
+	for _, tr := range pt.parts {
+		total += tr.CacheCapacity()
+	}
+	return total
+}
+
+// Validate checks the invariants of every partition and that every key is
+// in the partition its hash picks.
+func (pt *PartitionedTree) Validate() error {
+	var errs []error
+	for i, tr := range pt.parts {
+		if err := tr.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("partition %d: %w", i, err))
+		}
+		err := tr.ScanAll(func(key types.Comparable, _ int64) error {
+			if pt.part(key) != tr {
+				return fmt.Errorf("%w: partition %d holds key %v of another partition", ErrInvariant, i, key)
+			}
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Stats sums the stats of the partitions. Height is that of the tallest
+// partition and RootPageID is the root of the first partition.
+func (pt *PartitionedTree) Stats() (TreeStats, error) {
+	var total TreeStats
+	for i, tr := range pt.parts {
+		stats, err := tr.Stats()
+		if err != nil {
+			return total, fmt.Errorf("partition %d: %w", i, err)
+		}
+		if i == 0 {
+			total.RootPageID = stats.RootPageID
+		}
+		total.Keys += stats.Keys
+		total.Height = max(total.Height, stats.Height)
+		total.LeafPages += stats.LeafPages
+		total.InternalPages += stats.InternalPages
+		total.PageLSN = max(total.PageLSN, stats.PageLSN)
+	}
+	return total, nil
+}
+
+// ScanAll walks every key in ascending order.
+func (pt *PartitionedTree) ScanAll(fn func(key types.Comparable, value int64) error) error {
+	return pt.walk((*PartitionedCursor).SeekFirst, true, nil, fn)
+}
+
+// Scan walks [start, end] inclusive.
+func (pt *PartitionedTree) Scan(start, end types.Comparable, fn func(key types.Comparable, value int64) error) error {
+	seek := func(c *PartitionedCursor) error { return c.Seek(start) }
+	return pt.walk(seek, true, func(k types.Comparable) bool { return pt.compare(k, end) > 0 }, fn)
+}
+
+// ScanFrom walks the keys >= start in ascending order.
+func (pt *PartitionedTree) ScanFrom(start types.Comparable, fn func(key types.Comparable, value int64) error) error {
+	seek := func(c *PartitionedCursor) error { return c.Seek(start) }
+	return pt.walk(seek, true, nil, fn)
+}
+
+// ScanAllReverse walks every key in descending order.
+func (pt *PartitionedTree) ScanAllReverse(fn func(key types.Comparable, value int64) error) error {
+	return pt.walk((*PartitionedCursor).SeekLast, false, nil, fn)
+}
+
+// ScanReverse walks [start, end] inclusive in descending order.
+func (pt *PartitionedTree) ScanReverse(start, end types.Comparable, fn func(key types.Comparable, value int64) error) error {
+	seek := func(c *PartitionedCursor) error { return c.SeekForPrev(end) }
+	return pt.walk(seek, false, func(k types.Comparable) bool { return pt.compare(k, start) < 0 }, fn)
+}
+
+// ScanReverseFrom walks the keys <= end in descending order.
+func (pt *PartitionedTree) ScanReverseFrom(end types.Comparable, fn func(key types.Comparable, value int64) error) error {
+	seek := func(c *PartitionedCursor) error { return c.SeekForPrev(end) }
+	return pt.walk(seek, false, nil, fn)
+}
+
+// walk positions a cursor with seek and calls fn for every entry in the
+// given direction until the cursor runs out or stop reports true.
+func (pt *PartitionedTree) walk(seek func(*PartitionedCursor) error, forward bool, stop func(types.Comparable) bool, fn func(key types.Comparable, value int64) error) error {
+	cur := pt.NewCursor()
+	step := cur.Next
+	if !forward {
+		step = cur.Prev
+	}
+	for err := seek(cur); ; err = step() {
+		if err != nil {
+			return err
+		}
+		if !cur.Valid() || (stop != nil && stop(cur.Key())) {
+			return nil
+		}
+		if err := fn(cur.Key(), cur.Value()); err != nil {
+			return err
+		}
+	}
+}
+
+// compare orders two keys the way the partitions do, by their encoding.
+func (pt *PartitionedTree) compare(a, b types.Comparable) int {
+	tr := pt.parts[0]
+	if tr.isVariable {
+		return tr.varCodec.Compare(tr.varCodec.Encode(a), tr.varCodec.Encode(b))
+	}
+	return tr.codec.Compare(tr.codec.Encode(a), tr.codec.Encode(b))
+}
+
+// PartitionedCursor walks the keys of a PartitionedTree in either
+// direction, merging one Cursor per partition. It gives the guarantees
+// of Cursor: keys inserted or removed while it moves may or may not show
+// up.
+type PartitionedCursor struct {
+	pt      *PartitionedTree
+	cursors []*Cursor
+	cur     int // partition under the cursor; -1 when not Valid
+	forward bool
+}
+
+// NewCursor returns an unpositioned cursor; call one of the Seek methods
+// before reading it.
+func (pt *PartitionedTree) NewCursor() *PartitionedCursor {
+	c := &PartitionedCursor{pt: pt, cursors: make([]*Cursor, len(pt.parts)), cur: -1}
+	for i, tr := range pt.parts {
+		c.cursors[i] = tr.NewCursor()
+	}
+	return c
+}
+
+// Valid reports whether the cursor is positioned on an entry.
+func (c *PartitionedCursor) Valid() bool { return c.cur >= 0 }
+
+// Key returns the key under the cursor. Only meaningful when Valid.
+func (c *PartitionedCursor) Key() types.Comparable { return c.cursors[c.cur].Key() }
+
+// Value returns the value under the cursor. Only meaningful when Valid.
+func (c *PartitionedCursor) Value() int64 { return c.cursors[c.cur].Value() }
+
+// SeekFirst positions the cursor on the smallest key.
+func (c *PartitionedCursor) SeekFirst() error {
+	return c.seekAll(true, (*Cursor).SeekFirst)
+}
+
+// Seek positions the cursor on the smallest key >= key.
+func (c *PartitionedCursor) Seek(key types.Comparable) error {
+	return c.seekAll(true, func(pc *Cursor) error { return pc.Seek(key) })
+}
+
+// SeekLast positions the cursor on the largest key.
+func (c *PartitionedCursor) SeekLast() error {
+	return c.seekAll(false, (*Cursor).SeekLast)
+}
+
+// SeekForPrev positions the cursor on the largest key <= key.
+func (c *PartitionedCursor) SeekForPrev(key types.Comparable) error {
+	return c.seekAll(false, func(pc *Cursor) error { return pc.SeekForPrev(key) })
+}
+
+// Next moves to the following key. Past the last key the cursor becomes
+// invalid; Next on an invalid cursor does nothing.
+func (c *PartitionedCursor) Next() error {
+	if !c.Valid() {
+		return nil
+	}
+	if !c.forward {
+		// The other partitions sit before the current key: seek them
+		// past it. A key lives in one partition only, so only the
+		// current one can be on it.
+		key := c.Key()
+		return c.seekAll(true, func(pc *Cursor) error {
+			if err := pc.Seek(key); err != nil || !pc.Valid() || c.pt.compare(pc.Key(), key) != 0 {
+				return err
+			}
+			return pc.Next()
+		})
+	}
+	if err := c.cursors[c.cur].Next(); err != nil {
+		c.cur = -1
+		return err
+	}
+	c.pick()
+	return nil
+}
+
+// Prev moves to the preceding key. Before the first key the cursor
+// becomes invalid; Prev on an invalid cursor does nothing.
+func (c *PartitionedCursor) Prev() error {
+	if !c.Valid() {
+		return nil
+	}
+	if c.forward {
+		key := c.Key()
+		return c.seekAll(false, func(pc *Cursor) error {
+			if err := pc.SeekForPrev(key); err != nil || !pc.Valid() || c.pt.compare(pc.Key(), key) != 0 {
+				return err
+			}
+			return pc.Prev()
+		})
+	}
+	if err := c.cursors[c.cur].Prev(); err != nil {
+		c.cur = -1
+		return err
+	}
+	c.pick()
+	return nil
+}
+
+// seekAll positions every partition cursor with seek and settles on the
+// first of them in the new direction.
+func (c *PartitionedCursor) seekAll(forward bool, seek func(*Cursor) error) error {
+	c.forward, c.cur = forward, -1
+	for i, pc := range c.cursors {
+		if err := seek(pc); err != nil {
+			return fmt.Errorf("partition %d: %w", i, err)
+		}
+	}
+	c.pick()
+	return nil
+}
+
+// pick puts the cursor on the smallest partition key when moving
+// forward, the largest when moving back.
+func (c *PartitionedCursor) pick() {
+	c.cur = -1
+	for i, pc := range c.cursors {
+		if !pc.Valid() {
+			continue
+		}
+		if c.cur < 0 {
+			c.cur = i
+			continue
+		}
+		cmp := c.pt.compare(pc.Key(), c.cursors[c.cur].Key())
+		if (c.forward && cmp < 0) || (!c.forward && cmp > 0) {
+			c.cur = i
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package v2
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+func openPartitioned(t testing.TB, path string, n int) *PartitionedTree {
+	t.Helper()
+	pt, err := NewPartitionedTree(path, n, func(path string) (*BTreeV2, error) {
+		return NewBTreeV2(path, 16, nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pt
+}
+
+// TestPartitionedTree_ConcurrentWritersMergedScan: concurrent writers
+// with increasing keys; Get finds every key and the ordered scans (in
+// both directions) see them all, merging the partitions.
+func TestPartitionedTree_ConcurrentWritersMergedScan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idx.btree.v2")
+	pt := openPartitioned(t, path, 4)
+	defer pt.Close()
+
+	const writers, perWriter = 4, 1500
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for g := 0; g < writers; g++ {
+		wg.Add(1)
+		go func(g int64) {
+			defer wg.Done()
+			for i := int64(0); i < perWriter; i++ {
+				key := i*writers + g
+				if err := pt.Insert(k(key), key*2); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(int64(g))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	const total = writers * perWriter
+
+	for i, tr := range pt.Partitions() {
+		stats, err := tr.Stats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Keys == 0 || stats.Keys == total {
+			t.Fatalf("partition %d holds %d of %d keys", i, stats.Keys, total)
+		}
+	}
+	if err := pt.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(0); i < total; i += 97 {
+		if v, found, err := pt.Get(k(i)); err != nil || !found || v != i*2 {
+			t.Fatalf("Get(%d) = %d, %v, %v", i, v, found, err)
+		}
+	}
+
+	next := int64(0)
+	err := pt.ScanAll(func(key types.Comparable, value int64) error {
+		if key.Compare(k(next)) != 0 || value != next*2 {
+			return fmt.Errorf("ScanAll at %d got %v=%d", next, key, value)
+		}
+		next++
+		return nil
+	})
+	if err != nil || next != total {
+		t.Fatalf("ScanAll: %v after %d keys", err, next)
+	}
+
+	next = 4000
+	err = pt.ScanReverse(k(1000), k(4000), func(key types.Comparable, _ int64) error {
+		if key.Compare(k(next)) != 0 {
+			return fmt.Errorf("ScanReverse at %d got %v", next, key)
+		}
+		next--
+		return nil
+	})
+	if err != nil || next != 999 {
+		t.Fatalf("ScanReverse: %v, stopped at %d", err, next)
+	}
+}
+
+// TestPartitionedCursor_ChangesDirection: alternating Next and Prev move
+// one key at a time even when they switch partitions.
+func TestPartitionedCursor_ChangesDirection(t *testing.T) {
+	pt := openPartitioned(t, filepath.Join(t.TempDir(), "idx.btree.v2"), 3)
+	defer pt.Close()
+	for i := int64(0); i < 600; i += 2 {
+		if err := pt.Insert(k(i), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cur := pt.NewCursor()
+	if err := cur.Seek(k(101)); err != nil || !cur.Valid() || cur.Key().Compare(k(102)) != 0 {
+		t.Fatalf("Seek(101) = %v", cur.Key())
+	}
+	steps := []struct {
+		move func() error
+		want int64
+	}{
+		{cur.Next, 104}, {cur.Prev, 102}, {cur.Prev, 100}, {cur.Next, 102}, {cur.Next, 104},
+	}
+	for i, step := range steps {
+		if err := step.move(); err != nil || !cur.Valid() || cur.Key().Compare(k(step.want)) != 0 {
+			t.Fatalf("step %d: %v, %v; want %d", i, cur.Key(), err, step.want)
+		}
+	}
+	if err := cur.SeekFirst(); err != nil || cur.Prev() != nil || cur.Valid() {
+		t.Fatal("Prev before the first key should invalidate the cursor")
+	}
+	if err := cur.SeekForPrev(k(10_000)); err != nil || cur.Key().Compare(k(598)) != 0 {
+		t.Fatalf("SeekForPrev past the end = %v, %v", cur.Key(), err)
+	}
+}
+
+// TestPartitionedTree_ReopenNeedsSameCount: reopening with another number
+// of partitions would spread the keys differently, so it is refused.
+func TestPartitionedTree_ReopenNeedsSameCount(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idx.btree.v2")
+	pt := openPartitioned(t, path, 3)
+	for i := int64(0); i < 100; i++ {
+		if err := pt.Insert(k(i), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pt.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{2, 4} {
+		_, err := NewPartitionedTree(path, n, func(path string) (*BTreeV2, error) {
+			return NewBTreeV2(path, 16, nil)
+		})
+		if err == nil {
+			t.Fatalf("reopen with %d partitions succeeded", n)
+		}
+	}
+
+	pt = openPartitioned(t, path, 3)
+	defer pt.Close()
+	if v, found, err := pt.Get(k(42)); err != nil || !found || v != 42 {
+		t.Fatalf("Get(42) after reopen = %d, %v, %v", v, found, err)
+	}
+}
+
+// BenchmarkPartitionedInsertParallel measures concurrent inserts of
+// increasing keys into a single tree and into 8 partitions.
+func BenchmarkPartitionedInsertParallel(b *testing.B) {
+	for _, n := range []int{1, 8} {
+		b.Run(fmt.Sprintf("partitions=%d", n), func(b *testing.B) {
+			pt := openPartitioned(b, filepath.Join(b.TempDir(), "idx.btree.v2"), n)
+			defer pt.Close()
+			var next sync.Mutex
+			key := int64(0)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					next.Lock()
+					key++
+					i := key
+					next.Unlock()
+					if err := pt.Insert(k(i), i); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,530 @@
+package v2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/bobboyms/storage-engine/pkg/btree"
+	"github.com/bobboyms/storage-engine/pkg/crypto"
+	"github.com/bobboyms/storage-engine/pkg/pagestore"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// Compile-time assertion: *PostingTree satisfies btree.MultiValueTree.
+var _ btree.MultiValueTree = (*PostingTree)(nil)
+
+// OrderedKeyCodec serializes keys so that bytewise order matches the
+// semantic order of the key. That is what allows (key, value) to be
+// concatenated into a single composite key while keeping every posting
+// of a key contiguous in the tree.
+type OrderedKeyCodec interface {
+	// AppendOrdered appends the order-preserving form of k to dst.
+	AppendOrdered(dst []byte, k types.Comparable) []byte
+
+	// DecodeOrdered reverses AppendOrdered and reports how many bytes it consumed.
+	DecodeOrdered(b []byte) (types.Comparable, int, error)
+}
+
+var errShortOrderedKey = errors.New("btree/v2: ordered key truncated")
+
+const signBit = uint64(1) << 63
+
+func appendOrderedUint64(dst []byte, u uint64) []byte {
+	return binary.BigEndian.AppendUint64(dst, u)
+}
+
+func decodeOrderedUint64(b []byte) (uint64, error) {
+	if len(b) < 8 {
+		return 0, errShortOrderedKey
+	}
+	return binary.BigEndian.Uint64(b[:8]), nil
+}
+
+// IntKey: flipping the sign bit makes negatives sort before positives.
+func (IntKeyCodec) AppendOrdered(dst []byte, k types.Comparable) []byte {
+	return appendOrderedUint64(dst, uint64(int64(k.(types.IntKey)))^signBit)
+}
+
+func (IntKeyCodec) DecodeOrdered(b []byte) (types.Comparable, int, error) {
+	u, err := decodeOrderedUint64(b)
+	if err != nil {
+		return nil, 0, err
+	}
+	return types.IntKey(int64(u ^ signBit)), 8, nil
+}
+
+// FloatKey: negatives get every bit inverted, positives only the sign
+// bit — the classic trick to make IEEE 754 sort as unsigned.
+func (FloatKeyCodec) AppendOrdered(dst []byte, k types.Comparable) []byte {
+	bits := math.Float64bits(float64(k.(types.FloatKey)))
+	if bits&signBit != 0 {
+		bits = ^bits
+	} else {
+		bits |= signBit
+	}
+	return appendOrderedUint64(dst, bits)
+}
+
+func (FloatKeyCodec) DecodeOrdered(b []byte) (types.Comparable, int, error) {
+	bits, err := decodeOrderedUint64(b)
+	if err != nil {
+		return nil, 0, err
+	}
+	if bits&signBit != 0 {
+		bits &^= signBit
+	} else {
+		bits = ^bits
+	}
+	return types.FloatKey(math.Float64frombits(bits)), 8, nil
+}
+
+func (BoolKeyCodec) AppendOrdered(dst []byte, k types.Comparable) []byte {
+	if bool(k.(types.BoolKey)) {
+		return append(dst, 1)
+	}
+	return append(dst, 0)
+}
+
+func (BoolKeyCodec) DecodeOrdered(b []byte) (types.Comparable, int, error) {
+	if len(b) < 1 {
+		return nil, 0, errShortOrderedKey
+	}
+	return types.BoolKey(b[0] != 0), 1, nil
+}
+
+func (DateKeyCodec) AppendOrdered(dst []byte, k types.Comparable) []byte {
+	return appendOrderedUint64(dst, uint64(time.Time(k.(types.DateKey)).UnixNano())^signBit)
+}
+
+func (DateKeyCodec) DecodeOrdered(b []byte) (types.Comparable, int, error) {
+	u, err := decodeOrderedUint64(b)
+	if err != nil {
+		return nil, 0, err
+	}
+	return types.DateKey(time.Unix(0, int64(u^signBit))), 8, nil
+}
+
+// VarcharKey: 0x00 bytes become 0x00 0xFF and the string ends with
+// 0x00 0x01, so "a" < "a\x00" < "ab" still holds after encoding and the
+// composite key suffix never affects the comparison.
+func (VarcharKeyCodec) AppendOrdered(dst []byte, k types.Comparable) []byte {
+	return appendOrderedBytes(dst, []byte(string(k.(types.VarcharKey))))
+}
+
+func (VarcharKeyCodec) DecodeOrdered(b []byte) (types.Comparable, int, error) {
+	out, n, err := decodeOrderedBytes(b)
+	if err != nil {
+		return nil, 0, err
+	}
+	return types.VarcharKey(string(out)), n, nil
+}
+
+// DecimalKey: same layout as IntKey over the fixed-point units.
+func (DecimalKeyCodec) AppendOrdered(dst []byte, k types.Comparable) []byte {
+	return appendOrderedUint64(dst, uint64(int64(k.(types.DecimalKey)))^signBit)
+}
+
+func (DecimalKeyCodec) DecodeOrdered(b []byte) (types.Comparable, int, error) {
+	u, err := decodeOrderedUint64(b)
+	if err != nil {
+		return nil, 0, err
+	}
+	return types.DecimalKey(int64(u ^ signBit)), 8, nil
+}
+
+// UUIDKey: fixed 16 bytes, already in order.
+func (UUIDKeyCodec) AppendOrdered(dst []byte, k types.Comparable) []byte {
+	u := k.(types.UUIDKey)
+	return append(dst, u[:]...)
+}
+
+func (UUIDKeyCodec) DecodeOrdered(b []byte) (types.Comparable, int, error) {
+	var u types.UUIDKey
+	if len(b) < len(u) {
+		return nil, 0, errShortOrderedKey
+	}
+	copy(u[:], b)
+	return u, len(u), nil
+}
+
+// BytesKey: escaped like VarcharKey.
+func (BytesKeyCodec) AppendOrdered(dst []byte, k types.Comparable) []byte {
+	return appendOrderedBytes(dst, k.(types.BytesKey))
+}
+
+func (BytesKeyCodec) DecodeOrdered(b []byte) (types.Comparable, int, error) {
+	out, n, err := decodeOrderedBytes(b)
+	if err != nil {
+		return nil, 0, err
+	}
+	return types.BytesKey(out), n, nil
+}
+
+func appendOrderedBytes(dst, src []byte) []byte {
+	for _, c := range src {
+		if c == 0x00 {
+			dst = append(dst, 0x00, 0xFF)
+			continue
+		}
+		dst = append(dst, c)
+	}
+	return append(dst, 0x00, 0x01)
+}
+
+func decodeOrderedBytes(b []byte) ([]byte, int, error) {
+	out := make([]byte, 0, len(b))
+	for i := 0; i < len(b); i++ {
+		if b[i] != 0x00 {
+			out = append(out, b[i])
+			continue
+		}
+		if i+1 >= len(b) {
+			return nil, 0, errShortOrderedKey
+		}
+		switch b[i+1] {
+		case 0x01:
+			return out, i + 2, nil
+		case 0xFF:
+			out = append(out, 0x00)
+			i++
+		default:
+			return nil, 0, fmt.Errorf("btree/v2: invalid varchar escape 0x%02x", b[i+1])
+		}
+	}
+	return nil, 0, errShortOrderedKey
+}
+
+// NullableKeyCodec is the ordered codec of a nullable index: NullKey is
+// the single byte 0x00 and every other key is Inner's encoding after a
+// 0x01, so NULL sorts before every value.
+type NullableKeyCodec struct {
+	Inner OrderedKeyCodec
+}
+
+func (c NullableKeyCodec) AppendOrdered(dst []byte, k types.Comparable) []byte {
+	if _, ok := k.(types.NullKey); ok {
+		return append(dst, 0x00)
+	}
+	return c.Inner.AppendOrdered(append(dst, 0x01), k)
+}
+
+func (c NullableKeyCodec) DecodeOrdered(b []byte) (types.Comparable, int, error) {
+	if len(b) < 1 {
+		return nil, 0, errShortOrderedKey
+	}
+	if b[0] == 0x00 {
+		return types.NullKey{}, 1, nil
+	}
+	key, n, err := c.Inner.DecodeOrdered(b[1:])
+	if err != nil {
+		return nil, 0, err
+	}
+	return key, n + 1, nil
+}
+
+// CollatedKeyCodec is the ordered codec of a VarcharKey index with a
+// collation: the tree stores Collation.Key of the string, so keys the
+// collation holds equal share one posting list and ranges follow the
+// collation order. DecodeOrdered returns the collation key.
+type CollatedKeyCodec struct {
+	Collation types.Collation
+}
+
+func (c CollatedKeyCodec) AppendOrdered(dst []byte, k types.Comparable) []byte {
+	return appendOrderedBytes(dst, []byte(c.Collation.Key(string(k.(types.VarcharKey)))))
+}
+
+func (CollatedKeyCodec) DecodeOrdered(b []byte) (types.Comparable, int, error) {
+	return VarcharKeyCodec{}.DecodeOrdered(b)
+}
+
+// postingKeyCodec is the VariableKeyCodec of the PostingTree's inner
+// tree: ordered(key) || ordered(value).
+type postingKeyCodec struct {
+	key OrderedKeyCodec
+}
+
+func (c postingKeyCodec) Encode(k types.Comparable) []byte {
+	pk := k.(btree.PostingKey)
+	buf := c.key.AppendOrdered(make([]byte, 0, 24), pk.Key)
+	return appendOrderedUint64(buf, uint64(pk.Value)^signBit)
+}
+
+// Decode panics on a key it cannot decode: every key the tree wrote
+// decodes, so one that does not is corrupt.
+func (c postingKeyCodec) Decode(b []byte) types.Comparable {
+	key, n, err := c.key.DecodeOrdered(b)
+	if err == nil && len(b)-n != 8 {
+		err = fmt.Errorf("value takes %d bytes, want 8", len(b)-n)
+	}
+	if err != nil {
+		panic(fmt.Sprintf("btree/v2: corrupted posting key %x: %v", b, err))
+	}
+	u, _ := decodeOrderedUint64(b[n:])
+	return btree.PostingKey{Key: key, Value: int64(u ^ signBit)}
+}
+
+func (postingKeyCodec) Compare(a, b []byte) int {
+	return bytes.Compare(a, b)
+}
+
+// PostingTree is the page-based non-unique index: every (key, value)
+// pair becomes its own entry in a variable-key BTreeV2, reusing the
+// latch crabbing, split/merge, TDE and page redo machinery unchanged.
+type PostingTree struct {
+	tree *BTreeV2
+}
+
+// NewPostingTree opens or creates a PostingTree at `path`. `keyCodec`
+// defines how the user key is ordered inside the composite key.
+func NewPostingTree(path string, bufferPoolCapacity int, cipher crypto.Cipher, keyCodec OrderedKeyCodec) (*PostingTree, error) {
+	if keyCodec == nil {
+		return nil, fmt.Errorf("btree/v2: keyCodec is required")
+	}
+	tree, err := NewBTreeV2Varchar(path, bufferPoolCapacity, cipher, postingKeyCodec{key: keyCodec})
+	if err != nil {
+		return nil, err
+	}
+	return &PostingTree{tree: tree}, nil
+}
+
+// Close flushes the buffer pool and closes the page file.
+func (pt *PostingTree) Close() error { return pt.tree.Close() }
+
+// Sync flushes dirty pages without closing the tree.
+func (pt *PostingTree) Sync() error { return pt.tree.Sync() }
+
+// Path returns the file path.
+func (pt *PostingTree) Path() string { return pt.tree.Path() }
+
+// Cipher returns the cipher of the page file.
+func (pt *PostingTree) Cipher() crypto.Cipher { return pt.tree.Cipher() }
+
+func (pt *PostingTree) SetBeforeFlushHook(hook func(pageID pagestore.PageID, page *pagestore.Page) error) {
+	pt.tree.SetBeforeFlushHook(hook)
+}
+
+func (pt *PostingTree) DirtyPages() []pagestore.DirtyPageInfo {
+	return pt.tree.DirtyPages()
+}
+
+// MemoryUsage returns the bytes of pages the tree keeps in RAM.
+func (pt *PostingTree) MemoryUsage() int64 { return pt.tree.MemoryUsage() }
+
+func (pt *PostingTree) ApplyPageRedo(pageID pagestore.PageID, page *pagestore.Page, lsn uint64) (bool, error) {
+	return pt.tree.ApplyPageRedo(pageID, page, lsn)
+}
+
+func postingRange(start, end types.Comparable) (btree.PostingKey, btree.PostingKey) {
+	return btree.PostingKey{Key: start, Value: math.MinInt64}, btree.PostingKey{Key: end, Value: math.MaxInt64}
+}
+
+// GetAll returns every posting of `key` in ascending order.
+func (pt *PostingTree) GetAll(key types.Comparable) ([]int64, error) {
+	values := make([]int64, 0, 1)
+	err := pt.Scan(key, key, func(_ types.Comparable, value int64) error {
+		values = append(values, value)
+		return nil
+	})
+	return values, err
+}
+
+var errStopPostingScan = errors.New("btree/v2: stop posting scan")
+
+// Get returns the first posting of `key`.
+func (pt *PostingTree) Get(key types.Comparable) (int64, bool, error) {
+	var (
+		first int64
+		found bool
+	)
+	err := pt.Scan(key, key, func(_ types.Comparable, value int64) error {
+		first, found = value, true
+		return errStopPostingScan
+	})
+	if err != nil && !errors.Is(err, errStopPostingScan) {
+		return 0, false, err
+	}
+	return first, found, nil
+}
+
+// Insert adds (key, value) to the posting list, same as InsertValue.
+func (pt *PostingTree) Insert(key types.Comparable, value int64) error {
+	return pt.InsertValueWithLSN(key, value, 0)
+}
+
+// InsertValue adds (key, value); it is idempotent.
+func (pt *PostingTree) InsertValue(key types.Comparable, value int64) error {
+	return pt.InsertValueWithLSN(key, value, 0)
+}
+
+func (pt *PostingTree) InsertValueWithLSN(key types.Comparable, value int64, lsn uint64) error {
+	return pt.tree.InsertWithLSN(btree.PostingKey{Key: key, Value: value}, value, lsn)
+}
+
+// RemoveValue removes only the (key, value) posting.
+func (pt *PostingTree) RemoveValue(key types.Comparable, value int64) (bool, error) {
+	return pt.RemoveValueWithLSN(key, value, 0)
+}
+
+func (pt *PostingTree) RemoveValueWithLSN(key types.Comparable, value int64, lsn uint64) (bool, error) {
+	return pt.tree.DeleteWithLSN(btree.PostingKey{Key: key, Value: value}, lsn)
+}
+
+// Upsert calls fn with the first posting and adds the returned value as
+// a new posting. Existing postings are kept so older MVCC versions stay
+// reachable through the index.
+func (pt *PostingTree) Upsert(key types.Comparable, fn func(oldValue int64, exists bool) (int64, error)) error {
+	return pt.UpsertWithLSN(key, 0, fn)
+}
+
+func (pt *PostingTree) UpsertWithLSN(key types.Comparable, lsn uint64, fn func(oldValue int64, exists bool) (int64, error)) error {
+	first, exists, err := pt.Get(key)
+	if err != nil {
+		return err
+	}
+	value, err := fn(first, exists)
+	if err != nil {
+		return err
+	}
+	if exists && value == first {
+		return nil
+	}
+	return pt.InsertValueWithLSN(key, value, lsn)
+}
+
+// Replace collapses the posting list of `key` to a single value.
+func (pt *PostingTree) Replace(key types.Comparable, value int64) error {
+	return pt.ReplaceWithLSN(key, value, 0)
+}
+
+func (pt *PostingTree) ReplaceWithLSN(key types.Comparable, value int64, lsn uint64) error {
+	values, err := pt.GetAll(key)
+	if err != nil {
+		return err
+	}
+	for _, v := range values {
+		if v == value {
+			continue
+		}
+		if _, err := pt.RemoveValueWithLSN(key, v, lsn); err != nil {
+			return err
+		}
+	}
+	return pt.InsertValueWithLSN(key, value, lsn)
+}
+
+// Remove deletes every posting of `key`.
+func (pt *PostingTree) Remove(key types.Comparable) (bool, error) {
+	return pt.DeleteWithLSN(key, 0)
+}
+
+func (pt *PostingTree) DeleteWithLSN(key types.Comparable, lsn uint64) (bool, error) {
+	values, err := pt.GetAll(key)
+	if err != nil {
+		return false, err
+	}
+	for _, v := range values {
+		if _, err := pt.RemoveValueWithLSN(key, v, lsn); err != nil {
+			return false, err
+		}
+	}
+	return len(values) > 0, nil
+}
+
+// ScanAll walks every posting in (key, value) order. Duplicate keys
+// are reported once per posting.
+func (pt *PostingTree) ScanAll(fn func(key types.Comparable, value int64) error) error {
+	return pt.tree.ScanAll(func(k types.Comparable, value int64) error {
+		return fn(k.(btree.PostingKey).Key, value)
+	})
+}
+
+// Scan walks the postings whose key is in [start, end] inclusive.
+func (pt *PostingTree) Scan(start, end types.Comparable, fn func(key types.Comparable, value int64) error) error {
+	lo, hi := postingRange(start, end)
+	return pt.tree.Scan(lo, hi, func(k types.Comparable, value int64) error {
+		return fn(k.(btree.PostingKey).Key, value)
+	})
+}
+
+// ScanAllReverse walks every posting in descending (key, value) order.
+func (pt *PostingTree) ScanAllReverse(fn func(key types.Comparable, value int64) error) error {
+	return pt.tree.ScanAllReverse(func(k types.Comparable, value int64) error {
+		return fn(k.(btree.PostingKey).Key, value)
+	})
+}
+
+// ScanReverse walks the postings whose key is in [start, end] inclusive,
+// in descending order.
+func (pt *PostingTree) ScanReverse(start, end types.Comparable, fn func(key types.Comparable, value int64) error) error {
+	lo, hi := postingRange(start, end)
+	return pt.tree.ScanReverse(lo, hi, func(k types.Comparable, value int64) error {
+		return fn(k.(btree.PostingKey).Key, value)
+	})
+}
+
+// ScanFrom walks the postings whose key is >= start, in (key, value)
+// order.
+func (pt *PostingTree) ScanFrom(start types.Comparable, fn func(key types.Comparable, value int64) error) error {
+	return pt.tree.ScanFrom(btree.PostingKey{Key: start, Value: math.MinInt64}, func(k types.Comparable, value int64) error {
+		return fn(k.(btree.PostingKey).Key, value)
+	})
+}
+
+// ScanReverseFrom walks the postings whose key is <= end, in descending
+// (key, value) order.
+func (pt *PostingTree) ScanReverseFrom(end types.Comparable, fn func(key types.Comparable, value int64) error) error {
+	return pt.tree.ScanReverseFrom(btree.PostingKey{Key: end, Value: math.MaxInt64}, func(k types.Comparable, value int64) error {
+		return fn(k.(btree.PostingKey).Key, value)
+	})
+}
+
+// PostingCursor walks the postings of a PostingTree in either direction,
+// in (key, value) order, with the guarantees of Cursor.
+type PostingCursor struct {
+	cur *Cursor
+}
+
+// NewCursor returns an unpositioned cursor over the postings.
+func (pt *PostingTree) NewCursor() *PostingCursor {
+	return &PostingCursor{cur: pt.tree.NewCursor()}
+}
+
+// Valid reports whether the cursor is positioned on a posting.
+func (c *PostingCursor) Valid() bool { return c.cur.Valid() }
+
+// Key returns the key of the posting under the cursor.
+func (c *PostingCursor) Key() types.Comparable { return c.cur.Key().(btree.PostingKey).Key }
+
+// Value returns the value of the posting under the cursor.
+func (c *PostingCursor) Value() int64 { return c.cur.Value() }
+
+// SeekFirst positions the cursor on the first posting.
+func (c *PostingCursor) SeekFirst() error { return c.cur.SeekFirst() }
+
+// Seek positions the cursor on the first posting of the smallest key >= key.
+func (c *PostingCursor) Seek(key types.Comparable) error {
+	return c.cur.Seek(btree.PostingKey{Key: key, Value: math.MinInt64})
+}
+
+// SeekLast positions the cursor on the last posting.
+func (c *PostingCursor) SeekLast() error { return c.cur.SeekLast() }
+
+// SeekForPrev positions the cursor on the last posting of the largest
+// key <= key.
+func (c *PostingCursor) SeekForPrev(key types.Comparable) error {
+	return c.cur.SeekForPrev(btree.PostingKey{Key: key, Value: math.MaxInt64})
+}
+
+// Next moves to the following posting.
+func (c *PostingCursor) Next() error { return c.cur.Next() }
+
+// Prev moves to the preceding posting.
+func (c *PostingCursor) Prev() error { return c.cur.Prev() }
+
+// Validate checks the invariants of the underlying tree; see BTreeV2.Validate.
+func (pt *PostingTree) Validate() error { return pt.tree.Validate() }
@@ -0,0 +1,205 @@
+package v2
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/btree"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+func newPostingTree(t *testing.T, path string, codec OrderedKeyCodec) *PostingTree {
+	t.Helper()
+	tr, err := NewPostingTree(path, 16, nil, codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tr
+}
+
+func TestOrderedKeyCodec_PreservesOrder(t *testing.T) {
+	cases := []struct {
+		name  string
+		codec OrderedKeyCodec
+		keys  []types.Comparable
+	}{
+		{"int", IntKeyCodec{}, []types.Comparable{
+			types.IntKey(math.MinInt64), types.IntKey(-5), types.IntKey(0), types.IntKey(7), types.IntKey(math.MaxInt64),
+		}},
+		{"float", FloatKeyCodec{}, []types.Comparable{
+			types.FloatKey(-10.5), types.FloatKey(-0.25), types.FloatKey(0), types.FloatKey(0.25), types.FloatKey(99.9),
+		}},
+		{"bool", BoolKeyCodec{}, []types.Comparable{types.BoolKey(false), types.BoolKey(true)}},
+		{"varchar", VarcharKeyCodec{}, []types.Comparable{
+			types.VarcharKey(""), types.VarcharKey("a"), types.VarcharKey("a\x00"), types.VarcharKey("a\x00b"), types.VarcharKey("ab"), types.VarcharKey("b"),
+		}},
+		{"decimal", DecimalKeyCodec{}, []types.Comparable{
+			types.DecimalKey(-123400), types.DecimalKey(-1), types.DecimalKey(0), types.DecimalKey(5000), types.DecimalKey(123400),
+		}},
+		{"uuid", UUIDKeyCodec{}, []types.Comparable{
+			types.UUIDKey{}, types.UUIDKey{0x01, 0x89}, types.UUIDKey{0x01, 0x8a}, types.UUIDKey{0xff},
+		}},
+		{"bytes", BytesKeyCodec{}, []types.Comparable{
+			types.BytesKey{}, types.BytesKey{0x00}, types.BytesKey{0x00, 0x01}, types.BytesKey{0x01}, types.BytesKey{0xff, 0x00},
+		}},
+		{"nullable", NullableKeyCodec{Inner: IntKeyCodec{}}, []types.Comparable{
+			types.NullKey{}, types.IntKey(math.MinInt64), types.IntKey(0), types.IntKey(7),
+		}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var prev []byte
+			for i, k := range tc.keys {
+				enc := tc.codec.AppendOrdered(nil, k)
+				got, n, err := tc.codec.DecodeOrdered(append(enc, 0xAA))
+				if err != nil {
+					t.Fatalf("decode %v: %v", k, err)
+				}
+				if n != len(enc) || got.Compare(k) != 0 {
+					t.Fatalf("round trip %v: got %v (%d bytes, want %d)", k, got, n, len(enc))
+				}
+				if i > 0 && bytes.Compare(prev, enc) >= 0 {
+					t.Fatalf("%v does not sort after %v", k, tc.keys[i-1])
+				}
+				prev = enc
+			}
+		})
+	}
+}
+
+func TestPostingKeyCodec_PanicsOnCorruptKey(t *testing.T) {
+	codec := postingKeyCodec{key: IntKeyCodec{}}
+	enc := codec.Encode(btree.PostingKey{Key: types.IntKey(-3), Value: 42})
+	if got := codec.Decode(enc).(btree.PostingKey); got.Key.Compare(types.IntKey(-3)) != 0 || got.Value != 42 {
+		t.Fatalf("round trip: got %+v", got)
+	}
+
+	for _, corrupt := range [][]byte{enc[:4], enc[:12], append(enc, 0x00)} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "corrupted posting key") {
+					t.Fatalf("Decode(%x) recovered %v, want a corruption panic", corrupt, r)
+				}
+			}()
+			codec.Decode(corrupt)
+		}()
+	}
+}
+
+func TestPostingTree_DuplicateKeys(t *testing.T) {
+	tr := newPostingTree(t, filepath.Join(t.TempDir(), "posting.btree.v2"), VarcharKeyCodec{})
+	defer tr.Close()
+
+	for i := int64(0); i < 300; i++ {
+		dept := "eng"
+		if i%3 == 0 {
+			dept = "sales"
+		}
+		if err := tr.InsertValue(s(dept), i); err != nil {
+			t.Fatalf("InsertValue %d: %v", i, err)
+		}
+	}
+	// Re-inserting an existing pair is a no-op.
+	if err := tr.InsertValue(s("sales"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	sales, err := tr.GetAll(s("sales"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sales) != 100 || !slices.IsSorted(sales) {
+		t.Fatalf("expected 100 sorted postings for sales, got %d", len(sales))
+	}
+	eng, _ := tr.GetAll(s("eng"))
+	if len(eng) != 200 {
+		t.Fatalf("expected 200 postings for eng, got %d", len(eng))
+	}
+	if missing, _ := tr.GetAll(s("hr")); len(missing) != 0 {
+		t.Fatalf("expected no postings for hr, got %v", missing)
+	}
+
+	first, found, err := tr.Get(s("sales"))
+	if err != nil || !found || first != 0 {
+		t.Fatalf("Get sales: %d %v %v", first, found, err)
+	}
+
+	removed, err := tr.RemoveValue(s("sales"), 3)
+	if err != nil || !removed {
+		t.Fatalf("RemoveValue: %v %v", removed, err)
+	}
+	if removed, _ := tr.RemoveValue(s("sales"), 3); removed {
+		t.Fatal("second RemoveValue should report false")
+	}
+	sales, _ = tr.GetAll(s("sales"))
+	if len(sales) != 99 || slices.Contains(sales, 3) {
+		t.Fatalf("posting 3 still present: %v", sales)
+	}
+
+	count := 0
+	err = tr.Scan(s("eng"), s("sales"), func(key types.Comparable, _ int64) error {
+		if key != s("eng") && key != s("sales") {
+			t.Fatalf("unexpected key %v", key)
+		}
+		count++
+		return nil
+	})
+	if err != nil || count != 299 {
+		t.Fatalf("Scan: %d postings, err %v", count, err)
+	}
+
+	if err := tr.Replace(s("eng"), 1); err != nil {
+		t.Fatal(err)
+	}
+	if eng, _ := tr.GetAll(s("eng")); !slices.Equal(eng, []int64{1}) {
+		t.Fatalf("Replace should collapse the list, got %v", eng)
+	}
+	if removed, err := tr.Remove(s("sales")); err != nil || !removed {
+		t.Fatalf("Remove: %v %v", removed, err)
+	}
+	if sales, _ := tr.GetAll(s("sales")); len(sales) != 0 {
+		t.Fatalf("expected sales to be gone, got %v", sales)
+	}
+}
+
+func TestPostingTree_PersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "posting.btree.v2")
+	tr := newPostingTree(t, path, IntKeyCodec{})
+	for i := int64(0); i < 50; i++ {
+		if err := tr.InsertValue(types.IntKey(i%5-2), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tr.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tr = newPostingTree(t, path, IntKeyCodec{})
+	defer tr.Close()
+	for k := int64(-2); k <= 2; k++ {
+		values, err := tr.GetAll(types.IntKey(k))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(values) != 10 {
+			t.Fatalf("key %d: expected 10 postings after reopen, got %d", k, len(values))
+		}
+	}
+
+	var keys []int64
+	if err := tr.ScanAll(func(key types.Comparable, _ int64) error {
+		keys = append(keys, int64(key.(types.IntKey)))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 50 || !slices.IsSorted(keys) || keys[0] != -2 {
+		t.Fatalf("ScanAll out of order: %v", keys)
+	}
+}
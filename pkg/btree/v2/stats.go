@@ -0,0 +1,79 @@
+package v2
+
+import (
+	"fmt"
+
+	"github.com/bobboyms/storage-engine/pkg/pagestore"
+)
+
+// TreeStats describes the shape of a tree, counted page by page.
+type TreeStats struct {
+	Keys          int              // entries in the leaves (postings, in a PostingTree)
+	Height        int              // levels from the root to the leaves; 1 = the root only
+	LeafPages     int              // leaves reachable from the root
+	InternalPages int              // internal nodes reachable from the root
+	RootPageID    pagestore.PageID // current root
+	PageLSN       uint64           // highest PageLSN of the tree's pages
+}
+
+// Stats walks the whole tree and counts keys, levels and pages. PageLSN
+// is the highest LSN written to a page, i.e. the last WAL write reflected
+// in the tree; zero if it only received writes without an LSN. Writers
+// wait while Stats runs, as in Validate. An unreadable page is an error:
+// use Validate to diagnose the tree.
+func (tr *BTreeV2) Stats() (TreeStats, error) {
+	tr.writeMu.Lock()
+	defer tr.writeMu.Unlock()
+	tr.metaMu.RLock()
+	defer tr.metaMu.RUnlock()
+
+	stats := TreeStats{RootPageID: tr.rootPageID}
+	var err error
+	if tr.isVariable {
+		err = statsWalk(tr, tr.openValidateNodeVar, tr.rootPageID, 1, &stats, make(map[pagestore.PageID]bool))
+	} else {
+		err = statsWalk(tr, tr.openValidateNodeFixed, tr.rootPageID, 1, &stats, make(map[pagestore.PageID]bool))
+	}
+	return stats, err
+}
+
+// Stats describes the posting tree; Keys counts (key, value) pairs.
+func (pt *PostingTree) Stats() (TreeStats, error) { return pt.tree.Stats() }
+
+func statsWalk[K any](tr *BTreeV2, open func(*pagestore.Page) (validateNode[K], string), pageID pagestore.PageID, depth int, stats *TreeStats, seen map[pagestore.PageID]bool) error {
+	if seen[pageID] {
+		return &InvariantError{PageID: pageID, Detail: "page is reached more than once"}
+	}
+	seen[pageID] = true
+	h, err := tr.bp.Fetch(pageID)
+	if err != nil {
+		return fmt.Errorf("btree/v2: stats: page %d: %w", pageID, err)
+	}
+	if hdr, err := h.Page().GetHeader(); err == nil && hdr.PageLSN > stats.PageLSN {
+		stats.PageLSN = hdr.PageLSN
+	}
+	node, detail := open(h.Page())
+	h.Release()
+	if detail != "" {
+		return &InvariantError{PageID: pageID, Detail: detail}
+	}
+
+	if depth > stats.Height {
+		stats.Height = depth
+	}
+	if node.leaf {
+		stats.LeafPages++
+		stats.Keys += len(node.keys)
+		return nil
+	}
+	stats.InternalPages++
+	for _, child := range node.children {
+		if child == pagestore.InvalidPageID || child == metaPageID {
+			return &InvariantError{PageID: pageID, Detail: fmt.Sprintf("child pointer %d is not a node page", child)}
+		}
+		if err := statsWalk(tr, open, child, depth+1, stats, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
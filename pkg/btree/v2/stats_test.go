@@ -0,0 +1,45 @@
+package v2
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBTreeV2_Stats(t *testing.T) {
+	tr := newTree(t, nil)
+	stats, err := tr.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Keys != 0 || stats.Height != 1 || stats.LeafPages != 1 || stats.InternalPages != 0 {
+		t.Fatalf("empty tree stats = %+v", stats)
+	}
+
+	for i := 0; i < 5000; i++ {
+		if err := tr.InsertWithLSN(k(int64(i)), int64(i), uint64(i+1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		if _, err := tr.Delete(k(int64(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats, err = tr.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Keys != 4000 || stats.Height < 2 || stats.InternalPages == 0 || stats.PageLSN != 5000 || stats.RootPageID != tr.rootPage() {
+		t.Fatalf("stats = %+v", stats)
+	}
+
+	vt := newVarcharTree(t)
+	for i := 0; i < 2000; i++ {
+		if err := vt.Insert(s(fmt.Sprintf("key-%05d", i)), int64(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats, err = vt.Stats(); err != nil || stats.Keys != 2000 || stats.LeafPages < 2 {
+		t.Fatalf("varchar stats = %+v, %v", stats, err)
+	}
+}
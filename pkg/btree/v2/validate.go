@@ -0,0 +1,266 @@
+package v2
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bobboyms/storage-engine/pkg/pagestore"
+)
+
+// ErrInvariant marks the violations found by Validate.
+var ErrInvariant = errors.New("btree/v2: invariant violated")
+
+// InvariantError is an invariant violation found by Validate on page
+// PageID. errors.Is(err, ErrInvariant) holds for all of them.
+type InvariantError struct {
+	PageID pagestore.PageID
+	Detail string
+}
+
+func (e *InvariantError) Error() string {
+	return fmt.Sprintf("btree/v2: page %d: %s", e.PageID, e.Detail)
+}
+
+func (e *InvariantError) Unwrap() error { return ErrInvariant }
+
+// Validate walks the whole tree and checks the B+ tree invariants:
+//
+//   - keys strictly increasing within each node;
+//   - occupancy: no node exceeds the page capacity, no node other than the
+//     root is empty and every internal node has at least one separator
+//     (the half-page minimum is not an invariant: BulkLoad packs the
+//     leaves and variable keys split by bytes, not by count);
+//   - separators: the keys of the subtree of c_i lie in [sep_{i-1}, sep_i);
+//   - every leaf is at the same depth, and the NextLeafPageID chain links
+//     the leaves in tree order, ending at InvalidPageID;
+//   - internal nodes have no NextLeafPageID and every child is a valid
+//     node page, reached exactly once.
+//
+// It returns nil or an errors.Join of *InvariantError, one per violation.
+// Writers wait while Validate runs; it is meant for checking the tree
+// after recovery and in fuzz tests.
+func (tr *BTreeV2) Validate() error {
+	tr.writeMu.Lock()
+	defer tr.writeMu.Unlock()
+	tr.metaMu.RLock()
+	defer tr.metaMu.RUnlock()
+
+	if tr.isVariable {
+		v := &treeValidator[[]byte]{
+			tr:     tr,
+			open:   tr.openValidateNodeVar,
+			cmp:    tr.varCodec.Compare,
+			format: func(k []byte) string { return fmt.Sprintf("%v", tr.varCodec.Decode(k)) },
+		}
+		return v.run(tr.rootPageID)
+	}
+	v := &treeValidator[uint64]{
+		tr:     tr,
+		open:   tr.openValidateNodeFixed,
+		cmp:    tr.codec.Compare,
+		format: func(k uint64) string { return fmt.Sprintf("%v", tr.codec.Decode(k)) },
+	}
+	return v.run(tr.rootPageID)
+}
+
+// validateNode is a node decoded for Validate. In internal nodes, children
+// holds c_0 (leftmost) followed by one child per separator.
+type validateNode[K any] struct {
+	leaf     bool
+	next     pagestore.PageID
+	keys     []K
+	children []pagestore.PageID
+}
+
+type treeValidator[K any] struct {
+	tr     *BTreeV2
+	open   func(page *pagestore.Page) (validateNode[K], string)
+	cmp    func(a, b K) int
+	format func(K) string
+
+	errs      []error
+	seen      map[pagestore.PageID]bool
+	leaves    []pagestore.PageID
+	next      map[pagestore.PageID]pagestore.PageID
+	leafDepth int
+}
+
+func (v *treeValidator[K]) fail(pageID pagestore.PageID, format string, args ...any) {
+	v.errs = append(v.errs, &InvariantError{PageID: pageID, Detail: fmt.Sprintf(format, args...)})
+}
+
+func (v *treeValidator[K]) run(root pagestore.PageID) error {
+	v.seen = make(map[pagestore.PageID]bool)
+	v.next = make(map[pagestore.PageID]pagestore.PageID)
+	v.leafDepth = -1
+	v.walk(metaPageID, root, 0, nil, nil)
+
+	for i, leaf := range v.leaves {
+		want := pagestore.InvalidPageID
+		if i+1 < len(v.leaves) {
+			want = v.leaves[i+1]
+		}
+		if leaf == leafGap || want == leafGap {
+			continue
+		}
+		if got := v.next[leaf]; got != want {
+			v.fail(leaf, "next leaf is %d, want %d", got, want)
+		}
+	}
+	return errors.Join(v.errs...)
+}
+
+// leafGap marks in leaves a subtree that could not be walked; the leaf
+// chain is not checked across it.
+const leafGap = pagestore.InvalidPageID
+
+// walk validates the subtree at pageID, whose keys must lie in
+// [low, high); nil means unbounded. An unreadable page is a violation.
+func (v *treeValidator[K]) walk(parent, pageID pagestore.PageID, depth int, low, high *K) {
+	if pageID == pagestore.InvalidPageID || pageID == metaPageID {
+		v.fail(parent, "child pointer %d is not a node page", pageID)
+		v.leaves = append(v.leaves, leafGap)
+		return
+	}
+	if v.seen[pageID] {
+		v.fail(parent, "child %d is reached more than once", pageID)
+		v.leaves = append(v.leaves, leafGap)
+		return
+	}
+	v.seen[pageID] = true
+
+	h, err := v.tr.bp.Fetch(pageID)
+	if err != nil {
+		v.fail(parent, "child %d cannot be read: %v", pageID, err)
+		v.leaves = append(v.leaves, leafGap)
+		return
+	}
+	node, detail := v.open(h.Page())
+	h.Release()
+	if detail != "" {
+		v.fail(pageID, "%s", detail)
+		v.leaves = append(v.leaves, leafGap)
+		return
+	}
+
+	isRoot := depth == 0
+	switch {
+	case !node.leaf && len(node.keys) == 0:
+		v.fail(pageID, "internal node has no separators")
+	case !isRoot && len(node.keys) == 0:
+		v.fail(pageID, "non-root leaf is empty")
+	}
+	for i := 1; i < len(node.keys); i++ {
+		if v.cmp(node.keys[i-1], node.keys[i]) >= 0 {
+			v.fail(pageID, "keys out of order at slot %d: %s >= %s", i, v.format(node.keys[i-1]), v.format(node.keys[i]))
+			break
+		}
+	}
+	for i, key := range node.keys {
+		if low != nil && v.cmp(key, *low) < 0 {
+			v.fail(pageID, "key %s at slot %d is below separator %s", v.format(key), i, v.format(*low))
+			break
+		}
+		if high != nil && v.cmp(key, *high) >= 0 {
+			v.fail(pageID, "key %s at slot %d is not below separator %s", v.format(key), i, v.format(*high))
+			break
+		}
+	}
+
+	if node.leaf {
+		if v.leafDepth < 0 {
+			v.leafDepth = depth
+		} else if depth != v.leafDepth {
+			v.fail(pageID, "leaf at depth %d, other leaves at depth %d", depth, v.leafDepth)
+		}
+		v.leaves = append(v.leaves, pageID)
+		v.next[pageID] = node.next
+		return
+	}
+
+	if node.next != pagestore.InvalidPageID {
+		v.fail(pageID, "internal node has next leaf pointer %d", node.next)
+	}
+	for i, child := range node.children {
+		childLow, childHigh := low, high
+		if i > 0 {
+			childLow = &node.keys[i-1]
+		}
+		if i < len(node.keys) {
+			childHigh = &node.keys[i]
+		}
+		v.walk(pageID, child, depth+1, childLow, childHigh)
+	}
+}
+
+// openValidateNodeFixed decodes a page of a fixed-key tree, copying the
+// keys. detail says why the page is not a readable node.
+func (tr *BTreeV2) openValidateNodeFixed(page *pagestore.Page) (node validateNode[uint64], detail string) {
+	np, err := OpenNodePage(page, tr.maxBodySize, tr.codec.Compare)
+	if err != nil {
+		return node, err.Error()
+	}
+	if format := np.body[12]; format != keyFormatFixed {
+		return node, fmt.Sprintf("key format %d in a fixed-key tree", format)
+	}
+	node.leaf, node.next = np.IsLeaf(), np.NextLeafPageID()
+	n := np.NumKeys()
+	if node.leaf {
+		if n > np.MaxLeafSlots() {
+			return node, fmt.Sprintf("%d keys exceed leaf capacity %d", n, np.MaxLeafSlots())
+		}
+		for i := 0; i < n; i++ {
+			key, _ := np.LeafAt(i)
+			node.keys = append(node.keys, key)
+		}
+		return node, ""
+	}
+	if n > np.MaxInternalSlots() {
+		return node, fmt.Sprintf("%d separators exceed internal capacity %d", n, np.MaxInternalSlots())
+	}
+	node.children = append(node.children, np.LeftmostChild())
+	for i := 0; i < n; i++ {
+		key, child := np.InternalAt(i)
+		node.keys = append(node.keys, key)
+		node.children = append(node.children, child)
+	}
+	return node, ""
+}
+
+// openValidateNodeVar is the variable-key counterpart: besides the count,
+// it checks that slot_dir and the key bytes fit in the page before reading
+// the keys.
+func (tr *BTreeV2) openValidateNodeVar(page *pagestore.Page) (node validateNode[[]byte], detail string) {
+	vp, err := OpenVariableNodePage(page, tr.maxBodySize, tr.varCodec.Compare)
+	if err != nil {
+		return node, err.Error()
+	}
+	node.leaf, node.next = vp.IsLeaf(), vp.NextLeafPageID()
+	n := vp.NumKeys()
+	keysEnd := vp.maxBodySize - len(vp.leafPrefix())
+	slotEnd := vp.slotDirStart() + n*VariableSlotSize
+	freeEnd := int(vp.freeSpaceEnd())
+	if slotEnd > freeEnd || freeEnd > keysEnd {
+		return node, fmt.Sprintf("%d slots overflow the page: slot dir ends at %d, key bytes start at %d", n, slotEnd, freeEnd)
+	}
+	for i := 0; i < n; i++ {
+		off, length, _ := vp.readSlot(i)
+		if int(off) < freeEnd || int(off)+int(length) > keysEnd {
+			return node, fmt.Sprintf("slot %d key bytes [%d, %d) outside [%d, %d)", i, off, int(off)+int(length), freeEnd, keysEnd)
+		}
+	}
+	if node.leaf {
+		for i := 0; i < n; i++ {
+			key, _ := vp.LeafAtVar(i)
+			node.keys = append(node.keys, cloneBytes(key))
+		}
+		return node, ""
+	}
+	node.children = append(node.children, vp.LeftmostChild())
+	for i := 0; i < n; i++ {
+		key, child := vp.InternalAtVar(i)
+		node.keys = append(node.keys, cloneBytes(key))
+		node.children = append(node.children, child)
+	}
+	return node, ""
+}
@@ -0,0 +1,181 @@
+package v2
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/pagestore"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+func TestBTreeV2_Validate_AfterInsertsAndDeletes(t *testing.T) {
+	tr := newTree(t, nil)
+	if err := tr.Validate(); err != nil {
+		t.Fatalf("Validate empty tree: %v", err)
+	}
+
+	rng := rand.New(rand.NewSource(7))
+	keys := rng.Perm(8000)
+	for _, key := range keys {
+		if err := tr.Insert(k(int64(key)), int64(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tr.Validate(); err != nil {
+		t.Fatalf("Validate after inserts: %v", err)
+	}
+	for _, key := range keys[:6000] {
+		if _, err := tr.Delete(k(int64(key))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tr.Validate(); err != nil {
+		t.Fatalf("Validate after deletes: %v", err)
+	}
+}
+
+func TestBTreeV2_Validate_VarcharAndBulkLoad(t *testing.T) {
+	tr := newVarcharTree(t)
+	for i := 0; i < 3000; i++ {
+		if err := tr.Insert(s(fmt.Sprintf("user-%05d-%s", i, strings.Repeat("x", i%40))), int64(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 3000; i += 3 {
+		if _, err := tr.Delete(s(fmt.Sprintf("user-%05d-%s", i, strings.Repeat("x", i%40)))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tr.Validate(); err != nil {
+		t.Fatalf("Validate varchar tree: %v", err)
+	}
+
+	bulk := newTree(t, nil)
+	keys := make([]types.Comparable, 5001)
+	values := make([]int64, len(keys))
+	for i := range keys {
+		keys[i], values[i] = k(int64(i)), int64(i)
+	}
+	if err := bulk.BulkLoad(keys, values); err != nil {
+		t.Fatal(err)
+	}
+	if err := bulk.Validate(); err != nil {
+		t.Fatalf("Validate bulk loaded tree: %v", err)
+	}
+}
+
+// leafPages returns the leaves of the tree in chain order.
+func leafPages(t *testing.T, tr *BTreeV2) []pagestore.PageID {
+	t.Helper()
+	var leaves []pagestore.PageID
+	pid, err := tr.findLeftmostLeaf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for pid != pagestore.InvalidPageID {
+		leaves = append(leaves, pid)
+		h, err := tr.bp.Fetch(pid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		np, _ := OpenNodePage(h.Page(), tr.maxBodySize, tr.codec.Compare)
+		pid = np.NextLeafPageID()
+		h.Release()
+	}
+	return leaves
+}
+
+// corruptNode applies fn to node pid, marking the page dirty.
+func corruptNode(t *testing.T, tr *BTreeV2, pid pagestore.PageID, fn func(np *NodePage)) {
+	t.Helper()
+	h, err := tr.bp.Fetch(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Release()
+	np, err := OpenNodePage(h.Page(), tr.maxBodySize, tr.codec.Compare)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn(np)
+	h.MarkDirty()
+}
+
+func assertInvariantError(t *testing.T, err error, pid pagestore.PageID, detail string) {
+	t.Helper()
+	if !errors.Is(err, ErrInvariant) {
+		t.Fatalf("Validate = %v, want ErrInvariant", err)
+	}
+	want := fmt.Sprintf("page %d: %s", pid, detail)
+	if !strings.Contains(err.Error(), want) {
+		t.Fatalf("Validate = %v, want %q", err, want)
+	}
+}
+
+func TestBTreeV2_Validate_DetectsCorruption(t *testing.T) {
+	build := func(t *testing.T) (*BTreeV2, []pagestore.PageID) {
+		tr := newTree(t, nil)
+		for i := 0; i < 2000; i++ {
+			if err := tr.Insert(k(int64(i)), int64(i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		leaves := leafPages(t, tr)
+		if len(leaves) < 3 {
+			t.Fatalf("want at least 3 leaves, got %d", len(leaves))
+		}
+		return tr, leaves
+	}
+
+	t.Run("keys out of order", func(t *testing.T) {
+		tr, leaves := build(t)
+		corruptNode(t, tr, leaves[1], func(np *NodePage) {
+			k0, v0 := np.readLeafSlot(0)
+			k1, v1 := np.readLeafSlot(1)
+			np.writeLeafSlot(0, k1, v1)
+			np.writeLeafSlot(1, k0, v0)
+		})
+		assertInvariantError(t, tr.Validate(), leaves[1], "keys out of order at slot 1")
+	})
+
+	t.Run("key outside separators", func(t *testing.T) {
+		tr, leaves := build(t)
+		corruptNode(t, tr, leaves[1], func(np *NodePage) {
+			_, v := np.readLeafSlot(np.NumKeys() - 1)
+			np.writeLeafSlot(np.NumKeys()-1, tr.codec.Encode(k(1_000_000)), v)
+		})
+		assertInvariantError(t, tr.Validate(), leaves[1], "key 1000000 at slot")
+	})
+
+	t.Run("broken leaf chain", func(t *testing.T) {
+		tr, leaves := build(t)
+		corruptNode(t, tr, leaves[0], func(np *NodePage) {
+			np.setNextLeafPageID(leaves[2])
+		})
+		assertInvariantError(t, tr.Validate(), leaves[0], fmt.Sprintf("next leaf is %d, want %d", leaves[2], leaves[1]))
+	})
+
+	t.Run("next pointer on internal node", func(t *testing.T) {
+		tr, leaves := build(t)
+		root := tr.rootPage()
+		corruptNode(t, tr, root, func(np *NodePage) {
+			np.setNextLeafPageID(leaves[0])
+		})
+		assertInvariantError(t, tr.Validate(), root, fmt.Sprintf("internal node has next leaf pointer %d", leaves[0]))
+	})
+
+	t.Run("overfull leaf", func(t *testing.T) {
+		tr, leaves := build(t)
+		var max int
+		corruptNode(t, tr, leaves[2], func(np *NodePage) {
+			max = np.MaxLeafSlots()
+			h := np.header()
+			h.numKeys = uint16(max + 1)
+			np.writeHeader(h)
+		})
+		assertInvariantError(t, tr.Validate(), leaves[2], fmt.Sprintf("%d keys exceed leaf capacity %d", max+1, max))
+	})
+}
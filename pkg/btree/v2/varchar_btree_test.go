@@ -3,8 +3,10 @@ package v2
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/bobboyms/storage-engine/pkg/pagestore"
 	"github.com/bobboyms/storage-engine/pkg/types"
 )
 
@@ -379,3 +381,32 @@ func TestBTreeV2_Varchar_Remove_CollapsesRootToLeaf(t *testing.T) {
 		}
 	}
 }
+
+func TestBTreeV2_Varchar_PrefixCompressionFitsMoreKeys(t *testing.T) {
+	tr := newVarcharTree(t)
+	// Long keys with a common prefix: without compression about 72 would
+	// fit per leaf.
+	const n = 3000
+	prefix := strings.Repeat("tenant-0042/orders/", 5)
+	for i := 0; i < n; i++ {
+		if err := tr.Insert(s(fmt.Sprintf("%s%06d", prefix, i)), int64(i)); err != nil {
+			t.Fatalf("Insert %d: %v", i, err)
+		}
+	}
+	for _, i := range []int{0, 1, n / 2, n - 1} {
+		if v, found, err := tr.Get(s(fmt.Sprintf("%s%06d", prefix, i))); err != nil || !found || v != int64(i) {
+			t.Fatalf("Get %d: v=%d found=%v err=%v", i, v, found, err)
+		}
+	}
+	if _, found, _ := tr.Get(s("tenant-0042")); found {
+		t.Fatal("unexpected key found")
+	}
+
+	pages := tr.pf.NumPages()
+	if leaves := n / 80; pages >= uint64(leaves) {
+		t.Fatalf("expected fewer than %d pages with prefix compression, got %d", leaves, pages)
+	}
+	if usage := tr.MemoryUsage(); usage <= 0 || usage > int64(tr.CacheCapacity())*pagestore.PageSize {
+		t.Fatalf("MemoryUsage out of bounds: %d", usage)
+	}
+}
@@ -1,40 +1,53 @@
 package v2
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 
 	"github.com/bobboyms/storage-engine/pkg/pagestore"
 )
 
-// Layout de variable-key NodePage (leaf ou internal):
+// Layout of a variable-key NodePage (leaf or internal):
 //
 //   [NodeHeader: 16 bytes]
-//   [leftmostChild: 8 bytes]              ← só internal
-//   [slot_dir]: 12 bytes por slot, crescendo a partir do header:
+//   [leftmostChild: 8 bytes]              ← internal only
+//   [slot_dir]: 12 bytes per slot, growing from the header:
 //     slot[i]: keyOffset uint16 | keyLength uint16 | value int64
-//     (em internal, `value` é interpretado como childPageID uint64)
+//     (in internal nodes, `value` is read as childPageID uint64)
 //   [free space]
-//   [key bytes]: crescem de trás pra frente (fim do body → centro)
+//   [key bytes]: grow backwards (end of the body → middle)
 //
-// O `value` do slot segue a mesma convenção do formato fixo:
+// The slot `value` follows the same convention as the fixed format:
 //   - Leaf: RecordID (int64)
-//   - Internal: childPageID (uint64 cast p/ int64)
+//   - Internal: childPageID (uint64 cast to int64)
 //
-// Fragmentação: inserts crescem key bytes pra trás; updates in-place
-// no value (sem realocar key). DELETES (quando implementados) criariam
-// holes — precisariam de compaction pro reaproveitamento ser eficiente.
-// Por ora, sem delete na BTreeV2, fragmentação is not problema.
+// Fragmentation: inserts grow key bytes backwards; updates happen in place
+// on the value (without moving the key). Deletes and splits compact the page.
+//
+// Prefix compression (leaves only): byte 15 of the header holds the
+// length of a prefix shared by every key of the leaf, stored once at the
+// end of the body; the slots hold only the suffix. The prefix is chosen
+// when the leaf is rebuilt (split, delete, merge, bulk load) and shrinks
+// when a key outside it arrives. Comparisons are bytewise — every
+// variable codec compares with bytes.Compare. Older pages have byte 15
+// zeroed, that is, an empty prefix.
 
 const (
 	// VariableSlotSize: keyOffset(2) + keyLength(2) + value(8)
 	VariableSlotSize = 12
 
-	// keyFormatFixed é o header.format pro layout original (keys em 8 bytes fixos).
+	// keyFormatFixed is the header.format of the original layout (fixed 8-byte keys).
 	keyFormatFixed uint8 = 0
 
-	// keyFormatVariable é o header.format pro layout slotted.
+	// keyFormatVariable is the header.format of the slotted layout.
 	keyFormatVariable uint8 = 1
+
+	// leafPrefixLenOffset is the header byte holding the prefix length.
+	leafPrefixLenOffset = 15
+
+	// maxLeafPrefix is the longest common prefix stored (fits in 1 byte).
+	maxLeafPrefix = 255
 )
 
 // VariableCompareFn compara dois byte-slices semanticamente.
@@ -215,12 +228,90 @@ func (vp *VariableNodePage) writeSlot(i int, keyOffset, keyLength uint16, value
 	binary.LittleEndian.PutUint64(vp.body[base+4:base+12], uint64(value))
 }
 
-// keyBytesAt devolve o byte-slice da key do slot i.
+// keyBytesAt returns the key bytes of slot i. In leaves with a prefix
+// that is only the suffix.
 func (vp *VariableNodePage) keyBytesAt(i int) []byte {
 	off, length, _ := vp.readSlot(i)
 	return vp.body[off : off+length]
 }
 
+// leafPrefix returns the common prefix of the leaf (empty if there is none).
+func (vp *VariableNodePage) leafPrefix() []byte {
+	if vp.isInternal() {
+		return nil
+	}
+	n := int(vp.body[leafPrefixLenOffset])
+	return vp.body[vp.maxBodySize-n : vp.maxBodySize]
+}
+
+// resetLeafVar reinitializes the leaf with the (sorted) entries, storing
+// the longest prefix common to all of them. Used by split, delete and merges.
+func (vp *VariableNodePage) resetLeafVar(entries []varLeafEntry, nextLeaf pagestore.PageID) error {
+	InitLeafPageVar(vp.page, vp.maxBodySize, vp.cmp)
+	vp.setNextLeafPageID(nextLeaf)
+	if len(entries) > 1 {
+		prefix := commonPrefix(entries[0].key, entries[len(entries)-1].key)
+		if len(prefix) > maxLeafPrefix {
+			prefix = prefix[:maxLeafPrefix]
+		}
+		start := vp.maxBodySize - len(prefix)
+		copy(vp.body[start:vp.maxBodySize], prefix)
+		vp.body[leafPrefixLenOffset] = byte(len(prefix))
+		vp.setFreeSpaceEnd(uint16(start))
+	}
+	for _, entry := range entries {
+		if err := vp.LeafInsertVar(entry.key, entry.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commonPrefix returns the longest common prefix of a and b (aliasing a).
+func commonPrefix(a, b []byte) []byte {
+	n := min(len(a), len(b))
+	i := 0
+	for i < n && a[i] == b[i] {
+		i++
+	}
+	return a[:i]
+}
+
+// leafEntriesWith returns the entries of the leaf with (key, value) at
+// position idx.
+func (vp *VariableNodePage) leafEntriesWith(idx int, key []byte, value int64) []varLeafEntry {
+	n := vp.NumKeys()
+	entries := make([]varLeafEntry, 0, n+1)
+	for i := 0; i < n; i++ {
+		if i == idx {
+			entries = append(entries, varLeafEntry{key: cloneBytes(key), value: value})
+		}
+		k, v := vp.LeafAtVar(i)
+		entries = append(entries, varLeafEntry{key: cloneBytes(k), value: v})
+	}
+	if idx == n {
+		entries = append(entries, varLeafEntry{key: cloneBytes(key), value: value})
+	}
+	return entries
+}
+
+// shrunkPrefixFits reports whether the leaf can hold key outside the
+// current prefix once rebuilt with the prefix common to key and the
+// existing keys.
+func (vp *VariableNodePage) shrunkPrefixFits(key []byte) bool {
+	prefix := vp.leafPrefix()
+	newLen := len(commonPrefix(prefix, key))
+	n := vp.NumKeys()
+	used := 0
+	for i := 0; i < n; i++ {
+		_, length, _ := vp.readSlot(i)
+		used += VariableSlotSize + int(length) + len(prefix) - newLen
+	}
+	// The new key keeps only its suffix; the prefix is stored once.
+	used += VariableSlotSize + len(key)
+	return used <= vp.maxBodySize-NodeHeaderSize
+}
+
 // FreeSpace devolve quantos bytes livres há entre o fim do slot_dir e
 // o início da região de key bytes.
 func (vp *VariableNodePage) FreeSpace() int {
@@ -235,7 +326,11 @@ func (vp *VariableNodePage) CanLeafInsertVar(key []byte) bool {
 	if _, found := vp.binarySearchVar(key); found {
 		return true
 	}
-	return vp.FreeSpace() >= VariableSlotSize+len(key)
+	prefix := vp.leafPrefix()
+	if !bytes.HasPrefix(key, prefix) {
+		return vp.shrunkPrefixFits(key)
+	}
+	return vp.FreeSpace() >= VariableSlotSize+len(key)-len(prefix)
 }
 
 // CanAbsorbSeparatorVar retorna true quando a page internal ainda
@@ -257,13 +352,24 @@ func (vp *VariableNodePage) SplitSeparatorLenVar() int {
 
 	mid := n / 2
 	_, keyLen, _ := vp.readSlot(mid)
-	return int(keyLen)
+	return len(vp.leafPrefix()) + int(keyLen)
 }
 
 // binarySearchVar procura key no slot_dir. Se achou, (idx, true).
 // Otherwise, (idx = posição onde inserir pra manter ordem, false).
 func (vp *VariableNodePage) binarySearchVar(key []byte) (int, bool) {
 	n := vp.NumKeys()
+	if prefix := vp.leafPrefix(); len(prefix) > 0 {
+		if !bytes.HasPrefix(key, prefix) {
+			// Every key of the leaf starts with prefix: a key outside
+			// it sorts before or after all of them.
+			if bytes.Compare(key, prefix) < 0 {
+				return 0, false
+			}
+			return n, false
+		}
+		key = key[len(prefix):]
+	}
 	lo, hi := 0, n
 	for lo < hi {
 		mid := (lo + hi) / 2
@@ -300,6 +406,16 @@ func (vp *VariableNodePage) LeafInsertVar(key []byte, value int64) error {
 		return nil
 	}
 
+	prefix := vp.leafPrefix()
+	if !bytes.HasPrefix(key, prefix) {
+		// Key outside the prefix: rebuild the leaf with a shorter prefix.
+		if !vp.shrunkPrefixFits(key) {
+			return ErrLeafFull
+		}
+		return vp.resetLeafVar(vp.leafEntriesWith(idx, key, value), vp.NextLeafPageID())
+	}
+	key = key[len(prefix):]
+
 	needed := VariableSlotSize + len(key)
 	if vp.FreeSpace() < needed {
 		return ErrLeafFull
@@ -345,41 +461,36 @@ func (vp *VariableNodePage) LeafDeleteVar(key []byte) (bool, error) {
 		return false, nil
 	}
 
-	nextLeaf := vp.NextLeafPageID()
-	type kv struct {
-		key []byte
-		val int64
-	}
-
-	entries := make([]kv, 0, vp.NumKeys()-1)
+	entries := make([]varLeafEntry, 0, vp.NumKeys()-1)
 	for i := 0; i < vp.NumKeys(); i++ {
 		if i == idx {
 			continue
 		}
 		k, v := vp.LeafAtVar(i)
-		keyCopy := make([]byte, len(k))
-		copy(keyCopy, k)
-		entries = append(entries, kv{key: keyCopy, val: v})
+		entries = append(entries, varLeafEntry{key: cloneBytes(k), value: v})
 	}
 
-	InitLeafPageVar(vp.page, vp.maxBodySize, vp.cmp)
-	vp.setNextLeafPageID(nextLeaf)
-	for _, entry := range entries {
-		if err := vp.LeafInsertVar(entry.key, entry.val); err != nil {
-			return false, fmt.Errorf("btree/v2: post-delete compaction failed: %w", err)
-		}
+	if err := vp.resetLeafVar(entries, vp.NextLeafPageID()); err != nil {
+		return false, fmt.Errorf("btree/v2: post-delete compaction failed: %w", err)
 	}
 
 	return true, nil
 }
 
-// LeafAtVar devolve (keyBytes, value) do slot i.
+// LeafAtVar returns (keyBytes, value) of slot i. Without a prefix the key
+// aliases the body; with one it is a copy built from prefix + suffix.
 func (vp *VariableNodePage) LeafAtVar(i int) ([]byte, int64) {
 	if i < 0 || i >= vp.NumKeys() {
 		panic(fmt.Sprintf("btree/v2: LeafAtVar index %d fora de [0, %d)", i, vp.NumKeys()))
 	}
 	off, length, v := vp.readSlot(i)
-	return vp.body[off : off+length], v
+	suffix := vp.body[off : off+length]
+	prefix := vp.leafPrefix()
+	if len(prefix) == 0 {
+		return suffix, v
+	}
+	key := make([]byte, 0, len(prefix)+len(suffix))
+	return append(append(key, prefix...), suffix...), v
 }
 
 // internalBinarySearchVar busca o primeiro sep > key.
@@ -468,36 +579,23 @@ func (vp *VariableNodePage) splitLeafIntoVar(other *VariableNodePage) []byte {
 	n := vp.NumKeys()
 	mid := n / 2
 
-	// Copia slots[mid..n) pra other, realocando key bytes em other.
-	for i := mid; i < n; i++ {
-		keyBytes, value := vp.LeafAtVar(i)
-		if err := other.LeafInsertVar(keyBytes, value); err != nil {
-			panic(fmt.Sprintf("btree/v2: inserção no right pós-split failed: %v", err))
-		}
+	// Both halves are rebuilt, each with its own common prefix
+	// (usually longer than that of the original leaf).
+	entries := make([]varLeafEntry, 0, n)
+	for i := 0; i < n; i++ {
+		k, v := vp.LeafAtVar(i)
+		entries = append(entries, varLeafEntry{key: cloneBytes(k), value: v})
 	}
-
-	// Separador = primeira key do right half. Cópia segura: LeafAtVar
-	// em self ainda funciona pra ler, mas after truncation precisa
-	// retornar cópia.
-	sepSrc, _ := vp.LeafAtVar(mid)
-	sep := make([]byte, len(sepSrc))
-	copy(sep, sepSrc)
+	// Separator = first key of the right half (entries are already copies).
+	sep := entries[mid].key
 
 	// Other herda nextLeafPageID original do self.
-	otherHdr := other.header()
-	otherHdr.nextLeafPageID = vp.header().nextLeafPageID
-	other.writeHeader(otherHdr)
-
-	// Trunca self: slots[0..mid) mantidos; seus key bytes continuam
-	// espalhados no body (fragmentação). Pra "resetar" esses bytes,
-	// faríamos compaction — mas mantê-los é correto (só usa espaço
-	// que o insert futuro pode reaproveitar via update-in-place, ou
-	// num delete futuro).
-	//
-	// Simplicidade primeiro: só atualiza numKeys.
-	selfHdr := vp.header()
-	selfHdr.numKeys = uint16(mid)
-	vp.writeHeader(selfHdr)
+	if err := other.resetLeafVar(entries[mid:], vp.NextLeafPageID()); err != nil {
+		panic(fmt.Sprintf("btree/v2: post-split insert into the right half failed: %v", err))
+	}
+	if err := vp.resetLeafVar(entries[:mid], vp.NextLeafPageID()); err != nil {
+		panic(fmt.Sprintf("btree/v2: post-split rebuild of the left half failed: %v", err))
+	}
 
 	return sep
 }
@@ -294,3 +294,48 @@ func TestVarNode_OpenVariableNodePage(t *testing.T) {
 		t.Fatalf("reopen: ok=%v v=%d", ok, v)
 	}
 }
+
+func TestVarNode_LeafPrefixCompression(t *testing.T) {
+	_, vp := newVarLeaf(t)
+	entries := []varLeafEntry{
+		{key: []byte("customer/0001"), value: 1},
+		{key: []byte("customer/0002"), value: 2},
+		{key: []byte("customer/0010"), value: 3},
+	}
+	if err := vp.resetLeafVar(entries, pagestore.InvalidPageID); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(vp.leafPrefix()); got != "customer/00" {
+		t.Fatalf("prefix: got %q", got)
+	}
+	// 3 suffixes of 2 bytes + the prefix stored once.
+	if used := pagestore.BodySize - NodeHeaderSize - vp.FreeSpace(); used != 3*(VariableSlotSize+2)+len("customer/00") {
+		t.Fatalf("unexpected bytes used: %d", used)
+	}
+	if v, ok := vp.LeafGetVar([]byte("customer/0010")); !ok || v != 3 {
+		t.Fatalf("LeafGetVar: v=%d ok=%v", v, ok)
+	}
+	if _, ok := vp.LeafGetVar([]byte("customer/01")); ok {
+		t.Fatal("key outside the prefix found")
+	}
+
+	// A key outside the prefix shrinks the prefix and keeps the order.
+	if err := vp.LeafInsertVar([]byte("cart/9"), 4); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(vp.leafPrefix()); got != "c" {
+		t.Fatalf("prefix after shrink: got %q", got)
+	}
+	want := []string{"cart/9", "customer/0001", "customer/0002", "customer/0010"}
+	for i, w := range want {
+		if k, _ := vp.LeafAtVar(i); string(k) != w {
+			t.Fatalf("slot %d: got %q, want %q", i, k, w)
+		}
+	}
+	if deleted, err := vp.LeafDeleteVar([]byte("cart/9")); err != nil || !deleted {
+		t.Fatalf("LeafDeleteVar: %v %v", deleted, err)
+	}
+	if got := string(vp.leafPrefix()); got != "customer/00" {
+		t.Fatalf("prefix after delete: got %q", got)
+	}
+}
@@ -0,0 +1,168 @@
+// Package checkpoint inspects index files as a checkpoint leaves them on
+// disk. CreateCheckpoint flushes every index tree to its page file, so a
+// copy of that file (or the file of a closed data directory, or of a
+// backup) holds the index as of the checkpoint. Inspect reports the shape
+// of one such tree and Diff lists the keys that differ between two files
+// of the same index, to check a replica against its primary or a rebuilt
+// index against the one it replaces. Export and Import move an index
+// between files as a compact stream, for shipping it to another node or
+// archiving it far smaller than its pages.
+//
+// The files are opened read-write by the btree package, so they must not
+// be in use by a running engine: inspect copies, or a closed directory.
+package checkpoint
+
+import (
+	"fmt"
+	"slices"
+
+	btreev2 "github.com/bobboyms/storage-engine/pkg/btree/v2"
+	"github.com/bobboyms/storage-engine/pkg/crypto"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// Stats describes the tree of an index file.
+type Stats struct {
+	Path          string
+	Keys          int    // entries in the leaves; a secondary index counts one per row
+	Height        int    // levels from the root to the leaves
+	LeafPages     int    // leaves reachable from the root
+	InternalPages int    // internal nodes reachable from the root
+	LSN           uint64 // highest page LSN: the last WAL record reflected in the file
+}
+
+// Change is a key whose values differ between two index files. Values
+// are the record IDs the key points at: one for a primary index, every
+// row sharing the key for a secondary one. Before is empty for an added
+// key and After for a removed one.
+type Change struct {
+	Key    types.Comparable
+	Before []int64
+	After  []int64
+}
+
+// DiffResult is the difference between two files of one index, each
+// list in key order.
+type DiffResult struct {
+	Added   []Change
+	Removed []Change
+	Changed []Change
+}
+
+// Empty reports whether the two files hold the same keys and values.
+func (d *DiffResult) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// indexTree is what Inspect and Diff need of the trees OpenIndexFile
+// returns (*btreev2.BTreeV2, *btreev2.PostingTree and
+// *btreev2.PartitionedTree).
+type indexTree interface {
+	Stats() (btreev2.TreeStats, error)
+	ScanAll(fn func(key types.Comparable, value int64) error) error
+	Close() error
+}
+
+func open(path string, idx storage.Index, cipher crypto.Cipher) (indexTree, error) {
+	tree, err := storage.OpenIndexFile(idx, path, cipher)
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint: open %s: %w", path, err)
+	}
+	it, ok := tree.(indexTree)
+	if !ok {
+		tree.Close()
+		return nil, fmt.Errorf("checkpoint: %s: unsupported tree %T", path, tree)
+	}
+	return it, nil
+}
+
+// Inspect walks the index file at path, laid out as idx describes (its
+// Type, Primary, Nullable and Collation must match the index that wrote
+// it), and reports its stats. cipher is nil for an unencrypted file.
+func Inspect(path string, idx storage.Index, cipher crypto.Cipher) (Stats, error) {
+	tree, err := open(path, idx, cipher)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer tree.Close()
+	ts, err := tree.Stats()
+	if err != nil {
+		return Stats{}, fmt.Errorf("checkpoint: inspect %s: %w", path, err)
+	}
+	return Stats{
+		Path:          path,
+		Keys:          ts.Keys,
+		Height:        ts.Height,
+		LeafPages:     ts.LeafPages,
+		InternalPages: ts.InternalPages,
+		LSN:           ts.PageLSN,
+	}, nil
+}
+
+// Diff compares the index files a (before) and b (after), both laid out
+// as idx describes, and lists the keys added, removed and pointing at
+// different records. Both trees are read into memory, so it is meant
+// for tooling, not for the hot path.
+func Diff(a, b string, idx storage.Index, cipher crypto.Cipher) (*DiffResult, error) {
+	before, err := readEntries(a, idx, cipher)
+	if err != nil {
+		return nil, err
+	}
+	after, err := readEntries(b, idx, cipher)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DiffResult{}
+	i, j := 0, 0
+	for i < len(before) || j < len(after) {
+		var cmp int
+		switch {
+		case i == len(before):
+			cmp = 1
+		case j == len(after):
+			cmp = -1
+		default:
+			cmp = before[i].Key.Compare(after[j].Key)
+		}
+		switch {
+		case cmp < 0:
+			result.Removed = append(result.Removed, before[i])
+			i++
+		case cmp > 0:
+			result.Added = append(result.Added, Change{Key: after[j].Key, After: after[j].Before})
+			j++
+		default:
+			if !slices.Equal(before[i].Before, after[j].Before) {
+				result.Changed = append(result.Changed, Change{Key: before[i].Key, Before: before[i].Before, After: after[j].Before})
+			}
+			i++
+			j++
+		}
+	}
+	return result, nil
+}
+
+// readEntries reads every key of the file in order, with its values in
+// Before.
+func readEntries(path string, idx storage.Index, cipher crypto.Cipher) ([]Change, error) {
+	tree, err := open(path, idx, cipher)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+	var entries []Change
+	err = tree.ScanAll(func(key types.Comparable, value int64) error {
+		if n := len(entries); n > 0 && entries[n-1].Key.Compare(key) == 0 {
+			entries[n-1].Before = append(entries[n-1].Before, value)
+			return nil
+		}
+		entries = append(entries, Change{Key: key, Before: []int64{value}})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint: read %s: %w", path, err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,113 @@
+package checkpoint_test
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/checkpoint"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+var (
+	primary = storage.Index{Name: "id", Primary: true, Type: storage.TypeInt}
+	byDept  = storage.Index{Name: "dept", Type: storage.TypeVarchar}
+)
+
+// snapshot checkpoints the engine and copies the file of index to dst.
+func snapshot(t *testing.T, se *storage.StorageEngine, index, dst string) {
+	t.Helper()
+	if err := se.CreateCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	table, err := se.TableMetaData.GetTableByName("staff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := table.GetIndex(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := os.Open(idx.Tree.(interface{ Path() string }).Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, src); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInspectAndDiff(t *testing.T) {
+	se, err := storage.Open(t.TempDir(), storage.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer se.Close()
+	if err := se.CreateTable("staff", []storage.Index{primary, byDept}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 2000; i++ {
+		if err := se.InsertRow("staff", fmt.Sprintf(`{"id": %d, "dept": "d%d"}`, i, i%5), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := t.TempDir()
+	snapshot(t, se, "id", filepath.Join(out, "id.1"))
+	snapshot(t, se, "dept", filepath.Join(out, "dept.1"))
+
+	stats, err := checkpoint.Inspect(filepath.Join(out, "id.1"), primary, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Keys != 2000 || stats.Height < 2 || stats.LeafPages < 2 || stats.LSN == 0 {
+		t.Fatalf("stats = %+v", stats)
+	}
+
+	if _, err := se.DeleteRow("staff", types.IntKey(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := se.UpsertRow("staff", `{"id": 2, "dept": "d0"}`, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := se.InsertRow("staff", `{"id": 2001, "dept": "d9"}`, nil); err != nil {
+		t.Fatal(err)
+	}
+	snapshot(t, se, "id", filepath.Join(out, "id.2"))
+	snapshot(t, se, "dept", filepath.Join(out, "dept.2"))
+
+	diff, err := checkpoint.Diff(filepath.Join(out, "id.1"), filepath.Join(out, "id.2"), primary, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A delete only marks the row in the heap; key 1 stays in the index.
+	if len(diff.Added) != 1 || diff.Added[0].Key != types.IntKey(2001) || len(diff.Removed) != 0 {
+		t.Fatalf("diff = %+v", diff)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].Key != types.IntKey(2) {
+		t.Fatalf("changed = %+v", diff.Changed)
+	}
+
+	diff, err = checkpoint.Diff(filepath.Join(out, "dept.1"), filepath.Join(out, "dept.2"), byDept, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Added) != 1 || diff.Added[0].Key != types.VarcharKey("d9") || len(diff.Changed) == 0 {
+		t.Fatalf("secondary diff = %+v", diff)
+	}
+
+	if same, err := checkpoint.Diff(filepath.Join(out, "id.2"), filepath.Join(out, "id.2"), primary, nil); err != nil || !same.Empty() {
+		t.Fatalf("self diff = %+v, %v", same, err)
+	}
+	if _, err := checkpoint.Inspect(filepath.Join(out, "missing"), primary, nil); err == nil {
+		t.Fatal("Inspect of a missing file should fail")
+	}
+}
@@ -0,0 +1,313 @@
+package checkpoint
+
+import (
+	"bufio"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/bobboyms/storage-engine/pkg/crypto"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// An export is a compact, length-prefixed stream of an index's entries in
+// key order, much smaller than the page file it comes from: pages carry
+// free space, headers and internal nodes, the stream only keys and
+// record IDs. Import rebuilds a page file from it bottom-up with
+// BulkLoad, so the leaves are packed and no page is ever split.
+//
+// Layout: the header "SECX", a version byte and a flags byte, then the
+// body, deflated when flagCompressed is set. The body is a list of
+// records, each starting with a tag:
+//
+//	tagKey    uvarint key length, the key (a storage.Key message), varint value
+//	tagSame   varint value, for the key of the previous record
+//	tagEnd    uvarint record count, then the CRC-32 (IEEE) of the body up
+//	          to and including this tag, little-endian
+//
+// tagSame lets a secondary index write a key once for all its rows.
+
+var exportMagic = [4]byte{'S', 'E', 'C', 'X'}
+
+const (
+	exportVersion  = 1
+	flagCompressed = 1 << 0
+
+	tagEnd  = 0
+	tagKey  = 1
+	tagSame = 2
+)
+
+// ErrBadExport is returned by Import for a stream that is not an export,
+// is truncated or fails its checksum.
+var ErrBadExport = errors.New("checkpoint: bad export stream")
+
+// ExportOptions configure Export.
+type ExportOptions struct {
+	// Compress deflates the body. Keys of an index repeat long prefixes
+	// and record IDs grow slowly, so it usually halves the stream again.
+	Compress bool
+}
+
+// Export writes every entry of the index file at path, laid out as idx
+// describes, to w and returns how many it wrote. The file must not be in
+// use by a running engine (see the package doc).
+func Export(w io.Writer, path string, idx storage.Index, cipher crypto.Cipher, opts ExportOptions) (int, error) {
+	tree, err := open(path, idx, cipher)
+	if err != nil {
+		return 0, err
+	}
+	defer tree.Close()
+
+	header := append(exportMagic[:], exportVersion, 0)
+	if opts.Compress {
+		header[5] |= flagCompressed
+	}
+	if _, err := w.Write(header); err != nil {
+		return 0, fmt.Errorf("checkpoint: export %s: %w", path, err)
+	}
+
+	out := bufio.NewWriter(w)
+	var body io.Writer = out
+	var deflate *flate.Writer
+	if opts.Compress {
+		if deflate, err = flate.NewWriter(out, flate.DefaultCompression); err != nil {
+			return 0, err
+		}
+		body = deflate
+	}
+	crc := crc32.NewIEEE()
+	enc := &recordWriter{w: io.MultiWriter(body, crc)}
+
+	count := 0
+	var last types.Comparable
+	err = tree.ScanAll(func(key types.Comparable, value int64) error {
+		if last != nil && last.Compare(key) == 0 {
+			enc.uvarint(tagSame)
+		} else {
+			pk, err := storage.KeyToProto(key)
+			if err != nil {
+				return err
+			}
+			raw, err := proto.Marshal(pk)
+			if err != nil {
+				return err
+			}
+			enc.uvarint(tagKey)
+			enc.uvarint(uint64(len(raw)))
+			enc.bytes(raw)
+			last = key
+		}
+		enc.varint(value)
+		count++
+		return enc.err
+	})
+	if err != nil {
+		return count, fmt.Errorf("checkpoint: export %s: %w", path, err)
+	}
+
+	enc.uvarint(tagEnd)
+	enc.w = body
+	enc.uvarint(uint64(count))
+	enc.bytes(binary.LittleEndian.AppendUint32(nil, crc.Sum32()))
+	if enc.err == nil && deflate != nil {
+		enc.err = deflate.Close()
+	}
+	if enc.err == nil {
+		enc.err = out.Flush()
+	}
+	if enc.err != nil {
+		return count, fmt.Errorf("checkpoint: export %s: %w", path, enc.err)
+	}
+	return count, nil
+}
+
+// Import reads an export from r and builds a new index file at path, laid
+// out as idx describes, returning how many entries it loaded. The export
+// must take the rest of r. path must not exist. idx must describe the
+// index the export was taken from; the entries are bulk loaded, so the
+// file is written in one pass and synced before Import returns. On error
+// the partial file is left for the caller to remove.
+func Import(r io.Reader, path string, idx storage.Index, cipher crypto.Cipher) (int, error) {
+	var header [6]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, fmt.Errorf("%w: header: %v", ErrBadExport, err)
+	}
+	if [4]byte(header[:4]) != exportMagic || header[4] != exportVersion {
+		return 0, fmt.Errorf("%w: not an export (header %x)", ErrBadExport, header)
+	}
+	var body io.Reader = bufio.NewReader(r)
+	if header[5]&flagCompressed != 0 {
+		inflate := flate.NewReader(body)
+		defer inflate.Close()
+		body = inflate
+	}
+	keys, values, err := readRecords(bufio.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	tree, err := storage.CreateIndexFile(idx, path, cipher)
+	if err != nil {
+		return 0, fmt.Errorf("checkpoint: import %s: %w", path, err)
+	}
+	loader, ok := tree.(interface {
+		BulkLoad(keys []types.Comparable, values []int64) error
+		Sync() error
+	})
+	if !ok {
+		tree.Close()
+		return 0, fmt.Errorf("checkpoint: import %s: unsupported tree %T", path, tree)
+	}
+	if err := loader.BulkLoad(keys, values); err != nil {
+		tree.Close()
+		return 0, fmt.Errorf("checkpoint: import %s: %w", path, err)
+	}
+	if err := loader.Sync(); err != nil {
+		tree.Close()
+		return 0, fmt.Errorf("checkpoint: import %s: %w", path, err)
+	}
+	if err := tree.Close(); err != nil {
+		return 0, fmt.Errorf("checkpoint: import %s: %w", path, err)
+	}
+	return len(keys), nil
+}
+
+// readRecords decodes the body of an export, checks its count and
+// checksum and that nothing follows it.
+func readRecords(r *bufio.Reader) ([]types.Comparable, []int64, error) {
+	crc := crc32.NewIEEE()
+	dec := &recordReader{r: r, crc: crc}
+	var (
+		keys   []types.Comparable
+		values []int64
+		last   types.Comparable
+	)
+	for {
+		tag := dec.uvarint()
+		switch {
+		case dec.err != nil:
+			return nil, nil, dec.fail()
+		case tag == tagEnd:
+			sum := crc.Sum32()
+			dec.crc = nil
+			count := dec.uvarint()
+			var stored [4]byte
+			dec.read(stored[:])
+			if dec.err != nil {
+				return nil, nil, dec.fail()
+			}
+			if count != uint64(len(keys)) {
+				return nil, nil, fmt.Errorf("%w: %d records, trailer says %d", ErrBadExport, len(keys), count)
+			}
+			if binary.LittleEndian.Uint32(stored[:]) != sum {
+				return nil, nil, fmt.Errorf("%w: checksum mismatch", ErrBadExport)
+			}
+			// The export must end the stream; a deflated body also has to
+			// reach its final block, or the stream was cut short.
+			if _, err := r.ReadByte(); err != io.EOF {
+				if err == nil {
+					return nil, nil, fmt.Errorf("%w: data after the trailer", ErrBadExport)
+				}
+				dec.err = err
+				return nil, nil, dec.fail()
+			}
+			return keys, values, nil
+		case tag == tagKey:
+			raw := make([]byte, dec.uvarint())
+			dec.read(raw)
+			if dec.err != nil {
+				return nil, nil, dec.fail()
+			}
+			var pk storage.Key
+			if err := proto.Unmarshal(raw, &pk); err != nil {
+				return nil, nil, fmt.Errorf("%w: key: %v", ErrBadExport, err)
+			}
+			key, err := storage.KeyFromProto(&pk)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: key: %v", ErrBadExport, err)
+			}
+			last = key
+		case tag == tagSame && last != nil:
+		default:
+			return nil, nil, fmt.Errorf("%w: unexpected tag %d", ErrBadExport, tag)
+		}
+		value := dec.varint()
+		if dec.err != nil {
+			return nil, nil, dec.fail()
+		}
+		keys = append(keys, last)
+		values = append(values, value)
+	}
+}
+
+// recordWriter writes varints and bytes, keeping the first error.
+type recordWriter struct {
+	w   io.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (e *recordWriter) bytes(b []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(b)
+	}
+}
+
+func (e *recordWriter) uvarint(v uint64) { e.bytes(e.buf[:binary.PutUvarint(e.buf[:], v)]) }
+func (e *recordWriter) varint(v int64)   { e.bytes(e.buf[:binary.PutVarint(e.buf[:], v)]) }
+
+// recordReader is the reading side of recordWriter. While crc is set,
+// every byte read is added to it.
+type recordReader struct {
+	r   *bufio.Reader
+	crc io.Writer
+	err error
+}
+
+func (d *recordReader) ReadByte() (byte, error) {
+	b, err := d.r.ReadByte()
+	if err == nil && d.crc != nil {
+		d.crc.Write([]byte{b})
+	}
+	return b, err
+}
+
+func (d *recordReader) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(d)
+	d.err = err
+	return v
+}
+
+func (d *recordReader) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(d)
+	d.err = err
+	return v
+}
+
+func (d *recordReader) read(b []byte) {
+	if d.err != nil {
+		return
+	}
+	if _, d.err = io.ReadFull(d.r, b); d.err == nil && d.crc != nil {
+		d.crc.Write(b)
+	}
+}
+
+func (d *recordReader) fail() error {
+	if errors.Is(d.err, io.EOF) || errors.Is(d.err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: truncated", ErrBadExport)
+	}
+	return fmt.Errorf("%w: %v", ErrBadExport, d.err)
+}
@@ -0,0 +1,141 @@
+package checkpoint_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/checkpoint"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+func TestExportImport(t *testing.T) {
+	se, err := storage.Open(t.TempDir(), storage.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer se.Close()
+	if err := se.CreateTable("staff", []storage.Index{primary, byDept}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3000; i++ {
+		if err := se.InsertRow("staff", fmt.Sprintf(`{"id": %d, "dept": "department-%d"}`, i, i%7), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := t.TempDir()
+	snapshot(t, se, "id", filepath.Join(out, "id"))
+	snapshot(t, se, "dept", filepath.Join(out, "dept"))
+
+	for _, tc := range []struct {
+		index    string
+		idx      storage.Index
+		compress bool
+	}{
+		{"id", primary, false},
+		{"id", primary, true},
+		{"dept", byDept, false},
+		{"dept", byDept, true},
+	} {
+		src := filepath.Join(out, tc.index)
+		var buf bytes.Buffer
+		n, err := checkpoint.Export(&buf, src, tc.idx, nil, checkpoint.ExportOptions{Compress: tc.compress})
+		if err != nil || n != 3000 {
+			t.Fatalf("Export(%s, compress=%v) = %d, %v", tc.index, tc.compress, n, err)
+		}
+		info, err := os.Stat(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int64(buf.Len()) >= info.Size()/2 {
+			t.Fatalf("export of %s is %d bytes, page file %d", tc.index, buf.Len(), info.Size())
+		}
+		stream := buf.Bytes()
+
+		dst := filepath.Join(out, fmt.Sprintf("%s.imported.%v", tc.index, tc.compress))
+		if n, err := checkpoint.Import(bytes.NewReader(stream), dst, tc.idx, nil); err != nil || n != 3000 {
+			t.Fatalf("Import(%s, compress=%v) = %d, %v", tc.index, tc.compress, n, err)
+		}
+		if diff, err := checkpoint.Diff(src, dst, tc.idx, nil); err != nil || !diff.Empty() {
+			t.Fatalf("Diff after import of %s = %+v, %v", tc.index, diff, err)
+		}
+		if _, err := checkpoint.Import(bytes.NewReader(stream), dst, tc.idx, nil); err == nil {
+			t.Fatalf("Import over an existing file should fail")
+		}
+
+		// Truncated or with one byte flipped, the stream is refused.
+		bad := filepath.Join(out, "bad")
+		if _, err := checkpoint.Import(bytes.NewReader(stream[:len(stream)-3]), bad, tc.idx, nil); !errors.Is(err, checkpoint.ErrBadExport) {
+			t.Fatalf("Import of a truncated stream (compress=%v) = %v, want ErrBadExport", tc.compress, err)
+		}
+		if !tc.compress {
+			flipped := bytes.Clone(stream)
+			flipped[len(flipped)/2] ^= 0x40
+			if _, err := checkpoint.Import(bytes.NewReader(flipped), bad, tc.idx, nil); !errors.Is(err, checkpoint.ErrBadExport) {
+				t.Fatalf("Import of a corrupted stream = %v, want ErrBadExport", err)
+			}
+		}
+	}
+}
+
+const benchKeys = 20000
+
+// benchExport builds a primary index of benchKeys keys and returns its
+// export.
+func benchExport(b *testing.B) []byte {
+	b.Helper()
+	path := filepath.Join(b.TempDir(), "id")
+	tree, err := storage.CreateIndexFile(primary, path, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < benchKeys; i++ {
+		if err := tree.Insert(types.IntKey(i), int64(i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := tree.Close(); err != nil {
+		b.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := checkpoint.Export(&buf, path, primary, nil, checkpoint.ExportOptions{Compress: true}); err != nil {
+		b.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// BenchmarkImport rebuilds the index from its export with BulkLoad.
+func BenchmarkImport(b *testing.B) {
+	stream := benchExport(b)
+	dir := b.TempDir()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := checkpoint.Import(bytes.NewReader(stream), filepath.Join(dir, fmt.Sprint(i)), primary, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkInsertKeyByKey rebuilds the same index one Insert at a time,
+// the baseline BenchmarkImport is measured against.
+func BenchmarkInsertKeyByKey(b *testing.B) {
+	dir := b.TempDir()
+	for i := 0; i < b.N; i++ {
+		tree, err := storage.CreateIndexFile(primary, filepath.Join(dir, fmt.Sprint(i)), nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for k := 0; k < benchKeys; k++ {
+			if err := tree.Insert(types.IntKey(k), int64(k)); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := tree.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
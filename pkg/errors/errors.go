@@ -60,6 +60,15 @@ func (e *IndexNotFoundError) Error() string {
 	return fmt.Sprintf("index %q not found", e.Name)
 }
 
+type IndexAlreadyExistsError struct {
+	TableName string
+	Name      string
+}
+
+func (e *IndexAlreadyExistsError) Error() string {
+	return fmt.Sprintf("index %q already exists on table %q", e.Name, e.TableName)
+}
+
 type InvalidKeyTypeError struct {
 	Name     string
 	TypeName string
@@ -68,3 +77,32 @@ type InvalidKeyTypeError struct {
 func (e *InvalidKeyTypeError) Error() string {
 	return fmt.Sprintf("invalid key type for index %q: %s", e.Name, e.TypeName)
 }
+
+type RowNotFoundError struct {
+	TableName string
+	Key       string
+}
+
+func (e *RowNotFoundError) Error() string {
+	return fmt.Sprintf("row with key %q not found in table %q", e.Key, e.TableName)
+}
+
+// ValidationError reports a document rejected by the validator of a
+// table. Field is empty when the failure is not tied to one field.
+type ValidationError struct {
+	TableName string
+	Field     string
+	Reason    string
+	Err       error
+}
+
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("document rejected by table %q: %s", e.TableName, e.Reason)
+	}
+	return fmt.Sprintf("document rejected by table %q: field %q: %s", e.TableName, e.Field, e.Reason)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
@@ -10,7 +10,9 @@ func TestErrors_ErrorMethod(t *testing.T) {
 		&PrimarykeyNotDefinedError{TableName: "t1"},
 		&DuplicateKeyError{Key: "k1"},
 		&IndexNotFoundError{Name: "i1"},
+		&IndexAlreadyExistsError{TableName: "t1", Name: "i1"},
 		&InvalidKeyTypeError{Name: "i1", TypeName: "int"},
+		&RowNotFoundError{TableName: "t1", Key: "k1"},
 	}
 
 	for _, e := range errs {
@@ -1,13 +1,22 @@
 package heap
 
-// RecordHeader é o metadata por record (MVCC), compartilhado pela
-// implementação page-based do heap.
+// RecordHeader is the per-record metadata (MVCC), shared by the
+// page-based heap implementation.
 //
-// PrevRecordID é um int64 opaco apontando para a versão anterior.
-// O sentinela -1 significa "sem versão anterior".
+// PrevRecordID is an opaque int64 pointing to the previous version. The
+// sentinel -1 means "no previous version".
+//
+// Compression is the codec of the stored document (0 = raw). The heap
+// decompresses on Read, so callers always get the original document.
+// Checksummed means the record carries a CRC32 of the document, checked
+// on every Read. KeyCatalog means the record stores, besides the
+// document, the index keys it was written with.
 type RecordHeader struct {
 	Valid        bool
 	CreateLSN    uint64
 	DeleteLSN    uint64
 	PrevRecordID int64
+	Compression  uint8
+	Checksummed  bool
+	KeyCatalog   bool
 }
@@ -0,0 +1,73 @@
+package v2
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+)
+
+// Compression identifies the codec of a record. The id goes in the header
+// of each record (bits 1-3 of the Valid byte), so a heap can mix raw and
+// compressed records, and changing HeapOptions.Compression between opens
+// does not invalidate what was already written.
+type Compression uint8
+
+const (
+	// CompressionNone stores the document as it came.
+	CompressionNone Compression = iota
+	// CompressionFlate uses DEFLATE (compress/flate, level BestSpeed).
+	CompressionFlate
+
+	maxCompression = 7 // 3 bits in the header
+)
+
+// DefaultCompressMinSize is the size below which compression does not pay
+// off: the codec overhead eats the gain on small documents.
+const DefaultCompressMinSize = 256
+
+// compressDoc returns doc compressed with c, or doc itself and
+// CompressionNone when compressing does not pay off.
+func compressDoc(c Compression, minSize int, doc []byte) ([]byte, Compression, error) {
+	if c == CompressionNone || len(doc) < minSize {
+		return doc, CompressionNone, nil
+	}
+	var buf bytes.Buffer
+	switch c {
+	case CompressionFlate:
+		w, err := flate.NewWriter(&buf, flate.BestSpeed)
+		if err != nil {
+			return nil, CompressionNone, err
+		}
+		if _, err := w.Write(doc); err != nil {
+			return nil, CompressionNone, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, CompressionNone, err
+		}
+	default:
+		return nil, CompressionNone, fmt.Errorf("heap/v2: unknown compression %d", c)
+	}
+	if buf.Len() >= len(doc) {
+		return doc, CompressionNone, nil
+	}
+	return buf.Bytes(), c, nil
+}
+
+// decompressDoc undoes compressDoc with the codec stored in the header.
+func decompressDoc(c Compression, doc []byte) ([]byte, error) {
+	switch c {
+	case CompressionNone:
+		return doc, nil
+	case CompressionFlate:
+		r := flate.NewReader(bytes.NewReader(doc))
+		defer r.Close()
+		out, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: flate: %v", ErrBadRecord, err)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown compression %d", ErrBadRecord, c)
+	}
+}
@@ -0,0 +1,67 @@
+package v2
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestHeapV2_CompressionRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "heap.db")
+	opts := DefaultHeapOptions()
+	opts.Compression = CompressionFlate
+	h, err := NewHeapV2WithOptions(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	large := bytes.Repeat([]byte(`{"name": "compressible", "tags": ["a", "b"]} `), 200)
+	small := []byte(`{"id": 1}`)
+	ridLarge, err := h.Write(large, 1, NoRecordID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ridSmall, err := h.Write(small, 2, NoRecordID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A document larger than the page only fits compressed.
+	huge := bytes.Repeat([]byte("z"), 3*h.maxBodySize)
+	ridHuge, err := h.Write(huge, 3, NoRecordID)
+	if err != nil {
+		t.Fatalf("Write of a compressible document larger than the page: %v", err)
+	}
+	if err := h.Delete(ridLarge, 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopened without compression: old records stay readable.
+	h = newHeapAt(t, path, nil)
+	defer h.Close()
+	for _, c := range []struct {
+		rid   int64
+		want  []byte
+		codec Compression
+	}{
+		{ridLarge, large, CompressionFlate},
+		{ridSmall, small, CompressionNone},
+		{ridHuge, huge, CompressionFlate},
+	} {
+		doc, hdr, err := h.Read(c.rid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(doc, c.want) {
+			t.Fatalf("rid %d: document differs after round trip (%d bytes)", c.rid, len(doc))
+		}
+		if Compression(hdr.Compression) != c.codec {
+			t.Fatalf("rid %d: expected codec %d, got %d", c.rid, c.codec, hdr.Compression)
+		}
+	}
+	if hdr, _ := h.ReadHeader(ridLarge); hdr.Valid || hdr.DeleteLSN != 4 {
+		t.Fatalf("MarkDeleted lost the header: %+v", hdr)
+	}
+}
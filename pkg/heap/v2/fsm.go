@@ -6,17 +6,19 @@ import (
 	"github.com/bobboyms/storage-engine/pkg/pagestore"
 )
 
-// FreeSpaceMap é uma estrutura hint em memória que rastreia espaço livre
-// aproximado por page. Not é persistida — é reconstruída pelo Vacuum
-// quando as pages são compactadas.
+// FreeSpaceMap is an in-memory hint that tracks the approximate free
+// space of each page. It is not persisted: NewHeapV2 rebuilds it by
+// reading the header of every page, and Vacuum/FreeVersions update it
+// when they compact pages.
 //
-// Objetivo: evitar scan linear de todas as pages durante inserts.
-// Sem FSM, HeapV2.Write sempre vai para activePageID e aloca nova page
-// quando está cheia. Com FSM, pages liberadas pelo Vacuum são reutilizadas.
+// Goal: avoid a linear scan of every page during inserts. Without the
+// FSM, HeapV2.Write always goes to activePageID and allocates a new page
+// when it is full. With the FSM, pages freed by Vacuum are reused.
 //
-// Contrato de aproximação: o value em freeBytes pode estar desatualizado
-// (write concurrent pode ter consumido espaço). O Write path trata
-// ErrPageFull como "remover do FSM e tentar próximo" — seguro.
+// Approximation contract: the value in freeBytes may be stale (a
+// concurrent write may have used the space). The Write path treats
+// ErrPageFull as "remove from the FSM and try the next one", which is
+// safe.
 type FreeSpaceMap struct {
 	mu    sync.Mutex
 	pages map[pagestore.PageID]int // pageID → espaço livre aproximado em bytes
@@ -40,18 +42,23 @@ func (fsm *FreeSpaceMap) Register(pageID pagestore.PageID, freeBytes int) {
 	fsm.pages[pageID] = freeBytes
 }
 
-// FindPage retorna a PageID de uma page com pelo menos neededBytes livres.
-// Retorna (InvalidPageID, false) se nenhuma candidata foi encontrada.
-// A busca not tem ordem garantida — retorna a primeira que satisfaz.
+// FindPage returns the PageID of the page with the least free space among
+// those that fit neededBytes (best fit): small holes are filled first and
+// nearly empty pages are left for large records. It returns
+// (InvalidPageID, false) when no candidate is found.
 func (fsm *FreeSpaceMap) FindPage(neededBytes int) (pagestore.PageID, bool) {
 	fsm.mu.Lock()
 	defer fsm.mu.Unlock()
+	best, bestFree := pagestore.InvalidPageID, 0
 	for pid, free := range fsm.pages {
-		if free >= neededBytes {
-			return pid, true
+		if free < neededBytes {
+			continue
+		}
+		if best == pagestore.InvalidPageID || free < bestFree || (free == bestFree && pid < best) {
+			best, bestFree = pid, free
 		}
 	}
-	return pagestore.InvalidPageID, false
+	return best, best != pagestore.InvalidPageID
 }
 
 // Remove elimina uma page do FSM (ex: detectou que está cheia no Write path).
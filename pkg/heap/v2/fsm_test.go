@@ -1,6 +1,8 @@
 package v2
 
 import (
+	"bytes"
+	"path/filepath"
 	"sync"
 	"testing"
 
@@ -106,3 +108,59 @@ func TestFSM_UpdateAfterInsert(t *testing.T) {
 		t.Fatalf("expected pageID=10, got %d", pid)
 	}
 }
+
+func TestFSM_FindPageBestFit(t *testing.T) {
+	fsm := newFreeSpaceMap()
+	fsm.Register(pagestore.PageID(1), 3000)
+	fsm.Register(pagestore.PageID(2), 450)
+	fsm.Register(pagestore.PageID(3), 900)
+
+	if pid, _ := fsm.FindPage(400); pid != pagestore.PageID(2) {
+		t.Fatalf("expected the smallest hole that fits (page 2), got %d", pid)
+	}
+	if pid, _ := fsm.FindPage(500); pid != pagestore.PageID(3) {
+		t.Fatalf("expected page 3, got %d", pid)
+	}
+}
+
+func TestHeapV2_ReopenRebuildsFSM(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "heap.db")
+	h := newHeapAt(t, path, nil)
+
+	// 7 records of ~1 KiB fill a page: two full pages.
+	doc := bytes.Repeat([]byte("x"), 1000)
+	var firstPage []int64
+	for i := 0; i < 14; i++ {
+		rid, err := h.Write(doc, uint64(i+1), NoRecordID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pid, _ := DecodeRecordID(rid); pid == 1 {
+			firstPage = append(firstPage, rid)
+		}
+	}
+	for _, rid := range firstPage {
+		if err := h.Delete(rid, 100); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := h.Vacuum(200); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	h = newHeapAt(t, path, nil)
+	defer h.Close()
+	if h.FSM().Len() == 0 {
+		t.Fatal("expected the FSM to be rebuilt on reopen")
+	}
+	rid, err := h.Write(doc, 300, NoRecordID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid, _ := DecodeRecordID(rid); pid != 1 {
+		t.Fatalf("expected the space of page 1 to be reused, got page %d", pid)
+	}
+}
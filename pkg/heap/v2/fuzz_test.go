@@ -0,0 +1,165 @@
+package v2
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// FuzzHeapV2 runs write/read/delete/undelete/vacuum/reopen against an
+// in-memory model. The input is read in groups of 4 bytes [op, a, b, c]:
+// (a, b) picks the doc size or the target record and c is the fill byte
+// of the doc:
+//
+//	0 write   1 read   2 delete   3 undelete   4 vacuum   5 close and reopen
+//
+// After every reopen and at the end, every record of the model must read
+// back the same and ScanRecords must visit exactly the records of the
+// model, with the vacuumed ones in ErrVacuumed.
+func FuzzHeapV2(f *testing.F) {
+	f.Add([]byte{0, 0, 10, 'a', 0, 1, 0, 'b', 2, 0, 0, 0, 1, 0, 0, 0}, false)
+	f.Add([]byte{0, 15, 0, 'x', 0, 15, 0, 'y', 0, 15, 0, 'z', 2, 0, 1, 0, 4, 0, 0, 0, 5, 0, 0, 0, 0, 0, 50, 'w'}, true)
+	f.Add([]byte{0, 0, 200, 'q', 2, 0, 0, 0, 3, 0, 0, 0, 4, 0, 0, 0, 5, 0, 0, 0, 1, 0, 0, 0}, false)
+	f.Fuzz(func(t *testing.T, data []byte, compress bool) {
+		if len(data) > 128*4 {
+			data = data[:128*4]
+		}
+		opts := DefaultHeapOptions()
+		opts.BufferPoolCapacity = 4 // forces evictions
+		if compress {
+			opts.Compression = CompressionFlate
+		}
+		path := filepath.Join(t.TempDir(), "fuzz.heap")
+		h, err := NewHeapV2WithOptions(path, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { h.Close() }()
+
+		type record struct {
+			doc       []byte
+			createLSN uint64
+			deleteLSN uint64
+			vacuumed  bool
+		}
+		model := make(map[int64]*record)
+		var rids []int64
+		var lsn uint64
+
+		check := func() {
+			t.Helper()
+			for _, rid := range rids {
+				want := model[rid]
+				doc, rh, err := h.Read(rid)
+				if want.vacuumed {
+					if !errors.Is(err, ErrVacuumed) {
+						t.Fatalf("Read(%d) of a vacuumed record: %v", rid, err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("Read(%d): %v", rid, err)
+				}
+				if !bytes.Equal(doc, want.doc) || rh.CreateLSN != want.createLSN || rh.DeleteLSN != want.deleteLSN || rh.Valid != (want.deleteLSN == 0) {
+					t.Fatalf("Read(%d) = %d bytes, %+v; want %d bytes, %+v", rid, len(doc), rh, len(want.doc), want)
+				}
+			}
+			seen := 0
+			err := h.ScanRecords(func(rid int64, rh *RecordHeader, err error) error {
+				want, ok := model[rid]
+				switch {
+				case !ok:
+					t.Fatalf("ScanRecords visited %d, not in the model (%v)", rid, err)
+				case want.vacuumed && !errors.Is(err, ErrVacuumed):
+					t.Fatalf("ScanRecords %d: vacuumed record read as %+v, %v", rid, rh, err)
+				case !want.vacuumed && (err != nil || rh.CreateLSN != want.createLSN):
+					t.Fatalf("ScanRecords %d: %+v, %v", rid, rh, err)
+				}
+				seen++
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if seen != len(rids) {
+				t.Fatalf("ScanRecords visited %d records, want %d", seen, len(rids))
+			}
+		}
+		// pick returns the record picked by (a, b), or false without records.
+		pick := func(a, b byte) (int64, *record, bool) {
+			if len(rids) == 0 {
+				return 0, nil, false
+			}
+			rid := rids[(int(a)<<8|int(b))%len(rids)]
+			return rid, model[rid], true
+		}
+
+		for len(data) >= 4 {
+			op, a, b, c := data[0]%6, data[1], data[2], data[3]
+			data = data[4:]
+			switch op {
+			case 0:
+				lsn++
+				doc := bytes.Repeat([]byte{c}, (int(a)<<8|int(b))%3000+1)
+				rid, err := h.Write(doc, lsn, -1)
+				if err != nil {
+					t.Fatalf("Write(%d bytes): %v", len(doc), err)
+				}
+				if old, ok := model[rid]; ok && !old.vacuumed {
+					t.Fatalf("Write returned %d, which holds a live record", rid)
+				} else if !ok {
+					rids = append(rids, rid)
+				}
+				model[rid] = &record{doc: doc, createLSN: lsn}
+			case 1:
+				if rid, want, ok := pick(a, b); ok && !want.vacuumed {
+					doc, _, err := h.Read(rid)
+					if err != nil || !bytes.Equal(doc, want.doc) {
+						t.Fatalf("Read(%d) = %d bytes, %v; want %d bytes", rid, len(doc), err, len(want.doc))
+					}
+				}
+			case 2:
+				if rid, want, ok := pick(a, b); ok && !want.vacuumed && want.deleteLSN == 0 {
+					lsn++
+					if err := h.Delete(rid, lsn); err != nil {
+						t.Fatalf("Delete(%d): %v", rid, err)
+					}
+					want.deleteLSN = lsn
+				}
+			case 3:
+				if rid, want, ok := pick(a, b); ok && !want.vacuumed && want.deleteLSN != 0 {
+					lsn++
+					if err := h.Undelete(rid, want.deleteLSN, lsn); err != nil {
+						t.Fatalf("Undelete(%d): %v", rid, err)
+					}
+					want.deleteLSN = 0
+				}
+			case 4:
+				n, err := h.Vacuum(lsn)
+				if err != nil {
+					t.Fatalf("Vacuum: %v", err)
+				}
+				freed := 0
+				for _, rid := range rids {
+					if r := model[rid]; !r.vacuumed && r.deleteLSN != 0 {
+						r.vacuumed = true
+						freed++
+					}
+				}
+				if n != freed {
+					t.Fatalf("Vacuum freed %d records, model has %d tombstones", n, freed)
+				}
+			case 5:
+				if err := h.Close(); err != nil {
+					t.Fatalf("Close: %v", err)
+				}
+				if h, err = NewHeapV2WithOptions(path, opts); err != nil {
+					t.Fatalf("reopen: %v", err)
+				}
+				check()
+			}
+		}
+		check()
+	})
+}
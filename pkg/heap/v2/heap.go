@@ -15,6 +15,9 @@ import (
 // this Phase 3 implementation rejects it.
 var ErrRecordTooLarge = errors.New("heap/v2: record larger than a page")
 
+// ErrPageUnreadable marks, in ScanRecords, a page that could not be read.
+var ErrPageUnreadable = errors.New("heap/v2: page unreadable")
+
 // Compile-time assertion: *HeapV2 implementa heap.Heap.
 // Se a interface evoluir e v2 divergir, isto quebra o build imediatamente.
 var _ heap.Heap = (*HeapV2)(nil)
@@ -39,22 +42,71 @@ type HeapV2 struct {
 	// fsm rastreia pages com espaço livre (hint structure).
 	// Permite reutilizar espaço liberado por Vacuum sem scan linear.
 	fsm *FreeSpaceMap
+
+	compression     Compression
+	compressMinSize int
+
+	syncPolicy SyncPolicy
+	syncLoop   *syncLoop // only with SyncInterval
+
+	memDir string // NewMemHeap only: removed on Close
 }
 
 // NewHeapV2 abre ou cria um heap page-based em `path`. `bufferPoolCapacity`
 // define quantas pages ficam em cache RAM simultaneamente. Passe nil
 // para `cipher` para desligar TDE.
 func NewHeapV2(path string, bufferPoolCapacity int, cipher crypto.Cipher) (*HeapV2, error) {
-	pf, err := pagestore.NewPageFile(path, cipher)
+	opts := DefaultHeapOptions()
+	opts.BufferPoolCapacity = bufferPoolCapacity
+	opts.Cipher = cipher
+	return NewHeapV2WithOptions(path, opts)
+}
+
+// NewMemHeap creates an in-memory heap without a file (see
+// pagestore.MemPrefix): the same HeapV2, with its pages in a process
+// buffer. Close discards the content.
+func NewMemHeap(opts HeapOptions) (*HeapV2, error) {
+	dir := pagestore.TempMemDir()
+	h, err := NewHeapV2WithOptions(dir+"/heap", opts)
 	if err != nil {
 		return nil, err
 	}
+	h.memDir = dir
+	return h, nil
+}
+
+// NewHeapV2WithOptions is NewHeapV2 with configurable compression.
+func NewHeapV2WithOptions(path string, opts HeapOptions) (*HeapV2, error) {
+	if opts.Compression > maxCompression {
+		return nil, fmt.Errorf("heap/v2: unknown compression %d", opts.Compression)
+	}
+	pf, err := pagestore.NewPageFileWithOptions(path, opts.Cipher, pagestore.PageFileOptions{
+		ReadOnly:         opts.ReadOnly,
+		Exclusive:        !opts.ReadOnly,
+		PreallocateBytes: opts.PreallocateBytes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if opts.MmapReads {
+		if err := pf.EnableMmap(); err != nil {
+			pf.Close()
+			return nil, err
+		}
+	}
+	capacity := opts.BufferPoolCapacity
+	if opts.CacheBytes > 0 {
+		capacity = int(opts.CacheBytes / pagestore.PageSize)
+	}
 
 	h := &HeapV2{
-		pf:          pf,
-		bp:          pagestore.NewBufferPool(pf, bufferPoolCapacity),
-		maxBodySize: pf.UsableBodySize(),
-		fsm:         newFreeSpaceMap(),
+		pf:              pf,
+		bp:              pagestore.NewBufferPool(pf, capacity),
+		maxBodySize:     pf.UsableBodySize(),
+		fsm:             newFreeSpaceMap(),
+		compression:     opts.Compression,
+		compressMinSize: opts.CompressMinSize,
+		syncPolicy:      opts.SyncPolicy,
 	}
 
 	// Ao reopen, adota a última page existsnte como "ativa".
@@ -63,13 +115,43 @@ func NewHeapV2(path string, bufferPoolCapacity int, cipher crypto.Cipher) (*Heap
 	if n := pf.NumPages(); n > 1 {
 		h.activePageID = pagestore.PageID(n - 1)
 	}
+	h.rebuildFSM()
+
+	if opts.SyncPolicy == SyncInterval && !opts.ReadOnly {
+		interval := opts.SyncIntervalDuration
+		if interval <= 0 {
+			interval = DefaultHeapSyncInterval
+		}
+		h.syncLoop = startSyncLoop(interval, h.bp.FlushAll)
+	}
 
 	return h, nil
 }
 
+// rebuildFSM registers the free space of the existing pages, so that
+// space reclaimed before a restart is used again without waiting for the
+// next Vacuum. It reads straight from the PageFile (without polluting the
+// buffer pool); pages that fail to read are skipped: they may be torn and
+// will be restored by recovery, and the FSM is only a hint.
+func (h *HeapV2) rebuildFSM() {
+	n := h.pf.NumPages()
+	for pageID := pagestore.PageID(1); uint64(pageID) < n; pageID++ {
+		page, err := h.pf.ReadPage(pageID)
+		if err != nil {
+			continue
+		}
+		if free := OpenSlottedPage(page).FreeSpace(); free > SlotSize {
+			h.fsm.Register(pageID, free)
+		}
+	}
+}
+
 // Path devolve o caminho do page file subjacente.
 func (h *HeapV2) Path() string { return h.pf.Path() }
 
+// Cipher returns the cipher of the underlying page file.
+func (h *HeapV2) Cipher() crypto.Cipher { return h.pf.Cipher() }
+
 func (h *HeapV2) SetBeforeFlushHook(hook func(pageID pagestore.PageID, page *pagestore.Page) error) {
 	h.bp.SetBeforeFlushHook(hook)
 }
@@ -96,17 +178,75 @@ func (h *HeapV2) ApplyPageRedo(pageID pagestore.PageID, page *pagestore.Page, ls
 
 // Close flusha o buffer pool e fecha o page file.
 func (h *HeapV2) Close() error {
+	var loopErr error
+	if h.syncLoop != nil {
+		h.syncLoop.close()
+		loopErr = h.syncLoop.takeErr()
+	}
 	if err := h.bp.Close(); err != nil {
 		return err
 	}
-	return h.pf.Close()
+	if err := h.pf.Close(); err != nil {
+		return err
+	}
+	if h.memDir != "" {
+		pagestore.RemoveMemAll(h.memDir)
+	}
+	return loopErr
+}
+
+// checkWritable rejects mutations on a heap opened with ReadOnly.
+func (h *HeapV2) checkWritable() error {
+	if h.pf.ReadOnly() {
+		return pagestore.ErrReadOnly
+	}
+	return nil
+}
+
+// afterWrite applies SyncEveryWrite after a successful mutation.
+func (h *HeapV2) afterWrite() error {
+	if h.syncPolicy != SyncEveryWrite {
+		return nil
+	}
+	return h.bp.FlushAll()
 }
 
-// Write grava um documento. Retorna o RecordID (int64) estável.
-// Semântica idêntica ao v1: o record NUNCA se move depois de gravado.
+// Write stores a document and returns its stable RecordID (int64). Same
+// semantics as v1: a record NEVER moves once written. With compression
+// on, the size limit applies to the compressed document.
 func (h *HeapV2) Write(doc []byte, createLSN uint64, prevRecordID int64) (int64, error) {
+	return h.WriteWithKeys(doc, nil, createLSN, prevRecordID)
+}
+
+// WriteWithKeys is Write storing a key catalog next to the document
+// (opaque to the heap, never compressed). ReadKeys returns the catalog
+// without decompressing or parsing the document.
+func (h *HeapV2) WriteWithKeys(doc, keys []byte, createLSN uint64, prevRecordID int64) (int64, error) {
+	if err := h.checkWritable(); err != nil {
+		return 0, err
+	}
+	rid, err := h.write(doc, keys, createLSN, prevRecordID)
+	if err != nil {
+		return 0, err
+	}
+	if err := h.afterWrite(); err != nil {
+		return 0, err
+	}
+	return rid, nil
+}
+
+func (h *HeapV2) write(doc, keys []byte, createLSN uint64, prevRecordID int64) (int64, error) {
+	doc, codec, err := compressDoc(h.compression, h.compressMinSize, doc)
+	if err != nil {
+		return 0, err
+	}
+
 	// Valida tamanho: record precisa caber com folga (slot dir + record header).
-	recordNeeded := SlotSize + RecordHeaderSize + len(doc)
+	catalogSize := 0
+	if len(keys) > 0 {
+		catalogSize = keyCatalogLenSize + len(keys)
+	}
+	recordNeeded := SlotSize + RecordHeaderSize + RecordChecksumSize + catalogSize + len(doc)
 	maxPayload := h.maxBodySize - SlottedHeaderSize
 	if recordNeeded > maxPayload {
 		return 0, fmt.Errorf("%w: needs %d bytes, page has %d", ErrRecordTooLarge, recordNeeded, maxPayload)
@@ -117,18 +257,19 @@ func (h *HeapV2) Write(doc []byte, createLSN uint64, prevRecordID int64) (int64,
 		CreateLSN:    createLSN,
 		DeleteLSN:    0,
 		PrevRecordID: prevRecordID,
+		Compression:  uint8(codec),
 	}
 
 	h.writeMu.Lock()
 	defer h.writeMu.Unlock()
 
-	needed := SlotSize + RecordHeaderSize + len(doc)
+	needed := recordNeeded
 
 	// 1. Tenta reutilizar page do FSM (espaço liberado por Vacuum).
 	//    O FSM pode estar desatualizado — ErrPageFull é tratado como
 	//    "remover candidata e tentar activePageID".
 	if candidate, ok := h.fsm.FindPage(needed); ok && candidate != h.activePageID {
-		rid, ok, err := h.tryInsert(candidate, rh, doc)
+		rid, ok, err := h.tryInsert(candidate, rh, keys, doc)
 		if err != nil {
 			return 0, err
 		}
@@ -143,7 +284,7 @@ func (h *HeapV2) Write(doc []byte, createLSN uint64, prevRecordID int64) (int64,
 
 	// 2. Tenta inserir na page ativa (se houver).
 	if h.activePageID != pagestore.InvalidPageID {
-		rid, ok, err := h.tryInsert(h.activePageID, rh, doc)
+		rid, ok, err := h.tryInsert(h.activePageID, rh, keys, doc)
 		if err != nil {
 			return 0, err
 		}
@@ -164,7 +305,7 @@ func (h *HeapV2) Write(doc []byte, createLSN uint64, prevRecordID int64) (int64,
 	defer handle.Release()
 
 	sp := InitSlottedPage(handle.Page(), h.maxBodySize)
-	slotID, err := sp.Insert(rh, doc)
+	slotID, err := sp.InsertWithKeys(rh, keys, doc)
 	if err != nil {
 		// Not should acontecer — o check de ErrRecordTooLarge acima já
 		// garante que cabe em page empty.
@@ -202,11 +343,11 @@ func (h *HeapV2) updateFSMAfterInsert(pageID pagestore.PageID, consumedBytes int
 	}
 }
 
-// tryInsert tenta inserir rh+doc na page pid. Retorna (rid, ok, err):
-//   - ok=true: inserido, rid válido
-//   - ok=false, err=nil: page cheia, chamador must tentar outra
-//   - err != nil: erro real de I/O
-func (h *HeapV2) tryInsert(pid pagestore.PageID, rh RecordHeader, doc []byte) (int64, bool, error) {
+// tryInsert tries to insert rh+keys+doc into page pid. It returns (rid, ok, err):
+//   - ok=true: inserted, rid is valid
+//   - ok=false, err=nil: page full, the caller must try another one
+//   - err != nil: an actual I/O error
+func (h *HeapV2) tryInsert(pid pagestore.PageID, rh RecordHeader, keys, doc []byte) (int64, bool, error) {
 	handle, err := h.bp.FetchForWrite(pid)
 	if err != nil {
 		return 0, false, err
@@ -214,7 +355,7 @@ func (h *HeapV2) tryInsert(pid pagestore.PageID, rh RecordHeader, doc []byte) (i
 	defer handle.Release()
 
 	sp := OpenSlottedPage(handle.Page())
-	slotID, err := sp.Insert(rh, doc)
+	slotID, err := sp.InsertWithKeys(rh, keys, doc)
 	if errors.Is(err, ErrPageFull) {
 		return 0, false, nil
 	}
@@ -245,16 +386,77 @@ func (h *HeapV2) Read(rid int64) ([]byte, *RecordHeader, error) {
 
 	sp := OpenSlottedPage(handle.Page())
 	doc, rh, err := sp.Read(slotID)
+	var corrupt *CorruptRecordError
+	if errors.As(err, &corrupt) {
+		corrupt.RecordID = rid
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	doc, err = decompressDoc(Compression(rh.Compression), doc)
 	if err != nil {
 		return nil, nil, err
 	}
 	return doc, &rh, nil
 }
 
+// ReadKeys returns the key catalog stored by WriteWithKeys, or nil when
+// the record was written without one.
+func (h *HeapV2) ReadKeys(rid int64) ([]byte, error) {
+	pid, slotID := DecodeRecordID(rid)
+	if pid == pagestore.InvalidPageID {
+		return nil, fmt.Errorf("heap/v2: invalid RecordID %d (pageID=0)", rid)
+	}
+
+	handle, err := h.bp.Fetch(pid)
+	if err != nil {
+		return nil, err
+	}
+	defer handle.Release()
+
+	keys, err := OpenSlottedPage(handle.Page()).ReadKeys(slotID)
+	var corrupt *CorruptRecordError
+	if errors.As(err, &corrupt) {
+		corrupt.RecordID = rid
+	}
+	return keys, err
+}
+
+// ReadHeader returns only the header of the record, for visibility checks
+// that do not need the document.
+func (h *HeapV2) ReadHeader(rid int64) (*RecordHeader, error) {
+	pid, slotID := DecodeRecordID(rid)
+	if pid == pagestore.InvalidPageID {
+		return nil, fmt.Errorf("heap/v2: invalid RecordID %d (pageID=0)", rid)
+	}
+
+	handle, err := h.bp.Fetch(pid)
+	if err != nil {
+		return nil, err
+	}
+	defer handle.Release()
+
+	rh, err := OpenSlottedPage(handle.Page()).ReadHeader(slotID)
+	if err != nil {
+		return nil, err
+	}
+	return &rh, nil
+}
+
 // Delete marca o record como invalid (lazy delete do MVCC).
 // Bytes do doc e CreateLSN/PrevRecordID são preservados — transações
 // antigas continuam conseguindo ler a versão.
 func (h *HeapV2) Delete(rid int64, deleteLSN uint64) error {
+	if err := h.checkWritable(); err != nil {
+		return err
+	}
+	if err := h.delete(rid, deleteLSN); err != nil {
+		return err
+	}
+	return h.afterWrite()
+}
+
+func (h *HeapV2) delete(rid int64, deleteLSN uint64) error {
 	pid, slotID := DecodeRecordID(rid)
 	if pid == pagestore.InvalidPageID {
 		return fmt.Errorf("heap/v2: invalid RecordID %d (pageID=0)", rid)
@@ -275,7 +477,19 @@ func (h *HeapV2) Delete(rid int64, deleteLSN uint64) error {
 	return nil
 }
 
+// Undelete reverts a Delete (rollback/undo). With expectedDeleteLSN != 0
+// it only acts if the tombstone is still the one of that Delete.
 func (h *HeapV2) Undelete(rid int64, expectedDeleteLSN uint64, pageLSN uint64) error {
+	if err := h.checkWritable(); err != nil {
+		return err
+	}
+	if err := h.undelete(rid, expectedDeleteLSN, pageLSN); err != nil {
+		return err
+	}
+	return h.afterWrite()
+}
+
+func (h *HeapV2) undelete(rid int64, expectedDeleteLSN uint64, pageLSN uint64) error {
 	pid, slotID := DecodeRecordID(rid)
 	if pid == pagestore.InvalidPageID {
 		return fmt.Errorf("heap/v2: invalid RecordID %d (pageID=0)", rid)
@@ -288,11 +502,10 @@ func (h *HeapV2) Undelete(rid int64, expectedDeleteLSN uint64, pageLSN uint64) e
 	defer handle.Release()
 
 	sp := OpenSlottedPage(handle.Page())
-	doc, rh, err := sp.Read(slotID)
+	rh, err := sp.ReadHeader(slotID)
 	if err != nil {
 		return err
 	}
-	_ = doc
 	if rh.DeleteLSN == 0 && rh.Valid {
 		handle.Page().AdvancePageLSN(pageLSN)
 		handle.MarkDirty()
@@ -309,9 +522,22 @@ func (h *HeapV2) Undelete(rid int64, expectedDeleteLSN uint64, pageLSN uint64) e
 	return nil
 }
 
-// Sync persiste tudo no disco (buffer pool → fsync).
+// Sync persists everything to disk (buffer pool → fsync). It also returns
+// a pending error of the SyncInterval goroutine.
 func (h *HeapV2) Sync() error {
-	return h.bp.FlushAll()
+	if err := h.bp.FlushAll(); err != nil {
+		return err
+	}
+	if h.syncLoop != nil {
+		return h.syncLoop.takeErr()
+	}
+	return nil
+}
+
+// Flush writes the dirty pages to the file without fsync: that survives a
+// process crash, not a power loss. Use Sync for durability.
+func (h *HeapV2) Flush() error {
+	return h.bp.FlushDirty()
 }
 
 // Vacuum percorre todas as pages do heap e chama Compact(minLSN) em
@@ -324,6 +550,9 @@ func (h *HeapV2) Sync() error {
 // Concorrência: usa FetchForWrite por page, então Writes em OUTRAS
 // pages podem prosseguir em paralelo. Writes na mesma page esperam.
 func (h *HeapV2) Vacuum(minLSN uint64) (int, error) {
+	if err := h.checkWritable(); err != nil {
+		return 0, err
+	}
 	// FlushAll antes de iterar: pages newly allocated via NewPage ficam
 	// no BufferPool com dirty=true mas PageFile.NumPages() só aumenta
 	// quando WritePage é chamado. Sem o flush, pages novas ficariam
@@ -360,5 +589,147 @@ func (h *HeapV2) Vacuum(minLSN uint64) (int, error) {
 	return total, nil
 }
 
+// ScanRecords calls fn for every slot of the heap, in RecordID order, with
+// the record header or the error of reading it: ErrVacuumed for reclaimed
+// slots, *CorruptRecordError when the document checksum does not match. An
+// unreadable page is reported once, with the RecordID of slot 0 and an
+// ErrPageUnreadable error. It reads through the buffer pool, so it also
+// sees pages not flushed yet. If fn returns an error, the iteration stops
+// with it.
+func (h *HeapV2) ScanRecords(fn func(rid int64, rh *RecordHeader, err error) error) error {
+	numPages := h.pf.NumPages()
+	h.writeMu.Lock()
+	if active := uint64(h.activePageID) + 1; active > numPages {
+		numPages = active
+	}
+	h.writeMu.Unlock()
+
+	for pageID := pagestore.PageID(1); uint64(pageID) < numPages; pageID++ {
+		handle, err := h.bp.Fetch(pageID)
+		if err != nil {
+			err = fmt.Errorf("%w: page %d: %w", ErrPageUnreadable, pageID, err)
+			if err := fn(EncodeRecordID(pageID, 0), nil, err); err != nil {
+				return err
+			}
+			continue
+		}
+		sp := OpenSlottedPage(handle.Page())
+		for slotID := 0; slotID < sp.NumSlots(); slotID++ {
+			rid := EncodeRecordID(pageID, uint16(slotID))
+			doc, rh, err := sp.Read(uint16(slotID))
+			var corrupt *CorruptRecordError
+			if errors.As(err, &corrupt) {
+				corrupt.RecordID = rid
+			}
+			if err == nil {
+				_, err = decompressDoc(Compression(rh.Compression), doc)
+			}
+			if err != nil {
+				err = fn(rid, nil, err)
+			} else {
+				err = fn(rid, &rh, nil)
+			}
+			if err != nil {
+				handle.Release()
+				return err
+			}
+		}
+		handle.Release()
+	}
+	return nil
+}
+
+// FreeVersions vacuums the tombstones rids without scanning the whole
+// heap: only the pages holding them are repacked, and the space freed
+// goes to the FSM. Slots already vacuumed are skipped. It returns how
+// many records were freed.
+func (h *HeapV2) FreeVersions(rids []int64, pageLSN uint64) (int, error) {
+	if err := h.checkWritable(); err != nil {
+		return 0, err
+	}
+	byPage := make(map[pagestore.PageID][]uint16)
+	var pages []pagestore.PageID
+	for _, rid := range rids {
+		pid, slotID := DecodeRecordID(rid)
+		if pid == pagestore.InvalidPageID {
+			return 0, fmt.Errorf("heap/v2: invalid RecordID %d (pageID=0)", rid)
+		}
+		if _, ok := byPage[pid]; !ok {
+			pages = append(pages, pid)
+		}
+		byPage[pid] = append(byPage[pid], slotID)
+	}
+
+	total := 0
+	for _, pid := range pages {
+		n, err := h.freeSlots(pid, byPage[pid], pageLSN)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func (h *HeapV2) freeSlots(pid pagestore.PageID, slots []uint16, pageLSN uint64) (int, error) {
+	handle, err := h.bp.FetchForWrite(pid)
+	if err != nil {
+		return 0, err
+	}
+	defer handle.Release()
+
+	sp := OpenSlottedPage(handle.Page())
+	freed := 0
+	for _, slotID := range slots {
+		err := sp.Free(slotID)
+		if errors.Is(err, ErrVacuumed) {
+			continue
+		}
+		if err != nil {
+			return freed, err
+		}
+		freed++
+	}
+	if freed == 0 {
+		return 0, nil
+	}
+	sp.repack()
+	handle.Page().AdvancePageLSN(pageLSN)
+	handle.MarkDirty()
+	h.fsm.Register(pid, sp.FreeSpace())
+	return freed, nil
+}
+
+// SetPrevRecordID rewrites the PrevRecordID of the record rid; used to cut
+// the version chain once the older versions were freed.
+func (h *HeapV2) SetPrevRecordID(rid int64, prev int64, pageLSN uint64) error {
+	if err := h.checkWritable(); err != nil {
+		return err
+	}
+	pid, slotID := DecodeRecordID(rid)
+	if pid == pagestore.InvalidPageID {
+		return fmt.Errorf("heap/v2: invalid RecordID %d (pageID=0)", rid)
+	}
+
+	handle, err := h.bp.FetchForWrite(pid)
+	if err != nil {
+		return err
+	}
+	defer handle.Release()
+
+	if err := OpenSlottedPage(handle.Page()).SetPrevRecordID(slotID, prev); err != nil {
+		return err
+	}
+	handle.Page().AdvancePageLSN(pageLSN)
+	handle.MarkDirty()
+	return nil
+}
+
+// CacheStats returns the hit/miss counters of the heap's page cache.
+func (h *HeapV2) CacheStats() pagestore.BufferPoolStats { return h.bp.Stats() }
+
+// CacheCapacity returns how many pages fit in the cache.
+func (h *HeapV2) CacheCapacity() int { return h.bp.Capacity() }
+
 // FSM retorna o Free Space Map desta heap. Exposto para testes e diagnóstico.
 func (h *HeapV2) FSM() *FreeSpaceMap { return h.fsm }
@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	"github.com/bobboyms/storage-engine/pkg/crypto"
+	"github.com/bobboyms/storage-engine/pkg/pagestore"
 )
 
 func newHeap(t testing.TB, cipher crypto.Cipher) *HeapV2 {
@@ -423,3 +424,228 @@ func TestHeapV2_FSM_ReusesVacuumedSpace(t *testing.T) {
 		t.Fatal("doc corrupted after reutilização de page via FSM")
 	}
 }
+
+func TestHeapV2_ReadHeader(t *testing.T) {
+	h := newHeap(t, nil)
+
+	rid, _ := h.Write([]byte(`doc`), 10, NoRecordID)
+	if err := h.Delete(rid, 20); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	hdr, err := h.ReadHeader(rid)
+	if err != nil {
+		t.Fatalf("ReadHeader: %v", err)
+	}
+	if hdr.Valid || hdr.CreateLSN != 10 || hdr.DeleteLSN != 20 || hdr.PrevRecordID != NoRecordID {
+		t.Fatalf("unexpected header %+v", *hdr)
+	}
+	if _, err := h.ReadHeader(EncodeRecordID(1, 99)); err == nil {
+		t.Fatal("expected an error for a missing slot")
+	}
+}
+
+func TestHeapV2_CacheBudgetAndStats(t *testing.T) {
+	opts := DefaultHeapOptions()
+	opts.CacheBytes = 4 * 8192
+	h, err := NewHeapV2WithOptions(filepath.Join(t.TempDir(), "heap.db"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if h.CacheCapacity() != 4 {
+		t.Fatalf("expected 4 cache pages, got %d", h.CacheCapacity())
+	}
+
+	rid, err := h.Write([]byte("hot"), 1, NoRecordID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := h.CacheStats()
+	for i := 0; i < 10; i++ {
+		if _, _, err := h.Read(rid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hits := h.CacheStats().Hits - before.Hits; hits != 10 {
+		t.Fatalf("expected 10 hits on the hot page, got %d", hits)
+	}
+}
+
+func TestHeapV2_MmapReads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "heap.db")
+	h := newHeapAt(t, path, nil)
+	var rids []int64
+	for i := 0; i < 50; i++ {
+		rid, err := h.Write(bytes.Repeat([]byte{byte(i)}, 500), uint64(i+1), NoRecordID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rids = append(rids, rid)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultHeapOptions()
+	opts.MmapReads = true
+	opts.BufferPoolCapacity = 1
+	h, err := NewHeapV2WithOptions(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	for i, rid := range rids {
+		doc, _, err := h.Read(rid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(doc, bytes.Repeat([]byte{byte(i)}, 500)) {
+			t.Fatalf("rid %d: document differs", rid)
+		}
+	}
+}
+
+func TestHeapV2_CorruptRecordErrorCarriesRecordID(t *testing.T) {
+	h := newHeap(t, nil)
+	rid, err := h.Write([]byte("payload"), 1, NoRecordID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pid, slotID := DecodeRecordID(rid)
+	handle, err := h.bp.FetchForWrite(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp := OpenSlottedPage(handle.Page())
+	offset, _ := sp.readSlot(slotID)
+	sp.body[offset+RecordHeaderSize+RecordChecksumSize] ^= 0x01
+	handle.Release()
+
+	_, _, err = h.Read(rid)
+	var corrupt *CorruptRecordError
+	if !errors.As(err, &corrupt) || corrupt.RecordID != rid {
+		t.Fatalf("expected CorruptRecordError for rid %d, got %v", rid, err)
+	}
+}
+
+func TestHeapV2_ReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "heap.db")
+	h := newHeapAt(t, path, nil)
+	rid, err := h.Write([]byte("frozen"), 1, NoRecordID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultHeapOptions()
+	opts.ReadOnly = true
+	h, err = NewHeapV2WithOptions(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if doc, _, err := h.Read(rid); err != nil || string(doc) != "frozen" {
+		t.Fatalf("Read: doc=%q err=%v", doc, err)
+	}
+	if _, err := h.Write([]byte("x"), 2, NoRecordID); !errors.Is(err, pagestore.ErrReadOnly) {
+		t.Fatalf("Write: expected ErrReadOnly, got %v", err)
+	}
+	if err := h.Delete(rid, 2); !errors.Is(err, pagestore.ErrReadOnly) {
+		t.Fatalf("Delete: expected ErrReadOnly, got %v", err)
+	}
+}
+
+func TestHeapV2_WriteWithKeys(t *testing.T) {
+	h := newHeap(t, nil)
+	h.compression, h.compressMinSize = CompressionFlate, 0
+
+	doc := bytes.Repeat([]byte("document "), 100)
+	rid, err := h.WriteWithKeys(doc, []byte("catalog"), 1, NoRecordID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := h.Write([]byte("plain"), 2, NoRecordID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if keys, err := h.ReadKeys(rid); err != nil || string(keys) != "catalog" {
+		t.Fatalf("ReadKeys: keys=%q err=%v", keys, err)
+	}
+	got, hdr, err := h.Read(rid)
+	if err != nil || !bytes.Equal(got, doc) || !hdr.KeyCatalog {
+		t.Fatalf("Read: len=%d hdr=%+v err=%v", len(got), hdr, err)
+	}
+	if keys, err := h.ReadKeys(plain); err != nil || keys != nil {
+		t.Fatalf("ReadKeys without catalog: keys=%q err=%v", keys, err)
+	}
+
+	// The catalog survives the Compact of a neighbour and stays covered by the CRC.
+	if err := h.Delete(plain, 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Vacuum(4); err != nil {
+		t.Fatal(err)
+	}
+	if keys, err := h.ReadKeys(rid); err != nil || string(keys) != "catalog" {
+		t.Fatalf("ReadKeys after vacuum: keys=%q err=%v", keys, err)
+	}
+	pid, slotID := DecodeRecordID(rid)
+	handle, err := h.bp.FetchForWrite(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp := OpenSlottedPage(handle.Page())
+	offset, _ := sp.readSlot(slotID)
+	sp.body[offset+RecordHeaderSize+RecordChecksumSize+keyCatalogLenSize] ^= 0x01
+	handle.Release()
+	if _, err := h.ReadKeys(rid); !errors.Is(err, ErrRecordChecksum) {
+		t.Fatalf("expected ErrRecordChecksum, got %v", err)
+	}
+}
+
+func TestHeapV2_MemHeap(t *testing.T) {
+	h, err := NewMemHeap(DefaultHeapOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := h.Path()
+	if !pagestore.IsMemPath(path) {
+		t.Fatalf("Path = %q, want a memory path", path)
+	}
+
+	// Many pages, to exceed the buffer pool.
+	doc := bytes.Repeat([]byte("x"), 3000)
+	var ids []int64
+	for i := 0; i < 200; i++ {
+		id, err := h.Write(doc, uint64(i+1), -1)
+		if err != nil {
+			t.Fatalf("Write %d: %v", i, err)
+		}
+		ids = append(ids, id)
+	}
+	for _, id := range ids {
+		got, hdr, err := h.Read(id)
+		if err != nil || !hdr.Valid || !bytes.Equal(got, doc) {
+			t.Fatalf("Read %d: valid=%v err=%v", id, hdr != nil && hdr.Valid, err)
+		}
+	}
+	if err := h.Delete(ids[0], 500); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := h.Vacuum(1000); err != nil || n != 1 {
+		t.Fatalf("Vacuum = %d, %v", n, err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Close discards the content: no in-memory file is left to reopen.
+	if _, err := NewHeapV2WithOptions(path, HeapOptions{ReadOnly: true}); err == nil {
+		t.Fatal("in-memory heap still exists after Close")
+	}
+}
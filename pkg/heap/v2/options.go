@@ -0,0 +1,50 @@
+package v2
+
+import (
+	"time"
+
+	"github.com/bobboyms/storage-engine/pkg/crypto"
+)
+
+// HeapOptions configures NewHeapV2WithOptions.
+type HeapOptions struct {
+	// BufferPoolCapacity is how many pages are cached in RAM.
+	BufferPoolCapacity int
+	// CacheBytes, when > 0, sets the memory budget of the page cache in
+	// bytes and overrides BufferPoolCapacity.
+	CacheBytes int64
+	// Cipher enables TDE; nil disables it.
+	Cipher crypto.Cipher
+	// Compression is the codec of new records. A record is only stored
+	// compressed when that makes it smaller than the original.
+	Compression Compression
+	// CompressMinSize: documents smaller than this are stored raw.
+	CompressMinSize int
+	// MmapReads serves page cache misses from an mmap of the file instead
+	// of a pread per page (read-heavy workloads, long version chains).
+	MmapReads bool
+	// SyncPolicy decides when the heap fsyncs on its own.
+	SyncPolicy SyncPolicy
+	// SyncIntervalDuration is the period of SyncInterval.
+	SyncIntervalDuration time.Duration
+	// ReadOnly opens an existing heap for reading only: every mutation
+	// fails with pagestore.ErrReadOnly. Without ReadOnly the file is
+	// locked against other processes (pagestore.ErrLocked); with ReadOnly
+	// the lock is ignored, to inspect a heap another engine has open.
+	ReadOnly bool
+	// PreallocateBytes reserves disk space ahead of the end of the file
+	// in blocks of this size (see pagestore.PageFileOptions). Large blocks
+	// help on HDDs; on SSDs the default 0 is enough.
+	PreallocateBytes int64
+}
+
+// DefaultHeapOptions returns the options NewHeapV2 uses: no compression
+// and no cipher.
+func DefaultHeapOptions() HeapOptions {
+	return HeapOptions{
+		BufferPoolCapacity:   64,
+		CompressMinSize:      DefaultCompressMinSize,
+		SyncPolicy:           SyncNone,
+		SyncIntervalDuration: DefaultHeapSyncInterval,
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash/crc32"
 
 	"github.com/bobboyms/storage-engine/pkg/heap"
 	"github.com/bobboyms/storage-engine/pkg/pagestore"
@@ -20,6 +21,16 @@ const (
 
 	// RecordHeaderSize: Valid(1) + CreateLSN(8) + DeleteLSN(8) + PrevRecordID(8)
 	RecordHeaderSize = 25
+
+	// RecordChecksumSize is the CRC32 of the document stored right after
+	// the header in records with Checksummed=true (all written by Insert).
+	RecordChecksumSize = 4
+
+	checksumFlag   = 1 << 4 // bit 4 of the Valid byte
+	keyCatalogFlag = 1 << 5 // bit 5: record with a key catalog
+
+	// keyCatalogLenSize is the uint16 prefix holding the catalog size.
+	keyCatalogLenSize = 2
 )
 
 // NoRecordID é o sentinela para "sem versão anterior" (análogo ao -1 do v1).
@@ -34,8 +45,29 @@ var (
 	// externas continuam válidas), mas a read not devolve content.
 	// Chain walks mustm tratar como fim de cadeia.
 	ErrVacuumed = errors.New("heap/v2: slot vacuumado (record reclaimdo)")
+	// ErrRecordChecksum is the base error of CorruptRecordError.
+	ErrRecordChecksum = errors.New("heap/v2: record checksum mismatch")
+	// ErrRecordLive signals an attempt to free a record that is still live.
+	ErrRecordLive = errors.New("heap/v2: record still live")
 )
 
+// CorruptRecordError is returned when the CRC32 of a record does not
+// match the stored document: the record was corrupted and the document
+// cannot be trusted. RecordID is 0 when the error comes from a standalone
+// SlottedPage.
+type CorruptRecordError struct {
+	RecordID int64
+	SlotID   uint16
+	Expected uint32
+	Actual   uint32
+}
+
+func (e *CorruptRecordError) Error() string {
+	return fmt.Sprintf("heap/v2: corrupt record %d (slot %d): checksum %08x, expected %08x", e.RecordID, e.SlotID, e.Actual, e.Expected)
+}
+
+func (e *CorruptRecordError) Unwrap() error { return ErrRecordChecksum }
+
 // RecordHeader é alias pro tipo compartilhado em pkg/heap. Isso permite
 // que a interface heap.Heap trate v1 e v2 intercambiavelmente sem
 // conversões. Os métodos de encoding ficam como funções de pacote
@@ -44,10 +76,17 @@ type RecordHeader = heap.RecordHeader
 
 func encodeRecordHeader(h *RecordHeader, buf []byte) {
 	_ = buf[RecordHeaderSize-1]
+	// Byte 0: bit 0 = Valid, bits 1-3 = compression codec,
+	// bit 4 = record with a CRC32, bit 5 = record with a key catalog.
+	buf[0] = (h.Compression & maxCompression) << 1
 	if h.Valid {
-		buf[0] = 1
-	} else {
-		buf[0] = 0
+		buf[0] |= 1
+	}
+	if h.Checksummed {
+		buf[0] |= checksumFlag
+	}
+	if h.KeyCatalog {
+		buf[0] |= keyCatalogFlag
 	}
 	binary.LittleEndian.PutUint64(buf[1:9], h.CreateLSN)
 	binary.LittleEndian.PutUint64(buf[9:17], h.DeleteLSN)
@@ -55,7 +94,10 @@ func encodeRecordHeader(h *RecordHeader, buf []byte) {
 }
 
 func decodeRecordHeader(h *RecordHeader, buf []byte) {
-	h.Valid = buf[0] == 1
+	h.Valid = buf[0]&1 == 1
+	h.Compression = (buf[0] >> 1) & maxCompression
+	h.Checksummed = buf[0]&checksumFlag != 0
+	h.KeyCatalog = buf[0]&keyCatalogFlag != 0
 	h.CreateLSN = binary.LittleEndian.Uint64(buf[1:9])
 	h.DeleteLSN = binary.LittleEndian.Uint64(buf[9:17])
 	h.PrevRecordID = int64(binary.LittleEndian.Uint64(buf[17:25]))
@@ -183,7 +225,26 @@ func (sp *SlottedPage) writeSlot(i uint16, offset, length uint16) {
 // alocado. SlotIDs são monotonicamente crescentes — o engine nunca
 // reusa um SlotID enquanto o slot exist no dir.
 func (sp *SlottedPage) Insert(rh RecordHeader, doc []byte) (uint16, error) {
-	recordSize := RecordHeaderSize + len(doc)
+	return sp.InsertWithKeys(rh, nil, doc)
+}
+
+// InsertWithKeys is Insert with an opaque key catalog stored before the
+// document: index maintenance reads the keys back with ReadKeys without
+// parsing the document. Empty keys store a plain record.
+//
+// Layout: header | CRC32 | [len uint16 | keys] | doc. The CRC covers
+// everything after it.
+func (sp *SlottedPage) InsertWithKeys(rh RecordHeader, keys, doc []byte) (uint16, error) {
+	rh.Checksummed = true
+	rh.KeyCatalog = len(keys) > 0
+	catalogSize := 0
+	if rh.KeyCatalog {
+		if len(keys) > 0xFFFF {
+			return 0, fmt.Errorf("heap/v2: key catalog of %d bytes exceeds uint16 limit", len(keys))
+		}
+		catalogSize = keyCatalogLenSize + len(keys)
+	}
+	recordSize := RecordHeaderSize + RecordChecksumSize + catalogSize + len(doc)
 	needed := SlotSize + recordSize
 
 	h := sp.header()
@@ -197,9 +258,16 @@ func (sp *SlottedPage) Insert(rh RecordHeader, doc []byte) (uint16, error) {
 	// Novo record vai em freeSpaceEnd - recordSize, crescendo pra trás.
 	newRecordOffset := h.freeSpaceEnd - uint16(recordSize)
 
-	// Grava o header do record e o doc.
+	// Write the record header, the catalog and the document.
 	encodeRecordHeader(&rh, sp.body[newRecordOffset:newRecordOffset+RecordHeaderSize])
-	copy(sp.body[newRecordOffset+RecordHeaderSize:newRecordOffset+uint16(recordSize)], doc)
+	crcAt := int(newRecordOffset) + RecordHeaderSize
+	payload := sp.body[crcAt+RecordChecksumSize : int(newRecordOffset)+recordSize]
+	if rh.KeyCatalog {
+		binary.LittleEndian.PutUint16(payload[:keyCatalogLenSize], uint16(len(keys)))
+		copy(payload[keyCatalogLenSize:], keys)
+	}
+	copy(payload[catalogSize:], doc)
+	binary.LittleEndian.PutUint32(sp.body[crcAt:crcAt+RecordChecksumSize], crc32.ChecksumIEEE(payload))
 
 	// Adiciona o slot no dir.
 	slotID := h.numSlots
@@ -234,14 +302,8 @@ func (sp *SlottedPage) Compact(minLSN uint64) (int, error) {
 		return 0, nil
 	}
 
-	// Primeira passada: classifica cada slot.
-	type survivor struct {
-		slotID         uint16
-		offset, length uint16
-	}
-	survivors := make([]survivor, 0, h.numSlots)
+	// First pass: mark the reclaimable tombstones.
 	vacuumed := 0
-
 	for i := uint16(0); i < h.numSlots; i++ {
 		offset, length := sp.readSlot(i)
 		if length == 0 {
@@ -259,14 +321,30 @@ func (sp *SlottedPage) Compact(minLSN uint64) (int, error) {
 		if safeToVacuum {
 			sp.writeSlot(i, 0, 0)
 			vacuumed++
-			continue
 		}
-		survivors = append(survivors, survivor{slotID: i, offset: offset, length: length})
 	}
 
 	if vacuumed == 0 {
 		return 0, nil
 	}
+	sp.repack()
+	return vacuumed, nil
+}
+
+// repack packs the records of the slots with length > 0 at the end of the
+// page, joining the space of vacuumed slots to the free block.
+func (sp *SlottedPage) repack() {
+	h := sp.header()
+	type survivor struct {
+		slotID         uint16
+		offset, length uint16
+	}
+	survivors := make([]survivor, 0, h.numSlots)
+	for i := uint16(0); i < h.numSlots; i++ {
+		if offset, length := sp.readSlot(i); length > 0 {
+			survivors = append(survivors, survivor{slotID: i, offset: offset, length: length})
+		}
+	}
 
 	// Reescreve a região de records num buffer temporário, depois copia
 	// de volta. Evita copias sobrepostas (que corromperiam dados).
@@ -287,10 +365,58 @@ func (sp *SlottedPage) Compact(minLSN uint64) (int, error) {
 	copy(sp.body[currentPos:], tmp[currentPos:])
 
 	h.freeSpaceEnd = currentPos
-	// numValid not muda — tombstones vacuumados já estavam em "inválido".
+	// numValid does not change: only tombstones are vacuumed.
 	sp.writeHeader(h)
+}
 
-	return vacuumed, nil
+// Free vacuums a single tombstone without looking at DeleteLSN: the caller
+// has already decided that no snapshot sees the version. The space goes
+// back to the free block only on the next repack (see
+// HeapV2.FreeVersions).
+func (sp *SlottedPage) Free(slotID uint16) error {
+	h := sp.header()
+	if slotID >= h.numSlots {
+		return fmt.Errorf("%w: slotID %d >= numSlots %d", ErrSlotNotFound, slotID, h.numSlots)
+	}
+
+	offset, length := sp.readSlot(slotID)
+	if length == 0 {
+		return ErrVacuumed
+	}
+	if length < RecordHeaderSize {
+		return ErrBadRecord
+	}
+
+	var rh RecordHeader
+	decodeRecordHeader(&rh, sp.body[offset:offset+RecordHeaderSize])
+	if rh.Valid {
+		return fmt.Errorf("%w: slotID %d", ErrRecordLive, slotID)
+	}
+	sp.writeSlot(slotID, 0, 0)
+	return nil
+}
+
+// SetPrevRecordID rewrites the record's link to its previous version, in
+// place.
+func (sp *SlottedPage) SetPrevRecordID(slotID uint16, prev int64) error {
+	h := sp.header()
+	if slotID >= h.numSlots {
+		return fmt.Errorf("%w: slotID %d >= numSlots %d", ErrSlotNotFound, slotID, h.numSlots)
+	}
+
+	offset, length := sp.readSlot(slotID)
+	if length == 0 {
+		return ErrVacuumed
+	}
+	if length < RecordHeaderSize {
+		return ErrBadRecord
+	}
+
+	var rh RecordHeader
+	decodeRecordHeader(&rh, sp.body[offset:offset+RecordHeaderSize])
+	rh.PrevRecordID = prev
+	encodeRecordHeader(&rh, sp.body[offset:offset+RecordHeaderSize])
+	return nil
 }
 
 // Iterate percorre TODOS os slots (válidos e invalids) na ordem do
@@ -369,8 +495,61 @@ func (sp *SlottedPage) MarkUndeleted(slotID uint16) error {
 	return nil
 }
 
+// ReadHeader returns only the header of the given slot, without copying
+// the document.
+func (sp *SlottedPage) ReadHeader(slotID uint16) (RecordHeader, error) {
+	h := sp.header()
+	if slotID >= h.numSlots {
+		return RecordHeader{}, fmt.Errorf("%w: slotID %d >= numSlots %d", ErrSlotNotFound, slotID, h.numSlots)
+	}
+
+	offset, length := sp.readSlot(slotID)
+	if length == 0 {
+		return RecordHeader{}, ErrVacuumed
+	}
+	if length < RecordHeaderSize {
+		return RecordHeader{}, ErrBadRecord
+	}
+
+	var rh RecordHeader
+	decodeRecordHeader(&rh, sp.body[offset:offset+RecordHeaderSize])
+	return rh, nil
+}
+
 // Read devolve o doc e o header do slot indicado.
 func (sp *SlottedPage) Read(slotID uint16) ([]byte, RecordHeader, error) {
+	payload, rh, err := sp.readPayload(slotID)
+	if err != nil {
+		return nil, rh, err
+	}
+	_, doc, err := splitKeyCatalog(rh, payload)
+	if err != nil {
+		return nil, RecordHeader{}, err
+	}
+	out := make([]byte, len(doc))
+	copy(out, doc)
+	return out, rh, nil
+}
+
+// ReadKeys returns the key catalog stored by InsertWithKeys, or nil when
+// the record has none.
+func (sp *SlottedPage) ReadKeys(slotID uint16) ([]byte, error) {
+	payload, rh, err := sp.readPayload(slotID)
+	if err != nil {
+		return nil, err
+	}
+	keys, _, err := splitKeyCatalog(rh, payload)
+	if err != nil || keys == nil {
+		return nil, err
+	}
+	out := make([]byte, len(keys))
+	copy(out, keys)
+	return out, nil
+}
+
+// readPayload returns the header and the bytes after the CRC (without
+// copying), checking the CRC when the record has one.
+func (sp *SlottedPage) readPayload(slotID uint16) ([]byte, RecordHeader, error) {
 	h := sp.header()
 	if slotID >= h.numSlots {
 		return nil, RecordHeader{}, fmt.Errorf("%w: slotID %d >= numSlots %d", ErrSlotNotFound, slotID, h.numSlots)
@@ -387,9 +566,31 @@ func (sp *SlottedPage) Read(slotID uint16) ([]byte, RecordHeader, error) {
 	var rh RecordHeader
 	decodeRecordHeader(&rh, sp.body[offset:offset+RecordHeaderSize])
 
-	docLen := int(length) - RecordHeaderSize
-	doc := make([]byte, docLen)
-	copy(doc, sp.body[offset+RecordHeaderSize:offset+length])
+	payload := sp.body[offset+RecordHeaderSize : offset+length]
+	if rh.Checksummed {
+		if len(payload) < RecordChecksumSize {
+			return nil, RecordHeader{}, ErrBadRecord
+		}
+		expected := binary.LittleEndian.Uint32(payload[:RecordChecksumSize])
+		payload = payload[RecordChecksumSize:]
+		if actual := crc32.ChecksumIEEE(payload); actual != expected {
+			return nil, rh, &CorruptRecordError{SlotID: slotID, Expected: expected, Actual: actual}
+		}
+	}
+	return payload, rh, nil
+}
 
-	return doc, rh, nil
+// splitKeyCatalog splits the key catalog (nil if absent) from the document.
+func splitKeyCatalog(rh RecordHeader, payload []byte) (keys, doc []byte, err error) {
+	if !rh.KeyCatalog {
+		return nil, payload, nil
+	}
+	if len(payload) < keyCatalogLenSize {
+		return nil, nil, ErrBadRecord
+	}
+	n := int(binary.LittleEndian.Uint16(payload[:keyCatalogLenSize]))
+	if len(payload) < keyCatalogLenSize+n {
+		return nil, nil, ErrBadRecord
+	}
+	return payload[keyCatalogLenSize : keyCatalogLenSize+n], payload[keyCatalogLenSize+n:], nil
 }
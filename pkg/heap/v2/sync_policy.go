@@ -0,0 +1,78 @@
+package v2
+
+import (
+	"sync"
+	"time"
+)
+
+// SyncPolicy decides when the heap forces dirty pages to disk on its own.
+// With a WAL the default SyncNone is enough: recovery redoes what was only
+// in the buffer pool and checkpoints Sync the heap. The other policies are
+// for heaps used without a WAL, or for callers who want a bounded loss
+// window for the data too.
+type SyncPolicy int
+
+const (
+	// SyncNone: pages reach disk on eviction, Sync/Flush and checkpoints.
+	SyncNone SyncPolicy = iota
+
+	// SyncEveryWrite: Write, Delete and Undelete only return after the
+	// fsync. Safer, much slower — and every flush goes through the page
+	// redo hook, writing the page image to the WAL.
+	SyncEveryWrite
+
+	// SyncInterval: a goroutine calls Sync every SyncIntervalDuration.
+	SyncInterval
+)
+
+// DefaultHeapSyncInterval is the SyncInterval period when
+// HeapOptions.SyncIntervalDuration is not set.
+const DefaultHeapSyncInterval = 200 * time.Millisecond
+
+// syncLoop is the SyncInterval goroutine. An fsync error is kept and
+// returned by the next Sync (or Close).
+type syncLoop struct {
+	stop chan struct{}
+	done chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+func startSyncLoop(interval time.Duration, sync func() error) *syncLoop {
+	l := &syncLoop{stop: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(l.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.stop:
+				return
+			case <-ticker.C:
+				if err := sync(); err != nil {
+					l.mu.Lock()
+					if l.err == nil {
+						l.err = err
+					}
+					l.mu.Unlock()
+				}
+			}
+		}
+	}()
+	return l
+}
+
+// takeErr returns and clears the error kept by the goroutine.
+func (l *syncLoop) takeErr() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	err := l.err
+	l.err = nil
+	return err
+}
+
+func (l *syncLoop) close() {
+	close(l.stop)
+	<-l.done
+}
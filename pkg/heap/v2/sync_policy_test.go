@@ -0,0 +1,77 @@
+package v2
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bobboyms/storage-engine/pkg/pagestore"
+)
+
+// persistedPages reads the file from outside the heap: it only sees what
+// was already written to the PageFile.
+func persistedPages(t *testing.T, path string) uint64 {
+	t.Helper()
+	pf, err := pagestore.NewPageFile(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pf.Close()
+	return pf.NumPages()
+}
+
+func TestHeapV2_SyncPolicies(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		policy SyncPolicy
+		wait   time.Duration
+	}{
+		{"every-write", SyncEveryWrite, 0},
+		{"interval", SyncInterval, 100 * time.Millisecond},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "heap.db")
+			opts := DefaultHeapOptions()
+			opts.SyncPolicy = tc.policy
+			opts.SyncIntervalDuration = 10 * time.Millisecond
+			h, err := NewHeapV2WithOptions(path, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer h.Close()
+
+			if _, err := h.Write([]byte("durable"), 1, NoRecordID); err != nil {
+				t.Fatal(err)
+			}
+			deadline := time.Now().Add(tc.wait)
+			for persistedPages(t, path) < 2 && time.Now().Before(deadline) {
+				time.Sleep(5 * time.Millisecond)
+			}
+			if n := persistedPages(t, path); n < 2 {
+				t.Fatalf("the record's page was not written (NumPages=%d)", n)
+			}
+		})
+	}
+}
+
+func TestHeapV2_FlushWritesWithoutClosing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "heap.db")
+	h := newHeapAt(t, path, nil)
+	defer h.Close()
+
+	if _, err := h.Write([]byte("pending"), 1, NoRecordID); err != nil {
+		t.Fatal(err)
+	}
+	if n := persistedPages(t, path); n != 1 {
+		t.Fatalf("SyncNone should not write before Flush (NumPages=%d)", n)
+	}
+	if err := h.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if n := persistedPages(t, path); n != 2 {
+		t.Fatalf("expected the page written after Flush, NumPages=%d", n)
+	}
+	if len(h.DirtyPages()) != 0 {
+		t.Fatal("Flush left dirty pages")
+	}
+}
@@ -261,3 +261,68 @@ func TestSlottedPage_Read_VacuumedSlot_ReturnsErrVacuumed(t *testing.T) {
 		t.Fatalf("expected ErrVacuumed, got: %v", err)
 	}
 }
+
+func TestHeapV2_FreeVersions_TrimsChainTail(t *testing.T) {
+	h := newHeap(t, nil)
+
+	v1, _ := h.Write([]byte("v1"), 10, NoRecordID)
+	v2, _ := h.Write([]byte("v2"), 20, v1)
+	v3, _ := h.Write([]byte("v3"), 30, v2)
+	_ = h.Delete(v1, 20)
+	_ = h.Delete(v2, 30)
+
+	if _, err := h.FreeVersions([]int64{v3}, 0); !errors.Is(err, ErrRecordLive) {
+		t.Fatalf("expected ErrRecordLive for a live version, got %v", err)
+	}
+	n, err := h.FreeVersions([]int64{v1, v2}, 0)
+	if err != nil || n != 2 {
+		t.Fatalf("FreeVersions: n=%d err=%v", n, err)
+	}
+	if err := h.SetPrevRecordID(v3, NoRecordID, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, hdr, err := h.Read(v3)
+	if err != nil || string(doc) != "v3" || hdr.PrevRecordID != NoRecordID {
+		t.Fatalf("v3 after trim: doc=%q hdr=%+v err=%v", doc, hdr, err)
+	}
+	if _, _, err := h.Read(v2); !errors.Is(err, ErrVacuumed) {
+		t.Fatalf("expected ErrVacuumed for v2, got %v", err)
+	}
+	if n, _ := h.FreeVersions([]int64{v1}, 0); n != 0 {
+		t.Fatalf("freeing a vacuumed slot twice: n=%d", n)
+	}
+}
+
+func TestHeapV2_ScanRecords_ReportsEverySlot(t *testing.T) {
+	h := newHeap(t, nil)
+
+	v1, _ := h.Write([]byte("antiga"), 10, NoRecordID)
+	v2, _ := h.Write([]byte("recente"), 20, v1)
+	_ = h.Delete(v1, 30)
+	if _, err := h.Vacuum(100); err != nil {
+		t.Fatal(err)
+	}
+
+	var rids []int64
+	err := h.ScanRecords(func(rid int64, rh *RecordHeader, err error) error {
+		rids = append(rids, rid)
+		switch rid {
+		case v1:
+			if !errors.Is(err, ErrVacuumed) {
+				t.Fatalf("expected ErrVacuumed for %d, got %v", rid, err)
+			}
+		case v2:
+			if err != nil || rh.CreateLSN != 20 || rh.PrevRecordID != v1 {
+				t.Fatalf("unexpected header %+v (%v)", rh, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rids) != 2 || rids[0] != v1 || rids[1] != v2 {
+		t.Fatalf("expected slots %d and %d, got %v", v1, v2, rids)
+	}
+}
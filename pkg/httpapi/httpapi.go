@@ -0,0 +1,377 @@
+// Package httpapi serves a StorageEngine over HTTP with JSON bodies:
+//
+//	GET    /tables/{table}/rows/{key}  the row under key (?index= picks an index other than the primary)
+//	PUT    /tables/{table}/rows/{key}  writes the JSON body as the row of primary key key
+//	DELETE /tables/{table}/rows/{key}  deletes the row of primary key key
+//	POST   /tables/{table}/rows        inserts the JSON body as a new row
+//	GET    /tables/{table}/scan        one page of an index scan (see Handler.scan)
+//
+// Keys in paths and queries are parsed by the type of their index: INT,
+// FLOAT and BOOL as Go literals, DATE as RFC 3339, DECIMAL and UUID in
+// their text form, BYTES as unpadded base64url.
+package httpapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	storageerrors "github.com/bobboyms/storage-engine/pkg/errors"
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// Page sizes of a scan: DefaultLimit without ?limit=, never more than
+// MaxLimit.
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// Handler is the http.Handler of the API. The engine stays owned by the
+// caller.
+type Handler struct {
+	engine *storage.StorageEngine
+	mux    *http.ServeMux
+}
+
+// New returns the Handler of engine.
+func New(engine *storage.StorageEngine) *Handler {
+	h := &Handler{engine: engine, mux: http.NewServeMux()}
+	h.mux.HandleFunc("GET /tables/{table}/rows/{key}", h.getRow)
+	h.mux.HandleFunc("PUT /tables/{table}/rows/{key}", h.putRow)
+	h.mux.HandleFunc("DELETE /tables/{table}/rows/{key}", h.deleteRow)
+	h.mux.HandleFunc("POST /tables/{table}/rows", h.insertRow)
+	h.mux.HandleFunc("GET /tables/{table}/scan", h.scan)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// ScanPage is the body of a scan response. NextPageToken is empty on the
+// last page.
+type ScanPage struct {
+	Rows          []json.RawMessage `json:"rows"`
+	NextPageToken string            `json:"next_page_token,omitempty"`
+}
+
+func (h *Handler) getRow(w http.ResponseWriter, r *http.Request) {
+	tableName := r.PathValue("table")
+	index, err := h.index(tableName, r.URL.Query().Get("index"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	key, err := parseKey(index.Type, r.PathValue("key"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	doc, found, err := h.engine.GetCtx(r.Context(), tableName, index.Name, key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !found {
+		writeError(w, &storageerrors.RowNotFoundError{TableName: tableName, Key: r.PathValue("key")})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(doc))
+}
+
+func (h *Handler) putRow(w http.ResponseWriter, r *http.Request) {
+	tableName := r.PathValue("table")
+	primary, err := h.index(tableName, "")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	key, err := parseKey(primary.Type, r.PathValue("key"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	doc, err := readDocument(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	// The key of the path must match the one in the document.
+	keys := map[string]types.Comparable{primary.Name: key}
+	if err := h.engine.UpsertRow(tableName, doc, keys); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) deleteRow(w http.ResponseWriter, r *http.Request) {
+	tableName := r.PathValue("table")
+	primary, err := h.index(tableName, "")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	key, err := parseKey(primary.Type, r.PathValue("key"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	deleted, err := h.engine.DeleteRow(tableName, key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !deleted {
+		writeError(w, &storageerrors.RowNotFoundError{TableName: tableName, Key: r.PathValue("key")})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) insertRow(w http.ResponseWriter, r *http.Request) {
+	doc, err := readDocument(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := h.engine.InsertRow(r.PathValue("table"), doc, nil); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// scan reads one page of an index with keyset pagination (ScanPage).
+// Query parameters:
+//
+//	index          the index to walk; the primary by default
+//	eq             key = eq
+//	gt, gte        key > gt, key >= gte
+//	lt, lte        key < lt, key <= lte
+//	limit          rows per page, DefaultLimit by default
+//	reverse=true   walks from the largest key down
+//	fields=a,b     returns only those fields
+//	page_token     the next_page_token of the previous page
+//
+// The page token holds only the position of the last row, so the next
+// page must repeat the other parameters.
+func (h *Handler) scan(w http.ResponseWriter, r *http.Request) {
+	tableName := r.PathValue("table")
+	params := r.URL.Query()
+	index, err := h.index(tableName, params.Get("index"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	condition, err := scanCondition(index.Type, params)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	opts := storage.ScanOptions{Limit: DefaultLimit}
+	if value := params.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > MaxLimit {
+			writeError(w, badRequest("limit must be between 1 and %d", MaxLimit))
+			return
+		}
+		opts.Limit = limit
+	}
+	if value := params.Get("reverse"); value != "" {
+		if opts.Reverse, err = strconv.ParseBool(value); err != nil {
+			writeError(w, badRequest("reverse: %v", err))
+			return
+		}
+	}
+	if value := params.Get("fields"); value != "" {
+		opts.Projection = strings.Split(value, ",")
+	}
+
+	rows, next, err := h.engine.ScanPageTokenCtx(r.Context(), tableName, index.Name, condition, params.Get("page_token"), opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	page := ScanPage{Rows: make([]json.RawMessage, len(rows)), NextPageToken: next}
+	for i, row := range rows {
+		page.Rows[i] = json.RawMessage(row)
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// index returns the index name of table, or its primary index when name
+// is empty.
+func (h *Handler) index(tableName, name string) (*storage.Index, error) {
+	table, err := h.engine.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return nil, err
+	}
+	if name != "" {
+		return table.GetIndex(name)
+	}
+	for _, index := range table.GetIndices() {
+		if index.Primary {
+			return index, nil
+		}
+	}
+	return nil, &storageerrors.PrimarykeyNotDefinedError{TableName: tableName}
+}
+
+// scanCondition builds the condition of the eq/gt/gte/lt/lte parameters;
+// nil when there is none. A lower and an upper bound together become a
+// BETWEEN, so the scan walks only that range.
+func scanCondition(keyType storage.DataType, params map[string][]string) (*query.ScanCondition, error) {
+	get := func(name string) (types.Comparable, error) {
+		values := params[name]
+		if len(values) == 0 {
+			return nil, nil
+		}
+		key, err := parseKey(keyType, values[0])
+		if err != nil {
+			return nil, badRequest("%s: %v", name, err)
+		}
+		return key, nil
+	}
+	var conditions []*query.ScanCondition
+	var lower, upper types.Comparable
+	for _, bound := range []struct {
+		name  string
+		build func(types.Comparable) *query.ScanCondition
+	}{
+		{"eq", query.Equal},
+		{"gt", query.GreaterThan},
+		{"gte", query.GreaterOrEqual},
+		{"lt", query.LessThan},
+		{"lte", query.LessOrEqual},
+	} {
+		key, err := get(bound.name)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			continue
+		}
+		conditions = append(conditions, bound.build(key))
+		switch bound.name {
+		case "gt", "gte":
+			lower = key
+		case "lt", "lte":
+			upper = key
+		}
+	}
+	if lower != nil && upper != nil {
+		conditions = append([]*query.ScanCondition{query.Between(lower, upper)}, conditions...)
+	}
+	switch len(conditions) {
+	case 0:
+		return nil, nil
+	case 1:
+		return conditions[0], nil
+	}
+	return query.And(conditions...), nil
+}
+
+// parseKey parses the text form of a key of type keyType.
+func parseKey(keyType storage.DataType, s string) (types.Comparable, error) {
+	var key types.Comparable
+	var err error
+	switch keyType {
+	case storage.TypeInt:
+		var v int64
+		v, err = strconv.ParseInt(s, 10, 64)
+		key = types.IntKey(v)
+	case storage.TypeVarchar:
+		key = types.VarcharKey(s)
+	case storage.TypeBoolean:
+		var v bool
+		v, err = strconv.ParseBool(s)
+		key = types.BoolKey(v)
+	case storage.TypeFloat:
+		var v float64
+		v, err = strconv.ParseFloat(s, 64)
+		key = types.FloatKey(v)
+	case storage.TypeDate:
+		var v time.Time
+		v, err = time.Parse(time.RFC3339Nano, s)
+		key = types.DateKey(v)
+	case storage.TypeDecimal:
+		key, err = types.ParseDecimal(s)
+	case storage.TypeUUID:
+		key, err = types.ParseUUID(s)
+	case storage.TypeBytes:
+		var v []byte
+		v, err = base64.RawURLEncoding.DecodeString(s)
+		key = types.BytesKey(v)
+	default:
+		return nil, badRequest("unsupported key type %v", keyType)
+	}
+	if err != nil {
+		return nil, badRequest("invalid %v key %q: %v", keyType, s, err)
+	}
+	return key, nil
+}
+
+// readDocument returns the JSON body of r.
+func readDocument(r *http.Request) (string, error) {
+	var doc json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+		return "", badRequest("body: %v", err)
+	}
+	return string(doc), nil
+}
+
+// requestError is an error of the request itself, answered with 400.
+type requestError struct{ msg string }
+
+func (e *requestError) Error() string { return e.msg }
+
+func badRequest(format string, args ...any) error {
+	return &requestError{msg: fmt.Sprintf(format, args...)}
+}
+
+// errorStatus maps an error to its HTTP status.
+func errorStatus(err error) int {
+	var (
+		request       *requestError
+		tableNotFound *storageerrors.TableNotFoundError
+		indexNotFound *storageerrors.IndexNotFoundError
+		rowNotFound   *storageerrors.RowNotFoundError
+		duplicate     *storageerrors.DuplicateKeyError
+		invalidKey    *storageerrors.InvalidKeyTypeError
+		validation    *storageerrors.ValidationError
+	)
+	switch {
+	case errors.As(err, &request), errors.As(err, &invalidKey), errors.As(err, &validation),
+		errors.Is(err, storage.ErrBadScanToken):
+		return http.StatusBadRequest
+	case errors.As(err, &tableNotFound), errors.As(err, &indexNotFound), errors.As(err, &rowNotFound):
+		return http.StatusNotFound
+	case errors.As(err, &duplicate), errors.Is(err, storage.ErrWriteConflict),
+		errors.Is(err, storage.ErrSerializationConflict), errors.Is(err, storage.ErrDeadlockVictim):
+		return http.StatusConflict
+	case errors.Is(err, storage.ErrEngineDegraded):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	writeJSON(w, errorStatus(err), map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
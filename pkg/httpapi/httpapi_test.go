@@ -0,0 +1,146 @@
+package httpapi_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/httpapi"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+)
+
+func startServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	se, err := storage.Open("", storage.Options{InMemory: true})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := se.CreateTable("users", []storage.Index{
+		{Name: "id", Primary: true, Type: storage.TypeInt},
+		{Name: "email", Type: storage.TypeVarchar},
+	}); err != nil {
+		t.Fatalf("CreateTable: %v", err)
+	}
+	srv := httptest.NewServer(httpapi.New(se))
+	t.Cleanup(func() {
+		srv.Close()
+		se.Close()
+	})
+	return srv
+}
+
+func do(t *testing.T, method, url, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(data)
+}
+
+func TestHandler_Rows(t *testing.T) {
+	srv := startServer(t)
+
+	if code, body := do(t, "POST", srv.URL+"/tables/users/rows", `{"id": 1, "email": "ana@example.com"}`); code != http.StatusCreated {
+		t.Fatalf("POST = %d %s", code, body)
+	}
+	code, body := do(t, "GET", srv.URL+"/tables/users/rows/1", "")
+	if code != http.StatusOK || !strings.Contains(body, "ana@example.com") {
+		t.Fatalf("GET = %d %s", code, body)
+	}
+	if code, body := do(t, "GET", srv.URL+"/tables/users/rows/ana@example.com?index=email", ""); code != http.StatusOK {
+		t.Fatalf("GET by email = %d %s", code, body)
+	}
+
+	if code, body := do(t, "PUT", srv.URL+"/tables/users/rows/1", `{"id": 1, "email": "ana@new.example.com"}`); code != http.StatusNoContent {
+		t.Fatalf("PUT = %d %s", code, body)
+	}
+	if code, body := do(t, "GET", srv.URL+"/tables/users/rows/1", ""); !strings.Contains(body, "ana@new.example.com") {
+		t.Fatalf("GET after PUT = %d %s", code, body)
+	}
+	if code, _ := do(t, "PUT", srv.URL+"/tables/users/rows/2", `{"id": 3}`); code == http.StatusNoContent {
+		t.Fatal("PUT with a body of another key should fail")
+	}
+
+	if code, body := do(t, "DELETE", srv.URL+"/tables/users/rows/1", ""); code != http.StatusNoContent {
+		t.Fatalf("DELETE = %d %s", code, body)
+	}
+	for _, url := range []string{"/tables/users/rows/1", "/tables/missing/rows/1", "/tables/users/rows/1?index=missing"} {
+		if code, body := do(t, "GET", srv.URL+url, ""); code != http.StatusNotFound {
+			t.Fatalf("GET %s = %d %s, want 404", url, code, body)
+		}
+	}
+	if code, _ := do(t, "DELETE", srv.URL+"/tables/users/rows/1", ""); code != http.StatusNotFound {
+		t.Fatalf("DELETE of a missing row = %d, want 404", code)
+	}
+	if code, _ := do(t, "GET", srv.URL+"/tables/users/rows/abc", ""); code != http.StatusBadRequest {
+		t.Fatalf("GET with a bad key = %d, want 400", code)
+	}
+	if code, _ := do(t, "POST", srv.URL+"/tables/users/rows", `{"id": `); code != http.StatusBadRequest {
+		t.Fatalf("POST with a bad body = %d, want 400", code)
+	}
+}
+
+func TestHandler_ScanPages(t *testing.T) {
+	srv := startServer(t)
+	for i := 1; i <= 25; i++ {
+		doc := fmt.Sprintf(`{"id": %d, "email": "user%02d@example.com"}`, i, i)
+		if code, body := do(t, "POST", srv.URL+"/tables/users/rows", doc); code != http.StatusCreated {
+			t.Fatalf("POST %d = %d %s", i, code, body)
+		}
+	}
+
+	var ids []int
+	params := url.Values{"gt": {"3"}, "lte": {"22"}, "limit": {"7"}, "fields": {"id"}}
+	for pages := 1; ; pages++ {
+		code, body := do(t, "GET", srv.URL+"/tables/users/scan?"+params.Encode(), "")
+		if code != http.StatusOK {
+			t.Fatalf("scan = %d %s", code, body)
+		}
+		var page httpapi.ScanPage
+		if err := json.Unmarshal([]byte(body), &page); err != nil {
+			t.Fatalf("decode %s: %v", body, err)
+		}
+		for _, row := range page.Rows {
+			var doc struct{ ID int }
+			if err := json.Unmarshal(row, &doc); err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, doc.ID)
+		}
+		if page.NextPageToken == "" {
+			if pages != 3 {
+				t.Fatalf("%d pages, want 3", pages)
+			}
+			break
+		}
+		params.Set("page_token", page.NextPageToken)
+	}
+	if len(ids) != 19 || ids[0] != 4 || ids[18] != 22 {
+		t.Fatalf("scanned ids = %v", ids)
+	}
+
+	code, body := do(t, "GET", srv.URL+"/tables/users/scan?index=email&reverse=true&limit=2&fields=email", "")
+	if code != http.StatusOK || !strings.Contains(body, "user25@") || !strings.Contains(body, "user24@") {
+		t.Fatalf("reverse scan = %d %s", code, body)
+	}
+	for _, query := range []string{"limit=0", "page_token=!!", "gt=abc", "reverse=maybe"} {
+		if code, body := do(t, "GET", srv.URL+"/tables/users/scan?"+query, ""); code != http.StatusBadRequest {
+			t.Fatalf("scan?%s = %d %s, want 400", query, code, body)
+		}
+	}
+}
@@ -44,19 +44,56 @@ type BufferPool struct {
 	lru    *list.List // front = mais recente, back = menos recente
 
 	beforeFlush func(pageID PageID, page *Page) error
+
+	hits, misses, evictions atomic.Uint64
+
+	// auditID identifies the pool in the latch audit, which outlives the pool.
+	auditID uint64
+
+	// versionClock hands out the frame versions: none repeats.
+	versionClock atomic.Uint64
 }
 
+var nextPoolAuditID atomic.Uint64
+
+// BufferPoolStats are cumulative counters of the pool since it was created.
+type BufferPoolStats struct {
+	Hits      uint64 // Fetch served by a cached frame
+	Misses    uint64 // Fetch that had to read the page from disk
+	Evictions uint64 // frames dropped to make room
+}
+
+// HitRatio returns Hits / (Hits + Misses), or 0 before any Fetch.
+func (s BufferPoolStats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// DirtyPageInfo describes a dirty cached page. RecLSN is the pageLSN seen
+// when the page became dirty (the first change not yet written); PageLSN
+// is that of the latest change. Redo from a checkpoint must start at the
+// smallest RecLSN, not at the smallest PageLSN.
 type DirtyPageInfo struct {
 	PageID  PageID
 	PageLSN uint64
+	RecLSN  uint64
 }
 
 type frame struct {
 	page     Page
 	pageID   PageID
 	dirty    atomic.Bool
+	recLSN   atomic.Uint64 // pageLSN of the first change since the last flush
 	pinCount atomic.Int32
 
+	// version changes on every write latch acquired (odd while held)
+	// and released, and stays odd for good once the frame leaves the
+	// pool. See PageStamp.
+	version atomic.Uint64
+
 	rw sync.RWMutex // protege `page`
 
 	// só é tocado com pool.mu segurado
@@ -73,12 +110,22 @@ func NewBufferPool(pf *PageFile, capacity int) *BufferPool {
 		capacity: capacity,
 		frames:   make(map[PageID]*frame, capacity),
 		lru:      list.New(),
+		auditID:  nextPoolAuditID.Add(1),
 	}
 }
 
 // Capacity devolve a capacidade configurada.
 func (bp *BufferPool) Capacity() int { return bp.capacity }
 
+// Stats returns the hit/miss/eviction counters of the pool.
+func (bp *BufferPool) Stats() BufferPoolStats {
+	return BufferPoolStats{
+		Hits:      bp.hits.Load(),
+		Misses:    bp.misses.Load(),
+		Evictions: bp.evictions.Load(),
+	}
+}
+
 // Size devolve quantos frames estão ocupados no momento.
 func (bp *BufferPool) Size() int {
 	bp.mu.Lock()
@@ -115,9 +162,14 @@ func (bp *BufferPool) DirtyPages() []DirtyPageInfo {
 		if err != nil {
 			continue
 		}
+		recLSN := f.recLSN.Load()
+		if recLSN == 0 {
+			recLSN = hdr.PageLSN
+		}
 		dirty = append(dirty, DirtyPageInfo{
 			PageID:  f.pageID,
 			PageLSN: hdr.PageLSN,
+			RecLSN:  recLSN,
 		})
 	}
 	return dirty
@@ -153,6 +205,7 @@ func (bp *BufferPool) fetch(pageID PageID, write bool) (*PageHandle, error) {
 	bp.mu.Lock()
 
 	if f, ok := bp.frames[pageID]; ok {
+		bp.hits.Add(1)
 		bp.lru.MoveToFront(f.lruElem)
 		f.pinCount.Add(1)
 		bp.mu.Unlock()
@@ -162,6 +215,7 @@ func (bp *BufferPool) fetch(pageID PageID, write bool) (*PageHandle, error) {
 	}
 
 	// Miss: garante espaço antes de carregar.
+	bp.misses.Add(1)
 	for len(bp.frames) >= bp.capacity {
 		if !bp.tryEvictLocked() {
 			bp.mu.Unlock()
@@ -177,6 +231,7 @@ func (bp *BufferPool) fetch(pageID PageID, write bool) (*PageHandle, error) {
 	}
 
 	f := &frame{pageID: pageID, page: *p}
+	bp.stamp(f, false)
 	f.pinCount.Add(1)
 	f.lruElem = bp.lru.PushFront(f)
 	bp.frames[pageID] = f
@@ -187,11 +242,26 @@ func (bp *BufferPool) fetch(pageID PageID, write bool) (*PageHandle, error) {
 }
 
 func (bp *BufferPool) acquireLatch(f *frame, write bool) {
-	if write {
+	switch {
+	case latchAuditEnabled:
+		auditAcquire(bp, f, write)
+	case write:
 		f.rw.Lock()
-	} else {
+	default:
 		f.rw.RLock()
 	}
+	if write {
+		bp.stamp(f, true)
+	}
+}
+
+// stamp gives the frame a new version, odd if writing.
+func (bp *BufferPool) stamp(f *frame, writing bool) {
+	v := bp.versionClock.Add(1) << 1
+	if writing {
+		v |= 1
+	}
+	f.version.Store(v)
 }
 
 // tryEvictLocked tenta evictar uma page not-pinada, varrendo do tail
@@ -216,16 +286,19 @@ func (bp *BufferPool) tryEvictLocked() bool {
 				}
 			}
 			err := bp.pf.WritePage(f.pageID, &f.page)
-			f.rw.RUnlock()
 			if err != nil {
+				f.rw.RUnlock()
 				// Not evicta se flush failed — melhor manter do que perder dados.
 				return false
 			}
-			f.dirty.Store(false)
+			f.markClean()
+			f.rw.RUnlock()
 		}
 
 		delete(bp.frames, f.pageID)
 		bp.lru.Remove(e)
+		bp.stamp(f, true)
+		bp.evictions.Add(1)
 		return true
 	}
 	return false
@@ -249,19 +322,28 @@ func (bp *BufferPool) NewPage() (*PageHandle, error) {
 	}
 
 	f := &frame{pageID: pageID}
+	bp.stamp(f, false)
 	f.pinCount.Add(1)
 	f.dirty.Store(true) // garante write inicial no flush
 	f.lruElem = bp.lru.PushFront(f)
 	bp.frames[pageID] = f
 	bp.mu.Unlock()
 
-	f.rw.Lock()
+	bp.acquireLatch(f, true)
 	return &PageHandle{bp: bp, frame: f, write: true}, nil
 }
 
 // FlushAll grava todas as pages sujas no PageFile e chama fsync.
 // Not evicta — as pages continuam no pool, apenas deixam de estar sujas.
 func (bp *BufferPool) FlushAll() error {
+	if err := bp.FlushDirty(); err != nil {
+		return err
+	}
+	return bp.pf.Sync()
+}
+
+// FlushDirty writes the dirty pages to the PageFile without fsync.
+func (bp *BufferPool) FlushDirty() error {
 	bp.mu.Lock()
 	dirty := make([]*frame, 0, len(bp.frames))
 	for _, f := range bp.frames {
@@ -280,13 +362,21 @@ func (bp *BufferPool) FlushAll() error {
 			}
 		}
 		err := bp.pf.WritePage(f.pageID, &f.page)
-		f.rw.RUnlock()
 		if err != nil {
+			f.rw.RUnlock()
 			return err
 		}
-		f.dirty.Store(false)
+		// Clean it with the latch still held: a writer that comes in
+		// after the RUnlock marks the page dirty again.
+		f.markClean()
+		f.rw.RUnlock()
 	}
-	return bp.pf.Sync()
+	return nil
+}
+
+func (f *frame) markClean() {
+	f.dirty.Store(false)
+	f.recLSN.Store(0)
 }
 
 // Close flusha e libera todos os frames. Not fecha o PageFile — isso
@@ -297,6 +387,9 @@ func (bp *BufferPool) Close() error {
 	}
 	bp.mu.Lock()
 	defer bp.mu.Unlock()
+	for _, f := range bp.frames {
+		bp.stamp(f, true)
+	}
 	bp.frames = make(map[PageID]*frame)
 	bp.lru = list.New()
 	return nil
@@ -323,10 +416,43 @@ func (h *PageHandle) Page() *Page { return &h.frame.page }
 // ID devolve o PageID do frame.
 func (h *PageHandle) ID() PageID { return h.frame.pageID }
 
-// MarkDirty sinaliza que o content foi modificado. Só faz sentido
-// depois de um FetchForWrite ou NewPage — marcar com latch de read
-// é um bug no chamador mas does not cause corruption (só flush desnecessário).
-func (h *PageHandle) MarkDirty() { h.frame.dirty.Store(true) }
+// MarkDirty signals that the content was modified. It only makes sense
+// after a FetchForWrite or NewPage — marking under a read latch is a bug
+// in the caller but does not cause corruption (only an unneeded flush).
+// The first call with pageLSN != 0 since the last flush sets the frame's
+// RecLSN, so the pageLSN must be advanced first.
+func (h *PageHandle) MarkDirty() {
+	f := h.frame
+	f.dirty.Store(true)
+	if f.recLSN.Load() == 0 {
+		if hdr, err := f.page.GetHeader(); err == nil {
+			f.recLSN.Store(hdr.PageLSN)
+		}
+	}
+}
+
+// Stamp returns the current version of the page. While the handle's read
+// latch is held it does not change until the Release; afterwards,
+// PageStamp.Valid tells whether someone wrote to the page (or evicted it
+// from the pool) since.
+func (h *PageHandle) Stamp() PageStamp {
+	return PageStamp{frame: h.frame, version: h.frame.version.Load()}
+}
+
+// PageStamp is the version of a page at one moment, for optimistic reads:
+// whoever kept a copy of the page along with the stamp may use it without
+// a latch while Valid is true.
+type PageStamp struct {
+	frame   *frame
+	version uint64
+}
+
+// Valid reports, without a latch or lock, whether the page is still in the
+// pool at the same version: no write latch was acquired on it since the
+// stamp.
+func (s PageStamp) Valid() bool {
+	return s.frame != nil && s.version&1 == 0 && s.frame.version.Load() == s.version
+}
 
 // Release libera o latch e decrementa o pinCount. Idempotente.
 // Em caso de PAGES de write sujas, a gravação só acontece em
@@ -335,7 +461,11 @@ func (h *PageHandle) Release() {
 	if !h.released.CompareAndSwap(false, true) {
 		return
 	}
+	if latchAuditEnabled {
+		auditRelease(h.bp, h.frame)
+	}
 	if h.write {
+		h.bp.stamp(h.frame, false)
 		h.frame.rw.Unlock()
 	} else {
 		h.frame.rw.RUnlock()
@@ -170,6 +170,34 @@ func TestBufferPool_LRUEviction_OrderCorrect(t *testing.T) {
 	}
 }
 
+func TestBufferPool_StatsCountHitsMissesEvictions(t *testing.T) {
+	bp, pf := newPoolWithFile(t, 2)
+	id1 := allocAndWrite(t, bp, 1)
+	id2 := allocAndWrite(t, bp, 2)
+	id3 := allocAndWrite(t, bp, 3)
+
+	bp.Close()
+	bp = NewBufferPool(pf, 2)
+	t.Cleanup(func() { bp.Close() })
+
+	for _, id := range []PageID{id1, id2, id1, id3, id1} {
+		h, err := bp.Fetch(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Release()
+	}
+
+	// id1 miss, id2 miss, id1 hit, id3 miss (evicts id2), id1 hit.
+	want := BufferPoolStats{Hits: 2, Misses: 3, Evictions: 1}
+	if got := bp.Stats(); got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+	if r := bp.Stats().HitRatio(); r != 0.4 {
+		t.Fatalf("expected hit ratio 0.4, got %v", r)
+	}
+}
+
 func TestBufferPool_PinnedPagesNeverEvicted(t *testing.T) {
 	bp, _ := newPoolWithFile(t, 2)
 
@@ -385,3 +413,85 @@ func TestBufferPool_HeavyEviction(t *testing.T) {
 	}
 	_ = fmt.Sprint // evita import-not-used
 }
+
+func TestBufferPool_DirtyPagesTrackRecLSN(t *testing.T) {
+	bp, _ := newPoolWithFile(t, 8)
+	id := allocAndWrite(t, bp, 1)
+
+	write := func(lsn uint64) {
+		h, err := bp.FetchForWrite(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Page().AdvancePageLSN(lsn)
+		h.MarkDirty()
+		h.Release()
+	}
+	write(7)
+	write(9)
+
+	dirty := bp.DirtyPages()
+	if len(dirty) != 1 || dirty[0].RecLSN != 7 || dirty[0].PageLSN != 9 {
+		t.Fatalf("expected RecLSN=7 PageLSN=9, got %+v", dirty)
+	}
+
+	// After the flush the next change starts a new RecLSN.
+	if err := bp.FlushDirty(); err != nil {
+		t.Fatal(err)
+	}
+	if dirty := bp.DirtyPages(); len(dirty) != 0 {
+		t.Fatalf("expected no dirty pages after flush, got %+v", dirty)
+	}
+	write(12)
+	if dirty := bp.DirtyPages(); len(dirty) != 1 || dirty[0].RecLSN != 12 {
+		t.Fatalf("expected RecLSN=12, got %+v", dirty)
+	}
+}
+
+// TestPageHandle_StampInvalidatedByWriteAndEviction: a frame's stamp
+// stays valid across read latches and stops being valid after a write
+// latch or the eviction of the frame.
+func TestPageHandle_StampInvalidatedByWriteAndEviction(t *testing.T) {
+	bp, _ := newPoolWithFile(t, 1)
+	id1 := allocAndWrite(t, bp, 1)
+	id2 := allocAndWrite(t, bp, 2)
+
+	stampOf := func(id PageID) PageStamp {
+		t.Helper()
+		h, err := bp.Fetch(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer h.Release()
+		return h.Stamp()
+	}
+
+	s := stampOf(id1)
+	if !s.Valid() {
+		t.Fatal("stamp invalid right after the fetch")
+	}
+	if !stampOf(id1).Valid() || stampOf(id1) != s {
+		t.Fatal("read latch changed the stamp")
+	}
+
+	h, err := bp.FetchForWrite(id1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Valid() {
+		t.Fatal("stamp valid while the write latch is held")
+	}
+	h.Release()
+	if s.Valid() {
+		t.Fatal("stamp valid after the write latch")
+	}
+
+	s = stampOf(id1)
+	stampOf(id2) // capacity 1: evicts id1
+	if s.Valid() {
+		t.Fatal("stamp valid after the eviction")
+	}
+	if (PageStamp{}).Valid() {
+		t.Fatal("zero stamp valid")
+	}
+}
@@ -0,0 +1,37 @@
+package pagestore
+
+import "time"
+
+// LatchAuditEnabled reports whether the package was built with
+// -tags latchaudit. Only then are page latches audited (see latchaudit.go)
+// and the handler set with SetLatchAuditHandler ever called.
+const LatchAuditEnabled = latchAuditEnabled
+
+// Kinds of LatchReport.
+const (
+	// LatchOrderCycle: a goroutine took a latch while holding another in
+	// the opposite order of some earlier acquisition.
+	LatchOrderCycle = "order-cycle"
+	// LatchSlowWait: a latch was not acquired within the wait threshold.
+	LatchSlowWait = "slow-wait"
+)
+
+// LatchReport describes a latch ordering or wait problem found by the
+// latch audit.
+type LatchReport struct {
+	Kind      string
+	Goroutine int64
+	Page      PageID   // the page being acquired
+	Held      []PageID // pages the goroutine held, oldest first
+	Detail    string
+	Stacks    []byte // the goroutine's stack; every goroutine's for LatchSlowWait
+}
+
+// SetLatchAuditHandler routes latch audit reports to fn; nil restores the
+// default, which prints them to stderr. A no-op unless LatchAuditEnabled.
+func SetLatchAuditHandler(fn func(LatchReport)) { setLatchAuditHandler(fn) }
+
+// SetLatchWaitThreshold sets how long a latch acquisition may wait before
+// a LatchSlowWait report; 0 disables them. The default is one second. A
+// no-op unless LatchAuditEnabled.
+func SetLatchWaitThreshold(d time.Duration) { setLatchWaitThreshold(d) }
@@ -0,0 +1,267 @@
+//go:build latchaudit
+
+package pagestore
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Built with -tags latchaudit: every page latch acquired by Fetch,
+// FetchForWrite and NewPage is tracked per goroutine until its Release.
+//
+//   - Order: acquiring B while holding A records the edge A → B in a
+//     global graph. An edge that closes a cycle (someone already acquired
+//     A while holding B, directly or indirectly) is an order inversion, a
+//     deadlock waiting for the right interleaving, and yields an
+//     "order-cycle" report.
+//   - Wait: a latch not acquired within LatchWaitThreshold yields a
+//     "slow-wait" report with the latches held by every goroutine and
+//     all their stacks; the wait goes on after the report.
+//
+// A report is a candidate: an inversion between paths that a mutex above
+// the latches serializes (a tree's writeMu, for example) never actually
+// deadlocks. The graph only grows, so the mode is meant for tests and
+// debugging, not for production.
+const latchAuditEnabled = true
+
+type latchKey struct {
+	pool   uint64 // BufferPool.auditID
+	pageID PageID
+}
+
+type heldLatch struct {
+	key   latchKey
+	write bool
+}
+
+var latchAudit = struct {
+	mu        sync.Mutex
+	held      map[int64][]heldLatch
+	edges     map[latchKey]map[latchKey]struct{}
+	reported  map[[2]latchKey]bool
+	handler   func(LatchReport)
+	threshold time.Duration
+}{
+	held:      make(map[int64][]heldLatch),
+	edges:     make(map[latchKey]map[latchKey]struct{}),
+	reported:  make(map[[2]latchKey]bool),
+	threshold: time.Second,
+}
+
+func setLatchAuditHandler(fn func(LatchReport)) {
+	latchAudit.mu.Lock()
+	latchAudit.handler = fn
+	latchAudit.mu.Unlock()
+}
+
+func setLatchWaitThreshold(d time.Duration) {
+	latchAudit.mu.Lock()
+	latchAudit.threshold = d
+	latchAudit.mu.Unlock()
+}
+
+func auditAcquire(bp *BufferPool, f *frame, write bool) {
+	key := latchKey{pool: bp.auditID, pageID: f.pageID}
+	gid := goroutineID()
+
+	var reports []LatchReport
+	latchAudit.mu.Lock()
+	held := latchAudit.held[gid]
+	for _, h := range held {
+		if h.key == key {
+			continue
+		}
+		if r, ok := addLatchEdgeLocked(gid, h.key, key, held); ok {
+			reports = append(reports, r)
+		}
+	}
+	threshold := latchAudit.threshold
+	latchAudit.mu.Unlock()
+	for _, r := range reports {
+		emitLatchReport(r)
+	}
+
+	tryLock := f.rw.TryRLock
+	lock := f.rw.RLock
+	if write {
+		tryLock, lock = f.rw.TryLock, f.rw.Lock
+	}
+	start := time.Now()
+	for backoff := time.Microsecond; !tryLock(); {
+		if threshold > 0 && time.Since(start) >= threshold {
+			emitLatchReport(slowWaitReport(gid, key, write, time.Since(start)))
+			lock()
+			break
+		}
+		time.Sleep(backoff)
+		if backoff < time.Millisecond {
+			backoff *= 2
+		}
+	}
+
+	latchAudit.mu.Lock()
+	latchAudit.held[gid] = append(latchAudit.held[gid], heldLatch{key: key, write: write})
+	latchAudit.mu.Unlock()
+}
+
+func auditRelease(bp *BufferPool, f *frame) {
+	key := latchKey{pool: bp.auditID, pageID: f.pageID}
+	gid := goroutineID()
+	latchAudit.mu.Lock()
+	defer latchAudit.mu.Unlock()
+	// A handle may be released by another goroutine; look there first.
+	if removeHeldLocked(gid, key) {
+		return
+	}
+	for other := range latchAudit.held {
+		if removeHeldLocked(other, key) {
+			return
+		}
+	}
+}
+
+func removeHeldLocked(gid int64, key latchKey) bool {
+	held := latchAudit.held[gid]
+	for i := len(held) - 1; i >= 0; i-- {
+		if held[i].key != key {
+			continue
+		}
+		held = append(held[:i], held[i+1:]...)
+		if len(held) == 0 {
+			delete(latchAudit.held, gid)
+		} else {
+			latchAudit.held[gid] = held
+		}
+		return true
+	}
+	return false
+}
+
+// addLatchEdgeLocked records from → to and returns a report if to
+// already reaches from in the graph. Each pair is reported once.
+func addLatchEdgeLocked(gid int64, from, to latchKey, held []heldLatch) (LatchReport, bool) {
+	out := latchAudit.edges[from]
+	if out == nil {
+		out = make(map[latchKey]struct{})
+		latchAudit.edges[from] = out
+	}
+	if _, ok := out[to]; ok {
+		return LatchReport{}, false
+	}
+	out[to] = struct{}{}
+
+	path := latchPathLocked(to, from)
+	if path == nil || latchAudit.reported[[2]latchKey{from, to}] {
+		return LatchReport{}, false
+	}
+	latchAudit.reported[[2]latchKey{from, to}] = true
+	cycle := make([]PageID, 0, len(path)+1)
+	cycle = append(cycle, from.pageID)
+	for _, k := range path {
+		cycle = append(cycle, k.pageID)
+	}
+	return LatchReport{
+		Kind:      LatchOrderCycle,
+		Goroutine: gid,
+		Page:      to.pageID,
+		Held:      heldPages(held),
+		Detail:    fmt.Sprintf("acquiring page %d while holding page %d closes the cycle %v", to.pageID, from.pageID, cycle),
+		Stacks:    stacks(false),
+	}, true
+}
+
+// latchPathLocked returns a path from → ... → to in the graph, or nil.
+func latchPathLocked(from, to latchKey) []latchKey {
+	prev := map[latchKey]latchKey{from: from}
+	queue := []latchKey{from}
+	for len(queue) > 0 {
+		k := queue[0]
+		queue = queue[1:]
+		if k == to {
+			var path []latchKey
+			for ; k != from; k = prev[k] {
+				path = append([]latchKey{k}, path...)
+			}
+			return append([]latchKey{from}, path...)
+		}
+		for next := range latchAudit.edges[k] {
+			if _, seen := prev[next]; !seen {
+				prev[next] = k
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
+
+func slowWaitReport(gid int64, key latchKey, write bool, waited time.Duration) LatchReport {
+	mode := "read"
+	if write {
+		mode = "write"
+	}
+	var detail bytes.Buffer
+	fmt.Fprintf(&detail, "%s latch on page %d not acquired after %v; latches held:", mode, key.pageID, waited)
+	latchAudit.mu.Lock()
+	held := latchAudit.held[gid]
+	for other, latches := range latchAudit.held {
+		fmt.Fprintf(&detail, " goroutine %d %v;", other, heldPages(latches))
+	}
+	latchAudit.mu.Unlock()
+	return LatchReport{
+		Kind:      LatchSlowWait,
+		Goroutine: gid,
+		Page:      key.pageID,
+		Held:      heldPages(held),
+		Detail:    detail.String(),
+		Stacks:    stacks(true),
+	}
+}
+
+func emitLatchReport(r LatchReport) {
+	latchAudit.mu.Lock()
+	handler := latchAudit.handler
+	latchAudit.mu.Unlock()
+	if handler != nil {
+		handler(r)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "pagestore: latch audit: %s: goroutine %d: %s\n%s\n", r.Kind, r.Goroutine, r.Detail, r.Stacks)
+}
+
+func heldPages(held []heldLatch) []PageID {
+	pages := make([]PageID, len(held))
+	for i, h := range held {
+		pages[i] = h.key.pageID
+	}
+	return pages
+}
+
+func stacks(all bool) []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, all)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// goroutineID reads the id of the current goroutine from the header of
+// its stack ("goroutine 42 [running]:").
+func goroutineID() int64 {
+	var buf [64]byte
+	line := buf[:runtime.Stack(buf[:], false)]
+	line = bytes.TrimPrefix(line, []byte("goroutine "))
+	if i := bytes.IndexByte(line, ' '); i > 0 {
+		line = line[:i]
+	}
+	id, _ := strconv.ParseInt(string(line), 10, 64)
+	return id
+}
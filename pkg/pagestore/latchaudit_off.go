@@ -0,0 +1,12 @@
+//go:build !latchaudit
+
+package pagestore
+
+import "time"
+
+const latchAuditEnabled = false
+
+func setLatchAuditHandler(func(LatchReport)) {}
+func setLatchWaitThreshold(time.Duration)    {}
+func auditAcquire(*BufferPool, *frame, bool) {}
+func auditRelease(*BufferPool, *frame)       {}
@@ -0,0 +1,115 @@
+//go:build latchaudit
+
+package pagestore
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collectLatchReports installs a handler that keeps the reports until the
+// end of the test.
+func collectLatchReports(t *testing.T) func() []LatchReport {
+	t.Helper()
+	var mu sync.Mutex
+	var reports []LatchReport
+	SetLatchAuditHandler(func(r LatchReport) {
+		mu.Lock()
+		reports = append(reports, r)
+		mu.Unlock()
+	})
+	t.Cleanup(func() { SetLatchAuditHandler(nil) })
+	return func() []LatchReport {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]LatchReport(nil), reports...)
+	}
+}
+
+// newAuditPool creates a pool with n new pages, already released.
+func newAuditPool(t *testing.T, n int) (*BufferPool, []PageID) {
+	t.Helper()
+	pf, err := NewPageFile(filepath.Join(t.TempDir(), "audit.db"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pf.Close() })
+	bp := NewBufferPool(pf, 8)
+	ids := make([]PageID, n)
+	for i := range ids {
+		h, err := bp.NewPage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = h.ID()
+		h.Release()
+	}
+	return bp, ids
+}
+
+func TestLatchAudit_ReportsOrderCycle(t *testing.T) {
+	reports := collectLatchReports(t)
+	bp, ids := newAuditPool(t, 3)
+	a, b, c := ids[0], ids[1], ids[2]
+
+	take := func(first, second PageID) {
+		t.Helper()
+		h1, err := bp.Fetch(first)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h2, err := bp.FetchForWrite(second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h2.Release()
+		h1.Release()
+	}
+	take(a, b)
+	take(b, c)
+	if got := reports(); len(got) != 0 {
+		t.Fatalf("consistent order reported: %+v", got)
+	}
+
+	take(c, a) // closes a → b → c → a
+	got := reports()
+	if len(got) != 1 || got[0].Kind != LatchOrderCycle || got[0].Page != a || len(got[0].Held) != 1 || got[0].Held[0] != c {
+		t.Fatalf("reports = %+v, want one cycle acquiring %d holding %d", got, a, c)
+	}
+	take(c, a)
+	if n := len(reports()); n != 1 {
+		t.Fatalf("the same inversion was reported %d times", n)
+	}
+}
+
+func TestLatchAudit_ReportsSlowWait(t *testing.T) {
+	reports := collectLatchReports(t)
+	SetLatchWaitThreshold(20 * time.Millisecond)
+	t.Cleanup(func() { SetLatchWaitThreshold(time.Second) })
+	bp, ids := newAuditPool(t, 1)
+
+	h, err := bp.FetchForWrite(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r, err := bp.Fetch(ids[0])
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		r.Release()
+	}()
+	time.Sleep(60 * time.Millisecond)
+	h.Release()
+	<-done
+
+	got := reports()
+	if len(got) != 1 || got[0].Kind != LatchSlowWait || got[0].Page != ids[0] || len(got[0].Stacks) == 0 {
+		t.Fatalf("reports = %+v, want one slow wait on %d", got, ids[0])
+	}
+}
@@ -0,0 +1,48 @@
+package pagestore
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// locked holds, by absolute path, the files this process locks with
+// Exclusive. flock is per open: without it, reopening in the same process
+// a file that another PageFile still has open (which the crash tests do,
+// without closing the first one) would give ErrLocked. The lock belongs
+// to the process.
+var (
+	lockedMu sync.Mutex
+	locked   = make(map[string]bool)
+)
+
+// lockExclusive locks f against other processes. It returns the key to
+// release with unlockExclusive on Close, or "" if this process already
+// held the lock through another PageFile.
+func lockExclusive(f *os.File, path string) (string, error) {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	lockedMu.Lock()
+	defer lockedMu.Unlock()
+	if locked[key] {
+		return "", nil
+	}
+	if err := flockExclusive(f); err != nil {
+		return "", err
+	}
+	locked[key] = true
+	return key, nil
+}
+
+// unlockExclusive forgets the lock of key; the flock itself goes away
+// when the file is closed.
+func unlockExclusive(key string) {
+	if key == "" {
+		return
+	}
+	lockedMu.Lock()
+	delete(locked, key)
+	lockedMu.Unlock()
+}
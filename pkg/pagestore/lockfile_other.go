@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package pagestore
+
+import "os"
+
+// flockExclusive does nothing where flock does not exist: two processes
+// opening the same file are not detected.
+func flockExclusive(*os.File) error {
+	return nil
+}
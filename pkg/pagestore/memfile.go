@@ -0,0 +1,149 @@
+package pagestore
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bobboyms/storage-engine/pkg/crypto"
+)
+
+// MemPrefix marks the paths of in-memory files. NewPageFile with a path
+// "mem:..." keeps the pages in a process buffer instead of on disk:
+// nothing is written, Sync does nothing and the content survives Close
+// (like a file) until RemoveMemAll. Meant for tests and ephemeral caches.
+const MemPrefix = "mem:"
+
+// backingFile is what PageFile uses of the file: an *os.File or a memFile.
+type backingFile interface {
+	io.ReaderAt
+	io.WriterAt
+	Stat() (os.FileInfo, error)
+	Truncate(size int64) error
+	Close() error
+}
+
+var (
+	memFilesMu sync.Mutex
+	memFiles   = make(map[string]*memFile)
+	memDirSeq  atomic.Uint64
+)
+
+// IsMemPath reports whether path names an in-memory file.
+func IsMemPath(path string) bool {
+	return strings.HasPrefix(path, MemPrefix)
+}
+
+// TempMemDir returns a new in-memory directory, "mem:/<n>", to group
+// files that RemoveMemAll deletes together.
+func TempMemDir() string {
+	return fmt.Sprintf("%s/%d", MemPrefix, memDirSeq.Add(1))
+}
+
+// RemoveMemAll deletes the in-memory file path and everything below it,
+// like os.RemoveAll. PageFiles still open keep the content.
+func RemoveMemAll(path string) {
+	memFilesMu.Lock()
+	defer memFilesMu.Unlock()
+	for name := range memFiles {
+		if name == path || strings.HasPrefix(name, path+"/") {
+			delete(memFiles, name)
+		}
+	}
+}
+
+// openMemPageFile is NewPageFileWithOptions for an in-memory path. Locks
+// and preallocation do not apply.
+func openMemPageFile(path string, cipher crypto.Cipher, opts PageFileOptions) (*PageFile, error) {
+	memFilesMu.Lock()
+	f, ok := memFiles[path]
+	if !ok {
+		if opts.ReadOnly {
+			memFilesMu.Unlock()
+			return nil, &fs.PathError{Op: "open", Path: path, Err: fs.ErrNotExist}
+		}
+		f = &memFile{name: path}
+		memFiles[path] = f
+	}
+	memFilesMu.Unlock()
+
+	opts.PreallocateBytes = 0
+	return newPageFile(path, f, cipher, opts, f.size()), nil
+}
+
+// memFile is the content of an in-memory file, shared by every PageFile
+// that opens it.
+type memFile struct {
+	name string
+	mu   sync.RWMutex
+	data []byte
+}
+
+func (f *memFile) size() int64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return int64(len(f.data))
+}
+
+func (f *memFile) ReadAt(p []byte, off int64) (int, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) WriteAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if end := off + int64(len(p)); end > int64(len(f.data)) {
+		if end > int64(cap(f.data)) {
+			grown := make([]byte, end, max(end, 2*int64(cap(f.data))))
+			copy(grown, f.data)
+			f.data = grown
+		} else {
+			f.data = f.data[:end]
+		}
+	}
+	return copy(f.data[off:], p), nil
+}
+
+func (f *memFile) Truncate(size int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if size < int64(len(f.data)) {
+		clear(f.data[size:])
+		f.data = f.data[:size]
+	} else if size > int64(len(f.data)) {
+		f.data = append(f.data, make([]byte, size-int64(len(f.data)))...)
+	}
+	return nil
+}
+
+func (f *memFile) Stat() (os.FileInfo, error) {
+	return memFileInfo{name: f.name, size: f.size()}, nil
+}
+
+func (f *memFile) Close() error { return nil }
+
+type memFileInfo struct {
+	name string
+	size int64
+}
+
+func (i memFileInfo) Name() string       { return i.name }
+func (i memFileInfo) Size() int64        { return i.size }
+func (i memFileInfo) Mode() fs.FileMode  { return 0o644 }
+func (i memFileInfo) ModTime() time.Time { return time.Time{} }
+func (i memFileInfo) IsDir() bool        { return false }
+func (i memFileInfo) Sys() any           { return nil }
@@ -0,0 +1,99 @@
+package pagestore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrMmapUnsupported is returned by EnableMmap on platforms without mmap.
+var ErrMmapUnsupported = errors.New("pagestore: mmap not supported on this platform")
+
+// EnableMmap makes ReadPage serve pages from a read-only mapping of the
+// file instead of a pread per page. Writes still go through pwrite; the OS
+// page cache is shared, so the mapping sees them. Pages past the mapped end
+// (the file grew) trigger a remap.
+func (pf *PageFile) EnableMmap() error {
+	if pf.closed.Load() {
+		return ErrClosed
+	}
+	if _, ok := pf.file.(*os.File); !ok {
+		return ErrMmapUnsupported // in-memory file
+	}
+	pf.mmapMu.Lock()
+	defer pf.mmapMu.Unlock()
+	pf.mmapEnabled = true
+	return pf.remapLocked()
+}
+
+// remapLocked maps the whole file. MUST be called with mmapMu held
+// exclusively.
+func (pf *PageFile) remapLocked() error {
+	stat, err := pf.file.Stat()
+	if err != nil {
+		return err
+	}
+	size := int(stat.Size())
+	if size == len(pf.mapping) {
+		return nil
+	}
+	if pf.mapping != nil {
+		if err := munmapFile(pf.mapping); err != nil {
+			return fmt.Errorf("pagestore: munmap: %w", err)
+		}
+		pf.mapping = nil
+	}
+	if size == 0 {
+		return nil
+	}
+	f, ok := pf.file.(*os.File)
+	if !ok {
+		return ErrMmapUnsupported
+	}
+	m, err := mmapFile(f, size)
+	if err != nil {
+		return fmt.Errorf("pagestore: mmap: %w", err)
+	}
+	pf.mapping = m
+	return nil
+}
+
+// readMapped copies the page from the mapping. It returns false when mmap
+// is off, leaving the caller to fall back to pread.
+func (pf *PageFile) readMapped(page *Page, offset int64) (bool, error) {
+	pf.mmapMu.RLock()
+	if !pf.mmapEnabled {
+		pf.mmapMu.RUnlock()
+		return false, nil
+	}
+	if end := offset + PageSize; end <= int64(len(pf.mapping)) {
+		copy(page[:], pf.mapping[offset:end])
+		pf.mmapMu.RUnlock()
+		return true, nil
+	}
+	pf.mmapMu.RUnlock()
+
+	pf.mmapMu.Lock()
+	defer pf.mmapMu.Unlock()
+	if err := pf.remapLocked(); err != nil {
+		return false, err
+	}
+	if end := offset + PageSize; end <= int64(len(pf.mapping)) {
+		copy(page[:], pf.mapping[offset:end])
+		return true, nil
+	}
+	return false, nil
+}
+
+// unmap drops the mapping on Close.
+func (pf *PageFile) unmap() error {
+	pf.mmapMu.Lock()
+	defer pf.mmapMu.Unlock()
+	pf.mmapEnabled = false
+	if pf.mapping == nil {
+		return nil
+	}
+	err := munmapFile(pf.mapping)
+	pf.mapping = nil
+	return err
+}
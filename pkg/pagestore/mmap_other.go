@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package pagestore
+
+import "os"
+
+func mmapFile(*os.File, int) ([]byte, error) {
+	return nil, ErrMmapUnsupported
+}
+
+func munmapFile([]byte) error {
+	return nil
+}
@@ -0,0 +1,64 @@
+package pagestore
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestMmap_ReadsWritesAndGrowth(t *testing.T) {
+	pf, _ := openTemp(t, newCipher(t))
+	defer pf.Close()
+	usable := pf.cipher.UsableBodySize()
+
+	write := func(seed byte) PageID {
+		t.Helper()
+		var p Page
+		fillBody(&p, seed, usable)
+		id, err := pf.AllocatePage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := pf.WritePage(id, &p); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	check := func(id PageID, seed byte) {
+		t.Helper()
+		var want Page
+		fillBody(&want, seed, usable)
+		got, err := pf.ReadPage(id)
+		if err != nil {
+			t.Fatalf("read %d: %v", id, err)
+		}
+		if !bytes.Equal(got.Body()[:usable], want.Body()[:usable]) {
+			t.Fatalf("page %d: body differs via mmap", id)
+		}
+	}
+
+	first := write(1)
+	if err := pf.EnableMmap(); errors.Is(err, ErrMmapUnsupported) {
+		t.Skip(err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	check(first, 1)
+
+	// Overwrite a mapped page and add a new page past the mapping.
+	var p Page
+	fillBody(&p, 9, usable)
+	if err := pf.WritePage(first, &p); err != nil {
+		t.Fatal(err)
+	}
+	check(first, 9)
+	second := write(2)
+	check(second, 2)
+
+	if err := pf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pf.ReadPage(first); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package pagestore
+
+import "errors"
+
+// ErrReadOnly is returned by every write operation on a PageFile opened
+// with ReadOnly.
+var ErrReadOnly = errors.New("pagestore: page file opened read-only")
+
+// ErrLocked is returned when opening with Exclusive a file that another
+// process already locks.
+var ErrLocked = errors.New("pagestore: file is locked by another open")
+
+// PageFileOptions configures NewPageFileWithOptions.
+type PageFileOptions struct {
+	// ReadOnly opens the file for reading only: it must exist, writes
+	// fail with ErrReadOnly and Close does not fsync.
+	ReadOnly bool
+
+	// Exclusive locks the file (flock) while it is open, so that two
+	// processes do not write to it at the same time; the open fails with
+	// ErrLocked if another one already holds the lock. Opens without
+	// Exclusive (read-only, WAL readers) ignore the lock.
+	Exclusive bool
+
+	// PreallocateBytes, when > 0, reserves disk space in blocks of this
+	// size ahead of the end of the file (fallocate with KEEP_SIZE on
+	// Linux). The logical size does not change — NumPages still counts
+	// only written pages — but the file system allocates contiguous
+	// extents, which helps on HDDs. A no-op on platforms without fallocate.
+	PreallocateBytes int64
+}
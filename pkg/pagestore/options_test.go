@@ -0,0 +1,156 @@
+package pagestore
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"runtime"
+	"testing"
+)
+
+func TestPageFile_ReadOnly(t *testing.T) {
+	pf, path := openTemp(t, nil)
+	var p Page
+	fillBody(&p, 7, 64)
+	id, _ := pf.AllocatePage()
+	if err := pf.WritePage(id, &p); err != nil {
+		t.Fatal(err)
+	}
+	if err := pf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ro, err := NewPageFileWithOptions(path, nil, PageFileOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ro.Close()
+	if _, err := ro.ReadPage(id); err != nil {
+		t.Fatalf("ReadPage read-only: %v", err)
+	}
+	if _, err := ro.AllocatePage(); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("AllocatePage: expected ErrReadOnly, got %v", err)
+	}
+	if err := ro.WritePage(id, &p); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("WritePage: expected ErrReadOnly, got %v", err)
+	}
+
+	if _, err := NewPageFileWithOptions(path+".missing", nil, PageFileOptions{ReadOnly: true}); err == nil {
+		t.Fatal("read-only should not create the file")
+	}
+}
+
+func TestPageFile_PreallocateKeepsLogicalSize(t *testing.T) {
+	path := t.TempDir() + "/pages.db"
+	pf, err := NewPageFileWithOptions(path, nil, PageFileOptions{PreallocateBytes: 64 * PageSize})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p Page
+	for i := 0; i < 3; i++ {
+		id, _ := pf.AllocatePage()
+		if err := pf.WritePage(id, &p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Size() != 4*PageSize {
+		t.Fatalf("preallocation changed the logical size: %d", stat.Size())
+	}
+	pf, err = NewPageFile(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pf.Close()
+	if pf.NumPages() != 4 {
+		t.Fatalf("expected 4 pages, got %d", pf.NumPages())
+	}
+}
+
+func TestPageFile_ExclusiveLocksAgainstOtherOpens(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "plan9" {
+		t.Skip("flock does not exist on " + runtime.GOOS)
+	}
+	path := t.TempDir() + "/pages.db"
+	pf, err := NewPageFileWithOptions(path, nil, PageFileOptions{Exclusive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another process is another open of the file, without the registry lock.
+	other, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if err := flockExclusive(other); !errors.Is(err, ErrLocked) {
+		t.Fatalf("flock from another open: expected ErrLocked, got %v", err)
+	}
+
+	// In the same process the second open succeeds (reopening after a
+	// simulated crash), and read-only ignores the lock.
+	again, err := NewPageFileWithOptions(path, nil, PageFileOptions{Exclusive: true})
+	if err != nil {
+		t.Fatalf("second open in the same process: %v", err)
+	}
+	again.Close()
+	ro, err := NewPageFileWithOptions(path, nil, PageFileOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("read-only: %v", err)
+	}
+	ro.Close()
+
+	if err := pf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := flockExclusive(other); err != nil {
+		t.Fatalf("flock after Close: %v", err)
+	}
+}
+
+func TestPageFile_InMemory(t *testing.T) {
+	dir := TempMemDir()
+	path := dir + "/pages.db"
+	pf, err := NewPageFile(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p Page
+	fillBody(&p, 9, 64)
+	id, _ := pf.AllocatePage()
+	if err := pf.WritePage(id, &p); err != nil {
+		t.Fatal(err)
+	}
+	if err := pf.EnableMmap(); !errors.Is(err, ErrMmapUnsupported) {
+		t.Fatalf("EnableMmap: expected ErrMmapUnsupported, got %v", err)
+	}
+	if err := pf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("in-memory file reached the disk: %v", err)
+	}
+
+	// The content survives Close, like a file, until RemoveMemAll.
+	ro, err := NewPageFileWithOptions(path, nil, PageFileOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ro.ReadPage(id)
+	if err != nil || !bytes.Equal(got.Body(), p.Body()) {
+		t.Fatalf("ReadPage after reopen: %v", err)
+	}
+	ro.Close()
+
+	RemoveMemAll(dir)
+	if _, err := NewPageFileWithOptions(path, nil, PageFileOptions{ReadOnly: true}); !os.IsNotExist(err) {
+		t.Fatalf("open after RemoveMemAll: expected not exist, got %v", err)
+	}
+}
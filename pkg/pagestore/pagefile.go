@@ -30,7 +30,7 @@ var (
 // page cache do SO). Cache é responsabilidade do BufferPool (Fase 2).
 type PageFile struct {
 	path   string
-	file   *os.File
+	file   backingFile
 	cipher *PageCipher
 
 	// nextID é incrementado atomicamente por Allocate.
@@ -42,7 +42,28 @@ type PageFile struct {
 	// not precisam de lock.
 	syncMu sync.Mutex
 
+	// unsynced marks writes not yet covered by a Sync. Lets checkpoints
+	// skip the fsync of files they did not touch.
+	unsynced atomic.Bool
+
 	closed atomic.Bool
+
+	readOnly bool
+
+	// lockKey is the Exclusive lock this PageFile took, "" if none.
+	lockKey string
+
+	// prealloc is the block size reserved by preallocate; reserved is
+	// how far the file already has space reserved. Guarded by
+	// preallocMu.
+	preallocMu sync.Mutex
+	prealloc   int64
+	reserved   int64
+
+	// mmapMu guards mapping; see EnableMmap.
+	mmapMu      sync.RWMutex
+	mmapEnabled bool
+	mapping     []byte
 }
 
 // NewPageFile abre ou cria um page file em `path`. Passe nil para
@@ -52,11 +73,25 @@ type PageFile struct {
 // diretório pai — sem isso a criação pode ser "esquecida" pelo FS em
 // caso de crash mesmo after a função retornar.
 func NewPageFile(path string, cipher crypto.Cipher) (*PageFile, error) {
+	return NewPageFileWithOptions(path, cipher, PageFileOptions{})
+}
+
+// NewPageFileWithOptions is NewPageFile with read-only mode, an exclusive
+// lock and preallocation.
+func NewPageFileWithOptions(path string, cipher crypto.Cipher, opts PageFileOptions) (*PageFile, error) {
+	if IsMemPath(path) {
+		return openMemPageFile(path, cipher, opts)
+	}
+
 	// Detecta se vamos criar o arquivo pela primeira vez
 	_, statErr := os.Stat(path)
-	creating := os.IsNotExist(statErr)
+	creating := os.IsNotExist(statErr) && !opts.ReadOnly
 
-	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	flag := os.O_RDWR | os.O_CREATE
+	if opts.ReadOnly {
+		flag = os.O_RDONLY
+	}
+	f, err := os.OpenFile(path, flag, 0644)
 	if err != nil {
 		return nil, err
 	}
@@ -81,22 +116,42 @@ func NewPageFile(path string, cipher crypto.Cipher) (*PageFile, error) {
 		}
 	}
 
+	var lockKey string
+	if opts.Exclusive {
+		if lockKey, err = lockExclusive(f, path); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	pf := newPageFile(path, f, cipher, opts, stat.Size())
+	pf.lockKey = lockKey
+	return pf, nil
+}
+
+// newPageFile builds the PageFile on top of f, which already has size bytes.
+func newPageFile(path string, f backingFile, cipher crypto.Cipher, opts PageFileOptions, size int64) *PageFile {
 	pf := &PageFile{
-		path:   path,
-		file:   f,
-		cipher: NewPageCipher(cipher),
+		path:     path,
+		file:     f,
+		cipher:   NewPageCipher(cipher),
+		readOnly: opts.ReadOnly,
+		prealloc: opts.PreallocateBytes,
+		reserved: size,
 	}
+	// Conservative: whatever an earlier process left in the page cache is
+	// covered by the first Sync.
+	pf.unsynced.Store(true)
 
 	// PageID 0 é reservado (InvalidPageID). O próximo a alocar é o que
 	// corresponde ao fim do arquivo (ou 1 se estiver empty).
-	n := uint64(stat.Size() / PageSize)
+	n := uint64(size / PageSize)
 	if n == 0 {
 		n = 1 // reserva o slot 0
 	}
 	pf.nextID.Store(n)
 	pf.numPages.Store(n)
-
-	return pf, nil
+	return pf
 }
 
 // AllocatePage reserva um novo pageID. Not grava nada em disco — a
@@ -105,6 +160,9 @@ func (pf *PageFile) AllocatePage() (PageID, error) {
 	if pf.closed.Load() {
 		return InvalidPageID, ErrClosed
 	}
+	if pf.readOnly {
+		return InvalidPageID, ErrReadOnly
+	}
 	id := PageID(pf.nextID.Add(1) - 1)
 	return id, nil
 }
@@ -119,9 +177,42 @@ func (pf *PageFile) NumPages() uint64 { return pf.numPages.Load() }
 // esse limite para not perder bytes na cifragem.
 func (pf *PageFile) UsableBodySize() int { return pf.cipher.UsableBodySize() }
 
+// Refresh rereads the file size so a reader sees the pages written through
+// another handle (e.g. the WAL writer). It only grows NumPages.
+func (pf *PageFile) Refresh() (uint64, error) {
+	if pf.closed.Load() {
+		return 0, ErrClosed
+	}
+	stat, err := pf.file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	n := uint64(stat.Size() / PageSize)
+	for {
+		cur := pf.numPages.Load()
+		if n <= cur || pf.numPages.CompareAndSwap(cur, n) {
+			break
+		}
+	}
+	return pf.numPages.Load(), nil
+}
+
+// Stat returns the FileInfo of the open file, which stays the same even
+// if the path is renamed.
+func (pf *PageFile) Stat() (os.FileInfo, error) {
+	if pf.closed.Load() {
+		return nil, ErrClosed
+	}
+	return pf.file.Stat()
+}
+
 // Path devolve o caminho do arquivo.
 func (pf *PageFile) Path() string { return pf.path }
 
+// Cipher returns the cipher the file was opened with (NoOpCipher without
+// TDE), so the file can be recreated with the same encryption.
+func (pf *PageFile) Cipher() crypto.Cipher { return pf.cipher.inner }
+
 // WritePage grava a page `p` no offset correspondente a `pageID`.
 // O header é escrito em claro (com Magic, Version, PageID e Checksum
 // recalculados). O body é cifrado se TDE estiver ligado.
@@ -129,6 +220,9 @@ func (pf *PageFile) WritePage(pageID PageID, p *Page) error {
 	if pf.closed.Load() {
 		return ErrClosed
 	}
+	if pf.readOnly {
+		return ErrReadOnly
+	}
 	if pageID == InvalidPageID {
 		return fmt.Errorf("pagestore: pageID 0 is reserved")
 	}
@@ -165,7 +259,14 @@ func (pf *PageFile) WritePage(pageID PageID, p *Page) error {
 	hdr.Encode(disk[:HeaderSize])
 
 	offset := int64(pageID) * PageSize
-	if _, err := pf.file.WriteAt(disk[:], offset); err != nil {
+	if err := pf.reserve(offset + PageSize); err != nil {
+		return err
+	}
+	_, err := pf.file.WriteAt(disk[:], offset)
+	// Set after the write so a concurrent Sync either covers it or leaves
+	// the flag for the next one.
+	pf.unsynced.Store(true)
+	if err != nil {
 		return err
 	}
 
@@ -196,9 +297,15 @@ func (pf *PageFile) ReadPage(pageID PageID) (*Page, error) {
 
 	var page Page
 	offset := int64(pageID) * PageSize
-	if _, err := pf.file.ReadAt(page[:], offset); err != nil {
+	mapped, err := pf.readMapped(&page, offset)
+	if err != nil {
 		return nil, err
 	}
+	if !mapped {
+		if _, err := pf.file.ReadAt(page[:], offset); err != nil {
+			return nil, err
+		}
+	}
 
 	var hdr PageHeader
 	if err := hdr.Decode(page.HeaderBytes()); err != nil {
@@ -231,14 +338,90 @@ func (pf *PageFile) ReadPage(pageID PageID) (*Page, error) {
 	return &page, nil
 }
 
-// Sync força fsync no arquivo.
+// reserve ensures preallocated space up to end, in blocks of prealloc.
+func (pf *PageFile) reserve(end int64) error {
+	if pf.prealloc <= 0 {
+		return nil
+	}
+	pf.preallocMu.Lock()
+	defer pf.preallocMu.Unlock()
+	if end <= pf.reserved {
+		return nil
+	}
+	length := pf.prealloc
+	if end-pf.reserved > length {
+		length = end - pf.reserved
+	}
+	f, ok := pf.file.(*os.File)
+	if !ok {
+		return nil
+	}
+	if err := preallocate(f, pf.reserved, length); err != nil {
+		return fmt.Errorf("pagestore: preallocate: %w", err)
+	}
+	pf.reserved += length
+	return nil
+}
+
+// Truncate drops the pages from numPages on (slot 0 counts), e.g. the
+// torn tail of a WAL. Pages allocated but not yet written past that point
+// are forgotten too.
+func (pf *PageFile) Truncate(numPages uint64) error {
+	if pf.closed.Load() {
+		return ErrClosed
+	}
+	if pf.readOnly {
+		return ErrReadOnly
+	}
+	if numPages < 1 {
+		numPages = 1
+	}
+	if err := pf.file.Truncate(int64(numPages) * PageSize); err != nil {
+		return err
+	}
+	pf.unsynced.Store(true)
+	pf.numPages.Store(numPages)
+	pf.nextID.Store(numPages)
+	pf.mmapMu.Lock()
+	defer pf.mmapMu.Unlock()
+	if pf.mmapEnabled {
+		return pf.remapLocked()
+	}
+	return nil
+}
+
+// ReadOnly reports whether the file was opened for reading only.
+func (pf *PageFile) ReadOnly() bool { return pf.readOnly }
+
+// Sync fsyncs the file. Without writes since the last successful
+// Sync there is nothing to persist and the fsync is skipped, so flushing a
+// clean tree or heap costs no I/O.
 func (pf *PageFile) Sync() error {
 	if pf.closed.Load() {
 		return ErrClosed
 	}
+	if pf.readOnly {
+		return nil
+	}
 	pf.syncMu.Lock()
 	defer pf.syncMu.Unlock()
-	return syncFile(pf.file)
+	if !pf.unsynced.Swap(false) {
+		return nil
+	}
+	if err := pf.syncBacking(); err != nil {
+		pf.unsynced.Store(true)
+		return err
+	}
+	return nil
+}
+
+// syncBacking fsyncs the file; an in-memory file has nothing to
+// persist.
+func (pf *PageFile) syncBacking() error {
+	if f, ok := pf.file.(*os.File); ok {
+		return syncFile(f)
+	}
+	return nil
 }
 
 // Close fecha o arquivo. Operações subsequentes fail com ErrClosed.
@@ -253,10 +436,18 @@ func (pf *PageFile) Close() error {
 	}
 	// Tenta fsync — se fail (ex: disk full), ainda tentamos fechar
 	// pra not vazar descritor, mas propagamos o erro do fsync.
-	syncErr := syncFile(pf.file)
+	var syncErr error
+	if !pf.readOnly {
+		syncErr = pf.syncBacking()
+	}
+	unmapErr := pf.unmap()
 	closeErr := pf.file.Close()
+	unlockExclusive(pf.lockKey)
 	if syncErr != nil {
 		return syncErr
 	}
+	if unmapErr != nil {
+		return unmapErr
+	}
 	return closeErr
 }
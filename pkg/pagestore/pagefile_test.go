@@ -283,6 +283,56 @@ func TestCloseIsIdempotent(t *testing.T) {
 	}
 }
 
+func TestSync_SkipsCleanFile(t *testing.T) {
+	pf, _ := openTemp(t, nil)
+	defer pf.Close()
+
+	realSyncFile := syncFile
+	var calls int
+	failNext := false
+	syncFile = func(f *os.File) error {
+		calls++
+		if failNext {
+			failNext = false
+			return errors.New("injected fsync failure")
+		}
+		return realSyncFile(f)
+	}
+	defer func() { syncFile = realSyncFile }()
+
+	mustSync := func(wantCalls int) {
+		t.Helper()
+		if err := pf.Sync(); err != nil {
+			t.Fatal(err)
+		}
+		if calls != wantCalls {
+			t.Fatalf("expected %d fsyncs, got %d", wantCalls, calls)
+		}
+	}
+
+	mustSync(1) // first Sync after open always fsyncs
+	mustSync(1) // nothing written since
+
+	id, _ := pf.AllocatePage()
+	var p Page
+	fillBody(&p, 7, 64)
+	if err := pf.WritePage(id, &p); err != nil {
+		t.Fatal(err)
+	}
+	mustSync(2)
+	mustSync(2)
+
+	// A failed fsync keeps the writes pending for the next Sync.
+	if err := pf.WritePage(id, &p); err != nil {
+		t.Fatal(err)
+	}
+	failNext = true
+	if err := pf.Sync(); err == nil {
+		t.Fatal("expected injected fsync failure")
+	}
+	mustSync(4)
+}
+
 func TestConcurrentReadsAndWrites(t *testing.T) {
 	// Roda com `go test -race` para detectar data races.
 	pf, _ := openTemp(t, newCipher(t))
@@ -0,0 +1,20 @@
+//go:build linux
+
+package pagestore
+
+import (
+	"os"
+	"syscall"
+)
+
+// fallocFlKeepSize (FALLOC_FL_KEEP_SIZE) reserves blocks without changing
+// the file size.
+const fallocFlKeepSize = 0x01
+
+func preallocate(f *os.File, offset, length int64) error {
+	err := syscall.Fallocate(int(f.Fd()), fallocFlKeepSize, offset, length)
+	if err == syscall.EOPNOTSUPP || err == syscall.ENOSYS {
+		return nil
+	}
+	return err
+}
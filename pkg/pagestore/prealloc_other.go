@@ -0,0 +1,9 @@
+//go:build !linux
+
+package pagestore
+
+import "os"
+
+func preallocate(*os.File, int64, int64) error {
+	return nil
+}
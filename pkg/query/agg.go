@@ -0,0 +1,209 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// AggFunc is an aggregate function.
+type AggFunc int
+
+const (
+	AggCount AggFunc = iota // COUNT
+	AggMin                  // MIN
+	AggMax                  // MAX
+	AggSum                  // SUM
+	AggAvg                  // AVG
+)
+
+func (f AggFunc) String() string {
+	switch f {
+	case AggCount:
+		return "COUNT"
+	case AggMin:
+		return "MIN"
+	case AggMax:
+		return "MAX"
+	case AggSum:
+		return "SUM"
+	case AggAvg:
+		return "AVG"
+	default:
+		return fmt.Sprintf("AggFunc(%d)", int(f))
+	}
+}
+
+// AggSpec describes an aggregate. Without Field it aggregates the index
+// key; with Field it aggregates that document field (rows without the
+// field are skipped, like NULL in SQL).
+type AggSpec struct {
+	Func  AggFunc
+	Field string
+}
+
+// Ready-made aggregates over the index key.
+func Count() AggSpec { return AggSpec{Func: AggCount} }
+func Min() AggSpec   { return AggSpec{Func: AggMin} }
+func Max() AggSpec   { return AggSpec{Func: AggMax} }
+func Sum() AggSpec   { return AggSpec{Func: AggSum} }
+func Avg() AggSpec   { return AggSpec{Func: AggAvg} }
+
+// OfField returns the same aggregate over the document field `name`.
+func (s AggSpec) OfField(name string) AggSpec {
+	s.Field = name
+	return s
+}
+
+// AggResult is the result of an aggregate.
+//
+// Value is nil when no row was aggregated (except COUNT, which is
+// IntKey(0)). SUM of IntKeys is an IntKey; with any FloatKey, a FloatKey.
+// AVG is always a FloatKey.
+type AggResult struct {
+	Value types.Comparable
+	Count int
+}
+
+// Aggregator accumulates the values of an AggSpec.
+type Aggregator struct {
+	spec    AggSpec
+	count   int
+	best    types.Comparable
+	intSum  int64
+	fSum    float64
+	isFloat bool
+}
+
+func NewAggregator(spec AggSpec) *Aggregator {
+	return &Aggregator{spec: spec}
+}
+
+// Add accumulates a value. SUM and AVG only accept IntKey and FloatKey. As
+// in SQL, MIN, MAX, SUM and AVG ignore NULL (types.NullKey).
+func (a *Aggregator) Add(value types.Comparable) error {
+	if _, null := value.(types.NullKey); null && a.spec.Func != AggCount {
+		return nil
+	}
+	switch a.spec.Func {
+	case AggCount:
+	case AggMin, AggMax:
+		if a.best != nil {
+			c, ok := compareValues(value, a.best)
+			if !ok {
+				return fmt.Errorf("query: %s over mixed types %T and %T", a.spec.Func, a.best, value)
+			}
+			if (a.spec.Func == AggMin && c >= 0) || (a.spec.Func == AggMax && c <= 0) {
+				a.count++
+				return nil
+			}
+		}
+		a.best = value
+	case AggSum, AggAvg:
+		switch v := value.(type) {
+		case types.IntKey:
+			a.intSum += int64(v)
+			a.fSum += float64(v)
+		case types.FloatKey:
+			a.fSum += float64(v)
+			a.isFloat = true
+		default:
+			return fmt.Errorf("query: %s of non-numeric value %T", a.spec.Func, value)
+		}
+	default:
+		return fmt.Errorf("query: unknown aggregate %s", a.spec.Func)
+	}
+	a.count++
+	return nil
+}
+
+// Result returns the value aggregated so far.
+func (a *Aggregator) Result() AggResult {
+	result := AggResult{Count: a.count}
+	switch a.spec.Func {
+	case AggCount:
+		result.Value = types.IntKey(a.count)
+	case AggMin, AggMax:
+		result.Value = a.best
+	case AggSum:
+		if a.count > 0 {
+			if a.isFloat {
+				result.Value = types.FloatKey(a.fSum)
+			} else {
+				result.Value = types.IntKey(a.intSum)
+			}
+		}
+	case AggAvg:
+		if a.count > 0 {
+			result.Value = types.FloatKey(a.fSum / float64(a.count))
+		}
+	}
+	return result
+}
+
+// Group is one bucket of a GROUP BY: the value of the grouping field (nil
+// for rows without the field) and one result per AggSpec, in order.
+type Group struct {
+	Key     types.Comparable
+	Results []AggResult
+}
+
+// GroupAggregator accumulates AggSpecs separately per group value.
+type GroupAggregator struct {
+	specs  []AggSpec
+	groups map[types.Comparable][]*Aggregator
+}
+
+func NewGroupAggregator(specs ...AggSpec) *GroupAggregator {
+	return &GroupAggregator{specs: specs, groups: make(map[types.Comparable][]*Aggregator)}
+}
+
+// Add accumulates a row into the group groupKey. values holds one value
+// per AggSpec; nil leaves the row out of that aggregate.
+func (g *GroupAggregator) Add(groupKey types.Comparable, values []types.Comparable) error {
+	if len(values) != len(g.specs) {
+		return fmt.Errorf("query: %d values for %d aggregates", len(values), len(g.specs))
+	}
+	aggs, ok := g.groups[groupKey]
+	if !ok {
+		aggs = make([]*Aggregator, len(g.specs))
+		for i, spec := range g.specs {
+			aggs[i] = NewAggregator(spec)
+		}
+		g.groups[groupKey] = aggs
+	}
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		if err := aggs[i].Add(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Groups returns the groups ordered by group value; the nil group comes
+// first and values of different types are ordered by type name.
+func (g *GroupAggregator) Groups() []Group {
+	groups := make([]Group, 0, len(g.groups))
+	for key, aggs := range g.groups {
+		results := make([]AggResult, len(aggs))
+		for i, agg := range aggs {
+			results[i] = agg.Result()
+		}
+		groups = append(groups, Group{Key: key, Results: results})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := groups[i].Key, groups[j].Key
+		if a == nil || b == nil {
+			return a == nil && b != nil
+		}
+		if c, ok := compareValues(a, b); ok {
+			return c < 0
+		}
+		return fmt.Sprintf("%T", a) < fmt.Sprintf("%T", b)
+	})
+	return groups
+}
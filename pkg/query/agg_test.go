@@ -0,0 +1,99 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+func TestAggregator(t *testing.T) {
+	values := []types.Comparable{types.IntKey(4), types.IntKey(-2), types.IntKey(10)}
+	cases := []struct {
+		spec query.AggSpec
+		want types.Comparable
+	}{
+		{query.Count(), types.IntKey(3)},
+		{query.Min(), types.IntKey(-2)},
+		{query.Max(), types.IntKey(10)},
+		{query.Sum(), types.IntKey(12)},
+		{query.Avg(), types.FloatKey(4)},
+	}
+	for _, tc := range cases {
+		agg := query.NewAggregator(tc.spec)
+		for _, v := range values {
+			if err := agg.Add(v); err != nil {
+				t.Fatalf("%s: Add: %v", tc.spec.Func, err)
+			}
+		}
+		got := agg.Result()
+		if got.Value != tc.want || got.Count != len(values) {
+			t.Fatalf("%s: expected %v over %d rows, got %v over %d", tc.spec.Func, tc.want, len(values), got.Value, got.Count)
+		}
+	}
+}
+
+func TestAggregator_MixedAndEmpty(t *testing.T) {
+	agg := query.NewAggregator(query.Sum())
+	agg.Add(types.IntKey(1))
+	agg.Add(types.FloatKey(0.5))
+	if got := agg.Result().Value; got != types.FloatKey(1.5) {
+		t.Fatalf("expected FloatKey(1.5), got %v", got)
+	}
+	if err := agg.Add(types.VarcharKey("x")); err == nil {
+		t.Fatal("expected SUM of a varchar to fail")
+	}
+	if err := agg.Add(types.NullKey{}); err != nil || agg.Result().Count != 2 {
+		t.Fatalf("expected SUM to skip NULL, got count %d err %v", agg.Result().Count, err)
+	}
+
+	if got := query.NewAggregator(query.Max()).Result(); got.Value != nil || got.Count != 0 {
+		t.Fatalf("expected empty MAX to be nil, got %+v", got)
+	}
+	if got := query.NewAggregator(query.Count()).Result(); got.Value != types.IntKey(0) {
+		t.Fatalf("expected empty COUNT to be 0, got %v", got.Value)
+	}
+}
+
+func TestGroupAggregator(t *testing.T) {
+	g := query.NewGroupAggregator(query.Count(), query.Sum())
+	rows := []struct {
+		group types.Comparable
+		value types.Comparable
+	}{
+		{types.VarcharKey("b"), types.IntKey(1)},
+		{types.VarcharKey("a"), types.IntKey(2)},
+		{nil, types.IntKey(3)},
+		{types.VarcharKey("b"), nil},
+	}
+	for _, r := range rows {
+		if err := g.Add(r.group, []types.Comparable{types.IntKey(0), r.value}); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	groups := g.Groups()
+	want := []struct {
+		key   types.Comparable
+		count types.Comparable
+		sum   types.Comparable
+	}{
+		{nil, types.IntKey(1), types.IntKey(3)},
+		{types.VarcharKey("a"), types.IntKey(1), types.IntKey(2)},
+		{types.VarcharKey("b"), types.IntKey(2), types.IntKey(1)},
+	}
+	if len(groups) != len(want) {
+		t.Fatalf("expected %d groups, got %d", len(want), len(groups))
+	}
+	for i, w := range want {
+		got := groups[i]
+		if got.Key != w.key || got.Results[0].Value != w.count || got.Results[1].Value != w.sum {
+			t.Fatalf("group %d: expected %v count=%v sum=%v, got %v count=%v sum=%v",
+				i, w.key, w.count, w.sum, got.Key, got.Results[0].Value, got.Results[1].Value)
+		}
+	}
+
+	if err := g.Add(nil, nil); err == nil {
+		t.Fatal("expected an error when values do not match the specs")
+	}
+}
@@ -1,6 +1,9 @@
 package query
 
 import (
+	"reflect"
+	"sort"
+
 	"github.com/bobboyms/storage-engine/pkg/types"
 )
 
@@ -15,15 +18,32 @@ const (
 	OpLessThan                           // <
 	OpLessOrEqual                        // <=
 	OpBetween                            // BETWEEN x AND y
+	OpAnd                                // every one of Conditions
+	OpOr                                 // any of Conditions
+	OpNot                                // negation of Conditions[0]
+	OpIn                                 // IN (Values...)
+	OpIsNull                             // IS NULL
+	OpIsNotNull                          // IS NOT NULL
 )
 
 // Condição de scan
 type ScanCondition struct {
 	Operator ScanOperator
-	Value    types.Comparable // Para operadores unários (=, !=, >, <, >=, <=)
-	ValueEnd types.Comparable // Para BETWEEN (range)
+	Value    types.Comparable   // For the unary operators (=, !=, >, <, >=, <=)
+	ValueEnd types.Comparable   // For BETWEEN (range)
+	Values   []types.Comparable // For IN
+
+	// Field, when set, tests that document field instead of the index
+	// key (a post-filter applied after the heap read).
+	Field string
+
+	// Conditions are the operands of OpAnd, OpOr and OpNot.
+	Conditions []*ScanCondition
 }
 
+// FieldLookup returns the value of a top-level field of the document.
+type FieldLookup func(name string) (types.Comparable, bool)
+
 // Construtores convenientes
 func Equal(value types.Comparable) *ScanCondition {
 	return &ScanCondition{Operator: OpEqual, Value: value}
@@ -53,32 +73,277 @@ func Between(start, end types.Comparable) *ScanCondition {
 	return &ScanCondition{Operator: OpBetween, Value: start, ValueEnd: end}
 }
 
-// Matches verifica se uma key satisfaz a condição
+// In matches keys equal to any of the values. On an index it becomes one
+// seek per value, in order.
+func In(values ...types.Comparable) *ScanCondition {
+	return &ScanCondition{Operator: OpIn, Values: values}
+}
+
+// IsNull matches NULL keys (types.NullKey) of a nullable index. With Field
+// it also matches documents without the field.
+func IsNull() *ScanCondition {
+	return &ScanCondition{Operator: OpIsNull, Value: types.NullKey{}}
+}
+
+// IsNotNull matches any key that is not NULL.
+func IsNotNull() *ScanCondition {
+	return &ScanCondition{Operator: OpIsNotNull}
+}
+
+// And matches when every condition matches.
+func And(conditions ...*ScanCondition) *ScanCondition {
+	return &ScanCondition{Operator: OpAnd, Conditions: conditions}
+}
+
+// Or matches when any condition matches.
+func Or(conditions ...*ScanCondition) *ScanCondition {
+	return &ScanCondition{Operator: OpOr, Conditions: conditions}
+}
+
+// Not negates the condition.
+func Not(condition *ScanCondition) *ScanCondition {
+	return &ScanCondition{Operator: OpNot, Conditions: []*ScanCondition{condition}}
+}
+
+// Field applies the condition to the document field `name` instead of the
+// index key, e.g. Field("salary", GreaterThan(types.IntKey(80000))). A
+// document without the field does not match.
+func Field(name string, condition *ScanCondition) *ScanCondition {
+	c := *condition
+	if len(c.Conditions) == 0 {
+		c.Field = name
+		return &c
+	}
+	c.Conditions = make([]*ScanCondition, len(condition.Conditions))
+	for i, child := range condition.Conditions {
+		c.Conditions[i] = Field(name, child)
+	}
+	return &c
+}
+
+// Matches reports whether a key satisfies the condition. Field conditions
+// are not decided by the key alone: Matches returns false only when no
+// document with that key can match.
 func (sc *ScanCondition) Matches(key types.Comparable) bool {
+	return sc.eval(key, nil) != truthFalse
+}
+
+// MatchesDocument evaluates the whole condition, reading field conditions
+// through lookup.
+func (sc *ScanCondition) MatchesDocument(key types.Comparable, lookup FieldLookup) bool {
+	return sc.eval(key, lookup) == truthTrue
+}
+
+// NeedsDocument reports whether the condition tests document fields.
+func (sc *ScanCondition) NeedsDocument() bool {
+	if sc.Field != "" {
+		return true
+	}
+	for _, child := range sc.Conditions {
+		if child.NeedsDocument() {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyRange returns the range [start, end] of index keys outside which the
+// condition never matches, when there is one.
+func (sc *ScanCondition) KeyRange() (start, end types.Comparable, ok bool) {
+	if sc.Field != "" {
+		return nil, nil, false
+	}
+	switch sc.Operator {
+	case OpEqual, OpIsNull:
+		return sc.Value, sc.Value, true
+	case OpBetween:
+		return sc.Value, sc.ValueEnd, true
+	case OpAnd:
+		for _, child := range sc.Conditions {
+			if start, end, ok := child.KeyRange(); ok {
+				return start, end, true
+			}
+		}
+	}
+	return nil, nil, false
+}
+
+// KeyPoints returns, in ascending order and without repeats, the only
+// index keys that can match, when the condition reduces to a finite set
+// (= or IN, directly or inside an AND).
+func (sc *ScanCondition) KeyPoints() ([]types.Comparable, bool) {
+	if sc.Field != "" {
+		return nil, false
+	}
+	switch sc.Operator {
+	case OpEqual, OpIsNull:
+		return []types.Comparable{sc.Value}, true
+	case OpIn:
+		points := make([]types.Comparable, 0, len(sc.Values))
+		for _, v := range sc.Values {
+			if v != nil {
+				points = append(points, v)
+			}
+		}
+		sort.SliceStable(points, func(i, j int) bool {
+			c, _ := compareValues(points[i], points[j])
+			return c < 0
+		})
+		unique := points[:0]
+		for _, v := range points {
+			if len(unique) > 0 {
+				if c, ok := compareValues(unique[len(unique)-1], v); ok && c == 0 {
+					continue
+				}
+			}
+			unique = append(unique, v)
+		}
+		return unique, true
+	case OpAnd:
+		for _, child := range sc.Conditions {
+			if points, ok := child.KeyPoints(); ok {
+				return points, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// truth is the three-valued logic used while document fields are not
+// known yet.
+type truth uint8
+
+const (
+	truthFalse truth = iota
+	truthTrue
+	truthUnknown
+)
+
+func (sc *ScanCondition) eval(key types.Comparable, lookup FieldLookup) truth {
+	switch sc.Operator {
+	case OpAnd:
+		result := truthTrue
+		for _, child := range sc.Conditions {
+			switch child.eval(key, lookup) {
+			case truthFalse:
+				return truthFalse
+			case truthUnknown:
+				result = truthUnknown
+			}
+		}
+		return result
+	case OpOr:
+		result := truthFalse
+		for _, child := range sc.Conditions {
+			switch child.eval(key, lookup) {
+			case truthTrue:
+				return truthTrue
+			case truthUnknown:
+				result = truthUnknown
+			}
+		}
+		return result
+	case OpNot:
+		if len(sc.Conditions) == 0 {
+			return truthFalse
+		}
+		switch sc.Conditions[0].eval(key, lookup) {
+		case truthTrue:
+			return truthFalse
+		case truthFalse:
+			return truthTrue
+		}
+		return truthUnknown
+	}
+
+	value := key
+	if sc.Field != "" {
+		if lookup == nil {
+			return truthUnknown
+		}
+		v, ok := lookup(sc.Field)
+		if !ok {
+			if sc.Operator == OpIsNull {
+				return truthTrue
+			}
+			return truthFalse
+		}
+		value = v
+	}
+	if sc.matchValue(value) {
+		return truthTrue
+	}
+	return truthFalse
+}
+
+func (sc *ScanCondition) matchValue(value types.Comparable) bool {
+	_, null := value.(types.NullKey)
+	switch sc.Operator {
+	case OpIsNull:
+		return null
+	case OpIsNotNull:
+		return value != nil && !null
+	}
+	if sc.Operator == OpIn {
+		for _, v := range sc.Values {
+			if c, ok := compareValues(value, v); ok && c == 0 {
+				return true
+			}
+		}
+		return false
+	}
+	c, ok := compareValues(value, sc.Value)
+	if !ok {
+		return false
+	}
 	switch sc.Operator {
 	case OpEqual:
-		return key.Compare(sc.Value) == 0
+		return c == 0
 	case OpNotEqual:
-		return key.Compare(sc.Value) != 0
+		return c != 0
 	case OpGreaterThan:
-		return key.Compare(sc.Value) > 0
+		return c > 0
 	case OpGreaterOrEqual:
-		return key.Compare(sc.Value) >= 0
+		return c >= 0
 	case OpLessThan:
-		return key.Compare(sc.Value) < 0
+		return c < 0
 	case OpLessOrEqual:
-		return key.Compare(sc.Value) <= 0
+		return c <= 0
 	case OpBetween:
-		return key.Compare(sc.Value) >= 0 && key.Compare(sc.ValueEnd) <= 0
+		cEnd, ok := compareValues(value, sc.ValueEnd)
+		return ok && c >= 0 && cEnd <= 0
 	default:
 		return false
 	}
 }
 
+// compareValues compares keys of the same type; IntKey and FloatKey
+// compare as numbers. Different types are not comparable, and NULL only
+// matches IsNull/IsNotNull: NullKey is left out of every comparison.
+func compareValues(a, b types.Comparable) (int, bool) {
+	switch x := a.(type) {
+	case types.IntKey:
+		if y, ok := b.(types.FloatKey); ok {
+			return types.FloatKey(x).Compare(y), true
+		}
+	case types.FloatKey:
+		if y, ok := b.(types.IntKey); ok {
+			return x.Compare(types.FloatKey(y)), true
+		}
+	}
+	if a == nil || b == nil || reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return 0, false
+	}
+	if _, null := a.(types.NullKey); null {
+		return 0, false
+	}
+	return a.Compare(b), true
+}
+
 // GetStartKey retorna a key inicial para otimizar o scan
 func (sc *ScanCondition) GetStartKey() types.Comparable {
 	switch sc.Operator {
-	case OpEqual, OpGreaterThan, OpGreaterOrEqual, OpBetween:
+	case OpEqual, OpGreaterThan, OpGreaterOrEqual, OpBetween, OpIsNull:
 		return sc.Value
 	default:
 		return nil // Full scan necessário
@@ -88,7 +353,7 @@ func (sc *ScanCondition) GetStartKey() types.Comparable {
 // ShouldSeek indica se podemos usar Seek() para otimizar
 func (sc *ScanCondition) ShouldSeek() bool {
 	switch sc.Operator {
-	case OpEqual, OpGreaterThan, OpGreaterOrEqual, OpBetween:
+	case OpEqual, OpGreaterThan, OpGreaterOrEqual, OpBetween, OpIsNull:
 		return true
 	default:
 		return false // Operadores como != e < requerem full scan
@@ -98,7 +363,7 @@ func (sc *ScanCondition) ShouldSeek() bool {
 // ShouldContinue indica se mustmos continuar o scan after encontrar uma key
 func (sc *ScanCondition) ShouldContinue(key types.Comparable) bool {
 	switch sc.Operator {
-	case OpEqual:
+	case OpEqual, OpIsNull:
 		// Para =, paramos after encontrar a key (ou quando ultrapassar)
 		return key.Compare(sc.Value) <= 0
 	case OpLessThan, OpLessOrEqual:
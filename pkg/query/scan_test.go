@@ -436,3 +436,137 @@ func TestBetween_WithVarchar(t *testing.T) {
 		t.Error("Expected 'date' to not match (out of range)")
 	}
 }
+
+// =============================================
+// TESTS FOR COMPOUND CONDITIONS (AND/OR/NOT)
+// =============================================
+
+func lookupOf(fields map[string]types.Comparable) query.FieldLookup {
+	return func(name string) (types.Comparable, bool) {
+		v, ok := fields[name]
+		return v, ok
+	}
+}
+
+func TestComposite_KeyOnly(t *testing.T) {
+	cond := query.Or(
+		query.LessThan(types.IntKey(10)),
+		query.And(query.GreaterThan(types.IntKey(20)), query.Not(query.Equal(types.IntKey(25)))),
+	)
+
+	for key, want := range map[int]bool{5: true, 10: false, 15: false, 21: true, 25: false, 30: true} {
+		if got := cond.Matches(types.IntKey(key)); got != want {
+			t.Errorf("Matches(%d) = %v, expected %v", key, got, want)
+		}
+	}
+}
+
+func TestComposite_FieldConditions(t *testing.T) {
+	cond := query.And(
+		query.Equal(types.VarcharKey("Eng")),
+		query.Field("salary", query.GreaterThan(types.IntKey(80000))),
+	)
+	if !cond.NeedsDocument() {
+		t.Fatal("expected NeedsDocument for a field condition")
+	}
+
+	// The key alone can only rule rows out.
+	if cond.Matches(types.VarcharKey("Sales")) {
+		t.Error("expected key 'Sales' to be ruled out")
+	}
+	if !cond.Matches(types.VarcharKey("Eng")) {
+		t.Error("expected key 'Eng' to remain a candidate")
+	}
+
+	key := types.VarcharKey("Eng")
+	if !cond.MatchesDocument(key, lookupOf(map[string]types.Comparable{"salary": types.IntKey(90000)})) {
+		t.Error("expected salary 90000 to match")
+	}
+	if cond.MatchesDocument(key, lookupOf(map[string]types.Comparable{"salary": types.IntKey(70000)})) {
+		t.Error("expected salary 70000 to not match")
+	}
+	if !cond.MatchesDocument(key, lookupOf(map[string]types.Comparable{"salary": types.FloatKey(80000.5)})) {
+		t.Error("expected float salary to compare numerically")
+	}
+	if cond.MatchesDocument(key, lookupOf(nil)) {
+		t.Error("expected a document without salary to not match")
+	}
+	if cond.MatchesDocument(key, lookupOf(map[string]types.Comparable{"salary": types.VarcharKey("high")})) {
+		t.Error("expected a salary of another type to not match")
+	}
+}
+
+func TestComposite_KeyRange(t *testing.T) {
+	cond := query.And(
+		query.Field("age", query.Equal(types.IntKey(30))),
+		query.Between(types.IntKey(1), types.IntKey(9)),
+	)
+	start, end, ok := cond.KeyRange()
+	if !ok || start != types.IntKey(1) || end != types.IntKey(9) {
+		t.Fatalf("expected range [1, 9], got [%v, %v] ok=%v", start, end, ok)
+	}
+	if _, _, ok := query.Or(query.Equal(types.IntKey(1)), query.Equal(types.IntKey(2))).KeyRange(); ok {
+		t.Fatal("OR should not narrow the key range")
+	}
+}
+
+func TestIn_MatchesAndKeyPoints(t *testing.T) {
+	cond := query.In(types.IntKey(99), types.IntKey(3), types.IntKey(17), types.IntKey(3))
+
+	if !cond.Matches(types.IntKey(17)) || cond.Matches(types.IntKey(4)) {
+		t.Error("In should match exactly its values")
+	}
+	points, ok := cond.KeyPoints()
+	if !ok {
+		t.Fatal("expected In to reduce to key points")
+	}
+	want := []types.Comparable{types.IntKey(3), types.IntKey(17), types.IntKey(99)}
+	if len(points) != len(want) {
+		t.Fatalf("expected %v, got %v", want, points)
+	}
+	for i := range want {
+		if points[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, points)
+		}
+	}
+
+	field := query.Field("dept", query.In(types.VarcharKey("Eng"), types.VarcharKey("Ops")))
+	if _, ok := field.KeyPoints(); ok {
+		t.Fatal("a field IN must not seek the index")
+	}
+	if !field.MatchesDocument(nil, lookupOf(map[string]types.Comparable{"dept": types.VarcharKey("Ops")})) {
+		t.Error("expected dept Ops to match")
+	}
+}
+
+func TestIsNull_MatchesOnlyNullKeys(t *testing.T) {
+	null, notNull := query.IsNull(), query.IsNotNull()
+
+	if !null.Matches(types.NullKey{}) || null.Matches(types.IntKey(0)) {
+		t.Error("IsNull should match only NULL")
+	}
+	if notNull.Matches(types.NullKey{}) || !notNull.Matches(types.IntKey(0)) {
+		t.Error("IsNotNull should match every non-NULL key")
+	}
+	// NULL is outside every comparison.
+	for _, cond := range []*query.ScanCondition{
+		query.Equal(types.NullKey{}),
+		query.NotEqual(types.IntKey(1)),
+		query.LessThan(types.IntKey(1)),
+	} {
+		if cond.Matches(types.NullKey{}) {
+			t.Errorf("operator %v should not match NULL", cond.Operator)
+		}
+	}
+	if points, ok := null.KeyPoints(); !ok || len(points) != 1 || points[0] != (types.NullKey{}) {
+		t.Fatalf("expected IsNull to seek NULL, got %v", points)
+	}
+
+	missing := lookupOf(map[string]types.Comparable{})
+	if !query.Field("manager", query.IsNull()).MatchesDocument(nil, missing) {
+		t.Error("a missing field should be NULL")
+	}
+	if query.Field("manager", query.IsNotNull()).MatchesDocument(nil, missing) {
+		t.Error("a missing field should not be NOT NULL")
+	}
+}
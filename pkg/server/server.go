@@ -0,0 +1,313 @@
+// Package server exposes a StorageEngine as the gRPC service
+// storaged.Storage (storaged.proto), so clients in any language and several
+// application instances can share one engine process. cmd/storaged runs it.
+//
+// The Go code of the service is generated from storaged.proto:
+//
+//	protoc --go_out=. --go_opt=paths=source_relative \
+//	    --go-grpc_out=. --go-grpc_opt=paths=source_relative \
+//	    pkg/server/storaged.proto
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	storageerrors "github.com/bobboyms/storage-engine/pkg/errors"
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements StorageServer over one engine. Transactions opened by
+// Begin belong to the server, not to the connection that opened them:
+// a client that goes away leaves its transaction open until Close.
+type Server struct {
+	UnimplementedStorageServer
+
+	engine *storage.StorageEngine
+
+	mu     sync.Mutex
+	nextTx uint64
+	txs    map[uint64]*storage.WriteTransaction
+}
+
+// New returns a Server over engine; register it with RegisterStorageServer.
+// The engine stays owned by the caller.
+func New(engine *storage.StorageEngine) *Server {
+	return &Server{engine: engine, txs: make(map[uint64]*storage.WriteTransaction)}
+}
+
+// Close rolls back every transaction still open. Call it after the
+// grpc.Server stopped and before closing the engine.
+func (s *Server) Close() {
+	s.mu.Lock()
+	txs := s.txs
+	s.txs = make(map[uint64]*storage.WriteTransaction)
+	s.mu.Unlock()
+	for _, tx := range txs {
+		_ = tx.Rollback()
+	}
+}
+
+// OpenTransactions returns how many transactions are open.
+func (s *Server) OpenTransactions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.txs)
+}
+
+func (s *Server) Put(ctx context.Context, req *PutRequest) (*PutResponse, error) {
+	key, err := decodeKey(req.GetKey())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetTxId() != 0 {
+		tx, err := s.transaction(req.GetTxId())
+		if err != nil {
+			return nil, err
+		}
+		err = tx.Put(req.GetTable(), req.GetIndex(), key, req.GetDocument())
+		return &PutResponse{}, statusError(err)
+	}
+	err = s.engine.PutCtx(ctx, req.GetTable(), req.GetIndex(), key, req.GetDocument())
+	return &PutResponse{}, statusError(err)
+}
+
+func (s *Server) Get(ctx context.Context, req *GetRequest) (*GetResponse, error) {
+	key, err := decodeKey(req.GetKey())
+	if err != nil {
+		return nil, err
+	}
+	var doc string
+	var found bool
+	if req.GetTxId() != 0 {
+		tx, err := s.transaction(req.GetTxId())
+		if err != nil {
+			return nil, err
+		}
+		doc, found, err = tx.Get(req.GetTable(), req.GetIndex(), key)
+		if err != nil {
+			return nil, statusError(err)
+		}
+	} else {
+		doc, found, err = s.engine.GetCtx(ctx, req.GetTable(), req.GetIndex(), key)
+		if err != nil {
+			return nil, statusError(err)
+		}
+	}
+	return &GetResponse{Found: found, Document: doc}, nil
+}
+
+func (s *Server) Del(ctx context.Context, req *DelRequest) (*DelResponse, error) {
+	key, err := decodeKey(req.GetKey())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetTxId() != 0 {
+		tx, err := s.transaction(req.GetTxId())
+		if err != nil {
+			return nil, err
+		}
+		err = tx.Del(req.GetTable(), req.GetIndex(), key)
+		return &DelResponse{}, statusError(err)
+	}
+	deleted, err := s.engine.Del(req.GetTable(), req.GetIndex(), key)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &DelResponse{Deleted: deleted}, nil
+}
+
+func (s *Server) InsertRow(ctx context.Context, req *InsertRowRequest) (*InsertRowResponse, error) {
+	var keys map[string]types.Comparable
+	if len(req.GetKeys()) > 0 {
+		keys = make(map[string]types.Comparable, len(req.GetKeys()))
+		for index, pk := range req.GetKeys() {
+			key, err := decodeKey(pk)
+			if err != nil {
+				return nil, err
+			}
+			keys[index] = key
+		}
+	}
+	if req.GetTxId() != 0 {
+		tx, err := s.transaction(req.GetTxId())
+		if err != nil {
+			return nil, err
+		}
+		err = tx.InsertRow(req.GetTable(), req.GetDocument(), keys)
+		return &InsertRowResponse{}, statusError(err)
+	}
+	err := s.engine.InsertRow(req.GetTable(), req.GetDocument(), keys)
+	return &InsertRowResponse{}, statusError(err)
+}
+
+// Scan streams the rows of one snapshot. The engine collects the page
+// before the first row is sent, so limit bounds the memory of a call.
+func (s *Server) Scan(req *ScanRequest, stream Storage_ScanServer) error {
+	condition, err := decodeCondition(req.GetCondition())
+	if err != nil {
+		return err
+	}
+	opts := storage.ScanOptions{
+		Limit:      int(req.GetLimit()),
+		Offset:     int(req.GetOffset()),
+		Reverse:    req.GetReverse(),
+		Projection: req.GetProjection(),
+	}
+	rows, err := s.engine.ScanCtx(stream.Context(), req.GetTable(), req.GetIndex(), condition, opts)
+	if err != nil {
+		return statusError(err)
+	}
+	for _, doc := range rows {
+		if err := stream.Send(&Row{Document: doc}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) Begin(ctx context.Context, req *BeginRequest) (*BeginResponse, error) {
+	level := storage.ReadCommitted
+	switch req.GetIsolation() {
+	case Isolation_READ_COMMITTED:
+	case Isolation_REPEATABLE_READ:
+		level = storage.RepeatableRead
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown isolation %v", req.GetIsolation())
+	}
+	tx := s.engine.BeginWriteTransactionWithIsolation(level)
+
+	s.mu.Lock()
+	s.nextTx++
+	id := s.nextTx
+	s.txs[id] = tx
+	s.mu.Unlock()
+	return &BeginResponse{TxId: id}, nil
+}
+
+func (s *Server) Commit(ctx context.Context, req *CommitRequest) (*CommitResponse, error) {
+	tx, err := s.finish(req.GetTxId())
+	if err != nil {
+		return nil, err
+	}
+	return &CommitResponse{}, statusError(tx.CommitCtx(ctx))
+}
+
+func (s *Server) Rollback(ctx context.Context, req *RollbackRequest) (*RollbackResponse, error) {
+	tx, err := s.finish(req.GetTxId())
+	if err != nil {
+		return nil, err
+	}
+	return &RollbackResponse{}, statusError(tx.Rollback())
+}
+
+// transaction returns the open transaction id.
+func (s *Server) transaction(id uint64) (*storage.WriteTransaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, ok := s.txs[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "transaction %d is not open", id)
+	}
+	return tx, nil
+}
+
+// finish removes transaction id, which the caller then commits or rolls
+// back.
+func (s *Server) finish(id uint64) (*storage.WriteTransaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, ok := s.txs[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "transaction %d is not open", id)
+	}
+	delete(s.txs, id)
+	return tx, nil
+}
+
+func decodeKey(pk *storage.Key) (types.Comparable, error) {
+	key, err := storage.KeyFromProto(pk)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "key: %v", err)
+	}
+	return key, nil
+}
+
+// decodeCondition converts a Condition into a query.ScanCondition; nil
+// stays nil and scans the whole index.
+func decodeCondition(c *Condition) (*query.ScanCondition, error) {
+	if c == nil {
+		return nil, nil
+	}
+	if c.GetOperator() < Operator_EQUAL || c.GetOperator() > Operator_IS_NOT_NULL {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown operator %v", c.GetOperator())
+	}
+	sc := &query.ScanCondition{Operator: query.ScanOperator(c.GetOperator()), Field: c.GetField()}
+	var err error
+	if c.GetValue() != nil {
+		if sc.Value, err = decodeKey(c.GetValue()); err != nil {
+			return nil, err
+		}
+	}
+	if c.GetValueEnd() != nil {
+		if sc.ValueEnd, err = decodeKey(c.GetValueEnd()); err != nil {
+			return nil, err
+		}
+	}
+	for _, pk := range c.GetValues() {
+		value, err := decodeKey(pk)
+		if err != nil {
+			return nil, err
+		}
+		sc.Values = append(sc.Values, value)
+	}
+	for _, operand := range c.GetConditions() {
+		decoded, err := decodeCondition(operand)
+		if err != nil {
+			return nil, err
+		}
+		sc.Conditions = append(sc.Conditions, decoded)
+	}
+	return sc, nil
+}
+
+// statusError maps engine errors to gRPC codes; nil stays nil.
+func statusError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var (
+		tableNotFound *storageerrors.TableNotFoundError
+		indexNotFound *storageerrors.IndexNotFoundError
+		rowNotFound   *storageerrors.RowNotFoundError
+		duplicate     *storageerrors.DuplicateKeyError
+		invalidKey    *storageerrors.InvalidKeyTypeError
+		validation    *storageerrors.ValidationError
+	)
+	code := codes.Unknown
+	switch {
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	case errors.As(err, &tableNotFound), errors.As(err, &indexNotFound), errors.As(err, &rowNotFound):
+		code = codes.NotFound
+	case errors.As(err, &duplicate):
+		code = codes.AlreadyExists
+	case errors.As(err, &invalidKey), errors.As(err, &validation):
+		code = codes.InvalidArgument
+	case errors.Is(err, storage.ErrWriteConflict), errors.Is(err, storage.ErrSerializationConflict),
+		errors.Is(err, storage.ErrDeadlockVictim), errors.Is(err, storage.ErrLockWaitTimeout):
+		code = codes.Aborted
+	case errors.Is(err, storage.ErrSnapshotTooOld):
+		code = codes.FailedPrecondition
+	case errors.Is(err, storage.ErrEngineDegraded):
+		code = codes.Unavailable
+	}
+	return status.Error(code, err.Error())
+}
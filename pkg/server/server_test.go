@@ -0,0 +1,183 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/server"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startServer serves an in-memory engine with a "users" table over an
+// in-process listener and returns a client to it.
+func startServer(t *testing.T) (server.StorageClient, *server.Server) {
+	t.Helper()
+	se, err := storage.Open("", storage.Options{InMemory: true})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := se.CreateTable("users", []storage.Index{
+		{Name: "id", Primary: true, Type: storage.TypeInt},
+		{Name: "email", Type: storage.TypeVarchar},
+	}); err != nil {
+		t.Fatalf("CreateTable: %v", err)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	srv := server.New(se)
+	grpcServer := grpc.NewServer()
+	server.RegisterStorageServer(grpcServer, srv)
+	go grpcServer.Serve(lis)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		grpcServer.Stop()
+		srv.Close()
+		se.Close()
+	})
+	return server.NewStorageClient(conn), srv
+}
+
+func protoKey(t *testing.T, key types.Comparable) *storage.Key {
+	t.Helper()
+	pk, err := storage.KeyToProto(key)
+	if err != nil {
+		t.Fatalf("KeyToProto: %v", err)
+	}
+	return pk
+}
+
+func TestServer_AutocommitOperations(t *testing.T) {
+	client, _ := startServer(t)
+	ctx := context.Background()
+
+	for i := int64(1); i <= 5; i++ {
+		doc := fmt.Sprintf(`{"id": %d, "email": "u%d@example.com"}`, i, i)
+		if _, err := client.InsertRow(ctx, &server.InsertRowRequest{Table: "users", Document: doc}); err != nil {
+			t.Fatalf("InsertRow %d: %v", i, err)
+		}
+	}
+	_, err := client.InsertRow(ctx, &server.InsertRowRequest{Table: "users", Document: `{"id": 1, "email": "dup@example.com"}`})
+	if err == nil {
+		t.Fatal("InsertRow of a duplicate primary key should fail")
+	}
+
+	get, err := client.Get(ctx, &server.GetRequest{Table: "users", Index: "email", Key: protoKey(t, types.VarcharKey("u3@example.com"))})
+	if err != nil || !get.GetFound() {
+		t.Fatalf("Get = %v, %v", get, err)
+	}
+	if _, err := client.Put(ctx, &server.PutRequest{Table: "users", Index: "id", Key: protoKey(t, types.IntKey(9)), Document: `{"id": 9}`}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	del, err := client.Del(ctx, &server.DelRequest{Table: "users", Index: "id", Key: protoKey(t, types.IntKey(9))})
+	if err != nil || !del.GetDeleted() {
+		t.Fatalf("Del = %v, %v", del, err)
+	}
+
+	stream, err := client.Scan(ctx, &server.ScanRequest{
+		Table: "users",
+		Index: "id",
+		Condition: &server.Condition{
+			Operator: server.Operator_GREATER_OR_EQUAL,
+			Value:    protoKey(t, types.IntKey(2)),
+		},
+		Limit:      3,
+		Projection: []string{"id"},
+	})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	var rows []string
+	for {
+		row, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		rows = append(rows, row.GetDocument())
+	}
+	if len(rows) != 3 || rows[0] != `{"id":2}` {
+		t.Fatalf("Scan rows = %q", rows)
+	}
+
+	_, err = client.Get(ctx, &server.GetRequest{Table: "missing", Index: "id", Key: protoKey(t, types.IntKey(1))})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("Get on a missing table = %v, want NotFound", err)
+	}
+	_, err = client.Get(ctx, &server.GetRequest{Table: "users", Index: "id"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Get without a key = %v, want InvalidArgument", err)
+	}
+}
+
+func TestServer_Transactions(t *testing.T) {
+	client, srv := startServer(t)
+	ctx := context.Background()
+
+	begin, err := client.Begin(ctx, &server.BeginRequest{Isolation: server.Isolation_REPEATABLE_READ})
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	txID := begin.GetTxId()
+	if _, err := client.InsertRow(ctx, &server.InsertRowRequest{TxId: txID, Table: "users", Document: `{"id": 1, "email": "ana@example.com"}`}); err != nil {
+		t.Fatalf("InsertRow in tx: %v", err)
+	}
+	get, err := client.Get(ctx, &server.GetRequest{Table: "users", Index: "id", Key: protoKey(t, types.IntKey(1))})
+	if err != nil || get.GetFound() {
+		t.Fatalf("uncommitted row visible outside the tx: %v, %v", get, err)
+	}
+	if _, err := client.Commit(ctx, &server.CommitRequest{TxId: txID}); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	get, err = client.Get(ctx, &server.GetRequest{Table: "users", Index: "id", Key: protoKey(t, types.IntKey(1))})
+	if err != nil || !get.GetFound() {
+		t.Fatalf("Get after Commit = %v, %v", get, err)
+	}
+	if _, err := client.Commit(ctx, &server.CommitRequest{TxId: txID}); status.Code(err) != codes.NotFound {
+		t.Fatalf("second Commit = %v, want NotFound", err)
+	}
+
+	begin, err = client.Begin(ctx, &server.BeginRequest{})
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if _, err := client.Del(ctx, &server.DelRequest{TxId: begin.GetTxId(), Table: "users", Index: "id", Key: protoKey(t, types.IntKey(1))}); err != nil {
+		t.Fatalf("Del in tx: %v", err)
+	}
+	if _, err := client.Rollback(ctx, &server.RollbackRequest{TxId: begin.GetTxId()}); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	get, err = client.Get(ctx, &server.GetRequest{Table: "users", Index: "id", Key: protoKey(t, types.IntKey(1))})
+	if err != nil || !get.GetFound() {
+		t.Fatalf("Get after Rollback = %v, %v", get, err)
+	}
+
+	if _, err := client.Begin(ctx, &server.BeginRequest{}); err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if n := srv.OpenTransactions(); n != 1 {
+		t.Fatalf("OpenTransactions = %d, want 1", n)
+	}
+	srv.Close()
+	if n := srv.OpenTransactions(); n != 0 {
+		t.Fatalf("OpenTransactions after Close = %d", n)
+	}
+}
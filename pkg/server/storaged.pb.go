@@ -0,0 +1,1266 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v6.33.4
+// source: pkg/server/storaged.proto
+
+package server
+
+import (
+	storage "github.com/bobboyms/storage-engine/pkg/storage"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Operator mirrors query.ScanOperator, value for value.
+type Operator int32
+
+const (
+	Operator_EQUAL            Operator = 0
+	Operator_NOT_EQUAL        Operator = 1
+	Operator_GREATER_THAN     Operator = 2
+	Operator_GREATER_OR_EQUAL Operator = 3
+	Operator_LESS_THAN        Operator = 4
+	Operator_LESS_OR_EQUAL    Operator = 5
+	Operator_BETWEEN          Operator = 6
+	Operator_AND              Operator = 7
+	Operator_OR               Operator = 8
+	Operator_NOT              Operator = 9
+	Operator_IN               Operator = 10
+	Operator_IS_NULL          Operator = 11
+	Operator_IS_NOT_NULL      Operator = 12
+)
+
+// Enum value maps for Operator.
+var (
+	Operator_name = map[int32]string{
+		0:  "EQUAL",
+		1:  "NOT_EQUAL",
+		2:  "GREATER_THAN",
+		3:  "GREATER_OR_EQUAL",
+		4:  "LESS_THAN",
+		5:  "LESS_OR_EQUAL",
+		6:  "BETWEEN",
+		7:  "AND",
+		8:  "OR",
+		9:  "NOT",
+		10: "IN",
+		11: "IS_NULL",
+		12: "IS_NOT_NULL",
+	}
+	Operator_value = map[string]int32{
+		"EQUAL":            0,
+		"NOT_EQUAL":        1,
+		"GREATER_THAN":     2,
+		"GREATER_OR_EQUAL": 3,
+		"LESS_THAN":        4,
+		"LESS_OR_EQUAL":    5,
+		"BETWEEN":          6,
+		"AND":              7,
+		"OR":               8,
+		"NOT":              9,
+		"IN":               10,
+		"IS_NULL":          11,
+		"IS_NOT_NULL":      12,
+	}
+)
+
+func (x Operator) Enum() *Operator {
+	p := new(Operator)
+	*p = x
+	return p
+}
+
+func (x Operator) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Operator) Descriptor() protoreflect.EnumDescriptor {
+	return file_pkg_server_storaged_proto_enumTypes[0].Descriptor()
+}
+
+func (Operator) Type() protoreflect.EnumType {
+	return &file_pkg_server_storaged_proto_enumTypes[0]
+}
+
+func (x Operator) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Operator.Descriptor instead.
+func (Operator) EnumDescriptor() ([]byte, []int) {
+	return file_pkg_server_storaged_proto_rawDescGZIP(), []int{0}
+}
+
+type Isolation int32
+
+const (
+	Isolation_READ_COMMITTED  Isolation = 0
+	Isolation_REPEATABLE_READ Isolation = 1
+)
+
+// Enum value maps for Isolation.
+var (
+	Isolation_name = map[int32]string{
+		0: "READ_COMMITTED",
+		1: "REPEATABLE_READ",
+	}
+	Isolation_value = map[string]int32{
+		"READ_COMMITTED":  0,
+		"REPEATABLE_READ": 1,
+	}
+)
+
+func (x Isolation) Enum() *Isolation {
+	p := new(Isolation)
+	*p = x
+	return p
+}
+
+func (x Isolation) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Isolation) Descriptor() protoreflect.EnumDescriptor {
+	return file_pkg_server_storaged_proto_enumTypes[1].Descriptor()
+}
+
+func (Isolation) Type() protoreflect.EnumType {
+	return &file_pkg_server_storaged_proto_enumTypes[1]
+}
+
+func (x Isolation) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Isolation.Descriptor instead.
+func (Isolation) EnumDescriptor() ([]byte, []int) {
+	return file_pkg_server_storaged_proto_rawDescGZIP(), []int{1}
+}
+
+type PutRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TxId          uint64                 `protobuf:"varint,1,opt,name=tx_id,json=txId,proto3" json:"tx_id,omitempty"`
+	Table         string                 `protobuf:"bytes,2,opt,name=table,proto3" json:"table,omitempty"`
+	Index         string                 `protobuf:"bytes,3,opt,name=index,proto3" json:"index,omitempty"`
+	Key           *storage.Key           `protobuf:"bytes,4,opt,name=key,proto3" json:"key,omitempty"`
+	Document      string                 `protobuf:"bytes,5,opt,name=document,proto3" json:"document,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PutRequest) Reset() {
+	*x = PutRequest{}
+	mi := &file_pkg_server_storaged_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PutRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PutRequest) ProtoMessage() {}
+
+func (x *PutRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_server_storaged_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PutRequest.ProtoReflect.Descriptor instead.
+func (*PutRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_server_storaged_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *PutRequest) GetTxId() uint64 {
+	if x != nil {
+		return x.TxId
+	}
+	return 0
+}
+
+func (x *PutRequest) GetTable() string {
+	if x != nil {
+		return x.Table
+	}
+	return ""
+}
+
+func (x *PutRequest) GetIndex() string {
+	if x != nil {
+		return x.Index
+	}
+	return ""
+}
+
+func (x *PutRequest) GetKey() *storage.Key {
+	if x != nil {
+		return x.Key
+	}
+	return nil
+}
+
+func (x *PutRequest) GetDocument() string {
+	if x != nil {
+		return x.Document
+	}
+	return ""
+}
+
+type PutResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PutResponse) Reset() {
+	*x = PutResponse{}
+	mi := &file_pkg_server_storaged_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PutResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PutResponse) ProtoMessage() {}
+
+func (x *PutResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_server_storaged_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PutResponse.ProtoReflect.Descriptor instead.
+func (*PutResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_server_storaged_proto_rawDescGZIP(), []int{1}
+}
+
+type GetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TxId          uint64                 `protobuf:"varint,1,opt,name=tx_id,json=txId,proto3" json:"tx_id,omitempty"`
+	Table         string                 `protobuf:"bytes,2,opt,name=table,proto3" json:"table,omitempty"`
+	Index         string                 `protobuf:"bytes,3,opt,name=index,proto3" json:"index,omitempty"`
+	Key           *storage.Key           `protobuf:"bytes,4,opt,name=key,proto3" json:"key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRequest) Reset() {
+	*x = GetRequest{}
+	mi := &file_pkg_server_storaged_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRequest) ProtoMessage() {}
+
+func (x *GetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_server_storaged_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRequest.ProtoReflect.Descriptor instead.
+func (*GetRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_server_storaged_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetRequest) GetTxId() uint64 {
+	if x != nil {
+		return x.TxId
+	}
+	return 0
+}
+
+func (x *GetRequest) GetTable() string {
+	if x != nil {
+		return x.Table
+	}
+	return ""
+}
+
+func (x *GetRequest) GetIndex() string {
+	if x != nil {
+		return x.Index
+	}
+	return ""
+}
+
+func (x *GetRequest) GetKey() *storage.Key {
+	if x != nil {
+		return x.Key
+	}
+	return nil
+}
+
+type GetResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Found         bool                   `protobuf:"varint,1,opt,name=found,proto3" json:"found,omitempty"`
+	Document      string                 `protobuf:"bytes,2,opt,name=document,proto3" json:"document,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetResponse) Reset() {
+	*x = GetResponse{}
+	mi := &file_pkg_server_storaged_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetResponse) ProtoMessage() {}
+
+func (x *GetResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_server_storaged_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetResponse.ProtoReflect.Descriptor instead.
+func (*GetResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_server_storaged_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetResponse) GetFound() bool {
+	if x != nil {
+		return x.Found
+	}
+	return false
+}
+
+func (x *GetResponse) GetDocument() string {
+	if x != nil {
+		return x.Document
+	}
+	return ""
+}
+
+type DelRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TxId          uint64                 `protobuf:"varint,1,opt,name=tx_id,json=txId,proto3" json:"tx_id,omitempty"`
+	Table         string                 `protobuf:"bytes,2,opt,name=table,proto3" json:"table,omitempty"`
+	Index         string                 `protobuf:"bytes,3,opt,name=index,proto3" json:"index,omitempty"`
+	Key           *storage.Key           `protobuf:"bytes,4,opt,name=key,proto3" json:"key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DelRequest) Reset() {
+	*x = DelRequest{}
+	mi := &file_pkg_server_storaged_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DelRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DelRequest) ProtoMessage() {}
+
+func (x *DelRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_server_storaged_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DelRequest.ProtoReflect.Descriptor instead.
+func (*DelRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_server_storaged_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *DelRequest) GetTxId() uint64 {
+	if x != nil {
+		return x.TxId
+	}
+	return 0
+}
+
+func (x *DelRequest) GetTable() string {
+	if x != nil {
+		return x.Table
+	}
+	return ""
+}
+
+func (x *DelRequest) GetIndex() string {
+	if x != nil {
+		return x.Index
+	}
+	return ""
+}
+
+func (x *DelRequest) GetKey() *storage.Key {
+	if x != nil {
+		return x.Key
+	}
+	return nil
+}
+
+type DelResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Inside a transaction the delete is only applied on Commit, so
+	// deleted is always false there.
+	Deleted       bool `protobuf:"varint,1,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DelResponse) Reset() {
+	*x = DelResponse{}
+	mi := &file_pkg_server_storaged_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DelResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DelResponse) ProtoMessage() {}
+
+func (x *DelResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_server_storaged_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DelResponse.ProtoReflect.Descriptor instead.
+func (*DelResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_server_storaged_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DelResponse) GetDeleted() bool {
+	if x != nil {
+		return x.Deleted
+	}
+	return false
+}
+
+type InsertRowRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	TxId     uint64                 `protobuf:"varint,1,opt,name=tx_id,json=txId,proto3" json:"tx_id,omitempty"`
+	Table    string                 `protobuf:"bytes,2,opt,name=table,proto3" json:"table,omitempty"`
+	Document string                 `protobuf:"bytes,3,opt,name=document,proto3" json:"document,omitempty"`
+	// keys is optional: missing keys are extracted from the document.
+	Keys          map[string]*storage.Key `protobuf:"bytes,4,rep,name=keys,proto3" json:"keys,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InsertRowRequest) Reset() {
+	*x = InsertRowRequest{}
+	mi := &file_pkg_server_storaged_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InsertRowRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InsertRowRequest) ProtoMessage() {}
+
+func (x *InsertRowRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_server_storaged_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InsertRowRequest.ProtoReflect.Descriptor instead.
+func (*InsertRowRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_server_storaged_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *InsertRowRequest) GetTxId() uint64 {
+	if x != nil {
+		return x.TxId
+	}
+	return 0
+}
+
+func (x *InsertRowRequest) GetTable() string {
+	if x != nil {
+		return x.Table
+	}
+	return ""
+}
+
+func (x *InsertRowRequest) GetDocument() string {
+	if x != nil {
+		return x.Document
+	}
+	return ""
+}
+
+func (x *InsertRowRequest) GetKeys() map[string]*storage.Key {
+	if x != nil {
+		return x.Keys
+	}
+	return nil
+}
+
+type InsertRowResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InsertRowResponse) Reset() {
+	*x = InsertRowResponse{}
+	mi := &file_pkg_server_storaged_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InsertRowResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InsertRowResponse) ProtoMessage() {}
+
+func (x *InsertRowResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_server_storaged_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InsertRowResponse.ProtoReflect.Descriptor instead.
+func (*InsertRowResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_server_storaged_proto_rawDescGZIP(), []int{7}
+}
+
+// Condition mirrors query.ScanCondition.
+type Condition struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Operator      Operator               `protobuf:"varint,1,opt,name=operator,proto3,enum=storaged.Operator" json:"operator,omitempty"`
+	Value         *storage.Key           `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	ValueEnd      *storage.Key           `protobuf:"bytes,3,opt,name=value_end,json=valueEnd,proto3" json:"value_end,omitempty"` // BETWEEN
+	Values        []*storage.Key         `protobuf:"bytes,4,rep,name=values,proto3" json:"values,omitempty"`                     // IN
+	Field         string                 `protobuf:"bytes,5,opt,name=field,proto3" json:"field,omitempty"`
+	Conditions    []*Condition           `protobuf:"bytes,6,rep,name=conditions,proto3" json:"conditions,omitempty"` // AND, OR, NOT
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Condition) Reset() {
+	*x = Condition{}
+	mi := &file_pkg_server_storaged_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Condition) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Condition) ProtoMessage() {}
+
+func (x *Condition) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_server_storaged_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Condition.ProtoReflect.Descriptor instead.
+func (*Condition) Descriptor() ([]byte, []int) {
+	return file_pkg_server_storaged_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Condition) GetOperator() Operator {
+	if x != nil {
+		return x.Operator
+	}
+	return Operator_EQUAL
+}
+
+func (x *Condition) GetValue() *storage.Key {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *Condition) GetValueEnd() *storage.Key {
+	if x != nil {
+		return x.ValueEnd
+	}
+	return nil
+}
+
+func (x *Condition) GetValues() []*storage.Key {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+func (x *Condition) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *Condition) GetConditions() []*Condition {
+	if x != nil {
+		return x.Conditions
+	}
+	return nil
+}
+
+type ScanRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Table string                 `protobuf:"bytes,1,opt,name=table,proto3" json:"table,omitempty"`
+	Index string                 `protobuf:"bytes,2,opt,name=index,proto3" json:"index,omitempty"`
+	// A missing condition scans the whole index.
+	Condition     *Condition `protobuf:"bytes,3,opt,name=condition,proto3" json:"condition,omitempty"`
+	Limit         uint32     `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`
+	Offset        uint32     `protobuf:"varint,5,opt,name=offset,proto3" json:"offset,omitempty"`
+	Reverse       bool       `protobuf:"varint,6,opt,name=reverse,proto3" json:"reverse,omitempty"`
+	Projection    []string   `protobuf:"bytes,7,rep,name=projection,proto3" json:"projection,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScanRequest) Reset() {
+	*x = ScanRequest{}
+	mi := &file_pkg_server_storaged_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScanRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScanRequest) ProtoMessage() {}
+
+func (x *ScanRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_server_storaged_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScanRequest.ProtoReflect.Descriptor instead.
+func (*ScanRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_server_storaged_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ScanRequest) GetTable() string {
+	if x != nil {
+		return x.Table
+	}
+	return ""
+}
+
+func (x *ScanRequest) GetIndex() string {
+	if x != nil {
+		return x.Index
+	}
+	return ""
+}
+
+func (x *ScanRequest) GetCondition() *Condition {
+	if x != nil {
+		return x.Condition
+	}
+	return nil
+}
+
+func (x *ScanRequest) GetLimit() uint32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ScanRequest) GetOffset() uint32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *ScanRequest) GetReverse() bool {
+	if x != nil {
+		return x.Reverse
+	}
+	return false
+}
+
+func (x *ScanRequest) GetProjection() []string {
+	if x != nil {
+		return x.Projection
+	}
+	return nil
+}
+
+type Row struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Document      string                 `protobuf:"bytes,1,opt,name=document,proto3" json:"document,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Row) Reset() {
+	*x = Row{}
+	mi := &file_pkg_server_storaged_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Row) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Row) ProtoMessage() {}
+
+func (x *Row) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_server_storaged_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Row.ProtoReflect.Descriptor instead.
+func (*Row) Descriptor() ([]byte, []int) {
+	return file_pkg_server_storaged_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Row) GetDocument() string {
+	if x != nil {
+		return x.Document
+	}
+	return ""
+}
+
+type BeginRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Isolation     Isolation              `protobuf:"varint,1,opt,name=isolation,proto3,enum=storaged.Isolation" json:"isolation,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BeginRequest) Reset() {
+	*x = BeginRequest{}
+	mi := &file_pkg_server_storaged_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BeginRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BeginRequest) ProtoMessage() {}
+
+func (x *BeginRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_server_storaged_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BeginRequest.ProtoReflect.Descriptor instead.
+func (*BeginRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_server_storaged_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *BeginRequest) GetIsolation() Isolation {
+	if x != nil {
+		return x.Isolation
+	}
+	return Isolation_READ_COMMITTED
+}
+
+type BeginResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TxId          uint64                 `protobuf:"varint,1,opt,name=tx_id,json=txId,proto3" json:"tx_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BeginResponse) Reset() {
+	*x = BeginResponse{}
+	mi := &file_pkg_server_storaged_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BeginResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BeginResponse) ProtoMessage() {}
+
+func (x *BeginResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_server_storaged_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BeginResponse.ProtoReflect.Descriptor instead.
+func (*BeginResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_server_storaged_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *BeginResponse) GetTxId() uint64 {
+	if x != nil {
+		return x.TxId
+	}
+	return 0
+}
+
+type CommitRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TxId          uint64                 `protobuf:"varint,1,opt,name=tx_id,json=txId,proto3" json:"tx_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CommitRequest) Reset() {
+	*x = CommitRequest{}
+	mi := &file_pkg_server_storaged_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CommitRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CommitRequest) ProtoMessage() {}
+
+func (x *CommitRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_server_storaged_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CommitRequest.ProtoReflect.Descriptor instead.
+func (*CommitRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_server_storaged_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *CommitRequest) GetTxId() uint64 {
+	if x != nil {
+		return x.TxId
+	}
+	return 0
+}
+
+type CommitResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CommitResponse) Reset() {
+	*x = CommitResponse{}
+	mi := &file_pkg_server_storaged_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CommitResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CommitResponse) ProtoMessage() {}
+
+func (x *CommitResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_server_storaged_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CommitResponse.ProtoReflect.Descriptor instead.
+func (*CommitResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_server_storaged_proto_rawDescGZIP(), []int{14}
+}
+
+type RollbackRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TxId          uint64                 `protobuf:"varint,1,opt,name=tx_id,json=txId,proto3" json:"tx_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RollbackRequest) Reset() {
+	*x = RollbackRequest{}
+	mi := &file_pkg_server_storaged_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RollbackRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RollbackRequest) ProtoMessage() {}
+
+func (x *RollbackRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_server_storaged_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RollbackRequest.ProtoReflect.Descriptor instead.
+func (*RollbackRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_server_storaged_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *RollbackRequest) GetTxId() uint64 {
+	if x != nil {
+		return x.TxId
+	}
+	return 0
+}
+
+type RollbackResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RollbackResponse) Reset() {
+	*x = RollbackResponse{}
+	mi := &file_pkg_server_storaged_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RollbackResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RollbackResponse) ProtoMessage() {}
+
+func (x *RollbackResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_server_storaged_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RollbackResponse.ProtoReflect.Descriptor instead.
+func (*RollbackResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_server_storaged_proto_rawDescGZIP(), []int{16}
+}
+
+var File_pkg_server_storaged_proto protoreflect.FileDescriptor
+
+const file_pkg_server_storaged_proto_rawDesc = "" +
+	"\n" +
+	"\x19pkg/server/storaged.proto\x12\bstoraged\x1a\x1apkg/storage/docentry.proto\"\x89\x01\n" +
+	"\n" +
+	"PutRequest\x12\x13\n" +
+	"\x05tx_id\x18\x01 \x01(\x04R\x04txId\x12\x14\n" +
+	"\x05table\x18\x02 \x01(\tR\x05table\x12\x14\n" +
+	"\x05index\x18\x03 \x01(\tR\x05index\x12\x1e\n" +
+	"\x03key\x18\x04 \x01(\v2\f.storage.KeyR\x03key\x12\x1a\n" +
+	"\bdocument\x18\x05 \x01(\tR\bdocument\"\r\n" +
+	"\vPutResponse\"m\n" +
+	"\n" +
+	"GetRequest\x12\x13\n" +
+	"\x05tx_id\x18\x01 \x01(\x04R\x04txId\x12\x14\n" +
+	"\x05table\x18\x02 \x01(\tR\x05table\x12\x14\n" +
+	"\x05index\x18\x03 \x01(\tR\x05index\x12\x1e\n" +
+	"\x03key\x18\x04 \x01(\v2\f.storage.KeyR\x03key\"?\n" +
+	"\vGetResponse\x12\x14\n" +
+	"\x05found\x18\x01 \x01(\bR\x05found\x12\x1a\n" +
+	"\bdocument\x18\x02 \x01(\tR\bdocument\"m\n" +
+	"\n" +
+	"DelRequest\x12\x13\n" +
+	"\x05tx_id\x18\x01 \x01(\x04R\x04txId\x12\x14\n" +
+	"\x05table\x18\x02 \x01(\tR\x05table\x12\x14\n" +
+	"\x05index\x18\x03 \x01(\tR\x05index\x12\x1e\n" +
+	"\x03key\x18\x04 \x01(\v2\f.storage.KeyR\x03key\"'\n" +
+	"\vDelResponse\x12\x18\n" +
+	"\adeleted\x18\x01 \x01(\bR\adeleted\"\xda\x01\n" +
+	"\x10InsertRowRequest\x12\x13\n" +
+	"\x05tx_id\x18\x01 \x01(\x04R\x04txId\x12\x14\n" +
+	"\x05table\x18\x02 \x01(\tR\x05table\x12\x1a\n" +
+	"\bdocument\x18\x03 \x01(\tR\bdocument\x128\n" +
+	"\x04keys\x18\x04 \x03(\v2$.storaged.InsertRowRequest.KeysEntryR\x04keys\x1aE\n" +
+	"\tKeysEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\"\n" +
+	"\x05value\x18\x02 \x01(\v2\f.storage.KeyR\x05value:\x028\x01\"\x13\n" +
+	"\x11InsertRowResponse\"\xfb\x01\n" +
+	"\tCondition\x12.\n" +
+	"\boperator\x18\x01 \x01(\x0e2\x12.storaged.OperatorR\boperator\x12\"\n" +
+	"\x05value\x18\x02 \x01(\v2\f.storage.KeyR\x05value\x12)\n" +
+	"\tvalue_end\x18\x03 \x01(\v2\f.storage.KeyR\bvalueEnd\x12$\n" +
+	"\x06values\x18\x04 \x03(\v2\f.storage.KeyR\x06values\x12\x14\n" +
+	"\x05field\x18\x05 \x01(\tR\x05field\x123\n" +
+	"\n" +
+	"conditions\x18\x06 \x03(\v2\x13.storaged.ConditionR\n" +
+	"conditions\"\xd4\x01\n" +
+	"\vScanRequest\x12\x14\n" +
+	"\x05table\x18\x01 \x01(\tR\x05table\x12\x14\n" +
+	"\x05index\x18\x02 \x01(\tR\x05index\x121\n" +
+	"\tcondition\x18\x03 \x01(\v2\x13.storaged.ConditionR\tcondition\x12\x14\n" +
+	"\x05limit\x18\x04 \x01(\rR\x05limit\x12\x16\n" +
+	"\x06offset\x18\x05 \x01(\rR\x06offset\x12\x18\n" +
+	"\areverse\x18\x06 \x01(\bR\areverse\x12\x1e\n" +
+	"\n" +
+	"projection\x18\a \x03(\tR\n" +
+	"projection\"!\n" +
+	"\x03Row\x12\x1a\n" +
+	"\bdocument\x18\x01 \x01(\tR\bdocument\"A\n" +
+	"\fBeginRequest\x121\n" +
+	"\tisolation\x18\x01 \x01(\x0e2\x13.storaged.IsolationR\tisolation\"$\n" +
+	"\rBeginResponse\x12\x13\n" +
+	"\x05tx_id\x18\x01 \x01(\x04R\x04txId\"$\n" +
+	"\rCommitRequest\x12\x13\n" +
+	"\x05tx_id\x18\x01 \x01(\x04R\x04txId\"\x10\n" +
+	"\x0eCommitResponse\"&\n" +
+	"\x0fRollbackRequest\x12\x13\n" +
+	"\x05tx_id\x18\x01 \x01(\x04R\x04txId\"\x12\n" +
+	"\x10RollbackResponse*\xbb\x01\n" +
+	"\bOperator\x12\t\n" +
+	"\x05EQUAL\x10\x00\x12\r\n" +
+	"\tNOT_EQUAL\x10\x01\x12\x10\n" +
+	"\fGREATER_THAN\x10\x02\x12\x14\n" +
+	"\x10GREATER_OR_EQUAL\x10\x03\x12\r\n" +
+	"\tLESS_THAN\x10\x04\x12\x11\n" +
+	"\rLESS_OR_EQUAL\x10\x05\x12\v\n" +
+	"\aBETWEEN\x10\x06\x12\a\n" +
+	"\x03AND\x10\a\x12\x06\n" +
+	"\x02OR\x10\b\x12\a\n" +
+	"\x03NOT\x10\t\x12\x06\n" +
+	"\x02IN\x10\n" +
+	"\x12\v\n" +
+	"\aIS_NULL\x10\v\x12\x0f\n" +
+	"\vIS_NOT_NULL\x10\f*4\n" +
+	"\tIsolation\x12\x12\n" +
+	"\x0eREAD_COMMITTED\x10\x00\x12\x13\n" +
+	"\x0fREPEATABLE_READ\x10\x012\xd5\x03\n" +
+	"\aStorage\x122\n" +
+	"\x03Put\x12\x14.storaged.PutRequest\x1a\x15.storaged.PutResponse\x122\n" +
+	"\x03Get\x12\x14.storaged.GetRequest\x1a\x15.storaged.GetResponse\x122\n" +
+	"\x03Del\x12\x14.storaged.DelRequest\x1a\x15.storaged.DelResponse\x12D\n" +
+	"\tInsertRow\x12\x1a.storaged.InsertRowRequest\x1a\x1b.storaged.InsertRowResponse\x12.\n" +
+	"\x04Scan\x12\x15.storaged.ScanRequest\x1a\r.storaged.Row0\x01\x128\n" +
+	"\x05Begin\x12\x16.storaged.BeginRequest\x1a\x17.storaged.BeginResponse\x12;\n" +
+	"\x06Commit\x12\x17.storaged.CommitRequest\x1a\x18.storaged.CommitResponse\x12A\n" +
+	"\bRollback\x12\x19.storaged.RollbackRequest\x1a\x1a.storaged.RollbackResponseB/Z-github.com/bobboyms/storage-engine/pkg/serverb\x06proto3"
+
+var (
+	file_pkg_server_storaged_proto_rawDescOnce sync.Once
+	file_pkg_server_storaged_proto_rawDescData []byte
+)
+
+func file_pkg_server_storaged_proto_rawDescGZIP() []byte {
+	file_pkg_server_storaged_proto_rawDescOnce.Do(func() {
+		file_pkg_server_storaged_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_pkg_server_storaged_proto_rawDesc), len(file_pkg_server_storaged_proto_rawDesc)))
+	})
+	return file_pkg_server_storaged_proto_rawDescData
+}
+
+var file_pkg_server_storaged_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_pkg_server_storaged_proto_msgTypes = make([]protoimpl.MessageInfo, 18)
+var file_pkg_server_storaged_proto_goTypes = []any{
+	(Operator)(0),             // 0: storaged.Operator
+	(Isolation)(0),            // 1: storaged.Isolation
+	(*PutRequest)(nil),        // 2: storaged.PutRequest
+	(*PutResponse)(nil),       // 3: storaged.PutResponse
+	(*GetRequest)(nil),        // 4: storaged.GetRequest
+	(*GetResponse)(nil),       // 5: storaged.GetResponse
+	(*DelRequest)(nil),        // 6: storaged.DelRequest
+	(*DelResponse)(nil),       // 7: storaged.DelResponse
+	(*InsertRowRequest)(nil),  // 8: storaged.InsertRowRequest
+	(*InsertRowResponse)(nil), // 9: storaged.InsertRowResponse
+	(*Condition)(nil),         // 10: storaged.Condition
+	(*ScanRequest)(nil),       // 11: storaged.ScanRequest
+	(*Row)(nil),               // 12: storaged.Row
+	(*BeginRequest)(nil),      // 13: storaged.BeginRequest
+	(*BeginResponse)(nil),     // 14: storaged.BeginResponse
+	(*CommitRequest)(nil),     // 15: storaged.CommitRequest
+	(*CommitResponse)(nil),    // 16: storaged.CommitResponse
+	(*RollbackRequest)(nil),   // 17: storaged.RollbackRequest
+	(*RollbackResponse)(nil),  // 18: storaged.RollbackResponse
+	nil,                       // 19: storaged.InsertRowRequest.KeysEntry
+	(*storage.Key)(nil),       // 20: storage.Key
+}
+var file_pkg_server_storaged_proto_depIdxs = []int32{
+	20, // 0: storaged.PutRequest.key:type_name -> storage.Key
+	20, // 1: storaged.GetRequest.key:type_name -> storage.Key
+	20, // 2: storaged.DelRequest.key:type_name -> storage.Key
+	19, // 3: storaged.InsertRowRequest.keys:type_name -> storaged.InsertRowRequest.KeysEntry
+	0,  // 4: storaged.Condition.operator:type_name -> storaged.Operator
+	20, // 5: storaged.Condition.value:type_name -> storage.Key
+	20, // 6: storaged.Condition.value_end:type_name -> storage.Key
+	20, // 7: storaged.Condition.values:type_name -> storage.Key
+	10, // 8: storaged.Condition.conditions:type_name -> storaged.Condition
+	10, // 9: storaged.ScanRequest.condition:type_name -> storaged.Condition
+	1,  // 10: storaged.BeginRequest.isolation:type_name -> storaged.Isolation
+	20, // 11: storaged.InsertRowRequest.KeysEntry.value:type_name -> storage.Key
+	2,  // 12: storaged.Storage.Put:input_type -> storaged.PutRequest
+	4,  // 13: storaged.Storage.Get:input_type -> storaged.GetRequest
+	6,  // 14: storaged.Storage.Del:input_type -> storaged.DelRequest
+	8,  // 15: storaged.Storage.InsertRow:input_type -> storaged.InsertRowRequest
+	11, // 16: storaged.Storage.Scan:input_type -> storaged.ScanRequest
+	13, // 17: storaged.Storage.Begin:input_type -> storaged.BeginRequest
+	15, // 18: storaged.Storage.Commit:input_type -> storaged.CommitRequest
+	17, // 19: storaged.Storage.Rollback:input_type -> storaged.RollbackRequest
+	3,  // 20: storaged.Storage.Put:output_type -> storaged.PutResponse
+	5,  // 21: storaged.Storage.Get:output_type -> storaged.GetResponse
+	7,  // 22: storaged.Storage.Del:output_type -> storaged.DelResponse
+	9,  // 23: storaged.Storage.InsertRow:output_type -> storaged.InsertRowResponse
+	12, // 24: storaged.Storage.Scan:output_type -> storaged.Row
+	14, // 25: storaged.Storage.Begin:output_type -> storaged.BeginResponse
+	16, // 26: storaged.Storage.Commit:output_type -> storaged.CommitResponse
+	18, // 27: storaged.Storage.Rollback:output_type -> storaged.RollbackResponse
+	20, // [20:28] is the sub-list for method output_type
+	12, // [12:20] is the sub-list for method input_type
+	12, // [12:12] is the sub-list for extension type_name
+	12, // [12:12] is the sub-list for extension extendee
+	0,  // [0:12] is the sub-list for field type_name
+}
+
+func init() { file_pkg_server_storaged_proto_init() }
+func file_pkg_server_storaged_proto_init() {
+	if File_pkg_server_storaged_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pkg_server_storaged_proto_rawDesc), len(file_pkg_server_storaged_proto_rawDesc)),
+			NumEnums:      2,
+			NumMessages:   18,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_pkg_server_storaged_proto_goTypes,
+		DependencyIndexes: file_pkg_server_storaged_proto_depIdxs,
+		EnumInfos:         file_pkg_server_storaged_proto_enumTypes,
+		MessageInfos:      file_pkg_server_storaged_proto_msgTypes,
+	}.Build()
+	File_pkg_server_storaged_proto = out.File
+	file_pkg_server_storaged_proto_goTypes = nil
+	file_pkg_server_storaged_proto_depIdxs = nil
+}
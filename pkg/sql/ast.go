@@ -0,0 +1,134 @@
+// Package sql is a small SQL layer over a StorageEngine. It parses one
+// statement at a time and compiles it to the engine's primitives:
+//
+//	CREATE TABLE [IF NOT EXISTS] t (id INT PRIMARY KEY, email VARCHAR, ...)
+//	INSERT INTO t (col, ...) VALUES (v, ...), ...
+//	SELECT * | col, ... FROM t [WHERE cond] [ORDER BY col [ASC|DESC]] [LIMIT n [OFFSET m]]
+//	UPDATE t SET col = v, ... [WHERE cond]
+//	DELETE FROM t [WHERE cond]
+//
+// Rows are the engine's JSON documents. The columns of CREATE TABLE are
+// the indexed ones, each one an index of the table; rows may hold other
+// fields, which WHERE and ORDER BY can also use, without an index.
+//
+// A WHERE condition combines col op value (=, !=, <>, <, <=, >, >=),
+// BETWEEN, IN, IS [NOT] NULL, AND, OR, NOT and parentheses. Values are
+// integers, decimals, 'strings', TRUE, FALSE, NULL or "?" placeholders
+// bound to the arguments of Exec.
+package sql
+
+import (
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+)
+
+// Statement is a parsed statement: *CreateTable, *Insert, *Select,
+// *Update or *Delete.
+type Statement interface {
+	statement()
+}
+
+// CreateTable creates a table with one index per column.
+type CreateTable struct {
+	Name        string
+	IfNotExists bool
+	Columns     []ColumnDef
+}
+
+// ColumnDef is an indexed column of CREATE TABLE.
+type ColumnDef struct {
+	Name    string
+	Type    storage.DataType
+	Primary bool
+}
+
+// Insert inserts Rows, each with one operand per column of Columns.
+type Insert struct {
+	Table   string
+	Columns []string
+	Rows    [][]Operand
+}
+
+// Select reads rows. Columns is nil for SELECT *; Limit and Offset are
+// nil when absent.
+type Select struct {
+	Table   string
+	Columns []string
+	Where   Expr
+	OrderBy *OrderBy
+	Limit   Operand
+	Offset  Operand
+}
+
+// OrderBy sorts a SELECT by one column.
+type OrderBy struct {
+	Column string
+	Desc   bool
+}
+
+// Update sets columns of the rows matching Where.
+type Update struct {
+	Table string
+	Set   []Assignment
+	Where Expr
+}
+
+// Assignment is one col = value of UPDATE ... SET.
+type Assignment struct {
+	Column string
+	Value  Operand
+}
+
+// Delete deletes the rows matching Where.
+type Delete struct {
+	Table string
+	Where Expr
+}
+
+func (*CreateTable) statement() {}
+func (*Insert) statement()      {}
+func (*Select) statement()      {}
+func (*Update) statement()      {}
+func (*Delete) statement()      {}
+
+// Expr is a WHERE condition: *Comparison or *Logical. A nil Expr matches
+// every row.
+type Expr interface {
+	expr()
+}
+
+// Comparison tests one column. Op is a query.ScanOperator other than
+// OpAnd, OpOr and OpNot; Values holds one operand, two for OpBetween, the
+// list for OpIn and none for OpIsNull and OpIsNotNull.
+type Comparison struct {
+	Column string
+	Op     query.ScanOperator
+	Values []Operand
+}
+
+// Logical combines conditions with OpAnd, OpOr or OpNot (one operand).
+type Logical struct {
+	Op       query.ScanOperator
+	Operands []Expr
+}
+
+func (*Comparison) expr() {}
+func (*Logical) expr()    {}
+
+// Operand is a value of a statement: Literal or Placeholder.
+type Operand interface {
+	operand()
+}
+
+// Literal is a constant: int64, float64, string, bool or nil (NULL).
+type Literal struct {
+	Value any
+}
+
+// Placeholder is a "?", bound to the argument Index (from 0) of Exec.
+type Placeholder struct {
+	Index int
+}
+
+func (Literal) operand()     {}
+func (Placeholder) operand() {}
@@ -0,0 +1,478 @@
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// DB runs statements against one engine.
+type DB struct {
+	engine *storage.StorageEngine
+}
+
+// New returns a DB over engine.
+func New(engine *storage.StorageEngine) *DB {
+	return &DB{engine: engine}
+}
+
+// Result is the outcome of a statement. A SELECT fills Columns and Rows,
+// one JSON document per row; the other statements fill RowsAffected.
+type Result struct {
+	// Columns are the selected columns; for SELECT * they are the fields
+	// of the returned rows, in the order they first appear.
+	Columns      []string
+	Rows         []string
+	RowsAffected int
+}
+
+// Exec parses and runs one statement, binding args to its placeholders.
+func (db *DB) Exec(query string, args ...any) (*Result, error) {
+	return db.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext is Exec with a context, checked between rows.
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (*Result, error) {
+	stmt, err := Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return db.ExecStatement(ctx, stmt, args...)
+}
+
+// ExecStatement runs a parsed statement.
+//
+// INSERT writes its rows in one transaction. UPDATE and DELETE are not
+// atomic as a whole: UPDATE changes the matching rows one at a time and
+// DELETE removes them in batches (see StorageEngine.DeleteRange), so a
+// failure can leave part of the rows changed.
+func (db *DB) ExecStatement(ctx context.Context, stmt Statement, args ...any) (*Result, error) {
+	args, err := bindArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	switch stmt := stmt.(type) {
+	case *CreateTable:
+		return db.createTable(stmt)
+	case *Insert:
+		return db.insert(ctx, stmt, args)
+	case *Select:
+		return db.selectRows(ctx, stmt, args)
+	case *Update:
+		return db.update(ctx, stmt, args)
+	case *Delete:
+		return db.delete(stmt, args)
+	}
+	return nil, fmt.Errorf("sql: unsupported statement %T", stmt)
+}
+
+func (db *DB) createTable(stmt *CreateTable) (*Result, error) {
+	if stmt.IfNotExists {
+		if _, err := db.engine.TableMetaData.GetTableByName(stmt.Name); err == nil {
+			return &Result{}, nil
+		}
+	}
+	indices := make([]storage.Index, len(stmt.Columns))
+	primaries := 0
+	for i, column := range stmt.Columns {
+		if column.Primary {
+			primaries++
+		}
+		// Columns are nullable, as in SQL; the primary key never is.
+		indices[i] = storage.Index{Name: column.Name, Primary: column.Primary, Type: column.Type, Nullable: !column.Primary}
+	}
+	if primaries != 1 {
+		return nil, fmt.Errorf("sql: table %s needs exactly one PRIMARY KEY column, got %d", stmt.Name, primaries)
+	}
+	if err := db.engine.CreateTable(stmt.Name, indices); err != nil {
+		return nil, err
+	}
+	return &Result{}, nil
+}
+
+func (db *DB) insert(ctx context.Context, stmt *Insert, args []any) (*Result, error) {
+	docs := make([]string, len(stmt.Rows))
+	for i, row := range stmt.Rows {
+		var b strings.Builder
+		b.WriteByte('{')
+		for j, column := range stmt.Columns {
+			v, err := value(row[j], args)
+			if err != nil {
+				return nil, err
+			}
+			field, err := jsonValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("sql: column %s: %w", column, err)
+			}
+			name, _ := json.Marshal(column)
+			if j > 0 {
+				b.WriteByte(',')
+			}
+			b.Write(name)
+			b.WriteByte(':')
+			b.Write(field)
+		}
+		b.WriteByte('}')
+		docs[i] = b.String()
+	}
+
+	tx := db.engine.BeginWriteTransaction()
+	for _, doc := range docs {
+		if err := ctx.Err(); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := tx.InsertRow(stmt.Table, doc, nil); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &Result{RowsAffected: len(docs)}, nil
+}
+
+func (db *DB) selectRows(ctx context.Context, stmt *Select, args []any) (*Result, error) {
+	table, err := db.engine.TableMetaData.GetTableByName(stmt.Table)
+	if err != nil {
+		return nil, err
+	}
+	var order string
+	if stmt.OrderBy != nil {
+		order = stmt.OrderBy.Column
+	}
+	index := chooseIndex(table, stmt.Where, order)
+	condition, err := compileWhere(table, index, stmt.Where, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts storage.ScanOptions
+	if stmt.Limit != nil {
+		if opts.Limit, err = count(stmt.Limit, args, "LIMIT"); err != nil {
+			return nil, err
+		}
+		if opts.Limit == 0 {
+			return &Result{Columns: stmt.Columns}, nil
+		}
+	}
+	if stmt.Offset != nil {
+		if opts.Offset, err = count(stmt.Offset, args, "OFFSET"); err != nil {
+			return nil, err
+		}
+	}
+
+	// The index walk gives the order when it is on the ORDER BY column;
+	// otherwise the engine sorts what the index finds. Either way it
+	// pages and projects.
+	opts.Projection = stmt.Columns
+	if stmt.OrderBy != nil {
+		if index.FieldPath() == order {
+			opts.Reverse = stmt.OrderBy.Desc
+		} else {
+			opts.OrderBy = &storage.OrderBy{Field: order, Desc: stmt.OrderBy.Desc}
+		}
+	}
+	rows, err := db.engine.ScanCtx(ctx, stmt.Table, index.Name, condition, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := stmt.Columns
+	if columns == nil {
+		if columns, err = rowFields(rows); err != nil {
+			return nil, err
+		}
+	}
+	return &Result{Columns: columns, Rows: rows}, nil
+}
+
+func (db *DB) update(ctx context.Context, stmt *Update, args []any) (*Result, error) {
+	table, err := db.engine.TableMetaData.GetTableByName(stmt.Table)
+	if err != nil {
+		return nil, err
+	}
+	primary, err := primaryIndex(table)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]any, len(stmt.Set))
+	for _, assignment := range stmt.Set {
+		v, err := value(assignment.Value, args)
+		if err != nil {
+			return nil, err
+		}
+		fields[assignment.Column] = v
+	}
+
+	index := chooseIndex(table, stmt.Where, "")
+	condition, err := compileWhere(table, index, stmt.Where, args)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.engine.ScanCtx(ctx, stmt.Table, index.Name, condition, storage.ScanOptions{Projection: []string{primary.FieldPath()}})
+	if err != nil {
+		return nil, err
+	}
+	updated := 0
+	for _, row := range rows {
+		if err := ctx.Err(); err != nil {
+			return &Result{RowsAffected: updated}, err
+		}
+		key, err := documentKey(row, primary)
+		if err != nil {
+			return &Result{RowsAffected: updated}, err
+		}
+		if err := db.engine.UpdateFields(stmt.Table, primary.Name, key, fields); err != nil {
+			return &Result{RowsAffected: updated}, err
+		}
+		updated++
+	}
+	return &Result{RowsAffected: updated}, nil
+}
+
+func (db *DB) delete(stmt *Delete, args []any) (*Result, error) {
+	table, err := db.engine.TableMetaData.GetTableByName(stmt.Table)
+	if err != nil {
+		return nil, err
+	}
+	index := chooseIndex(table, stmt.Where, "")
+	condition, err := compileWhere(table, index, stmt.Where, args)
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := db.engine.DeleteRange(stmt.Table, index.Name, condition)
+	return &Result{RowsAffected: deleted}, err
+}
+
+func primaryIndex(table *storage.Table) (*storage.Index, error) {
+	for _, index := range table.GetIndices() {
+		if index.Primary {
+			return index, nil
+		}
+	}
+	return nil, fmt.Errorf("sql: table %s has no primary index", table.Name)
+}
+
+// columnIndex returns the index that reads column, or nil. Multikey
+// indexes hold one entry per array element and cannot stand for the
+// column.
+func columnIndex(table *storage.Table, column string) *storage.Index {
+	var found *storage.Index
+	for _, index := range table.GetIndices() {
+		if index.FieldPath() != column || index.Multikey {
+			continue
+		}
+		if found == nil || index.Primary {
+			found = index
+		}
+	}
+	return found
+}
+
+// chooseIndex picks the index a statement scans: the index of the ORDER
+// BY column, so rows come out sorted; else one an = or IN of the WHERE
+// can seek; else one a range of the WHERE can narrow; else the primary
+// index. Only the top-level conjuncts of the WHERE are considered.
+func chooseIndex(table *storage.Table, where Expr, order string) *storage.Index {
+	if order != "" {
+		if index := columnIndex(table, order); index != nil {
+			return index
+		}
+	}
+	conjuncts := conjuncts(where)
+	for _, seekable := range []func(query.ScanOperator) bool{
+		func(op query.ScanOperator) bool { return op == query.OpEqual || op == query.OpIn },
+		func(op query.ScanOperator) bool { return op != query.OpNotEqual && op != query.OpIsNotNull },
+	} {
+		for _, expr := range conjuncts {
+			comparison, ok := expr.(*Comparison)
+			if !ok || !seekable(comparison.Op) {
+				continue
+			}
+			if index := columnIndex(table, comparison.Column); index != nil {
+				return index
+			}
+		}
+	}
+	index, _ := primaryIndex(table)
+	return index
+}
+
+// conjuncts splits the top level of where on AND.
+func conjuncts(where Expr) []Expr {
+	if logical, ok := where.(*Logical); ok && logical.Op == query.OpAnd {
+		return logical.Operands
+	}
+	if where == nil {
+		return nil
+	}
+	return []Expr{where}
+}
+
+// compileWhere builds the scan condition of where for a scan of index.
+// Comparisons on the indexed column test the index key; the others test
+// the document field. A lower and an upper bound of the indexed column at
+// the top level also add a BETWEEN, so the scan seeks to the range.
+func compileWhere(table *storage.Table, index *storage.Index, where Expr, args []any) (*query.ScanCondition, error) {
+	if where == nil {
+		return nil, nil
+	}
+	condition, err := compileExpr(table, index, where, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var lower, upper types.Comparable
+	for _, expr := range conjuncts(where) {
+		comparison, ok := expr.(*Comparison)
+		if !ok || comparison.Column != index.FieldPath() {
+			continue
+		}
+		switch comparison.Op {
+		case query.OpEqual, query.OpIn, query.OpBetween:
+			// Already seeks.
+			return condition, nil
+		case query.OpGreaterThan, query.OpGreaterOrEqual:
+			lower, err = operandKey(index.Type, comparison.Values[0], args)
+		case query.OpLessThan, query.OpLessOrEqual:
+			upper, err = operandKey(index.Type, comparison.Values[0], args)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if lower != nil && upper != nil {
+		condition = query.And(query.Between(lower, upper), condition)
+	}
+	return condition, nil
+}
+
+func compileExpr(table *storage.Table, index *storage.Index, expr Expr, args []any) (*query.ScanCondition, error) {
+	switch expr := expr.(type) {
+	case *Logical:
+		conditions := make([]*query.ScanCondition, len(expr.Operands))
+		for i, operand := range expr.Operands {
+			condition, err := compileExpr(table, index, operand, args)
+			if err != nil {
+				return nil, err
+			}
+			conditions[i] = condition
+		}
+		return &query.ScanCondition{Operator: expr.Op, Conditions: conditions}, nil
+	case *Comparison:
+		onIndex := expr.Column == index.FieldPath()
+		key := func(op Operand) (types.Comparable, error) {
+			if onIndex {
+				return operandKey(index.Type, op, args)
+			}
+			return fieldKey(table, expr.Column, op, args)
+		}
+		condition := &query.ScanCondition{Operator: expr.Op}
+		switch expr.Op {
+		case query.OpIsNull:
+			condition = query.IsNull()
+		case query.OpIsNotNull:
+		case query.OpIn:
+			for _, op := range expr.Values {
+				k, err := key(op)
+				if err != nil {
+					return nil, err
+				}
+				condition.Values = append(condition.Values, k)
+			}
+		case query.OpBetween:
+			start, err := key(expr.Values[0])
+			if err != nil {
+				return nil, err
+			}
+			end, err := key(expr.Values[1])
+			if err != nil {
+				return nil, err
+			}
+			condition = query.Between(start, end)
+		default:
+			k, err := key(expr.Values[0])
+			if err != nil {
+				return nil, err
+			}
+			condition.Value = k
+		}
+		if onIndex {
+			return condition, nil
+		}
+		return query.Field(expr.Column, condition), nil
+	}
+	return nil, fmt.Errorf("sql: unknown expression %T", expr)
+}
+
+func operandKey(t storage.DataType, op Operand, args []any) (types.Comparable, error) {
+	v, err := value(op, args)
+	if err != nil {
+		return nil, err
+	}
+	return keyFor(t, v)
+}
+
+// fieldKey converts an operand compared with a document field. Dates and
+// bytes are stored with their own BSON types, so a column indexed as one
+// of them converts strings; other values keep the type they were written
+// with.
+func fieldKey(table *storage.Table, column string, op Operand, args []any) (types.Comparable, error) {
+	v, err := value(op, args)
+	if err != nil {
+		return nil, err
+	}
+	if index := columnIndex(table, column); index != nil && (index.Type == storage.TypeDate || index.Type == storage.TypeBytes) {
+		return keyFor(index.Type, v)
+	}
+	return naturalKey(v)
+}
+
+// documentField reads a field of a JSON document; a missing field reads
+// as NULL.
+func documentField(row, field string) (types.Comparable, error) {
+	doc, err := storage.JsonToBson(row)
+	if err != nil {
+		return nil, fmt.Errorf("sql: cannot decode row: %w", err)
+	}
+	if value, err := storage.GetValueFromBson(doc, field); err == nil {
+		return value, nil
+	}
+	return types.NullKey{}, nil
+}
+
+// documentKey reads the key of index from a row.
+func documentKey(row string, index *storage.Index) (types.Comparable, error) {
+	value, err := documentField(row, index.FieldPath())
+	if err != nil {
+		return nil, err
+	}
+	return keyFor(index.Type, goValue(value))
+}
+
+// rowFields lists the top-level fields of rows in the order they first
+// appear.
+func rowFields(rows []string) ([]string, error) {
+	var fields []string
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		doc, err := storage.JsonToBson(row)
+		if err != nil {
+			return nil, fmt.Errorf("sql: cannot decode row: %w", err)
+		}
+		for _, elem := range doc {
+			if !seen[elem.Key] {
+				seen[elem.Key] = true
+				fields = append(fields, elem.Key)
+			}
+		}
+	}
+	return fields, nil
+}
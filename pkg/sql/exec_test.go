@@ -0,0 +1,141 @@
+package sql_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/sql"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	se, err := storage.Open("", storage.Options{InMemory: true})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { se.Close() })
+	db := sql.New(se)
+	mustExec(t, db, `CREATE TABLE users (id INT PRIMARY KEY, email VARCHAR, age INT)`)
+	for i := 1; i <= 10; i++ {
+		mustExec(t, db, `INSERT INTO users (id, email, age, city) VALUES (?, ?, ?, ?)`,
+			i, fmt.Sprintf("user%02d@example.com", i), 20+i%4, []string{"Recife", "Lisboa"}[i%2])
+	}
+	return db
+}
+
+func mustExec(t *testing.T, db *sql.DB, query string, args ...any) *sql.Result {
+	t.Helper()
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		t.Fatalf("Exec(%q): %v", query, err)
+	}
+	return result
+}
+
+// ids runs a SELECT of the id column and returns the ids in order.
+func ids(t *testing.T, db *sql.DB, query string, args ...any) string {
+	t.Helper()
+	var out []string
+	for _, row := range mustExec(t, db, query, args...).Rows {
+		out = append(out, strings.TrimSuffix(strings.TrimPrefix(row, `{"id":`), "}"))
+	}
+	return strings.Join(out, ",")
+}
+
+func TestDB_Select(t *testing.T) {
+	db := openDB(t)
+	tests := []struct {
+		query string
+		args  []any
+		want  string
+	}{
+		{`SELECT id FROM users WHERE id > 3 AND id <= 6`, nil, "4,5,6"},
+		{`SELECT id FROM users WHERE id IN (9, 2, 42)`, nil, "2,9"},
+		{`SELECT id FROM users WHERE email = ?`, []any{"user07@example.com"}, "7"},
+		{`SELECT id FROM users WHERE age = 21 AND city = 'Lisboa'`, nil, "1,5,9"},
+		{`SELECT id FROM users WHERE city = 'Recife' AND NOT id BETWEEN 3 AND 8`, nil, "2,10"},
+		{`SELECT id FROM users WHERE age < 21 OR id = 1`, nil, "1,4,8"},
+		{`SELECT id FROM users ORDER BY email DESC LIMIT 3 OFFSET 1`, nil, "9,8,7"},
+		{`SELECT id FROM users WHERE id <= 6 ORDER BY city DESC LIMIT 3`, nil, "2,4,6"},
+		{`SELECT id FROM users WHERE email IS NULL`, nil, ""},
+		{`SELECT id FROM users LIMIT 0`, nil, ""},
+	}
+	for _, tt := range tests {
+		if got := ids(t, db, tt.query, tt.args...); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+
+	result := mustExec(t, db, `SELECT * FROM users WHERE id = 1`)
+	if want := []string{"id", "email", "age", "city"}; !reflect.DeepEqual(result.Columns, want) {
+		t.Fatalf("Columns = %v, want %v", result.Columns, want)
+	}
+	if _, err := db.Exec(`SELECT * FROM users WHERE id = 'one'`); err == nil {
+		t.Fatal("a string key for an INT column should fail")
+	}
+	if _, err := db.Exec(`SELECT * FROM missing`); err == nil {
+		t.Fatal("SELECT from a missing table should fail")
+	}
+}
+
+func TestDB_InsertIsAtomic(t *testing.T) {
+	db := openDB(t)
+	if _, err := db.Exec(`INSERT INTO users (id, email) VALUES (11, 'a@example.com'), (3, 'dup@example.com')`); err == nil {
+		t.Fatal("inserting a duplicate id should fail")
+	}
+	if got := ids(t, db, `SELECT id FROM users WHERE id = 11`); got != "" {
+		t.Fatalf("row 11 of the failed INSERT is visible: %q", got)
+	}
+	mustExec(t, db, `INSERT INTO users (id) VALUES (11)`)
+	if got := ids(t, db, `SELECT id FROM users WHERE email IS NULL`); got != "11" {
+		t.Fatalf("rows without email = %q, want 11", got)
+	}
+}
+
+func TestDB_UpdateDelete(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+
+	result, err := db.ExecContext(ctx, `UPDATE users SET age = ?, note = 'moved' WHERE city = 'Recife' AND id < 7`, 99)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RowsAffected != 3 {
+		t.Fatalf("UPDATE affected %d rows, want 3", result.RowsAffected)
+	}
+	if got := ids(t, db, `SELECT id FROM users WHERE age = 99 AND note = 'moved'`); got != "2,4,6" {
+		t.Fatalf("updated rows = %q", got)
+	}
+	if _, err := db.Exec(`UPDATE users SET id = 100 WHERE id = 1`); err == nil {
+		t.Fatal("changing the primary key should fail")
+	}
+
+	result = mustExec(t, db, `DELETE FROM users WHERE age = 99 OR id > 8`)
+	if result.RowsAffected != 5 {
+		t.Fatalf("DELETE affected %d rows, want 5", result.RowsAffected)
+	}
+	if got := ids(t, db, `SELECT id FROM users`); got != "1,3,5,7,8" {
+		t.Fatalf("rows left = %q", got)
+	}
+	if result := mustExec(t, db, `DELETE FROM users`); result.RowsAffected != 5 {
+		t.Fatalf("DELETE of all rows affected %d", result.RowsAffected)
+	}
+}
+
+func TestDB_CreateTable(t *testing.T) {
+	db := openDB(t)
+	if _, err := db.Exec(`CREATE TABLE users (id INT PRIMARY KEY)`); err == nil {
+		t.Fatal("creating an existing table should fail")
+	}
+	mustExec(t, db, `CREATE TABLE IF NOT EXISTS users (id INT PRIMARY KEY)`)
+	if _, err := db.Exec(`CREATE TABLE t (a INT, b INT)`); err == nil {
+		t.Fatal("a table without a primary key should fail")
+	}
+	if _, err := db.Exec(`SELECT * FROM users WHERE id = ?`); err == nil {
+		t.Fatal("a missing argument should fail")
+	}
+}
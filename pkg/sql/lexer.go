@@ -0,0 +1,129 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokEOF    tokenKind = iota
+	tokIdent            // name or keyword; keywords match case-insensitively
+	tokQuoted           // "quoted identifier"
+	tokNumber
+	tokString
+	tokSymbol // ( ) , * = != <> < <= > >= ; ? -
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// SyntaxError reports where a statement stopped parsing.
+type SyntaxError struct {
+	Pos int // byte offset in the statement
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("sql: syntax error at offset %d: %s", e.Pos, e.Msg)
+}
+
+// lex splits input into tokens, ending with tokEOF.
+func lex(input string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(input); {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '-' && i+1 < len(input) && input[i+1] == '-':
+			// A -- comment runs to the end of the line.
+			for i < len(input) && input[i] != '\n' {
+				i++
+			}
+		case isIdentStart(c):
+			start := i
+			for i < len(input) && (isIdentStart(input[i]) || isDigit(input[i]) || input[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: input[start:i], pos: start})
+		case isDigit(c) || (c == '.' && i+1 < len(input) && isDigit(input[i+1])):
+			start := i
+			for i < len(input) && (isDigit(input[i]) || input[i] == '.') {
+				i++
+			}
+			if i < len(input) && (input[i] == 'e' || input[i] == 'E') {
+				i++
+				if i < len(input) && (input[i] == '+' || input[i] == '-') {
+					i++
+				}
+				for i < len(input) && isDigit(input[i]) {
+					i++
+				}
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: input[start:i], pos: start})
+		case c == '\'' || c == '"':
+			start := i
+			text, next, err := lexQuoted(input, i)
+			if err != nil {
+				return nil, err
+			}
+			kind := tokString
+			if c == '"' {
+				kind = tokQuoted
+			}
+			tokens = append(tokens, token{kind: kind, text: text, pos: start})
+			i = next
+		default:
+			start := i
+			symbol := string(c)
+			if i+1 < len(input) {
+				switch two := input[i : i+2]; two {
+				case "!=", "<>", "<=", ">=":
+					symbol = two
+				}
+			}
+			if len(symbol) == 1 && !strings.Contains("(),*=<>;?-", symbol) {
+				return nil, &SyntaxError{Pos: start, Msg: fmt.Sprintf("unexpected character %q", c)}
+			}
+			tokens = append(tokens, token{kind: tokSymbol, text: symbol, pos: start})
+			i += len(symbol)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(input)}), nil
+}
+
+// lexQuoted reads the quoted text starting at input[start]; a doubled
+// quote stands for the quote itself.
+func lexQuoted(input string, start int) (string, int, error) {
+	quote := input[start]
+	var b strings.Builder
+	for i := start + 1; i < len(input); i++ {
+		if input[i] != quote {
+			b.WriteByte(input[i])
+			continue
+		}
+		if i+1 < len(input) && input[i+1] == quote {
+			b.WriteByte(quote)
+			i++
+			continue
+		}
+		return b.String(), i + 1, nil
+	}
+	return "", 0, &SyntaxError{Pos: start, Msg: "unterminated quoted text"}
+}
+
+// isIdentStart accepts ASCII letters, '_' and every byte of a multi-byte
+// UTF-8 character.
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= utf8.RuneSelf
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
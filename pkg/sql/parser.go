@@ -0,0 +1,520 @@
+package sql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+)
+
+// Parse parses one statement; a trailing ";" is allowed.
+func Parse(input string) (Statement, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	stmt, err := p.statement()
+	if err != nil {
+		return nil, err
+	}
+	p.acceptSymbol(";")
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorf(tok, "unexpected %q after the statement", tok.text)
+	}
+	return stmt, nil
+}
+
+// columnTypes maps the type names of CREATE TABLE to index types.
+var columnTypes = map[string]storage.DataType{
+	"INT": storage.TypeInt, "INTEGER": storage.TypeInt, "BIGINT": storage.TypeInt,
+	"VARCHAR": storage.TypeVarchar, "TEXT": storage.TypeVarchar, "STRING": storage.TypeVarchar,
+	"BOOL": storage.TypeBoolean, "BOOLEAN": storage.TypeBoolean,
+	"FLOAT": storage.TypeFloat, "DOUBLE": storage.TypeFloat, "REAL": storage.TypeFloat,
+	"DATE": storage.TypeDate, "TIMESTAMP": storage.TypeDate,
+	"DECIMAL": storage.TypeDecimal, "NUMERIC": storage.TypeDecimal,
+	"UUID":  storage.TypeUUID,
+	"BYTES": storage.TypeBytes, "BLOB": storage.TypeBytes,
+}
+
+type parser struct {
+	tokens       []token
+	pos          int
+	placeholders int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) errorf(tok token, format string, args ...any) error {
+	return &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// isKeyword reports whether tok is the keyword word (upper case).
+func isKeyword(tok token, word string) bool {
+	return tok.kind == tokIdent && strings.EqualFold(tok.text, word)
+}
+
+func (p *parser) acceptKeyword(word string) bool {
+	if isKeyword(p.peek(), word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectKeyword(word string) error {
+	if tok := p.peek(); !p.acceptKeyword(word) {
+		return p.errorf(tok, "expected %s, found %q", word, tok.text)
+	}
+	return nil
+}
+
+func (p *parser) acceptSymbol(symbol string) bool {
+	if tok := p.peek(); tok.kind == tokSymbol && tok.text == symbol {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectSymbol(symbol string) error {
+	if tok := p.peek(); !p.acceptSymbol(symbol) {
+		return p.errorf(tok, "expected %q, found %q", symbol, tok.text)
+	}
+	return nil
+}
+
+// identifier reads a name: a bare word or a "quoted" one.
+func (p *parser) identifier() (string, error) {
+	tok := p.next()
+	if tok.kind != tokIdent && tok.kind != tokQuoted {
+		return "", p.errorf(tok, "expected a name, found %q", tok.text)
+	}
+	return tok.text, nil
+}
+
+func (p *parser) identifierList() ([]string, error) {
+	var names []string
+	for {
+		name, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		if !p.acceptSymbol(",") {
+			return names, nil
+		}
+	}
+}
+
+func (p *parser) statement() (Statement, error) {
+	tok := p.next()
+	switch {
+	case isKeyword(tok, "CREATE"):
+		return p.createTable()
+	case isKeyword(tok, "INSERT"):
+		return p.insert()
+	case isKeyword(tok, "SELECT"):
+		return p.selectStatement()
+	case isKeyword(tok, "UPDATE"):
+		return p.update()
+	case isKeyword(tok, "DELETE"):
+		return p.delete()
+	}
+	return nil, p.errorf(tok, "expected a statement, found %q", tok.text)
+}
+
+func (p *parser) createTable() (*CreateTable, error) {
+	if err := p.expectKeyword("TABLE"); err != nil {
+		return nil, err
+	}
+	stmt := &CreateTable{}
+	if p.acceptKeyword("IF") {
+		if err := p.expectKeyword("NOT"); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("EXISTS"); err != nil {
+			return nil, err
+		}
+		stmt.IfNotExists = true
+	}
+	var err error
+	if stmt.Name, err = p.identifier(); err != nil {
+		return nil, err
+	}
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	for {
+		column, err := p.columnDef()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Columns = append(stmt.Columns, column)
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+	if err := p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+func (p *parser) columnDef() (ColumnDef, error) {
+	var column ColumnDef
+	var err error
+	if column.Name, err = p.identifier(); err != nil {
+		return column, err
+	}
+	tok := p.next()
+	typ, ok := columnTypes[strings.ToUpper(tok.text)]
+	if tok.kind != tokIdent || !ok {
+		return column, p.errorf(tok, "unknown column type %q", tok.text)
+	}
+	column.Type = typ
+	// VARCHAR(255), DECIMAL(10, 2): the sizes do not change the index.
+	if p.acceptSymbol("(") {
+		for !p.acceptSymbol(")") {
+			if tok := p.next(); tok.kind == tokEOF {
+				return column, p.errorf(tok, "unterminated type arguments")
+			}
+		}
+	}
+	if p.acceptKeyword("PRIMARY") {
+		if err := p.expectKeyword("KEY"); err != nil {
+			return column, err
+		}
+		column.Primary = true
+	}
+	return column, nil
+}
+
+func (p *parser) insert() (*Insert, error) {
+	if err := p.expectKeyword("INTO"); err != nil {
+		return nil, err
+	}
+	stmt := &Insert{}
+	var err error
+	if stmt.Table, err = p.identifier(); err != nil {
+		return nil, err
+	}
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	if stmt.Columns, err = p.identifierList(); err != nil {
+		return nil, err
+	}
+	if err := p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("VALUES"); err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		row, err := p.operandList()
+		if err != nil {
+			return nil, err
+		}
+		if len(row) != len(stmt.Columns) {
+			return nil, p.errorf(tok, "%d values for %d columns", len(row), len(stmt.Columns))
+		}
+		stmt.Rows = append(stmt.Rows, row)
+		if !p.acceptSymbol(",") {
+			return stmt, nil
+		}
+	}
+}
+
+func (p *parser) selectStatement() (*Select, error) {
+	stmt := &Select{}
+	if !p.acceptSymbol("*") {
+		columns, err := p.identifierList()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Columns = columns
+	}
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	var err error
+	if stmt.Table, err = p.identifier(); err != nil {
+		return nil, err
+	}
+	if stmt.Where, err = p.where(); err != nil {
+		return nil, err
+	}
+	if p.acceptKeyword("ORDER") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		column, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		stmt.OrderBy = &OrderBy{Column: column}
+		if p.acceptKeyword("DESC") {
+			stmt.OrderBy.Desc = true
+		} else {
+			p.acceptKeyword("ASC")
+		}
+	}
+	if p.acceptKeyword("LIMIT") {
+		if stmt.Limit, err = p.operand(); err != nil {
+			return nil, err
+		}
+		if p.acceptKeyword("OFFSET") {
+			if stmt.Offset, err = p.operand(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return stmt, nil
+}
+
+func (p *parser) update() (*Update, error) {
+	stmt := &Update{}
+	var err error
+	if stmt.Table, err = p.identifier(); err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("SET"); err != nil {
+		return nil, err
+	}
+	for {
+		column, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectSymbol("="); err != nil {
+			return nil, err
+		}
+		value, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Set = append(stmt.Set, Assignment{Column: column, Value: value})
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+	if stmt.Where, err = p.where(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+func (p *parser) delete() (*Delete, error) {
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	stmt := &Delete{}
+	var err error
+	if stmt.Table, err = p.identifier(); err != nil {
+		return nil, err
+	}
+	if stmt.Where, err = p.where(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// where reads an optional WHERE clause.
+func (p *parser) where() (Expr, error) {
+	if !p.acceptKeyword("WHERE") {
+		return nil, nil
+	}
+	return p.orExpr()
+}
+
+func (p *parser) orExpr() (Expr, error) {
+	return p.logical("OR", query.OpOr, p.andExpr)
+}
+
+func (p *parser) andExpr() (Expr, error) {
+	return p.logical("AND", query.OpAnd, p.notExpr)
+}
+
+// logical reads operands joined by keyword.
+func (p *parser) logical(keyword string, op query.ScanOperator, operand func() (Expr, error)) (Expr, error) {
+	first, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	operands := []Expr{first}
+	for p.acceptKeyword(keyword) {
+		next, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, next)
+	}
+	if len(operands) == 1 {
+		return first, nil
+	}
+	return &Logical{Op: op, Operands: operands}, nil
+}
+
+func (p *parser) notExpr() (Expr, error) {
+	if p.acceptKeyword("NOT") {
+		operand, err := p.notExpr()
+		if err != nil {
+			return nil, err
+		}
+		return not(operand), nil
+	}
+	if p.acceptSymbol("(") {
+		expr, err := p.orExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	}
+	return p.comparison()
+}
+
+func not(expr Expr) Expr {
+	return &Logical{Op: query.OpNot, Operands: []Expr{expr}}
+}
+
+var comparisonOperators = map[string]query.ScanOperator{
+	"=": query.OpEqual, "!=": query.OpNotEqual, "<>": query.OpNotEqual,
+	"<": query.OpLessThan, "<=": query.OpLessOrEqual,
+	">": query.OpGreaterThan, ">=": query.OpGreaterOrEqual,
+}
+
+func (p *parser) comparison() (Expr, error) {
+	column, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	tok := p.next()
+	if op, ok := comparisonOperators[tok.text]; ok && tok.kind == tokSymbol {
+		value, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return &Comparison{Column: column, Op: op, Values: []Operand{value}}, nil
+	}
+
+	if isKeyword(tok, "IS") {
+		op := query.OpIsNull
+		if p.acceptKeyword("NOT") {
+			op = query.OpIsNotNull
+		}
+		if err := p.expectKeyword("NULL"); err != nil {
+			return nil, err
+		}
+		return &Comparison{Column: column, Op: op}, nil
+	}
+
+	negated := isKeyword(tok, "NOT")
+	if negated {
+		tok = p.next()
+	}
+	var expr Expr
+	switch {
+	case isKeyword(tok, "BETWEEN"):
+		low, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("AND"); err != nil {
+			return nil, err
+		}
+		high, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		expr = &Comparison{Column: column, Op: query.OpBetween, Values: []Operand{low, high}}
+	case isKeyword(tok, "IN"):
+		values, err := p.operandList()
+		if err != nil {
+			return nil, err
+		}
+		expr = &Comparison{Column: column, Op: query.OpIn, Values: values}
+	default:
+		return nil, p.errorf(tok, "expected a comparison after %s, found %q", column, tok.text)
+	}
+	if negated {
+		return not(expr), nil
+	}
+	return expr, nil
+}
+
+// operandList reads "(v, ...)".
+func (p *parser) operandList() ([]Operand, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	var values []Operand
+	for {
+		value, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+	if err := p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func (p *parser) operand() (Operand, error) {
+	tok := p.next()
+	switch {
+	case tok.kind == tokSymbol && tok.text == "?":
+		p.placeholders++
+		return Placeholder{Index: p.placeholders - 1}, nil
+	case tok.kind == tokSymbol && tok.text == "-":
+		number := p.next()
+		if number.kind != tokNumber {
+			return nil, p.errorf(number, "expected a number after '-', found %q", number.text)
+		}
+		return parseNumber(p, token{kind: tokNumber, text: "-" + number.text, pos: tok.pos})
+	case tok.kind == tokNumber:
+		return parseNumber(p, tok)
+	case tok.kind == tokString:
+		return Literal{Value: tok.text}, nil
+	case isKeyword(tok, "TRUE"):
+		return Literal{Value: true}, nil
+	case isKeyword(tok, "FALSE"):
+		return Literal{Value: false}, nil
+	case isKeyword(tok, "NULL"):
+		return Literal{Value: nil}, nil
+	}
+	return nil, p.errorf(tok, "expected a value, found %q", tok.text)
+}
+
+// parseNumber reads an integer as int64 and anything else as float64.
+func parseNumber(p *parser, tok token) (Operand, error) {
+	if i, err := strconv.ParseInt(tok.text, 10, 64); err == nil {
+		return Literal{Value: i}, nil
+	}
+	f, err := strconv.ParseFloat(tok.text, 64)
+	if err != nil {
+		return nil, p.errorf(tok, "invalid number %q", tok.text)
+	}
+	return Literal{Value: f}, nil
+}
@@ -0,0 +1,108 @@
+package sql
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+)
+
+func TestParse_Statements(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Statement
+	}{
+		{
+			`create table if not exists users (id INT PRIMARY KEY, email VARCHAR(255), score decimal(10, 2));`,
+			&CreateTable{Name: "users", IfNotExists: true, Columns: []ColumnDef{
+				{Name: "id", Type: storage.TypeInt, Primary: true},
+				{Name: "email", Type: storage.TypeVarchar},
+				{Name: "score", Type: storage.TypeDecimal},
+			}},
+		},
+		{
+			`INSERT INTO users (id, "e-mail") VALUES (1, 'it''s'), (?, NULL)`,
+			&Insert{Table: "users", Columns: []string{"id", "e-mail"}, Rows: [][]Operand{
+				{Literal{int64(1)}, Literal{"it's"}},
+				{Placeholder{0}, Literal{nil}},
+			}},
+		},
+		{
+			`SELECT id, address.city FROM users WHERE age >= -1.5 ORDER BY id DESC LIMIT ? OFFSET 10 -- page 2`,
+			&Select{
+				Table:   "users",
+				Columns: []string{"id", "address.city"},
+				Where:   &Comparison{Column: "age", Op: query.OpGreaterOrEqual, Values: []Operand{Literal{-1.5}}},
+				OrderBy: &OrderBy{Column: "id", Desc: true},
+				Limit:   Placeholder{0},
+				Offset:  Literal{int64(10)},
+			},
+		},
+		{
+			`UPDATE users SET active = FALSE, name = ? WHERE id = ?`,
+			&Update{
+				Table: "users",
+				Set:   []Assignment{{"active", Literal{false}}, {"name", Placeholder{0}}},
+				Where: &Comparison{Column: "id", Op: query.OpEqual, Values: []Operand{Placeholder{1}}},
+			},
+		},
+		{`DELETE FROM users`, &Delete{Table: "users"}},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.input)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.input, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %#v, want %#v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParse_Where(t *testing.T) {
+	stmt, err := Parse(`SELECT * FROM t WHERE a = 1 OR NOT (b BETWEEN 1 AND 5 AND c NOT IN ('x', 'y')) AND d IS NOT NULL`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	one := func(v any) []Operand { return []Operand{Literal{v}} }
+	want := &Logical{Op: query.OpOr, Operands: []Expr{
+		&Comparison{Column: "a", Op: query.OpEqual, Values: one(int64(1))},
+		&Logical{Op: query.OpAnd, Operands: []Expr{
+			&Logical{Op: query.OpNot, Operands: []Expr{
+				&Logical{Op: query.OpAnd, Operands: []Expr{
+					&Comparison{Column: "b", Op: query.OpBetween, Values: []Operand{Literal{int64(1)}, Literal{int64(5)}}},
+					&Logical{Op: query.OpNot, Operands: []Expr{
+						&Comparison{Column: "c", Op: query.OpIn, Values: []Operand{Literal{"x"}, Literal{"y"}}},
+					}},
+				}},
+			}},
+			&Comparison{Column: "d", Op: query.OpIsNotNull},
+		}},
+	}}
+	if got := stmt.(*Select).Where; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Where = %#v", got)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, input := range []string{
+		``,
+		`SELECT FROM t`,
+		`SELECT * FROM t WHERE`,
+		`SELECT * FROM t WHERE a LIKE 'x'`,
+		`SELECT * FROM t LIMIT 1 extra`,
+		`INSERT INTO t (a, b) VALUES (1)`,
+		`INSERT INTO t VALUES (1)`,
+		`CREATE TABLE t (id MONEY)`,
+		`SELECT * FROM t WHERE a = 'open`,
+		`SELECT * FROM t WHERE a = #`,
+	} {
+		_, err := Parse(input)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("Parse(%q) error = %v, want *SyntaxError", input, err)
+		}
+	}
+}
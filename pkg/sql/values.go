@@ -0,0 +1,201 @@
+package sql
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// bindArgs normalizes the arguments of Exec to the literal types:
+// integers become int64 and float32 float64. Strings, bools, nil,
+// time.Time and []byte pass as they are.
+func bindArgs(args []any) ([]any, error) {
+	bound := make([]any, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case nil, int64, float64, string, bool, time.Time, []byte:
+			bound[i] = v
+		case int:
+			bound[i] = int64(v)
+		case int8:
+			bound[i] = int64(v)
+		case int16:
+			bound[i] = int64(v)
+		case int32:
+			bound[i] = int64(v)
+		case uint8:
+			bound[i] = int64(v)
+		case uint16:
+			bound[i] = int64(v)
+		case uint32:
+			bound[i] = int64(v)
+		case uint:
+			if uint64(v) > math.MaxInt64 {
+				return nil, fmt.Errorf("sql: argument %d overflows int64", i+1)
+			}
+			bound[i] = int64(v)
+		case uint64:
+			if v > math.MaxInt64 {
+				return nil, fmt.Errorf("sql: argument %d overflows int64", i+1)
+			}
+			bound[i] = int64(v)
+		case float32:
+			bound[i] = float64(v)
+		default:
+			return nil, fmt.Errorf("sql: unsupported argument %d of type %T", i+1, arg)
+		}
+	}
+	return bound, nil
+}
+
+// value resolves an operand against the bound arguments.
+func value(op Operand, args []any) (any, error) {
+	switch op := op.(type) {
+	case Literal:
+		return op.Value, nil
+	case Placeholder:
+		if op.Index >= len(args) {
+			return nil, fmt.Errorf("sql: missing argument %d (%d given)", op.Index+1, len(args))
+		}
+		return args[op.Index], nil
+	}
+	return nil, fmt.Errorf("sql: unknown operand %T", op)
+}
+
+// count resolves the operand of LIMIT or OFFSET.
+func count(op Operand, args []any, clause string) (int, error) {
+	v, err := value(op, args)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := v.(int64)
+	if !ok || n < 0 || n > math.MaxInt32 {
+		return 0, fmt.Errorf("sql: %s must be a non-negative integer, got %v", clause, v)
+	}
+	return int(n), nil
+}
+
+// keyFor converts v to a key of an index of type t.
+func keyFor(t storage.DataType, v any) (types.Comparable, error) {
+	if v == nil {
+		return types.NullKey{}, nil
+	}
+	switch t {
+	case storage.TypeInt:
+		switch v := v.(type) {
+		case int64:
+			return types.IntKey(v), nil
+		case float64:
+			if v == math.Trunc(v) && math.Abs(v) < 1<<63 {
+				return types.IntKey(v), nil
+			}
+		}
+	case storage.TypeVarchar:
+		if s, ok := v.(string); ok {
+			return types.VarcharKey(s), nil
+		}
+	case storage.TypeBoolean:
+		if b, ok := v.(bool); ok {
+			return types.BoolKey(b), nil
+		}
+	case storage.TypeFloat:
+		switch v := v.(type) {
+		case int64:
+			return types.FloatKey(v), nil
+		case float64:
+			return types.FloatKey(v), nil
+		}
+	case storage.TypeDate:
+		switch v := v.(type) {
+		case time.Time:
+			return types.DateKey(v), nil
+		case string:
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return nil, fmt.Errorf("sql: invalid DATE %q: want RFC 3339", v)
+			}
+			return types.DateKey(t), nil
+		}
+	case storage.TypeDecimal:
+		switch v := v.(type) {
+		case int64:
+			return types.ParseDecimal(strconv.FormatInt(v, 10))
+		case float64:
+			return types.ParseDecimal(strconv.FormatFloat(v, 'f', -1, 64))
+		case string:
+			return types.ParseDecimal(v)
+		}
+	case storage.TypeUUID:
+		if s, ok := v.(string); ok {
+			return types.ParseUUID(s)
+		}
+	case storage.TypeBytes:
+		switch v := v.(type) {
+		case []byte:
+			return types.BytesKey(v), nil
+		case string:
+			return types.BytesKey(v), nil
+		}
+	}
+	return nil, fmt.Errorf("sql: cannot use %v (%T) as a %v value", v, v, t)
+}
+
+// naturalKey is the key of v as a document field without an index: the
+// type of the value decides.
+func naturalKey(v any) (types.Comparable, error) {
+	switch v := v.(type) {
+	case nil:
+		return types.NullKey{}, nil
+	case int64:
+		return types.IntKey(v), nil
+	case float64:
+		return types.FloatKey(v), nil
+	case string:
+		return types.VarcharKey(v), nil
+	case bool:
+		return types.BoolKey(v), nil
+	case time.Time:
+		return types.DateKey(v), nil
+	case []byte:
+		return types.BytesKey(v), nil
+	}
+	return nil, fmt.Errorf("sql: unsupported value %v (%T)", v, v)
+}
+
+// goValue is the inverse of keyFor and naturalKey, for keys read back from
+// a document.
+func goValue(key types.Comparable) any {
+	switch k := key.(type) {
+	case types.IntKey:
+		return int64(k)
+	case types.FloatKey:
+		return float64(k)
+	case types.VarcharKey:
+		return string(k)
+	case types.BoolKey:
+		return bool(k)
+	case types.DateKey:
+		return time.Time(k)
+	case types.BytesKey:
+		return []byte(k)
+	}
+	return nil
+}
+
+// jsonValue encodes v as the JSON of a document field. Dates and bytes use
+// extended JSON, so they are stored with their BSON types.
+func jsonValue(v any) (json.RawMessage, error) {
+	switch v := v.(type) {
+	case time.Time:
+		return json.RawMessage(fmt.Sprintf(`{"$date":{"$numberLong":"%d"}}`, v.UnixMilli())), nil
+	case []byte:
+		return json.RawMessage(fmt.Sprintf(`{"$binary":{"base64":"%s","subType":"00"}}`, base64.StdEncoding.EncodeToString(v))), nil
+	}
+	return json.Marshal(v)
+}
@@ -0,0 +1,343 @@
+// Package sqldriver is a database/sql driver for the SQL dialect of
+// pkg/sql. Importing it registers the driver as "storageengine":
+//
+//	import _ "github.com/bobboyms/storage-engine/pkg/sqldriver"
+//
+//	db, err := sql.Open("storageengine", "data") // or ":memory:"
+//
+// The data source name is the data directory given to storage.Open;
+// ":memory:" opens an in-memory engine. The engine is opened once per
+// sql.DB and closed by DB.Close. OpenDB serves an engine the application
+// already opened instead.
+//
+// Statements take "?" placeholders. Query returns the columns of the
+// SELECT, each row's values decoded from its JSON document: integers as
+// int64, other numbers as float64, strings, bools, NULL as nil, dates as
+// time.Time, bytes as []byte and nested documents or arrays as their JSON
+// text. Transactions are not supported: Begin fails, since UPDATE and
+// DELETE of pkg/sql apply row by row.
+package sqldriver
+
+import (
+	"context"
+	gosql "database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bobboyms/storage-engine/pkg/sql"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+)
+
+// DriverName is the name the driver is registered under.
+const DriverName = "storageengine"
+
+// MemoryDSN opens an in-memory engine.
+const MemoryDSN = ":memory:"
+
+// ErrTransactionsUnsupported is returned by Begin.
+var ErrTransactionsUnsupported = errors.New("sqldriver: transactions are not supported")
+
+func init() {
+	gosql.Register(DriverName, &Driver{})
+}
+
+// Driver is the database/sql driver.
+type Driver struct{}
+
+// Open opens an engine for one connection, closed with it. database/sql
+// uses OpenConnector instead, which shares one engine among the
+// connections of a sql.DB.
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	c, err := d.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{db: c.(*connector).db, owned: c.(*connector)}, nil
+}
+
+// OpenConnector opens the engine of dsn.
+func (d *Driver) OpenConnector(dsn string) (driver.Connector, error) {
+	opts := storage.Options{}
+	if dsn == MemoryDSN {
+		dsn, opts.InMemory = "", true
+	}
+	engine, err := storage.Open(dsn, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &connector{driver: d, db: sql.New(engine), engine: engine}, nil
+}
+
+// OpenDB returns a sql.DB over an engine the caller owns: closing the
+// sql.DB leaves the engine open.
+func OpenDB(engine *storage.StorageEngine) *gosql.DB {
+	return gosql.OpenDB(&connector{driver: &Driver{}, db: sql.New(engine)})
+}
+
+type connector struct {
+	driver *Driver
+	db     *sql.DB
+	engine *storage.StorageEngine // nil when the caller owns the engine
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{db: c.db}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// Close closes the engine opened by OpenConnector; sql.DB.Close calls it.
+func (c *connector) Close() error {
+	if c.engine == nil {
+		return nil
+	}
+	return c.engine.Close()
+}
+
+type conn struct {
+	db    *sql.DB
+	owned io.Closer // the engine of a connection made by Driver.Open
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(_ context.Context, query string) (driver.Stmt, error) {
+	stmt, err := sql.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return &statement{db: c.db, stmt: stmt}, nil
+}
+
+func (c *conn) Close() error {
+	if c.owned != nil {
+		return c.owned.Close()
+	}
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return nil, ErrTransactionsUnsupported
+}
+
+func (c *conn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return nil, ErrTransactionsUnsupported
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	stmt, err := sql.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return exec(ctx, c.db, stmt, args)
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	stmt, err := sql.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return queryRows(ctx, c.db, stmt, args)
+}
+
+type statement struct {
+	db   *sql.DB
+	stmt sql.Statement
+}
+
+func (s *statement) Close() error {
+	return nil
+}
+
+// NumInput returns -1: pkg/sql checks the arguments when it runs.
+func (s *statement) NumInput() int {
+	return -1
+}
+
+func (s *statement) Exec(args []driver.Value) (driver.Result, error) {
+	return exec(context.Background(), s.db, s.stmt, namedValues(args))
+}
+
+func (s *statement) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return exec(ctx, s.db, s.stmt, args)
+}
+
+func (s *statement) Query(args []driver.Value) (driver.Rows, error) {
+	return queryRows(context.Background(), s.db, s.stmt, namedValues(args))
+}
+
+func (s *statement) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return queryRows(ctx, s.db, s.stmt, args)
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
+
+// bind orders the arguments for the placeholders; "?" has no names.
+func bind(args []driver.NamedValue) ([]any, error) {
+	values := make([]any, len(args))
+	for _, arg := range args {
+		if arg.Name != "" {
+			return nil, fmt.Errorf("sqldriver: named argument %s is not supported", arg.Name)
+		}
+		values[arg.Ordinal-1] = arg.Value
+	}
+	return values, nil
+}
+
+func run(ctx context.Context, db *sql.DB, stmt sql.Statement, args []driver.NamedValue) (*sql.Result, error) {
+	values, err := bind(args)
+	if err != nil {
+		return nil, err
+	}
+	return db.ExecStatement(ctx, stmt, values...)
+}
+
+func exec(ctx context.Context, db *sql.DB, stmt sql.Statement, args []driver.NamedValue) (driver.Result, error) {
+	res, err := run(ctx, db, stmt, args)
+	if err != nil {
+		return nil, err
+	}
+	return result(res.RowsAffected), nil
+}
+
+func queryRows(ctx context.Context, db *sql.DB, stmt sql.Statement, args []driver.NamedValue) (driver.Rows, error) {
+	res, err := run(ctx, db, stmt, args)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{columns: res.Columns, docs: res.Rows}, nil
+}
+
+type result int
+
+func (r result) LastInsertId() (int64, error) {
+	return 0, errors.New("sqldriver: LastInsertId is not supported")
+}
+
+func (r result) RowsAffected() (int64, error) {
+	return int64(r), nil
+}
+
+type rows struct {
+	columns []string
+	docs    []string
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	r.docs = nil
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.docs) == 0 {
+		return io.EOF
+	}
+	doc := r.docs[0]
+	r.docs = r.docs[1:]
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(doc), &fields); err != nil {
+		return fmt.Errorf("sqldriver: cannot decode row: %w", err)
+	}
+	for i, column := range r.columns {
+		raw, ok := lookup(fields, column)
+		if !ok {
+			dest[i] = nil
+			continue
+		}
+		v, err := decodeValue(raw)
+		if err != nil {
+			return fmt.Errorf("sqldriver: column %s: %w", column, err)
+		}
+		dest[i] = v
+	}
+	return nil
+}
+
+// lookup follows a dotted column through nested documents; a field named
+// like the whole column wins, as in the engine.
+func lookup(fields map[string]json.RawMessage, column string) (json.RawMessage, bool) {
+	if raw, ok := fields[column]; ok {
+		return raw, true
+	}
+	name, rest, nested := strings.Cut(column, ".")
+	if !nested {
+		return nil, false
+	}
+	var inner map[string]json.RawMessage
+	if raw, ok := fields[name]; !ok || json.Unmarshal(raw, &inner) != nil {
+		return nil, false
+	}
+	return lookup(inner, rest)
+}
+
+// decodeValue converts one field of a relaxed extended JSON document to a
+// driver.Value.
+func decodeValue(raw json.RawMessage) (driver.Value, error) {
+	dec := json.NewDecoder(strings.NewReader(string(raw)))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	switch v := v.(type) {
+	case nil, string, bool:
+		return v, nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	case map[string]any:
+		if date, ok := v["$date"]; ok && len(v) == 1 {
+			return decodeDate(date)
+		}
+		if binary, ok := v["$binary"].(map[string]any); ok && len(v) == 1 {
+			if data, ok := binary["base64"].(string); ok {
+				return base64.StdEncoding.DecodeString(data)
+			}
+		}
+	}
+	return string(raw), nil
+}
+
+// decodeDate reads the value of "$date": an RFC 3339 string, or the
+// milliseconds since the epoch as a number or {"$numberLong": "<ms>"}.
+func decodeDate(date any) (driver.Value, error) {
+	if d, ok := date.(map[string]any); ok {
+		date = d["$numberLong"]
+	}
+	switch d := date.(type) {
+	case string:
+		if ms, err := strconv.ParseInt(d, 10, 64); err == nil {
+			return time.UnixMilli(ms).UTC(), nil
+		}
+		return time.Parse(time.RFC3339Nano, d)
+	case json.Number:
+		if ms, err := d.Int64(); err == nil {
+			return time.UnixMilli(ms).UTC(), nil
+		}
+	}
+	return nil, fmt.Errorf("invalid $date %v", date)
+}
@@ -0,0 +1,119 @@
+package sqldriver_test
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bobboyms/storage-engine/pkg/sqldriver"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+func TestDriver_QueryAndExec(t *testing.T) {
+	db, err := sql.Open(sqldriver.DriverName, sqldriver.MemoryDSN)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`CREATE TABLE users (id INT PRIMARY KEY, email VARCHAR)`); err != nil {
+		t.Fatal(err)
+	}
+	joined := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	insert, err := db.Prepare(`INSERT INTO users (id, email, joined, score) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer insert.Close()
+	for i, email := range []string{"ana@example.com", "bia@example.com", "caio@example.com"} {
+		if _, err := insert.Exec(i+1, email, joined, 1.5*float64(i)); err != nil {
+			t.Fatalf("insert %d: %v", i+1, err)
+		}
+	}
+
+	rows, err := db.Query(`SELECT id, email, joined, score, missing FROM users WHERE id >= ? ORDER BY email DESC`, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []int64
+	for rows.Next() {
+		var (
+			id      int64
+			email   string
+			when    time.Time
+			score   float64
+			missing sql.NullString
+		)
+		if err := rows.Scan(&id, &email, &when, &score, &missing); err != nil {
+			t.Fatal(err)
+		}
+		if !when.Equal(joined) || missing.Valid {
+			t.Fatalf("row %d: joined %v, missing %v", id, when, missing)
+		}
+		got = append(got, id)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != 3 || got[1] != 2 {
+		t.Fatalf("ids = %v, want [3 2]", got)
+	}
+
+	res, err := db.Exec(`UPDATE users SET email = ? WHERE id = ?`, "ana@new.example.com", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		t.Fatalf("RowsAffected = %d, want 1", n)
+	}
+	var email string
+	if err := db.QueryRow(`SELECT email FROM users WHERE id = 1`).Scan(&email); err != nil || email != "ana@new.example.com" {
+		t.Fatalf("email = %q, %v", email, err)
+	}
+	if _, err := db.Begin(); !errors.Is(err, sqldriver.ErrTransactionsUnsupported) {
+		t.Fatalf("Begin error = %v", err)
+	}
+	if _, err := db.Exec(`SELEC 1`); err == nil {
+		t.Fatal("a syntax error should fail")
+	}
+}
+
+func TestDriver_DataDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	db, err := sql.Open(sqldriver.DriverName, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE kv (k VARCHAR PRIMARY KEY)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO kv (k, v) VALUES ('a', 1)`); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Close released the engine: the directory opens again.
+	engine, err := storage.Open(dir, storage.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+	shared := sqldriver.OpenDB(engine)
+	var v int64
+	if err := shared.QueryRow(`SELECT v FROM kv WHERE k = 'a'`).Scan(&v); err != nil || v != 1 {
+		t.Fatalf("v = %d, %v", v, err)
+	}
+	if err := shared.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// The engine belongs to the caller and stays open.
+	if _, found, err := engine.Get("kv", "k", types.VarcharKey("a")); err != nil || !found {
+		t.Fatalf("Get after closing the sql.DB = %v, %v", found, err)
+	}
+}
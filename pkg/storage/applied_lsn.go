@@ -24,8 +24,11 @@ func (t *AppliedLSNTracker) Get(tableName, indexName string) uint64 {
 }
 
 func (t *AppliedLSNTracker) MarkApplied(tableName, indexName string, lsn uint64) {
-	key := appliedLSNKey(tableName, indexName)
+	t.markKey(appliedLSNKey(tableName, indexName), lsn)
+}
 
+// markKey is MarkApplied for a key built by appliedLSNKey.
+func (t *AppliedLSNTracker) markKey(key string, lsn uint64) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
@@ -41,3 +44,15 @@ func (t *AppliedLSNTracker) Set(tableName, indexName string, lsn uint64) {
 	defer t.mu.Unlock()
 	t.byIndex[key] = lsn
 }
+
+// Snapshot returns a copy of the applied LSN of every index, keyed by
+// appliedLSNKey.
+func (t *AppliedLSNTracker) Snapshot() map[string]uint64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	out := make(map[string]uint64, len(t.byIndex))
+	for key, lsn := range t.byIndex {
+		out[key] = lsn
+	}
+	return out
+}
@@ -20,14 +20,20 @@ const (
 	backupManifestName = "manifest.json"
 	backupFilesDirName = "files"
 	backupManifestVer  = 1
+	// backupManifestIncrementalVer marks incremental backups, which
+	// versions without support for them refuse.
+	backupManifestIncrementalVer = 2
 )
 
 type BackupManifest struct {
-	Version       int          `json:"version"`
-	CreatedAtUTC  time.Time    `json:"created_at_utc"`
-	SourceRoot    string       `json:"source_root"`
-	CheckpointLSN uint64       `json:"checkpoint_lsn"`
-	Files         []BackupFile `json:"files"`
+	Version       int       `json:"version"`
+	CreatedAtUTC  time.Time `json:"created_at_utc"`
+	SourceRoot    string    `json:"source_root"`
+	CheckpointLSN uint64    `json:"checkpoint_lsn"`
+	// Incremental backups hold only what changed after SinceLSN.
+	Incremental bool         `json:"incremental,omitempty"`
+	SinceLSN    uint64       `json:"since_lsn,omitempty"`
+	Files       []BackupFile `json:"files"`
 }
 
 type BackupFile struct {
@@ -35,6 +41,10 @@ type BackupFile struct {
 	Path   string `json:"path"`
 	Size   int64  `json:"size"`
 	SHA256 string `json:"sha256"`
+	// PageDelta: the file holds only the changed pages (see
+	// BackupIncremental); SourceSize is the size of the original.
+	PageDelta  bool  `json:"page_delta,omitempty"`
+	SourceSize int64 `json:"source_size,omitempty"`
 }
 
 type backupSourceFile struct {
@@ -49,6 +59,12 @@ type pathProvider interface {
 // BackupOnline cria um snapshot consistente do engine com ele aberto.
 // Escritas ficam pausadas durante o checkpoint/cópia; reads continuam.
 func (se *StorageEngine) BackupOnline(backupDir string) (*BackupManifest, error) {
+	return se.backupOnline(backupDir, nil)
+}
+
+// backupOnline copies whole files, or only what changed after *sinceLSN
+// in an incremental backup.
+func (se *StorageEngine) backupOnline(backupDir string, sinceLSN *uint64) (*BackupManifest, error) {
 	if backupDir == "" {
 		return nil, fmt.Errorf("backup: backupDir empty")
 	}
@@ -96,6 +112,11 @@ func (se *StorageEngine) BackupOnline(backupDir string) (*BackupManifest, error)
 		CheckpointLSN: se.lsnTracker.Current(),
 		Files:         make([]BackupFile, 0, len(sources)),
 	}
+	if sinceLSN != nil {
+		manifest.Version = backupManifestIncrementalVer
+		manifest.Incremental = true
+		manifest.SinceLSN = *sinceLSN
+	}
 
 	for _, src := range sources {
 		abs, err := filepath.Abs(src.path)
@@ -111,6 +132,17 @@ func (se *StorageEngine) BackupOnline(backupDir string) (*BackupManifest, error)
 		}
 
 		dst := filepath.Join(filesDir, rel)
+		if sinceLSN != nil {
+			file, copied, err := se.copyIncrementalFile(src, dst, *sinceLSN)
+			if err != nil {
+				return nil, fmt.Errorf("backup: copiar %s: %w", src.path, err)
+			}
+			if copied {
+				file.Path = filepath.ToSlash(rel)
+				manifest.Files = append(manifest.Files, file)
+			}
+			continue
+		}
 		size, sum, err := copyFileWithHash(src.path, dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY)
 		if err != nil {
 			return nil, fmt.Errorf("backup: copiar %s: %w", src.path, err)
@@ -146,7 +178,7 @@ func VerifyBackup(backupDir string) (*BackupManifest, error) {
 	if err != nil {
 		return nil, err
 	}
-	if manifest.Version != backupManifestVer {
+	if manifest.Version != backupManifestVer && (!manifest.Incremental || manifest.Version != backupManifestIncrementalVer) {
 		return nil, fmt.Errorf("backup: unsupported manifest version: %d", manifest.Version)
 	}
 	if len(manifest.Files) == 0 {
@@ -189,6 +221,9 @@ func RestoreBackup(backupDir, targetDir string) (*BackupManifest, error) {
 	if err != nil {
 		return nil, err
 	}
+	if manifest.Incremental {
+		return nil, fmt.Errorf("restore: %s is an incremental backup: use RestoreBackupChain", backupDir)
+	}
 	if err := os.MkdirAll(targetDir, 0700); err != nil {
 		return nil, err
 	}
@@ -260,12 +295,14 @@ func (se *StorageEngine) backupSourceFiles() ([]backupSourceFile, error) {
 			if idx.Tree == nil {
 				continue
 			}
-			pathed, ok := idx.Tree.(pathProvider)
-			if !ok {
+			paths := indexFilePaths(idx.Tree)
+			if len(paths) == 0 {
 				return nil, fmt.Errorf("backup: index %s.%s does not expose Path()", tableName, idx.Name)
 			}
-			if err := add("index:"+tableName+"."+idx.Name, pathed.Path()); err != nil {
-				return nil, err
+			for _, path := range paths {
+				if err := add("index:"+tableName+"."+idx.Name, path); err != nil {
+					return nil, err
+				}
 			}
 		}
 	}
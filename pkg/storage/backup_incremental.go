@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bobboyms/storage-engine/pkg/pagestore"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+// BackupIncremental copies only what changed after sinceLSN, usually the
+// CheckpointLSN of the previous backup (full or incremental):
+//
+//   - of heaps and indexes, the pages with PageLSN > sinceLSN, plus the
+//     meta pages and the pages without an LSN, which change without
+//     advancing it;
+//   - of the WAL, the segments with records after sinceLSN and the active
+//     file.
+//
+// Like BackupOnline, it takes a checkpoint with writes paused. Restore it
+// with RestoreBackupChain on top of the full backup.
+func (se *StorageEngine) BackupIncremental(backupDir string, sinceLSN uint64) (*BackupManifest, error) {
+	return se.backupOnline(backupDir, &sinceLSN)
+}
+
+// copyIncrementalFile copies to dst the part of src that changed after
+// sinceLSN. copied is false when nothing changed.
+func (se *StorageEngine) copyIncrementalFile(src backupSourceFile, dst string, sinceLSN uint64) (BackupFile, bool, error) {
+	file := BackupFile{Role: src.role}
+	if strings.HasPrefix(src.role, "wal") {
+		active, err := filepath.Abs(se.WAL.Path())
+		if err != nil {
+			return file, false, err
+		}
+		if src.path != active {
+			maxLSN, ok, err := wal.SegmentMaxLSN(src.path, se.walCipher())
+			if err != nil {
+				return file, false, err
+			}
+			if ok && maxLSN <= sinceLSN {
+				return file, false, nil
+			}
+		}
+	} else {
+		info, err := os.Stat(src.path)
+		if err != nil {
+			return file, false, err
+		}
+		if info.Size()%pagestore.PageSize == 0 {
+			size, sum, err := copyPageDelta(src.path, dst, sinceLSN)
+			if err != nil {
+				return file, false, err
+			}
+			file.Size, file.SHA256 = size, sum
+			file.PageDelta, file.SourceSize = true, info.Size()
+			return file, true, nil
+		}
+	}
+
+	size, sum, err := copyFileWithHash(src.path, dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY)
+	if err != nil {
+		return file, false, err
+	}
+	file.Size, file.SHA256 = size, sum
+	return file, true, nil
+}
+
+// A page delta is a sequence of (PageID uint64, page of PageSize bytes).
+const pageDeltaRecordSize = 8 + pagestore.PageSize
+
+// copyPageDelta writes to dst the pages of src changed after sinceLSN.
+func copyPageDelta(src, dst string, sinceLSN uint64) (int64, string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, "", err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return 0, "", err
+	}
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, "", err
+	}
+
+	h := sha256.New()
+	w := io.MultiWriter(out, h)
+	var size int64
+	copyErr := func() error {
+		var record [pageDeltaRecordSize]byte
+		page := record[8:]
+		for pageID := uint64(0); ; pageID++ {
+			if _, err := io.ReadFull(in, page); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if !pageChangedSince(page, sinceLSN) {
+				continue
+			}
+			binary.LittleEndian.PutUint64(record[:8], pageID)
+			if _, err := w.Write(record[:]); err != nil {
+				return err
+			}
+			size += pageDeltaRecordSize
+		}
+	}()
+	syncErr := out.Sync()
+	closeErr := out.Close()
+	for _, err := range []error{copyErr, syncErr, closeErr} {
+		if err != nil {
+			_ = os.Remove(tmp)
+			return 0, "", err
+		}
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		_ = os.Remove(tmp)
+		return 0, "", err
+	}
+	if err := syncDirectory(filepath.Dir(dst)); err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// pageChangedSince decides from the plaintext header, which does not need
+// the TDE key. Zeroed (preallocated) pages are left out; unreadable ones
+// are included.
+func pageChangedSince(page []byte, sinceLSN uint64) bool {
+	var hdr pagestore.PageHeader
+	if err := hdr.Decode(page); err != nil || hdr.Magic != pagestore.MagicV1 {
+		for _, b := range page {
+			if b != 0 {
+				return true
+			}
+		}
+		return false
+	}
+	return hdr.PageLSN > sinceLSN || hdr.PageLSN == 0 || hdr.Type == pagestore.PageTypeMeta
+}
+
+// RestoreBackupChain restores the full backup baseDir into targetDir and
+// applies on top of it, in order, the incremental backups incrementDirs.
+// Each increment must start (SinceLSN) at or before the CheckpointLSN of
+// the previous one. It returns the manifest of the last backup applied.
+func RestoreBackupChain(targetDir, baseDir string, incrementDirs ...string) (*BackupManifest, error) {
+	manifests := make([]*BackupManifest, 0, len(incrementDirs))
+	prev, err := VerifyBackup(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	if prev.Incremental {
+		return nil, fmt.Errorf("restore: base %s is an incremental backup", baseDir)
+	}
+	for _, dir := range incrementDirs {
+		manifest, err := VerifyBackup(dir)
+		if err != nil {
+			return nil, err
+		}
+		if !manifest.Incremental {
+			return nil, fmt.Errorf("restore: %s is not an incremental backup", dir)
+		}
+		if manifest.SinceLSN > prev.CheckpointLSN {
+			return nil, fmt.Errorf("restore: gap in backup chain: %s starts after LSN %d, previous backup ends at %d", dir, manifest.SinceLSN, prev.CheckpointLSN)
+		}
+		manifests = append(manifests, manifest)
+		prev = manifest
+	}
+
+	last, err := RestoreBackup(baseDir, targetDir)
+	if err != nil {
+		return nil, err
+	}
+	for i, manifest := range manifests {
+		if err := applyIncrementalBackup(incrementDirs[i], targetDir, manifest); err != nil {
+			return nil, err
+		}
+		last = manifest
+	}
+	return last, nil
+}
+
+func applyIncrementalBackup(backupDir, targetDir string, manifest *BackupManifest) error {
+	filesDir := filepath.Join(backupDir, backupFilesDirName)
+	for _, file := range manifest.Files {
+		rel := filepath.FromSlash(file.Path)
+		if err := validateBackupRelPath(rel); err != nil {
+			return err
+		}
+		src := filepath.Join(filesDir, rel)
+		dst := filepath.Join(targetDir, rel)
+		if file.PageDelta {
+			if err := applyPageDelta(src, dst, file.SourceSize); err != nil {
+				return fmt.Errorf("restore: apply %s: %w", file.Path, err)
+			}
+			continue
+		}
+		size, sum, err := copyFileWithHash(src, dst, os.O_CREATE|os.O_WRONLY)
+		if err != nil {
+			return fmt.Errorf("restore: copy %s: %w", file.Path, err)
+		}
+		if size != file.Size || sum != file.SHA256 {
+			return fmt.Errorf("restore: post-copy verification failed for %s", file.Path)
+		}
+	}
+	return syncDirectory(targetDir)
+}
+
+// applyPageDelta writes the pages of the delta to dst, sized to
+// sourceSize.
+func applyPageDelta(deltaPath, dst string, sourceSize int64) error {
+	in, err := os.Open(deltaPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := out.Truncate(sourceSize); err != nil {
+		return err
+	}
+
+	var record [pageDeltaRecordSize]byte
+	for {
+		if _, err := io.ReadFull(in, record[:]); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		offset := int64(binary.LittleEndian.Uint64(record[:8])) * pagestore.PageSize
+		if offset+pagestore.PageSize > sourceSize {
+			return fmt.Errorf("page at offset %d beyond file size %d", offset, sourceSize)
+		}
+		if _, err := out.WriteAt(record[8:], offset); err != nil {
+			return err
+		}
+	}
+	return out.Sync()
+}
@@ -244,3 +244,73 @@ func TestRestoreBackupDoesNotOverwriteExistingFiles(t *testing.T) {
 		t.Fatal("RestoreBackup should recusar sobrescrever arquivo existsnte")
 	}
 }
+
+func TestIncrementalBackupRestoreChain(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "db")
+	db := newBackupTestDB(t, src)
+	defer db.engine.Close()
+
+	padding := strings.Repeat("x", 200)
+	for i := int64(1); i <= 200; i++ {
+		putAccount(t, db.engine, i, fmt.Sprintf("user-%d-%s@example.com", i, padding))
+	}
+	baseDir := filepath.Join(t.TempDir(), "base")
+	base, err := db.engine.BackupOnline(baseDir)
+	if err != nil {
+		t.Fatalf("BackupOnline: %v", err)
+	}
+
+	putAccount(t, db.engine, 201, "first-increment@example.com")
+	inc1Dir := filepath.Join(t.TempDir(), "inc1")
+	inc1, err := db.engine.BackupIncremental(inc1Dir, base.CheckpointLSN)
+	if err != nil {
+		t.Fatalf("BackupIncremental 1: %v", err)
+	}
+	if !inc1.Incremental || inc1.SinceLSN != base.CheckpointLSN {
+		t.Fatalf("unexpected incremental manifest: %+v", inc1)
+	}
+	for _, file := range inc1.Files {
+		if file.Role == "heap:accounts" && (!file.PageDelta || file.Size >= file.SourceSize) {
+			t.Fatalf("heap should hold only the changed pages: %+v", file)
+		}
+	}
+
+	if _, err := db.engine.DeleteRow("accounts", types.IntKey(1)); err != nil {
+		t.Fatalf("DeleteRow: %v", err)
+	}
+	putAccount(t, db.engine, 2, "updated@example.com")
+	inc2Dir := filepath.Join(t.TempDir(), "inc2")
+	inc2, err := db.engine.BackupIncremental(inc2Dir, inc1.CheckpointLSN)
+	if err != nil {
+		t.Fatalf("BackupIncremental 2: %v", err)
+	}
+	putAccount(t, db.engine, 202, "after-backups@example.com")
+
+	if _, err := RestoreBackup(inc1Dir, filepath.Join(t.TempDir(), "alone")); err == nil {
+		t.Fatal("RestoreBackup should refuse an incremental backup")
+	}
+	if _, err := RestoreBackupChain(filepath.Join(t.TempDir(), "gap"), baseDir, inc2Dir); err == nil {
+		t.Fatal("RestoreBackupChain should refuse a chain with a gap")
+	}
+
+	restoreDir := filepath.Join(t.TempDir(), "restore")
+	last, err := RestoreBackupChain(restoreDir, baseDir, inc1Dir, inc2Dir)
+	if err != nil {
+		t.Fatalf("RestoreBackupChain: %v", err)
+	}
+	if last.CheckpointLSN != inc2.CheckpointLSN {
+		t.Fatalf("expected the last manifest, got checkpoint %d", last.CheckpointLSN)
+	}
+
+	restored := reopenBackupTestDB(t, restoreDir)
+	defer restored.Close()
+	for id, want := range map[int64]string{1: "", 2: "updated@example.com", 3: "user-3-", 201: "first-increment@example.com", 202: ""} {
+		got, ok, err := restored.Get("accounts", "id", types.IntKey(id))
+		if err != nil {
+			t.Fatalf("Get restored %d: %v", id, err)
+		}
+		if ok != (want != "") || !strings.Contains(got, want) {
+			t.Fatalf("account %d: expected %q, got ok=%v %s", id, want, ok, got)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/bobboyms/storage-engine/pkg/bitmap"
+	"github.com/bobboyms/storage-engine/pkg/btree"
+	btreev2 "github.com/bobboyms/storage-engine/pkg/btree/v2"
+	"github.com/bobboyms/storage-engine/pkg/pagestore"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// A bitmap index (Index.Bitmap) is meant for low-cardinality columns such
+// as a status or a flag. Its postings stay in the posting tree, which is
+// what is logged, checkpointed and recovered; on top of it every distinct
+// value keeps a compressed bitmap of the row versions under it, built on
+// first use and kept current by the posting writes. ScanBitmaps combines
+// the bitmaps of several indexes with AND/OR before touching the heap.
+
+// bitmapPostings is the tree of a bitmap index. It embeds the posting
+// tree, so every interface the engine asserts on index trees still holds.
+type bitmapPostings struct {
+	*btreev2.PostingTree
+
+	// mu orders the posting writes with the bitmap builds, so a bitmap is
+	// never built from a posting list a concurrent write is changing.
+	mu    sync.Mutex
+	cache map[string]*bitmap.Bitmap
+
+	// collation makes keys the index holds equal share one bitmap.
+	collation types.Collation
+}
+
+// newBitmapTree wraps the tree of the bitmap index idx.
+func newBitmapTree(tableName string, idx *Index, tree btree.Tree) (*bitmapPostings, error) {
+	postings, ok := tree.(*btreev2.PostingTree)
+	if !ok {
+		return nil, fmt.Errorf("storage: bitmap index %s.%s must be a non-unique secondary index", tableName, idx.Name)
+	}
+	return &bitmapPostings{PostingTree: postings, cache: make(map[string]*bitmap.Bitmap), collation: idx.Collation}, nil
+}
+
+func (bp *bitmapPostings) cacheKey(key types.Comparable) string {
+	key = bp.collation.Apply(key)
+	return fmt.Sprintf("%T:%v", key, key)
+}
+
+// Bitmap returns the row versions stored under key. The result is a copy
+// the caller may change.
+func (bp *bitmapPostings) Bitmap(key types.Comparable) (*bitmap.Bitmap, error) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	cacheKey := bp.cacheKey(key)
+	if bm, ok := bp.cache[cacheKey]; ok {
+		return bm.Clone(), nil
+	}
+	recordIDs, err := bp.PostingTree.GetAll(key)
+	if err != nil {
+		return nil, err
+	}
+	bm := bitmap.New()
+	for _, rid := range recordIDs {
+		bm.Add(uint64(rid))
+	}
+	bp.cache[cacheKey] = bm
+	return bm.Clone(), nil
+}
+
+func (bp *bitmapPostings) added(key types.Comparable, value int64) {
+	if bm, ok := bp.cache[bp.cacheKey(key)]; ok {
+		bm.Add(uint64(value))
+	}
+}
+
+func (bp *bitmapPostings) removed(key types.Comparable, value int64) {
+	if bm, ok := bp.cache[bp.cacheKey(key)]; ok {
+		bm.Remove(uint64(value))
+	}
+}
+
+func (bp *bitmapPostings) InsertValue(key types.Comparable, value int64) error {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	if err := bp.PostingTree.InsertValue(key, value); err != nil {
+		return err
+	}
+	bp.added(key, value)
+	return nil
+}
+
+func (bp *bitmapPostings) InsertValueWithLSN(key types.Comparable, value int64, lsn uint64) error {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	if err := bp.PostingTree.InsertValueWithLSN(key, value, lsn); err != nil {
+		return err
+	}
+	bp.added(key, value)
+	return nil
+}
+
+func (bp *bitmapPostings) RemoveValue(key types.Comparable, value int64) (bool, error) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	removed, err := bp.PostingTree.RemoveValue(key, value)
+	if removed {
+		bp.removed(key, value)
+	}
+	return removed, err
+}
+
+func (bp *bitmapPostings) RemoveValueWithLSN(key types.Comparable, value int64, lsn uint64) (bool, error) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	removed, err := bp.PostingTree.RemoveValueWithLSN(key, value, lsn)
+	if removed {
+		bp.removed(key, value)
+	}
+	return removed, err
+}
+
+// The writes below replace whole posting lists; the bitmap of the key is
+// dropped and built again on the next read.
+
+func (bp *bitmapPostings) Insert(key types.Comparable, value int64) error {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	delete(bp.cache, bp.cacheKey(key))
+	return bp.PostingTree.Insert(key, value)
+}
+
+func (bp *bitmapPostings) Upsert(key types.Comparable, fn func(oldValue int64, exists bool) (int64, error)) error {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	delete(bp.cache, bp.cacheKey(key))
+	return bp.PostingTree.Upsert(key, fn)
+}
+
+func (bp *bitmapPostings) UpsertWithLSN(key types.Comparable, lsn uint64, fn func(oldValue int64, exists bool) (int64, error)) error {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	delete(bp.cache, bp.cacheKey(key))
+	return bp.PostingTree.UpsertWithLSN(key, lsn, fn)
+}
+
+func (bp *bitmapPostings) Replace(key types.Comparable, value int64) error {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	delete(bp.cache, bp.cacheKey(key))
+	return bp.PostingTree.Replace(key, value)
+}
+
+func (bp *bitmapPostings) ReplaceWithLSN(key types.Comparable, value int64, lsn uint64) error {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	delete(bp.cache, bp.cacheKey(key))
+	return bp.PostingTree.ReplaceWithLSN(key, value, lsn)
+}
+
+func (bp *bitmapPostings) Remove(key types.Comparable) (bool, error) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	delete(bp.cache, bp.cacheKey(key))
+	return bp.PostingTree.Remove(key)
+}
+
+func (bp *bitmapPostings) DeleteWithLSN(key types.Comparable, lsn uint64) (bool, error) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	delete(bp.cache, bp.cacheKey(key))
+	return bp.PostingTree.DeleteWithLSN(key, lsn)
+}
+
+// BulkLoad and page redo change the tree below the posting API, so every
+// bitmap is dropped.
+
+func (bp *bitmapPostings) BulkLoad(keys []types.Comparable, values []int64) error {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	clear(bp.cache)
+	return bp.PostingTree.BulkLoad(keys, values)
+}
+
+func (bp *bitmapPostings) ApplyPageRedo(pageID pagestore.PageID, page *pagestore.Page, lsn uint64) (bool, error) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	clear(bp.cache)
+	return bp.PostingTree.ApplyPageRedo(pageID, page, lsn)
+}
+
+func (bp *bitmapPostings) MemoryUsage() int64 {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	usage := bp.PostingTree.MemoryUsage()
+	for _, bm := range bp.cache {
+		usage += int64(bm.SizeInBytes())
+	}
+	return usage
+}
+
+// BitmapOp combines the terms of ScanBitmaps.
+type BitmapOp int
+
+const (
+	// BitmapAnd keeps the rows that match every term.
+	BitmapAnd BitmapOp = iota
+	// BitmapOr keeps the rows that match any term.
+	BitmapOr
+)
+
+// BitmapTerm selects the rows whose key in the bitmap index Index is any
+// of Values.
+type BitmapTerm struct {
+	Index  string
+	Values []types.Comparable
+}
+
+// ScanBitmaps returns the rows of tableName selected by terms, combined
+// with op. Every term must name a bitmap index. The bitmaps are combined
+// in memory and only the resulting row versions are read from the heap;
+// rows come back in storage order, each once. ScanOptions pages the
+// result as in Scan.
+func (tx *Transaction) ScanBitmaps(tableName string, op BitmapOp, terms []BitmapTerm, opts ...ScanOptions) ([]string, error) {
+	se := tx.engine
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return nil, err
+	}
+
+	tx.refreshSnapshot()
+
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return nil, err
+	}
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("storage: ScanBitmaps on %s needs at least one term", tableName)
+	}
+
+	var combined *bitmap.Bitmap
+	for _, term := range terms {
+		bm, err := termBitmap(table, term)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case combined == nil:
+			combined = bm
+		case op == BitmapOr:
+			combined = combined.Or(bm)
+		default:
+			combined = combined.And(bm)
+		}
+	}
+
+	recordIDs := combined.ToArray()
+	page := scanPage(opts)
+	if page.Reverse {
+		for i, j := 0, len(recordIDs)-1; i < j; i, j = i+1, j-1 {
+			recordIDs[i], recordIDs[j] = recordIDs[j], recordIDs[i]
+		}
+	}
+
+	results := []string{}
+	skipped := 0
+	for _, rid := range recordIDs {
+		record, err := se.readVisiblePosting(tx, table, nil, int64(rid))
+		if err != nil {
+			return results, err
+		}
+		if !record.Found {
+			continue
+		}
+		if skipped < page.Offset {
+			skipped++
+			continue
+		}
+		document, err := record.Projected(page.Projection)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, document)
+		if page.Limit > 0 && len(results) >= page.Limit {
+			break
+		}
+	}
+	return results, nil
+}
+
+// ScanBitmaps is Transaction.ScanBitmaps on a snapshot taken for the call.
+func (se *StorageEngine) ScanBitmaps(tableName string, op BitmapOp, terms []BitmapTerm, opts ...ScanOptions) ([]string, error) {
+	tx := se.BeginRead()
+	defer tx.Close()
+	return tx.ScanBitmaps(tableName, op, terms, opts...)
+}
+
+// termBitmap ORs the bitmaps of the values of term.
+func termBitmap(table *Table, term BitmapTerm) (*bitmap.Bitmap, error) {
+	index, err := table.GetIndex(term.Index)
+	if err != nil {
+		return nil, err
+	}
+	tree, ok := index.Tree.(*bitmapPostings)
+	if !index.Bitmap || !ok {
+		return nil, fmt.Errorf("storage: index %s.%s is not a bitmap index", table.Name, index.Name)
+	}
+
+	result := bitmap.New()
+	for _, value := range term.Values {
+		if err := validateKeyForIndex(index, value); err != nil {
+			return nil, err
+		}
+		bm, err := tree.Bitmap(value)
+		if err != nil {
+			return nil, fmt.Errorf("bitmap %s.%s: %w", table.Name, index.Name, err)
+		}
+		result = result.Or(bm)
+	}
+	return result, nil
+}
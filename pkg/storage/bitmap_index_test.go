@@ -0,0 +1,99 @@
+package storage_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+func openAccountsEngine(t *testing.T, dir string) *storage.StorageEngine {
+	t.Helper()
+	hm, err := storage.NewHeapForTable(storage.HeapFormatV2, filepath.Join(dir, "heap.data"))
+	if err != nil {
+		t.Fatalf("Failed to create heap: %v", err)
+	}
+	tableMgr := storage.NewTableMenager()
+	if err := tableMgr.NewTable("accounts", []storage.Index{
+		{Name: "id", Primary: true, Type: storage.TypeInt},
+		{Name: "status", Type: storage.TypeVarchar, Bitmap: true},
+		{Name: "active", Type: storage.TypeBoolean, Bitmap: true},
+		{Name: "plan", Type: storage.TypeVarchar},
+	}, 3, hm); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	se, err := storage.NewStorageEngine(tableMgr, nil)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	return se
+}
+
+func TestBitmapIndex_CombinesIndexesWithAndOr(t *testing.T) {
+	se := openAccountsEngine(t, t.TempDir())
+	defer se.Close()
+
+	for _, doc := range []string{
+		`{"id": 1, "status": "open", "active": true, "plan": "free"}`,
+		`{"id": 2, "status": "open", "active": false, "plan": "pro"}`,
+		`{"id": 3, "status": "closed", "active": true, "plan": "pro"}`,
+		`{"id": 4, "status": "frozen", "active": false, "plan": "free"}`,
+	} {
+		if err := se.InsertRow("accounts", doc, nil); err != nil {
+			t.Fatalf("InsertRow: %v", err)
+		}
+	}
+
+	open := storage.BitmapTerm{Index: "status", Values: []types.Comparable{types.VarcharKey("open")}}
+	active := storage.BitmapTerm{Index: "active", Values: []types.Comparable{types.BoolKey(true)}}
+
+	docs, err := se.ScanBitmaps("accounts", storage.BitmapAnd, []storage.BitmapTerm{open, active})
+	if err != nil {
+		t.Fatalf("ScanBitmaps AND: %v", err)
+	}
+	assertIDs(t, "open AND active", docs, "1")
+
+	docs, _ = se.ScanBitmaps("accounts", storage.BitmapOr, []storage.BitmapTerm{open, active})
+	assertIDs(t, "open OR active", docs, "1", "2", "3")
+
+	inactive := storage.BitmapTerm{Index: "status", Values: []types.Comparable{types.VarcharKey("closed"), types.VarcharKey("frozen")}}
+	docs, _ = se.ScanBitmaps("accounts", storage.BitmapAnd, []storage.BitmapTerm{inactive})
+	assertIDs(t, "closed or frozen", docs, "3", "4")
+
+	// Writes after the bitmaps were built keep them current, and older
+	// snapshots still read the versions they saw.
+	snapshot := se.BeginRead()
+	defer snapshot.Close()
+	if err := se.UpsertRow("accounts", `{"id": 2, "status": "open", "active": true, "plan": "pro"}`, nil); err != nil {
+		t.Fatalf("UpsertRow: %v", err)
+	}
+	if _, err := se.DeleteRow("accounts", types.IntKey(1)); err != nil {
+		t.Fatalf("DeleteRow: %v", err)
+	}
+	docs, _ = se.ScanBitmaps("accounts", storage.BitmapAnd, []storage.BitmapTerm{open, active})
+	assertIDs(t, "open AND active after writes", docs, "2")
+	docs, _ = snapshot.ScanBitmaps("accounts", storage.BitmapAnd, []storage.BitmapTerm{open, active})
+	assertIDs(t, "snapshot open AND active", docs, "1")
+
+	if _, err := se.ScanBitmaps("accounts", storage.BitmapAnd, []storage.BitmapTerm{{Index: "plan", Values: []types.Comparable{types.VarcharKey("pro")}}}); err == nil {
+		t.Fatal("expected a plain index to be rejected")
+	}
+	if _, err := se.ScanBitmaps("accounts", storage.BitmapAnd, []storage.BitmapTerm{{Index: "active", Values: []types.Comparable{types.VarcharKey("yes")}}}); err == nil {
+		t.Fatal("expected a key of the wrong type to be rejected")
+	}
+}
+
+func TestBitmapIndex_RequiresSecondaryIndex(t *testing.T) {
+	hm, err := storage.NewHeapForTable(storage.HeapFormatV2, filepath.Join(t.TempDir(), "heap.data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hm.Close()
+	err = storage.NewTableMenager().NewTable("accounts", []storage.Index{
+		{Name: "id", Primary: true, Type: storage.TypeInt, Bitmap: true},
+	}, 3, hm)
+	if err == nil {
+		t.Fatal("expected a bitmap primary index to be rejected")
+	}
+}
@@ -2,6 +2,8 @@ package storage
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/bobboyms/storage-engine/pkg/types"
@@ -49,62 +51,213 @@ func BsonToJson(bsonData []byte) (string, error) {
 	return string(jsonBytes), nil
 }
 
-func DoesTheKeyExist(doc bson.D, key string) (bool, DataType) {
-	for _, v := range doc {
-		if v.Key == key {
-			switch v.Value.(type) {
-			case int, int32, int64:
-				return true, TypeInt
-			case string:
-				return true, TypeVarchar
-			case bool:
-				return true, TypeBoolean
-			case float32, float64:
-				return true, TypeFloat
-			case time.Time:
-				return true, TypeDate
-			default:
-				// Verificamos via string para tipos que not importamos diretamente (ex: primitive.DateTime)
-				if fmt.Sprintf("%T", v.Value) == "primitive.DateTime" {
-					return true, TypeDate
-				}
-				return true, TypeVarchar
+// ProjectBson keeps only the given fields of doc. A field path uses dots to
+// reach into nested documents ("address.city"); the nesting is kept in the
+// result. Missing fields are left out.
+func ProjectBson(doc bson.D, fields []string) bson.D {
+	out := bson.D{}
+	for _, field := range fields {
+		out = projectPath(out, doc, strings.Split(field, "."))
+	}
+	return out
+}
+
+// ProjectBsonToJson converts only the given fields of doc to JSON.
+func ProjectBsonToJson(doc bson.D, fields []string) (string, error) {
+	jsonBytes, err := bson.MarshalExtJSON(ProjectBson(doc, fields), false, false)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+func projectPath(out, doc bson.D, path []string) bson.D {
+	for _, elem := range doc {
+		if elem.Key != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			return setBsonField(out, elem.Key, elem.Value)
+		}
+		nested, ok := elem.Value.(bson.D)
+		if !ok {
+			return out
+		}
+		var sub bson.D
+		for _, existing := range out {
+			if existing.Key == elem.Key {
+				sub, _ = existing.Value.(bson.D)
 			}
 		}
+		return setBsonField(out, elem.Key, projectPath(sub, nested, path[1:]))
 	}
-	return false, 0
+	return out
 }
 
+func setBsonField(doc bson.D, key string, value interface{}) bson.D {
+	for i := range doc {
+		if doc[i].Key == key {
+			doc[i].Value = value
+			return doc
+		}
+	}
+	return append(doc, bson.E{Key: key, Value: value})
+}
+
+// DoesTheKeyExist reports whether doc holds key and the type of its value.
+// key may be a dotted path into subdocuments ("user.profile.age").
+func DoesTheKeyExist(doc bson.D, key string) (bool, DataType) {
+	value, ok := lookupBsonPath(doc, key)
+	if !ok {
+		return false, 0
+	}
+	switch value.(type) {
+	case int, int32, int64:
+		return true, TypeInt
+	case string:
+		return true, TypeVarchar
+	case bool:
+		return true, TypeBoolean
+	case float32, float64:
+		return true, TypeFloat
+	case time.Time:
+		return true, TypeDate
+	case []byte:
+		return true, TypeBytes
+	default:
+		// Check by type name for types we do not import directly (e.g. primitive.DateTime)
+		if fmt.Sprintf("%T", value) == "primitive.DateTime" {
+			return true, TypeDate
+		}
+		return true, TypeVarchar
+	}
+}
+
+// GetValueFromBson returns the value of key in doc as a key. key may be a
+// dotted path into subdocuments.
 func GetValueFromBson(doc bson.D, key string) (types.Comparable, error) {
-	for _, v := range doc {
-		if v.Key == key {
-			switch val := v.Value.(type) {
-			case int:
-				return types.IntKey(val), nil
-			case int32:
-				return types.IntKey(val), nil
-			case int64:
-				return types.IntKey(val), nil
-			case string:
-				return types.VarcharKey(val), nil
-			case bool:
-				return types.BoolKey(val), nil
-			case float32:
-				return types.FloatKey(val), nil
-			case float64:
-				return types.FloatKey(val), nil
-			case time.Time:
-				return types.DateKey(val), nil
-			default:
-				// Helper for primitive.DateTime without import
-				if fmt.Sprintf("%T", val) == "primitive.DateTime" {
-					// We can't access .Time() without casting.
-					// Fallback to string representation logic or just return Varchar
-					return types.VarcharKey(fmt.Sprintf("%v", val)), nil
-				}
-				return types.VarcharKey(fmt.Sprintf("%v", val)), nil
+	value, ok := lookupBsonPath(doc, key)
+	if !ok {
+		return nil, fmt.Errorf("key %s not found in document", key)
+	}
+	return comparableFromBson(value), nil
+}
+
+// keyFromBson extracts the key of idx from doc. A nullable index reads a
+// missing field as NULL.
+func (idx *Index) keyFromBson(doc bson.D) (types.Comparable, error) {
+	value, ok := lookupBsonPath(doc, idx.FieldPath())
+	if !ok {
+		if idx.Nullable {
+			return normalizeIndexKey(idx, types.NullKey{}), nil
+		}
+		return nil, fmt.Errorf("key %s not found in document", idx.FieldPath())
+	}
+	if !idx.Multikey {
+		return coerceKey(idx.Type, comparableFromBson(value)), nil
+	}
+	key := multikeyFromBson(value)
+	for i, elem := range key {
+		key[i] = coerceKey(idx.Type, elem)
+	}
+	return normalizeIndexKey(idx, key), nil
+}
+
+// coerceKey converts a document value to the key type of a TypeDecimal or
+// TypeUUID index. JSON has neither type, so documents carry a decimal as
+// a number or a string and a UUID as its string form. Values that do not
+// convert are returned as they are, for validateKeyForIndex to reject.
+func coerceKey(t DataType, key types.Comparable) types.Comparable {
+	switch t {
+	case TypeDecimal:
+		var literal string
+		switch k := key.(type) {
+		case types.VarcharKey:
+			literal = string(k)
+		case types.IntKey:
+			literal = strconv.FormatInt(int64(k), 10)
+		case types.FloatKey:
+			literal = strconv.FormatFloat(float64(k), 'f', -1, 64)
+		default:
+			return key
+		}
+		if d, err := types.ParseDecimal(literal); err == nil {
+			return d
+		}
+	case TypeUUID:
+		if k, ok := key.(types.VarcharKey); ok {
+			if u, err := types.ParseUUID(string(k)); err == nil {
+				return u
 			}
 		}
 	}
-	return nil, fmt.Errorf("key %s not found in document", key)
+	return key
+}
+
+// lookupBsonPath follows a dotted path ("address.city") through the
+// subdocuments of doc. A top-level field named exactly like the path wins,
+// so documents written before nested paths keep resolving.
+func lookupBsonPath(doc bson.D, path string) (interface{}, bool) {
+	if value, ok := bsonField(doc, path); ok {
+		return value, true
+	}
+	parts := strings.Split(path, ".")
+	if len(parts) == 1 {
+		return nil, false
+	}
+	for i, part := range parts {
+		value, ok := bsonField(doc, part)
+		if !ok {
+			return nil, false
+		}
+		if i == len(parts)-1 {
+			return value, true
+		}
+		if doc, ok = value.(bson.D); !ok {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+func bsonField(doc bson.D, key string) (interface{}, bool) {
+	for _, elem := range doc {
+		if elem.Key == key {
+			return elem.Value, true
+		}
+	}
+	return nil, false
+}
+
+func comparableFromBson(value interface{}) types.Comparable {
+	switch val := value.(type) {
+	case nil:
+		return types.NullKey{}
+	case int:
+		return types.IntKey(val)
+	case int32:
+		return types.IntKey(val)
+	case int64:
+		return types.IntKey(val)
+	case string:
+		return types.VarcharKey(val)
+	case bool:
+		return types.BoolKey(val)
+	case float32:
+		return types.FloatKey(val)
+	case float64:
+		return types.FloatKey(val)
+	case time.Time:
+		return types.DateKey(val)
+	case []byte:
+		return types.BytesKey(val)
+	default:
+		// Helper for primitive.DateTime without import
+		if fmt.Sprintf("%T", val) == "primitive.DateTime" {
+			// We can't access .Time() without casting.
+			// Fallback to string representation logic or just return Varchar
+			return types.VarcharKey(fmt.Sprintf("%v", val))
+		}
+		return types.VarcharKey(fmt.Sprintf("%v", val))
+	}
 }
@@ -37,7 +37,8 @@ func TestBson_DoesTheKeyExist_Types(t *testing.T) {
 		{Key: "bool", Value: true},
 		{Key: "float", Value: 1.5},
 		{Key: "date", Value: time.Now()},
-		{Key: "unknown", Value: []byte("bytes")},
+		{Key: "bytes", Value: []byte("bytes")},
+		{Key: "unknown", Value: bson.A{"a"}},
 	}
 
 	tests := []struct {
@@ -51,6 +52,7 @@ func TestBson_DoesTheKeyExist_Types(t *testing.T) {
 		{"bool", storage.TypeBoolean},
 		{"float", storage.TypeFloat},
 		{"date", storage.TypeDate},
+		{"bytes", storage.TypeBytes},
 		{"unknown", storage.TypeVarchar}, // Fallback
 	}
 
@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/bobboyms/storage-engine/pkg/types"
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
@@ -71,6 +72,29 @@ func TestDoesTheKeyExist(t *testing.T) {
 			expectedFound: false,
 			expectedType:  0,
 		},
+		{
+			name: "Nested Path",
+			doc: bson.D{{Key: "user", Value: bson.D{
+				{Key: "profile", Value: bson.D{{Key: "age", Value: int64(41)}}},
+			}}},
+			key:           "user.profile.age",
+			expectedFound: true,
+			expectedType:  TypeInt,
+		},
+		{
+			name:          "Nested Path Through Scalar",
+			doc:           bson.D{{Key: "user", Value: "Thiago"}},
+			key:           "user.profile.age",
+			expectedFound: false,
+			expectedType:  0,
+		},
+		{
+			name:          "Dotted Top-Level Field",
+			doc:           bson.D{{Key: "a.b", Value: "x"}},
+			key:           "a.b",
+			expectedFound: true,
+			expectedType:  TypeVarchar,
+		},
 	}
 
 	for _, tt := range tests {
@@ -98,3 +122,49 @@ func TestDoesTheKeyExist_Coverage(t *testing.T) {
 		t.Error("Key should not exist")
 	}
 }
+
+func TestGetValueFromBson_NestedPath(t *testing.T) {
+	doc := bson.D{
+		{Key: "id", Value: int64(1)},
+		{Key: "user", Value: bson.D{{Key: "profile", Value: bson.D{{Key: "name", Value: "Ana"}}}}},
+	}
+	key, err := GetValueFromBson(doc, "user.profile.name")
+	if err != nil || key.Compare(types.VarcharKey("Ana")) != 0 {
+		t.Fatalf("expected Ana, got %v (%v)", key, err)
+	}
+	if _, err := GetValueFromBson(doc, "user.profile.age"); err == nil {
+		t.Fatal("expected a missing nested field to fail")
+	}
+	if _, err := GetValueFromBson(doc, "id.value"); err == nil {
+		t.Fatal("expected a path through a scalar to fail")
+	}
+}
+
+func TestProjectBson(t *testing.T) {
+	doc := bson.D{
+		{Key: "id", Value: int32(1)},
+		{Key: "name", Value: "Ana"},
+		{Key: "address", Value: bson.D{
+			{Key: "city", Value: "Lisbon"},
+			{Key: "zip", Value: "1000"},
+			{Key: "geo", Value: bson.D{{Key: "lat", Value: 38.7}}},
+		}},
+	}
+
+	got := ProjectBson(doc, []string{"name", "address.city", "address.geo.lat", "missing", "name.first"})
+	want := bson.D{
+		{Key: "name", Value: "Ana"},
+		{Key: "address", Value: bson.D{
+			{Key: "city", Value: "Lisbon"},
+			{Key: "geo", Value: bson.D{{Key: "lat", Value: 38.7}}},
+		}},
+	}
+	gotJSON, err := bson.MarshalExtJSON(got, false, false)
+	if err != nil {
+		t.Fatalf("MarshalExtJSON: %v", err)
+	}
+	wantJSON, _ := bson.MarshalExtJSON(want, false, false)
+	if string(gotJSON) != string(wantJSON) {
+		t.Fatalf("expected %s, got %s", wantJSON, gotJSON)
+	}
+}
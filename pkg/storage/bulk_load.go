@@ -0,0 +1,202 @@
+package storage
+
+import (
+	goerrors "errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// bulkLoadTree is implemented by index trees that can be built bottom-up
+// from an empty state.
+type bulkLoadTree interface {
+	rangeScanner
+	BulkLoad(keys []types.Comparable, values []int64) error
+}
+
+var errTreeNotEmpty = goerrors.New("storage: tree not empty")
+
+// BulkLoader imports rows into an empty table without writing a WAL
+// record per row. Rows are added in strictly ascending primary key order;
+// documents go to the heap as they arrive and Finish builds every index
+// bottom-up, then takes a checkpoint so the import is durable.
+//
+// Rows become visible only when Finish returns. A crash before that
+// loses the import: the table must be truncated and loaded again.
+type BulkLoader struct {
+	engine  *StorageEngine
+	table   *Table
+	lsn     uint64
+	primary string
+	last    types.Comparable
+
+	mu       sync.Mutex
+	keys     map[string][]types.Comparable
+	offsets  []int64
+	finished bool
+}
+
+// NewBulkLoader starts a bulk load into tableName, whose indexes must all
+// be empty.
+func (se *StorageEngine) NewBulkLoader(tableName string) (*BulkLoader, error) {
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return nil, err
+	}
+
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return nil, err
+	}
+	bl := &BulkLoader{
+		engine: se,
+		table:  table,
+		keys:   make(map[string][]types.Comparable),
+	}
+	for _, idx := range table.GetIndices() {
+		if err := checkBulkLoadable(tableName, idx); err != nil {
+			return nil, err
+		}
+		if idx.Primary {
+			bl.primary = idx.Name
+		}
+	}
+	if bl.primary == "" {
+		return nil, fmt.Errorf("storage: table %s has no primary key", tableName)
+	}
+	bl.lsn = se.lsnTracker.Next()
+	return bl, nil
+}
+
+// checkBulkLoadable fails when idx cannot be bulk loaded or has entries.
+func checkBulkLoadable(tableName string, idx *Index) error {
+	tree, ok := idx.Tree.(bulkLoadTree)
+	if !ok {
+		return fmt.Errorf("storage: index %s.%s uses unsupported type %T", tableName, idx.Name, idx.Tree)
+	}
+	err := tree.ScanAll(func(types.Comparable, int64) error { return errTreeNotEmpty })
+	if goerrors.Is(err, errTreeNotEmpty) {
+		return fmt.Errorf("storage: bulk load requires an empty table, index %s.%s has entries", tableName, idx.Name)
+	}
+	return err
+}
+
+// Add writes one row to the heap. Keys is optional, as in InsertRow; the
+// primary key must be greater than the one of the previous row.
+func (bl *BulkLoader) Add(doc string, keys map[string]types.Comparable) error {
+	se := bl.engine
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return err
+	}
+
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	if bl.finished {
+		return fmt.Errorf("storage: bulk load of %s already finished", bl.table.Name)
+	}
+
+	bsonData, rowKeys, err := prepareRowDocument(bl.table, doc, keys)
+	if err != nil {
+		return err
+	}
+	primaryKey := rowKeys[bl.primary]
+	if bl.last != nil && primaryKey.Compare(bl.last) <= 0 {
+		return fmt.Errorf("storage: bulk load rows must be in ascending primary key order: %v after %v", primaryKey, bl.last)
+	}
+
+	offset, err := writeRowVersion(bl.table, rowKeys, bsonData, bl.lsn, -1)
+	if err != nil {
+		return fmt.Errorf("heap write failed: %w", err)
+	}
+	for name, key := range rowKeys {
+		bl.keys[name] = append(bl.keys[name], key)
+	}
+	bl.offsets = append(bl.offsets, offset)
+	bl.last = primaryKey
+	return nil
+}
+
+// Finish builds every index from the added rows and checkpoints the
+// engine. The table must still be empty.
+func (bl *BulkLoader) Finish() error {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	if bl.finished {
+		return nil
+	}
+	bl.finished = true
+
+	if err := bl.buildIndexes(); err != nil {
+		return err
+	}
+	if bl.engine.WAL == nil {
+		return bl.engine.CreateCheckpoint()
+	}
+	return bl.engine.FuzzyCheckpoint()
+}
+
+func (bl *BulkLoader) buildIndexes() error {
+	se := bl.engine
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return err
+	}
+
+	table := bl.table
+	table.Lock()
+	defer table.Unlock()
+
+	indices := table.GetIndicesUnsafe()
+	for _, idx := range indices {
+		if err := checkBulkLoadable(table.Name, idx); err != nil {
+			return err
+		}
+	}
+	// Every index is sorted before any is loaded, so a repeated unique
+	// key leaves the table untouched.
+	keys := make([][]types.Comparable, len(indices))
+	offsets := make([][]int64, len(indices))
+	for i, idx := range indices {
+		keys[i], offsets[i] = bl.keys[idx.Name], bl.offsets
+		if !idx.Primary && !idx.IsMultiValue() {
+			var err error
+			keys[i], offsets[i], err = sortUniqueBulkKeys(table.Name, idx.Name, keys[i], offsets[i])
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for i, idx := range indices {
+		if err := idx.Tree.(bulkLoadTree).BulkLoad(keys[i], offsets[i]); err != nil {
+			return fmt.Errorf("bulk load index %s: %w", idx.Name, err)
+		}
+		se.appliedLSN.MarkApplied(table.Name, idx.Name, bl.lsn)
+	}
+	return nil
+}
+
+// sortUniqueBulkKeys orders the entries of a unique secondary index,
+// failing on a repeated key.
+func sortUniqueBulkKeys(tableName, indexName string, keys []types.Comparable, offsets []int64) ([]types.Comparable, []int64, error) {
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return keys[order[a]].Compare(keys[order[b]]) < 0 })
+
+	sortedKeys := make([]types.Comparable, len(keys))
+	sortedOffsets := make([]int64, len(keys))
+	for i, idx := range order {
+		sortedKeys[i], sortedOffsets[i] = keys[idx], offsets[idx]
+		if i > 0 && sortedKeys[i-1].Compare(sortedKeys[i]) == 0 {
+			return nil, nil, fmt.Errorf("duplicate key error: key %v repeats in index %s.%s", sortedKeys[i], tableName, indexName)
+		}
+	}
+	return sortedKeys, sortedOffsets, nil
+}
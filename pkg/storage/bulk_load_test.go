@@ -0,0 +1,83 @@
+package storage_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+func TestBulkLoader_LoadsEmptyTable(t *testing.T) {
+	dir := t.TempDir()
+	se := openEmployeesEngine(t, dir)
+
+	loader, err := se.NewBulkLoader("employees")
+	if err != nil {
+		t.Fatalf("NewBulkLoader: %v", err)
+	}
+	depts := []string{"Engineering", "Sales", "HR"}
+	const n = 3000
+	for id := int64(1); id <= n; id++ {
+		if err := loader.Add(fmt.Sprintf(`{"id": %d, "department": "%s"}`, id, depts[id%3]), nil); err != nil {
+			t.Fatalf("Add %d: %v", id, err)
+		}
+	}
+	if err := loader.Add(`{"id": 7, "department": "HR"}`, nil); err == nil {
+		t.Fatal("expected an error for a row out of primary key order")
+	}
+	if _, found, _ := se.Get("employees", "id", types.IntKey(1)); found {
+		t.Fatal("row visible before Finish")
+	}
+	if err := loader.Finish(); err != nil {
+		t.Fatalf("Finish: %v", err)
+	}
+
+	if count, err := se.Count("employees", "department", nil); err != nil || count != n {
+		t.Fatalf("Count: %d, %v", count, err)
+	}
+	docs, _ := se.GetAll("employees", "department", types.VarcharKey("Sales"))
+	if len(docs) != n/3 {
+		t.Fatalf("expected %d Sales rows, got %d", n/3, len(docs))
+	}
+	putEmployee(t, se, n+1, "Sales")
+	if _, err := se.NewBulkLoader("employees"); err == nil {
+		t.Fatal("expected an error for a table that is not empty")
+	}
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reader, err := wal.NewWALReader(filepath.Join(dir, "wal.log"))
+	if err != nil {
+		t.Fatalf("Failed to open WAL: %v", err)
+	}
+	rowEntries := 0
+	for {
+		entry, err := reader.ReadEntry()
+		if err != nil {
+			break
+		}
+		if entry.Header.EntryType == wal.EntryMultiInsert {
+			rowEntries++
+		}
+		wal.ReleaseEntry(entry)
+	}
+	reader.Close()
+	if rowEntries != 1 {
+		t.Fatalf("expected only the row written after the load in the WAL, got %d row entries", rowEntries)
+	}
+
+	se2 := openEmployeesEngine(t, dir)
+	defer se2.Close()
+	if err := se2.Recover(filepath.Join(dir, "wal.log")); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	if count, err := se2.Count("employees", "id", nil); err != nil || count != n+1 {
+		t.Fatalf("Count after reopen: %d, %v", count, err)
+	}
+	if doc, found, _ := se2.Get("employees", "id", types.IntKey(1500)); !found {
+		t.Fatalf("row 1500 missing after reopen: %q", doc)
+	}
+}
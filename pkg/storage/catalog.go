@@ -0,0 +1,285 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/bobboyms/storage-engine/pkg/crypto"
+	"github.com/bobboyms/storage-engine/pkg/errors"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+const catalogVersion = 1
+
+// Catalog is the on-disk schema: every table with its heap, degree and
+// indexes. TableMetaData rewrites it on each schema change so a restart
+// can reopen the tables without the application declaring them again.
+//
+// Heap and index paths are stored relative to the directory of the
+// catalog file when they lie under it, so a directory that is copied or
+// moved whole opens its own files. Files outside it keep absolute paths.
+type Catalog struct {
+	Version int            `json:"version"`
+	Tables  []CatalogTable `json:"tables"`
+}
+
+type CatalogTable struct {
+	Name       string            `json:"name"`
+	Degree     int               `json:"degree"`
+	HeapFormat HeapFormat        `json:"heap_format"`
+	HeapPath   string            `json:"heap_path"`
+	Indices    []CatalogIndex    `json:"indices"`
+	TTLIndex   string            `json:"ttl_index,omitempty"`
+	Retention  *VersionRetention `json:"retention,omitempty"`
+}
+
+type CatalogIndex struct {
+	Name       string          `json:"name"`
+	Primary    bool            `json:"primary"`
+	Type       DataType        `json:"type"`
+	Path       string          `json:"path"`
+	Field      string          `json:"field,omitempty"`
+	Multikey   bool            `json:"multikey,omitempty"`
+	Bitmap     bool            `json:"bitmap,omitempty"`
+	Nullable   bool            `json:"nullable,omitempty"`
+	Collation  types.Collation `json:"collation,omitempty"`
+	Partitions int             `json:"partitions,omitempty"`
+}
+
+// NewCatalogTableMenager opens the catalog at catalogPath, reopening every
+// table it lists, and keeps it up to date on later NewTable calls. A
+// missing file starts an empty catalog. cipher is used for the heaps and
+// indexes it opens and for indexes created implicitly by NewTable.
+func NewCatalogTableMenager(catalogPath string, cipher crypto.Cipher) (*TableMetaData, error) {
+	return openCatalogTableMenager(catalogPath, "", cipher)
+}
+
+// openCatalogTableMenager is NewCatalogTableMenager that, when within is
+// set, refuses a catalog listing a file outside that directory before
+// opening any.
+func openCatalogTableMenager(catalogPath string, within string, cipher crypto.Cipher) (*TableMetaData, error) {
+	tb := NewEncryptedTableMenager(cipher)
+	tb.catalogPath = catalogPath
+
+	catalog, err := readCatalog(catalogPath)
+	if err != nil {
+		return nil, err
+	}
+	base := filepath.Dir(catalogPath)
+	if within != "" {
+		if err := catalog.checkWithin(base, within); err != nil {
+			return nil, err
+		}
+	}
+	for _, ct := range catalog.Tables {
+		table, err := openCatalogTable(ct, base, cipher)
+		if err != nil {
+			tb.closeAll()
+			return nil, fmt.Errorf("storage: catalog table %s: %w", ct.Name, err)
+		}
+		tb.tables[ct.Name] = table
+		tb.degrees[ct.Name] = ct.Degree
+	}
+	return tb, nil
+}
+
+// CatalogPath returns the catalog file, or "" when the schema is not
+// persisted.
+func (tb *TableMetaData) CatalogPath() string {
+	return tb.catalogPath
+}
+
+// openCatalogTable opens the files of ct, resolving relative paths
+// against base, the directory of the catalog.
+func openCatalogTable(ct CatalogTable, base string, cipher crypto.Cipher) (*Table, error) {
+	hm, err := NewHeapForTable(ct.HeapFormat, resolveCatalogPath(base, ct.HeapPath), cipher)
+	if err != nil {
+		return nil, err
+	}
+
+	table := &Table{
+		Name:     ct.Name,
+		Indices:  make(map[string]*Index, len(ct.Indices)),
+		Heap:     hm,
+		ttlIndex: ct.TTLIndex,
+	}
+	if ct.Retention != nil {
+		table.retention = *ct.Retention
+	}
+	for _, ci := range ct.Indices {
+		idx := &Index{
+			Name:       ci.Name,
+			Primary:    ci.Primary,
+			Type:       ci.Type,
+			Field:      ci.Field,
+			Multikey:   ci.Multikey,
+			Bitmap:     ci.Bitmap,
+			Nullable:   ci.Nullable,
+			Collation:  ci.Collation,
+			Partitions: ci.Partitions,
+		}
+		tree, err := newIndexTree(idx, resolveCatalogPath(base, ci.Path), cipher)
+		if err != nil {
+			closeTable(table)
+			return nil, err
+		}
+		if ci.Bitmap {
+			if tree, err = newBitmapTree(ct.Name, idx, tree); err != nil {
+				closeTable(table)
+				return nil, err
+			}
+		}
+		idx.Tree = tree
+		table.Indices[ci.Name] = idx
+	}
+	return table, nil
+}
+
+func (tb *TableMetaData) saveCatalog() error {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	return tb.saveCatalogLocked()
+}
+
+// saveCatalogLocked rewrites the catalog from the tables in memory.
+// Caller must hold tb.mu.
+func (tb *TableMetaData) saveCatalogLocked() error {
+	if tb.catalogPath == "" {
+		return nil
+	}
+
+	catalog := Catalog{Version: catalogVersion, Tables: make([]CatalogTable, 0, len(tb.tables))}
+	base := filepath.Dir(tb.catalogPath)
+	for name, table := range tb.tables {
+		ct, err := catalogTableFor(table, tb.degrees[name], base)
+		if err != nil {
+			return err
+		}
+		catalog.Tables = append(catalog.Tables, ct)
+	}
+	sort.Slice(catalog.Tables, func(i, j int) bool { return catalog.Tables[i].Name < catalog.Tables[j].Name })
+
+	data, err := json.MarshalIndent(catalog, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := durableWriteFile(tb.catalogPath, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("storage: write catalog: %w", err)
+	}
+	return nil
+}
+
+func catalogTableFor(table *Table, degree int, base string) (CatalogTable, error) {
+	ct := CatalogTable{
+		Name:       table.Name,
+		Degree:     degree,
+		HeapFormat: HeapFormatV2,
+		HeapPath:   relativeCatalogPath(base, table.Heap.Path()),
+		Indices:    []CatalogIndex{},
+		TTLIndex:   table.TTLIndex(),
+	}
+	if retention := table.VersionRetention(); retention != (VersionRetention{}) {
+		ct.Retention = &retention
+	}
+	for _, idx := range table.GetIndices() {
+		provider, ok := idx.Tree.(pathProvider)
+		if !ok {
+			return CatalogTable{}, fmt.Errorf("storage: index %s.%s has no file path to persist in the catalog", table.Name, idx.Name)
+		}
+		ct.Indices = append(ct.Indices, CatalogIndex{
+			Name:       idx.Name,
+			Primary:    idx.Primary,
+			Type:       idx.Type,
+			Path:       relativeCatalogPath(base, provider.Path()),
+			Field:      idx.Field,
+			Multikey:   idx.Multikey,
+			Bitmap:     idx.Bitmap,
+			Nullable:   idx.Nullable,
+			Collation:  idx.Collation,
+			Partitions: idx.Partitions,
+		})
+	}
+	sort.Slice(ct.Indices, func(i, j int) bool { return ct.Indices[i].Name < ct.Indices[j].Name })
+	return ct, nil
+}
+
+// relativeCatalogPath returns path relative to base when it lies under
+// base, and path unchanged otherwise.
+func relativeCatalogPath(base, path string) string {
+	if rel, err := filepath.Rel(base, path); err == nil && filepath.IsLocal(rel) {
+		return rel
+	}
+	return path
+}
+
+// resolveCatalogPath returns the file a catalog path names: a relative
+// path is under base.
+func resolveCatalogPath(base, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(base, path)
+}
+
+// checkWithin returns an error when a heap or index of the catalog,
+// resolved against base, is not under dir.
+func (c *Catalog) checkWithin(base, dir string) error {
+	for _, ct := range c.Tables {
+		paths := []string{ct.HeapPath}
+		for _, ci := range ct.Indices {
+			paths = append(paths, ci.Path)
+		}
+		for _, path := range paths {
+			file := resolveCatalogPath(base, path)
+			if rel, err := filepath.Rel(dir, file); err != nil || !filepath.IsLocal(rel) {
+				return fmt.Errorf("storage: catalog table %s: file %s is outside %s", ct.Name, file, dir)
+			}
+		}
+	}
+	return nil
+}
+
+func readCatalog(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Catalog{Version: catalogVersion}, nil
+		}
+		return nil, err
+	}
+	var catalog Catalog
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return nil, fmt.Errorf("storage: corrupted catalog %s: %w", path, err)
+	}
+	if catalog.Version != catalogVersion {
+		return nil, fmt.Errorf("storage: unsupported catalog version: %d", catalog.Version)
+	}
+	seen := make(map[string]bool, len(catalog.Tables))
+	for _, ct := range catalog.Tables {
+		if seen[ct.Name] {
+			return nil, &errors.TableAlreadyExistsError{Name: ct.Name}
+		}
+		seen[ct.Name] = true
+	}
+	return &catalog, nil
+}
+
+func (tb *TableMetaData) closeAll() {
+	for _, table := range tb.tables {
+		closeTable(table)
+	}
+}
+
+func closeTable(table *Table) {
+	for _, idx := range table.Indices {
+		if idx.Tree != nil {
+			idx.Tree.Close()
+		}
+	}
+	if table.Heap != nil {
+		table.Heap.Close()
+	}
+}
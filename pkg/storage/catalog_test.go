@@ -0,0 +1,181 @@
+package storage_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	storageErrors "github.com/bobboyms/storage-engine/pkg/errors"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+func openCatalogEngine(t *testing.T, dir string, tableMgr *storage.TableMetaData) *storage.StorageEngine {
+	t.Helper()
+	walWriter, err := wal.NewWALWriter(filepath.Join(dir, "wal.log"), wal.DefaultOptions())
+	if err != nil {
+		t.Fatalf("Failed to create WAL: %v", err)
+	}
+	se, err := storage.NewProductionStorageEngine(tableMgr, walWriter)
+	if err != nil {
+		walWriter.Close()
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	return se
+}
+
+func TestCatalog_SchemaSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	catalogPath := filepath.Join(dir, "catalog.json")
+
+	tableMgr, err := storage.NewCatalogTableMenager(catalogPath, nil)
+	if err != nil {
+		t.Fatalf("NewCatalogTableMenager: %v", err)
+	}
+	hm, err := storage.NewHeapForTable(storage.HeapFormatV2, filepath.Join(dir, "heap.data"))
+	if err != nil {
+		t.Fatalf("Failed to create heap: %v", err)
+	}
+	if err := tableMgr.NewTable("employees", []storage.Index{
+		{Name: "id", Primary: true, Type: storage.TypeInt},
+		{Name: "department", Primary: false, Type: storage.TypeVarchar},
+	}, 3, hm); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	if _, err := os.Stat(catalogPath); err != nil {
+		t.Fatalf("catalog not written on NewTable: %v", err)
+	}
+
+	se := openCatalogEngine(t, dir, tableMgr)
+	putEmployee(t, se, 1, "Engineering")
+	putEmployee(t, se, 2, "Engineering")
+	putEmployee(t, se, 3, "Sales")
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Restart without declaring any table.
+	reopened, err := storage.NewCatalogTableMenager(catalogPath, nil)
+	if err != nil {
+		t.Fatalf("reopen catalog: %v", err)
+	}
+	if tables := reopened.ListTables(); len(tables) != 1 || tables[0] != "employees" {
+		t.Fatalf("expected [employees] after restart, got %v", tables)
+	}
+	dept, err := reopened.GetIndexByName("employees", "department")
+	if err != nil {
+		t.Fatalf("department index missing: %v", err)
+	}
+	if dept.Primary || dept.Type != storage.TypeVarchar || !dept.IsMultiValue() {
+		t.Fatalf("department index reopened with the wrong schema: %+v", dept)
+	}
+
+	se2 := openCatalogEngine(t, dir, reopened)
+	defer se2.Close()
+	docs, err := se2.GetAll("employees", "department", types.VarcharKey("Engineering"))
+	if err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	assertIDs(t, "Engineering after restart", docs, "1", "2")
+	if _, found, _ := se2.Get("employees", "id", types.IntKey(3)); !found {
+		t.Fatal("row 3 missing after restart")
+	}
+
+	// The reloaded catalog still rejects a second declaration.
+	hm2, err := storage.NewHeapForTable(storage.HeapFormatV2, filepath.Join(dir, "other.data"))
+	if err != nil {
+		t.Fatalf("Failed to create heap: %v", err)
+	}
+	defer hm2.Close()
+	err = reopened.NewTable("employees", []storage.Index{{Name: "id", Primary: true, Type: storage.TypeInt}}, 3, hm2)
+	var exists *storageErrors.TableAlreadyExistsError
+	if !errors.As(err, &exists) {
+		t.Fatalf("expected TableAlreadyExistsError, got %v", err)
+	}
+}
+
+func TestCatalog_InvalidFile(t *testing.T) {
+	dir := t.TempDir()
+	catalogPath := filepath.Join(dir, "catalog.json")
+
+	if err := os.WriteFile(catalogPath, []byte("{not json"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := storage.NewCatalogTableMenager(catalogPath, nil); err == nil {
+		t.Fatal("expected error for a corrupted catalog")
+	}
+
+	if err := os.WriteFile(catalogPath, []byte(`{"version": 99, "tables": []}`), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := storage.NewCatalogTableMenager(catalogPath, nil); err == nil {
+		t.Fatal("expected error for an unknown catalog version")
+	}
+}
+
+func TestCatalog_NotWrittenWithoutPath(t *testing.T) {
+	dir := t.TempDir()
+	se := openEmployeesEngine(t, dir)
+	defer se.Close()
+
+	if path := se.TableMetaData.CatalogPath(); path != "" {
+		t.Fatalf("expected no catalog, got %q", path)
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(matches) != 0 {
+		t.Fatalf("unexpected catalog files: %v", matches)
+	}
+}
+
+func TestCatalog_DirectoryCanBeMoved(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	tableMgr, err := storage.NewCatalogTableMenager(filepath.Join(dir, "catalog.json"), nil)
+	if err != nil {
+		t.Fatalf("NewCatalogTableMenager: %v", err)
+	}
+	hm, err := storage.NewHeapForTable(storage.HeapFormatV2, filepath.Join(dir, "heap.data"))
+	if err != nil {
+		t.Fatalf("Failed to create heap: %v", err)
+	}
+	if err := tableMgr.NewTable("employees", []storage.Index{
+		{Name: "id", Primary: true, Type: storage.TypeInt},
+		{Name: "department", Type: storage.TypeVarchar},
+	}, 3, hm); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	se := openCatalogEngine(t, dir, tableMgr)
+	putEmployee(t, se, 1, "Engineering")
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "catalog.json"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if strings.Contains(string(data), dir) {
+		t.Fatalf("catalog holds absolute paths:\n%s", data)
+	}
+
+	moved := filepath.Join(t.TempDir(), "moved")
+	if err := os.Rename(dir, moved); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	reopened, err := storage.NewCatalogTableMenager(filepath.Join(moved, "catalog.json"), nil)
+	if err != nil {
+		t.Fatalf("reopen moved catalog: %v", err)
+	}
+	se2 := openCatalogEngine(t, moved, reopened)
+	defer se2.Close()
+	docs, err := se2.GetAll("employees", "department", types.VarcharKey("Engineering"))
+	if err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	assertIDs(t, "Engineering after move", docs, "1")
+}
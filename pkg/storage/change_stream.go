@@ -0,0 +1,397 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+// Change streams turn the data records of the WAL into row-level events.
+// Every WAL write that changes data is also handed to the change feed:
+// auto-commit writes as soon as they are logged, transaction writes once
+// their COMMIT is logged and they are applied. A stream first replays the records already in the WAL
+// from its starting LSN and then follows the feed, so a consumer sees each
+// committed change once, in LSN order.
+
+// ChangeOp is the kind of a ChangeEvent.
+type ChangeOp int
+
+const (
+	ChangeInsert ChangeOp = iota
+	ChangeUpdate
+	ChangeDelete
+	// ChangeTruncate removes every row of the table.
+	ChangeTruncate
+	// ChangeDrop removes the table.
+	ChangeDrop
+)
+
+func (op ChangeOp) String() string {
+	return [...]string{"INSERT", "UPDATE", "DELETE", "TRUNCATE", "DROP"}[op]
+}
+
+// ChangeEvent is one committed change of a table. Key is the key of Index
+// the change was written under, the primary key for whole-row writes.
+// Document is the new document; for a delete it is the deleted one.
+//
+// Insert and update are told apart, and a deleted document is found, by
+// reading the row as it was just before LSN. Once vacuum has reclaimed
+// that version an old update is reported as an insert and the deleted
+// document is empty.
+type ChangeEvent struct {
+	LSN      uint64
+	Op       ChangeOp
+	Table    string
+	Index    string
+	Key      types.Comparable
+	Document string
+
+	// previous is the document an update replaced, when it was found.
+	previous string
+}
+
+// walChange is a data record of the WAL, without the transaction prefix.
+type walChange struct {
+	entryType uint8
+	payload   []byte
+	lsn       uint64
+}
+
+// changeFeed fans the logged data records out to the open streams.
+type changeFeed struct {
+	mu      sync.Mutex
+	streams map[*ChangeStream]struct{}
+	count   atomic.Int32
+}
+
+func newChangeFeed() *changeFeed {
+	return &changeFeed{streams: make(map[*ChangeStream]struct{})}
+}
+
+// publish queues a logged record on every stream. It never blocks on a
+// consumer.
+func (cf *changeFeed) publish(entryType uint8, payload []byte, lsn uint64) {
+	if cf.count.Load() == 0 || !isChangeEntry(entryType) {
+		return
+	}
+	change := walChange{entryType: entryType, payload: append([]byte(nil), payload...), lsn: lsn}
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	for cs := range cf.streams {
+		cs.enqueue(change)
+	}
+}
+
+func (cf *changeFeed) add(cs *ChangeStream) {
+	cf.mu.Lock()
+	cf.streams[cs] = struct{}{}
+	cf.count.Store(int32(len(cf.streams)))
+	cf.mu.Unlock()
+}
+
+func (cf *changeFeed) remove(cs *ChangeStream) {
+	cf.mu.Lock()
+	delete(cf.streams, cs)
+	cf.count.Store(int32(len(cf.streams)))
+	cf.mu.Unlock()
+}
+
+// closeAll ends every stream; StorageEngine.Close calls it.
+func (cf *changeFeed) closeAll() {
+	cf.mu.Lock()
+	streams := make([]*ChangeStream, 0, len(cf.streams))
+	for cs := range cf.streams {
+		streams = append(streams, cs)
+	}
+	cf.mu.Unlock()
+	for _, cs := range streams {
+		cs.Close()
+	}
+}
+
+func isChangeEntry(entryType uint8) bool {
+	switch entryType {
+	case wal.EntryInsert, wal.EntryUpdate, wal.EntryDelete,
+		wal.EntryMultiInsert, wal.EntryMultiDelete,
+		wal.EntryMultiBatch, wal.EntryMultiDeleteBatch,
+		wal.EntryTruncate, wal.EntryDropTable:
+		return true
+	}
+	return false
+}
+
+// ChangeStream delivers the changes of one table on C until Close. C is
+// closed when the stream ends; Err then reports why, nil after Close.
+type ChangeStream struct {
+	C <-chan ChangeEvent
+
+	engine    *StorageEngine
+	tableName string
+	fromLSN   uint64
+	// backlogLSN is the last LSN logged before the stream subscribed;
+	// records up to it are read from the WAL, later ones from the feed.
+	backlogLSN uint64
+
+	out  chan ChangeEvent
+	stop chan struct{}
+	done chan struct{}
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []walChange
+	closed bool
+	err    error
+}
+
+// Watch streams the committed changes of tableName with LSN >= fromLSN.
+// Changes already in the WAL are replayed first, so fromLSN must not be
+// older than the WAL kept since the last checkpoint; fromLSN 0 replays
+// the whole WAL. Watch needs an engine with a WAL. A consumer that falls
+// behind is buffered in memory; writes never wait for it.
+func (se *StorageEngine) Watch(tableName string, fromLSN uint64) (*ChangeStream, error) {
+	if se.WAL == nil {
+		return nil, fmt.Errorf("storage: Watch needs a WAL")
+	}
+
+	// With every writer held off, the WAL holds all records up to the
+	// current LSN and every later one reaches the feed.
+	se.opMu.Lock()
+	if err := se.runtimeReadyError(); err != nil {
+		se.opMu.Unlock()
+		return nil, err
+	}
+	if _, err := se.TableMetaData.GetTableByName(tableName); err != nil {
+		se.opMu.Unlock()
+		return nil, err
+	}
+	if err := se.WAL.Sync(); err != nil {
+		se.opMu.Unlock()
+		return nil, err
+	}
+	out := make(chan ChangeEvent)
+	cs := &ChangeStream{
+		C:          out,
+		engine:     se,
+		tableName:  tableName,
+		fromLSN:    fromLSN,
+		backlogLSN: se.lsnTracker.Current(),
+		out:        out,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	cs.cond = sync.NewCond(&cs.mu)
+	se.changes.add(cs)
+	se.opMu.Unlock()
+
+	go cs.run()
+	return cs, nil
+}
+
+// Close ends the stream and waits for its goroutine to finish.
+func (cs *ChangeStream) Close() {
+	cs.mu.Lock()
+	if !cs.closed {
+		cs.closed = true
+		close(cs.stop)
+		cs.cond.Broadcast()
+	}
+	cs.mu.Unlock()
+	<-cs.done
+}
+
+// Err reports the error that ended the stream, if any.
+func (cs *ChangeStream) Err() error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.err
+}
+
+func (cs *ChangeStream) enqueue(change walChange) {
+	cs.mu.Lock()
+	if !cs.closed {
+		cs.queue = append(cs.queue, change)
+		cs.cond.Signal()
+	}
+	cs.mu.Unlock()
+}
+
+func (cs *ChangeStream) run() {
+	defer close(cs.done)
+	defer close(cs.out)
+	defer cs.engine.changes.remove(cs)
+
+	err := cs.replayBacklog()
+	for err == nil {
+		change, ok := cs.next()
+		if !ok {
+			break
+		}
+		err = cs.emit(change)
+	}
+	if err != nil && err != errStreamClosed {
+		cs.mu.Lock()
+		cs.err = err
+		cs.mu.Unlock()
+	}
+}
+
+var errStreamClosed = fmt.Errorf("storage: change stream closed")
+
+// next waits for the next record of the feed.
+func (cs *ChangeStream) next() (walChange, bool) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	for len(cs.queue) == 0 && !cs.closed {
+		cs.cond.Wait()
+	}
+	if cs.closed {
+		return walChange{}, false
+	}
+	change := cs.queue[0]
+	cs.queue = cs.queue[1:]
+	return change, true
+}
+
+// replayBacklog emits the records in the WAL between fromLSN and
+// backlogLSN. Transaction records are held until their COMMIT and
+// dropped on ABORT.
+func (cs *ChangeStream) replayBacklog() error {
+	if cs.backlogLSN < cs.fromLSN {
+		return nil
+	}
+	reader, err := wal.NewWALReaderWithCipher(cs.engine.WAL.Path(), cs.engine.WAL.Cipher())
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	pending := make(map[uint64][]walChange)
+	for {
+		entry, err := reader.ReadEntry()
+		if err == io.EOF || (err != nil && isExpectedWALTail(err)) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("change stream: read wal: %w", err)
+		}
+		header := entry.Header
+		txID, payload, transactional, err := unwrapTxPayload(header, entry.Payload)
+		if err != nil {
+			wal.ReleaseEntry(entry)
+			return err
+		}
+		change := walChange{entryType: header.EntryType, payload: append([]byte(nil), payload...), lsn: header.LSN}
+		wal.ReleaseEntry(entry)
+		if header.LSN > cs.backlogLSN {
+			continue
+		}
+
+		var ready []walChange
+		switch {
+		case transactional && header.EntryType == wal.EntryCommit:
+			ready = pending[txID]
+			delete(pending, txID)
+		case transactional && header.EntryType == wal.EntryAbort:
+			delete(pending, txID)
+		case !isChangeEntry(header.EntryType) || header.LSN < cs.fromLSN:
+		case transactional:
+			pending[txID] = append(pending[txID], change)
+		default:
+			ready = []walChange{change}
+		}
+		for _, change := range ready {
+			if err := cs.emit(change); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// emit decodes change and sends the events of the watched table.
+func (cs *ChangeStream) emit(change walChange) error {
+	events, err := cs.engine.changeEvents(cs.tableName, change)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		select {
+		case cs.out <- event:
+		case <-cs.stop:
+			return errStreamClosed
+		}
+	}
+	return nil
+}
+
+// changeEvents decodes a WAL record into the events of tableName.
+func (se *StorageEngine) changeEvents(tableName string, change walChange) ([]ChangeEvent, error) {
+	records, err := decodeLogicalRecords(change.entryType, change.payload, change.lsn)
+	if err != nil {
+		return nil, err
+	}
+	var events []ChangeEvent
+	for _, record := range records {
+		if record.Table != tableName {
+			continue
+		}
+		event := ChangeEvent{LSN: change.lsn, Table: record.Table}
+		switch record.Op {
+		case LogicalTruncate:
+			event.Op = ChangeTruncate
+		case LogicalDropTable:
+			event.Op = ChangeDrop
+		default:
+			event.Index, event.Key = se.changePrimaryKey(record.Table, record.Keys)
+			event = se.describeChange(event, record.Document, record.Op == LogicalDelete)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// changePrimaryKey picks the primary key out of the keys of a row record.
+func (se *StorageEngine) changePrimaryKey(tableName string, keys map[string]types.Comparable) (string, types.Comparable) {
+	if table, err := se.TableMetaData.GetTableByName(tableName); err == nil {
+		if primary, err := primaryIndex(table); err == nil {
+			if key, ok := keys[primary.Name]; ok {
+				return primary.Name, key
+			}
+		}
+	}
+	for name, key := range keys {
+		return name, key
+	}
+	return "", nil
+}
+
+// describeChange fills Op and Document from the row as it was before the
+// change.
+func (se *StorageEngine) describeChange(event ChangeEvent, doc string, deleted bool) ChangeEvent {
+	var before visibleRecord
+	if event.Index != "" && event.LSN > 0 {
+		view := &Transaction{SnapshotLSN: event.LSN - 1, Level: RepeatableRead, engine: se}
+		se.opMu.RLock()
+		before, _ = se.visibleRecordForKey(context.Background(), view, event.Table, event.Index, event.Key)
+		se.opMu.RUnlock()
+	}
+	switch {
+	case deleted:
+		event.Op = ChangeDelete
+		if before.Found {
+			event.Document = before.Document()
+		}
+		return event
+	case before.Found:
+		event.Op = ChangeUpdate
+		event.previous = before.Document()
+	default:
+		event.Op = ChangeInsert
+	}
+	event.Document = doc
+	return event
+}
@@ -0,0 +1,142 @@
+package storage_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+func nextChange(t *testing.T, cs *storage.ChangeStream) storage.ChangeEvent {
+	t.Helper()
+	select {
+	case ev, ok := <-cs.C:
+		if !ok {
+			t.Fatalf("stream ended: %v", cs.Err())
+		}
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a change")
+	}
+	return storage.ChangeEvent{}
+}
+
+func expectChange(t *testing.T, cs *storage.ChangeStream, op storage.ChangeOp, key int64, docPart string) storage.ChangeEvent {
+	t.Helper()
+	ev := nextChange(t, cs)
+	if ev.Op != op || ev.Table != "reports" || ev.Key != types.IntKey(key) {
+		t.Fatalf("expected %s of %d, got %s of %v", op, key, ev.Op, ev.Key)
+	}
+	if !strings.Contains(ev.Document, docPart) {
+		t.Fatalf("expected %s of %d to carry %q, got %q", op, key, docPart, ev.Document)
+	}
+	return ev
+}
+
+func TestChangeStream_BacklogThenLiveChanges(t *testing.T) {
+	se := openReportsEngine(t, t.TempDir())
+
+	if err := se.InsertRow("reports", `{"id": 1, "dept": "Eng"}`, nil); err != nil {
+		t.Fatalf("InsertRow: %v", err)
+	}
+	if err := se.InsertRow("reports", `{"id": 2, "dept": "Ops"}`, nil); err != nil {
+		t.Fatalf("InsertRow: %v", err)
+	}
+
+	cs, err := se.Watch("reports", 0)
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	defer cs.Close()
+
+	// Backlog from the WAL.
+	first := expectChange(t, cs, storage.ChangeInsert, 1, `"Eng"`)
+	second := expectChange(t, cs, storage.ChangeInsert, 2, `"Ops"`)
+	if second.LSN <= first.LSN {
+		t.Fatalf("expected increasing LSNs, got %d then %d", first.LSN, second.LSN)
+	}
+
+	// Live changes.
+	if err := se.UpdateRow("reports", `{"id": 1, "dept": "Sales"}`, nil); err != nil {
+		t.Fatalf("UpdateRow: %v", err)
+	}
+	expectChange(t, cs, storage.ChangeUpdate, 1, `"Sales"`)
+	if deleted, err := se.DeleteRow("reports", types.IntKey(2)); err != nil || !deleted {
+		t.Fatalf("DeleteRow: %v %v", deleted, err)
+	}
+	expectChange(t, cs, storage.ChangeDelete, 2, `"Ops"`)
+
+	// A rolled back transaction is never seen; a committed one is.
+	rolledBack := se.BeginWriteTransaction()
+	if err := rolledBack.InsertRow("reports", `{"id": 3, "dept": "Lost"}`, nil); err != nil {
+		t.Fatalf("tx InsertRow: %v", err)
+	}
+	rolledBack.Rollback()
+	tx := se.BeginWriteTransaction()
+	if err := tx.InsertRow("reports", `{"id": 4, "dept": "HR"}`, nil); err != nil {
+		t.Fatalf("tx InsertRow: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	expectChange(t, cs, storage.ChangeInsert, 4, `"HR"`)
+
+	// A second stream starting later skips the older changes.
+	late, err := se.Watch("reports", second.LSN+1)
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	expectChange(t, late, storage.ChangeUpdate, 1, `"Sales"`)
+
+	if err := se.TruncateTable("reports"); err != nil {
+		t.Fatalf("TruncateTable: %v", err)
+	}
+	if ev := nextChange(t, cs); ev.Op != storage.ChangeTruncate {
+		t.Fatalf("expected TRUNCATE, got %s", ev.Op)
+	}
+
+	// Close ends the streams still open.
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	for range late.C {
+	}
+	if err := late.Err(); err != nil {
+		t.Fatalf("expected a clean end, got %v", err)
+	}
+}
+
+func TestChangeStream_CommittedTransactionInBacklog(t *testing.T) {
+	dir := t.TempDir()
+	se := openReportsEngine(t, dir)
+
+	tx := se.BeginWriteTransaction()
+	if err := tx.InsertRow("reports", `{"id": 7, "dept": "Eng"}`, nil); err != nil {
+		t.Fatalf("tx InsertRow: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	aborted := se.BeginWriteTransaction()
+	if err := aborted.InsertRow("reports", `{"id": 8, "dept": "Lost"}`, nil); err != nil {
+		t.Fatalf("tx InsertRow: %v", err)
+	}
+	aborted.Rollback()
+
+	// After a crash the recovered engine replays the committed row only.
+	se.WAL.Close()
+	se2 := openReportsEngine(t, dir)
+	defer se2.Close()
+	cs, err := se2.Watch("reports", 0)
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	defer cs.Close()
+	expectChange(t, cs, storage.ChangeInsert, 7, `"Eng"`)
+	if err := se2.InsertRow("reports", `{"id": 9, "dept": "Ops"}`, nil); err != nil {
+		t.Fatalf("InsertRow: %v", err)
+	}
+	expectChange(t, cs, storage.ChangeInsert, 9, `"Ops"`)
+}
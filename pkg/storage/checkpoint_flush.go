@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/bobboyms/storage-engine/pkg/btree"
+	"github.com/bobboyms/storage-engine/pkg/heap"
+	v2 "github.com/bobboyms/storage-engine/pkg/heap/v2"
+)
+
+// SetCheckpointWorkers sets how many page files CreateCheckpoint and
+// FuzzyCheckpoint flush at once. Every tree and heap has its own buffer
+// pool and file, so they flush independently; n <= 0 restores the
+// default, GOMAXPROCS. 1 flushes them one after the other.
+func (se *StorageEngine) SetCheckpointWorkers(n int) {
+	if n < 0 {
+		n = 0
+	}
+	se.checkpointWorkers.Store(int32(n))
+}
+
+func (se *StorageEngine) checkpointWorkerCount() int {
+	if n := int(se.checkpointWorkers.Load()); n > 0 {
+		return n
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// pageFileSync flushes one tree or heap; name identifies it in errors.
+type pageFileSync struct {
+	name string
+	sync func() error
+}
+
+// pageFileSyncs lists the trees and heaps of every table, each once.
+func (se *StorageEngine) pageFileSyncs() []pageFileSync {
+	var syncs []pageFileSync
+	seenTrees := make(map[btree.Tree]bool)
+	seenHeaps := make(map[heap.Heap]bool)
+
+	for _, tableName := range se.TableMetaData.ListTables() {
+		table, err := se.TableMetaData.GetTableByName(tableName)
+		if err != nil {
+			continue
+		}
+
+		for _, idx := range table.GetIndices() {
+			if idx.Tree == nil || seenTrees[idx.Tree] {
+				continue
+			}
+			seenTrees[idx.Tree] = true
+			// A partitioned index flushes one partition file per worker.
+			fileTrees := indexFileTrees(idx.Tree)
+			for i, fileTree := range fileTrees {
+				syncer, ok := fileTree.(syncableTree)
+				if !ok {
+					continue
+				}
+				name := tableName + "." + idx.Name
+				if len(fileTrees) > 1 {
+					name += fmt.Sprintf(" partition %d", i)
+				}
+				syncs = append(syncs, pageFileSync{name: name, sync: syncer.Sync})
+			}
+		}
+
+		if table.Heap == nil || seenHeaps[table.Heap] {
+			continue
+		}
+		seenHeaps[table.Heap] = true
+		if heapV2, ok := table.Heap.(*v2.HeapV2); ok {
+			syncs = append(syncs, pageFileSync{name: tableName + " heap", sync: heapV2.Sync})
+		}
+	}
+	return syncs
+}
+
+// syncPageFiles flushes every tree and heap, up to checkpointWorkerCount
+// at a time, and waits for all of them. It returns every failure joined;
+// the callers write their checkpoint record only when it returns nil, so
+// the record is the manifest of a checkpoint whose files all made it to
+// disk.
+func (se *StorageEngine) syncPageFiles() error {
+	syncs := se.pageFileSyncs()
+	workers := min(se.checkpointWorkerCount(), len(syncs))
+
+	errs := make([]error, len(syncs))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if err := syncs[i].sync(); err != nil {
+					errs[i] = fmt.Errorf("%s: %w", syncs[i].name, err)
+				}
+			}
+		}()
+	}
+	for i := range syncs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return errors.Join(errs...)
+}
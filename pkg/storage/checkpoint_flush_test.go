@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/btree"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// failingSyncTree is a tree whose Sync always fails.
+type failingSyncTree struct {
+	btree.Tree
+}
+
+func (failingSyncTree) Sync() error { return errors.New("disk full") }
+
+// setupCheckpointTables creates the engine with tables t0..t(n-1), each
+// with its own heap, and writes a few rows to each.
+func setupCheckpointTables(t *testing.T, dir string, n int) *StorageEngine {
+	t.Helper()
+	se := setupEngineWithWAL(t, dir, "t0")
+	for i := 1; i < n; i++ {
+		name := fmt.Sprintf("t%d", i)
+		hm, err := NewHeapForTable(HeapFormatV2, filepath.Join(dir, name+".heap"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := se.TableMetaData.NewTable(name, []Index{{Name: "id", Primary: true, Type: TypeInt}}, 0, hm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < n; i++ {
+		for k := 1; k <= 50; k++ {
+			if err := se.Put(fmt.Sprintf("t%d", i), "id", types.IntKey(k), fmt.Sprintf(`{"id":%d}`, k)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return se
+}
+
+func TestCreateCheckpoint_FlushesPageFilesInParallel(t *testing.T) {
+	dir := t.TempDir()
+	se := setupCheckpointTables(t, dir, 6)
+	if got := len(se.pageFileSyncs()); got != 12 {
+		t.Fatalf("pageFileSyncs = %d, want a tree and a heap per table", got)
+	}
+	for _, workers := range []int{1, 4, 32} {
+		se.SetCheckpointWorkers(workers)
+		if err := se.CreateCheckpoint(); err != nil {
+			t.Fatalf("CreateCheckpoint with %d workers: %v", workers, err)
+		}
+		if lsn := se.oldestDirtyPageLSN(); lsn != 0 {
+			t.Fatalf("dirty page at LSN %d after the checkpoint with %d workers", lsn, workers)
+		}
+	}
+	if err := se.FuzzyCheckpoint(); err != nil {
+		t.Fatalf("FuzzyCheckpoint: %v", err)
+	}
+}
+
+// TestCreateCheckpoint_FailedFlushWritesNoRecord: if one index fails its
+// flush, the whole checkpoint fails and no record is written, so recovery
+// still starts from the previous checkpoint.
+func TestCreateCheckpoint_FailedFlushWritesNoRecord(t *testing.T) {
+	dir := t.TempDir()
+	se := setupCheckpointTables(t, dir, 3)
+	table, err := se.TableMetaData.GetTableByName("t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := table.GetIndex("id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := idx.Tree
+	idx.Tree = failingSyncTree{tree}
+	defer func() { idx.Tree = tree }()
+
+	err = se.CreateCheckpoint()
+	if err == nil || !strings.Contains(err.Error(), "t1.id: disk full") {
+		t.Fatalf("CreateCheckpoint = %v, want the t1.id failure", err)
+	}
+	if err := se.WAL.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, err := findLastCheckpointLSN(filepath.Join(dir, "wal.log")); err != nil || found {
+		t.Fatalf("checkpoint record after a failed flush: found=%v, %v", found, err)
+	}
+	if err := se.FuzzyCheckpoint(); err == nil {
+		t.Fatal("FuzzyCheckpoint should fail too")
+	}
+}
@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCheckpointSchedulerRunning is returned by Start when the scheduler
+// is already running.
+var ErrCheckpointSchedulerRunning = errors.New("storage: checkpoint scheduler already running")
+
+// CheckpointSchedulerConfig selects when the scheduler takes a checkpoint.
+// A trigger set to zero is disabled; at least one must be set. Whichever
+// trigger fires first wins, and every checkpoint resets all of them.
+type CheckpointSchedulerConfig struct {
+	// Interval is the longest time between checkpoints while there is new
+	// work. An idle engine is not checkpointed again.
+	Interval time.Duration
+
+	// WALBytes triggers a checkpoint once this many bytes were written to
+	// the WAL since the last one.
+	WALBytes uint64
+
+	// Operations triggers a checkpoint once this many LSNs were handed out
+	// since the last one (roughly one per logged write).
+	Operations uint64
+
+	// PollInterval is how often the triggers are evaluated. Defaults to
+	// 100ms, or Interval when that is shorter.
+	PollInterval time.Duration
+
+	// OnError, when set, receives every failed checkpoint. The scheduler
+	// keeps running and tries again on the next trigger.
+	OnError func(error)
+}
+
+// CheckpointSchedulerStats reports what the scheduler has done since the
+// engine was opened.
+type CheckpointSchedulerStats struct {
+	Running        bool
+	Checkpoints    uint64
+	Failures       uint64
+	LastCheckpoint time.Time
+	LastError      error
+}
+
+// CheckpointScheduler runs FuzzyCheckpoint in the background based on
+// elapsed time, WAL volume or write count, so recovery only has to replay
+// the WAL written since the last checkpoint. StorageEngine.Close stops it.
+type CheckpointScheduler struct {
+	engine *StorageEngine
+
+	mu      sync.Mutex
+	cfg     CheckpointSchedulerConfig
+	stop    chan struct{}
+	done    chan struct{}
+	running bool
+
+	// Baseline of the last checkpoint (or of Start).
+	lastTime     time.Time
+	lastWALBytes uint64
+	lastLSN      uint64
+
+	stats CheckpointSchedulerStats
+}
+
+func newCheckpointScheduler(se *StorageEngine) *CheckpointScheduler {
+	return &CheckpointScheduler{engine: se}
+}
+
+// Start launches the background loop with cfg.
+func (cs *CheckpointScheduler) Start(cfg CheckpointSchedulerConfig) error {
+	if cfg.Interval <= 0 && cfg.WALBytes == 0 && cfg.Operations == 0 {
+		return errors.New("storage: checkpoint scheduler needs Interval, WALBytes or Operations")
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 100 * time.Millisecond
+		if cfg.Interval > 0 && cfg.Interval < cfg.PollInterval {
+			cfg.PollInterval = cfg.Interval
+		}
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.running {
+		return ErrCheckpointSchedulerRunning
+	}
+	cs.cfg = cfg
+	cs.resetBaselineLocked()
+	cs.stop = make(chan struct{})
+	cs.done = make(chan struct{})
+	cs.running = true
+	cs.stats.Running = true
+	go cs.loop(cs.stop, cs.done)
+	return nil
+}
+
+// Stop ends the background loop and waits for a checkpoint in progress to
+// finish. Stopping a scheduler that is not running is a no-op.
+func (cs *CheckpointScheduler) Stop() {
+	cs.mu.Lock()
+	if !cs.running {
+		cs.mu.Unlock()
+		return
+	}
+	stop, done := cs.stop, cs.done
+	cs.running = false
+	cs.stats.Running = false
+	cs.mu.Unlock()
+
+	close(stop)
+	<-done
+}
+
+// Stats returns a snapshot of the scheduler counters.
+func (cs *CheckpointScheduler) Stats() CheckpointSchedulerStats {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.stats
+}
+
+func (cs *CheckpointScheduler) loop(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	cs.mu.Lock()
+	ticker := time.NewTicker(cs.cfg.PollInterval)
+	cs.mu.Unlock()
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if cs.due() {
+				cs.runCheckpoint()
+			}
+		}
+	}
+}
+
+// due reports whether any configured trigger has fired.
+func (cs *CheckpointScheduler) due() bool {
+	walBytes, lsn := cs.progress()
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.cfg.WALBytes > 0 && walBytes-cs.lastWALBytes >= cs.cfg.WALBytes {
+		return true
+	}
+	if cs.cfg.Operations > 0 && lsn-cs.lastLSN >= cs.cfg.Operations {
+		return true
+	}
+	if cs.cfg.Interval > 0 && time.Since(cs.lastTime) >= cs.cfg.Interval {
+		if walBytes != cs.lastWALBytes || lsn != cs.lastLSN {
+			return true
+		}
+		// Nothing new: restart the interval instead of checkpointing an
+		// idle engine.
+		cs.lastTime = time.Now()
+	}
+	return false
+}
+
+func (cs *CheckpointScheduler) runCheckpoint() {
+	err := cs.engine.FuzzyCheckpoint()
+
+	cs.mu.Lock()
+	onError := cs.cfg.OnError
+	if err != nil {
+		cs.stats.Failures++
+		cs.stats.LastError = err
+	} else {
+		cs.stats.Checkpoints++
+		cs.stats.LastError = nil
+		cs.resetBaselineLocked()
+		cs.stats.LastCheckpoint = cs.lastTime
+	}
+	cs.mu.Unlock()
+
+	if err != nil && onError != nil {
+		onError(err)
+	}
+}
+
+// resetBaselineLocked measures the triggers from now on. Taken after the
+// checkpoint so its own WAL record does not count towards the next one.
+func (cs *CheckpointScheduler) resetBaselineLocked() {
+	cs.lastTime = time.Now()
+	cs.lastWALBytes, cs.lastLSN = cs.progress()
+}
+
+func (cs *CheckpointScheduler) progress() (walBytes uint64, lsn uint64) {
+	if cs.engine.WAL != nil {
+		walBytes = cs.engine.WAL.BytesWritten()
+	}
+	return walBytes, cs.engine.lsnTracker.Current()
+}
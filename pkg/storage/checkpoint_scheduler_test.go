@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+func waitForCheckpoints(t *testing.T, cs *CheckpointScheduler, want uint64) CheckpointSchedulerStats {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		stats := cs.Stats()
+		if stats.Checkpoints >= want {
+			return stats
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d scheduled checkpoints, got %+v", want, stats)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func putCounters(t *testing.T, se *StorageEngine, from, to int) {
+	t.Helper()
+	for i := from; i < to; i++ {
+		if err := se.Put("counters", "id", types.IntKey(int64(i)), fmt.Sprintf(`{"id":%d,"value":0}`, i)); err != nil {
+			t.Fatalf("put %d: %v", i, err)
+		}
+	}
+}
+
+func TestCheckpointScheduler_OperationsTrigger(t *testing.T) {
+	se := newCounterEngine(t, time.Second)
+	cs := se.CheckpointScheduler
+	if err := cs.Start(CheckpointSchedulerConfig{Operations: 10, PollInterval: 5 * time.Millisecond}); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer cs.Stop()
+
+	putCounters(t, se, 0, 5)
+	time.Sleep(30 * time.Millisecond)
+	if got := cs.Stats().Checkpoints; got != 0 {
+		t.Fatalf("checkpoint before the operations threshold: %d", got)
+	}
+
+	putCounters(t, se, 5, 10)
+	stats := waitForCheckpoints(t, cs, 1)
+	if stats.LastCheckpoint.IsZero() || stats.LastError != nil || !stats.Running {
+		t.Fatalf("unexpected stats after checkpoint: %+v", stats)
+	}
+}
+
+func TestCheckpointScheduler_WALBytesTrigger(t *testing.T) {
+	se := newCounterEngine(t, time.Second)
+	cs := se.CheckpointScheduler
+	if err := cs.Start(CheckpointSchedulerConfig{WALBytes: 1, PollInterval: 5 * time.Millisecond}); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer cs.Stop()
+
+	putCounters(t, se, 0, 1)
+	waitForCheckpoints(t, cs, 1)
+
+	// The checkpoint's own WAL record must not trigger the next one.
+	time.Sleep(30 * time.Millisecond)
+	if got := cs.Stats().Checkpoints; got != 1 {
+		t.Fatalf("expected no checkpoint without new writes, got %d", got)
+	}
+}
+
+func TestCheckpointScheduler_IntervalSkipsIdleEngine(t *testing.T) {
+	se := newCounterEngine(t, time.Second)
+	cs := se.CheckpointScheduler
+	if err := cs.Start(CheckpointSchedulerConfig{Interval: 10 * time.Millisecond}); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer cs.Stop()
+
+	time.Sleep(50 * time.Millisecond)
+	if got := cs.Stats().Checkpoints; got != 0 {
+		t.Fatalf("idle engine was checkpointed %d times", got)
+	}
+
+	putCounters(t, se, 0, 1)
+	waitForCheckpoints(t, cs, 1)
+}
+
+func TestCheckpointScheduler_WritesCheckpointRecord(t *testing.T) {
+	se := newCounterEngine(t, time.Second)
+	walPath := se.WAL.Path()
+	cs := se.CheckpointScheduler
+	if err := cs.Start(CheckpointSchedulerConfig{Operations: 1, PollInterval: 5 * time.Millisecond}); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	putCounters(t, se, 0, 3)
+	waitForCheckpoints(t, cs, 1)
+	if err := se.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if cs.Stats().Running {
+		t.Fatal("Close should stop the scheduler")
+	}
+
+	reader, err := wal.NewWALReader(walPath)
+	if err != nil {
+		t.Fatalf("open WAL: %v", err)
+	}
+	defer reader.Close()
+	for {
+		entry, err := reader.ReadEntry()
+		if err != nil {
+			t.Fatal("no checkpoint record in the active WAL segment")
+		}
+		isCheckpoint := entry.Header.EntryType == wal.EntryCheckpoint
+		wal.ReleaseEntry(entry)
+		if isCheckpoint {
+			return
+		}
+	}
+}
+
+func TestCheckpointScheduler_StartStop(t *testing.T) {
+	se := newCounterEngine(t, time.Second)
+	cs := se.CheckpointScheduler
+
+	if err := cs.Start(CheckpointSchedulerConfig{}); err == nil {
+		t.Fatal("expected error without any trigger")
+	}
+	cs.Stop() // not running: no-op
+
+	cfg := CheckpointSchedulerConfig{Interval: time.Hour}
+	if err := cs.Start(cfg); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if err := cs.Start(cfg); !errors.Is(err, ErrCheckpointSchedulerRunning) {
+		t.Fatalf("expected ErrCheckpointSchedulerRunning, got %v", err)
+	}
+	cs.Stop()
+	cs.Stop()
+	if err := cs.Start(cfg); err != nil {
+		t.Fatalf("restart after stop: %v", err)
+	}
+	cs.Stop()
+}
+
+func TestCheckpointScheduler_ReportsErrors(t *testing.T) {
+	se := newCounterEngine(t, time.Second)
+	se.markDegraded(errors.New("boom"))
+
+	errCh := make(chan error, 1)
+	cs := se.CheckpointScheduler
+	err := cs.Start(CheckpointSchedulerConfig{
+		Interval:     5 * time.Millisecond,
+		PollInterval: 5 * time.Millisecond,
+		OnError: func(err error) {
+			select {
+			case errCh <- err:
+			default:
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer cs.Stop()
+
+	// The degraded engine rejects writes, so make the interval see work by
+	// advancing the LSN directly.
+	se.lsnTracker.Next()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, ErrEngineDegraded) {
+			t.Fatalf("expected ErrEngineDegraded, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnError was never called")
+	}
+	if stats := cs.Stats(); stats.Failures == 0 || stats.LastError == nil {
+		t.Fatalf("failure not recorded: %+v", stats)
+	}
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// An index with a collation (Index.Collation) stores the collation key of
+// every VARCHAR value instead of the value itself: its tree is built with
+// btreev2.CollatedKeyCodec, so lookups, ranges and posting maintenance
+// compare collation keys while the document keeps the original string.
+// The WAL logs the original keys and redo encodes them again, so replay
+// and checkpoints produce the same tree. Keys read back from the tree are
+// collation keys; conditions on the index key are brought to the same
+// form before they are matched against them.
+
+// collateCondition returns condition with the values that test the index
+// key replaced by their collation keys. Conditions on document fields
+// are kept as they are. Without a collation condition itself is returned.
+func collateCondition(index *Index, condition *query.ScanCondition) *query.ScanCondition {
+	if condition == nil || index.Collation == types.CollationBinary || condition.Field != "" {
+		return condition
+	}
+	collated := *condition
+	if condition.Value != nil {
+		collated.Value = index.Collation.Apply(condition.Value)
+	}
+	if condition.ValueEnd != nil {
+		collated.ValueEnd = index.Collation.Apply(condition.ValueEnd)
+	}
+	if condition.Values != nil {
+		collated.Values = make([]types.Comparable, len(condition.Values))
+		for i, value := range condition.Values {
+			collated.Values[i] = index.Collation.Apply(value)
+		}
+	}
+	if condition.Conditions != nil {
+		collated.Conditions = make([]*query.ScanCondition, len(condition.Conditions))
+		for i, operand := range condition.Conditions {
+			collated.Conditions[i] = collateCondition(index, operand)
+		}
+	}
+	return &collated
+}
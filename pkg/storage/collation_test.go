@@ -0,0 +1,157 @@
+package storage_test
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+func openContactsEngine(t *testing.T, dir string) *storage.StorageEngine {
+	t.Helper()
+	hm, err := storage.NewHeapForTable(storage.HeapFormatV2, filepath.Join(dir, "heap.data"))
+	if err != nil {
+		t.Fatalf("Failed to create heap: %v", err)
+	}
+	tableMgr, err := storage.NewCatalogTableMenager(filepath.Join(dir, "catalog.json"), nil)
+	if err != nil {
+		t.Fatalf("Failed to open catalog: %v", err)
+	}
+	if _, err := tableMgr.GetTableByName("contacts"); err != nil {
+		if err := tableMgr.NewTable("contacts", []storage.Index{
+			{Name: "id", Primary: true, Type: storage.TypeInt},
+			{Name: "name", Type: storage.TypeVarchar, Collation: types.CollationAccentInsensitive},
+			{Name: "email", Type: storage.TypeVarchar, Collation: types.CollationCaseInsensitive, Bitmap: true},
+		}, 3, hm); err != nil {
+			t.Fatalf("Failed to create table: %v", err)
+		}
+	} else {
+		hm.Close()
+	}
+	walWriter, err := wal.NewWALWriter(filepath.Join(dir, "wal.log"), wal.DefaultOptions())
+	if err != nil {
+		t.Fatalf("Failed to create WAL: %v", err)
+	}
+	se, err := storage.NewProductionStorageEngine(tableMgr, walWriter)
+	if err != nil {
+		walWriter.Close()
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	return se
+}
+
+// assertNames checks the "name" field of docs, in order.
+func assertNames(t *testing.T, label string, docs []string, want ...string) {
+	t.Helper()
+	got := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		var row struct{ Name string }
+		if err := json.Unmarshal([]byte(doc), &row); err != nil {
+			t.Fatalf("%s: %v", label, err)
+		}
+		got = append(got, row.Name)
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("%s: expected names %v, got %v", label, want, got)
+	}
+}
+
+func TestCollation_CaseAndAccentInsensitiveIndex(t *testing.T) {
+	dir := t.TempDir()
+	se := openContactsEngine(t, dir)
+
+	for _, doc := range []string{
+		`{"id": 1, "name": "Bruno", "email": "bruno@example.com"}`,
+		`{"id": 2, "name": "Álvaro", "email": "Alvaro@Example.com"}`,
+		`{"id": 3, "name": "Alvo", "email": "alvo@example.com"}`,
+		`{"id": 4, "name": "ALVARO", "email": "ALVARO@EXAMPLE.COM"}`,
+		`{"id": 5, "name": "Çarla", "email": "carla@example.com"}`,
+	} {
+		if err := se.InsertRow("contacts", doc, nil); err != nil {
+			t.Fatalf("InsertRow %s: %v", doc, err)
+		}
+	}
+
+	check := func(se *storage.StorageEngine, label string) {
+		t.Helper()
+		docs, err := se.GetAll("contacts", "name", types.VarcharKey("alvaro"))
+		if err != nil {
+			t.Fatalf("%s: GetAll: %v", label, err)
+		}
+		assertIDs(t, label+" GetAll alvaro", docs, "2", "4")
+		// The collation orders "Álvaro" with the other a's, not after "z".
+		docs, _ = se.Scan("contacts", "name", nil)
+		assertNames(t, label+" name order", docs, "Álvaro", "ALVARO", "Alvo", "Bruno", "Çarla")
+		docs, _ = se.Scan("contacts", "name", query.Equal(types.VarcharKey("ÁLVARO")))
+		assertIDs(t, label+" Scan ÁLVARO", docs, "2", "4")
+		docs, _ = se.Scan("contacts", "name", query.Between(types.VarcharKey("ALVO"), types.VarcharKey("carla")))
+		assertIDs(t, label+" Between", docs, "1", "3", "5")
+		docs, _ = se.Scan("contacts", "name", query.In(types.VarcharKey("alvo"), types.VarcharKey("bruno")))
+		assertIDs(t, label+" In", docs, "1", "3")
+		if count, _ := se.Count("contacts", "name", query.Equal(types.VarcharKey("Alvaro"))); count != 2 {
+			t.Fatalf("%s: expected Count 2, got %d", label, count)
+		}
+		// The document keeps the original spelling.
+		doc, found, _ := se.Get("contacts", "id", types.IntKey(2))
+		if !found || doc != `{"id":2,"name":"Álvaro","email":"Alvaro@Example.com"}` {
+			t.Fatalf("%s: expected the original document, got %q", label, doc)
+		}
+		docs, _ = se.ScanBitmaps("contacts", storage.BitmapOr, []storage.BitmapTerm{
+			{Index: "email", Values: []types.Comparable{types.VarcharKey("alvaro@example.com")}},
+		})
+		assertIDs(t, label+" bitmap email", docs, "2", "4")
+	}
+	check(se, "live")
+
+	// Crash without a checkpoint: redo rebuilds the collated keys.
+	se.WAL.Close()
+	se2 := openContactsEngine(t, dir)
+	check(se2, "after recovery")
+
+	deleted, err := se2.DeleteRange("contacts", "name", query.Equal(types.VarcharKey("alvaro")))
+	if err != nil || deleted != 2 {
+		t.Fatalf("DeleteRange: deleted %d, err %v", deleted, err)
+	}
+	if err := se2.CreateCheckpoint(); err != nil {
+		t.Fatalf("CreateCheckpoint: %v", err)
+	}
+	if err := se2.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	se3 := openContactsEngine(t, dir)
+	defer se3.Close()
+	docs, _ := se3.Scan("contacts", "name", nil)
+	assertNames(t, "after checkpoint", docs, "Alvo", "Bruno", "Çarla")
+	if err := se3.InsertRow("contacts", `{"id": 6, "name": "alvaro", "email": "x@example.com"}`, nil); err != nil {
+		t.Fatalf("InsertRow after reopen: %v", err)
+	}
+	docs, _ = se3.GetAll("contacts", "name", types.VarcharKey("ÁLVARO"))
+	assertIDs(t, "collation from the catalog", docs, "6")
+}
+
+func TestCollation_RejectsInvalidIndexes(t *testing.T) {
+	for _, idx := range []storage.Index{
+		{Name: "id", Primary: true, Type: storage.TypeVarchar, Collation: types.CollationCaseInsensitive},
+		{Name: "age", Type: storage.TypeInt, Collation: types.CollationCaseInsensitive},
+		{Name: "name", Type: storage.TypeVarchar, Collation: "pt_BR"},
+	} {
+		hm, err := storage.NewHeapForTable(storage.HeapFormatV2, filepath.Join(t.TempDir(), "heap.data"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		indexes := []storage.Index{idx}
+		if !idx.Primary {
+			indexes = append(indexes, storage.Index{Name: "id", Primary: true, Type: storage.TypeInt})
+		}
+		if err := storage.NewTableMenager().NewTable("contacts", indexes, 3, hm); err == nil {
+			t.Fatalf("expected collation on %s to be rejected", idx.Name)
+		}
+		hm.Close()
+	}
+}
@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Collection is a typed view of a table whose rows are values of the
+// struct type T. Rows are marshalled with the BSON codec, so the `bson`
+// tags of T name the document fields.
+//
+// Every indexed field carries a `storage:"name"` tag, and the primary key
+// `storage:"name,primary"`; the index name must equal the field's BSON
+// name, since index keys are read from the document. Supported key types
+// are the Go integer kinds (except unsigned), string, bool and the float
+// kinds.
+type Collection[T any] struct {
+	engine  *StorageEngine
+	table   string
+	primary string
+}
+
+type collectionField struct {
+	name    string
+	primary bool
+	typ     DataType
+}
+
+// CollectionIndexes derives the index definitions of T, ready for
+// TableMetaData.NewTable.
+func CollectionIndexes[T any]() ([]Index, error) {
+	fields, err := collectionFields(reflect.TypeFor[T]())
+	if err != nil {
+		return nil, err
+	}
+	indices := make([]Index, len(fields))
+	for i, f := range fields {
+		indices[i] = Index{Name: f.name, Primary: f.primary, Type: f.typ}
+	}
+	return indices, nil
+}
+
+// NewCollection binds T to an existing table. The table must have exactly
+// the indexes CollectionIndexes derives from T.
+func NewCollection[T any](se *StorageEngine, tableName string) (*Collection[T], error) {
+	fields, err := collectionFields(reflect.TypeFor[T]())
+	if err != nil {
+		return nil, err
+	}
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	indices := table.GetIndices()
+	if len(indices) != len(fields) {
+		return nil, fmt.Errorf("storage: table %s has %d indexes, %s tags %d fields", tableName, len(indices), reflect.TypeFor[T](), len(fields))
+	}
+	c := &Collection[T]{engine: se, table: tableName}
+	for _, f := range fields {
+		idx, err := table.GetIndex(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if idx.Primary != f.primary || idx.Type != f.typ {
+			return nil, fmt.Errorf("storage: index %s.%s is %s (primary=%v), field is %s (primary=%v)", tableName, f.name, idx.Type, idx.Primary, f.typ, f.primary)
+		}
+		if f.primary {
+			c.primary = f.name
+		}
+	}
+	return c, nil
+}
+
+// Insert adds v; it fails when its primary key already exists.
+func (c *Collection[T]) Insert(v T) error { return c.write(v, rowInsert) }
+
+// Upsert inserts v or replaces the row with the same primary key.
+func (c *Collection[T]) Upsert(v T) error { return c.write(v, rowUpsert) }
+
+// Update replaces the row with the primary key of v. Returns
+// *errors.RowNotFoundError when there is none.
+func (c *Collection[T]) Update(v T) error { return c.write(v, rowUpdate) }
+
+// Delete removes the row with the given primary key.
+func (c *Collection[T]) Delete(key types.Comparable) (bool, error) {
+	return c.engine.DeleteRow(c.table, key)
+}
+
+// Get reads the row with the given primary key.
+func (c *Collection[T]) Get(key types.Comparable) (T, bool, error) {
+	var v T
+	doc, found, err := c.engine.Get(c.table, c.primary, key)
+	if err != nil || !found {
+		return v, found, err
+	}
+	if err := bson.UnmarshalExtJSON([]byte(doc), false, &v); err != nil {
+		return v, false, fmt.Errorf("storage: decode %s row: %w", c.table, err)
+	}
+	return v, true, nil
+}
+
+// Scan runs StorageEngine.Scan and decodes every row.
+func (c *Collection[T]) Scan(indexName string, condition *query.ScanCondition, opts ...ScanOptions) ([]T, error) {
+	docs, err := c.engine.Scan(c.table, indexName, condition, opts...)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]T, len(docs))
+	for i, doc := range docs {
+		if err := bson.UnmarshalExtJSON([]byte(doc), false, &rows[i]); err != nil {
+			return nil, fmt.Errorf("storage: decode %s row: %w", c.table, err)
+		}
+	}
+	return rows, nil
+}
+
+func (c *Collection[T]) write(v T, mode rowWriteMode) error {
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("storage: encode %s row: %w", c.table, err)
+	}
+	doc, err := UnmarshalBson(data)
+	if err != nil {
+		return err
+	}
+	return c.engine.writeBsonRow(c.table, doc, mode)
+}
+
+// collectionFields reads the `storage` tags of a struct type.
+func collectionFields(t reflect.Type) ([]collectionField, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("storage: collection type %s is not a struct", t)
+	}
+
+	var fields []collectionField
+	primaries := 0
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("storage")
+		if !ok {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		f := collectionField{name: opts[0]}
+		for _, opt := range opts[1:] {
+			if opt != "primary" {
+				return nil, fmt.Errorf("storage: field %s.%s: unknown tag option %q", t, sf.Name, opt)
+			}
+			f.primary = true
+			primaries++
+		}
+
+		bsonName := strings.ToLower(sf.Name)
+		if name, _, _ := strings.Cut(sf.Tag.Get("bson"), ","); name != "" {
+			bsonName = name
+		}
+		if f.name == "" {
+			f.name = bsonName
+		}
+		if f.name != bsonName {
+			return nil, fmt.Errorf("storage: field %s.%s: index %q must match its bson name %q", t, sf.Name, f.name, bsonName)
+		}
+
+		switch sf.Type.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f.typ = TypeInt
+		case reflect.String:
+			f.typ = TypeVarchar
+		case reflect.Bool:
+			f.typ = TypeBoolean
+		case reflect.Float32, reflect.Float64:
+			f.typ = TypeFloat
+		default:
+			return nil, fmt.Errorf("storage: field %s.%s: type %s cannot be indexed", t, sf.Name, sf.Type)
+		}
+		fields = append(fields, f)
+	}
+	if primaries != 1 {
+		return nil, fmt.Errorf("storage: collection type %s needs exactly one primary field, has %d", t, primaries)
+	}
+	return fields, nil
+}
@@ -0,0 +1,110 @@
+package storage_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	storageErrors "github.com/bobboyms/storage-engine/pkg/errors"
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+type employee struct {
+	ID         int     `bson:"id" storage:"id,primary"`
+	Department string  `bson:"department" storage:"department"`
+	Salary     float64 `bson:"salary"`
+	Tags       []string
+}
+
+func openEmployeeCollection(t *testing.T) (*storage.StorageEngine, *storage.Collection[employee]) {
+	t.Helper()
+	indices, err := storage.CollectionIndexes[employee]()
+	if err != nil {
+		t.Fatalf("CollectionIndexes: %v", err)
+	}
+	hm, err := storage.NewHeapForTable(storage.HeapFormatV2, filepath.Join(t.TempDir(), "heap.data"))
+	if err != nil {
+		t.Fatalf("Failed to create heap: %v", err)
+	}
+	tableMgr := storage.NewTableMenager()
+	if err := tableMgr.NewTable("employees", indices, 3, hm); err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	se, err := storage.NewStorageEngine(tableMgr, nil)
+	if err != nil {
+		t.Fatalf("NewStorageEngine: %v", err)
+	}
+	c, err := storage.NewCollection[employee](se, "employees")
+	if err != nil {
+		t.Fatalf("NewCollection: %v", err)
+	}
+	return se, c
+}
+
+func TestCollection_TypedRoundTrip(t *testing.T) {
+	se, c := openEmployeeCollection(t)
+	defer se.Close()
+
+	ana := employee{ID: 1, Department: "Eng", Salary: 95000.5, Tags: []string{"go"}}
+	if err := c.Insert(ana); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if err := c.Insert(employee{ID: 2, Department: "Sales", Salary: 50000}); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if err := c.Insert(ana); err == nil {
+		t.Fatal("expected a duplicate key error")
+	}
+
+	got, found, err := c.Get(types.IntKey(1))
+	if err != nil || !found {
+		t.Fatalf("Get: found=%v err=%v", found, err)
+	}
+	if got.ID != 1 || got.Department != "Eng" || got.Salary != 95000.5 || len(got.Tags) != 1 || got.Tags[0] != "go" {
+		t.Fatalf("unexpected row %+v", got)
+	}
+
+	ana.Department = "Sales"
+	if err := c.Update(ana); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	sales, err := c.Scan("department", query.Equal(types.VarcharKey("Sales")))
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if len(sales) != 2 {
+		t.Fatalf("expected 2 Sales rows, got %+v", sales)
+	}
+
+	if deleted, err := c.Delete(types.IntKey(2)); err != nil || !deleted {
+		t.Fatalf("Delete: deleted=%v err=%v", deleted, err)
+	}
+	var notFound *storageErrors.RowNotFoundError
+	if err := c.Update(employee{ID: 2, Department: "Ops"}); !errors.As(err, &notFound) {
+		t.Fatalf("expected RowNotFoundError, got %v", err)
+	}
+}
+
+func TestCollection_TagErrors(t *testing.T) {
+	type noPrimary struct {
+		ID int `storage:"id"`
+	}
+	type wrongName struct {
+		ID int `bson:"_id" storage:"id,primary"`
+	}
+	type badType struct {
+		ID   int            `storage:"id,primary"`
+		Meta map[string]int `storage:"meta"`
+	}
+	if _, err := storage.CollectionIndexes[noPrimary](); err == nil {
+		t.Error("expected an error without a primary field")
+	}
+	if _, err := storage.CollectionIndexes[wrongName](); err == nil {
+		t.Error("expected an error when the index name differs from the bson name")
+	}
+	if _, err := storage.CollectionIndexes[badType](); err == nil {
+		t.Error("expected an error for a map index")
+	}
+}
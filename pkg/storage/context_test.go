@@ -0,0 +1,86 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// cancelAfterCtx reports cancellation once Err has been called n times.
+type cancelAfterCtx struct {
+	context.Context
+	n int
+}
+
+func (c *cancelAfterCtx) Err() error {
+	if c.n <= 0 {
+		return context.Canceled
+	}
+	c.n--
+	return nil
+}
+
+func TestContext_CancelledCallsHaveNoEffect(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+	putEmployee(t, se, 1, "Engineering")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := se.PutCtx(ctx, "employees", "id", types.IntKey(2), `{"id": 2, "department": "Sales"}`); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected PutCtx to be cancelled, got %v", err)
+	}
+	if _, found, _ := se.Get("employees", "id", types.IntKey(2)); found {
+		t.Fatal("cancelled PutCtx wrote the row")
+	}
+	if _, _, err := se.GetCtx(ctx, "employees", "id", types.IntKey(1)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected GetCtx to be cancelled, got %v", err)
+	}
+	if _, err := se.ScanCtx(ctx, "employees", "id", nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected ScanCtx to be cancelled, got %v", err)
+	}
+
+	tx := se.BeginWriteTransaction()
+	if err := tx.Put("employees", "id", types.IntKey(3), `{"id": 3, "department": "Ops"}`); err != nil {
+		t.Fatalf("tx put: %v", err)
+	}
+	if err := tx.CommitCtx(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected CommitCtx to be cancelled, got %v", err)
+	}
+	if err := tx.Commit(); err == nil {
+		t.Fatal("expected the cancelled transaction to be finished")
+	}
+	if _, found, _ := se.Get("employees", "id", types.IntKey(3)); found {
+		t.Fatal("cancelled CommitCtx made the row visible")
+	}
+
+	// The key is free again for the next transaction.
+	retry := se.BeginWriteTransaction()
+	if err := retry.Put("employees", "id", types.IntKey(3), `{"id": 3, "department": "Ops"}`); err != nil {
+		t.Fatalf("retry put: %v", err)
+	}
+	if err := retry.CommitCtx(context.Background()); err != nil {
+		t.Fatalf("retry commit: %v", err)
+	}
+}
+
+func TestContext_ScanStopsWhenCancelled(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+	for id := int64(1); id <= 10; id++ {
+		putEmployee(t, se, id, "Engineering")
+	}
+
+	ctx := &cancelAfterCtx{Context: context.Background(), n: 4}
+	docs, err := se.ScanCtx(ctx, "employees", "id", query.GreaterOrEqual(types.IntKey(1)))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the scan to be cancelled, got %v", err)
+	}
+	if len(docs) != 4 {
+		t.Fatalf("expected the rows read before cancellation, got %v", docs)
+	}
+}
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	goerrors "errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bobboyms/storage-engine/pkg/pagestore"
+)
+
+// ErrDatabaseLocked is returned when the data directory is already open,
+// in this process or another, or when a heap or the WAL is open for
+// writing in another process. The locks are advisory; read-only opens,
+// such as heaps opened with ReadOnly and WAL readers, do not take them.
+var ErrDatabaseLocked = goerrors.New("storage: database is locked by another engine")
+
+// Layout of a data directory.
+const (
+	dataDirLockFile    = "LOCK"
+	dataDirWAL         = "wal"
+	dataDirHeap        = "heap"
+	dataDirCheckpoints = "checkpoints"
+	dataDirTemp        = "tmp"
+	tempFileSuffix     = ".tmp"
+)
+
+// DataDir owns the layout of a data directory:
+//
+//	LOCK          held while the directory is open
+//	catalog.json  the schema (see NewCatalogTableMenager)
+//	wal/          WAL segments
+//	heap/         heaps and their index files
+//	checkpoints/  checkpoint and archive images
+//	tmp/          scratch files, emptied on open
+//
+// Opening it takes an exclusive lock on LOCK, so the same directory is
+// never opened twice, and removes the scratch files a crash left behind:
+// everything in tmp/ and every "*.tmp" of an interrupted atomic write or
+// vacuum in the directory, wal/ and heap/. On platforms without file
+// locks, double opens are not detected.
+type DataDir struct {
+	root string
+	lock *os.File
+}
+
+// OpenDataDir creates the layout under root when missing, locks it and
+// cleans its scratch files.
+func OpenDataDir(root string) (*DataDir, error) {
+	for _, sub := range []string{dataDirWAL, dataDirHeap, dataDirCheckpoints, dataDirTemp} {
+		if err := os.MkdirAll(filepath.Join(root, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("storage: data directory %s: %w", root, err)
+		}
+	}
+	lock, err := os.OpenFile(filepath.Join(root, dataDirLockFile), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("storage: data directory %s: %w", root, err)
+	}
+	if err := lockFile(lock); err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("%w: %s: %v", ErrDatabaseLocked, root, err)
+	}
+	d := &DataDir{root: root, lock: lock}
+	if err := d.cleanTemp(); err != nil {
+		d.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// newMemDataDir returns a DataDir kept in memory (see
+// pagestore.MemPrefix), for Options.InMemory: nothing is created on disk,
+// there is no lock, and Close drops every file under it.
+func newMemDataDir() *DataDir {
+	return &DataDir{root: pagestore.TempMemDir()}
+}
+
+// cleanTemp empties tmp/ and removes leftover "*.tmp" files.
+func (d *DataDir) cleanTemp() error {
+	entries, err := os.ReadDir(d.TempDir())
+	if err != nil {
+		return fmt.Errorf("storage: clean %s: %w", d.TempDir(), err)
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(d.TempDir(), entry.Name())); err != nil {
+			return fmt.Errorf("storage: clean %s: %w", d.TempDir(), err)
+		}
+	}
+	for _, dir := range []string{d.root, d.WALDir(), d.HeapDir()} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return fmt.Errorf("storage: clean %s: %w", dir, err)
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), tempFileSuffix) {
+				if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+					return fmt.Errorf("storage: clean %s: %w", dir, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Root returns the directory itself.
+func (d *DataDir) Root() string { return d.root }
+
+// CatalogPath returns the catalog file.
+func (d *DataDir) CatalogPath() string { return filepath.Join(d.root, catalogFileName) }
+
+// WALDir returns the directory of the WAL.
+func (d *DataDir) WALDir() string { return filepath.Join(d.root, dataDirWAL) }
+
+// HeapDir returns the directory of heaps and index files.
+func (d *DataDir) HeapDir() string { return filepath.Join(d.root, dataDirHeap) }
+
+// CheckpointDir returns the directory for checkpoint and archive images.
+func (d *DataDir) CheckpointDir() string { return filepath.Join(d.root, dataDirCheckpoints) }
+
+// TempDir returns the scratch directory, emptied on every open.
+func (d *DataDir) TempDir() string { return filepath.Join(d.root, dataDirTemp) }
+
+// Close releases the lock. The files stay, unless the DataDir is in
+// memory.
+func (d *DataDir) Close() error {
+	if pagestore.IsMemPath(d.root) {
+		pagestore.RemoveMemAll(d.root)
+		return nil
+	}
+	if d.lock == nil {
+		return nil
+	}
+	err := unlockFile(d.lock)
+	if cErr := d.lock.Close(); err == nil {
+		err = cErr
+	}
+	d.lock = nil
+	return err
+}
+
+// databaseLockedError marks err with ErrDatabaseLocked when it comes from
+// a file already locked.
+func databaseLockedError(err error) error {
+	if goerrors.Is(err, pagestore.ErrLocked) {
+		return fmt.Errorf("%w: %w", ErrDatabaseLocked, err)
+	}
+	return err
+}
@@ -0,0 +1,242 @@
+package storage_test
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	v2 "github.com/bobboyms/storage-engine/pkg/heap/v2"
+	"github.com/bobboyms/storage-engine/pkg/pagestore"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+func TestDataDir_LayoutAndLock(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "data")
+	d, err := storage.OpenDataDir(root)
+	if err != nil {
+		t.Fatalf("OpenDataDir: %v", err)
+	}
+	for _, dir := range []string{d.WALDir(), d.HeapDir(), d.CheckpointDir(), d.TempDir()} {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			t.Fatalf("%s: %v", dir, err)
+		}
+	}
+
+	if _, err := storage.OpenDataDir(root); !errors.Is(err, storage.ErrDatabaseLocked) {
+		t.Fatalf("second OpenDataDir = %v, want ErrDatabaseLocked", err)
+	}
+	if _, err := storage.Open(root, storage.Options{}); !errors.Is(err, storage.ErrDatabaseLocked) {
+		t.Fatalf("Open of a locked directory = %v, want ErrDatabaseLocked", err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	d, err = storage.OpenDataDir(root)
+	if err != nil {
+		t.Fatalf("OpenDataDir after Close: %v", err)
+	}
+	d.Close()
+}
+
+func TestDataDir_RemovesTempFilesOnOpen(t *testing.T) {
+	root := t.TempDir()
+	d, err := storage.OpenDataDir(root)
+	if err != nil {
+		t.Fatalf("OpenDataDir: %v", err)
+	}
+	leftovers := []string{
+		filepath.Join(d.TempDir(), "vacuum-users.heap"),
+		filepath.Join(d.HeapDir(), "users.heap.tmp"),
+		filepath.Join(d.WALDir(), "wal.000002.tmp"),
+		d.CatalogPath() + ".tmp",
+	}
+	kept := []string{filepath.Join(d.HeapDir(), "users.heap"), filepath.Join(d.CheckpointDir(), "image.tmp")}
+	if err := os.MkdirAll(filepath.Join(d.TempDir(), "sort"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	leftovers = append(leftovers, filepath.Join(d.TempDir(), "sort", "run-1"))
+	for _, path := range append(leftovers, kept...) {
+		if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d.Close()
+
+	d, err = storage.OpenDataDir(root)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer d.Close()
+	for _, path := range leftovers {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("%s was not removed: %v", path, err)
+		}
+	}
+	for _, path := range kept {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("%s was removed: %v", path, err)
+		}
+	}
+}
+
+// readDataDirFiles returns the content of every regular file under root
+// but the lock, by path relative to root.
+func readDataDirFiles(t *testing.T, root string) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || d.Name() == "LOCK" {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		files[rel] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("read %s: %v", root, err)
+	}
+	return files
+}
+
+func TestDataDir_CopyOpensItsOwnFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	se, err := storage.Open(dir, storage.Options{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := se.CreateTable("users", []storage.Index{
+		{Name: "id", Primary: true, Type: storage.TypeInt},
+		{Name: "email", Type: storage.TypeVarchar},
+	}); err != nil {
+		t.Fatalf("CreateTable: %v", err)
+	}
+	if err := se.InsertRow("users", `{"id": 1, "email": "ana@example.com"}`, nil); err != nil {
+		t.Fatalf("InsertRow: %v", err)
+	}
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	copied := filepath.Join(t.TempDir(), "copy")
+	copyDataDir(t, dir, copied)
+	original := readDataDirFiles(t, dir)
+
+	se, err = storage.Open(copied, storage.Options{})
+	if err != nil {
+		t.Fatalf("Open copy: %v", err)
+	}
+	if err := se.InsertRow("users", `{"id": 2, "email": "bia@example.com"}`, nil); err != nil {
+		t.Fatalf("InsertRow into copy: %v", err)
+	}
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close copy: %v", err)
+	}
+
+	after := readDataDirFiles(t, dir)
+	if len(after) != len(original) {
+		t.Fatalf("original files changed from %d to %d", len(original), len(after))
+	}
+	for path, data := range original {
+		if after[path] != data {
+			t.Fatalf("original file %s changed when the copy was written", path)
+		}
+	}
+	copies := readDataDirFiles(t, copied)
+	if copies[filepath.Join("heap", "users.heap")] == original[filepath.Join("heap", "users.heap")] {
+		t.Fatal("the copy's heap did not change")
+	}
+
+	se, err = storage.Open(copied, storage.Options{})
+	if err != nil {
+		t.Fatalf("reopen copy: %v", err)
+	}
+	defer se.Close()
+	if _, found, err := se.Get("users", "email", types.VarcharKey("bia@example.com")); err != nil || !found {
+		t.Fatalf("Get from the copy = %v, %v", found, err)
+	}
+}
+
+func TestDatabaseLock_OtherProcess(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "plan9" {
+		t.Skip("file locks are not enforced on " + runtime.GOOS)
+	}
+	dir := t.TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestDatabaseLockChildProcess$")
+	cmd.Env = append(os.Environ(), "STORAGE_ENGINE_LOCK_CHILD="+dir)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start child: %v", err)
+	}
+	defer cmd.Wait()
+	defer stdin.Close()
+	// The child logs recovery before it is ready.
+	lines := bufio.NewScanner(stdout)
+	for lines.Scan() && lines.Text() != "ready" {
+	}
+	if lines.Text() != "ready" {
+		t.Fatalf("child exited before holding the engine: %v", lines.Err())
+	}
+
+	if _, err := storage.Open(dir, storage.Options{}); !errors.Is(err, storage.ErrDatabaseLocked) {
+		t.Fatalf("Open = %v, want ErrDatabaseLocked", err)
+	}
+	heapPath := filepath.Join(dir, "heap", "users.heap")
+	if _, err := storage.NewHeapForTable(storage.HeapFormatV2, heapPath); !errors.Is(err, storage.ErrDatabaseLocked) {
+		t.Fatalf("NewHeapForTable = %v, want ErrDatabaseLocked", err)
+	}
+	if _, err := wal.NewWALWriter(filepath.Join(dir, "wal", "wal.log"), wal.DefaultOptions()); !errors.Is(err, pagestore.ErrLocked) {
+		t.Fatalf("NewWALWriter = %v, want pagestore.ErrLocked", err)
+	}
+	ro, err := v2.NewHeapV2WithOptions(heapPath, v2.HeapOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("read-only heap: %v", err)
+	}
+	ro.Close()
+
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("child: %v", err)
+	}
+	se, err := storage.Open(dir, storage.Options{})
+	if err != nil {
+		t.Fatalf("Open after the child exits: %v", err)
+	}
+	se.Close()
+}
+
+// TestDatabaseLockChildProcess holds an engine open until its stdin closes.
+func TestDatabaseLockChildProcess(t *testing.T) {
+	dir := os.Getenv("STORAGE_ENGINE_LOCK_CHILD")
+	if dir == "" {
+		t.Skip("helper process only")
+	}
+	se, err := storage.Open(dir, storage.Options{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer se.Close()
+	if err := se.CreateTable("users", []storage.Index{{Name: "id", Primary: true, Type: storage.TypeInt}}); err != nil {
+		t.Fatalf("CreateTable: %v", err)
+	}
+	fmt.Println("ready")
+	_, _ = io.Copy(io.Discard, os.Stdin)
+}
@@ -0,0 +1,114 @@
+package storage_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+func TestDeleteRow_RemovesRowFromEveryIndex(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+
+	putEmployee(t, se, 1, "Engineering")
+	putEmployee(t, se, 2, "Engineering")
+
+	snapshot := se.BeginRead()
+	defer snapshot.Close()
+
+	deleted, err := se.DeleteRow("employees", types.IntKey(1))
+	if err != nil || !deleted {
+		t.Fatalf("DeleteRow: deleted=%v err=%v", deleted, err)
+	}
+
+	if _, found, _ := se.Get("employees", "id", types.IntKey(1)); found {
+		t.Fatal("row 1 still visible through the primary index")
+	}
+	docs, _ := se.GetAll("employees", "department", types.VarcharKey("Engineering"))
+	assertIDs(t, "Engineering after DeleteRow", docs, "2")
+
+	docs, _ = snapshot.GetAll("employees", "department", types.VarcharKey("Engineering"))
+	assertIDs(t, "snapshot Engineering", docs, "1", "2")
+
+	deleted, err = se.DeleteRow("employees", types.IntKey(1))
+	if err != nil || deleted {
+		t.Fatalf("second DeleteRow: deleted=%v err=%v", deleted, err)
+	}
+	deleted, err = se.DeleteRow("employees", types.IntKey(42))
+	if err != nil || deleted {
+		t.Fatalf("DeleteRow of a missing row: deleted=%v err=%v", deleted, err)
+	}
+	if _, err := se.DeleteRow("employees", types.VarcharKey("1")); err == nil {
+		t.Fatal("expected an error for a key of the wrong type")
+	}
+}
+
+func TestDeleteRow_SingleWALEntry(t *testing.T) {
+	dir := t.TempDir()
+	se := openEmployeesEngine(t, dir)
+	putEmployee(t, se, 1, "Engineering")
+	if _, err := se.DeleteRow("employees", types.IntKey(1)); err != nil {
+		t.Fatalf("DeleteRow failed: %v", err)
+	}
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reader, err := wal.NewWALReader(filepath.Join(dir, "wal.log"))
+	if err != nil {
+		t.Fatalf("Failed to open WAL: %v", err)
+	}
+	defer reader.Close()
+
+	var entryTypes []uint8
+	for {
+		entry, err := reader.ReadEntry()
+		if err != nil {
+			break
+		}
+		if entry.Header.EntryType != wal.EntryPageRedo && entry.Header.EntryType != wal.EntryClock {
+			entryTypes = append(entryTypes, entry.Header.EntryType)
+		}
+		wal.ReleaseEntry(entry)
+	}
+	if len(entryTypes) != 2 || entryTypes[0] != wal.EntryMultiInsert || entryTypes[1] != wal.EntryMultiDelete {
+		t.Fatalf("expected [MultiInsert MultiDelete] in the WAL, got %v", entryTypes)
+	}
+}
+
+func TestDeleteRow_Recovery(t *testing.T) {
+	dir := t.TempDir()
+	se := openEmployeesEngine(t, dir)
+	putEmployee(t, se, 1, "Engineering")
+	putEmployee(t, se, 2, "Engineering")
+	putEmployee(t, se, 3, "Sales")
+	if _, err := se.DeleteRow("employees", types.IntKey(2)); err != nil {
+		t.Fatalf("DeleteRow failed: %v", err)
+	}
+	// Deleted then inserted again: recovery must keep the new version.
+	if _, err := se.DeleteRow("employees", types.IntKey(3)); err != nil {
+		t.Fatalf("DeleteRow failed: %v", err)
+	}
+	putEmployee(t, se, 3, "HR")
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	se2 := openEmployeesEngine(t, dir)
+	defer se2.Close()
+	if err := se2.Recover(filepath.Join(dir, "wal.log")); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+
+	if _, found, _ := se2.Get("employees", "id", types.IntKey(2)); found {
+		t.Fatal("row 2 reappeared after recovery")
+	}
+	docs, _ := se2.GetAll("employees", "department", types.VarcharKey("Engineering"))
+	assertIDs(t, "recovered Engineering", docs, "1")
+	docs, _ = se2.GetAll("employees", "department", types.VarcharKey("Sales"))
+	assertIDs(t, "recovered Sales", docs)
+	docs, _ = se2.GetAll("employees", "department", types.VarcharKey("HR"))
+	assertIDs(t, "recovered HR", docs, "3")
+}
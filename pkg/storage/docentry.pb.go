@@ -98,6 +98,11 @@ type Key struct {
 	//	*Key_BoolValue
 	//	*Key_FloatValue
 	//	*Key_DateValue
+	//	*Key_ListValue
+	//	*Key_DecimalValue
+	//	*Key_UuidValue
+	//	*Key_BytesValue
+	//	*Key_NullValue
 	Value         isKey_Value `protobuf_oneof:"value"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
@@ -185,6 +190,51 @@ func (x *Key) GetDateValue() int64 {
 	return 0
 }
 
+func (x *Key) GetListValue() *KeyList {
+	if x != nil {
+		if x, ok := x.Value.(*Key_ListValue); ok {
+			return x.ListValue
+		}
+	}
+	return nil
+}
+
+func (x *Key) GetDecimalValue() int64 {
+	if x != nil {
+		if x, ok := x.Value.(*Key_DecimalValue); ok {
+			return x.DecimalValue
+		}
+	}
+	return 0
+}
+
+func (x *Key) GetUuidValue() []byte {
+	if x != nil {
+		if x, ok := x.Value.(*Key_UuidValue); ok {
+			return x.UuidValue
+		}
+	}
+	return nil
+}
+
+func (x *Key) GetBytesValue() []byte {
+	if x != nil {
+		if x, ok := x.Value.(*Key_BytesValue); ok {
+			return x.BytesValue
+		}
+	}
+	return nil
+}
+
+func (x *Key) GetNullValue() bool {
+	if x != nil {
+		if x, ok := x.Value.(*Key_NullValue); ok {
+			return x.NullValue
+		}
+	}
+	return false
+}
+
 type isKey_Value interface {
 	isKey_Value()
 }
@@ -209,6 +259,26 @@ type Key_DateValue struct {
 	DateValue int64 `protobuf:"varint,5,opt,name=date_value,json=dateValue,proto3,oneof"` // UnixNano
 }
 
+type Key_ListValue struct {
+	ListValue *KeyList `protobuf:"bytes,6,opt,name=list_value,json=listValue,proto3,oneof"` // ArrayKey (multikey)
+}
+
+type Key_DecimalValue struct {
+	DecimalValue int64 `protobuf:"varint,7,opt,name=decimal_value,json=decimalValue,proto3,oneof"` // DecimalKey fixed-point units
+}
+
+type Key_UuidValue struct {
+	UuidValue []byte `protobuf:"bytes,8,opt,name=uuid_value,json=uuidValue,proto3,oneof"` // UUIDKey (16 bytes)
+}
+
+type Key_BytesValue struct {
+	BytesValue []byte `protobuf:"bytes,9,opt,name=bytes_value,json=bytesValue,proto3,oneof"`
+}
+
+type Key_NullValue struct {
+	NullValue bool `protobuf:"varint,10,opt,name=null_value,json=nullValue,proto3,oneof"` // NullKey
+}
+
 func (*Key_IntValue) isKey_Value() {}
 
 func (*Key_StringValue) isKey_Value() {}
@@ -219,6 +289,60 @@ func (*Key_FloatValue) isKey_Value() {}
 
 func (*Key_DateValue) isKey_Value() {}
 
+func (*Key_ListValue) isKey_Value() {}
+
+func (*Key_DecimalValue) isKey_Value() {}
+
+func (*Key_UuidValue) isKey_Value() {}
+
+func (*Key_BytesValue) isKey_Value() {}
+
+func (*Key_NullValue) isKey_Value() {}
+
+type KeyList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Values        []*Key                 `protobuf:"bytes,1,rep,name=values,proto3" json:"values,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KeyList) Reset() {
+	*x = KeyList{}
+	mi := &file_pkg_storage_docentry_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KeyList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KeyList) ProtoMessage() {}
+
+func (x *KeyList) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_storage_docentry_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KeyList.ProtoReflect.Descriptor instead.
+func (*KeyList) Descriptor() ([]byte, []int) {
+	return file_pkg_storage_docentry_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *KeyList) GetValues() []*Key {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
 type MultiIndexEntry struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	TableName     string                 `protobuf:"bytes,1,opt,name=table_name,json=tableName,proto3" json:"table_name,omitempty"`
@@ -230,7 +354,7 @@ type MultiIndexEntry struct {
 
 func (x *MultiIndexEntry) Reset() {
 	*x = MultiIndexEntry{}
-	mi := &file_pkg_storage_docentry_proto_msgTypes[2]
+	mi := &file_pkg_storage_docentry_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -242,7 +366,7 @@ func (x *MultiIndexEntry) String() string {
 func (*MultiIndexEntry) ProtoMessage() {}
 
 func (x *MultiIndexEntry) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_storage_docentry_proto_msgTypes[2]
+	mi := &file_pkg_storage_docentry_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -255,7 +379,7 @@ func (x *MultiIndexEntry) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MultiIndexEntry.ProtoReflect.Descriptor instead.
 func (*MultiIndexEntry) Descriptor() ([]byte, []int) {
-	return file_pkg_storage_docentry_proto_rawDescGZIP(), []int{2}
+	return file_pkg_storage_docentry_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *MultiIndexEntry) GetTableName() string {
@@ -290,7 +414,7 @@ const file_pkg_storage_docentry_proto_rawDesc = "" +
 	"\n" +
 	"index_name\x18\x02 \x01(\tR\tindexName\x12\x1e\n" +
 	"\x03key\x18\x03 \x01(\v2\f.storage.KeyR\x03key\x12\x1a\n" +
-	"\bdocument\x18\x04 \x01(\fR\bdocument\"\xb7\x01\n" +
+	"\bdocument\x18\x04 \x01(\fR\bdocument\"\xf6\x02\n" +
 	"\x03Key\x12\x1d\n" +
 	"\tint_value\x18\x01 \x01(\x03H\x00R\bintValue\x12#\n" +
 	"\fstring_value\x18\x02 \x01(\tH\x00R\vstringValue\x12\x1f\n" +
@@ -299,8 +423,20 @@ const file_pkg_storage_docentry_proto_rawDesc = "" +
 	"\vfloat_value\x18\x04 \x01(\x01H\x00R\n" +
 	"floatValue\x12\x1f\n" +
 	"\n" +
-	"date_value\x18\x05 \x01(\x03H\x00R\tdateValueB\a\n" +
-	"\x05value\"\xcb\x01\n" +
+	"date_value\x18\x05 \x01(\x03H\x00R\tdateValue\x121\n" +
+	"\n" +
+	"list_value\x18\x06 \x01(\v2\x10.storage.KeyListH\x00R\tlistValue\x12%\n" +
+	"\rdecimal_value\x18\a \x01(\x03H\x00R\fdecimalValue\x12\x1f\n" +
+	"\n" +
+	"uuid_value\x18\b \x01(\fH\x00R\tuuidValue\x12!\n" +
+	"\vbytes_value\x18\t \x01(\fH\x00R\n" +
+	"bytesValue\x12\x1f\n" +
+	"\n" +
+	"null_value\x18\n" +
+	" \x01(\bH\x00R\tnullValueB\a\n" +
+	"\x05value\"/\n" +
+	"\aKeyList\x12$\n" +
+	"\x06values\x18\x01 \x03(\v2\f.storage.KeyR\x06values\"\xcb\x01\n" +
 	"\x0fMultiIndexEntry\x12\x1d\n" +
 	"\n" +
 	"table_name\x18\x01 \x01(\tR\ttableName\x126\n" +
@@ -322,22 +458,25 @@ func file_pkg_storage_docentry_proto_rawDescGZIP() []byte {
 	return file_pkg_storage_docentry_proto_rawDescData
 }
 
-var file_pkg_storage_docentry_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_pkg_storage_docentry_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
 var file_pkg_storage_docentry_proto_goTypes = []any{
 	(*DocumentEntry)(nil),   // 0: storage.DocumentEntry
 	(*Key)(nil),             // 1: storage.Key
-	(*MultiIndexEntry)(nil), // 2: storage.MultiIndexEntry
-	nil,                     // 3: storage.MultiIndexEntry.KeysEntry
+	(*KeyList)(nil),         // 2: storage.KeyList
+	(*MultiIndexEntry)(nil), // 3: storage.MultiIndexEntry
+	nil,                     // 4: storage.MultiIndexEntry.KeysEntry
 }
 var file_pkg_storage_docentry_proto_depIdxs = []int32{
 	1, // 0: storage.DocumentEntry.key:type_name -> storage.Key
-	3, // 1: storage.MultiIndexEntry.keys:type_name -> storage.MultiIndexEntry.KeysEntry
-	1, // 2: storage.MultiIndexEntry.KeysEntry.value:type_name -> storage.Key
-	3, // [3:3] is the sub-list for method output_type
-	3, // [3:3] is the sub-list for method input_type
-	3, // [3:3] is the sub-list for extension type_name
-	3, // [3:3] is the sub-list for extension extendee
-	0, // [0:3] is the sub-list for field type_name
+	2, // 1: storage.Key.list_value:type_name -> storage.KeyList
+	1, // 2: storage.KeyList.values:type_name -> storage.Key
+	4, // 3: storage.MultiIndexEntry.keys:type_name -> storage.MultiIndexEntry.KeysEntry
+	1, // 4: storage.MultiIndexEntry.KeysEntry.value:type_name -> storage.Key
+	5, // [5:5] is the sub-list for method output_type
+	5, // [5:5] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
 }
 
 func init() { file_pkg_storage_docentry_proto_init() }
@@ -351,6 +490,11 @@ func file_pkg_storage_docentry_proto_init() {
 		(*Key_BoolValue)(nil),
 		(*Key_FloatValue)(nil),
 		(*Key_DateValue)(nil),
+		(*Key_ListValue)(nil),
+		(*Key_DecimalValue)(nil),
+		(*Key_UuidValue)(nil),
+		(*Key_BytesValue)(nil),
+		(*Key_NullValue)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -358,7 +502,7 @@ func file_pkg_storage_docentry_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pkg_storage_docentry_proto_rawDesc), len(file_pkg_storage_docentry_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   4,
+			NumMessages:   5,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	goerrors "errors"
 	"fmt"
 	"io"
@@ -9,12 +10,12 @@ import (
 	"sync/atomic"
 
 	"github.com/bobboyms/storage-engine/pkg/btree"
-	btreev2 "github.com/bobboyms/storage-engine/pkg/btree/v2"
 	"github.com/bobboyms/storage-engine/pkg/crypto"
 	"github.com/bobboyms/storage-engine/pkg/errors"
 	"github.com/bobboyms/storage-engine/pkg/heap"
 	v2 "github.com/bobboyms/storage-engine/pkg/heap/v2"
 	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/tracing"
 	"github.com/bobboyms/storage-engine/pkg/types"
 	"github.com/bobboyms/storage-engine/pkg/wal"
 	"github.com/google/uuid"
@@ -50,6 +51,34 @@ type StorageEngine struct {
 	metaMu        sync.RWMutex // Lock apenas para operações de metadados (ListTables, etc)
 	opMu          sync.RWMutex // Escritas usam RLock; backup online usa Lock para snapshot consistente
 	// Nota: Lock por tabela agora está em Table.mu
+
+	// CheckpointScheduler takes checkpoints in the background once started.
+	CheckpointScheduler *CheckpointScheduler
+
+	// TTLExpirer deletes expired rows in the background once started.
+	TTLExpirer *TTLExpirer
+
+	sequences *sequenceSet
+	changes   *changeFeed
+	triggers  *triggerSet
+	stats     *statsSet
+	tracer    tracing.Slot
+
+	// dataDir and cipher are set by Open, for CreateTable.
+	dataDir *DataDir
+	cipher  crypto.Cipher
+
+	// walClock is the time of the last clock mark in the WAL (unix nanos).
+	walClock atomic.Int64
+	// lsnClock maps time to LSNs for VersionRetention.Duration.
+	lsnClock lsnClock
+
+	// redoVersions is set while Recover replays the WAL.
+	redoVersions *redoVersionIndex
+
+	// checkpointWorkers bounds the page files a checkpoint flushes at
+	// once; 0 means GOMAXPROCS (see SetCheckpointWorkers).
+	checkpointWorkers atomic.Int32
 }
 
 // NewProductionStorageEngine é o construtor recomendado pra uso em produção.
@@ -90,13 +119,14 @@ func NewStorageEngine(tableMetaData *TableMetaData, walWriter *wal.WALWriter) (*
 	//
 	// Só fazemos o SCAN do WAL aqui (leve, O(entries), sem replay).
 	// O rebuild efetivo continua em Recover().
-	initialLSN := uint64(0)
+	initialLSN, initialTxID := uint64(0), uint64(0)
 	if walWriter != nil {
-		maxLSN, err := scanMaxWALLSN(walWriter.Path(), walWriter.Cipher())
+		maxLSN, maxTxID, err := scanWALHighWater(walWriter.Path(), walWriter.Cipher())
 		if err != nil {
 			return nil, fmt.Errorf("storage: failed to synchronize WAL LSN: %w", err)
 		}
 		initialLSN = maxLSN
+		initialTxID = max(maxLSN, maxTxID)
 	}
 
 	se := &StorageEngine{
@@ -104,10 +134,19 @@ func NewStorageEngine(tableMetaData *TableMetaData, walWriter *wal.WALWriter) (*
 		WAL:           walWriter,
 		LockManager:   NewLockManager(LockManagerConfig{}),
 		lsnTracker:    NewLSNTracker(initialLSN),
-		txIDCounter:   initialLSN,
+		txIDCounter:   initialTxID,
 		appliedLSN:    NewAppliedLSNTracker(),
 		TxRegistry:    NewTransactionRegistry(),
+		sequences:     newSequenceSet(),
+		changes:       newChangeFeed(),
+		triggers:      newTriggerSet(),
+		stats:         newStatsSet(),
 	}
+	if walWriter != nil {
+		se.tracer.Set(walWriter.Tracer())
+	}
+	se.CheckpointScheduler = newCheckpointScheduler(se)
+	se.TTLExpirer = newTTLExpirer(se)
 	se.registerPageRedoHooks()
 	return se, nil
 }
@@ -116,24 +155,26 @@ func (se *StorageEngine) nextTxID() uint64 {
 	return atomic.AddUint64(&se.txIDCounter, 1)
 }
 
-// scanMaxWALLSN lê o WAL em `path` procurando o maior LSN. Leve e
-// independente de Recover (que faz replay completo). Arquivo inexistsnte
-// ou empty → retorna 0 sem erro.
-func scanMaxWALLSN(path string, cipher crypto.Cipher) (uint64, error) {
+// scanWALHighWater reads the WAL at `path` for its highest LSN and highest
+// transaction ID, without the replay Recover does. A missing or empty file
+// returns zeros without error. Transaction IDs are not LSNs (autocommit
+// writes take IDs they never log), so the counter must start past both:
+// reusing the ID of a logged transaction would mix the records of the two
+// in recovery.
+func scanWALHighWater(path string, cipher crypto.Cipher) (maxLSN uint64, maxTxID uint64, err error) {
 	if _, err := os.Stat(path); err != nil {
 		if os.IsNotExist(err) {
-			return 0, nil
+			return 0, 0, nil
 		}
-		return 0, err
+		return 0, 0, err
 	}
 
 	reader, err := wal.NewWALReaderWithCipher(path, cipher)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 	defer reader.Close()
 
-	var maxLSN uint64
 	for {
 		entry, err := reader.ReadEntry()
 		if err == io.EOF {
@@ -147,9 +188,14 @@ func scanMaxWALLSN(path string, cipher crypto.Cipher) (uint64, error) {
 		if entry.Header.LSN > maxLSN {
 			maxLSN = entry.Header.LSN
 		}
+		if entry.Header.EntryType != wal.EntryCheckpoint {
+			if txID, _, transactional, err := unwrapTxPayload(entry.Header, entry.Payload); err == nil && transactional {
+				maxTxID = max(maxTxID, txID)
+			}
+		}
 		wal.ReleaseEntry(entry)
 	}
-	return maxLSN, nil
+	return maxLSN, maxTxID, nil
 }
 
 // IsolationLevel define o nível de isolamento da transação
@@ -168,11 +214,33 @@ type Transaction struct {
 }
 
 type visibleRecord struct {
-	Document  string
+	Raw       []byte // the document as stored in the heap (BSON, or legacy JSON)
 	Found     bool
 	CreateLSN uint64
 }
 
+// Document converts the stored document to JSON; legacy documents that
+// are not BSON come back as they are.
+func (r visibleRecord) Document() string {
+	if jsonStr, err := BsonToJson(r.Raw); err == nil {
+		return jsonStr
+	}
+	return string(r.Raw)
+}
+
+// Projected converts only the requested fields to JSON (see ProjectBson).
+// Without fields it returns the whole document.
+func (r visibleRecord) Projected(fields []string) (string, error) {
+	if len(fields) == 0 {
+		return r.Document(), nil
+	}
+	doc, err := storedDocumentBson(r.Raw)
+	if err != nil {
+		return "", fmt.Errorf("storage: cannot project document: %w", err)
+	}
+	return ProjectBsonToJson(doc, fields)
+}
+
 // BeginTransaction inicia uma transação com o nível de isolamento especificado
 func (se *StorageEngine) BeginTransaction(level IsolationLevel) *Transaction {
 	se.opMu.RLock()
@@ -208,6 +276,15 @@ func (se *StorageEngine) Close() error {
 	var err error
 	// TODO: Clean up TxRegistry? Not strictly needed as Engine is closing.
 
+	// No background checkpoint may run against closed trees or WAL.
+	if se.CheckpointScheduler != nil {
+		se.CheckpointScheduler.Stop()
+	}
+	if se.TTLExpirer != nil {
+		se.TTLExpirer.Stop()
+	}
+	se.changes.closeAll()
+
 	// Fecha as trees do runtime page-based.
 	closedTrees := make(map[btree.Tree]bool)
 	for _, tableName := range se.TableMetaData.ListTables() {
@@ -253,15 +330,17 @@ func (se *StorageEngine) Close() error {
 			}
 		}
 	}
-	return err
-}
-
-func (se *StorageEngine) readVisibleValue(tx *Transaction, table *Table, key types.Comparable, currentOffset int64) (string, bool, error) {
-	record, err := se.readVisibleRecord(tx, table, key, currentOffset)
-	if err != nil {
-		return "", false, err
+	// The directory lock goes last, once every file is closed.
+	if se.dataDir != nil {
+		if dErr := se.dataDir.Close(); dErr != nil {
+			if err == nil {
+				err = dErr
+			} else {
+				err = fmt.Errorf("%v; data directory close error: %v", err, dErr)
+			}
+		}
 	}
-	return record.Document, record.Found, nil
+	return err
 }
 
 func (se *StorageEngine) readVisibleRecord(tx *Transaction, table *Table, key types.Comparable, currentOffset int64) (visibleRecord, error) {
@@ -280,16 +359,8 @@ func (se *StorageEngine) readVisibleRecord(tx *Transaction, table *Table, key ty
 				return visibleRecord{}, nil
 			}
 
-			jsonStr, err := BsonToJson(docBytes)
-			if err == nil {
-				return visibleRecord{
-					Document:  jsonStr,
-					Found:     true,
-					CreateLSN: header.CreateLSN,
-				}, nil
-			}
 			return visibleRecord{
-				Document:  string(docBytes),
+				Raw:       docBytes,
 				Found:     true,
 				CreateLSN: header.CreateLSN,
 			}, nil
@@ -300,7 +371,9 @@ func (se *StorageEngine) readVisibleRecord(tx *Transaction, table *Table, key ty
 	return visibleRecord{}, nil
 }
 
-func (se *StorageEngine) visibleRecordForKey(tx *Transaction, tableName string, indexName string, key types.Comparable) (visibleRecord, error) {
+// visibleRecordForKey traces the index lookup as "btree.get" and the
+// version chain walk as "heap.read", under the span of ctx.
+func (se *StorageEngine) visibleRecordForKey(ctx context.Context, tx *Transaction, tableName string, indexName string, key types.Comparable) (visibleRecord, error) {
 	table, err := se.TableMetaData.GetTableByName(tableName)
 	if err != nil {
 		return visibleRecord{}, err
@@ -309,18 +382,45 @@ func (se *StorageEngine) visibleRecordForKey(tx *Transaction, tableName string,
 	if err != nil {
 		return visibleRecord{}, err
 	}
+	if postings, ok := index.postings(); ok {
+		_, span := se.tracer.Start(ctx, "btree.get")
+		records, err := se.visiblePostings(tx, table, postings, key)
+		tracing.End(span, err)
+		if err != nil || len(records) == 0 {
+			return visibleRecord{}, err
+		}
+		return records[0], nil
+	}
+	_, span := se.tracer.Start(ctx, "btree.get")
 	currentOffset, found, err := index.Tree.Get(key)
+	tracing.End(span, err)
 	if err != nil {
 		return visibleRecord{}, fmt.Errorf("tree get: %w", err)
 	}
 	if !found {
 		return visibleRecord{}, nil
 	}
-	return se.readVisibleRecord(tx, table, key, currentOffset)
+	_, span = se.tracer.Start(ctx, "heap.read")
+	record, err := se.readVisibleRecord(tx, table, key, currentOffset)
+	tracing.End(span, err)
+	return record, err
 }
 
 // Put: Insert ou Update com Durabilidade (WAL)
 func (se *StorageEngine) Put(tableName string, indexName string, key types.Comparable, document string) error {
+	return se.PutCtx(context.Background(), tableName, indexName, key, document)
+}
+
+// PutCtx is Put with ctx: a ctx cancelled before the write reaches the WAL
+// (including while waiting for locks) aborts the Put without effect. Once
+// the entry is written the Put completes, since the result has to match
+// the log.
+func (se *StorageEngine) PutCtx(ctx context.Context, tableName string, indexName string, key types.Comparable, document string) (err error) {
+	ctx, span := se.startSpan(ctx, "storage.Put", tableName, indexName)
+	defer func() { tracing.End(span, err) }()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	se.opMu.RLock()
 	defer se.opMu.RUnlock()
 	if err := se.runtimeReadyError(); err != nil {
@@ -348,19 +448,27 @@ func (se *StorageEngine) Put(tableName string, indexName string, key types.Compa
 	bsonDoc, err := JsonToBson(document)
 	var bsonData []byte
 	if err == nil {
+		if err := validateDocument(table, bsonDoc); err != nil {
+			return err
+		}
+
 		// Verify if the key exists
-		exists, keyType := DoesTheKeyExist(bsonDoc, indexName)
+		exists, keyType := DoesTheKeyExist(bsonDoc, index.FieldPath())
 		if !exists {
 			return &errors.IndexNotFoundError{
 				Name: indexName,
 			}
 		}
 
-		// Verify if the key type is valid
+		// Verify if the key type is valid. Decimal and UUID keys are
+		// written in JSON as numbers or strings.
 		if keyType != index.Type {
-			return &errors.InvalidKeyTypeError{
-				Name:     indexName,
-				TypeName: keyType.String(),
+			docKey, err := index.keyFromBson(bsonDoc)
+			if err != nil || getTypeFromKey(docKey) != index.Type {
+				return &errors.InvalidKeyTypeError{
+					Name:     indexName,
+					TypeName: keyType.String(),
+				}
 			}
 		}
 
@@ -374,9 +482,15 @@ func (se *StorageEngine) Put(tableName string, indexName string, key types.Compa
 			if !sameComparableKey(docKey, key) {
 				return fmt.Errorf("storage: key informada %v diverge do campo indexado %s=%v", key, indexName, docKey)
 			}
-			return se.writeRowLocked(tableName, document, keys, false)
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return se.writeRowLocked(ctx, tableName, document, keys, rowUpsert)
 		}
 	} else {
+		if err := validateOpaqueDocument(table); err != nil {
+			return err
+		}
 		// Fallback to raw bytes
 		bsonData = []byte(document)
 	}
@@ -387,9 +501,14 @@ func (se *StorageEngine) Put(tableName string, indexName string, key types.Compa
 	}
 
 	return se.withAutoCommitLocks([]string{resource}, func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		// LSN Management
 		// Geramos o LSN *antes* de escrever no WAL ou Heap para garantir ordem
-		currentLSN := se.lsnTracker.Next()
+		currentLSN := se.lsnTracker.Reserve()
+		defer se.lsnTracker.Release(currentLSN)
 
 		// 1. Write Ahead Log
 		if se.WAL != nil {
@@ -398,38 +517,32 @@ func (se *StorageEngine) Put(tableName string, indexName string, key types.Compa
 				return err
 			}
 
-			entry := wal.AcquireEntry()
-			entry.Header.Magic = wal.WALMagic
-			entry.Header.Version = 1
-			entry.Header.EntryType = wal.EntryInsert // Tratamos Update como Insert no WAL log-structured
-
-			entry.Header.LSN = currentLSN
-
-			entry.Header.PayloadLen = uint32(len(payload))
-			entry.Header.CRC32 = wal.CalculateCRC32(payload)
-			entry.Payload = append(entry.Payload, payload...)
-
-			if err := se.WAL.WriteEntry(entry); err != nil {
-				wal.ReleaseEntry(entry)
-				return fmt.Errorf("wal write failed: %w", err)
+			// An update is logged as an insert in the log-structured WAL
+			if err := se.writeAutoCommitWALCtx(ctx, wal.EntryInsert, payload, currentLSN); err != nil {
+				return err
 			}
-			wal.ReleaseEntry(entry)
 		}
 
 		// 2 ~ 4. Atomic Upsert (Write Heap -> Update Tree)
 		// Usamos Upsert para garantir atomocidade no acesso à versão anterior e atualização do ponteiro HEAD.
 		table.Lock()
 		defer table.Unlock()
+		multiValue := index.IsMultiValue()
+		treeCtx, treeSpan := se.tracer.Start(ctx, "btree.upsert")
 		upsert := func(oldOffset int64, exists bool) (int64, error) {
 			var prevOffset int64 = -1
-			if exists {
+			// Postings of a non-unique key belong to different rows, so
+			// a new one never chains onto an existing posting.
+			if exists && !multiValue {
 				prevOffset = oldOffset
 			}
 
 			// Write to Heap (dentro do Lock da folha - safe mas aumenta latência do lock)
 			// TODO: Otimização futura - Se heap write for lento, refatorar.
 			// Mas como é append-only bufio, must ser rápido.
+			_, heapSpan := se.tracer.Start(treeCtx, "heap.write")
 			offset, err := table.Heap.Write(bsonData, currentLSN, prevOffset)
+			tracing.End(heapSpan, err)
 			if err != nil {
 				return 0, fmt.Errorf("heap write failed: %w", err)
 			}
@@ -437,12 +550,8 @@ func (se *StorageEngine) Put(tableName string, indexName string, key types.Compa
 			return offset, nil
 		}
 
-		if treeV2, ok := index.Tree.(*btreev2.BTreeV2); ok {
-			err = treeV2.UpsertWithLSN(key, currentLSN, upsert)
-		} else {
-			err = index.Tree.Upsert(key, upsert)
-		}
-
+		err := upsertIndexKeyWithLSN(index, key, currentLSN, upsert)
+		tracing.End(treeSpan, err)
 		if err != nil {
 			return err
 		}
@@ -453,8 +562,26 @@ func (se *StorageEngine) Put(tableName string, indexName string, key types.Compa
 	})
 }
 
-// Get executa uma busca no contexto da transação (Snapshot Isolation)
-func (tx *Transaction) Get(tableName string, indexName string, key types.Comparable) (string, bool, error) {
+// Get looks a key up in the transaction's snapshot (Snapshot Isolation).
+//
+// With `fields`, only those fields (dotted paths for nested fields) are
+// converted and returned.
+func (tx *Transaction) Get(tableName string, indexName string, key types.Comparable, fields ...string) (string, bool, error) {
+	return tx.GetCtx(context.Background(), tableName, indexName, key, fields...)
+}
+
+// GetCtx is Get with ctx, checked before the heap read.
+func (tx *Transaction) GetCtx(ctx context.Context, tableName string, indexName string, key types.Comparable, fields ...string) (_ string, found bool, err error) {
+	ctx, span := tx.engine.startSpan(ctx, "storage.Get", tableName, indexName)
+	defer func() {
+		if tracing.Recording(span) {
+			span.SetAttribute("storage.found", found)
+		}
+		tracing.End(span, err)
+	}()
+	if err := ctx.Err(); err != nil {
+		return "", false, err
+	}
 	se := tx.engine
 	se.opMu.RLock()
 	defer se.opMu.RUnlock()
@@ -465,22 +592,105 @@ func (tx *Transaction) Get(tableName string, indexName string, key types.Compara
 	// Se Read Committed, atualiza o snapshot antes de começar
 	tx.refreshSnapshot()
 
-	record, err := se.visibleRecordForKey(tx, tableName, indexName, key)
+	record, err := se.visibleRecordForKey(ctx, tx, tableName, indexName, key)
+	if err != nil || !record.Found {
+		return "", false, err
+	}
+	document, err := record.Projected(fields)
 	if err != nil {
 		return "", false, err
 	}
-	return record.Document, record.Found, nil
+	return document, true, nil
 }
 
 // Get wrapper para conveniência (Autocommit / Snapshot instantâneo)
-func (se *StorageEngine) Get(tableName string, indexName string, key types.Comparable) (string, bool, error) {
+func (se *StorageEngine) Get(tableName string, indexName string, key types.Comparable, fields ...string) (string, bool, error) {
+	return se.GetCtx(context.Background(), tableName, indexName, key, fields...)
+}
+
+// GetCtx is Get with ctx.
+func (se *StorageEngine) GetCtx(ctx context.Context, tableName string, indexName string, key types.Comparable, fields ...string) (string, bool, error) {
 	tx := se.BeginRead()
 	defer tx.Close() // Autocommit: Release transaction registration
-	return tx.Get(tableName, indexName, key)
+	return tx.GetCtx(ctx, tableName, indexName, key, fields...)
+}
+
+// GetAll returns every document visible to the transaction under key.
+// On a non-unique index that is one document per matching row; on a
+// unique index it is at most one.
+func (tx *Transaction) GetAll(tableName string, indexName string, key types.Comparable) ([]string, error) {
+	se := tx.engine
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return nil, err
+	}
+
+	tx.refreshSnapshot()
+
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return nil, err
+	}
+	index, err := table.GetIndex(indexName)
+	if err != nil {
+		return nil, err
+	}
+
+	if isNullKey(key) && !index.Nullable {
+		return []string{}, nil
+	}
+
+	var records []visibleRecord
+	if postings, ok := index.postings(); ok {
+		records, err = se.visiblePostings(tx, table, postings, key)
+	} else {
+		var record visibleRecord
+		record, err = se.visibleRecordForKey(context.Background(), tx, tableName, indexName, key)
+		if record.Found {
+			records = append(records, record)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	documents := make([]string, 0, len(records))
+	for _, record := range records {
+		documents = append(documents, record.Document())
+	}
+	return documents, nil
 }
 
-// Scan executa uma busca por range no contexto da transação
-func (tx *Transaction) Scan(tableName string, indexName string, condition *query.ScanCondition) ([]string, error) {
+// GetAll is Transaction.GetAll on a snapshot taken for the call.
+func (se *StorageEngine) GetAll(tableName string, indexName string, key types.Comparable) ([]string, error) {
+	tx := se.BeginRead()
+	defer tx.Close()
+	return tx.GetAll(tableName, indexName, key)
+}
+
+// Scan runs a range search in the transaction's snapshot. An optional
+// ScanOptions limits the page (Offset/Limit) and sets the direction.
+func (tx *Transaction) Scan(tableName string, indexName string, condition *query.ScanCondition, opts ...ScanOptions) ([]string, error) {
+	return tx.ScanCtx(context.Background(), tableName, indexName, condition, opts...)
+}
+
+// ScanCtx is Scan with ctx: the cursor checks ctx at every index entry
+// and, once it is cancelled, stops and returns ctx.Err() with the rows
+// collected so far.
+func (tx *Transaction) ScanCtx(ctx context.Context, tableName string, indexName string, condition *query.ScanCondition, opts ...ScanOptions) ([]string, error) {
+	ctx, span := tx.engine.startSpan(ctx, "storage.Scan", tableName, indexName)
+	results, err := tx.scan(ctx, tableName, indexName, condition, opts...)
+	if tracing.Recording(span) {
+		span.SetAttribute("storage.rows", len(results))
+	}
+	tracing.End(span, err)
+	return results, err
+}
+
+// scan is ScanCtx under its span; the index cursor, heap reads included,
+// is the child span "btree.scan".
+func (tx *Transaction) scan(ctx context.Context, tableName string, indexName string, condition *query.ScanCondition, opts ...ScanOptions) ([]string, error) {
 	se := tx.engine
 	se.opMu.RLock()
 	defer se.opMu.RUnlock()
@@ -496,70 +706,177 @@ func (tx *Transaction) Scan(tableName string, indexName string, condition *query
 	if err != nil {
 		return nil, err
 	}
+	return tx.scanTable(ctx, table, indexName, condition, opts...)
+}
 
-	// Lock-Free Scan: Cursor thread-safe cuida dos locks de folha
+// scanTable is scan once opMu is held and the table found.
+func (tx *Transaction) scanTable(ctx context.Context, table *Table, indexName string, condition *query.ScanCondition, opts ...ScanOptions) ([]string, error) {
+	results, _, err := tx.scanTableFrom(ctx, table, indexName, condition, nil, opts...)
+	return results, err
+}
 
+// scanTableFrom is scanTable resumed after the entry of cursor (nil starts
+// from the beginning). It also returns the cursor of the last row when the
+// page filled up, nil otherwise.
+func (tx *Transaction) scanTableFrom(ctx context.Context, table *Table, indexName string, condition *query.ScanCondition, cursor *ScanCursor, opts ...ScanOptions) ([]string, *ScanCursor, error) {
+	se := tx.engine
+	page := scanPage(opts)
 	results := []string{}
+	skipped := 0
+	var last ScanCursor
+	var sorter *externalSort
+	if page.OrderBy != nil {
+		sorter = se.newExternalSort(page)
+		defer sorter.close()
+	}
+	emit := func(document func() (string, error)) error {
+		if skipped < page.Offset {
+			skipped++
+			return nil
+		}
+		doc, err := document()
+		if err != nil {
+			return err
+		}
+		results = append(results, doc)
+		if page.Limit > 0 && len(results) >= page.Limit {
+			return errScanPageFull
+		}
+		return nil
+	}
+
+	full, err := tx.walkIndex(ctx, table, indexName, condition, cursor, page.Reverse, func(key types.Comparable, offset int64, record visibleRecord) error {
+		if sorter != nil {
+			return sorter.add(record.Raw)
+		}
+		last = ScanCursor{Key: key, Offset: offset}
+		return emit(func() (string, error) { return record.Projected(page.Projection) })
+	})
+	if err != nil || sorter == nil {
+		var next *ScanCursor
+		if full {
+			next = &last
+		}
+		return results, next, err
+	}
+
+	_, span := se.tracer.Start(ctx, "storage.sort")
+	err = sorter.each(ctx, func(raw []byte) error {
+		return emit(func() (string, error) { return visibleRecord{Raw: raw, Found: true}.Projected(page.Projection) })
+	})
+	if tracing.Recording(span) {
+		span.SetAttribute("storage.sort.spilled_runs", sorter.spilledRuns())
+	}
+	tracing.End(span, err)
+	return results, nil, err
+}
+
+// walkIndex calls fn, in index order, with every row of the index that
+// matches condition and is visible to tx, resuming after the entry of
+// cursor when it is not nil. fn returning errScanPageFull stops the walk
+// early; walkIndex then reports true and no error. The walk is the child
+// span "btree.scan".
+func (tx *Transaction) walkIndex(ctx context.Context, table *Table, indexName string, condition *query.ScanCondition, cursor *ScanCursor, reverse bool, fn func(key types.Comparable, offset int64, record visibleRecord) error) (bool, error) {
+	se := tx.engine
+
+	// Lock-Free Scan: Cursor thread-safe cuida dos locks de folha
+
 	// Obtém o index (já temos o lock da tabela)
 	index, err := table.GetIndex(indexName)
 	if err != nil {
-		return results, err
+		return false, err
 	}
-	if treeV2, ok := index.Tree.(*btreev2.BTreeV2); ok {
-		var scanErr error
-		visit := func(key types.Comparable, currentOffset int64) error {
-			if condition != nil && !condition.Matches(key) {
-				return nil
-			}
-
-			visibleVal, foundVisible, err := se.readVisibleValue(tx, table, key, currentOffset)
-			if err != nil {
-				return err
-			}
-			if foundVisible {
-				results = append(results, visibleVal)
-			}
+	scanner, ok := index.Tree.(rangeScanner)
+	if !ok {
+		return false, fmt.Errorf("Scan: index %s uses unsupported type %T", indexName, index.Tree)
+	}
+	condition = collateCondition(index, condition)
+	multiValue := index.IsMultiValue()
+	seen := multikeySeen(index)
+	entries := 0
+	var from types.Comparable
+	if cursor != nil {
+		from = cursor.Key
+	}
+	visit := func(key types.Comparable, currentOffset int64) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		entries++
+		if cursor != nil && !cursor.before(key, currentOffset, multiValue, reverse) {
+			return nil
+		}
+		if condition != nil && !condition.Matches(key) {
+			return nil
+		}
+		if seen != nil && seen(currentOffset) {
 			return nil
 		}
 
-		if condition != nil {
-			switch condition.Operator {
-			case query.OpEqual:
-				scanErr = treeV2.Scan(condition.Value, condition.Value, visit)
-			case query.OpBetween:
-				scanErr = treeV2.Scan(condition.Value, condition.ValueEnd, visit)
-			default:
-				scanErr = treeV2.ScanAll(visit)
+		record, err := se.readVisibleEntry(tx, table, multiValue, key, currentOffset)
+		if err != nil {
+			return err
+		}
+		if !record.Found {
+			return nil
+		}
+		if condition != nil && condition.NeedsDocument() {
+			matches, err := matchesDocument(condition, key, record.Raw)
+			if err != nil || !matches {
+				return err
 			}
-		} else {
-			scanErr = treeV2.ScanAll(visit)
 		}
-		return results, scanErr
+		return fn(key, currentOffset, record)
 	}
 
-	return results, fmt.Errorf("Scan: index %s uses unsupported type %T", indexName, index.Tree)
+	_, span := se.tracer.Start(ctx, "btree.scan")
+	err = scanIndexRangeFrom(index, scanner, condition, reverse, from, visit)
+	full := goerrors.Is(err, errScanPageFull)
+	if full {
+		err = nil
+	}
+	if tracing.Recording(span) {
+		span.SetAttribute("btree.entries", entries)
+	}
+	tracing.End(span, err)
+	return full, err
 }
 
-// InsertRow insere uma nova linha e atualiza todos os indexs da tabela.
-// Chaves primárias duplicadas fail enquanto o lock exclusivo da tabela está
-// mantido, fechando a corrida check-then-write.
+// InsertRow inserts a new row and updates every index of the table.
+// Duplicate primary keys fail while the table's exclusive lock is held,
+// closing the check-then-write race. It always commits on its own, even
+// while a WriteTransaction is open; use WriteTransaction.InsertRow to
+// write the row inside that transaction.
 func (se *StorageEngine) InsertRow(tableName string, doc string, keys map[string]types.Comparable) error {
-	return se.writeRow(tableName, doc, keys, true)
+	return se.writeRow(tableName, doc, keys, rowInsert)
 }
 
-// UpsertRow insere ou atualiza uma linha inteira mantendo todos os indexs
-// sincronizados. Quando a key primária já exists, a versão anterior é
-// tombstoned no heap; entradas antigas de indexs secundários passam a apontar
-// para uma versão not visible a snapshots novos.
+// UpsertRow inserts or replaces a whole row, keeping every index in sync.
+// When the primary key already exists the previous version is tombstoned
+// in the heap, so old secondary entries point to a version that new
+// snapshots do not see.
 func (se *StorageEngine) UpsertRow(tableName string, doc string, keys map[string]types.Comparable) error {
-	return se.writeRow(tableName, doc, keys, false)
+	return se.writeRow(tableName, doc, keys, rowUpsert)
+}
+
+// UpdateRow replaces an existing row, keeping every index in sync. Keys
+// are extracted from the document; `keys` is optional and, when given,
+// must match the document fields. Returns *errors.RowNotFoundError when
+// there is no live row under the primary key.
+func (se *StorageEngine) UpdateRow(tableName string, doc string, keys map[string]types.Comparable) error {
+	return se.writeRow(tableName, doc, keys, rowUpdate)
 }
 
 // Scan wrapper para conveniência
-func (se *StorageEngine) Scan(tableName string, indexName string, condition *query.ScanCondition) ([]string, error) {
+func (se *StorageEngine) Scan(tableName string, indexName string, condition *query.ScanCondition, opts ...ScanOptions) ([]string, error) {
+	return se.ScanCtx(context.Background(), tableName, indexName, condition, opts...)
+}
+
+// ScanCtx is Scan with ctx.
+func (se *StorageEngine) ScanCtx(ctx context.Context, tableName string, indexName string, condition *query.ScanCondition, opts ...ScanOptions) ([]string, error) {
 	tx := se.BeginRead()
 	defer tx.Close()
-	return tx.Scan(tableName, indexName, condition)
+	return tx.ScanCtx(ctx, tableName, indexName, condition, opts...)
 }
 
 // RangeScan: Wrapper de conveniência para BETWEEN (mantido para compatibilidade)
@@ -597,7 +914,8 @@ func (se *StorageEngine) Del(tableName string, indexName string, key types.Compa
 	var wasFound bool
 	err = se.withAutoCommitLocks([]string{resource}, func() error {
 		// LSN Management
-		currentLSN := se.lsnTracker.Next()
+		currentLSN := se.lsnTracker.Reserve()
+		defer se.lsnTracker.Release(currentLSN)
 
 		// 1. Write Ahead Log
 		if se.WAL != nil {
@@ -607,22 +925,9 @@ func (se *StorageEngine) Del(tableName string, indexName string, key types.Compa
 				return err
 			}
 
-			entry := wal.AcquireEntry()
-			entry.Header.Magic = wal.WALMagic
-			entry.Header.Version = 1
-			entry.Header.EntryType = wal.EntryDelete
-
-			entry.Header.LSN = currentLSN
-
-			entry.Header.PayloadLen = uint32(len(payload))
-			entry.Header.CRC32 = wal.CalculateCRC32(payload)
-			entry.Payload = append(entry.Payload, payload...)
-
-			if err := se.WAL.WriteEntry(entry); err != nil {
-				wal.ReleaseEntry(entry)
-				return fmt.Errorf("wal write failed: %w", err)
+			if err := se.writeAutoCommitWAL(wal.EntryDelete, payload, currentLSN); err != nil {
+				return err
 			}
-			wal.ReleaseEntry(entry)
 		}
 
 		// 2. Modifica Memória e Heap
@@ -658,10 +963,11 @@ func (se *StorageEngine) Del(tableName string, indexName string, key types.Compa
 			return oldOffset, nil
 		}
 
-		if treeV2, ok := index.Tree.(*btreev2.BTreeV2); ok {
-			err = treeV2.UpsertWithLSN(key, currentLSN, upsert)
+		if postings, ok := index.postings(); ok {
+			// Non-unique index: every live row under key is deleted.
+			wasFound, err = tombstonePostings(table, postings, key, currentLSN)
 		} else {
-			err = index.Tree.Upsert(key, upsert)
+			err = upsertIndexKeyWithLSN(index, key, currentLSN, upsert)
 		}
 
 		if err != nil {
@@ -690,11 +996,16 @@ func (se *StorageEngine) Del(tableName string, indexName string, key types.Compa
 	return wasFound, nil
 }
 
-// CreateCheckpoint agora faz flush durável do estado page-based.
-// O formato `.chk` legado is not mais usado pelo runtime do engine.
+// CreateCheckpoint durably flushes the page-based state with writes
+// stopped and writes an EntryCheckpoint with the current LSN and, per
+// table/index, the last LSN applied (the fences). Recovery starts redo at
+// that LSN and skips, in each index, the entries covered by its fence.
+// Trees and heaps are flushed in parallel (see SetCheckpointWorkers) and
+// the record is only written if all of them reached the disk.
+// The legacy `.chk` format is no longer used by the engine runtime.
 func (se *StorageEngine) CreateCheckpoint() error {
-	se.opMu.RLock()
-	defer se.opMu.RUnlock()
+	se.opMu.Lock()
+	defer se.opMu.Unlock()
 	if err := se.runtimeReadyError(); err != nil {
 		return err
 	}
@@ -705,36 +1016,20 @@ func (se *StorageEngine) CreateCheckpoint() error {
 		}
 	}
 
-	syncedTrees := make(map[btree.Tree]bool)
-	syncedHeaps := make(map[heap.Heap]bool)
-
-	for _, tableName := range se.TableMetaData.ListTables() {
-		table, err := se.TableMetaData.GetTableByName(tableName)
-		if err != nil {
-			continue
-		}
-
-		for _, idx := range table.GetIndices() {
-			if idx.Tree != nil && !syncedTrees[idx.Tree] {
-				if treeV2, ok := idx.Tree.(*btreev2.BTreeV2); ok {
-					if err := treeV2.Sync(); err != nil {
-						return err
-					}
-				}
-				syncedTrees[idx.Tree] = true
-			}
-		}
+	if err := se.syncPageFiles(); err != nil {
+		return err
+	}
 
-		if table.Heap != nil && !syncedHeaps[table.Heap] {
-			if heapV2, ok := table.Heap.(*v2.HeapV2); ok {
-				if err := heapV2.Sync(); err != nil {
-					return err
-				}
-			}
-			syncedHeaps[table.Heap] = true
-		}
+	if se.WAL == nil {
+		return nil
 	}
-	return nil
+	// With no writes in flight, everything up to the current LSN is in the pages.
+	current := se.lsnTracker.Current()
+	return se.WAL.WriteCheckpoint(wal.CheckpointRecord{
+		BeginLSN: current,
+		EndLSN:   current,
+		Fences:   se.appliedLSN.Snapshot(),
+	})
 }
 
 // Helper to refresh snapshot for ReadCommitted
@@ -744,27 +1039,65 @@ func (tx *Transaction) refreshSnapshot() {
 	}
 }
 
-// Recover: reconstrói o estado a partir do WAL.
-// NOTA: Deve ser chamado ANTES de qualquer operação concurrent no engine.
-// Durante o recovery, assume acesso exclusivo (startup).
-func (se *StorageEngine) Recover(walPath string) error {
-	return se.RecoverWithCipher(walPath, se.walCipher())
+// Recover rebuilds the state from the WAL.
+// NOTE: It must be called BEFORE any concurrent operation on the engine.
+// Recovery assumes exclusive access (startup).
+// With RecoverOptions the replay stops at a point in time (see RecoverOptions).
+func (se *StorageEngine) Recover(walPath string, opts ...RecoverOptions) error {
+	return se.RecoverWithCipher(walPath, se.walCipher(), opts...)
 }
 
-// RecoverWithCipher reconstrói o estado a partir de um WAL cifrado ou em claro.
-// Use diretamente apenas quando o WALWriter do engine not está disponível.
-func (se *StorageEngine) RecoverWithCipher(walPath string, cipher crypto.Cipher) error {
-	var maxLSN uint64
+// RecoverWithCipher rebuilds the state from an encrypted or plaintext WAL.
+// Call it directly only when the engine's WALWriter is not available.
+//
+// With a tracer (see SetTracer) it runs as the span "storage.Recover", with
+// the phases "recover.analysis", "recover.redo.physical",
+// "recover.redo.logical" and "recover.undo" as children.
+func (se *StorageEngine) RecoverWithCipher(walPath string, cipher crypto.Cipher, opts ...RecoverOptions) (err error) {
+	ctx, span := se.tracer.Start(context.Background(), "storage.Recover")
+	phase := tracing.Noop
+	startPhase := func(name string) {
+		phase.End()
+		_, phase = se.tracer.Start(ctx, name)
+	}
+	defer func() {
+		tracing.End(phase, err)
+		tracing.End(span, err)
+	}()
+
+	var target RecoverOptions
+	if len(opts) > 0 {
+		target = opts[0]
+	}
+	startPhase("recover.analysis")
+	timeline, err := scanRecoveryTimeline(walPath, cipher, target)
+	if err != nil {
+		return err
+	}
+	// Records cut off still hold their LSNs; new writes must not reuse them.
+	maxLSN := timeline.maxLSN
 	loadedLSNs := make(map[string]uint64)
 	pageRedoTargets := se.pageRedoTargets()
 
-	analysis, err := se.analyzeRecoveryWithCipher(walPath, cipher)
+	analysis, err := se.analyzeRecoveryTimeline(walPath, cipher, timeline.discards)
 	if err != nil {
 		return err
 	}
 	if analysis.MaxLSN > maxLSN {
 		maxLSN = analysis.MaxLSN
 	}
+	se.redoVersions = newRedoVersionIndex(analysis.CheckpointLSN)
+	defer func() { se.redoVersions = nil }()
+	var verifier *ReplayVerifier
+	if target.Verify {
+		verifier = NewReplayVerifier()
+	}
+
+	// The checkpoint fences count as LSNs already applied.
+	for key, lsn := range analysis.Fences {
+		loadedLSNs[key] = lsn
+		se.appliedLSN.markKey(key, lsn)
+	}
 
 	// 1. Redo scan-only: relê o WAL inteiro, mas reaplica apenas
 	// operações autocommit ou pertencentes a transações commitadas.
@@ -773,7 +1106,8 @@ func (se *StorageEngine) RecoverWithCipher(walPath string, cipher crypto.Cipher)
 		return nil
 	}
 
-	reader, err := wal.NewWALReaderWithCipher(walPath, cipher)
+	startPhase("recover.redo.physical")
+	reader, err := newRecoveryReader(walPath, cipher, timeline.discards)
 	if err != nil {
 		return err
 	}
@@ -823,7 +1157,8 @@ func (se *StorageEngine) RecoverWithCipher(walPath string, cipher crypto.Cipher)
 		return err
 	}
 
-	reader, err = wal.NewWALReaderWithCipher(walPath, cipher)
+	startPhase("recover.redo.logical")
+	reader, err = newRecoveryReader(walPath, cipher, timeline.discards)
 	if err != nil {
 		return err
 	}
@@ -846,11 +1181,29 @@ func (se *StorageEngine) RecoverWithCipher(walPath string, cipher crypto.Cipher)
 			maxLSN = entry.Header.LSN
 		}
 
+		// Sequence marks are not kept in any page, so they are restored
+		// even from before the checkpoint.
+		if entry.Header.EntryType == wal.EntrySequence {
+			if err := se.sequences.restore(entry.Payload); err != nil {
+				wal.ReleaseEntry(entry)
+				return fmt.Errorf("redo sequence failed at entry %d: %w", count, err)
+			}
+			wal.ReleaseEntry(entry)
+			count++
+			continue
+		}
+
 		payload, shouldRedo, err := analysis.shouldRedo(entry)
 		if err != nil {
 			wal.ReleaseEntry(entry)
 			return fmt.Errorf("redo classification failed at entry %d: %w", count, err)
 		}
+		if verifier != nil {
+			if err := verifier.observeEntry(analysis, entry); err != nil {
+				wal.ReleaseEntry(entry)
+				return fmt.Errorf("replay verification failed at entry %d: %w", count, err)
+			}
+		}
 		if !shouldRedo {
 			skipped++
 			wal.ReleaseEntry(entry)
@@ -869,6 +1222,26 @@ func (se *StorageEngine) RecoverWithCipher(walPath string, cipher crypto.Cipher)
 				wal.ReleaseEntry(entry)
 				return fmt.Errorf("redo multi-insert failed at entry %d: %w", count, err)
 			}
+		case wal.EntryMultiBatch:
+			if err := se.redoMultiInsertBatch(entry, payload, loadedLSNs); err != nil {
+				wal.ReleaseEntry(entry)
+				return fmt.Errorf("redo multi-batch failed at entry %d: %w", count, err)
+			}
+		case wal.EntryMultiDelete:
+			if err := se.redoMultiDeleteEntry(entry, payload, loadedLSNs); err != nil {
+				wal.ReleaseEntry(entry)
+				return fmt.Errorf("redo multi-delete failed at entry %d: %w", count, err)
+			}
+		case wal.EntryMultiDeleteBatch:
+			if err := se.redoMultiDeleteBatch(entry, payload, loadedLSNs); err != nil {
+				wal.ReleaseEntry(entry)
+				return fmt.Errorf("redo multi-delete batch failed at entry %d: %w", count, err)
+			}
+		case wal.EntryTruncate, wal.EntryDropTable:
+			if err := se.redoTableResetEntry(entry, payload, loadedLSNs); err != nil {
+				wal.ReleaseEntry(entry)
+				return fmt.Errorf("redo table reset failed at entry %d: %w", count, err)
+			}
 		case wal.EntryCLR:
 			if err := se.redoCompensationEntry(entry, payload); err != nil {
 				wal.ReleaseEntry(entry)
@@ -884,12 +1257,29 @@ func (se *StorageEngine) RecoverWithCipher(walPath string, cipher crypto.Cipher)
 
 	// 2. Undo-lite: loser txs nunca chegaram ao estado visible porque o
 	// write path só aplica heap/tree after COMMIT durável.
+	startPhase("recover.undo")
 	if err := se.undoLoserTransactions(walPath, cipher, analysis); err != nil {
 		return err
 	}
+	if verifier != nil {
+		if err := verifier.Verify(se); err != nil {
+			return err
+		}
+	}
+	if tracing.Recording(span) {
+		span.SetAttribute("recover.physical_applied", physicalApplied)
+		span.SetAttribute("recover.logical_applied", count)
+		span.SetAttribute("recover.logical_skipped", skipped)
+		span.SetAttribute("recover.max_lsn", maxLSN)
+	}
 
 	se.lsnTracker.Set(maxLSN)
-	atomic.StoreUint64(&se.txIDCounter, maxLSN)
+	atomic.StoreUint64(&se.txIDCounter, max(maxLSN, analysis.MaxTxID))
+	if timeline.cut != nil {
+		if err := se.logDiscard(*timeline.cut); err != nil {
+			return err
+		}
+	}
 	se.clearDegraded()
 	if analysis.CheckpointLSN > 0 {
 		fmt.Printf("Recovered: physical redo applied=%d skipped=%d; logical entries applied=%d skipped=%d (checkpoint LSN=%d → redo start). Current LSN: %d\n",
@@ -911,7 +1301,12 @@ func (se *StorageEngine) walCipher() crypto.Cipher {
 // Vacuum performs Garbage Collection on the specified table.
 // It removes dead Tombstones (deleted records visible to no active transaction)
 // and compacts the Heap file, reclaiming space.
-func (se *StorageEngine) Vacuum(tableName string) error {
+//
+// With a tracer (see SetTracer) it runs as the span "storage.Vacuum",
+// with the heap compaction as its child "heap.vacuum".
+func (se *StorageEngine) Vacuum(tableName string) (err error) {
+	ctx, span := se.startSpan(context.Background(), "storage.Vacuum", tableName, "")
+	defer func() { tracing.End(span, err) }()
 	se.opMu.RLock()
 	defer se.opMu.RUnlock()
 	if err := se.runtimeReadyError(); err != nil {
@@ -926,9 +1321,10 @@ func (se *StorageEngine) Vacuum(tableName string) error {
 	table.Lock()
 	defer table.Unlock()
 
-	// 2. Determine Minimum Visible LSN
-	// Any Tombstone with DeleteLSN < minLSN is safe to remove.
-	minLSN := se.TxRegistry.GetMinActiveLSN()
+	// 2. Determine Minimum Visible LSN, held back by the table's
+	// VersionRetention. Any Tombstone with DeleteLSN < minLSN is safe to remove.
+	minLSN := se.vacuumMinLSN(table)
+	table.advanceVacuumHorizon(min(minLSN, se.lsnTracker.Current()))
 
 	fmt.Printf("Starting Vacuum for table %s. MinLSN: %d\n", tableName, minLSN)
 
@@ -937,7 +1333,12 @@ func (se *StorageEngine) Vacuum(tableName string) error {
 	// reads caem em ErrVacuumed (tratado como fim de chain no
 	// engine.Get).
 	if heapV2, ok := table.Heap.(*v2.HeapV2); ok {
+		_, heapSpan := se.tracer.Start(ctx, "heap.vacuum")
 		n, err := heapV2.Vacuum(minLSN)
+		if tracing.Recording(heapSpan) {
+			heapSpan.SetAttribute("heap.reclaimed", n)
+		}
+		tracing.End(heapSpan, err)
 		if err != nil {
 			return fmt.Errorf("Vacuum v2 failed for table %s: %w", tableName, err)
 		}
@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// ScanAccess is how a Scan reads its index.
+type ScanAccess int
+
+const (
+	// AccessFullScan walks every entry of the index.
+	AccessFullScan ScanAccess = iota
+	// AccessRangeSeek seeks to StartKey and stops after EndKey.
+	AccessRangeSeek
+	// AccessPointSeek seeks each key of Points.
+	AccessPointSeek
+	// AccessNone reads nothing: no key of the index can match.
+	AccessNone
+)
+
+func (a ScanAccess) String() string {
+	return [...]string{"FULL SCAN", "RANGE SEEK", "POINT SEEK", "NONE"}[a]
+}
+
+// ScanPlan describes what Scan does for a condition, without running it.
+// KeyFilter is set when part of the condition is not expressed by the
+// seek, so entries read from the index are re-checked against it;
+// DocumentFilter when the condition tests document fields, checked after
+// each heap read. EstimatedRows is how many index entries the seek reads,
+// before filters and Limit, estimated from the statistics of Analyze; -1
+// when the table was not analyzed.
+type ScanPlan struct {
+	Table          string
+	Index          string
+	Access         ScanAccess
+	Reverse        bool
+	StartKey       types.Comparable
+	EndKey         types.Comparable
+	Points         []types.Comparable
+	KeyFilter      bool
+	DocumentFilter bool
+	Offset         int
+	Limit          int
+	EstimatedRows  int64
+}
+
+// String renders the plan on one line, EXPLAIN style.
+func (p *ScanPlan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v %s.%s", p.Access, p.Table, p.Index)
+	switch p.Access {
+	case AccessRangeSeek:
+		fmt.Fprintf(&b, " [%v, %v]", p.StartKey, p.EndKey)
+	case AccessPointSeek:
+		fmt.Fprintf(&b, " %v", p.Points)
+	}
+	if p.Reverse {
+		b.WriteString(" reverse")
+	}
+	if p.KeyFilter {
+		b.WriteString(", key filter")
+	}
+	if p.DocumentFilter {
+		b.WriteString(", document filter")
+	}
+	if p.Offset > 0 {
+		fmt.Fprintf(&b, ", offset %d", p.Offset)
+	}
+	if p.Limit > 0 {
+		fmt.Fprintf(&b, ", limit %d", p.Limit)
+	}
+	if p.EstimatedRows >= 0 {
+		fmt.Fprintf(&b, ", ~%d rows", p.EstimatedRows)
+	}
+	return b.String()
+}
+
+// Explain returns the plan Scan would follow for the same arguments:
+// which part of the index it seeks, in which direction, and which
+// filters run after the seek. Keys of a collated index are shown as
+// their collation keys.
+func (se *StorageEngine) Explain(tableName string, indexName string, condition *query.ScanCondition, opts ...ScanOptions) (*ScanPlan, error) {
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return nil, err
+	}
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return nil, err
+	}
+	index, err := table.GetIndex(indexName)
+	if err != nil {
+		return nil, err
+	}
+	scanner, ok := index.Tree.(rangeScanner)
+	if !ok {
+		return nil, fmt.Errorf("Explain: index %s uses unsupported type %T", indexName, index.Tree)
+	}
+	page := scanPage(opts)
+	if _, ok := scanner.(reverseRangeScanner); page.Reverse && !ok {
+		return nil, fmt.Errorf("storage: index type %T cannot be scanned in reverse", scanner)
+	}
+
+	condition = collateCondition(index, condition)
+	plan := &ScanPlan{
+		Table:         tableName,
+		Index:         indexName,
+		Reverse:       page.Reverse,
+		Offset:        page.Offset,
+		Limit:         page.Limit,
+		EstimatedRows: -1,
+	}
+	bounds := scanBounds(index, condition)
+	switch {
+	case bounds.empty:
+		plan.Access = AccessNone
+	case bounds.pointSeek:
+		plan.Access, plan.Points = AccessPointSeek, bounds.points
+	case bounds.start != nil:
+		plan.Access, plan.StartKey, plan.EndKey = AccessRangeSeek, bounds.start, bounds.end
+	}
+	if condition != nil {
+		plan.KeyFilter = testsKey(condition) && !seekCoversCondition(condition)
+		plan.DocumentFilter = condition.NeedsDocument()
+	}
+	if stats := se.stats.get(tableName); stats != nil {
+		if indexStats := stats.Indexes[indexName]; indexStats != nil {
+			if estimate, ok := indexStats.estimateEntries(bounds); ok {
+				plan.EstimatedRows = estimate
+			}
+		}
+	}
+	return plan, nil
+}
+
+// seekCoversCondition reports whether the seek alone selects exactly the
+// keys that match condition. An AND is covered when its only operand on
+// the key is.
+func seekCoversCondition(condition *query.ScanCondition) bool {
+	if condition.Field != "" {
+		return false
+	}
+	switch condition.Operator {
+	case query.OpEqual, query.OpIsNull, query.OpBetween, query.OpIn:
+		return true
+	case query.OpAnd:
+		var keyOperand *query.ScanCondition
+		for _, operand := range condition.Conditions {
+			if testsKey(operand) {
+				if keyOperand != nil {
+					return false
+				}
+				keyOperand = operand
+			}
+		}
+		return keyOperand != nil && seekCoversCondition(keyOperand)
+	}
+	return false
+}
+
+// testsKey reports whether condition tests the index key, and not only
+// document fields.
+func testsKey(condition *query.ScanCondition) bool {
+	if condition.Field != "" {
+		return false
+	}
+	switch condition.Operator {
+	case query.OpAnd, query.OpOr, query.OpNot:
+		for _, operand := range condition.Conditions {
+			if testsKey(operand) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
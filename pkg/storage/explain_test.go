@@ -0,0 +1,60 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+func TestExplain_DescribesScanPlans(t *testing.T) {
+	se := openReportsEngine(t, t.TempDir())
+	defer se.Close()
+
+	cases := []struct {
+		name      string
+		index     string
+		condition *query.ScanCondition
+		opts      []storage.ScanOptions
+		want      string
+	}{
+		{"no condition", "id", nil, nil, "FULL SCAN reports.id"},
+		{"equal", "id", query.Equal(types.IntKey(5)), nil, "POINT SEEK reports.id [5]"},
+		{"in", "id", query.In(types.IntKey(9), types.IntKey(3)), nil, "POINT SEEK reports.id [3 9]"},
+		{"between reverse", "dept", query.Between(types.VarcharKey("A"), types.VarcharKey("M")),
+			[]storage.ScanOptions{{Reverse: true, Offset: 10, Limit: 5}},
+			"RANGE SEEK reports.dept [A, M] reverse, offset 10, limit 5"},
+		{"greater than", "id", query.GreaterThan(types.IntKey(5)), nil, "FULL SCAN reports.id, key filter"},
+		{"range and key filter", "id", query.And(query.Between(types.IntKey(1), types.IntKey(9)), query.NotEqual(types.IntKey(4))), nil,
+			"RANGE SEEK reports.id [1, 9], key filter"},
+		{"range and field", "id", query.And(query.Equal(types.IntKey(1)), query.Field("dept", query.Equal(types.VarcharKey("Eng")))), nil,
+			"POINT SEEK reports.id [1], document filter"},
+		{"field only", "id", query.Field("dept", query.Equal(types.VarcharKey("Eng"))), nil, "FULL SCAN reports.id, document filter"},
+		{"null on non-nullable index", "id", query.IsNull(), nil, "NONE reports.id"},
+		{"null on nullable index", "manager", query.IsNull(), nil, "POINT SEEK reports.manager [NULL]"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			plan, err := se.Explain("reports", tc.index, tc.condition, tc.opts...)
+			if err != nil {
+				t.Fatalf("Explain: %v", err)
+			}
+			if got := plan.String(); got != tc.want {
+				t.Fatalf("plan = %q, want %q", got, tc.want)
+			}
+			if plan.EstimatedRows != -1 {
+				t.Fatalf("EstimatedRows = %d without statistics, want -1", plan.EstimatedRows)
+			}
+		})
+	}
+}
+
+func TestExplain_UnknownIndex(t *testing.T) {
+	se := openReportsEngine(t, t.TempDir())
+	defer se.Close()
+
+	if _, err := se.Explain("reports", "missing", nil); err == nil {
+		t.Fatal("Explain on a missing index should fail")
+	}
+}
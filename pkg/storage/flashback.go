@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+// ErrFlashbackConflict is returned by UndoTransaction when a row it would
+// restore was changed again after the undone range.
+var ErrFlashbackConflict = errors.New("storage: row changed after the range to undo")
+
+// Flashback restores the row under the primary key key to the state it
+// had at toLSN, read from its MVCC version chain: the old document is
+// written back as a new version, or the row is deleted if it did not
+// exist then. The restore is an ordinary logged write, so it is durable
+// and can itself be flashed back. Reports whether the row changed.
+//
+// Fails with ErrSnapshotTooOld when vacuum may have freed the version
+// (see VersionRetention).
+func (se *StorageEngine) Flashback(tableName string, key types.Comparable, toLSN uint64) (bool, error) {
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return false, err
+	}
+	primary, err := primaryIndex(table)
+	if err != nil {
+		return false, err
+	}
+	row := flashbackRow{table: table, index: primary.Name, key: key, whole: len(table.GetIndices()) > 1}
+	past, err := se.rowStateAt(row, toLSN)
+	if err != nil {
+		return false, err
+	}
+	return se.restoreRow(row, past)
+}
+
+// UndoTransaction reverts the committed writes logged from firstLSN to
+// lastLSN, typically the BEGIN and COMMIT of a bad transaction: every row
+// they touched is flashed back to its state at firstLSN-1. Nothing is
+// written if any of those rows was changed after lastLSN (the change
+// would be lost); that fails with ErrFlashbackConflict. Rows already
+// restored are left alone, so an interrupted undo can be run again.
+// Truncates and drops cannot be undone. Returns the number of rows
+// restored.
+//
+// Each row is restored by its own logged write; the undo as a whole is
+// not atomic.
+func (se *StorageEngine) UndoTransaction(firstLSN, lastLSN uint64) (int, error) {
+	if se.WAL == nil {
+		return 0, fmt.Errorf("storage: UndoTransaction needs the WAL")
+	}
+	if firstLSN == 0 || lastLSN < firstLSN {
+		return 0, fmt.Errorf("storage: invalid LSN range [%d, %d]", firstLSN, lastLSN)
+	}
+	rows, err := se.rowsLoggedBetween(firstLSN, lastLSN)
+	if err != nil {
+		return 0, err
+	}
+
+	pasts := make([]visibleRecord, len(rows))
+	for i, row := range rows {
+		past, err := se.rowStateAt(row, firstLSN-1)
+		if err != nil {
+			return 0, err
+		}
+		after, err := se.rowStateAt(row, lastLSN)
+		if err != nil {
+			return 0, err
+		}
+		now, err := se.rowStateAt(row, se.lsnTracker.Current())
+		if err != nil {
+			return 0, err
+		}
+		if !sameRowState(now, after) && !sameRowState(now, past) {
+			return 0, fmt.Errorf("%w: %s.%s key %v", ErrFlashbackConflict, row.table.Name, row.index, row.key)
+		}
+		pasts[i] = past
+	}
+
+	restored := 0
+	for i, row := range rows {
+		changed, err := se.restoreRow(row, pasts[i])
+		if err != nil {
+			return restored, err
+		}
+		if changed {
+			restored++
+		}
+	}
+	return restored, nil
+}
+
+// flashbackRow is a row to restore. whole rows are rewritten with
+// UpsertRow and DeleteRow; the others, logged by Put and Del on index,
+// with Put and Del.
+type flashbackRow struct {
+	table *Table
+	index string
+	key   types.Comparable
+	whole bool
+}
+
+// rowsLoggedBetween returns the rows written by the committed records
+// logged from firstLSN to lastLSN, once each, in log order.
+func (se *StorageEngine) rowsLoggedBetween(firstLSN, lastLSN uint64) ([]flashbackRow, error) {
+	if err := se.WAL.Sync(); err != nil {
+		return nil, err
+	}
+	reader, err := wal.NewWALReaderWithCipher(se.WAL.Path(), se.WAL.Cipher())
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var rows []flashbackRow
+	seen := make(map[string]bool)
+	for {
+		entry, err := reader.ReadEntry()
+		if err == io.EOF || (err != nil && isExpectedWALTail(err)) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("storage: read wal: %w", err)
+		}
+		header := entry.Header
+		if header.LSN < firstLSN || header.LSN > lastLSN || !isChangeEntry(header.EntryType) {
+			wal.ReleaseEntry(entry)
+			continue
+		}
+		_, payload, _, err := unwrapTxPayload(header, entry.Payload)
+		if err != nil {
+			wal.ReleaseEntry(entry)
+			return nil, err
+		}
+		records, err := decodeLogicalRecords(header.EntryType, payload, header.LSN)
+		wal.ReleaseEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, record := range records {
+			if record.Op == LogicalTruncate || record.Op == LogicalDropTable {
+				return nil, fmt.Errorf("storage: cannot undo the %s of table %s at LSN %d", record.Op, record.Table, header.LSN)
+			}
+			table, err := se.TableMetaData.GetTableByName(record.Table)
+			if err != nil {
+				return nil, err
+			}
+			indexName, key := se.changePrimaryKey(record.Table, record.Keys)
+			resource, err := lockResourceForKey(record.Table, indexName, key)
+			if err != nil {
+				return nil, err
+			}
+			if seen[resource] {
+				continue
+			}
+			seen[resource] = true
+			rows = append(rows, flashbackRow{
+				table: table,
+				index: indexName,
+				key:   key,
+				whole: len(record.Keys) > 1,
+			})
+		}
+	}
+}
+
+// rowStateAt reads row as a snapshot at lsn sees it. Aborted writes are
+// never in a version chain, so they do not show.
+func (se *StorageEngine) rowStateAt(row flashbackRow, lsn uint64) (visibleRecord, error) {
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return visibleRecord{}, err
+	}
+	if err := se.snapshotLSNError(row.table, lsn); err != nil {
+		return visibleRecord{}, err
+	}
+	view := &Transaction{SnapshotLSN: lsn, Level: RepeatableRead, engine: se}
+	return se.visibleRecordForKey(context.Background(), view, row.table.Name, row.index, row.key)
+}
+
+// restoreRow writes past back as the current state of row, unless it
+// already is.
+func (se *StorageEngine) restoreRow(row flashbackRow, past visibleRecord) (bool, error) {
+	now, err := se.rowStateAt(row, se.lsnTracker.Current())
+	if err != nil || sameRowState(now, past) {
+		return false, err
+	}
+	tableName := row.table.Name
+	switch {
+	case past.Found && row.whole:
+		err = se.UpsertRow(tableName, past.Document(), nil)
+	case past.Found:
+		err = se.Put(tableName, row.index, row.key, past.Document())
+	case row.whole:
+		_, err = se.DeleteRow(tableName, row.key)
+	default:
+		_, err = se.Del(tableName, row.index, row.key)
+	}
+	return err == nil, err
+}
+
+func sameRowState(a, b visibleRecord) bool {
+	return a.Found == b.Found && (!a.Found || bytes.Equal(a.Raw, b.Raw))
+}
@@ -0,0 +1,123 @@
+package storage_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+func openFlashbackEngine(t *testing.T) *storage.StorageEngine {
+	t.Helper()
+	se, err := storage.Open(t.TempDir(), storage.Options{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { se.Close() })
+	if err := se.CreateTable("users", []storage.Index{
+		{Name: "id", Primary: true, Type: storage.TypeInt},
+		{Name: "email", Type: storage.TypeVarchar},
+	}); err != nil {
+		t.Fatalf("CreateTable: %v", err)
+	}
+	for _, doc := range []string{
+		`{"id": 1, "email": "ana@example.com"}`,
+		`{"id": 2, "email": "bia@example.com"}`,
+	} {
+		if err := se.InsertRow("users", doc, nil); err != nil {
+			t.Fatalf("InsertRow: %v", err)
+		}
+	}
+	return se
+}
+
+func wantRow(t *testing.T, se *storage.StorageEngine, id int64, want string) {
+	t.Helper()
+	doc, found, err := se.Get("users", "id", types.IntKey(id))
+	if err != nil {
+		t.Fatalf("Get %d: %v", id, err)
+	}
+	if want == "" {
+		if found {
+			t.Fatalf("row %d = %s, want none", id, doc)
+		}
+		return
+	}
+	if !found || doc != want {
+		t.Fatalf("row %d = %q (%v), want %q", id, doc, found, want)
+	}
+}
+
+func TestFlashback_RestoresRow(t *testing.T) {
+	se := openFlashbackEngine(t)
+	lsn := se.CurrentLSN()
+	if err := se.UpdateRow("users", `{"id": 1, "email": "wrong@example.com"}`, nil); err != nil {
+		t.Fatalf("UpdateRow: %v", err)
+	}
+	if _, err := se.DeleteRow("users", types.IntKey(2)); err != nil {
+		t.Fatalf("DeleteRow: %v", err)
+	}
+
+	for _, id := range []int64{1, 2} {
+		if changed, err := se.Flashback("users", types.IntKey(id), lsn); err != nil || !changed {
+			t.Fatalf("Flashback %d = %v, %v", id, changed, err)
+		}
+	}
+	wantRow(t, se, 1, `{"id":1,"email":"ana@example.com"}`)
+	wantRow(t, se, 2, `{"id":2,"email":"bia@example.com"}`)
+	if _, found, err := se.Get("users", "email", types.VarcharKey("wrong@example.com")); err != nil || found {
+		t.Fatalf("secondary index still finds the bad email: %v, %v", found, err)
+	}
+	if changed, err := se.Flashback("users", types.IntKey(1), lsn); err != nil || changed {
+		t.Fatalf("Flashback of a restored row = %v, %v", changed, err)
+	}
+	// Before its insert the row did not exist.
+	if changed, err := se.Flashback("users", types.IntKey(2), 0); err != nil || !changed {
+		t.Fatalf("Flashback to LSN 0 = %v, %v", changed, err)
+	}
+	wantRow(t, se, 2, "")
+}
+
+func TestUndoTransaction(t *testing.T) {
+	se := openFlashbackEngine(t)
+	first := se.CurrentLSN() + 1
+	if err := se.UpdateRow("users", `{"id": 1, "email": "wrong@example.com"}`, nil); err != nil {
+		t.Fatalf("UpdateRow: %v", err)
+	}
+	if _, err := se.DeleteRow("users", types.IntKey(2)); err != nil {
+		t.Fatalf("DeleteRow: %v", err)
+	}
+	tx := se.BeginWriteTransaction()
+	if err := tx.InsertRow("users", `{"id": 3, "email": "caio@example.com"}`, nil); err != nil {
+		t.Fatalf("InsertRow: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	last := se.CurrentLSN()
+
+	restored, err := se.UndoTransaction(first, last)
+	if err != nil || restored != 3 {
+		t.Fatalf("UndoTransaction = %d, %v", restored, err)
+	}
+	wantRow(t, se, 1, `{"id":1,"email":"ana@example.com"}`)
+	wantRow(t, se, 2, `{"id":2,"email":"bia@example.com"}`)
+	wantRow(t, se, 3, "")
+	if restored, err := se.UndoTransaction(first, last); err != nil || restored != 0 {
+		t.Fatalf("second UndoTransaction = %d, %v", restored, err)
+	}
+
+	first = se.CurrentLSN() + 1
+	if err := se.UpdateRow("users", `{"id": 1, "email": "bad@example.com"}`, nil); err != nil {
+		t.Fatalf("UpdateRow: %v", err)
+	}
+	last = se.CurrentLSN()
+	if err := se.UpdateRow("users", `{"id": 1, "email": "later@example.com"}`, nil); err != nil {
+		t.Fatalf("UpdateRow: %v", err)
+	}
+	if _, err := se.UndoTransaction(first, last); !errors.Is(err, storage.ErrFlashbackConflict) {
+		t.Fatalf("UndoTransaction over a later change = %v, want ErrFlashbackConflict", err)
+	}
+	wantRow(t, se, 1, `{"id":1,"email":"later@example.com"}`)
+}
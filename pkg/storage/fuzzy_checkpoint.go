@@ -1,43 +1,43 @@
 package storage
 
-// FuzzyCheckpoint é um checkpoint not-bloqueante para writes.
+// FuzzyCheckpoint is a checkpoint that does not block writes.
 //
-// Diferença do CreateCheckpoint (hard checkpoint):
-//   - CreateCheckpoint: comportamento idêntico, mas NOT grava record WAL.
-//     Recovery precisa reprocessar o WAL inteiro.
-//   - FuzzyCheckpoint: grava um record EntryCheckpoint no WAL com o
-//     beginLSN. Recovery usa esse LSN para pular entradas antigas e
-//     iniciar o redo só a partir daí, reduzindo O(WAL completo) para
-//     O(WAL desde o último checkpoint).
+// Difference from CreateCheckpoint (hard checkpoint):
+//   - CreateCheckpoint: stops writes (exclusive opMu) during the flush
+//     and writes, besides the beginLSN, the fence of every table/index.
+//   - FuzzyCheckpoint: writes an EntryCheckpoint record to the WAL with
+//     the beginLSN. Recovery uses that LSN to skip old entries and start
+//     the redo only from there, going from O(whole WAL) to O(WAL since
+//     the last checkpoint).
 //
-// Semântica de not-bloqueio:
-//   Not adquire lock global de tabelas. As pages são flushadas com
-//   latches por-frame (como sempre), então writes em pages DIFERENTES
-//   das que estão sendo flushadas prosseguem em paralelo. O único
-//   "bloqueio" é por-page e é de curtíssima duração.
+// Non-blocking semantics:
+//   It takes no global table lock. Pages are flushed under per-frame
+//   latches (as always), so writes to pages OTHER than the ones being
+//   flushed proceed in parallel. The only "blocking" is per page and
+//   very short.
 //
-// Garantia para recovery:
-//   Todas as pages sujas com LSN ≤ beginLSN são flushadas antes do
-//   record de checkpoint ser escrito. Portanto, recovery pode assumir
-//   que operações com LSN < beginLSN estão duravelmente em disco e pode
-//   pular o redo delas.
+// Guarantee for recovery:
+//   beginLSN is at most the oldest write still in flight when the flush
+//   starts (see LSNTracker.OldestUnapplied): every write with a lower LSN
+//   was already in the pages, and every dirty page is flushed before the
+//   checkpoint record is written. So recovery can assume that operations
+//   with LSN < beginLSN are durably on disk and skip their redo. The
+//   pages may also carry later writes, up to the record's endLSN; the
+//   redo from beginLSN is idempotent and does not duplicate them.
 //
-// Uso recomendado: substitui CreateCheckpoint em produção. O engine
-// mantém CreateCheckpoint para compatibilidade e uso em testes.
+// Recommended use: it replaces CreateCheckpoint in production. The engine
+// keeps CreateCheckpoint for compatibility and for tests.
 
 import (
 	"fmt"
 	"math"
 
-	"github.com/bobboyms/storage-engine/pkg/btree"
-	btreev2 "github.com/bobboyms/storage-engine/pkg/btree/v2"
-	"github.com/bobboyms/storage-engine/pkg/heap"
-	v2 "github.com/bobboyms/storage-engine/pkg/heap/v2"
+	"github.com/bobboyms/storage-engine/pkg/wal"
 )
 
-// FuzzyCheckpoint executa um checkpoint not-bloqueante e grava um record
-// de checkpoint no WAL, permitindo que recovery pule entradas anteriores
-// ao beginLSN.
+// FuzzyCheckpoint runs a non-blocking checkpoint and writes a checkpoint
+// record to the WAL, letting recovery skip the entries before the
+// beginLSN.
 func (se *StorageEngine) FuzzyCheckpoint() error {
 	se.opMu.RLock()
 	defer se.opMu.RUnlock()
@@ -50,36 +50,49 @@ func (se *StorageEngine) FuzzyCheckpoint() error {
 
 func (se *StorageEngine) fuzzyCheckpointLocked() error {
 	if se.WAL == nil {
-		// Sem WAL there is no recovery, checkpoint fuzzy é no-op.
+		// Without a WAL there is no recovery; a fuzzy checkpoint is a no-op.
 		return nil
 	}
 
-	// 1. Determina o menor pageLSN ainda sujo. Esse é o ponto seguro de
-	//    redo para o checkpoint, porque qualquer page anterior já está
-	//    durável e qualquer page suja a partir daqui será flushada já.
-	beginLSN := se.oldestDirtyPageLSN()
-	if beginLSN == 0 {
-		beginLSN = se.lsnTracker.Current()
+	// 1. Pick the beginLSN: the oldest write still in flight (LSN
+	//    already reserved, pages not yet touched) or, with none, the
+	//    current LSN. Everything before it is already in the pages, dirty
+	//    or not, and the flush below takes those pages to disk. The
+	//    oldest dirty pageLSN only matters when it is older still.
+	beginLSN := min(se.lsnTracker.OldestUnapplied(), se.lsnTracker.Current())
+	if dirty := se.oldestDirtyPageLSN(); dirty != 0 && dirty < beginLSN {
+		beginLSN = dirty
 	}
 
-	// 2. Flush do WAL: garante que entradas até beginLSN estão em disco.
+	// 2. Flush the WAL: entries up to beginLSN must be on disk.
 	if err := se.WAL.Sync(); err != nil {
 		return fmt.Errorf("fuzzy checkpoint: sync WAL: %w", err)
 	}
 
-	// 3. Flush das pages sujas — not bloqueia writes (per-frame latch).
+	// 3. Flush the dirty pages; this does not block writes (per-frame
+	//    latch). Concurrent writes may land in the flushed pages: the
+	//    endLSN marks how far.
 	if err := se.flushAllDirtyPages(); err != nil {
 		return fmt.Errorf("fuzzy checkpoint: flush pages: %w", err)
 	}
+	endLSN := se.lsnTracker.Current()
 
-	// 4. Grava o record de checkpoint no WAL com o beginLSN.
-	//    Recovery encontrará este record e iniciará o redo a partir de beginLSN.
-	if err := se.WAL.WriteCheckpointRecord(beginLSN); err != nil {
-		return fmt.Errorf("fuzzy checkpoint: escrever record WAL: %w", err)
+	// 4. Log the sequences again: the lifecycle may remove the
+	//    segments that held the earlier records.
+	if err := se.logAllSequences(); err != nil {
+		return fmt.Errorf("fuzzy checkpoint: sequences: %w", err)
+	}
+
+	// 5. Write the checkpoint record to the WAL with beginLSN and endLSN.
+	//    Recovery finds this record and starts the redo from beginLSN;
+	//    the redo is idempotent, so reapplying writes the flush already
+	//    took to disk (up to endLSN) duplicates nothing.
+	if err := se.WAL.WriteCheckpoint(wal.CheckpointRecord{BeginLSN: beginLSN, EndLSN: endLSN}); err != nil {
+		return fmt.Errorf("fuzzy checkpoint: write WAL record: %w", err)
 	}
 
 	if err := se.WAL.CheckpointLifecycle(beginLSN); err != nil {
-		return fmt.Errorf("fuzzy checkpoint: lifecycle WAL: %w", err)
+		return fmt.Errorf("fuzzy checkpoint: WAL lifecycle: %w", err)
 	}
 
 	return nil
@@ -108,17 +121,19 @@ func (se *StorageEngine) oldestDirtyPageLSN() uint64 {
 		}
 
 		for _, idx := range table.GetIndices() {
-			hookable, ok := idx.Tree.(redoHookable)
-			if !ok {
-				continue
-			}
-			for _, info := range hookable.DirtyPages() {
-				if info.PageLSN == 0 {
+			for _, fileTree := range indexFileTrees(idx.Tree) {
+				hookable, ok := fileTree.(redoHookable)
+				if !ok {
 					continue
 				}
-				found = true
-				if info.PageLSN < oldest {
-					oldest = info.PageLSN
+				for _, info := range hookable.DirtyPages() {
+					if info.PageLSN == 0 {
+						continue
+					}
+					found = true
+					if info.PageLSN < oldest {
+						oldest = info.PageLSN
+					}
 				}
 			}
 		}
@@ -130,39 +145,9 @@ func (se *StorageEngine) oldestDirtyPageLSN() uint64 {
 	return oldest
 }
 
-// flushAllDirtyPages flusha todas as pages sujas de heaps e trees
-// sem adquirir locks globais de tabela.
+// flushAllDirtyPages flushes every dirty page of the heaps and trees
+// without taking global table locks, several files at once (see
+// syncPageFiles).
 func (se *StorageEngine) flushAllDirtyPages() error {
-	syncedTrees := make(map[btree.Tree]bool)
-	syncedHeaps := make(map[heap.Heap]bool)
-
-	for _, tableName := range se.TableMetaData.ListTables() {
-		table, err := se.TableMetaData.GetTableByName(tableName)
-		if err != nil {
-			continue
-		}
-
-		for _, idx := range table.GetIndices() {
-			if idx.Tree == nil || syncedTrees[idx.Tree] {
-				continue
-			}
-			if treeV2, ok := idx.Tree.(*btreev2.BTreeV2); ok {
-				if err := treeV2.Sync(); err != nil {
-					return err
-				}
-			}
-			syncedTrees[idx.Tree] = true
-		}
-
-		if table.Heap == nil || syncedHeaps[table.Heap] {
-			continue
-		}
-		if heapV2, ok := table.Heap.(*v2.HeapV2); ok {
-			if err := heapV2.Sync(); err != nil {
-				return err
-			}
-		}
-		syncedHeaps[table.Heap] = true
-	}
-	return nil
+	return se.syncPageFiles()
 }
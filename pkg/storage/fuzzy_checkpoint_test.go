@@ -311,3 +311,137 @@ func TestFuzzyCheckpoint_RotatesAndTruncatesWALSafely(t *testing.T) {
 		}
 	}
 }
+
+func TestCreateCheckpoint_WritesIndexFences(t *testing.T) {
+	dir := t.TempDir()
+	walPath := filepath.Join(dir, "wal.log")
+	se := setupEngineWithWAL(t, dir, "users")
+
+	for i := 1; i <= 5; i++ {
+		if err := se.Put("users", "id", types.IntKey(i), fmt.Sprintf(`{"id":%d}`, i)); err != nil {
+			t.Fatalf("Put %d: %v", i, err)
+		}
+	}
+	fence := se.lsnTracker.Current()
+	if err := se.CreateCheckpoint(); err != nil {
+		t.Fatalf("CreateCheckpoint: %v", err)
+	}
+	for i := 6; i <= 7; i++ {
+		if err := se.Put("users", "id", types.IntKey(i), fmt.Sprintf(`{"id":%d}`, i)); err != nil {
+			t.Fatalf("Put %d: %v", i, err)
+		}
+	}
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	analysis, err := se.analyzeRecoveryWithCipher(walPath, nil)
+	if err != nil {
+		t.Fatalf("analyzeRecovery: %v", err)
+	}
+	if analysis.CheckpointLSN != fence {
+		t.Fatalf("CheckpointLSN = %d, want %d", analysis.CheckpointLSN, fence)
+	}
+	if got := analysis.Fences[appliedLSNKey("users", "id")]; got != fence {
+		t.Fatalf("fence users.id = %d, want %d", got, fence)
+	}
+
+	se2 := setupEngineWithWAL(t, dir, "users")
+	if err := se2.Recover(walPath); err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	for i := 1; i <= 7; i++ {
+		if _, found, err := se2.Get("users", "id", types.IntKey(i)); err != nil || !found {
+			t.Fatalf("Get %d after recovery = %v, %v", i, found, err)
+		}
+	}
+	if got := se2.appliedLSN.Get("users", "id"); got <= fence {
+		t.Fatalf("applied LSN after recovery = %d, want > %d", got, fence)
+	}
+}
+
+// TestFuzzyCheckpoint_InFlightWriteIsRedone: a write already logged but
+// not yet applied when the checkpoint runs (here, key 99) must stay in
+// the redo, even with a later write already applied and its page the
+// only dirty one.
+func TestFuzzyCheckpoint_InFlightWriteIsRedone(t *testing.T) {
+	dir := t.TempDir()
+	walPath := filepath.Join(dir, "wal.log")
+	se := setupEngineWithWAL(t, dir, "users")
+	for i := 1; i <= 5; i++ {
+		if err := se.Put("users", "id", types.IntKey(i), fmt.Sprintf(`{"id":%d}`, i)); err != nil {
+			t.Fatalf("Put %d: %v", i, err)
+		}
+	}
+	if err := se.CreateCheckpoint(); err != nil {
+		t.Fatalf("CreateCheckpoint: %v", err)
+	}
+
+	// The in-flight write: LSN reserved and record in the WAL, pages untouched.
+	inFlight := se.lsnTracker.Reserve()
+	doc, err := JsonToBson(`{"id":99}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bsonData, err := MarshalBson(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := SerializeDocumentEntry("users", "id", types.IntKey(99), bsonData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := se.writeAutoCommitWAL(wal.EntryInsert, payload, inFlight); err != nil {
+		t.Fatal(err)
+	}
+	if err := se.Put("users", "id", types.IntKey(100), `{"id":100}`); err != nil {
+		t.Fatalf("Put 100: %v", err)
+	}
+
+	if err := se.FuzzyCheckpoint(); err != nil {
+		t.Fatalf("FuzzyCheckpoint: %v", err)
+	}
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	analysis, err := se.analyzeRecoveryWithCipher(walPath, nil)
+	if err != nil {
+		t.Fatalf("analyzeRecovery: %v", err)
+	}
+	if analysis.CheckpointLSN == 0 || analysis.CheckpointLSN > inFlight {
+		t.Fatalf("CheckpointLSN = %d, want <= the in-flight LSN %d", analysis.CheckpointLSN, inFlight)
+	}
+	if analysis.MaxLSN <= inFlight {
+		t.Fatalf("MaxLSN = %d, want past the in-flight LSN %d", analysis.MaxLSN, inFlight)
+	}
+
+	se2 := setupEngineWithWAL(t, dir, "users")
+	if err := se2.Recover(walPath); err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	for _, id := range []int64{1, 5, 99, 100} {
+		if _, found, err := se2.Get("users", "id", types.IntKey(id)); err != nil || !found {
+			t.Fatalf("Get %d after recovery = %v, %v", id, found, err)
+		}
+	}
+}
+
+func TestLSNTracker_OldestUnapplied(t *testing.T) {
+	lt := NewLSNTracker(10)
+	if got := lt.OldestUnapplied(); got != 11 {
+		t.Fatalf("OldestUnapplied with nothing in flight = %d, want 11", got)
+	}
+	a, b := lt.Reserve(), lt.Reserve()
+	lt.Next()
+	if got := lt.OldestUnapplied(); got != a {
+		t.Fatalf("OldestUnapplied = %d, want %d", got, a)
+	}
+	lt.Release(a)
+	if got := lt.OldestUnapplied(); got != b {
+		t.Fatalf("OldestUnapplied after releasing %d = %d, want %d", a, got, b)
+	}
+	lt.Release(b)
+	if got := lt.OldestUnapplied(); got != lt.Current()+1 {
+		t.Fatalf("OldestUnapplied with nothing in flight = %d, want %d", got, lt.Current()+1)
+	}
+}
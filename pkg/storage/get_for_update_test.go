@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+func newCounterEngine(t *testing.T, waitTimeout time.Duration) *StorageEngine {
+	t.Helper()
+	tmpDir := t.TempDir()
+
+	hm, err := NewHeapForTable(HeapFormatV2, filepath.Join(tmpDir, "heap.data"))
+	if err != nil {
+		t.Fatalf("new heap: %v", err)
+	}
+	tableMgr := NewTableMenager()
+	if err := tableMgr.NewTable("counters", []Index{{Name: "id", Primary: true, Type: TypeInt}}, 4, hm); err != nil {
+		t.Fatalf("new table: %v", err)
+	}
+	walWriter, err := wal.NewWALWriter(filepath.Join(tmpDir, "wal.log"), wal.DefaultOptions())
+	if err != nil {
+		t.Fatalf("new wal: %v", err)
+	}
+	se, err := NewStorageEngine(tableMgr, walWriter)
+	if err != nil {
+		t.Fatalf("new storage engine: %v", err)
+	}
+	t.Cleanup(func() { se.Close() })
+
+	se.LockManager = NewLockManager(LockManagerConfig{WaitTimeout: waitTimeout})
+	return se
+}
+
+func parseCounter(doc string) (int, error) {
+	idx := strings.Index(doc, `"value":`)
+	if idx == -1 {
+		return 0, fmt.Errorf("document without value: %s", doc)
+	}
+	rest := strings.TrimLeft(doc[idx+len(`"value":`):], " ")
+	end := strings.IndexAny(rest, ",}")
+	return strconv.Atoi(strings.TrimSpace(rest[:end]))
+}
+
+func counterValue(t *testing.T, doc string) int {
+	t.Helper()
+	v, err := parseCounter(doc)
+	if err != nil {
+		t.Fatalf("bad counter document %q: %v", doc, err)
+	}
+	return v
+}
+
+func TestWriteTransaction_GetForUpdatePreventsLostUpdates(t *testing.T) {
+	se := newCounterEngine(t, 5*time.Second)
+	if err := se.Put("counters", "id", types.IntKey(1), `{"id":1,"value":0}`); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	const workers = 8
+	var wg sync.WaitGroup
+	errCh := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tx := se.BeginWriteTransaction()
+			doc, found, err := tx.GetForUpdate("counters", "id", types.IntKey(1))
+			if err != nil || !found {
+				_ = tx.Rollback()
+				errCh <- fmt.Errorf("get for update: found=%v err=%v", found, err)
+				return
+			}
+			current, err := parseCounter(doc)
+			if err != nil {
+				_ = tx.Rollback()
+				errCh <- err
+				return
+			}
+			next := current + 1
+			if err := tx.Put("counters", "id", types.IntKey(1), fmt.Sprintf(`{"id":1,"value":%d}`, next)); err != nil {
+				_ = tx.Rollback()
+				errCh <- err
+				return
+			}
+			errCh <- tx.Commit()
+		}()
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		if err != nil {
+			t.Fatalf("worker failed: %v", err)
+		}
+	}
+
+	doc, _, err := se.Get("counters", "id", types.IntKey(1))
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got := counterValue(t, doc); got != workers {
+		t.Fatalf("expected counter %d, got %d (lost update)", workers, got)
+	}
+}
+
+func TestWriteTransaction_GetForUpdateReadsLatestAndOwnWrites(t *testing.T) {
+	se := newCounterEngine(t, time.Second)
+	if err := se.Put("counters", "id", types.IntKey(1), `{"id":1,"value":1}`); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	tx := se.BeginWriteTransaction()
+	defer tx.Rollback()
+
+	// Committed after tx started: the snapshot misses it, the locking read
+	// does not.
+	if err := se.Put("counters", "id", types.IntKey(1), `{"id":1,"value":2}`); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	doc, found, err := tx.GetForUpdate("counters", "id", types.IntKey(1))
+	if err != nil || !found || counterValue(t, doc) != 2 {
+		t.Fatalf("expected latest committed value 2, got %q found=%v err=%v", doc, found, err)
+	}
+	if err := tx.Put("counters", "id", types.IntKey(1), `{"id":1,"value":3}`); err != nil {
+		t.Fatalf("put after locking read should not conflict: %v", err)
+	}
+	doc, _, _ = tx.GetForUpdate("counters", "id", types.IntKey(1))
+	if counterValue(t, doc) != 3 {
+		t.Fatalf("expected own buffered write, got %q", doc)
+	}
+
+	if _, _, err := tx.GetForUpdate("counters", "missing", types.IntKey(1)); err == nil {
+		t.Fatal("expected error for unknown index")
+	}
+}
+
+func TestWriteTransaction_GetForUpdateLockWaitTimeout(t *testing.T) {
+	se := newCounterEngine(t, 50*time.Millisecond)
+
+	holder := se.BeginWriteTransaction()
+	if _, _, err := holder.GetForUpdate("counters", "id", types.IntKey(1)); err != nil {
+		t.Fatalf("holder lock: %v", err)
+	}
+
+	waiter := se.BeginWriteTransaction()
+	if _, _, err := waiter.GetForUpdate("counters", "id", types.IntKey(1)); !errors.Is(err, ErrLockWaitTimeout) {
+		t.Fatalf("expected lock wait timeout, got %v", err)
+	}
+	if err := waiter.Put("counters", "id", types.IntKey(1), `{"id":1,"value":9}`); !errors.Is(err, ErrLockWaitTimeout) {
+		t.Fatalf("timed out transaction should stay finished, got %v", err)
+	}
+
+	// Autocommit writers wait for the row lock too.
+	if err := se.Put("counters", "id", types.IntKey(1), `{"id":1,"value":1}`); !errors.Is(err, ErrLockWaitTimeout) {
+		t.Fatalf("expected autocommit put to time out, got %v", err)
+	}
+
+	if err := holder.Rollback(); err != nil {
+		t.Fatalf("holder rollback: %v", err)
+	}
+	if err := se.Put("counters", "id", types.IntKey(1), `{"id":1,"value":1}`); err != nil {
+		t.Fatalf("put after release: %v", err)
+	}
+}
+
+func TestWriteTransaction_GetForUpdateDeadlock(t *testing.T) {
+	se := newCounterEngine(t, time.Second)
+
+	tx1 := se.BeginWriteTransaction()
+	tx2 := se.BeginWriteTransaction()
+	if _, _, err := tx1.GetForUpdate("counters", "id", types.IntKey(1)); err != nil {
+		t.Fatalf("tx1 lock key1: %v", err)
+	}
+	if _, _, err := tx2.GetForUpdate("counters", "id", types.IntKey(2)); err != nil {
+		t.Fatalf("tx2 lock key2: %v", err)
+	}
+
+	tx1ErrCh := make(chan error, 1)
+	go func() {
+		_, _, err := tx1.GetForUpdate("counters", "id", types.IntKey(2))
+		tx1ErrCh <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	if _, _, err := tx2.GetForUpdate("counters", "id", types.IntKey(1)); !errors.Is(err, ErrDeadlockVictim) {
+		t.Fatalf("expected deadlock victim, got %v", err)
+	}
+	select {
+	case err := <-tx1ErrCh:
+		if err != nil {
+			t.Fatalf("tx1 should survive the deadlock, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("tx1 never got key2")
+	}
+	if err := tx1.Commit(); err != nil {
+		t.Fatalf("tx1 commit: %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bobboyms/storage-engine/pkg/errors"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// CreateIndex adds a secondary (non-unique) index on the document field
+// indexName to a table that may already hold data, and backfills it from
+// the heap.
+//
+// Every row version reachable from the primary index gets its own posting,
+// so snapshots older than the index see the same rows through it as
+// through the primary key. Writes to the table wait while the heap is
+// scanned; reads keep going. Once the backfill is done the index is
+// flushed by a checkpoint and, when the schema is persisted, recorded in
+// the catalog. A crash before that leaves the table without the index.
+func (se *StorageEngine) CreateIndex(tableName, indexName string, keyType DataType) error {
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return err
+	}
+
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return err
+	}
+	if err := se.backfillIndex(table, indexName, keyType); err != nil {
+		return err
+	}
+
+	if se.WAL != nil {
+		if err := se.fuzzyCheckpointLocked(); err != nil {
+			return fmt.Errorf("storage: create index %s.%s: %w", tableName, indexName, err)
+		}
+	} else if idx, err := table.GetIndex(indexName); err == nil {
+		if syncer, ok := idx.Tree.(syncableTree); ok {
+			if err := syncer.Sync(); err != nil {
+				return err
+			}
+		}
+	}
+	se.registerPageRedoHooks()
+	return se.TableMetaData.saveCatalog()
+}
+
+func (se *StorageEngine) backfillIndex(table *Table, indexName string, keyType DataType) error {
+	table.Lock()
+	defer table.Unlock()
+
+	if _, exists := table.Indices[indexName]; exists {
+		return &errors.IndexAlreadyExistsError{TableName: table.Name, Name: indexName}
+	}
+	primary, err := primaryIndex(table)
+	if err != nil {
+		return err
+	}
+	scanner, ok := primary.Tree.(rangeScanner)
+	if !ok {
+		return fmt.Errorf("storage: primary index of %s cannot be scanned", table.Name)
+	}
+
+	treePath := defaultV2IndexPath(table.Heap.Path(), table.Name, indexName)
+	// Leftover of a creation that crashed before reaching the catalog.
+	if err := os.Remove(treePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	tree, err := NewBTreeForIndex(BTreeFormatV2, false, keyType, treePath, se.TableMetaData.indexCipher())
+	if err != nil {
+		return err
+	}
+	idx := &Index{Name: indexName, Type: keyType, Tree: tree}
+	postings, _ := idx.postings()
+
+	var heads []int64
+	if err := scanner.ScanAll(func(_ types.Comparable, head int64) error {
+		heads = append(heads, head)
+		return nil
+	}); err != nil {
+		tree.Close()
+		return err
+	}
+
+	lsn := se.lsnTracker.Current()
+	for _, head := range heads {
+		for rid := head; rid != -1; {
+			docBytes, hdr, err := table.Heap.Read(rid)
+			if isChainEndErr(err) {
+				break
+			}
+			if err != nil {
+				tree.Close()
+				return fmt.Errorf("heap read failed: %w", err)
+			}
+			key, ok, err := indexKeyFromStoredDocument(idx, docBytes)
+			if err != nil {
+				tree.Close()
+				return err
+			}
+			// Versions without the field are simply not indexed.
+			if ok {
+				if err := insertPostingWithLSN(postings, key, rid, lsn); err != nil {
+					tree.Close()
+					return fmt.Errorf("failed to backfill index %s: %w", indexName, err)
+				}
+			}
+			rid = hdr.PrevRecordID
+		}
+	}
+
+	table.Indices[indexName] = idx
+	return nil
+}
+
+// DropIndex removes a secondary index and deletes its file. The primary
+// index cannot be dropped. Every reader and writer is drained first, so no
+// one is left holding the closed tree.
+func (se *StorageEngine) DropIndex(tableName, indexName string) error {
+	se.opMu.Lock()
+	defer se.opMu.Unlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return err
+	}
+
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return err
+	}
+
+	table.Lock()
+	idx, ok := table.Indices[indexName]
+	if !ok {
+		table.Unlock()
+		return &errors.IndexNotFoundError{Name: indexName}
+	}
+	if idx.Primary {
+		table.Unlock()
+		return fmt.Errorf("storage: cannot drop primary index %s of table %s", indexName, tableName)
+	}
+	delete(table.Indices, indexName)
+	if table.ttlIndex == indexName {
+		table.ttlIndex = ""
+	}
+	table.Unlock()
+
+	if err := se.TableMetaData.saveCatalog(); err != nil {
+		return err
+	}
+
+	treeFiles := indexFilePaths(idx.Tree)
+	if err := idx.Tree.Close(); err != nil {
+		return err
+	}
+	for _, path := range treeFiles {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexKeyFromStoredDocument extracts the key of idx from a heap document
+// (BSON, or JSON for older rows). ok is false when the field is missing.
+func indexKeyFromStoredDocument(idx *Index, docBytes []byte) (types.Comparable, bool, error) {
+	doc, err := storedDocumentBson(docBytes)
+	if err != nil {
+		return nil, false, err
+	}
+	key, err := idx.keyFromBson(doc)
+	if err != nil {
+		return nil, false, nil
+	}
+	if err := validateKeyForIndex(idx, key); err != nil {
+		return nil, false, err
+	}
+	return key, true, nil
+}
+
+// existingIndexKeys drops keys of indexes the table no longer has, so
+// WAL entries logged before a DropIndex still replay.
+func existingIndexKeys(table *Table, keys map[string]types.Comparable) map[string]types.Comparable {
+	filtered := make(map[string]types.Comparable, len(keys))
+	for indexName, key := range keys {
+		if _, ok := table.Indices[indexName]; ok {
+			filtered[indexName] = key
+		}
+	}
+	return filtered
+}
+
+// IndexMemoryUsage reports, per index of tableName, how many bytes of tree
+// pages are currently held in RAM. Each tree keeps at most
+// DefaultIndexCachePages pages cached.
+func (se *StorageEngine) IndexMemoryUsage(tableName string) (map[string]int64, error) {
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return nil, err
+	}
+
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return nil, err
+	}
+	usage := make(map[string]int64)
+	for _, idx := range table.GetIndices() {
+		if tree, ok := idx.Tree.(memoryUsageTree); ok {
+			usage[idx.Name] = tree.MemoryUsage()
+		}
+	}
+	return usage, nil
+}
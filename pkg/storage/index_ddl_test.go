@@ -0,0 +1,222 @@
+package storage_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	storageErrors "github.com/bobboyms/storage-engine/pkg/errors"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+// openStaffEngine opens a table with only a primary key; its rows carry a
+// "city" field that is not indexed yet.
+func openStaffEngine(t *testing.T, dir string, tableMgr *storage.TableMetaData) *storage.StorageEngine {
+	t.Helper()
+	if tableMgr == nil {
+		hm, err := storage.NewHeapForTable(storage.HeapFormatV2, filepath.Join(dir, "heap.data"))
+		if err != nil {
+			t.Fatalf("Failed to create heap: %v", err)
+		}
+		tableMgr = storage.NewTableMenager()
+		if err := tableMgr.NewTable("staff", []storage.Index{
+			{Name: "id", Primary: true, Type: storage.TypeInt},
+		}, 3, hm); err != nil {
+			t.Fatalf("Failed to create table: %v", err)
+		}
+	}
+	walWriter, err := wal.NewWALWriter(filepath.Join(dir, "wal.log"), wal.DefaultOptions())
+	if err != nil {
+		t.Fatalf("Failed to create WAL: %v", err)
+	}
+	se, err := storage.NewStorageEngine(tableMgr, walWriter)
+	if err != nil {
+		walWriter.Close()
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	return se
+}
+
+func putStaff(t *testing.T, se *storage.StorageEngine, id int64, city string) {
+	t.Helper()
+	doc := fmt.Sprintf(`{"id": %d, "city": "%s"}`, id, city)
+	if err := se.UpsertRow("staff", doc, map[string]types.Comparable{"id": types.IntKey(id)}); err != nil {
+		t.Fatalf("UpsertRow %d: %v", id, err)
+	}
+}
+
+func TestCreateIndex_BackfillsExistingRows(t *testing.T) {
+	se := openStaffEngine(t, t.TempDir(), nil)
+	defer se.Close()
+
+	putStaff(t, se, 1, "Lisbon")
+	putStaff(t, se, 2, "Porto")
+	putStaff(t, se, 3, "Lisbon")
+	old := se.BeginRead()
+	defer old.Close()
+	putStaff(t, se, 3, "Porto") // moves city; the old version stays for the snapshot
+	if _, err := se.DeleteRow("staff", types.IntKey(2)); err != nil {
+		t.Fatalf("DeleteRow: %v", err)
+	}
+
+	if err := se.CreateIndex("staff", "city", storage.TypeVarchar); err != nil {
+		t.Fatalf("CreateIndex: %v", err)
+	}
+
+	docs, _ := se.GetAll("staff", "city", types.VarcharKey("Lisbon"))
+	assertIDs(t, "Lisbon", docs, "1")
+	docs, _ = se.GetAll("staff", "city", types.VarcharKey("Porto"))
+	assertIDs(t, "Porto", docs, "3")
+
+	docs, _ = old.GetAll("staff", "city", types.VarcharKey("Lisbon"))
+	assertIDs(t, "snapshot Lisbon", docs, "1", "3")
+	docs, _ = old.GetAll("staff", "city", types.VarcharKey("Porto"))
+	assertIDs(t, "snapshot Porto", docs, "2")
+
+	// New writes maintain the index.
+	if err := se.UpsertRow("staff", `{"id": 4, "city": "Lisbon"}`, map[string]types.Comparable{
+		"id":   types.IntKey(4),
+		"city": types.VarcharKey("Lisbon"),
+	}); err != nil {
+		t.Fatalf("UpsertRow after CreateIndex: %v", err)
+	}
+	docs, _ = se.GetAll("staff", "city", types.VarcharKey("Lisbon"))
+	assertIDs(t, "Lisbon after insert", docs, "1", "4")
+
+	err := se.CreateIndex("staff", "city", storage.TypeVarchar)
+	var exists *storageErrors.IndexAlreadyExistsError
+	if !errors.As(err, &exists) {
+		t.Fatalf("expected IndexAlreadyExistsError, got %v", err)
+	}
+}
+
+func TestCreateIndex_SurvivesRecovery(t *testing.T) {
+	dir := t.TempDir()
+	catalogPath := filepath.Join(dir, "catalog.json")
+	tableMgr, err := storage.NewCatalogTableMenager(catalogPath, nil)
+	if err != nil {
+		t.Fatalf("NewCatalogTableMenager: %v", err)
+	}
+	hm, err := storage.NewHeapForTable(storage.HeapFormatV2, filepath.Join(dir, "heap.data"))
+	if err != nil {
+		t.Fatalf("Failed to create heap: %v", err)
+	}
+	if err := tableMgr.NewTable("staff", []storage.Index{{Name: "id", Primary: true, Type: storage.TypeInt}}, 3, hm); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	se := openStaffEngine(t, dir, tableMgr)
+	putStaff(t, se, 1, "Lisbon")
+	putStaff(t, se, 2, "Porto")
+	if err := se.CreateIndex("staff", "city", storage.TypeVarchar); err != nil {
+		t.Fatalf("CreateIndex: %v", err)
+	}
+	if err := se.UpsertRow("staff", `{"id": 3, "city": "Porto"}`, map[string]types.Comparable{
+		"id":   types.IntKey(3),
+		"city": types.VarcharKey("Porto"),
+	}); err != nil {
+		t.Fatalf("UpsertRow: %v", err)
+	}
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened, err := storage.NewCatalogTableMenager(catalogPath, nil)
+	if err != nil {
+		t.Fatalf("reopen catalog: %v", err)
+	}
+	se2 := openStaffEngine(t, dir, reopened)
+	defer se2.Close()
+	if err := se2.Recover(filepath.Join(dir, "wal.log")); err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	docs, err := se2.GetAll("staff", "city", types.VarcharKey("Porto"))
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	assertIDs(t, "recovered Porto", docs, "2", "3")
+}
+
+func TestDropIndex(t *testing.T) {
+	dir := t.TempDir()
+	se := openEmployeesEngine(t, dir)
+	putEmployee(t, se, 1, "Engineering")
+
+	if err := se.DropIndex("employees", "id"); err == nil {
+		t.Fatal("expected error dropping the primary index")
+	}
+	if err := se.DropIndex("employees", "missing"); err == nil {
+		t.Fatal("expected error dropping an unknown index")
+	}
+
+	treePath := filepath.Join(dir, "heap.data.employees.department.btree.v2")
+	if _, err := os.Stat(treePath); err != nil {
+		t.Fatalf("index file missing before drop: %v", err)
+	}
+	if err := se.DropIndex("employees", "department"); err != nil {
+		t.Fatalf("DropIndex: %v", err)
+	}
+	if _, err := os.Stat(treePath); !os.IsNotExist(err) {
+		t.Fatalf("index file should be removed, stat err=%v", err)
+	}
+	if _, err := se.GetAll("employees", "department", types.VarcharKey("Engineering")); err == nil {
+		t.Fatal("dropped index still readable")
+	}
+	if _, found, _ := se.Get("employees", "id", types.IntKey(1)); !found {
+		t.Fatal("row lost after dropping a secondary index")
+	}
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// WAL entries that still name the dropped index must replay.
+	hm, err := storage.NewHeapForTable(storage.HeapFormatV2, filepath.Join(t.TempDir(), "heap.data"))
+	if err != nil {
+		t.Fatalf("Failed to create heap: %v", err)
+	}
+	tableMgr := storage.NewTableMenager()
+	if err := tableMgr.NewTable("employees", []storage.Index{{Name: "id", Primary: true, Type: storage.TypeInt}}, 3, hm); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	walWriter, err := wal.NewWALWriter(filepath.Join(dir, "wal.log"), wal.DefaultOptions())
+	if err != nil {
+		t.Fatalf("Failed to open WAL: %v", err)
+	}
+	se2, err := storage.NewStorageEngine(tableMgr, walWriter)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer se2.Close()
+	if err := se2.Recover(filepath.Join(dir, "wal.log")); err != nil {
+		t.Fatalf("Recover with dropped index: %v", err)
+	}
+	if _, found, _ := se2.Get("employees", "id", types.IntKey(1)); !found {
+		t.Fatal("row 1 missing after recovery")
+	}
+}
+
+func TestIndexMemoryUsage(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+	for id := int64(1); id <= 500; id++ {
+		putEmployee(t, se, id, fmt.Sprintf("dept-%03d", id%50))
+	}
+
+	usage, err := se.IndexMemoryUsage("employees")
+	if err != nil {
+		t.Fatalf("IndexMemoryUsage: %v", err)
+	}
+	limit := int64(storage.DefaultIndexCachePages) * 8192
+	for _, name := range []string{"id", "department"} {
+		if usage[name] <= 0 || usage[name] > limit {
+			t.Fatalf("index %s: usage %d outside (0, %d]", name, usage[name], limit)
+		}
+	}
+	if _, err := se.IndexMemoryUsage("missing"); err == nil {
+		t.Fatal("expected an error for an unknown table")
+	}
+}
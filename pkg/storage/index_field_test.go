@@ -0,0 +1,108 @@
+package storage_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+func TestIndexField_ExtractsKeysFromNestedPaths(t *testing.T) {
+	dir := t.TempDir()
+	catalogPath := filepath.Join(dir, "catalog.json")
+
+	tableMgr, err := storage.NewCatalogTableMenager(catalogPath, nil)
+	if err != nil {
+		t.Fatalf("NewCatalogTableMenager: %v", err)
+	}
+	hm, err := storage.NewHeapForTable(storage.HeapFormatV2, filepath.Join(dir, "heap.data"))
+	if err != nil {
+		t.Fatalf("Failed to create heap: %v", err)
+	}
+	if err := tableMgr.NewTable("customers", []storage.Index{
+		{Name: "id", Primary: true, Type: storage.TypeInt, Field: "customer_id"},
+		{Name: "city", Primary: false, Type: storage.TypeVarchar, Field: "address.city"},
+	}, 3, hm); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	se := openCatalogEngine(t, dir, tableMgr)
+	for _, doc := range []string{
+		`{"customer_id": 1, "address": {"city": "Lisbon", "zip": "1000"}}`,
+		`{"customer_id": 2, "address": {"city": "Porto"}}`,
+		`{"customer_id": 3, "address": {"city": "Lisbon"}}`,
+	} {
+		if err := se.InsertRow("customers", doc, nil); err != nil {
+			t.Fatalf("InsertRow %s: %v", doc, err)
+		}
+	}
+	if err := se.InsertRow("customers", `{"customer_id": 4, "address": "Lisbon"}`, nil); err == nil {
+		t.Fatal("expected a document without address.city to be rejected")
+	}
+	// A key passed by hand must agree with the document.
+	err = se.InsertRow("customers", `{"customer_id": 5, "address": {"city": "Faro"}}`, map[string]types.Comparable{
+		"city": types.VarcharKey("Braga"),
+	})
+	if err == nil {
+		t.Fatal("expected a mismatching city key to be rejected")
+	}
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reopened, err := storage.NewCatalogTableMenager(catalogPath, nil)
+	if err != nil {
+		t.Fatalf("reopen catalog: %v", err)
+	}
+	se = openCatalogEngine(t, dir, reopened)
+	defer se.Close()
+
+	city, err := reopened.GetIndexByName("customers", "city")
+	if err != nil || city.FieldPath() != "address.city" {
+		t.Fatalf("index field not restored: %v %v", city, err)
+	}
+	docs, err := se.GetAll("customers", "city", types.VarcharKey("Lisbon"))
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if len(docs) != 2 || !strings.Contains(docs[0]+docs[1], `"customer_id":1`) || !strings.Contains(docs[0]+docs[1], `"customer_id":3`) {
+		t.Fatalf("unexpected Lisbon customers: %v", docs)
+	}
+	if err := se.InsertRow("customers", `{"customer_id": 6, "address": {"city": "Porto"}}`, nil); err != nil {
+		t.Fatalf("InsertRow after reopen: %v", err)
+	}
+	if docs, _ := se.GetAll("customers", "city", types.VarcharKey("Porto")); len(docs) != 2 {
+		t.Fatalf("expected 2 Porto customers, got %v", docs)
+	}
+}
+
+func TestPut_ValidatesDottedIndexPaths(t *testing.T) {
+	dir := t.TempDir()
+	hm, err := storage.NewHeapForTable(storage.HeapFormatV2, filepath.Join(dir, "heap.data"))
+	if err != nil {
+		t.Fatalf("Failed to create heap: %v", err)
+	}
+	tableMgr := storage.NewTableMenager()
+	if err := tableMgr.NewTable("profiles", []storage.Index{
+		{Name: "user.id", Primary: true, Type: storage.TypeInt},
+		{Name: "user.profile.age", Primary: false, Type: storage.TypeInt},
+	}, 3, hm); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	se := openCatalogEngine(t, dir, tableMgr)
+	defer se.Close()
+
+	doc := `{"user": {"id": 7, "profile": {"age": 30}}}`
+	if err := se.Put("profiles", "user.id", types.IntKey(7), doc); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := se.Put("profiles", "user.id", types.IntKey(8), `{"user": {"id": "8"}}`); err == nil {
+		t.Fatal("expected a nested key of the wrong type to be rejected")
+	}
+	docs, err := se.GetAll("profiles", "user.profile.age", types.IntKey(30))
+	if err != nil || len(docs) != 1 {
+		t.Fatalf("GetAll by nested age: %v %v", docs, err)
+	}
+}
@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/bobboyms/storage-engine/pkg/btree"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// Non-unique indexes keep one posting per row version instead of a single
+// MVCC chain head. Every posting is therefore checked on its own: a
+// version is visible when it was created before the snapshot and was not
+// deleted before it. Walking PrevRecordID would surface the same row
+// twice (once per version posting), so posting reads never follow it.
+
+type postingLSNTree interface {
+	InsertValueWithLSN(key types.Comparable, value int64, lsn uint64) error
+	RemoveValueWithLSN(key types.Comparable, value int64, lsn uint64) (bool, error)
+}
+
+type rangeScanner interface {
+	Scan(start, end types.Comparable, fn func(key types.Comparable, value int64) error) error
+	ScanAll(fn func(key types.Comparable, value int64) error) error
+}
+
+type syncableTree interface {
+	Sync() error
+}
+
+type memoryUsageTree interface {
+	MemoryUsage() int64
+}
+
+// lsnTree is a unique index tree that stamps the pages a write dirties
+// with its LSN.
+type lsnTree interface {
+	ReplaceWithLSN(key types.Comparable, value int64, lsn uint64) error
+	DeleteWithLSN(key types.Comparable, lsn uint64) (bool, error)
+}
+
+type lsnUpsertTree interface {
+	UpsertWithLSN(key types.Comparable, lsn uint64, fn func(oldValue int64, exists bool) (int64, error)) error
+}
+
+// insertPostingWithLSN adds a posting for every element of key (see
+// indexKeyElements).
+func insertPostingWithLSN(tree btree.MultiValueTree, key types.Comparable, value int64, lsn uint64) error {
+	for _, elem := range indexKeyElements(key) {
+		var err error
+		if lsnTree, ok := tree.(postingLSNTree); ok {
+			err = lsnTree.InsertValueWithLSN(elem, value, lsn)
+		} else {
+			err = tree.InsertValue(elem, value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removePostingWithLSN removes the postings of every element of key and
+// reports whether any was there.
+func removePostingWithLSN(tree btree.MultiValueTree, key types.Comparable, value int64, lsn uint64) (bool, error) {
+	removed := false
+	for _, elem := range indexKeyElements(key) {
+		var ok bool
+		var err error
+		if lsnTree, isLSN := tree.(postingLSNTree); isLSN {
+			ok, err = lsnTree.RemoveValueWithLSN(elem, value, lsn)
+		} else {
+			ok, err = tree.RemoveValue(elem, value)
+		}
+		if err != nil {
+			return removed, err
+		}
+		removed = removed || ok
+	}
+	return removed, nil
+}
+
+// upsertIndexKeyWithLSN runs fn against the index entry of key, stamping
+// the touched pages with lsn when the tree supports it.
+func upsertIndexKeyWithLSN(index *Index, key types.Comparable, lsn uint64, fn func(oldValue int64, exists bool) (int64, error)) error {
+	if tree, ok := index.Tree.(lsnUpsertTree); ok {
+		return tree.UpsertWithLSN(key, lsn, fn)
+	}
+	return index.Tree.Upsert(key, fn)
+}
+
+// readVisiblePosting resolves a single posting without walking the
+// version chain.
+func (se *StorageEngine) readVisiblePosting(tx *Transaction, table *Table, key types.Comparable, recordID int64) (visibleRecord, error) {
+	docBytes, header, err := table.Heap.Read(recordID)
+	if isChainEndErr(err) {
+		return visibleRecord{}, nil
+	}
+	if err != nil {
+		return visibleRecord{}, fmt.Errorf("heap read failed at key %v: %w", key, err)
+	}
+	if !tx.IsVisible(header.CreateLSN) {
+		return visibleRecord{}, nil
+	}
+	if !header.Valid && header.DeleteLSN <= tx.SnapshotLSN {
+		return visibleRecord{}, nil
+	}
+
+	return visibleRecord{
+		Raw:       docBytes,
+		Found:     true,
+		CreateLSN: header.CreateLSN,
+	}, nil
+}
+
+// readVisibleEntry returns the row the index entry (key, value) leads to,
+// as tx sees it: value is a record ID in a multi-value index and the head
+// of a version chain otherwise.
+func (se *StorageEngine) readVisibleEntry(tx *Transaction, table *Table, multiValue bool, key types.Comparable, value int64) (visibleRecord, error) {
+	if multiValue {
+		return se.readVisiblePosting(tx, table, key, value)
+	}
+	return se.readVisibleRecord(tx, table, key, value)
+}
+
+// visiblePostings returns every row visible to tx under key.
+func (se *StorageEngine) visiblePostings(tx *Transaction, table *Table, tree btree.MultiValueTree, key types.Comparable) ([]visibleRecord, error) {
+	recordIDs, err := tree.GetAll(key)
+	if err != nil {
+		return nil, fmt.Errorf("tree get: %w", err)
+	}
+	records := make([]visibleRecord, 0, len(recordIDs))
+	for _, rid := range recordIDs {
+		record, err := se.readVisiblePosting(tx, table, key, rid)
+		if err != nil {
+			return nil, err
+		}
+		if record.Found {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// tombstonePostings marks every live row under key as deleted at lsn.
+// Postings stay in the tree so older snapshots can still reach them.
+func tombstonePostings(table *Table, tree btree.MultiValueTree, key types.Comparable, lsn uint64) (bool, error) {
+	recordIDs, err := tree.GetAll(key)
+	if err != nil {
+		return false, err
+	}
+	deleted := false
+	for _, rid := range recordIDs {
+		_, header, err := table.Heap.Read(rid)
+		if isChainEndErr(err) {
+			continue
+		}
+		if err != nil {
+			return deleted, err
+		}
+		if !header.Valid || header.CreateLSN > lsn {
+			continue
+		}
+		if err := table.Heap.Delete(rid, lsn); err != nil {
+			if isChainEndErr(err) {
+				continue
+			}
+			return deleted, fmt.Errorf("heap delete failed: %w", err)
+		}
+		deleted = true
+	}
+	return deleted, nil
+}
+
+// findPostingByLSN returns the posting of key whose record was created
+// (or deleted, when byDelete is set) at lsn; -1 when there is none.
+func findPostingByLSN(table *Table, tree btree.MultiValueTree, key types.Comparable, lsn uint64, byDelete bool) (int64, error) {
+	recordIDs, err := tree.GetAll(key)
+	if err != nil {
+		return -1, err
+	}
+	for _, rid := range recordIDs {
+		_, header, err := table.Heap.Read(rid)
+		if isChainEndErr(err) {
+			continue
+		}
+		if err != nil {
+			return -1, err
+		}
+		if (byDelete && header.DeleteLSN == lsn) || (!byDelete && header.CreateLSN == lsn) {
+			return rid, nil
+		}
+	}
+	return -1, nil
+}
@@ -132,6 +132,7 @@ func TestRecover_CorruptedMultiInsert(t *testing.T) {
 			EntryType:  wal.EntryMultiInsert,
 			LSN:        1,
 			PayloadLen: 4,
+			CRC32:      wal.CalculateCRC32([]byte("junk")),
 		},
 		Payload: []byte("junk"),
 	}
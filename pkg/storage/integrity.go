@@ -0,0 +1,256 @@
+package storage
+
+import (
+	goerrors "errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	v2 "github.com/bobboyms/storage-engine/pkg/heap/v2"
+	"github.com/bobboyms/storage-engine/pkg/pagestore"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+// IntegrityIssueKind classifies an IntegrityIssue.
+type IntegrityIssueKind int
+
+const (
+	// IssueCorruptPage: a heap page cannot be read (bad checksum or I/O).
+	IssueCorruptPage IntegrityIssueKind = iota
+	// IssueCorruptRecord: a heap record fails its checksum or decoding.
+	IssueCorruptRecord
+	// IssueDanglingPointer: an index entry names a record that does not
+	// exist or cannot be read.
+	IssueDanglingPointer
+	// IssueBrokenVersionChain: a PrevRecordID names a record that does not
+	// exist, is not older than the version before it, or loops.
+	IssueBrokenVersionChain
+	// IssueOrphanedRecord: a live record that no index entry reaches.
+	IssueOrphanedRecord
+	// IssueCorruptWAL: the WAL cannot be read past this point.
+	IssueCorruptWAL
+)
+
+func (k IntegrityIssueKind) String() string {
+	return [...]string{"CORRUPT_PAGE", "CORRUPT_RECORD", "DANGLING_POINTER", "BROKEN_VERSION_CHAIN", "ORPHANED_RECORD", "CORRUPT_WAL"}[k]
+}
+
+// IntegrityIssue is one problem found by CheckIntegrity. Index and Key
+// name the index entry at fault, when there is one.
+type IntegrityIssue struct {
+	Kind     IntegrityIssueKind
+	Index    string
+	Key      types.Comparable
+	RecordID int64
+	Detail   string
+}
+
+func (i IntegrityIssue) String() string {
+	if i.Index != "" {
+		return fmt.Sprintf("%v: %s[%v] -> record %d: %s", i.Kind, i.Index, i.Key, i.RecordID, i.Detail)
+	}
+	return fmt.Sprintf("%v: record %d: %s", i.Kind, i.RecordID, i.Detail)
+}
+
+// IntegrityReport is the result of CheckIntegrity. Records counts the heap
+// slots holding a record, Vacuumed the reclaimed ones. StaleEntries counts
+// index entries left on versions Vacuum reclaimed; reads treat them as
+// the end of a chain, so they are not issues.
+type IntegrityReport struct {
+	Table        string
+	Records      int
+	Vacuumed     int
+	IndexEntries map[string]int
+	StaleEntries int
+	Issues       []IntegrityIssue
+}
+
+// OK reports whether no issue was found.
+func (r *IntegrityReport) OK() bool {
+	return len(r.Issues) == 0
+}
+
+func (r *IntegrityReport) add(issue IntegrityIssue) {
+	r.Issues = append(r.Issues, issue)
+}
+
+// heapSlotState is what the heap scan learned about one record ID.
+type heapSlotState struct {
+	header   *v2.RecordHeader
+	vacuumed bool
+	corrupt  bool
+	reached  bool
+}
+
+// CheckIntegrity checks a table like fsck: it reads every heap page and
+// record, validating page and record checksums; checks that every index
+// entry lands on an existing record; walks the version chain of every
+// primary key; and reports live records no index reaches. Writes to the
+// table wait while it runs. The error is for failures to run the check;
+// what it finds goes in the report.
+func (se *StorageEngine) CheckIntegrity(tableName string) (*IntegrityReport, error) {
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return nil, err
+	}
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return nil, err
+	}
+	heapV2, ok := table.Heap.(*v2.HeapV2)
+	if !ok {
+		return nil, fmt.Errorf("storage: CheckIntegrity: table %s must use HeapV2", tableName)
+	}
+	table.RLock()
+	defer table.RUnlock()
+
+	report := &IntegrityReport{Table: tableName, IndexEntries: make(map[string]int)}
+	slots := make(map[int64]*heapSlotState)
+	corruptPages := make(map[pagestore.PageID]bool)
+	err = heapV2.ScanRecords(func(rid int64, rh *v2.RecordHeader, err error) error {
+		switch {
+		case err == nil:
+			report.Records++
+			slots[rid] = &heapSlotState{header: rh}
+		case goerrors.Is(err, v2.ErrVacuumed):
+			report.Vacuumed++
+			slots[rid] = &heapSlotState{vacuumed: true}
+		case goerrors.Is(err, v2.ErrPageUnreadable):
+			pageID, _ := v2.DecodeRecordID(rid)
+			corruptPages[pageID] = true
+			report.add(IntegrityIssue{Kind: IssueCorruptPage, RecordID: rid, Detail: err.Error()})
+		default:
+			report.Records++
+			slots[rid] = &heapSlotState{corrupt: true}
+			report.add(IntegrityIssue{Kind: IssueCorruptRecord, RecordID: rid, Detail: err.Error()})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// lookup returns the heap state of rid, or why there is none.
+	lookup := func(rid int64) (state *heapSlotState, detail string) {
+		if state = slots[rid]; state != nil {
+			return state, ""
+		}
+		pageID, _ := v2.DecodeRecordID(rid)
+		if corruptPages[pageID] {
+			return nil, fmt.Sprintf("record on corrupt page %d", pageID)
+		}
+		return nil, "record does not exist"
+	}
+
+	indices := table.GetIndicesUnsafe()
+	sort.Slice(indices, func(i, j int) bool { return indices[i].Name < indices[j].Name })
+	for _, index := range indices {
+		scanner, ok := index.Tree.(rangeScanner)
+		if !ok {
+			return nil, fmt.Errorf("storage: CheckIntegrity: index %s uses unsupported type %T", index.Name, index.Tree)
+		}
+		err := scanner.ScanAll(func(key types.Comparable, rid int64) error {
+			report.IndexEntries[index.Name]++
+			state, detail := lookup(rid)
+			switch {
+			case state == nil:
+				report.add(IntegrityIssue{Kind: IssueDanglingPointer, Index: index.Name, Key: key, RecordID: rid, Detail: detail})
+				return nil
+			case state.vacuumed:
+				report.StaleEntries++
+				return nil
+			case state.corrupt:
+				return nil // already reported by the heap scan
+			}
+			state.reached = true
+			if index.Primary {
+				checkVersionChain(report, index.Name, key, rid, state, lookup)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("storage: CheckIntegrity: scan index %s: %w", index.Name, err)
+		}
+	}
+
+	rids := make([]int64, 0, len(slots))
+	for rid, state := range slots {
+		if state.header != nil && state.header.Valid && !state.reached {
+			rids = append(rids, rid)
+		}
+	}
+	sort.Slice(rids, func(i, j int) bool { return rids[i] < rids[j] })
+	for _, rid := range rids {
+		report.add(IntegrityIssue{Kind: IssueOrphanedRecord, RecordID: rid, Detail: "live record not reached by any index"})
+	}
+	return report, nil
+}
+
+// checkVersionChain follows the PrevRecordID links from the head of a
+// primary key, marking every version reached. A vacuumed version ends the
+// chain, as it does for reads.
+func checkVersionChain(report *IntegrityReport, indexName string, key types.Comparable, rid int64, head *heapSlotState, lookup func(int64) (*heapSlotState, string)) {
+	seen := map[int64]bool{rid: true}
+	current := head
+	for prev := current.header.PrevRecordID; prev != v2.NoRecordID; prev = current.header.PrevRecordID {
+		issue := IntegrityIssue{Kind: IssueBrokenVersionChain, Index: indexName, Key: key, RecordID: rid}
+		state, detail := lookup(prev)
+		switch {
+		case seen[prev]:
+			issue.Detail = fmt.Sprintf("version chain loops back to record %d", prev)
+		case state == nil:
+			issue.Detail = fmt.Sprintf("previous version %d: %s", prev, detail)
+		case state.vacuumed || state.corrupt:
+			return
+		case state.header.CreateLSN > current.header.CreateLSN:
+			issue.Detail = fmt.Sprintf("previous version %d (LSN %d) is newer than record %d (LSN %d)", prev, state.header.CreateLSN, rid, current.header.CreateLSN)
+		}
+		if issue.Detail != "" {
+			report.add(issue)
+			return
+		}
+		seen[prev] = true
+		state.reached = true
+		rid, current = prev, state
+	}
+}
+
+// CheckWALIntegrity reads the whole WAL, checking the CRC of every entry.
+// A torn entry at the tail, left by a crash, is expected and not an
+// issue; any other read error is. Records counts the entries read.
+func (se *StorageEngine) CheckWALIntegrity() (*IntegrityReport, error) {
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if se.WAL == nil {
+		return nil, fmt.Errorf("storage: CheckWALIntegrity: engine has no WAL")
+	}
+	if err := se.WAL.Sync(); err != nil {
+		return nil, err
+	}
+	report := &IntegrityReport{}
+	if _, err := os.Stat(se.WAL.Path()); os.IsNotExist(err) {
+		return report, nil
+	}
+	reader, err := wal.NewWALReaderWithCipher(se.WAL.Path(), se.walCipher())
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	var lastLSN uint64
+	for {
+		entry, err := reader.ReadEntry()
+		if err == io.EOF || (err != nil && isExpectedWALTail(err)) {
+			return report, nil
+		}
+		if err != nil {
+			report.add(IntegrityIssue{Kind: IssueCorruptWAL, Detail: fmt.Sprintf("entry %d, after LSN %d: %v", report.Records, lastLSN, err)})
+			return report, nil
+		}
+		report.Records++
+		lastLSN = entry.Header.LSN
+		wal.ReleaseEntry(entry)
+	}
+}
@@ -0,0 +1,109 @@
+package storage_test
+
+import (
+	"testing"
+
+	v2 "github.com/bobboyms/storage-engine/pkg/heap/v2"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+func TestCheckIntegrity_CleanTableAfterWrites(t *testing.T) {
+	se := openReportsEngine(t, t.TempDir())
+	defer se.Close()
+
+	for _, doc := range []string{
+		`{"id": 1, "dept": "Eng"}`,
+		`{"id": 2, "manager": 1, "dept": "Eng"}`,
+		`{"id": 3, "dept": "Ops"}`,
+	} {
+		if err := se.InsertRow("reports", doc, nil); err != nil {
+			t.Fatalf("InsertRow: %v", err)
+		}
+	}
+	if err := se.UpdateRow("reports", `{"id": 2, "manager": 3, "dept": "Ops"}`, nil); err != nil {
+		t.Fatalf("UpdateRow: %v", err)
+	}
+	if _, err := se.DeleteRow("reports", types.IntKey(3)); err != nil {
+		t.Fatalf("DeleteRow: %v", err)
+	}
+	tx := se.BeginWriteTransaction()
+	if err := tx.InsertRow("reports", `{"id": 4, "dept": "Lost"}`, nil); err != nil {
+		t.Fatalf("tx InsertRow: %v", err)
+	}
+	tx.Rollback()
+	if err := se.Vacuum("reports"); err != nil {
+		t.Fatalf("Vacuum: %v", err)
+	}
+
+	report, err := se.CheckIntegrity("reports")
+	if err != nil {
+		t.Fatalf("CheckIntegrity: %v", err)
+	}
+	if !report.OK() {
+		t.Fatalf("expected a clean report, got %v", report.Issues)
+	}
+	if report.Records == 0 || report.IndexEntries["id"] == 0 || report.IndexEntries["dept"] == 0 {
+		t.Fatalf("expected records and index entries to be counted, got %+v", report)
+	}
+
+	walReport, err := se.CheckWALIntegrity()
+	if err != nil || !walReport.OK() || walReport.Records == 0 {
+		t.Fatalf("expected a clean WAL report, got %+v (%v)", walReport, err)
+	}
+}
+
+func TestCheckIntegrity_ReportsDanglingOrphanedAndBrokenChains(t *testing.T) {
+	se := openReportsEngine(t, t.TempDir())
+	defer se.Close()
+
+	if err := se.InsertRow("reports", `{"id": 1, "dept": "Eng"}`, nil); err != nil {
+		t.Fatalf("InsertRow: %v", err)
+	}
+	table, err := se.TableMetaData.GetTableByName("reports")
+	if err != nil {
+		t.Fatalf("GetTableByName: %v", err)
+	}
+	missing := v2.EncodeRecordID(9999, 0)
+
+	// An index entry on a page that does not exist.
+	if err := table.Indices["dept"].Tree.(interface {
+		InsertValue(types.Comparable, int64) error
+	}).InsertValue(types.VarcharKey("Ghost"), missing); err != nil {
+		t.Fatalf("InsertValue: %v", err)
+	}
+	// A live record no index reaches.
+	orphan, err := table.Heap.Write([]byte("{}"), 50, v2.NoRecordID)
+	if err != nil {
+		t.Fatalf("Heap.Write: %v", err)
+	}
+	// A primary key whose previous version is gone.
+	head, err := table.Heap.Write([]byte("{}"), 60, missing)
+	if err != nil {
+		t.Fatalf("Heap.Write: %v", err)
+	}
+	if err := table.Indices["id"].Tree.Insert(types.IntKey(7), head); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	report, err := se.CheckIntegrity("reports")
+	if err != nil {
+		t.Fatalf("CheckIntegrity: %v", err)
+	}
+	kinds := map[storage.IntegrityIssueKind]storage.IntegrityIssue{}
+	for _, issue := range report.Issues {
+		kinds[issue.Kind] = issue
+	}
+	if len(report.Issues) != 3 {
+		t.Fatalf("expected 3 issues, got %v", report.Issues)
+	}
+	if issue := kinds[storage.IssueDanglingPointer]; issue.Index != "dept" || issue.Key != types.VarcharKey("Ghost") || issue.RecordID != missing {
+		t.Fatalf("unexpected dangling pointer issue %v", issue)
+	}
+	if issue := kinds[storage.IssueOrphanedRecord]; issue.RecordID != orphan {
+		t.Fatalf("unexpected orphan issue %v", issue)
+	}
+	if issue := kinds[storage.IssueBrokenVersionChain]; issue.Index != "id" || issue.RecordID != head {
+		t.Fatalf("unexpected broken chain issue %v", issue)
+	}
+}
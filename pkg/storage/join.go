@@ -0,0 +1,248 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/tracing"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// JoinStrategy picks how Join finds the right rows of a left row.
+type JoinStrategy int
+
+const (
+	// JoinAuto looks rows up when the right table has an index on
+	// RightField and builds a hash table otherwise.
+	JoinAuto JoinStrategy = iota
+	// JoinLookup seeks an index of the right table on RightField once
+	// per left row (a nested loop join over the index).
+	JoinLookup
+	// JoinHash reads the whole right table once into an in-memory hash
+	// table keyed by RightField.
+	JoinHash
+)
+
+// JoinSpec describes a Join.
+type JoinSpec struct {
+	// LeftIndex and LeftCondition pick the left rows as a Scan would; an
+	// empty LeftIndex walks the primary index of the left table.
+	LeftIndex     string
+	LeftCondition *query.ScanCondition
+	// LeftField and RightField are the document fields that must be
+	// equal; dotted paths reach nested fields. A missing field or a null
+	// matches nothing.
+	LeftField  string
+	RightField string
+	// Outer keeps the left rows that match nothing, with an empty Right
+	// (a left outer join).
+	Outer bool
+	// Limit caps the rows returned; zero means no limit.
+	Limit    int
+	Strategy JoinStrategy
+}
+
+// JoinRow is one row of a Join: a left document and a right document
+// whose fields match.
+type JoinRow struct {
+	Left  string
+	Right string // "" for an unmatched left row of an outer join
+}
+
+// Join pairs the rows of the left table with the rows of the right table
+// whose RightField equals their LeftField (an equi-join), reading both
+// tables at one snapshot. Rows come in the order of the left index walk;
+// the right rows of one left row come in index order.
+//
+// With an index on RightField in the right table, Join seeks it for every
+// left row, which suits a small or filtered left side. Without one it
+// reads the right table once and holds it in memory as a hash table, so
+// the right table should be the smaller one.
+func (se *StorageEngine) Join(left string, right string, spec JoinSpec) ([]JoinRow, error) {
+	return se.JoinCtx(context.Background(), left, right, spec)
+}
+
+// JoinCtx is Join with ctx.
+func (se *StorageEngine) JoinCtx(ctx context.Context, left string, right string, spec JoinSpec) (_ []JoinRow, err error) {
+	ctx, span := se.startSpan(ctx, "storage.Join", left, spec.LeftIndex)
+	defer func() { tracing.End(span, err) }()
+
+	// BeginRead takes opMu, so it comes before the lock.
+	tx := se.BeginRead()
+	defer tx.Close()
+
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err = se.runtimeReadyError(); err != nil {
+		return nil, err
+	}
+	leftTable, err := se.TableMetaData.GetTableByName(left)
+	if err != nil {
+		return nil, err
+	}
+	rightTable, err := se.TableMetaData.GetTableByName(right)
+	if err != nil {
+		return nil, err
+	}
+	if spec.LeftField == "" || spec.RightField == "" {
+		return nil, fmt.Errorf("storage: join %s with %s needs LeftField and RightField", left, right)
+	}
+	leftIndex := spec.LeftIndex
+	if leftIndex == "" {
+		leftTable.RLock()
+		primary, err := primaryIndex(leftTable)
+		leftTable.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+		leftIndex = primary.Name
+	}
+
+	lookup := joinLookupIndex(rightTable, spec.RightField)
+	if spec.Strategy == JoinLookup && lookup == nil {
+		return nil, fmt.Errorf("storage: join lookup needs an index on %s.%s", right, spec.RightField)
+	}
+	useLookup := lookup != nil && spec.Strategy != JoinHash
+	if tracing.Recording(span) {
+		span.SetAttribute("storage.join.lookup", useLookup)
+	}
+
+	var match func(key types.Comparable, fn func(right string) error) error
+	if useLookup {
+		match = func(key types.Comparable, fn func(right string) error) error {
+			key = joinLookupKey(lookup, key)
+			if isNullKey(key) || validateKeyForIndex(lookup, key) != nil {
+				return nil
+			}
+			full, err := tx.walkIndex(ctx, rightTable, lookup.Name, query.Equal(key), nil, false, func(_ types.Comparable, _ int64, record visibleRecord) error {
+				return fn(record.Document())
+			})
+			if full {
+				// Limit reached: stop the left walk too.
+				return errScanPageFull
+			}
+			return err
+		}
+	} else {
+		buckets, err := tx.buildJoinHash(ctx, rightTable, spec.RightField)
+		if err != nil {
+			return nil, err
+		}
+		match = func(key types.Comparable, fn func(right string) error) error {
+			hash, ok := joinHashKey(key)
+			if !ok {
+				return nil
+			}
+			for _, doc := range buckets[hash] {
+				if err := fn(doc); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	rows := []JoinRow{}
+	emit := func(row JoinRow) error {
+		rows = append(rows, row)
+		if spec.Limit > 0 && len(rows) >= spec.Limit {
+			return errScanPageFull
+		}
+		return nil
+	}
+	_, err = tx.walkIndex(ctx, leftTable, leftIndex, spec.LeftCondition, nil, false, func(_ types.Comparable, _ int64, record visibleRecord) error {
+		key, err := sortKey(record.Raw, spec.LeftField)
+		if err != nil {
+			return err
+		}
+		leftDoc := record.Document()
+		matched := false
+		err = match(key, func(right string) error {
+			matched = true
+			return emit(JoinRow{Left: leftDoc, Right: right})
+		})
+		if err != nil {
+			return err
+		}
+		if !matched && spec.Outer {
+			return emit(JoinRow{Left: leftDoc})
+		}
+		return nil
+	})
+	return rows, err
+}
+
+// joinLookupIndex returns an index of table on field that a lookup can
+// seek, preferring the primary index; nil when there is none. A multikey
+// index holds the elements of an array field, not the field itself.
+func joinLookupIndex(table *Table, field string) *Index {
+	indices := table.GetIndices()
+	slices.SortFunc(indices, func(a, b *Index) int {
+		if a.Primary != b.Primary {
+			return boolOrder(b.Primary) - boolOrder(a.Primary)
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+	for _, index := range indices {
+		if index.FieldPath() == field && !index.Multikey {
+			return index
+		}
+	}
+	return nil
+}
+
+// joinLookupKey converts a left value to the key type of the index it is
+// looked up in, as documents are converted when they are indexed.
+func joinLookupKey(index *Index, key types.Comparable) types.Comparable {
+	if k, ok := key.(types.IntKey); ok && index.Type == TypeFloat {
+		return types.FloatKey(k)
+	}
+	return coerceKey(index.Type, key)
+}
+
+// buildJoinHash reads every visible row of table and groups the documents
+// by the hash key of field.
+func (tx *Transaction) buildJoinHash(ctx context.Context, table *Table, field string) (map[string][]string, error) {
+	table.RLock()
+	primary, err := primaryIndex(table)
+	table.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	buckets := make(map[string][]string)
+	_, err = tx.walkIndex(ctx, table, primary.Name, nil, nil, false, func(_ types.Comparable, _ int64, record visibleRecord) error {
+		key, err := sortKey(record.Raw, field)
+		if err != nil {
+			return err
+		}
+		if hash, ok := joinHashKey(key); ok {
+			buckets[hash] = append(buckets[hash], record.Document())
+		}
+		return nil
+	})
+	return buckets, err
+}
+
+// joinHashKey maps a document value to the key of its hash bucket; equal
+// numbers share a bucket whether stored as integers or floats. NULL has
+// no bucket.
+func joinHashKey(key types.Comparable) (string, bool) {
+	switch k := key.(type) {
+	case types.NullKey:
+		return "", false
+	case types.IntKey:
+		return "n:" + strconv.FormatInt(int64(k), 10), true
+	case types.FloatKey:
+		f := float64(k)
+		if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+			return "n:" + strconv.FormatInt(int64(f), 10), true
+		}
+		return "f:" + strconv.FormatFloat(f, 'g', -1, 64), true
+	}
+	return fmt.Sprintf("%T:%v", key, key), true
+}
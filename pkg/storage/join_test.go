@@ -0,0 +1,95 @@
+package storage_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// TestJoin_LookupAndHashAgree joins orders and customers along both paths
+// (index lookup and hash) and checks that they give the same rows, with
+// an outer join, a limit and a missing field.
+func TestJoin_LookupAndHashAgree(t *testing.T) {
+	se, err := storage.Open(filepath.Join(t.TempDir(), "data"), storage.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer se.Close()
+	if err := se.CreateTable("customers", []storage.Index{{Name: "id", Primary: true, Type: storage.TypeInt}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := se.CreateTable("orders", []storage.Index{
+		{Name: "id", Primary: true, Type: storage.TypeInt},
+		{Name: "customer", Type: storage.TypeInt},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for id := 1; id <= 4; id++ {
+		if err := se.InsertRow("customers", fmt.Sprintf(`{"id": %d, "name": "c%d"}`, id, id), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Customer 3 has no orders; order 6 points to no customer.
+	for id, customer := range []int{1, 2, 1, 4, 2, 9} {
+		doc := fmt.Sprintf(`{"id": %d, "customer": %d}`, id+1, customer)
+		if err := se.InsertRow("orders", doc, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := se.Del("orders", "id", types.IntKey(5)); err != nil {
+		t.Fatal(err)
+	}
+
+	pairs := func(rows []storage.JoinRow) []string {
+		out := make([]string, len(rows))
+		for i, row := range rows {
+			out[i] = row.Left + "|" + row.Right
+		}
+		return out
+	}
+	order := func(id, customer int) string { return fmt.Sprintf(`{"id":%d,"customer":%d}`, id, customer) }
+	customer := func(id int) string { return fmt.Sprintf(`{"id":%d,"name":"c%d"}`, id, id) }
+
+	want := []string{
+		customer(1) + "|" + order(1, 1),
+		customer(1) + "|" + order(3, 1),
+		customer(2) + "|" + order(2, 2),
+		customer(3) + "|",
+		customer(4) + "|" + order(4, 4),
+	}
+	for _, strategy := range []storage.JoinStrategy{storage.JoinLookup, storage.JoinHash} {
+		rows, err := se.Join("customers", "orders", storage.JoinSpec{
+			LeftField: "id", RightField: "customer", Outer: true, Strategy: strategy,
+		})
+		if err != nil {
+			t.Fatalf("strategy %d: %v", strategy, err)
+		}
+		if got := pairs(rows); !slices.Equal(got, want) {
+			t.Fatalf("strategy %d:\n got %v\nwant %v", strategy, got, want)
+		}
+
+		rows, err = se.Join("orders", "customers", storage.JoinSpec{
+			LeftCondition: query.GreaterThan(types.IntKey(1)),
+			LeftIndex:     "id", LeftField: "customer", RightField: "id", Limit: 2, Strategy: strategy,
+		})
+		if err != nil {
+			t.Fatalf("strategy %d: %v", strategy, err)
+		}
+		if got, want := pairs(rows), []string{order(2, 2) + "|" + customer(2), order(3, 1) + "|" + customer(1)}; !slices.Equal(got, want) {
+			t.Fatalf("strategy %d inner:\n got %v\nwant %v", strategy, got, want)
+		}
+	}
+
+	rows, err := se.Join("customers", "orders", storage.JoinSpec{LeftField: "missing", RightField: "customer", Outer: true})
+	if err != nil || len(rows) != 4 || rows[0].Right != "" {
+		t.Fatalf("join on a missing field = %v, %v", rows, err)
+	}
+	if _, err := se.Join("customers", "orders", storage.JoinSpec{LeftField: "id", RightField: "note", Strategy: storage.JoinLookup}); err == nil {
+		t.Fatal("JoinLookup without an index on the right field should fail")
+	}
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// keyCatalogHeap is implemented by heaps that can store the index keys of
+// a row next to its document, so index maintenance reads them back
+// without decoding the document.
+type keyCatalogHeap interface {
+	WriteWithKeys(doc, keys []byte, createLSN uint64, prevRecordID int64) (int64, error)
+	ReadKeys(recordID int64) ([]byte, error)
+}
+
+// encodeKeyCatalog packs the index keys of a row into the compact form
+// stored with each heap version.
+func encodeKeyCatalog(keys map[string]types.Comparable) ([]byte, error) {
+	return SerializeMultiIndexEntry("", keys, nil)
+}
+
+func decodeKeyCatalog(data []byte) (map[string]types.Comparable, error) {
+	_, keys, _, err := DeserializeMultiIndexEntry(data)
+	return keys, err
+}
+
+// writeRowVersion writes a row version to the heap, carrying its key
+// catalog when the heap supports one.
+func writeRowVersion(table *Table, keys map[string]types.Comparable, doc []byte, lsn uint64, prevOffset int64) (int64, error) {
+	catalogHeap, ok := table.Heap.(keyCatalogHeap)
+	if !ok || len(keys) == 0 {
+		return table.Heap.Write(doc, lsn, prevOffset)
+	}
+	catalog, err := encodeKeyCatalog(keys)
+	if err != nil {
+		return 0, fmt.Errorf("key catalog encode failed: %w", err)
+	}
+	return catalogHeap.WriteWithKeys(doc, catalog, lsn, prevOffset)
+}
+
+// storedRowKeys returns the indexed keys of the row version at rid. The
+// key catalog is used when it covers every index of the table; otherwise
+// (rows written before the catalog existed, through the single-index Put
+// path, or before an index was created) the document is decoded and the
+// catalog entries take precedence over the decoded ones. found is false
+// when neither source yields the keys; err reports heap failures only.
+func storedRowKeys(table *Table, rid int64) (keys map[string]types.Comparable, found bool, err error) {
+	var catalog map[string]types.Comparable
+	if catalogHeap, ok := table.Heap.(keyCatalogHeap); ok {
+		data, err := catalogHeap.ReadKeys(rid)
+		if err != nil {
+			return nil, false, err
+		}
+		if data != nil {
+			if catalog, err = decodeKeyCatalog(data); err != nil {
+				return nil, false, fmt.Errorf("key catalog decode failed: %w", err)
+			}
+			if catalogCoversIndexes(table, catalog) {
+				return catalog, true, nil
+			}
+		}
+	}
+
+	docBytes, _, err := table.Heap.Read(rid)
+	if err != nil {
+		return nil, false, err
+	}
+	keys, err = keysFromStoredDocument(table, docBytes)
+	if err != nil {
+		return catalog, catalog != nil, nil
+	}
+	for name, key := range catalog {
+		keys[name] = key
+	}
+	return keys, true, nil
+}
+
+func catalogCoversIndexes(table *Table, catalog map[string]types.Comparable) bool {
+	for _, idx := range table.GetIndicesUnsafe() {
+		if _, ok := catalog[idx.Name]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,127 @@
+package storage_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+func openPaymentsEngine(t *testing.T, dir string) *storage.StorageEngine {
+	t.Helper()
+	hm, err := storage.NewHeapForTable(storage.HeapFormatV2, filepath.Join(dir, "heap.data"))
+	if err != nil {
+		t.Fatalf("Failed to create heap: %v", err)
+	}
+	tableMgr := storage.NewTableMenager()
+	if err := tableMgr.NewTable("payments", []storage.Index{
+		{Name: "uuid", Primary: true, Type: storage.TypeUUID},
+		{Name: "amount", Type: storage.TypeDecimal},
+	}, 3, hm); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	blobs, err := storage.NewHeapForTable(storage.HeapFormatV2, filepath.Join(dir, "blobs.data"))
+	if err != nil {
+		t.Fatalf("Failed to create heap: %v", err)
+	}
+	if err := tableMgr.NewTable("blobs", []storage.Index{
+		{Name: "digest", Primary: true, Type: storage.TypeBytes},
+		{Name: "size", Type: storage.TypeDecimal},
+	}, 3, blobs); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	walWriter, err := wal.NewWALWriter(filepath.Join(dir, "wal.log"), wal.DefaultOptions())
+	if err != nil {
+		t.Fatalf("Failed to create WAL: %v", err)
+	}
+	se, err := storage.NewProductionStorageEngine(tableMgr, walWriter)
+	if err != nil {
+		walWriter.Close()
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	return se
+}
+
+func mustUUID(t *testing.T, s string) types.UUIDKey {
+	t.Helper()
+	u, err := types.ParseUUID(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestKeyTypes_DecimalUUIDAndBytesRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	se := openPaymentsEngine(t, dir)
+
+	first := mustUUID(t, "01890a5d-ac96-774b-bcce-b302099a8057")
+	second := mustUUID(t, "01890a5d-ac97-774b-bcce-b302099a8057")
+	// JSON has no decimal or UUID type: both come as strings or numbers.
+	for _, doc := range []string{
+		`{"uuid": "01890a5d-ac97-774b-bcce-b302099a8057", "id": 2, "amount": "19.99"}`,
+		`{"uuid": "01890a5d-ac96-774b-bcce-b302099a8057", "id": 1, "amount": 5}`,
+	} {
+		if err := se.InsertRow("payments", doc, nil); err != nil {
+			t.Fatalf("InsertRow %s: %v", doc, err)
+		}
+	}
+	if err := se.InsertRow("payments", `{"uuid": "not-a-uuid", "id": 3, "amount": 1}`, nil); err == nil {
+		t.Fatal("expected an invalid uuid to be rejected")
+	}
+	if err := se.InsertRow("payments", `{"uuid": "01890a5d-ac98-774b-bcce-b302099a8057", "id": 3, "amount": "0.00001"}`, nil); err == nil {
+		t.Fatal("expected an inexact decimal to be rejected")
+	}
+	for i, digest := range []types.BytesKey{{0x00, 0xff}, {0x00}} {
+		if err := se.InsertRow("blobs", "blob", map[string]types.Comparable{
+			"digest": digest,
+			"size":   types.DecimalKey(i),
+		}); err != nil {
+			t.Fatalf("InsertRow blob: %v", err)
+		}
+	}
+
+	check := func(se *storage.StorageEngine, label string) {
+		t.Helper()
+		// UUIDv7 keys sort by creation time.
+		docs, err := se.Scan("payments", "uuid", nil)
+		if err != nil || len(docs) != 2 {
+			t.Fatalf("%s: Scan uuid: %v %v", label, docs, err)
+		}
+		assertIDs(t, label+" first uuid", docs[:1], "1")
+
+		docs, _ = se.Scan("payments", "amount", query.GreaterThan(types.DecimalKey(50000)))
+		assertIDs(t, label+" amount > 5", docs, "2")
+		if _, found, _ := se.Get("payments", "uuid", second); !found {
+			t.Fatalf("%s: row %v not found", label, second)
+		}
+		if _, found, _ := se.Get("blobs", "digest", types.BytesKey{0x00}); !found {
+			t.Fatalf("%s: blob 00 not found", label)
+		}
+		if docs, _ := se.Scan("blobs", "digest", query.GreaterOrEqual(types.BytesKey{0x00, 0x01})); len(docs) != 1 {
+			t.Fatalf("%s: expected one blob >= 0001, got %v", label, docs)
+		}
+	}
+	check(se, "live")
+	if _, err := se.DeleteRow("payments", first); err != nil {
+		t.Fatalf("DeleteRow: %v", err)
+	}
+	if err := se.InsertRow("payments", `{"uuid": "01890a5d-ac96-774b-bcce-b302099a8057", "id": 1, "amount": 5}`, nil); err != nil {
+		t.Fatalf("InsertRow after delete: %v", err)
+	}
+
+	// Crash without a checkpoint: keys come back from the WAL.
+	se.WAL.Close()
+	se2 := openPaymentsEngine(t, dir)
+	check(se2, "after recovery")
+	if err := se2.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	se3 := openPaymentsEngine(t, dir)
+	defer se3.Close()
+	check(se3, "after checkpoint")
+}
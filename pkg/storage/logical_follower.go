@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+// LogicalOp is the kind of a LogicalRecord.
+type LogicalOp int
+
+const (
+	// LogicalPut writes a row (insert or replace).
+	LogicalPut LogicalOp = iota
+	LogicalDelete
+	// LogicalTruncate removes every row of the table.
+	LogicalTruncate
+	// LogicalDropTable removes the table.
+	LogicalDropTable
+)
+
+func (op LogicalOp) String() string {
+	return [...]string{"PUT", "DELETE", "TRUNCATE", "DROP"}[op]
+}
+
+// LogicalRecord is a committed data change decoded from the WAL. Keys
+// holds the index keys the change was logged with: every indexed key for
+// a whole-row write, a single index for Put and Del. Document is the
+// written document as JSON, empty for deletes.
+type LogicalRecord struct {
+	LSN      uint64
+	Op       LogicalOp
+	Table    string
+	Keys     map[string]types.Comparable
+	Document string
+}
+
+// LogicalFollower tails a WAL, possibly written by another process, and
+// returns its committed data changes in LSN order: auto-commit writes as
+// they are read, transaction writes when their COMMIT is. A replica or a
+// CDC connector applies them in the order returned.
+type LogicalFollower struct {
+	follower *wal.Follower
+	pending  map[uint64][]LogicalRecord // writes of open transactions
+	ready    []LogicalRecord
+}
+
+// NewLogicalFollower follows the WAL at walPath from fromLSN; see
+// wal.NewFollower. Changes before the oldest WAL kept on disk are gone.
+func NewLogicalFollower(walPath string, fromLSN uint64, opts wal.FollowerOptions) (*LogicalFollower, error) {
+	follower, err := wal.NewFollower(walPath, fromLSN, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &LogicalFollower{follower: follower, pending: make(map[uint64][]LogicalRecord)}, nil
+}
+
+// Next returns the next committed change, waiting for it at the end of
+// the log. It returns ctx.Err() when ctx is cancelled.
+func (lf *LogicalFollower) Next(ctx context.Context) (LogicalRecord, error) {
+	for len(lf.ready) == 0 {
+		entry, err := lf.follower.Next(ctx)
+		if err != nil {
+			return LogicalRecord{}, err
+		}
+		err = lf.add(entry)
+		wal.ReleaseEntry(entry)
+		if err != nil {
+			return LogicalRecord{}, err
+		}
+	}
+	record := lf.ready[0]
+	lf.ready = lf.ready[1:]
+	return record, nil
+}
+
+func (lf *LogicalFollower) add(entry *wal.WALEntry) error {
+	header := entry.Header
+	txID, payload, transactional, err := unwrapTxPayload(header, entry.Payload)
+	if err != nil {
+		return err
+	}
+	switch {
+	case transactional && header.EntryType == wal.EntryCommit:
+		lf.ready = append(lf.ready, lf.pending[txID]...)
+		delete(lf.pending, txID)
+		return nil
+	case transactional && header.EntryType == wal.EntryAbort:
+		delete(lf.pending, txID)
+		return nil
+	}
+	records, err := decodeLogicalRecords(header.EntryType, payload, header.LSN)
+	if err != nil {
+		return err
+	}
+	if transactional {
+		lf.pending[txID] = append(lf.pending[txID], records...)
+	} else {
+		lf.ready = append(lf.ready, records...)
+	}
+	return nil
+}
+
+// Close stops the follower, interrupting a waiting Next.
+func (lf *LogicalFollower) Close() error {
+	return lf.follower.Close()
+}
+
+// decodeLogicalRecords decodes a data record of the WAL, without its
+// transaction prefix. Other records decode to nothing.
+func decodeLogicalRecords(entryType uint8, payload []byte, lsn uint64) ([]LogicalRecord, error) {
+	switch entryType {
+	case wal.EntryInsert, wal.EntryUpdate, wal.EntryDelete:
+		table, indexName, key, doc, err := DeserializeDocumentEntry(payload)
+		if err != nil {
+			return nil, err
+		}
+		op := LogicalPut
+		if entryType == wal.EntryDelete {
+			op = LogicalDelete
+		}
+		return []LogicalRecord{{
+			LSN:      lsn,
+			Op:       op,
+			Table:    table,
+			Keys:     map[string]types.Comparable{indexName: key},
+			Document: documentJSON(doc),
+		}}, nil
+	case wal.EntryTruncate, wal.EntryDropTable:
+		table, _, _, err := DeserializeMultiIndexEntry(payload)
+		if err != nil {
+			return nil, err
+		}
+		op := LogicalTruncate
+		if entryType == wal.EntryDropTable {
+			op = LogicalDropTable
+		}
+		return []LogicalRecord{{LSN: lsn, Op: op, Table: table}}, nil
+	case wal.EntryMultiInsert, wal.EntryMultiDelete, wal.EntryMultiBatch, wal.EntryMultiDeleteBatch:
+	default:
+		return nil, nil
+	}
+
+	rows := [][]byte{payload}
+	if entryType == wal.EntryMultiBatch || entryType == wal.EntryMultiDeleteBatch {
+		var err error
+		if rows, err = DeserializeBatchEntry(payload); err != nil {
+			return nil, err
+		}
+	}
+	op := LogicalPut
+	if entryType == wal.EntryMultiDelete || entryType == wal.EntryMultiDeleteBatch {
+		op = LogicalDelete
+	}
+	records := make([]LogicalRecord, 0, len(rows))
+	for _, row := range rows {
+		table, keys, doc, err := DeserializeMultiIndexEntry(row)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, LogicalRecord{LSN: lsn, Op: op, Table: table, Keys: keys, Document: documentJSON(doc)})
+	}
+	return records, nil
+}
+
+func documentJSON(raw []byte) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	return visibleRecord{Raw: raw, Found: true}.Document()
+}
+
+// DescribeWALEntry names the table and keys an entry of the WAL writes,
+// for wal.DumpOptions.Describe. A row shows its keys as index=value; a
+// batch shows its row count. Other entries, and payloads that do not
+// decode, describe to empty strings.
+func DescribeWALEntry(entry *wal.WALEntry) (table, key string) {
+	_, body, _, err := unwrapTxPayload(entry.Header, entry.Payload)
+	if err != nil {
+		return "", ""
+	}
+	records, err := decodeLogicalRecords(entry.Header.EntryType, body, entry.Header.LSN)
+	if err != nil || len(records) == 0 {
+		return "", ""
+	}
+	if len(records) > 1 {
+		return records[0].Table, fmt.Sprintf("%d rows", len(records))
+	}
+	names := make([]string, 0, len(records[0].Keys))
+	for name := range records[0].Keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s=%v", name, records[0].Keys[name])
+	}
+	return records[0].Table, strings.Join(parts, ",")
+}
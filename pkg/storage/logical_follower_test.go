@@ -0,0 +1,99 @@
+package storage_test
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+func TestLogicalFollower_StreamsCommittedChanges(t *testing.T) {
+	dir := t.TempDir()
+	se := openReportsEngine(t, dir)
+	defer se.Close()
+
+	if err := se.InsertRow("reports", `{"id": 1, "dept": "Eng"}`, nil); err != nil {
+		t.Fatalf("InsertRow: %v", err)
+	}
+
+	follower, err := storage.NewLogicalFollower(filepath.Join(dir, "wal.log"), 0, wal.FollowerOptions{PollInterval: time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewLogicalFollower: %v", err)
+	}
+	defer follower.Close()
+	next := func() storage.LogicalRecord {
+		t.Helper()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		record, err := follower.Next(ctx)
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		return record
+	}
+
+	record := next()
+	if record.Op != storage.LogicalPut || record.Table != "reports" || record.Keys["id"] != types.IntKey(1) || record.Document != `{"id":1,"dept":"Eng"}` {
+		t.Fatalf("unexpected first record %+v", record)
+	}
+
+	// The follower waits at the end of the log for new writes.
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		aborted := se.BeginWriteTransaction()
+		_ = aborted.InsertRow("reports", `{"id": 2, "dept": "Lost"}`, nil)
+		aborted.Rollback()
+		tx := se.BeginWriteTransaction()
+		_ = tx.InsertRow("reports", `{"id": 3, "dept": "Ops"}`, nil)
+		_ = tx.Commit()
+		_, _ = se.DeleteRow("reports", types.IntKey(1))
+		_ = se.TruncateTable("reports")
+	}()
+
+	record = next()
+	if record.Op != storage.LogicalPut || record.Keys["id"] != types.IntKey(3) || record.LSN <= 1 {
+		t.Fatalf("expected the committed insert of 3, got %+v", record)
+	}
+	record = next()
+	if record.Op != storage.LogicalDelete || record.Keys["id"] != types.IntKey(1) || record.Document != "" {
+		t.Fatalf("expected the delete of 1, got %+v", record)
+	}
+	if record = next(); record.Op != storage.LogicalTruncate || record.Table != "reports" {
+		t.Fatalf("expected the truncate, got %+v", record)
+	}
+}
+
+func TestDescribeWALEntry(t *testing.T) {
+	dir := t.TempDir()
+	se := openReportsEngine(t, dir)
+	defer se.Close()
+
+	if err := se.InsertRow("reports", `{"id": 7, "dept": "Eng"}`, nil); err != nil {
+		t.Fatalf("InsertRow: %v", err)
+	}
+	if err := se.WAL.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	var described []string
+	_, err := wal.Dump(filepath.Join(dir, "wal.log"), io.Discard, wal.DumpOptions{
+		Describe: func(entry *wal.WALEntry) (string, string) {
+			table, key := storage.DescribeWALEntry(entry)
+			if table != "" {
+				described = append(described, table+" "+key)
+			}
+			return table, key
+		},
+	})
+	if err != nil {
+		t.Fatalf("Dump: %v", err)
+	}
+	if len(described) != 1 || described[0] != "reports dept=Eng,id=7,manager=NULL" {
+		t.Fatalf("described = %q", described)
+	}
+}
@@ -1,34 +1,72 @@
 package storage
 
 import (
+	"sync"
 	"sync/atomic"
 )
 
-// LSNTracker gerencia o Log Sequence Number de forma thread-safe
+// LSNTracker manages the Log Sequence Number in a thread-safe way
 type LSNTracker struct {
 	current uint64
-	// Utilizando sync.Mutex para operações que are not puramente atômicas se necessário,
-	// mas para um contador simples, atomic é suficiente e mais rápido.
-	// Mantemos a struct preparada para lógica mais complexa se precisar.
+
+	// inFlight holds the LSNs reserved with Reserve whose writes have
+	// not been applied to the pages yet (see OldestUnapplied).
+	mu       sync.Mutex
+	inFlight map[uint64]struct{}
 }
 
 func NewLSNTracker(start uint64) *LSNTracker {
 	return &LSNTracker{
-		current: start,
+		current:  start,
+		inFlight: make(map[uint64]struct{}),
 	}
 }
 
-// Next incrementa e retorna o próximo LSN
+// Next increments and returns the next LSN
 func (lt *LSNTracker) Next() uint64 {
 	return atomic.AddUint64(&lt.current, 1)
 }
 
-// Current retorna o LSN atual
+// Current returns the current LSN
 func (lt *LSNTracker) Current() uint64 {
 	return atomic.LoadUint64(&lt.current)
 }
 
-// Set define o LSN atual (usado no recovery)
+// Set sets the current LSN (used by recovery)
 func (lt *LSNTracker) Set(val uint64) {
 	atomic.StoreUint64(&lt.current, val)
 }
+
+// Reserve is the Next of a write that runs in parallel with a fuzzy
+// checkpoint: the LSN stays in flight until Release, which the caller
+// does after applying (or giving up on) the write to the heap and trees.
+func (lt *LSNTracker) Reserve() uint64 {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	lsn := atomic.AddUint64(&lt.current, 1)
+	lt.inFlight[lsn] = struct{}{}
+	return lsn
+}
+
+// Release marks the write of an LSN returned by Reserve as applied.
+func (lt *LSNTracker) Release(lsn uint64) {
+	lt.mu.Lock()
+	delete(lt.inFlight, lsn)
+	lt.mu.Unlock()
+}
+
+// OldestUnapplied returns the lowest LSN reserved and still in flight, or
+// Current()+1 if there is none. Every reserved write with a lower LSN is
+// already in the pages (dirty or not), so a flush that starts after the
+// call takes them all to disk.
+func (lt *LSNTracker) OldestUnapplied() uint64 {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	oldest := atomic.LoadUint64(&lt.current) + 1
+	for lsn := range lt.inFlight {
+		if lsn < oldest {
+			oldest = lsn
+		}
+	}
+	return oldest
+}
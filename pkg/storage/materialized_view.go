@@ -0,0 +1,469 @@
+package storage
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ErrViewClosed is returned by Sync once a MaterializedView has stopped
+// without an error.
+var ErrViewClosed = goerrors.New("storage: materialized view closed")
+
+// ViewSpec defines a MaterializedView over one source table.
+type ViewSpec struct {
+	// Source is the table the view is computed from.
+	Source string
+	// Filter picks the source rows as a condition on a walk of the
+	// source's primary index: key comparisons test the primary key and
+	// query.Field conditions test document fields. nil keeps every row.
+	Filter *query.ScanCondition
+	// Projection, for a view without GroupBy, lists the fields the view
+	// keeps of each row; the primary key field is always kept. Empty
+	// keeps whole documents.
+	Projection []string
+	// GroupBy makes an aggregate view: one row per value of this field,
+	// holding the value under "group" (the view's primary key, of type
+	// GroupType) and one field per Aggregates spec, named after it:
+	// "count", "sum_amount", "max_price". Source rows without the field,
+	// or with null, are left out.
+	GroupBy    string
+	GroupType  DataType
+	Aggregates []query.AggSpec
+}
+
+// MaterializedView keeps a table, named after the view, filled with the
+// result of a ViewSpec over its source table. Creating the view computes
+// it in full; from then on a change stream of the source (see Watch)
+// feeds it every committed change: a row view upserts or deletes the one
+// row a change touches, an aggregate view recomputes the groups the old
+// and the new document belong to. The view trails its source; Sync waits
+// for it to catch up.
+//
+// Read the view as any table, with Get and Scan on its name. Only the
+// view should write to it. The definition is not stored: after reopening
+// the engine, CreateMaterializedView again, which refreshes the existing
+// table in full and resumes following the source.
+type MaterializedView struct {
+	se     *StorageEngine
+	name   string
+	spec   ViewSpec
+	source *Index // primary index of the source
+	key    *Index // primary index of the view
+	stream *ChangeStream
+
+	mu        sync.Mutex // one refresh or change at a time
+	refreshed uint64     // snapshot of the last full refresh
+
+	state   sync.Mutex
+	changed *sync.Cond
+	lsn     uint64 // changes up to here are in the view
+	stopped bool
+	err     error
+	done    chan struct{}
+}
+
+// CreateMaterializedView creates the table name (or reuses it when it
+// exists), fills it from spec and starts following the source. The
+// engine needs a WAL. Close the view to stop following; its table stays.
+func (se *StorageEngine) CreateMaterializedView(name string, spec ViewSpec) (*MaterializedView, error) {
+	if se.WAL == nil {
+		return nil, fmt.Errorf("storage: materialized view %s needs a WAL", name)
+	}
+	if (spec.GroupBy == "") != (len(spec.Aggregates) == 0) {
+		return nil, fmt.Errorf("storage: materialized view %s: GroupBy and Aggregates go together", name)
+	}
+	if spec.GroupBy != "" && len(spec.Projection) > 0 {
+		return nil, fmt.Errorf("storage: materialized view %s: an aggregate view has no Projection", name)
+	}
+	sourceTable, err := se.TableMetaData.GetTableByName(spec.Source)
+	if err != nil {
+		return nil, err
+	}
+	sourceTable.RLock()
+	source, err := primaryIndex(sourceTable)
+	sourceTable.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	keyIndex := Index{Name: source.Name, Primary: true, Type: source.Type, Field: source.Field}
+	if spec.GroupBy != "" {
+		keyIndex = Index{Name: "group", Primary: true, Type: spec.GroupType}
+	} else if len(spec.Projection) > 0 && !slices.Contains(spec.Projection, source.FieldPath()) {
+		spec.Projection = append([]string{source.FieldPath()}, spec.Projection...)
+	}
+	if _, err := se.TableMetaData.GetTableByName(name); err != nil {
+		if err := se.CreateTable(name, []Index{keyIndex}); err != nil {
+			return nil, err
+		}
+	}
+	viewTable, err := se.TableMetaData.GetTableByName(name)
+	if err != nil {
+		return nil, err
+	}
+	viewTable.RLock()
+	key, err := primaryIndex(viewTable)
+	viewTable.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	if key.Name != keyIndex.Name || key.Type != keyIndex.Type {
+		return nil, fmt.Errorf("storage: table %s exists with another primary key than view %s needs", name, name)
+	}
+
+	mv := &MaterializedView{se: se, name: name, spec: spec, source: source, key: key, done: make(chan struct{})}
+	mv.changed = sync.NewCond(&mv.state)
+	if err := mv.RefreshFull(); err != nil {
+		return nil, err
+	}
+	// Changes after the refresh snapshot are still in the WAL, so the
+	// stream replays them.
+	if mv.stream, err = se.Watch(spec.Source, mv.refreshed+1); err != nil {
+		return nil, err
+	}
+	go mv.run()
+	return mv, nil
+}
+
+// Name returns the name of the view and of its table.
+func (mv *MaterializedView) Name() string { return mv.name }
+
+// LSN returns the LSN up to which the source's changes are in the view.
+func (mv *MaterializedView) LSN() uint64 {
+	mv.state.Lock()
+	defer mv.state.Unlock()
+	return mv.lsn
+}
+
+// Err returns the error that stopped the view, if any.
+func (mv *MaterializedView) Err() error {
+	mv.state.Lock()
+	defer mv.state.Unlock()
+	return mv.err
+}
+
+// Sync waits until every change the source held when Sync was called is
+// in the view. It fails with the error that stopped the view, or
+// ErrViewClosed.
+func (mv *MaterializedView) Sync(ctx context.Context) error {
+	target := mv.sourceLSN()
+	stop := context.AfterFunc(ctx, func() {
+		mv.state.Lock()
+		mv.changed.Broadcast()
+		mv.state.Unlock()
+	})
+	defer stop()
+
+	mv.state.Lock()
+	defer mv.state.Unlock()
+	for mv.lsn < target {
+		switch {
+		case mv.err != nil:
+			return mv.err
+		case mv.stopped:
+			return ErrViewClosed
+		case ctx.Err() != nil:
+			return ctx.Err()
+		}
+		mv.changed.Wait()
+	}
+	return nil
+}
+
+// sourceLSN is the last LSN applied to any index of the source.
+func (mv *MaterializedView) sourceLSN() uint64 {
+	var lsn uint64
+	table, err := mv.se.TableMetaData.GetTableByName(mv.spec.Source)
+	if err != nil {
+		return 0
+	}
+	for _, index := range table.GetIndices() {
+		lsn = max(lsn, mv.se.appliedLSN.Get(mv.spec.Source, index.Name))
+	}
+	return lsn
+}
+
+// Close stops following the source. The view's table keeps its rows.
+func (mv *MaterializedView) Close() error {
+	mv.stream.Close()
+	<-mv.done
+	return nil
+}
+
+// RefreshFull recomputes the whole view from a snapshot of the source:
+// it writes every row of the result and deletes the rows of the view
+// that are no longer in it.
+func (mv *MaterializedView) RefreshFull() error {
+	mv.mu.Lock()
+	defer mv.mu.Unlock()
+	return mv.refreshLocked()
+}
+
+// refreshLocked is RefreshFull with mv.mu held.
+func (mv *MaterializedView) refreshLocked() error {
+	tx := mv.se.BeginRead()
+	defer tx.Close()
+	rows, err := mv.compute(tx)
+	if err != nil {
+		return err
+	}
+
+	keep := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		hash, _ := joinHashKey(row.key)
+		keep[hash] = true
+		if err := mv.se.UpsertRow(mv.name, row.doc, nil); err != nil {
+			return fmt.Errorf("storage: refresh view %s: %w", mv.name, err)
+		}
+	}
+	// Only the view writes its table, so the rows it held at the
+	// snapshot are the ones that may be stale.
+	var stale []types.Comparable
+	err = tx.walkVisibleRows(mv.name, mv.key.Name, nil, false, false, func(key types.Comparable, _ []byte) error {
+		if hash, _ := joinHashKey(key); !keep[hash] {
+			stale = append(stale, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range stale {
+		if _, err := mv.se.DeleteRow(mv.name, key); err != nil {
+			return fmt.Errorf("storage: refresh view %s: %w", mv.name, err)
+		}
+	}
+
+	mv.refreshed = tx.SnapshotLSN
+	mv.advance(tx.SnapshotLSN)
+	return nil
+}
+
+// viewRow is one row of the result of a view.
+type viewRow struct {
+	key types.Comparable
+	doc string
+}
+
+// compute evaluates the view over the snapshot of tx.
+func (mv *MaterializedView) compute(tx *Transaction) ([]viewRow, error) {
+	if mv.spec.GroupBy != "" {
+		groups, err := tx.GroupBy(mv.spec.Source, mv.source.Name, mv.spec.Filter, mv.spec.GroupBy, mv.spec.Aggregates...)
+		if err != nil {
+			return nil, err
+		}
+		rows := make([]viewRow, 0, len(groups))
+		for _, group := range groups {
+			row, ok, err := mv.groupRow(group)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				rows = append(rows, row)
+			}
+		}
+		return rows, nil
+	}
+
+	docs, err := tx.Scan(mv.spec.Source, mv.source.Name, mv.spec.Filter, ScanOptions{Projection: mv.spec.Projection})
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]viewRow, len(docs))
+	for i, doc := range docs {
+		key, err := sortKey([]byte(doc), mv.source.FieldPath())
+		if err != nil {
+			return nil, err
+		}
+		rows[i] = viewRow{key: key, doc: doc}
+	}
+	return rows, nil
+}
+
+func (mv *MaterializedView) run() {
+	defer func() {
+		mv.state.Lock()
+		mv.stopped = true
+		if err := mv.stream.Err(); err != nil && mv.err == nil {
+			mv.err = err
+		}
+		mv.changed.Broadcast()
+		mv.state.Unlock()
+		close(mv.done)
+	}()
+	for event := range mv.stream.C {
+		mv.mu.Lock()
+		var err error
+		if event.LSN > mv.refreshed {
+			err = mv.apply(event)
+		}
+		mv.mu.Unlock()
+		if err != nil {
+			mv.state.Lock()
+			mv.err = fmt.Errorf("storage: materialized view %s at LSN %d: %w", mv.name, event.LSN, err)
+			mv.state.Unlock()
+			mv.stream.Close()
+			return
+		}
+		mv.advance(event.LSN)
+	}
+}
+
+func (mv *MaterializedView) advance(lsn uint64) {
+	mv.state.Lock()
+	mv.lsn = max(mv.lsn, lsn)
+	mv.changed.Broadcast()
+	mv.state.Unlock()
+}
+
+// apply brings one change of the source into the view.
+func (mv *MaterializedView) apply(event ChangeEvent) error {
+	switch event.Op {
+	case ChangeTruncate:
+		return mv.se.TruncateTable(mv.name)
+	case ChangeDrop:
+		return fmt.Errorf("source table %s was dropped", mv.spec.Source)
+	}
+	if event.Index != mv.source.Name {
+		// A Put or Del through a secondary index names no row.
+		return mv.refreshLocked()
+	}
+
+	if mv.spec.GroupBy != "" {
+		groups := make(map[string]types.Comparable)
+		for _, doc := range []string{event.Document, event.previous} {
+			if doc == "" {
+				continue
+			}
+			group, err := sortKey([]byte(doc), mv.spec.GroupBy)
+			if err != nil {
+				return err
+			}
+			if hash, ok := joinHashKey(group); ok {
+				groups[hash] = group
+			}
+		}
+		for _, group := range groups {
+			if err := mv.refreshGroup(group); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if event.Op != ChangeDelete {
+		matches := true
+		if mv.spec.Filter != nil {
+			var err error
+			if matches, err = matchesDocument(mv.spec.Filter, event.Key, []byte(event.Document)); err != nil {
+				return err
+			}
+		}
+		if matches {
+			doc := event.Document
+			if len(mv.spec.Projection) > 0 {
+				bsonDoc, err := JsonToBson(doc)
+				if err != nil {
+					return err
+				}
+				if doc, err = ProjectBsonToJson(bsonDoc, mv.spec.Projection); err != nil {
+					return err
+				}
+			}
+			return mv.se.UpsertRow(mv.name, doc, nil)
+		}
+	}
+	_, err := mv.se.DeleteRow(mv.name, event.Key)
+	return err
+}
+
+// refreshGroup recomputes the row of one group, reading only its rows
+// through an index on the GroupBy field when the view has no Filter.
+func (mv *MaterializedView) refreshGroup(group types.Comparable) error {
+	indexName := mv.source.Name
+	condition := query.Field(mv.spec.GroupBy, query.Equal(group))
+	if mv.spec.Filter != nil {
+		condition = query.And(mv.spec.Filter, condition)
+	} else if table, err := mv.se.TableMetaData.GetTableByName(mv.spec.Source); err == nil {
+		if index := joinLookupIndex(table, mv.spec.GroupBy); index != nil {
+			if key := joinLookupKey(index, group); validateKeyForIndex(index, key) == nil {
+				indexName, condition = index.Name, query.Equal(key)
+			}
+		}
+	}
+
+	tx := mv.se.BeginRead()
+	defer tx.Close()
+	groups, err := tx.GroupBy(mv.spec.Source, indexName, condition, mv.spec.GroupBy, mv.spec.Aggregates...)
+	if err != nil {
+		return err
+	}
+	for _, g := range groups {
+		row, ok, err := mv.groupRow(g)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return mv.se.UpsertRow(mv.name, row.doc, nil)
+		}
+	}
+	_, err = mv.se.DeleteRow(mv.name, joinLookupKey(mv.key, group))
+	return err
+}
+
+// groupRow turns a group into its row of the view; groups without a
+// value have none.
+func (mv *MaterializedView) groupRow(group query.Group) (viewRow, bool, error) {
+	if group.Key == nil || isNullKey(group.Key) {
+		return viewRow{}, false, nil
+	}
+	key := joinLookupKey(mv.key, group.Key)
+	doc := bson.D{{Key: "group", Value: bsonValue(key)}}
+	for i, spec := range mv.spec.Aggregates {
+		doc = append(doc, bson.E{Key: aggColumn(spec), Value: bsonValue(group.Results[i].Value)})
+	}
+	out, err := bson.MarshalExtJSON(doc, false, false)
+	if err != nil {
+		return viewRow{}, false, err
+	}
+	return viewRow{key: key, doc: string(out)}, true, nil
+}
+
+// aggColumn names the field of an aggregate in a view row.
+func aggColumn(spec query.AggSpec) string {
+	name := strings.ToLower(spec.Func.String())
+	if spec.Field == "" {
+		return name
+	}
+	return name + "_" + spec.Field
+}
+
+// bsonValue converts a key back to the value a document holds.
+func bsonValue(value types.Comparable) any {
+	switch v := value.(type) {
+	case nil, types.NullKey:
+		return nil
+	case types.IntKey:
+		return int64(v)
+	case types.FloatKey:
+		return float64(v)
+	case types.VarcharKey:
+		return string(v)
+	case types.BoolKey:
+		return bool(v)
+	case types.DateKey:
+		return time.Time(v)
+	case types.BytesKey:
+		return []byte(v)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,134 @@
+package storage_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+func syncView(t *testing.T, mv *storage.MaterializedView) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := mv.Sync(ctx); err != nil {
+		t.Fatalf("Sync %s: %v", mv.Name(), err)
+	}
+}
+
+// TestMaterializedView_FollowsChanges maintains a row view and a group
+// view while the source table receives inserts, updates, deletes and a
+// transaction, and checks that reopening the view rebuilds it from the
+// table.
+func TestMaterializedView_FollowsChanges(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	se, err := storage.Open(dir, storage.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { se.Close() }()
+	if err := se.CreateTable("orders", []storage.Index{{Name: "id", Primary: true, Type: storage.TypeInt}}); err != nil {
+		t.Fatal(err)
+	}
+	order := func(id int, customer, status string, amount int) string {
+		return fmt.Sprintf(`{"id": %d, "customer": %q, "status": %q, "amount": %d}`, id, customer, status, amount)
+	}
+	for _, doc := range []string{order(1, "ana", "paid", 10), order(2, "bia", "open", 20), order(3, "ana", "open", 5)} {
+		if err := se.InsertRow("orders", doc, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	specs := map[string]storage.ViewSpec{
+		"paid": {
+			Source:     "orders",
+			Filter:     query.Field("status", query.Equal(types.VarcharKey("paid"))),
+			Projection: []string{"amount"},
+		},
+		"by_customer": {
+			Source:     "orders",
+			GroupBy:    "customer",
+			GroupType:  storage.TypeVarchar,
+			Aggregates: []query.AggSpec{query.Count(), query.Sum().OfField("amount")},
+		},
+	}
+	open := func(name string) *storage.MaterializedView {
+		t.Helper()
+		mv, err := se.CreateMaterializedView(name, specs[name])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return mv
+	}
+	paid, byCustomer := open("paid"), open("by_customer")
+	expectView := func(mv *storage.MaterializedView, want ...string) {
+		t.Helper()
+		syncView(t, mv)
+		index := "id"
+		if mv.Name() == "by_customer" {
+			index = "group"
+		}
+		got, err := se.Scan(mv.Name(), index, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("%s:\n got %v\nwant %v", mv.Name(), got, want)
+		}
+	}
+	expectView(paid, `{"id":1,"amount":10}`)
+	expectView(byCustomer, `{"group":"ana","count":2,"sum_amount":15}`, `{"group":"bia","count":1,"sum_amount":20}`)
+
+	// Order 2 is paid and moves to ana, order 1 is deleted, order 4
+	// comes in a transaction.
+	if err := se.UpdateRow("orders", order(2, "ana", "paid", 20), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := se.DeleteRow("orders", types.IntKey(1)); err != nil {
+		t.Fatal(err)
+	}
+	tx := se.BeginWriteTransaction()
+	if err := tx.InsertRow("orders", order(4, "caio", "paid", 7), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	expectView(paid, `{"id":2,"amount":20}`, `{"id":4,"amount":7}`)
+	expectView(byCustomer, `{"group":"ana","count":2,"sum_amount":25}`, `{"group":"caio","count":1,"sum_amount":7}`)
+
+	for _, mv := range []*storage.MaterializedView{paid, byCustomer} {
+		if err := mv.Close(); err != nil || mv.Err() != nil {
+			t.Fatalf("Close %s: %v, %v", mv.Name(), err, mv.Err())
+		}
+	}
+
+	// Changes while the view is closed are picked up by the refresh when
+	// it is created again over its table.
+	if err := se.InsertRow("orders", order(5, "bia", "paid", 1), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := paid.Sync(context.Background()); err != storage.ErrViewClosed {
+		t.Fatalf("Sync after Close = %v, want ErrViewClosed", err)
+	}
+	if err := se.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if se, err = storage.Open(dir, storage.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	paid, byCustomer = open("paid"), open("by_customer")
+	defer paid.Close()
+	defer byCustomer.Close()
+	expectView(paid, `{"id":2,"amount":20}`, `{"id":4,"amount":7}`, `{"id":5,"amount":1}`)
+	if err := se.TruncateTable("orders"); err != nil {
+		t.Fatal(err)
+	}
+	expectView(byCustomer)
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// A multikey index (Index.Multikey) indexes an array field: the row key
+// is a types.ArrayKey and every distinct element gets its own posting
+// pointing at the same heap version. The posting helpers expand an
+// ArrayKey into its elements, so inserting, removing and pruning a row
+// version touches one entry per element. Lookups use a single element.
+
+// indexKeyElements returns the entries key occupies in an index.
+func indexKeyElements(key types.Comparable) []types.Comparable {
+	if elems, ok := key.(types.ArrayKey); ok {
+		return elems
+	}
+	return []types.Comparable{key}
+}
+
+// multikeyFromBson builds the key of a multikey index from a field value.
+// A scalar is indexed as a one-element array.
+func multikeyFromBson(value interface{}) types.ArrayKey {
+	var elems []interface{}
+	switch v := value.(type) {
+	case bson.A:
+		elems = v
+	case []interface{}:
+		elems = v
+	default:
+		return types.ArrayKey{comparableFromBson(value)}
+	}
+	key := make(types.ArrayKey, len(elems))
+	for i, elem := range elems {
+		key[i] = comparableFromBson(elem)
+	}
+	return key
+}
+
+// normalizeIndexKey gives keys of a multikey index their stored form:
+// scalars become one-element arrays and elements are sorted without
+// repeats, as the index collation compares them. Elements of mixed types
+// are left as they are for validateKeyForIndex to reject.
+func normalizeIndexKey(idx *Index, key types.Comparable) types.Comparable {
+	if !idx.Multikey || key == nil {
+		return key
+	}
+	elems, ok := key.(types.ArrayKey)
+	if !ok {
+		return types.ArrayKey{key}
+	}
+	for _, elem := range elems {
+		if getTypeFromKey(elem) != getTypeFromKey(elems[0]) {
+			return elems
+		}
+		if _, nested := elem.(types.ArrayKey); nested {
+			return elems
+		}
+	}
+	sorted := append(types.ArrayKey(nil), elems...)
+	sort.Slice(sorted, func(i, j int) bool { return idx.Collation.Compare(sorted[i], sorted[j]) < 0 })
+	unique := sorted[:0]
+	for _, elem := range sorted {
+		if len(unique) == 0 || idx.Collation.Compare(unique[len(unique)-1], elem) != 0 {
+			unique = append(unique, elem)
+		}
+	}
+	return unique
+}
+
+func validateMultikey(index *Index, key types.ArrayKey) error {
+	if !index.Multikey {
+		return fmt.Errorf("storage: index %s is not multikey and cannot take array key %v", index.Name, key)
+	}
+	for _, elem := range key {
+		if _, nested := elem.(types.ArrayKey); nested {
+			return fmt.Errorf("storage: multikey index %s cannot take nested arrays", index.Name)
+		}
+		if err := validateKeyForIndex(index, elem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keyMatches reports whether condition holds for the row key of an
+// index. An array key matches when any element does.
+func keyMatches(condition *query.ScanCondition, key types.Comparable) bool {
+	for _, elem := range indexKeyElements(key) {
+		if condition.Matches(elem) {
+			return true
+		}
+	}
+	return false
+}
+
+// multikeySeen filters the repeated visits of a multikey scan: a row
+// with several elements in the scanned range has one posting per
+// element. It returns nil for other indexes.
+func multikeySeen(index *Index) func(recordID int64) bool {
+	if !index.Multikey {
+		return nil
+	}
+	seen := make(map[int64]struct{})
+	return func(recordID int64) bool {
+		if _, ok := seen[recordID]; ok {
+			return true
+		}
+		seen[recordID] = struct{}{}
+		return false
+	}
+}
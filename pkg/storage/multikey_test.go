@@ -0,0 +1,117 @@
+package storage_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/btree"
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+func openArticlesEngine(t *testing.T, dir string) *storage.StorageEngine {
+	t.Helper()
+	hm, err := storage.NewHeapForTable(storage.HeapFormatV2, filepath.Join(dir, "heap.data"))
+	if err != nil {
+		t.Fatalf("Failed to create heap: %v", err)
+	}
+	tableMgr := storage.NewTableMenager()
+	if err := tableMgr.NewTable("articles", []storage.Index{
+		{Name: "id", Primary: true, Type: storage.TypeInt},
+		{Name: "tags", Type: storage.TypeVarchar, Multikey: true},
+	}, 3, hm); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	walWriter, err := wal.NewWALWriter(filepath.Join(dir, "wal.log"), wal.DefaultOptions())
+	if err != nil {
+		t.Fatalf("Failed to create WAL: %v", err)
+	}
+	se, err := storage.NewProductionStorageEngine(tableMgr, walWriter)
+	if err != nil {
+		walWriter.Close()
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	return se
+}
+
+func tagPostings(t *testing.T, se *storage.StorageEngine, tag string) []int64 {
+	t.Helper()
+	table, err := se.TableMetaData.GetTableByName("articles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	postings, err := table.Indices["tags"].Tree.(btree.MultiValueTree).GetAll(types.VarcharKey(tag))
+	if err != nil {
+		t.Fatalf("GetAll postings: %v", err)
+	}
+	return postings
+}
+
+func TestMultikeyIndex_IndexesEveryArrayElement(t *testing.T) {
+	dir := t.TempDir()
+	se := openArticlesEngine(t, dir)
+
+	for _, doc := range []string{
+		`{"id": 1, "tags": ["go", "db", "go"]}`,
+		`{"id": 2, "tags": ["db"]}`,
+		`{"id": 3, "tags": "go"}`,
+		`{"id": 4, "tags": []}`,
+	} {
+		if err := se.InsertRow("articles", doc, nil); err != nil {
+			t.Fatalf("InsertRow %s: %v", doc, err)
+		}
+	}
+	if err := se.InsertRow("articles", `{"id": 5, "tags": ["go", 1]}`, nil); err == nil {
+		t.Fatal("expected mixed element types to be rejected")
+	}
+
+	docs, _ := se.GetAll("articles", "tags", types.VarcharKey("go"))
+	assertIDs(t, "go", docs, "1", "3")
+	docs, _ = se.GetAll("articles", "tags", types.VarcharKey("db"))
+	assertIDs(t, "db", docs, "1", "2")
+	if postings := tagPostings(t, se, "go"); len(postings) != 2 {
+		t.Fatalf("repeated element indexed twice: %v", postings)
+	}
+
+	// A scan reaching several elements of a row returns it once.
+	docs, err := se.Scan("articles", "tags", query.Between(types.VarcharKey("a"), types.VarcharKey("z")))
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	assertIDs(t, "scan", docs, "1", "2", "3")
+	if count, _ := se.Count("articles", "tags", nil); count != 3 {
+		t.Fatalf("expected 3 tagged rows, got %d", count)
+	}
+
+	snapshot := se.BeginRead()
+	if err := se.UpsertRow("articles", `{"id": 1, "tags": ["db", "storage"]}`, nil); err != nil {
+		t.Fatalf("UpsertRow: %v", err)
+	}
+	docs, _ = se.GetAll("articles", "tags", types.VarcharKey("go"))
+	assertIDs(t, "go after update", docs, "3")
+	docs, _ = snapshot.GetAll("articles", "tags", types.VarcharKey("go"))
+	assertIDs(t, "go in snapshot", docs, "1", "3")
+	snapshot.Close()
+
+	if _, err := se.DeleteRow("articles", types.IntKey(3)); err != nil {
+		t.Fatalf("DeleteRow: %v", err)
+	}
+	if freed, err := se.PruneVersions("articles"); err != nil || freed != 2 {
+		t.Fatalf("PruneVersions: freed=%d err=%v", freed, err)
+	}
+	if postings := tagPostings(t, se, "go"); len(postings) != 0 {
+		t.Fatalf("go postings left behind: %v", postings)
+	}
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	se2 := openArticlesEngine(t, dir)
+	defer se2.Close()
+	docs, _ = se2.GetAll("articles", "tags", types.VarcharKey("storage"))
+	assertIDs(t, "storage after recovery", docs, "1")
+	docs, _ = se2.GetAll("articles", "tags", types.VarcharKey("db"))
+	assertIDs(t, "db after recovery", docs, "1", "2")
+}
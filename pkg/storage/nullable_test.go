@@ -0,0 +1,115 @@
+package storage_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+func openReportsEngine(t *testing.T, dir string) *storage.StorageEngine {
+	t.Helper()
+	hm, err := storage.NewHeapForTable(storage.HeapFormatV2, filepath.Join(dir, "heap.data"))
+	if err != nil {
+		t.Fatalf("Failed to create heap: %v", err)
+	}
+	tableMgr := storage.NewTableMenager()
+	if err := tableMgr.NewTable("reports", []storage.Index{
+		{Name: "id", Primary: true, Type: storage.TypeInt},
+		{Name: "manager", Type: storage.TypeInt, Nullable: true},
+		{Name: "dept", Type: storage.TypeVarchar},
+	}, 3, hm); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	walWriter, err := wal.NewWALWriter(filepath.Join(dir, "wal.log"), wal.DefaultOptions())
+	if err != nil {
+		t.Fatalf("Failed to create WAL: %v", err)
+	}
+	se, err := storage.NewProductionStorageEngine(tableMgr, walWriter)
+	if err != nil {
+		walWriter.Close()
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	return se
+}
+
+func TestNullableIndex_IndexesMissingAndNullFields(t *testing.T) {
+	dir := t.TempDir()
+	se := openReportsEngine(t, dir)
+
+	for _, doc := range []string{
+		`{"id": 1, "dept": "Eng"}`,
+		`{"id": 2, "manager": null, "dept": "Eng"}`,
+		`{"id": 3, "manager": 1, "dept": "Eng"}`,
+		`{"id": 4, "manager": -5, "dept": "Ops"}`,
+	} {
+		if err := se.InsertRow("reports", doc, nil); err != nil {
+			t.Fatalf("InsertRow %s: %v", doc, err)
+		}
+	}
+	if err := se.InsertRow("reports", "opaque", map[string]types.Comparable{
+		"id":   types.IntKey(5),
+		"dept": types.VarcharKey("Ops"),
+	}); err != nil {
+		t.Fatalf("InsertRow without manager key: %v", err)
+	}
+	if err := se.InsertRow("reports", `{"id": 6, "manager": 1}`, nil); err == nil {
+		t.Fatal("expected a missing non-nullable field to be rejected")
+	}
+	if err := se.InsertRow("reports", "opaque", map[string]types.Comparable{
+		"id":   types.IntKey(6),
+		"dept": types.NullKey{},
+	}); err == nil {
+		t.Fatal("expected NULL in a non-nullable index to be rejected")
+	}
+
+	check := func(se *storage.StorageEngine, label string) {
+		t.Helper()
+		docs, err := se.Scan("reports", "manager", query.IsNull())
+		if err != nil {
+			t.Fatalf("%s: Scan IsNull: %v", label, err)
+		}
+		if len(docs) != 3 {
+			t.Fatalf("%s: expected 3 NULL managers, got %v", label, docs)
+		}
+		docs, _ = se.Scan("reports", "manager", query.IsNotNull())
+		assertIDs(t, label+" not null", docs, "3", "4")
+		// NULL sorts first and stays out of comparisons.
+		docs, _ = se.Scan("reports", "manager", nil, storage.ScanOptions{Limit: 1, Offset: 3})
+		assertIDs(t, label+" first non-null", docs, "4")
+		docs, _ = se.Scan("reports", "manager", query.LessThan(types.IntKey(10)))
+		assertIDs(t, label+" manager < 10", docs, "3", "4")
+		if count, _ := se.Count("reports", "manager", query.IsNull()); count != 3 {
+			t.Fatalf("%s: expected Count IsNull 3, got %d", label, count)
+		}
+		docs, _ = se.Scan("reports", "dept", query.And(query.Equal(types.VarcharKey("Eng")), query.Field("manager", query.IsNull())))
+		assertIDs(t, label+" Eng without manager", docs, "1", "2")
+		if docs, _ := se.Scan("reports", "dept", query.IsNull()); len(docs) != 0 {
+			t.Fatalf("%s: non-nullable index returned NULL rows %v", label, docs)
+		}
+	}
+	check(se, "live")
+
+	// Crash without a checkpoint: NULL keys come back from the WAL.
+	se.WAL.Close()
+	se2 := openReportsEngine(t, dir)
+	defer se2.Close()
+	check(se2, "after recovery")
+}
+
+func TestNullableIndex_RejectsNullablePrimary(t *testing.T) {
+	hm, err := storage.NewHeapForTable(storage.HeapFormatV2, filepath.Join(t.TempDir(), "heap.data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hm.Close()
+	err = storage.NewTableMenager().NewTable("reports", []storage.Index{
+		{Name: "id", Primary: true, Type: storage.TypeInt, Nullable: true},
+	}, 3, hm)
+	if err == nil {
+		t.Fatal("expected a nullable primary index to be rejected")
+	}
+}
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bobboyms/storage-engine/pkg/crypto"
+	"github.com/bobboyms/storage-engine/pkg/errors"
+	"github.com/bobboyms/storage-engine/pkg/tracing"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+// Names of the files Open keeps in the DataDir. Heaps are named after
+// their table, "<table>.heap", and indexes after the heap.
+const (
+	catalogFileName = "catalog.json"
+	walFileName     = "wal.log"
+	heapFileSuffix  = ".heap"
+)
+
+// Options configure Open. The zero value opens a clear-text engine with
+// wal.DefaultOptions() and no background jobs.
+type Options struct {
+	// WAL configures the write-ahead log; nil uses wal.DefaultOptions().
+	// Its Cipher is replaced by Cipher.
+	WAL *wal.Options
+
+	// Cipher encrypts the WAL, heaps and indexes (TDE); nil writes them
+	// in clear. A directory must always be opened with the same cipher.
+	Cipher crypto.Cipher
+
+	// Tracer, when set, is installed before recovery (see SetTracer).
+	Tracer tracing.Tracer
+
+	// Checkpoints, when set, starts the CheckpointScheduler.
+	Checkpoints *CheckpointSchedulerConfig
+
+	// TTL, when set, starts the TTLExpirer.
+	TTL *TTLExpirerConfig
+
+	// InMemory opens an engine that never touches disk: heaps and indexes
+	// live in memory (see pagestore.MemPrefix) and there is no WAL, as
+	// with NewStorageEngine and a nil writer. The dir given to Open is
+	// ignored and everything is dropped on Close. WAL and Checkpoints
+	// must be nil.
+	InMemory bool
+}
+
+// Open opens the engine kept in the data directory dir, laid out and
+// locked by a DataDir, creating it on first use. It loads the catalog,
+// reopening every table, opens the WAL and replays it, so the engine
+// returned is ready for use. New tables are added with CreateTable; Close
+// releases everything, the directory lock included.
+func Open(dir string, opts Options) (*StorageEngine, error) {
+	if opts.InMemory {
+		return openInMemory(opts)
+	}
+	dataDir, err := OpenDataDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// A copied or restored directory may carry a catalog with absolute
+	// paths into the original one; opening those would write to another
+	// database.
+	tables, err := openCatalogTableMenager(dataDir.CatalogPath(), dataDir.HeapDir(), opts.Cipher)
+	if err != nil {
+		dataDir.Close()
+		return nil, fmt.Errorf("storage: open %s: %w", dir, err)
+	}
+
+	walOpts := wal.DefaultOptions()
+	if opts.WAL != nil {
+		walOpts = *opts.WAL
+	}
+	walOpts.Cipher = opts.Cipher
+	walWriter, err := wal.NewWALWriter(filepath.Join(dataDir.WALDir(), walFileName), walOpts)
+	if err != nil {
+		tables.closeAll()
+		dataDir.Close()
+		return nil, fmt.Errorf("storage: open %s: %w", dir, databaseLockedError(err))
+	}
+	walWriter.SetTracer(opts.Tracer)
+
+	se, err := NewProductionStorageEngine(tables, walWriter)
+	if err != nil {
+		_ = walWriter.Close()
+		tables.closeAll()
+		dataDir.Close()
+		return nil, err
+	}
+	se.dataDir = dataDir
+	se.cipher = opts.Cipher
+
+	if opts.Checkpoints != nil {
+		if err := se.CheckpointScheduler.Start(*opts.Checkpoints); err != nil {
+			_ = se.Close()
+			return nil, err
+		}
+	}
+	if opts.TTL != nil {
+		if err := se.TTLExpirer.Start(*opts.TTL); err != nil {
+			_ = se.Close()
+			return nil, err
+		}
+	}
+	return se, nil
+}
+
+// openInMemory is Open with Options.InMemory.
+func openInMemory(opts Options) (*StorageEngine, error) {
+	if opts.WAL != nil || opts.Checkpoints != nil {
+		return nil, fmt.Errorf("storage: an in-memory engine has no WAL to configure or checkpoint")
+	}
+	se, err := NewStorageEngine(NewEncryptedTableMenager(opts.Cipher), nil)
+	if err != nil {
+		return nil, err
+	}
+	se.SetTracer(opts.Tracer)
+	se.dataDir = newMemDataDir()
+	se.cipher = opts.Cipher
+	if opts.TTL != nil {
+		if err := se.TTLExpirer.Start(*opts.TTL); err != nil {
+			_ = se.Close()
+			return nil, err
+		}
+	}
+	return se, nil
+}
+
+// Dir returns the data directory of an engine opened with Open, or "".
+// An in-memory engine has a "mem:" path.
+func (se *StorageEngine) Dir() string {
+	if se.dataDir == nil {
+		return ""
+	}
+	return se.dataDir.Root()
+}
+
+// DataDir returns the data directory layout of an engine opened with
+// Open, or nil.
+func (se *StorageEngine) DataDir() *DataDir {
+	return se.dataDir
+}
+
+// CreateTable creates a table in the data directory of an engine opened
+// with Open: its heap is "heap/<table>.heap" and its indexes sit next to
+// it.
+// The table is added to the catalog, so the next Open reopens it.
+func (se *StorageEngine) CreateTable(tableName string, indices []Index) error {
+	if se.dataDir == nil {
+		return fmt.Errorf("storage: CreateTable needs an engine opened with Open")
+	}
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return err
+	}
+	if tableName == "" || filepath.Base(tableName) != tableName {
+		return fmt.Errorf("storage: table name %q cannot be used as a file name", tableName)
+	}
+	// The heap file of an existing table must not be opened twice.
+	if _, err := se.TableMetaData.GetTableByName(tableName); err == nil {
+		return &errors.TableAlreadyExistsError{Name: tableName}
+	}
+
+	heapPath := filepath.Join(se.dataDir.HeapDir(), tableName+heapFileSuffix)
+	hm, err := NewHeapForTable(HeapFormatV2, heapPath, se.cipher)
+	if err != nil {
+		return err
+	}
+	if err := se.TableMetaData.NewTable(tableName, indices, 0, hm); err != nil {
+		hm.Close()
+		_ = os.Remove(heapPath)
+		return err
+	}
+	return nil
+}
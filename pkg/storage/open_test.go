@@ -0,0 +1,337 @@
+package storage_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bobboyms/storage-engine/pkg/crypto"
+	storageErrors "github.com/bobboyms/storage-engine/pkg/errors"
+	"github.com/bobboyms/storage-engine/pkg/pagestore"
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/tracing"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+func TestOpen_CreatesAndReopensDataDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	se, err := storage.Open(dir, storage.Options{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if se.Dir() != dir {
+		t.Fatalf("Dir = %q, want %q", se.Dir(), dir)
+	}
+	if err := se.CreateTable("users", []storage.Index{
+		{Name: "id", Primary: true, Type: storage.TypeInt},
+		{Name: "email", Type: storage.TypeVarchar},
+	}); err != nil {
+		t.Fatalf("CreateTable: %v", err)
+	}
+	if err := se.InsertRow("users", `{"id": 1, "email": "ana@example.com"}`, nil); err != nil {
+		t.Fatalf("InsertRow: %v", err)
+	}
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	rec := &tracing.Recorder{}
+	se, err = storage.Open(dir, storage.Options{Tracer: rec})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer se.Close()
+	doc, found, err := se.Get("users", "email", types.VarcharKey("ana@example.com"))
+	if err != nil || !found {
+		t.Fatalf("Get after reopen = %q, %v, %v", doc, found, err)
+	}
+	findSpan(t, rec.Spans(), "storage.Recover", "")
+
+	err = se.CreateTable("users", []storage.Index{{Name: "id", Primary: true, Type: storage.TypeInt}})
+	var exists *storageErrors.TableAlreadyExistsError
+	if !errors.As(err, &exists) {
+		t.Fatalf("CreateTable of an existing table = %v, want TableAlreadyExistsError", err)
+	}
+	if err := se.CreateTable("../escape", []storage.Index{{Name: "id", Primary: true, Type: storage.TypeInt}}); err == nil {
+		t.Fatal("CreateTable with a path in the name should fail")
+	}
+	if err := se.CreateTable("orders", []storage.Index{{Name: "id", Type: storage.TypeInt}}); err == nil {
+		t.Fatal("CreateTable without a primary index should fail")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "heap", "orders.heap")); !os.IsNotExist(err) {
+		t.Fatalf("heap of the failed table left behind: %v", err)
+	}
+}
+
+func TestOpen_AppliesOptions(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	cipher, err := crypto.NewAESGCM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	walOpts := wal.GroupCommitOptions()
+	dir := t.TempDir()
+	se, err := storage.Open(dir, storage.Options{
+		WAL:         &walOpts,
+		Cipher:      cipher,
+		Checkpoints: &storage.CheckpointSchedulerConfig{Operations: 1, PollInterval: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := se.CreateTable("users", []storage.Index{{Name: "id", Primary: true, Type: storage.TypeInt}}); err != nil {
+		t.Fatalf("CreateTable: %v", err)
+	}
+	if err := se.InsertRow("users", `{"id": 1, "name": "secret-name"}`, nil); err != nil {
+		t.Fatalf("InsertRow: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for se.CheckpointScheduler.Stats().Checkpoints == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the checkpoint scheduler did not run")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	for _, name := range []string{"wal/wal.log", "heap/users.heap"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(data, []byte("secret-name")) {
+			t.Fatalf("%s holds the document in clear", name)
+		}
+	}
+
+	se, err = storage.Open(dir, storage.Options{Cipher: cipher})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer se.Close()
+	if _, found, err := se.Get("users", "id", types.IntKey(1)); err != nil || !found {
+		t.Fatalf("Get after reopen = %v, %v", found, err)
+	}
+}
+
+func TestOpen_InMemory(t *testing.T) {
+	dir := t.TempDir()
+	se, err := storage.Open(dir, storage.Options{InMemory: true})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if !pagestore.IsMemPath(se.Dir()) {
+		t.Fatalf("Dir = %q, want a memory path", se.Dir())
+	}
+	if err := se.CreateTable("users", []storage.Index{
+		{Name: "id", Primary: true, Type: storage.TypeInt},
+		{Name: "email", Type: storage.TypeVarchar},
+	}); err != nil {
+		t.Fatalf("CreateTable: %v", err)
+	}
+	for i := 1; i <= 500; i++ {
+		if err := se.InsertRow("users", fmt.Sprintf(`{"id": %d, "email": "user%d@example.com"}`, i, i), nil); err != nil {
+			t.Fatalf("InsertRow %d: %v", i, err)
+		}
+	}
+	tx := se.BeginWriteTransaction()
+	if err := tx.Del("users", "id", types.IntKey(1)); err != nil {
+		t.Fatalf("Del: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if _, found, err := se.Get("users", "email", types.VarcharKey("user250@example.com")); err != nil || !found {
+		t.Fatalf("Get = %v, %v", found, err)
+	}
+	rows, err := se.Scan("users", "id", query.GreaterOrEqual(types.IntKey(0)))
+	if err != nil || len(rows) != 499 {
+		t.Fatalf("Scan = %d rows, %v", len(rows), err)
+	}
+	if err := se.Vacuum("users"); err != nil {
+		t.Fatalf("Vacuum: %v", err)
+	}
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Fatalf("an in-memory engine wrote %v to disk (%v)", entries, err)
+	}
+
+	se, err = storage.Open(dir, storage.Options{InMemory: true})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer se.Close()
+	if _, err := se.TableMetaData.GetTableByName("users"); err == nil {
+		t.Fatal("an in-memory engine kept its tables after Close")
+	}
+
+	walOpts := wal.DefaultOptions()
+	if _, err := storage.Open("", storage.Options{InMemory: true, WAL: &walOpts}); err == nil {
+		t.Fatal("Open InMemory with WAL options should fail")
+	}
+}
+
+func TestOpen_TruncatesTornWALTail(t *testing.T) {
+	dir := t.TempDir()
+	se, err := storage.Open(dir, storage.Options{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := se.CreateTable("users", []storage.Index{{Name: "id", Primary: true, Type: storage.TypeInt}}); err != nil {
+		t.Fatalf("CreateTable: %v", err)
+	}
+	if err := se.InsertRow("users", `{"id": 1}`, nil); err != nil {
+		t.Fatalf("InsertRow: %v", err)
+	}
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// A crash mid-append: an entry spanning pages loses its last page.
+	walPath := filepath.Join(dir, "wal", "wal.log")
+	w, err := wal.NewWALWriter(walPath, wal.DefaultOptions())
+	if err != nil {
+		t.Fatalf("NewWALWriter: %v", err)
+	}
+	payload := bytes.Repeat([]byte("x"), 3*pagestore.PageSize)
+	if err := w.WriteEntry(&wal.WALEntry{
+		Header: wal.WALHeader{
+			Magic: wal.WALMagic, Version: wal.WALVersion, EntryType: wal.EntryInsert, LSN: 1000,
+			PayloadLen: uint32(len(payload)), CRC32: wal.CalculateCRC32(payload),
+		},
+		Payload: payload,
+	}); err != nil {
+		t.Fatalf("WriteEntry: %v", err)
+	}
+	w.Close()
+	info, err := os.Stat(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(walPath, info.Size()-pagestore.PageSize); err != nil {
+		t.Fatal(err)
+	}
+
+	se, err = storage.Open(dir, storage.Options{})
+	if err != nil {
+		t.Fatalf("Open with a torn WAL tail: %v", err)
+	}
+	if se.WAL.TornTailBytes() == 0 {
+		t.Fatal("the torn tail was not truncated")
+	}
+	if err := se.InsertRow("users", `{"id": 2}`, nil); err != nil {
+		t.Fatalf("InsertRow after repair: %v", err)
+	}
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	se, err = storage.Open(dir, storage.Options{})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer se.Close()
+	for _, id := range []int64{1, 2} {
+		if _, found, err := se.Get("users", "id", types.IntKey(id)); err != nil || !found {
+			t.Fatalf("Get(%d) = %v, %v", id, found, err)
+		}
+	}
+	report, err := se.CheckWALIntegrity()
+	if err != nil || len(report.Issues) != 0 {
+		t.Fatalf("CheckWALIntegrity = %+v, %v", report, err)
+	}
+}
+
+// copyDataDir copies the regular files under src to dst, as a backup
+// restore would.
+func copyDataDir(t *testing.T, src, dst string) {
+	t.Helper()
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0o755)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dst, rel), data, 0o644)
+	})
+	if err != nil {
+		t.Fatalf("copy %s: %v", src, err)
+	}
+}
+
+func TestOpen_RejectsCatalogFilesOutsideDataDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	se, err := storage.Open(dir, storage.Options{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := se.CreateTable("users", []storage.Index{{Name: "id", Primary: true, Type: storage.TypeInt}}); err != nil {
+		t.Fatalf("CreateTable: %v", err)
+	}
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// A catalog with absolute paths, as older versions wrote it.
+	catalogPath := filepath.Join(dir, "catalog.json")
+	data, err := os.ReadFile(catalogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var catalog storage.Catalog
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		t.Fatal(err)
+	}
+	for i := range catalog.Tables {
+		ct := &catalog.Tables[i]
+		ct.HeapPath = filepath.Join(dir, ct.HeapPath)
+		for j := range ct.Indices {
+			ct.Indices[j].Path = filepath.Join(dir, ct.Indices[j].Path)
+		}
+	}
+	if data, err = json.Marshal(catalog); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(catalogPath, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	copied := filepath.Join(t.TempDir(), "copy")
+	copyDataDir(t, dir, copied)
+	if se, err := storage.Open(copied, storage.Options{}); err == nil || !strings.Contains(err.Error(), "outside") {
+		if se != nil {
+			se.Close()
+		}
+		t.Fatalf("Open of a copy pointing at the original = %v, want an outside-the-directory error", err)
+	}
+
+	se, err = storage.Open(dir, storage.Options{})
+	if err != nil {
+		t.Fatalf("Open of the original: %v", err)
+	}
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
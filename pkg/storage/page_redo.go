@@ -4,7 +4,7 @@ import (
 	"encoding/binary"
 	"fmt"
 
-	btreev2 "github.com/bobboyms/storage-engine/pkg/btree/v2"
+	"github.com/bobboyms/storage-engine/pkg/btree"
 	heapv2 "github.com/bobboyms/storage-engine/pkg/heap/v2"
 	"github.com/bobboyms/storage-engine/pkg/pagestore"
 	"github.com/bobboyms/storage-engine/pkg/wal"
@@ -21,6 +21,14 @@ type pageRedoTarget interface {
 	ApplyPageRedo(pageID pagestore.PageID, page *pagestore.Page, lsn uint64) (bool, error)
 }
 
+// redoTree is an index tree backed by a page file: both BTreeV2 and
+// PostingTree qualify.
+type redoTree interface {
+	redoHookable
+	pageRedoTarget
+	Path() string
+}
+
 func serializePageRedoPayload(path string, pageID pagestore.PageID, page *pagestore.Page) ([]byte, error) {
 	if len(path) > 0xFFFF {
 		return nil, fmt.Errorf("storage: redo path too long: %d", len(path))
@@ -55,7 +63,7 @@ func (se *StorageEngine) registerPageRedoHooks() {
 		return
 	}
 	seenHeaps := make(map[*heapv2.HeapV2]struct{})
-	seenTrees := make(map[*btreev2.BTreeV2]struct{})
+	seenTrees := make(map[btree.Tree]struct{})
 
 	for _, tableName := range se.TableMetaData.ListTables() {
 		table, err := se.TableMetaData.GetTableByName(tableName)
@@ -74,18 +82,20 @@ func (se *StorageEngine) registerPageRedoHooks() {
 		}
 
 		for _, idx := range table.GetIndices() {
-			treeV2, ok := idx.Tree.(*btreev2.BTreeV2)
-			if !ok {
-				continue
-			}
-			if _, done := seenTrees[treeV2]; done {
-				continue
+			for _, fileTree := range indexFileTrees(idx.Tree) {
+				tree, ok := fileTree.(redoTree)
+				if !ok {
+					continue
+				}
+				if _, done := seenTrees[fileTree]; done {
+					continue
+				}
+				treePath := tree.Path()
+				tree.SetBeforeFlushHook(func(pageID pagestore.PageID, page *pagestore.Page) error {
+					return se.writePageRedoRecord(treePath, pageID, page)
+				})
+				seenTrees[fileTree] = struct{}{}
 			}
-			treePath := treeV2.Path()
-			treeV2.SetBeforeFlushHook(func(pageID pagestore.PageID, page *pagestore.Page) error {
-				return se.writePageRedoRecord(treePath, pageID, page)
-			})
-			seenTrees[treeV2] = struct{}{}
 		}
 	}
 }
@@ -139,8 +149,10 @@ func (se *StorageEngine) pageRedoTargets() map[string]pageRedoTarget {
 			targets[heapV2.Path()] = heapV2
 		}
 		for _, idx := range table.GetIndices() {
-			if treeV2, ok := idx.Tree.(*btreev2.BTreeV2); ok {
-				targets[treeV2.Path()] = treeV2
+			for _, fileTree := range indexFileTrees(idx.Tree) {
+				if tree, ok := fileTree.(redoTree); ok {
+					targets[tree.Path()] = tree
+				}
 			}
 		}
 	}
@@ -0,0 +1,104 @@
+package storage_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// TestPartitionedPrimaryIndex creates a primary index with 4 partitions,
+// inserts with concurrent writers and checks that the scans come out in
+// order, that the index comes back the same after a reopen and that
+// DropTable removes every partition.
+func TestPartitionedPrimaryIndex(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	se, err := storage.Open(dir, storage.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := se.CreateTable("bad", []storage.Index{
+		{Name: "id", Primary: true, Type: storage.TypeInt},
+		{Name: "v", Type: storage.TypeVarchar, Partitions: 2},
+	}); err == nil {
+		t.Fatal("CreateTable with a partitioned secondary index should fail")
+	}
+	if err := se.CreateTable("t", []storage.Index{
+		{Name: "id", Primary: true, Type: storage.TypeInt, Partitions: 4},
+		{Name: "v", Type: storage.TypeVarchar},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	const writers, perWriter = 4, 100
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for g := 0; g < writers; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				id := i*writers + g
+				if err := se.InsertRow("t", fmt.Sprintf(`{"id": %d, "v": "v%d"}`, id, id), nil); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	checkScan := func(se *storage.StorageEngine) {
+		t.Helper()
+		docs, err := se.Scan("t", "id", query.Between(types.IntKey(50), types.IntKey(149)))
+		if err != nil || len(docs) != 100 {
+			t.Fatalf("Scan = %d docs, %v; want 100", len(docs), err)
+		}
+		for i, doc := range docs {
+			if !strings.Contains(doc, fmt.Sprintf(`"v%d"`, 50+i)) {
+				t.Fatalf("Scan[%d] = %s, want id %d", i, doc, 50+i)
+			}
+		}
+		docs, err = se.Scan("t", "id", query.LessThan(types.IntKey(10)), storage.ScanOptions{Reverse: true})
+		if err != nil || len(docs) != 10 || !strings.Contains(docs[0], `"v9"`) || !strings.Contains(docs[9], `"v0"`) {
+			t.Fatalf("reverse Scan = %v, %v", docs, err)
+		}
+	}
+	checkScan(se)
+	if err := se.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	se, err = storage.Open(dir, storage.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer se.Close()
+	checkScan(se)
+	if doc, found, err := se.Get("t", "id", types.IntKey(399)); err != nil || !found || !strings.Contains(doc, `"v399"`) {
+		t.Fatalf("Get(399) = %s, %v, %v", doc, found, err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "heap", "*.t.id.btree.v2*"))
+	if len(files) != 4 {
+		t.Fatalf("index files = %v, want 4 partitions", files)
+	}
+	if err := se.DropTable("t"); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Fatalf("%s survived DropTable: %v", file, err)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/bobboyms/storage-engine/pkg/crypto"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+// RecoverOptions stops recovery at a point in time. With neither target
+// set, Recover replays the whole WAL.
+//
+// Point-in-time recovery only moves forward: it must run on data files
+// from before the target, e.g. a backup restored with RestoreBackup,
+// together with the WAL written since that backup.
+type RecoverOptions struct {
+	// TargetLSN keeps the records with LSN <= TargetLSN. A transaction is
+	// kept only if its COMMIT is.
+	TargetLSN uint64
+	// TargetTime keeps the records logged before TargetTime. The WAL
+	// holds a clock mark every WALClockInterval at most, so records logged
+	// up to one interval after TargetTime may be kept too.
+	TargetTime time.Time
+	// Verify runs a ReplayVerifier over the replayed records and fails the
+	// recovery with a *ReplayError when a primary key is not left on the
+	// version of its last committed write.
+	Verify bool
+}
+
+// WALClockInterval is the minimum time between two clock marks in the WAL,
+// the precision of RecoverOptions.TargetTime.
+const WALClockInterval = time.Second
+
+// Point-in-time recovery cuts the WAL at an LSN: records above it are
+// skipped by analysis, redo and undo. The cut is logged as an
+// EntryDiscard record naming the skipped LSN range, so every later
+// recovery skips it too, even after new records are appended behind it.
+
+// logWALClock writes a clock mark, before the record with LSN next, when
+// the last one is older than WALClockInterval. The mark carries next-1:
+// records from next on were logged after it.
+func (se *StorageEngine) logWALClock(ctx context.Context, next uint64) error {
+	now := time.Now().UnixNano()
+	last := se.walClock.Load()
+	if last != 0 && now-last < int64(WALClockInterval) {
+		return nil
+	}
+	if !se.walClock.CompareAndSwap(last, now) {
+		return nil
+	}
+	se.lsnClock.observe(time.Unix(0, now), next-1)
+	payload := binary.LittleEndian.AppendUint64(nil, uint64(now))
+
+	entry := wal.AcquireEntry()
+	entry.Header.Magic = wal.WALMagic
+	entry.Header.Version = 1
+	entry.Header.EntryType = wal.EntryClock
+	entry.Header.LSN = next - 1
+	entry.Header.PayloadLen = uint32(len(payload))
+	entry.Header.CRC32 = wal.CalculateCRC32(payload)
+	entry.Payload = append(entry.Payload, payload...)
+
+	err := se.WAL.WriteEntryCtx(ctx, entry)
+	wal.ReleaseEntry(entry)
+	if err != nil {
+		return fmt.Errorf("wal write clock failed: %w", err)
+	}
+	return nil
+}
+
+// clockMark is the LSN current at a wall-clock time.
+type clockMark struct {
+	lsn  uint64
+	time time.Time
+}
+
+// lsnRange is an inclusive range of LSNs.
+type lsnRange struct {
+	from, to uint64
+}
+
+type lsnRanges []lsnRange
+
+func (rs lsnRanges) contains(lsn uint64) bool {
+	for _, r := range rs {
+		if lsn >= r.from && lsn <= r.to {
+			return true
+		}
+	}
+	return false
+}
+
+// recoveryTimeline is what recovery needs to know before its analysis:
+// the LSN ranges to skip and the highest LSN in the WAL.
+type recoveryTimeline struct {
+	discards lsnRanges
+	// cut is the range opts cuts off, logged once recovery is done.
+	cut    *lsnRange
+	maxLSN uint64
+}
+
+// scanRecoveryTimeline reads the EntryDiscard records and clock marks of
+// the WAL and turns opts into the LSN range to cut off.
+func scanRecoveryTimeline(walPath string, cipher crypto.Cipher, opts RecoverOptions) (*recoveryTimeline, error) {
+	timeline := &recoveryTimeline{}
+	if _, err := os.Stat(walPath); os.IsNotExist(err) {
+		return timeline, nil
+	}
+	reader, err := wal.NewWALReaderWithCipher(walPath, cipher)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var clocks []clockMark
+	var checkpoints []uint64
+	for count := 0; ; count++ {
+		entry, err := reader.ReadEntry()
+		if err == io.EOF || (err != nil && isExpectedWALTail(err)) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("timeline scan error at entry %d: %w", count, err)
+		}
+		header := entry.Header
+		if header.LSN > timeline.maxLSN {
+			timeline.maxLSN = header.LSN
+		}
+		switch header.EntryType {
+		case wal.EntryDiscard:
+			r, err := deserializeDiscardEntry(entry.Payload)
+			if err != nil {
+				wal.ReleaseEntry(entry)
+				return nil, err
+			}
+			timeline.discards = append(timeline.discards, r)
+		case wal.EntryClock:
+			if len(entry.Payload) >= 8 {
+				nanos := int64(binary.LittleEndian.Uint64(entry.Payload[:8]))
+				clocks = append(clocks, clockMark{lsn: header.LSN, time: time.Unix(0, nanos)})
+			}
+		case wal.EntryCheckpoint:
+			checkpoints = append(checkpoints, header.LSN)
+		}
+		wal.ReleaseEntry(entry)
+	}
+
+	target, hasTarget := opts.TargetLSN, opts.TargetLSN > 0
+	if !opts.TargetTime.IsZero() {
+		for _, mark := range clocks {
+			if timeline.discards.contains(mark.lsn) || !mark.time.After(opts.TargetTime) {
+				continue
+			}
+			if !hasTarget || mark.lsn < target {
+				target, hasTarget = mark.lsn, true
+			}
+			break
+		}
+	}
+	if !hasTarget || target >= timeline.maxLSN {
+		return timeline, nil
+	}
+	for _, lsn := range checkpoints {
+		if lsn > target && !timeline.discards.contains(lsn) {
+			return nil, fmt.Errorf("storage: recovery target LSN %d is before the checkpoint at LSN %d: restore a backup taken before the target", target, lsn)
+		}
+	}
+	cut := lsnRange{from: target + 1, to: timeline.maxLSN}
+	timeline.cut = &cut
+	timeline.discards = append(timeline.discards, cut)
+	return timeline, nil
+}
+
+// logDiscard makes the cut of a point-in-time recovery permanent.
+func (se *StorageEngine) logDiscard(r lsnRange) error {
+	if se.WAL == nil {
+		return nil
+	}
+	if err := se.writeAutoCommitWAL(wal.EntryDiscard, serializeDiscardEntry(r), se.lsnTracker.Next()); err != nil {
+		return err
+	}
+	return se.WAL.Sync()
+}
+
+// recoveryReader reads the WAL without the records in discarded ranges.
+type recoveryReader struct {
+	*wal.WALReader
+	discards lsnRanges
+}
+
+func newRecoveryReader(walPath string, cipher crypto.Cipher, discards lsnRanges) (*recoveryReader, error) {
+	reader, err := wal.NewWALReaderWithCipher(walPath, cipher)
+	if err != nil {
+		return nil, err
+	}
+	return &recoveryReader{WALReader: reader, discards: discards}, nil
+}
+
+func (r *recoveryReader) ReadEntry() (*wal.WALEntry, error) {
+	for {
+		entry, err := r.WALReader.ReadEntry()
+		if err != nil {
+			return nil, err
+		}
+		if entry.Header.EntryType == wal.EntryDiscard || !r.discards.contains(entry.Header.LSN) {
+			return entry, nil
+		}
+		wal.ReleaseEntry(entry)
+	}
+}
+
+// serializeDiscardEntry: from and to, one uint64 each.
+func serializeDiscardEntry(r lsnRange) []byte {
+	buf := binary.LittleEndian.AppendUint64(make([]byte, 0, 16), r.from)
+	return binary.LittleEndian.AppendUint64(buf, r.to)
+}
+
+func deserializeDiscardEntry(data []byte) (lsnRange, error) {
+	if len(data) < 16 {
+		return lsnRange{}, fmt.Errorf("discard entry too short: %d", len(data))
+	}
+	return lsnRange{from: binary.LittleEndian.Uint64(data[:8]), to: binary.LittleEndian.Uint64(data[8:16])}, nil
+}
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+// copyWALFiles replaces the WAL of dst with the active WAL and segments of
+// src.
+func copyWALFiles(t *testing.T, src, dst string) {
+	t.Helper()
+	old, _ := filepath.Glob(filepath.Join(dst, "accounts.wal*"))
+	for _, path := range old {
+		if err := os.Remove(path); err != nil {
+			t.Fatalf("remove %s: %v", path, err)
+		}
+	}
+	paths, _ := filepath.Glob(filepath.Join(src, "accounts.wal*"))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read %s: %v", path, err)
+		}
+		if err := os.WriteFile(filepath.Join(dst, filepath.Base(path)), data, 0600); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+}
+
+func assertAccounts(t *testing.T, label string, se *StorageEngine, want ...int64) {
+	t.Helper()
+	var got []string
+	for id := int64(1); id <= 10; id++ {
+		if _, found, err := se.Get("accounts", "id", types.IntKey(id)); err != nil {
+			t.Fatalf("%s: Get %d: %v", label, id, err)
+		} else if found {
+			got = append(got, fmt.Sprint(id))
+		}
+	}
+	var wantIDs []string
+	for _, id := range want {
+		wantIDs = append(wantIDs, fmt.Sprint(id))
+	}
+	if strings.Join(got, ",") != strings.Join(wantIDs, ",") {
+		t.Fatalf("%s: expected accounts %v, got %v", label, wantIDs, got)
+	}
+}
+
+func TestPointInTimeRecovery_UndoesBulkDelete(t *testing.T) {
+	for _, byTime := range []bool{false, true} {
+		src := filepath.Join(t.TempDir(), "db")
+		db := newBackupTestDB(t, src)
+		for id := int64(1); id <= 3; id++ {
+			putAccount(t, db.engine, id, fmt.Sprintf("user-%d@example.com", id))
+		}
+		backupDir := filepath.Join(t.TempDir(), "backup")
+		if _, err := db.engine.BackupOnline(backupDir); err != nil {
+			t.Fatalf("BackupOnline: %v", err)
+		}
+		putAccount(t, db.engine, 4, "user-4@example.com")
+		putAccount(t, db.engine, 5, "user-5@example.com")
+
+		opts := RecoverOptions{TargetLSN: db.engine.lsnTracker.Current()}
+		if byTime {
+			time.Sleep(5 * time.Millisecond)
+			opts = RecoverOptions{TargetTime: time.Now()}
+			time.Sleep(5 * time.Millisecond)
+			db.engine.walClock.Store(0) // the next write logs a clock mark
+		}
+
+		// The accident: every account deleted, then life goes on.
+		for id := int64(1); id <= 5; id++ {
+			if _, err := db.engine.DeleteRow("accounts", types.IntKey(id)); err != nil {
+				t.Fatalf("DeleteRow %d: %v", id, err)
+			}
+		}
+		putAccount(t, db.engine, 6, "user-6@example.com")
+		db.engine.WAL.Close()
+
+		restoreDir := filepath.Join(t.TempDir(), "restore")
+		if _, err := RestoreBackup(backupDir, restoreDir); err != nil {
+			t.Fatalf("RestoreBackup: %v", err)
+		}
+		copyWALFiles(t, src, restoreDir)
+
+		restored := openPITRTestDB(t, restoreDir)
+		if err := restored.Recover(filepath.Join(restoreDir, "accounts.wal"), opts); err != nil {
+			t.Fatalf("Recover to %+v: %v", opts, err)
+		}
+		assertAccounts(t, fmt.Sprintf("byTime=%v: after point-in-time recovery", byTime), restored, 1, 2, 3, 4, 5)
+		putAccount(t, restored, 7, "user-7@example.com")
+		restored.WAL.Close()
+
+		// A full recovery keeps the cut: the delete stays undone.
+		reopened := reopenBackupTestDB(t, restoreDir)
+		assertAccounts(t, fmt.Sprintf("byTime=%v: after reopen", byTime), reopened, 1, 2, 3, 4, 5, 7)
+		reopened.Close()
+	}
+}
+
+func TestPointInTimeRecovery_RejectsTargetBeforeCheckpoint(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	db := newBackupTestDB(t, dir)
+	putAccount(t, db.engine, 1, "user-1@example.com")
+	target := db.engine.lsnTracker.Current()
+	putAccount(t, db.engine, 2, "user-2@example.com")
+	if err := db.engine.FuzzyCheckpoint(); err != nil {
+		t.Fatalf("FuzzyCheckpoint: %v", err)
+	}
+	putAccount(t, db.engine, 3, "user-3@example.com")
+	db.engine.WAL.Close()
+
+	se := openPITRTestDB(t, dir)
+	defer se.WAL.Close()
+	if err := se.Recover(db.walPath, RecoverOptions{TargetLSN: target}); err == nil {
+		t.Fatal("expected a target before the last checkpoint to be rejected")
+	}
+}
+
+// openPITRTestDB opens the accounts database without recovering it.
+func openPITRTestDB(t *testing.T, dir string) *StorageEngine {
+	t.Helper()
+	hm, err := NewHeapForTable(HeapFormatV2, filepath.Join(dir, "accounts.heap"))
+	if err != nil {
+		t.Fatalf("NewHeapForTable: %v", err)
+	}
+	meta := NewTableMenager()
+	if err := meta.NewTable("accounts", []Index{
+		{Name: "id", Primary: true, Type: TypeInt},
+		{Name: "email", Type: TypeVarchar},
+	}, 0, hm); err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	ww, err := wal.NewWALWriter(filepath.Join(dir, "accounts.wal"), wal.DefaultOptions())
+	if err != nil {
+		t.Fatalf("NewWALWriter: %v", err)
+	}
+	se, err := NewStorageEngine(meta, ww)
+	if err != nil {
+		t.Fatalf("NewStorageEngine: %v", err)
+	}
+	return se
+}
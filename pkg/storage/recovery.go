@@ -8,7 +8,7 @@ import (
 	"io"
 	"os"
 
-	btreev2 "github.com/bobboyms/storage-engine/pkg/btree/v2"
+	"github.com/bobboyms/storage-engine/pkg/btree"
 	"github.com/bobboyms/storage-engine/pkg/crypto"
 	"github.com/bobboyms/storage-engine/pkg/types"
 	"github.com/bobboyms/storage-engine/pkg/wal"
@@ -47,9 +47,13 @@ func findLastCheckpointLSNWithCipher(walPath string, cipher crypto.Cipher) (uint
 			return 0, false, err
 		}
 		if entry.Header.EntryType == wal.EntryCheckpoint && len(entry.Payload) >= 8 {
-			beginLSN := binary.LittleEndian.Uint64(entry.Payload[:8])
-			if beginLSN >= lastCheckpointLSN {
-				lastCheckpointLSN = beginLSN
+			record, err := wal.DecodeCheckpoint(entry.Payload)
+			if err != nil {
+				wal.ReleaseEntry(entry)
+				return 0, false, err
+			}
+			if record.BeginLSN >= lastCheckpointLSN {
+				lastCheckpointLSN = record.BeginLSN
 				found = true
 			}
 		}
@@ -78,19 +82,43 @@ type recoveryTxnState struct {
 	LastLSN  uint64
 }
 
+// observe advances the status of a transaction with one of its records.
+// COMMIT and ABORT set the outcome; other records (the CLRs an undo logs
+// after an ABORT, say) only mark an unknown transaction active. Each
+// transaction ID is logged by one transaction only (see scanWALHighWater).
+func (s *recoveryTxnState) observe(entryType uint8) {
+	switch entryType {
+	case wal.EntryCommit:
+		s.Status = recoveryTxnCommitted
+	case wal.EntryAbort:
+		s.Status = recoveryTxnAborted
+	default:
+		if s.Status == recoveryTxnUnknown {
+			s.Status = recoveryTxnActive
+		}
+	}
+}
+
 type recoveryAnalysis struct {
 	MaxLSN        uint64
-	CheckpointLSN uint64 // beginLSN do último checkpoint; 0 = not encontrado
+	MaxTxID       uint64
+	CheckpointLSN uint64 // beginLSN of the last checkpoint; 0 = not found
+	// Fences: per table/index, the highest LSN already durable according
+	// to the checkpoints (see CreateCheckpoint).
+	Fences map[string]uint64
 	DirtyIndexes  map[string]uint64
 	TxTable       map[uint64]recoveryTxnState
 	CommittedTxs  map[uint64]struct{}
 	LoserTxs      map[uint64]struct{}
 	UndoneLSNs    map[uint64]map[uint64]struct{}
+	// Discards are the LSN ranges cut off by point-in-time recovery.
+	Discards lsnRanges
 }
 
 func newRecoveryAnalysis() *recoveryAnalysis {
 	return &recoveryAnalysis{
 		DirtyIndexes: make(map[string]uint64),
+		Fences:       make(map[string]uint64),
 		TxTable:      make(map[uint64]recoveryTxnState),
 		CommittedTxs: make(map[uint64]struct{}),
 		LoserTxs:     make(map[uint64]struct{}),
@@ -126,13 +154,23 @@ func (se *StorageEngine) analyzeRecovery(walPath string) (*recoveryAnalysis, err
 }
 
 func (se *StorageEngine) analyzeRecoveryWithCipher(walPath string, cipher crypto.Cipher) (*recoveryAnalysis, error) {
+	timeline, err := scanRecoveryTimeline(walPath, cipher, RecoverOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return se.analyzeRecoveryTimeline(walPath, cipher, timeline.discards)
+}
+
+// analyzeRecoveryTimeline analyzes the WAL without the records in discards.
+func (se *StorageEngine) analyzeRecoveryTimeline(walPath string, cipher crypto.Cipher, discards lsnRanges) (*recoveryAnalysis, error) {
 	result := newRecoveryAnalysis()
+	result.Discards = discards
 
 	if _, err := os.Stat(walPath); os.IsNotExist(err) {
 		return result, nil
 	}
 
-	reader, err := wal.NewWALReaderWithCipher(walPath, cipher)
+	reader, err := newRecoveryReader(walPath, cipher, discards)
 	if err != nil {
 		return nil, err
 	}
@@ -155,11 +193,12 @@ func (se *StorageEngine) analyzeRecoveryWithCipher(walPath string, cipher crypto
 
 		// Atualiza o checkpoint LSN se encontrar record mais recente.
 		if entry.Header.EntryType == wal.EntryCheckpoint && len(entry.Payload) >= 8 {
-			beginLSN := binary.LittleEndian.Uint64(entry.Payload[:8])
-			if beginLSN >= result.CheckpointLSN {
-				result.CheckpointLSN = beginLSN
-			}
+			record, err := wal.DecodeCheckpoint(entry.Payload)
 			wal.ReleaseEntry(entry)
+			if err != nil {
+				return nil, fmt.Errorf("analysis checkpoint failed at entry %d: %w", count, err)
+			}
+			result.addCheckpoint(record)
 			continue
 		}
 
@@ -178,21 +217,9 @@ func (se *StorageEngine) analyzeRecoveryWithCipher(walPath string, cipher crypto
 				state.LastLSN = entry.Header.LSN
 			}
 
-			switch entry.Header.EntryType {
-			case wal.EntryBegin:
-				if state.Status == recoveryTxnUnknown {
-					state.Status = recoveryTxnActive
-				}
-			case wal.EntryCommit:
-				state.Status = recoveryTxnCommitted
-			case wal.EntryAbort:
-				state.Status = recoveryTxnAborted
-			default:
-				if state.Status == recoveryTxnUnknown {
-					state.Status = recoveryTxnActive
-				}
-			}
+			state.observe(entry.Header.EntryType)
 			result.TxTable[txID] = state
+			result.MaxTxID = max(result.MaxTxID, txID)
 
 			if entry.Header.EntryType == wal.EntryCLR {
 				originalLSN, _, _, _, clrErr := DeserializeCompensationEntry(payload)
@@ -218,17 +245,26 @@ func (se *StorageEngine) analyzeRecoveryWithCipher(walPath string, cipher crypto
 			if _, ok := result.DirtyIndexes[key]; !ok {
 				result.DirtyIndexes[key] = entry.Header.LSN
 			}
-		case wal.EntryMultiInsert:
+		case wal.EntryMultiInsert, wal.EntryMultiDelete:
 			tableName, keys, _, err := DeserializeMultiIndexEntry(payload)
 			if err != nil {
 				wal.ReleaseEntry(entry)
 				return nil, fmt.Errorf("analysis deserialize multi failed at entry %d: %w", count, err)
 			}
-			for indexName := range keys {
-				key := appliedLSNKey(tableName, indexName)
-				if _, ok := result.DirtyIndexes[key]; !ok {
-					result.DirtyIndexes[key] = entry.Header.LSN
+			result.markDirtyIndexes(tableName, keys, entry.Header.LSN)
+		case wal.EntryMultiBatch, wal.EntryMultiDeleteBatch:
+			rows, err := DeserializeBatchEntry(payload)
+			if err != nil {
+				wal.ReleaseEntry(entry)
+				return nil, fmt.Errorf("analysis deserialize batch failed at entry %d: %w", count, err)
+			}
+			for _, row := range rows {
+				tableName, keys, _, err := DeserializeMultiIndexEntry(row)
+				if err != nil {
+					wal.ReleaseEntry(entry)
+					return nil, fmt.Errorf("analysis deserialize batch failed at entry %d: %w", count, err)
 				}
+				result.markDirtyIndexes(tableName, keys, entry.Header.LSN)
 			}
 		}
 
@@ -247,6 +283,91 @@ func (se *StorageEngine) analyzeRecoveryWithCipher(walPath string, cipher crypto
 	return result, nil
 }
 
+// addCheckpoint takes in a checkpoint record. The fences only grow: what a
+// checkpoint already brought to disk never needs redo again.
+func (ra *recoveryAnalysis) addCheckpoint(record wal.CheckpointRecord) {
+	if record.BeginLSN >= ra.CheckpointLSN {
+		ra.CheckpointLSN = record.BeginLSN
+	}
+	// The pages may carry writes up to the EndLSN; new LSNs must not
+	// fall below them.
+	if record.EndLSN > ra.MaxLSN {
+		ra.MaxLSN = record.EndLSN
+	}
+	for key, lsn := range record.Fences {
+		if lsn > ra.Fences[key] {
+			ra.Fences[key] = lsn
+		}
+	}
+}
+
+// fenced reports whether every index the entry touches is covered by a
+// checkpoint fence, so its redo can be skipped. Table-wide entries and
+// CLRs are never fenced.
+func (ra *recoveryAnalysis) fenced(entry *wal.WALEntry, payload []byte) (bool, error) {
+	if len(ra.Fences) == 0 {
+		return false, nil
+	}
+	lsn := entry.Header.LSN
+	covered := func(tableName, indexName string) bool {
+		fence, ok := ra.Fences[appliedLSNKey(tableName, indexName)]
+		return ok && lsn <= fence
+	}
+	coveredKeys := func(tableName string, keys map[string]types.Comparable) bool {
+		if len(keys) == 0 {
+			return false
+		}
+		for indexName := range keys {
+			if !covered(tableName, indexName) {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch entry.Header.EntryType {
+	case wal.EntryInsert, wal.EntryUpdate, wal.EntryDelete:
+		tableName, indexName, _, _, err := DeserializeDocumentEntry(payload)
+		if err != nil {
+			return false, err
+		}
+		return covered(tableName, indexName), nil
+	case wal.EntryMultiInsert, wal.EntryMultiDelete:
+		tableName, keys, _, err := DeserializeMultiIndexEntry(payload)
+		if err != nil {
+			return false, err
+		}
+		return coveredKeys(tableName, keys), nil
+	case wal.EntryMultiBatch, wal.EntryMultiDeleteBatch:
+		rows, err := DeserializeBatchEntry(payload)
+		if err != nil {
+			return false, err
+		}
+		for _, row := range rows {
+			tableName, keys, _, err := DeserializeMultiIndexEntry(row)
+			if err != nil {
+				return false, err
+			}
+			if !coveredKeys(tableName, keys) {
+				return false, nil
+			}
+		}
+		return len(rows) > 0, nil
+	}
+	return false, nil
+}
+
+// markDirtyIndexes records lsn as the first change of every index in keys
+// not seen before.
+func (ra *recoveryAnalysis) markDirtyIndexes(tableName string, keys map[string]types.Comparable, lsn uint64) {
+	for indexName := range keys {
+		key := appliedLSNKey(tableName, indexName)
+		if _, ok := ra.DirtyIndexes[key]; !ok {
+			ra.DirtyIndexes[key] = lsn
+		}
+	}
+}
+
 func (ra *recoveryAnalysis) shouldRedo(entry *wal.WALEntry) ([]byte, bool, error) {
 	// Entradas anteriores ao último checkpoint já estão em disco.
 	// Pular o redo reduz o tempo de startup de O(WAL inteiro) para
@@ -268,6 +389,10 @@ func (ra *recoveryAnalysis) shouldRedo(entry *wal.WALEntry) ([]byte, bool, error
 		return payload, true, nil
 	}
 
+	if fenced, err := ra.fenced(entry, payload); err != nil || fenced {
+		return payload, false, err
+	}
+
 	if !transactional {
 		return payload, true, nil
 	}
@@ -292,7 +417,11 @@ func (se *StorageEngine) redoDocumentEntry(entry *wal.WALEntry, payload []byte,
 		return nil
 	}
 
-	if entry.Header.EntryType == wal.EntryDelete {
+	if postings, ok := index.postings(); ok {
+		if err := redoPostingEntry(table, postings, entry, key, docBytes); err != nil {
+			return err
+		}
+	} else if entry.Header.EntryType == wal.EntryDelete {
 		if shouldSkipDeleteRedo(table, index, key, entry.Header.LSN) {
 			loadedLSNs[appliedLSNKey(tableName, indexName)] = entry.Header.LSN
 			se.appliedLSN.MarkApplied(tableName, indexName, entry.Header.LSN)
@@ -323,12 +452,12 @@ func (se *StorageEngine) redoDocumentEntry(entry *wal.WALEntry, payload []byte,
 			prevOffset = prev
 		}
 
-		offset, err := table.Heap.Write(docBytes, entry.Header.LSN, prevOffset)
+		offset, err := se.redoWriteVersion(table, nil, docBytes, entry.Header.LSN, prevOffset)
 		if err != nil {
 			return fmt.Errorf("heap write failed: %w", err)
 		}
-		if treeV2, ok := index.Tree.(*btreev2.BTreeV2); ok {
-			err = treeV2.ReplaceWithLSN(key, offset, entry.Header.LSN)
+		if tree, ok := index.Tree.(lsnTree); ok {
+			err = tree.ReplaceWithLSN(key, offset, entry.Header.LSN)
 		} else {
 			err = index.Tree.Replace(key, offset)
 		}
@@ -342,31 +471,86 @@ func (se *StorageEngine) redoDocumentEntry(entry *wal.WALEntry, payload []byte,
 	return nil
 }
 
+// redoPostingEntry replays a single-index entry against a non-unique
+// index. Inserts are skipped when a posting created at the entry LSN is
+// already present; deletes only touch rows created before the entry.
+func redoPostingEntry(table *Table, postings btree.MultiValueTree, entry *wal.WALEntry, key types.Comparable, docBytes []byte) error {
+	lsn := entry.Header.LSN
+	if entry.Header.EntryType == wal.EntryDelete {
+		if _, err := tombstonePostings(table, postings, key, lsn); err != nil {
+			return fmt.Errorf("heap delete failed: %w", err)
+		}
+		return nil
+	}
+
+	existing, err := findPostingByLSN(table, postings, key, lsn, false)
+	if err != nil {
+		return err
+	}
+	if existing != -1 {
+		return nil
+	}
+	offset, err := table.Heap.Write(docBytes, lsn, -1)
+	if err != nil {
+		return fmt.Errorf("heap write failed: %w", err)
+	}
+	if err := insertPostingWithLSN(postings, key, offset, lsn); err != nil {
+		return fmt.Errorf("failed to update tree during recovery: %w", err)
+	}
+	return nil
+}
+
 func (se *StorageEngine) redoMultiInsertEntry(entry *wal.WALEntry, payload []byte, loadedLSNs map[string]uint64) error {
 	tableName, keys, docBytes, err := DeserializeMultiIndexEntry(payload)
 	if err != nil {
 		return err
 	}
+	return se.redoMultiInsertRow(tableName, keys, docBytes, entry.Header.LSN, loadedLSNs, loadedLSNs)
+}
 
+// redoMultiInsertBatch replays an EntryMultiBatch. Every row shares the
+// entry's LSN, so each one is checked against the applied LSNs from before
+// the batch rather than those its predecessors just advanced.
+func (se *StorageEngine) redoMultiInsertBatch(entry *wal.WALEntry, payload []byte, loadedLSNs map[string]uint64) error {
+	rows, err := DeserializeBatchEntry(payload)
+	if err != nil {
+		return err
+	}
+	before := cloneKeys(loadedLSNs)
+	for i, row := range rows {
+		tableName, keys, docBytes, err := DeserializeMultiIndexEntry(row)
+		if err != nil {
+			return fmt.Errorf("batch row %d: %w", i, err)
+		}
+		if err := se.redoMultiInsertRow(tableName, keys, docBytes, entry.Header.LSN, before, loadedLSNs); err != nil {
+			return fmt.Errorf("batch row %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// redoMultiInsertRow replays one row write at lsn unless every index
+// already had it in before.
+func (se *StorageEngine) redoMultiInsertRow(tableName string, keys map[string]types.Comparable, docBytes []byte, lsn uint64, before, loadedLSNs map[string]uint64) error {
 	table, err := se.TableMetaData.GetTableByName(tableName)
 	if err != nil {
 		return nil
 	}
 
-	if skip, err := shouldSkipMultiInsertRedo(table, keys, docBytes, entry.Header.LSN); err != nil {
+	if skip, err := shouldSkipMultiInsertRedo(table, keys, docBytes, lsn); err != nil {
 		return err
 	} else if skip {
 		for indexName := range keys {
 			lookupKey := appliedLSNKey(tableName, indexName)
-			loadedLSNs[lookupKey] = entry.Header.LSN
-			se.appliedLSN.MarkApplied(tableName, indexName, entry.Header.LSN)
+			loadedLSNs[lookupKey] = lsn
+			se.appliedLSN.MarkApplied(tableName, indexName, lsn)
 		}
 		return nil
 	}
 
 	needsUpdate := false
 	for indexName := range keys {
-		if loadedLSNs[appliedLSNKey(tableName, indexName)] < entry.Header.LSN {
+		if before[appliedLSNKey(tableName, indexName)] < lsn {
 			needsUpdate = true
 			break
 		}
@@ -378,6 +562,7 @@ func (se *StorageEngine) redoMultiInsertEntry(entry *wal.WALEntry, payload []byt
 	table.Lock()
 	defer table.Unlock()
 
+	keys = existingIndexKeys(table, keys)
 	prevOffset := int64(-1)
 	primary, primaryKey, err := primaryIndexAndKey(table, keys)
 	if err == nil {
@@ -388,25 +573,25 @@ func (se *StorageEngine) redoMultiInsertEntry(entry *wal.WALEntry, payload []byt
 		}
 	}
 
-	offset, err := table.Heap.Write(docBytes, entry.Header.LSN, prevOffset)
+	offset, err := se.redoWriteVersion(table, keys, docBytes, lsn, prevOffset)
 	if err != nil {
 		return fmt.Errorf("heap write failed: %w", err)
 	}
 
-	if err := applyIndexPointersWithLSN(table, keys, offset, entry.Header.LSN); err != nil {
+	if err := applyIndexPointersWithLSN(table, keys, offset, lsn); err != nil {
 		return err
 	}
 
 	if prevOffset != -1 {
-		if err := table.Heap.Delete(prevOffset, entry.Header.LSN); err != nil && !isChainEndErr(err) {
+		if err := table.Heap.Delete(prevOffset, lsn); err != nil && !isChainEndErr(err) {
 			return fmt.Errorf("heap delete previous version during recovery failed: %w", err)
 		}
 	}
 
 	for indexName := range keys {
 		lookupKey := appliedLSNKey(tableName, indexName)
-		loadedLSNs[lookupKey] = entry.Header.LSN
-		se.appliedLSN.MarkApplied(tableName, indexName, entry.Header.LSN)
+		loadedLSNs[lookupKey] = lsn
+		se.appliedLSN.MarkApplied(tableName, indexName, lsn)
 	}
 
 	return nil
@@ -2,6 +2,7 @@ package storage_test
 
 import (
 	"fmt"
+	v2 "github.com/bobboyms/storage-engine/pkg/heap/v2"
 	"github.com/bobboyms/storage-engine/pkg/storage"
 	"github.com/bobboyms/storage-engine/pkg/types"
 	"github.com/bobboyms/storage-engine/pkg/wal"
@@ -321,3 +322,85 @@ func TestRecovery_EmptyWAL(t *testing.T) {
 		t.Fatal("Put after Recover em WAL empty not funcionou")
 	}
 }
+
+// TestRecovery_CrashDuringRecoveryDoesNotDuplicate: a crash in the middle
+// of recovery may bring the heap pages with the redone versions to disk
+// without those of the tree. The next recovery must reuse them, not write
+// copies.
+func TestRecovery_CrashDuringRecoveryDoesNotDuplicate(t *testing.T) {
+	tmpDir := t.TempDir()
+	walPath := filepath.Join(tmpDir, "wal.log")
+	heapPath := filepath.Join(tmpDir, "heap.v2")
+	btreePath := filepath.Join(tmpDir, "idx.btree.v2")
+	const N = 20
+
+	open := func() (*storage.StorageEngine, *v2.HeapV2, *wal.WALWriter) {
+		t.Helper()
+		hm, err := storage.NewHeapForTable(storage.HeapFormatV2, heapPath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		idxTree, err := storage.NewBTreeForIndex(storage.BTreeFormatV2, true, storage.TypeInt, btreePath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tm := storage.NewTableMenager()
+		if err := tm.NewTable("t", []storage.Index{
+			{Name: "id", Primary: true, Type: storage.TypeInt, Tree: idxTree},
+		}, 3, hm); err != nil {
+			t.Fatal(err)
+		}
+		ww, err := wal.NewWALWriter(walPath, wal.DefaultOptions())
+		if err != nil {
+			t.Fatal(err)
+		}
+		se, err := storage.NewStorageEngine(tm, ww)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return se, hm.(*v2.HeapV2), ww
+	}
+
+	// PHASE 1: writes and a crash without flushing the heap/tree.
+	se, _, ww := open()
+	for i := 1; i <= N; i++ {
+		if err := se.Put("t", "id", types.IntKey(int64(i)), fmt.Sprintf(`{"id":%d}`, i)); err != nil {
+			t.Fatalf("Put %d: %v", i, err)
+		}
+	}
+	ww.Close()
+
+	// PHASE 2: recovery, flush of the heap only, and a crash.
+	se, hm, ww := open()
+	if err := se.Recover(walPath); err != nil {
+		t.Fatalf("Recover 1: %v", err)
+	}
+	if err := hm.Sync(); err != nil {
+		t.Fatalf("heap Sync: %v", err)
+	}
+	ww.Close()
+
+	// PHASE 3: recovery again.
+	se, hm, _ = open()
+	defer se.Close()
+	if err := se.Recover(walPath); err != nil {
+		t.Fatalf("Recover 2: %v", err)
+	}
+	for i := 1; i <= N; i++ {
+		if _, found, err := se.Get("t", "id", types.IntKey(int64(i))); err != nil || !found {
+			t.Fatalf("Get %d = %v, %v", i, found, err)
+		}
+	}
+	records := 0
+	if err := hm.ScanRecords(func(_ int64, _ *v2.RecordHeader, err error) error {
+		if err == nil {
+			records++
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if records != N {
+		t.Fatalf("heap has %d records after two recoveries, want %d", records, N)
+	}
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"path/filepath"
+	"strings"
 	"testing"
 	"github.com/bobboyms/storage-engine/pkg/types"
 	"github.com/bobboyms/storage-engine/pkg/wal"
@@ -192,3 +193,312 @@ func TestWriteTransaction_Rollback_PersistsAbortMarker(t *testing.T) {
 		t.Fatalf("BEGIN/ABORT txid mismatch: %d vs %d", beginTxID, abortTxID)
 	}
 }
+
+func writeTxRowForTest(t *testing.T, w *wal.WALWriter, txID, lsn uint64, tableName string, keys map[string]types.Comparable, doc string) {
+	t.Helper()
+
+	bsonDoc, err := JsonToBson(doc)
+	if err != nil {
+		t.Fatalf("tx row to bson: %v", err)
+	}
+	row, err := MarshalBson(bsonDoc)
+	if err != nil {
+		t.Fatalf("marshal tx row: %v", err)
+	}
+	payload, err := SerializeMultiIndexEntry(tableName, keys, row)
+	if err != nil {
+		t.Fatalf("serialize tx row: %v", err)
+	}
+
+	entry := wal.AcquireEntry()
+	entry.Header.Magic = wal.WALMagic
+	entry.Header.Version = txAwareWALVersion
+	entry.Header.EntryType = wal.EntryMultiInsert
+	entry.Header.LSN = lsn
+	entry.Payload = append(entry.Payload, wrapTxPayload(txID, payload)...)
+	entry.Header.PayloadLen = uint32(len(entry.Payload))
+	entry.Header.CRC32 = wal.CalculateCRC32(entry.Payload)
+
+	if err := w.WriteEntry(entry); err != nil {
+		wal.ReleaseEntry(entry)
+		t.Fatalf("write tx row lsn=%d: %v", lsn, err)
+	}
+	wal.ReleaseEntry(entry)
+}
+
+// TestRecovery_TransactionRows_DropsUncommitted: the InsertRow and
+// UpsertRow of a transaction are logged between BEGIN and COMMIT; the
+// replay applies those of the committed transaction and discards those
+// of a transaction without a COMMIT.
+func TestRecovery_TransactionRows_DropsUncommitted(t *testing.T) {
+	tmpDir := t.TempDir()
+	walPath := filepath.Join(tmpDir, "wal.log")
+	indexes := func() []Index {
+		return []Index{
+			{Name: "id", Primary: true, Type: TypeInt},
+			{Name: "email", Type: TypeVarchar},
+		}
+	}
+
+	hm, err := NewHeapForTable(HeapFormatV2, filepath.Join(tmpDir, "heap-1.data"))
+	if err != nil {
+		t.Fatalf("new heap: %v", err)
+	}
+	tableMgr := NewTableMenager()
+	if err := tableMgr.NewTable("users", indexes(), 4, hm); err != nil {
+		t.Fatalf("new table: %v", err)
+	}
+	walWriter, err := wal.NewWALWriter(walPath, wal.DefaultOptions())
+	if err != nil {
+		t.Fatalf("new wal writer: %v", err)
+	}
+	se, err := NewStorageEngine(tableMgr, walWriter)
+	if err != nil {
+		walWriter.Close()
+		t.Fatalf("new storage engine: %v", err)
+	}
+
+	tx := se.BeginWriteTransaction()
+	if err := tx.InsertRow("users", `{"id": 1, "email": "ana@example.com"}`, nil); err != nil {
+		t.Fatalf("tx InsertRow: %v", err)
+	}
+	if err := tx.UpsertRow("users", `{"id": 2, "email": "bia@example.com"}`, nil); err != nil {
+		t.Fatalf("tx UpsertRow: %v", err)
+	}
+	if err := tx.UpsertRow("users", `{"id": 1, "email": "ana@example.org"}`, nil); err != nil {
+		t.Fatalf("tx UpsertRow over own insert: %v", err)
+	}
+	if doc, found, err := tx.Get("users", "email", types.VarcharKey("ana@example.org")); err != nil || !found {
+		t.Fatalf("tx Get of own UpsertRow = %q, %v, %v", doc, found, err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	// Crash: only the WAL is left.
+	if err := walWriter.Close(); err != nil {
+		t.Fatalf("close wal writer: %v", err)
+	}
+
+	// A transaction that died before its COMMIT.
+	writer, err := wal.NewWALWriter(walPath, wal.DefaultOptions())
+	if err != nil {
+		t.Fatalf("reopen wal writer: %v", err)
+	}
+	writeTxMarkerForTest(t, writer, 9001, 1001, wal.EntryBegin)
+	writeTxRowForTest(t, writer, 9001, 1002, "users",
+		map[string]types.Comparable{"id": types.IntKey(1), "email": types.VarcharKey("lost@example.com")},
+		`{"id": 1, "email": "lost@example.com"}`)
+	writeTxRowForTest(t, writer, 9001, 1003, "users",
+		map[string]types.Comparable{"id": types.IntKey(3), "email": types.VarcharKey("cai@example.com")},
+		`{"id": 3, "email": "cai@example.com"}`)
+	if err := writer.Close(); err != nil {
+		t.Fatalf("close wal writer: %v", err)
+	}
+
+	reader, err := wal.NewWALReader(walPath)
+	if err != nil {
+		t.Fatalf("new wal reader: %v", err)
+	}
+	rows := 0
+	for {
+		entry, err := reader.ReadEntry()
+		if err != nil {
+			break
+		}
+		if entry.Header.EntryType == wal.EntryMultiInsert {
+			if _, _, transactional, _ := unwrapTxPayload(entry.Header, entry.Payload); !transactional {
+				t.Fatalf("multi-insert at LSN %d logged outside a transaction", entry.Header.LSN)
+			}
+			rows++
+		}
+		wal.ReleaseEntry(entry)
+	}
+	reader.Close()
+	if rows != 5 {
+		t.Fatalf("WAL holds %d multi-insert entries, want 5", rows)
+	}
+
+	hm2, err := NewHeapForTable(HeapFormatV2, filepath.Join(tmpDir, "heap-2.data"))
+	if err != nil {
+		t.Fatalf("new heap: %v", err)
+	}
+	tableMgr2 := NewTableMenager()
+	if err := tableMgr2.NewTable("users", indexes(), 4, hm2); err != nil {
+		t.Fatalf("new table: %v", err)
+	}
+	walWriter2, err := wal.NewWALWriter(walPath, wal.DefaultOptions())
+	if err != nil {
+		t.Fatalf("reopen wal writer: %v", err)
+	}
+	se2, err := NewStorageEngine(tableMgr2, walWriter2)
+	if err != nil {
+		walWriter2.Close()
+		t.Fatalf("new storage engine: %v", err)
+	}
+	defer se2.Close()
+	if err := se2.Recover(walPath); err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+
+	if doc, found, err := se2.Get("users", "id", types.IntKey(1)); err != nil || !found || !strings.Contains(doc, "ana@example.org") {
+		t.Fatalf("Get(1) = %q, %v, %v; want the committed upsert", doc, found, err)
+	}
+	if _, found, err := se2.Get("users", "id", types.IntKey(2)); err != nil || !found {
+		t.Fatalf("Get(2) = %v, %v; want the committed row", found, err)
+	}
+	if _, found, err := se2.Get("users", "id", types.IntKey(3)); err != nil || found {
+		t.Fatalf("Get(3) = %v, %v; the uncommitted row must be dropped", found, err)
+	}
+	if _, found, err := se2.Get("users", "email", types.VarcharKey("lost@example.com")); err != nil || found {
+		t.Fatalf("Get(email=lost) = %v, %v; the uncommitted row must be dropped", found, err)
+	}
+}
+
+// recoverUsersForTest opens the "users" table over an empty heap and
+// replays the WAL at walPath.
+func recoverUsersForTest(t *testing.T, walPath string) *StorageEngine {
+	t.Helper()
+	return openUsersForTest(t, filepath.Join(t.TempDir(), "heap.data"), walPath)
+}
+
+// openUsersForTest opens the "users" table over the heap at heapPath and
+// replays the WAL at walPath.
+func openUsersForTest(t *testing.T, heapPath, walPath string) *StorageEngine {
+	t.Helper()
+
+	hm, err := NewHeapForTable(HeapFormatV2, heapPath)
+	if err != nil {
+		t.Fatalf("new heap: %v", err)
+	}
+	tableMgr := NewTableMenager()
+	if err := tableMgr.NewTable("users", []Index{{Name: "id", Primary: true, Type: TypeInt}}, 4, hm); err != nil {
+		t.Fatalf("new table: %v", err)
+	}
+	walWriter, err := wal.NewWALWriter(walPath, wal.DefaultOptions())
+	if err != nil {
+		t.Fatalf("reopen wal writer: %v", err)
+	}
+	se, err := NewStorageEngine(tableMgr, walWriter)
+	if err != nil {
+		walWriter.Close()
+		t.Fatalf("new storage engine: %v", err)
+	}
+	t.Cleanup(func() { se.Close() })
+	if err := se.Recover(walPath); err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	return se
+}
+
+func TestRecovery_AbortedTransaction_IsNotReplayed(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), "wal.log")
+	writer, err := wal.NewWALWriter(walPath, wal.DefaultOptions())
+	if err != nil {
+		t.Fatalf("new wal writer: %v", err)
+	}
+
+	writeTxMarkerForTest(t, writer, 1001, 1, wal.EntryBegin)
+	writeTxDocumentForTest(t, writer, 1001, 2, "users", "id", types.IntKey(1), `{"id":1}`)
+	writeTxMarkerForTest(t, writer, 1001, 3, wal.EntryAbort)
+
+	writeTxMarkerForTest(t, writer, 3003, 4, wal.EntryBegin)
+	writeTxDocumentForTest(t, writer, 3003, 5, "users", "id", types.IntKey(3), `{"id":3}`)
+	writeTxMarkerForTest(t, writer, 3003, 6, wal.EntryCommit)
+	if err := writer.Close(); err != nil {
+		t.Fatalf("close wal writer: %v", err)
+	}
+
+	se := recoverUsersForTest(t, walPath)
+	for id, want := range map[int64]bool{1: false, 3: true} {
+		if _, found, err := se.Get("users", "id", types.IntKey(id)); err != nil || found != want {
+			t.Fatalf("Get(%d) = %v, %v; want found=%v", id, found, err, want)
+		}
+	}
+}
+
+func TestRecovery_TornCommit_DropsTransaction(t *testing.T) {
+	walPath := filepath.Join(t.TempDir(), "wal.log")
+	writer, err := wal.NewWALWriter(walPath, wal.DefaultOptions())
+	if err != nil {
+		t.Fatalf("new wal writer: %v", err)
+	}
+	writeTxMarkerForTest(t, writer, 1001, 1, wal.EntryBegin)
+	writeTxDocumentForTest(t, writer, 1001, 2, "users", "id", types.IntKey(1), `{"id":1}`)
+	writeTxMarkerForTest(t, writer, 1001, 3, wal.EntryCommit)
+	writeTxMarkerForTest(t, writer, 2002, 4, wal.EntryBegin)
+	writeTxDocumentForTest(t, writer, 2002, 5, "users", "id", types.IntKey(2), `{"id":2}`)
+
+	// The crash left the COMMIT of the second transaction half written:
+	// its CRC does not match.
+	commit := wal.AcquireEntry()
+	commit.Header.Magic = wal.WALMagic
+	commit.Header.Version = txAwareWALVersion
+	commit.Header.EntryType = wal.EntryCommit
+	commit.Header.LSN = 6
+	commit.Payload = append(commit.Payload, wrapTxPayload(2002, nil)...)
+	commit.Header.PayloadLen = uint32(len(commit.Payload))
+	commit.Header.CRC32 = wal.CalculateCRC32(commit.Payload) + 1
+	if err := writer.WriteEntry(commit); err != nil {
+		t.Fatalf("write torn commit: %v", err)
+	}
+	wal.ReleaseEntry(commit)
+	if err := writer.Close(); err != nil {
+		t.Fatalf("close wal writer: %v", err)
+	}
+
+	se := recoverUsersForTest(t, walPath)
+	if _, found, err := se.Get("users", "id", types.IntKey(1)); err != nil || !found {
+		t.Fatalf("Get(1) = %v, %v; want the committed row", found, err)
+	}
+	if _, found, err := se.Get("users", "id", types.IntKey(2)); err != nil || found {
+		t.Fatalf("Get(2) = %v, %v; a torn COMMIT must not commit", found, err)
+	}
+}
+
+func TestRecovery_TxIDsStayUniqueAcrossCrashes(t *testing.T) {
+	dir := t.TempDir()
+	heapPath, walPath := filepath.Join(dir, "heap.data"), filepath.Join(dir, "wal.log")
+
+	se := openUsersForTest(t, heapPath, walPath)
+	if err := se.InsertRow("users", `{"id": 1}`, nil); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	// Failed autocommit writes take transaction IDs without logging, so
+	// the IDs run ahead of the LSNs.
+	for i := 0; i < 50; i++ {
+		if err := se.InsertRow("users", `{"id": 1}`, nil); err == nil {
+			t.Fatal("expected a duplicate key error")
+		}
+	}
+	rolledBack := se.BeginWriteTransaction()
+	if err := rolledBack.Put("users", "id", types.IntKey(2), `{"id": 2}`); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if err := rolledBack.Rollback(); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	if rolledBack.txID <= se.lsnTracker.Current() {
+		t.Fatalf("setup: tx ID %d should be ahead of LSN %d", rolledBack.txID, se.lsnTracker.Current())
+	}
+	se.WAL.Close() // crash
+
+	se = openUsersForTest(t, heapPath, walPath)
+	committed := se.BeginWriteTransaction()
+	if committed.txID <= rolledBack.txID {
+		t.Fatalf("tx ID %d reused after restart (rolled back tx had %d)", committed.txID, rolledBack.txID)
+	}
+	if err := committed.Put("users", "id", types.IntKey(3), `{"id": 3}`); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if err := committed.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	se.WAL.Close() // crash
+
+	se = openUsersForTest(t, heapPath, walPath)
+	for id, want := range map[int64]bool{1: true, 2: false, 3: true} {
+		if _, found, err := se.Get("users", "id", types.IntKey(id)); err != nil || found != want {
+			t.Fatalf("Get(%d) = %v, %v; want found=%v", id, found, err, want)
+		}
+	}
+}
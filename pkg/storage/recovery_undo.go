@@ -5,7 +5,7 @@ import (
 	"io"
 	"slices"
 
-	btreev2 "github.com/bobboyms/storage-engine/pkg/btree/v2"
+	"github.com/bobboyms/storage-engine/pkg/btree"
 	"github.com/bobboyms/storage-engine/pkg/crypto"
 	v2 "github.com/bobboyms/storage-engine/pkg/heap/v2"
 	"github.com/bobboyms/storage-engine/pkg/types"
@@ -109,7 +109,7 @@ func (se *StorageEngine) undoLoserTransactionsWithLimit(walPath string, cipher c
 }
 
 func (se *StorageEngine) collectLoserUndoTasks(walPath string, cipher crypto.Cipher, analysis *recoveryAnalysis) ([]undoTask, error) {
-	reader, err := wal.NewWALReaderWithCipher(walPath, cipher)
+	reader, err := newRecoveryReader(walPath, cipher, analysis.Discards)
 	if err != nil {
 		return nil, err
 	}
@@ -149,7 +149,7 @@ func (se *StorageEngine) collectLoserUndoTasks(walPath string, cipher crypto.Cip
 		}
 
 		switch entry.Header.EntryType {
-		case wal.EntryInsert, wal.EntryUpdate, wal.EntryDelete, wal.EntryMultiInsert:
+		case wal.EntryInsert, wal.EntryUpdate, wal.EntryDelete, wal.EntryMultiInsert, wal.EntryMultiDelete:
 			body := append([]byte(nil), payload...)
 			tasks = append(tasks, undoTask{
 				txID:      txID,
@@ -228,6 +228,8 @@ func (se *StorageEngine) applyCompensation(clrLSN uint64, clr compensationEntry)
 		return se.undoDocumentEntry(clr.OriginalEntryType, clr.OriginalLSN, clr.OriginalPayload, clrLSN)
 	case wal.EntryMultiInsert:
 		return se.undoMultiInsertEntry(clr.OriginalLSN, clr.OriginalPayload, clrLSN)
+	case wal.EntryMultiDelete:
+		return se.undoMultiDeleteEntry(clr.OriginalLSN, clr.OriginalPayload, clrLSN)
 	default:
 		return fmt.Errorf("unsupported compensation entry type %d", clr.OriginalEntryType)
 	}
@@ -251,6 +253,14 @@ func (se *StorageEngine) undoDocumentEntry(entryType uint8, originalLSN uint64,
 	table.Lock()
 	defer table.Unlock()
 
+	if postings, ok := index.postings(); ok {
+		if err := undoPostingEntry(table, postings, entryType, key, originalLSN, clrLSN); err != nil {
+			return err
+		}
+		se.appliedLSN.MarkApplied(tableName, indexName, clrLSN)
+		return nil
+	}
+
 	head, found, err := index.Tree.Get(key)
 	if err != nil {
 		return err
@@ -294,6 +304,36 @@ func (se *StorageEngine) undoDocumentEntry(entryType uint8, originalLSN uint64,
 	return nil
 }
 
+// undoPostingEntry reverts a single-index entry on a non-unique index. A
+// delete may have tombstoned several rows at the same LSN, so all of them
+// are restored.
+func undoPostingEntry(table *Table, postings btree.MultiValueTree, entryType uint8, key types.Comparable, originalLSN, clrLSN uint64) error {
+	switch entryType {
+	case wal.EntryDelete:
+		for {
+			rid, err := findPostingByLSN(table, postings, key, originalLSN, true)
+			if err != nil {
+				return err
+			}
+			if rid == -1 {
+				return nil
+			}
+			if err := undeleteRecord(table.Heap, rid, originalLSN, clrLSN); err != nil {
+				return err
+			}
+		}
+	case wal.EntryInsert, wal.EntryUpdate:
+		rid, err := findPostingByLSN(table, postings, key, originalLSN, false)
+		if err != nil || rid == -1 {
+			return err
+		}
+		_, err = removePostingWithLSN(postings, key, rid, clrLSN)
+		return err
+	default:
+		return fmt.Errorf("unsupported undo document entry type %d", entryType)
+	}
+}
+
 func (se *StorageEngine) undoMultiInsertEntry(originalLSN uint64, payload []byte, clrLSN uint64) error {
 	tableName, newKeys, _, err := DeserializeMultiIndexEntry(payload)
 	if err != nil {
@@ -343,13 +383,12 @@ func (se *StorageEngine) undoMultiInsertEntry(originalLSN uint64, payload []byte
 		return nil
 	}
 
-	prevDoc, _, err := table.Heap.Read(targetHdr.PrevRecordID)
+	oldKeys, found, err := storedRowKeys(table, targetHdr.PrevRecordID)
 	if err != nil {
 		return err
 	}
-	oldKeys, err := keysFromStoredDocument(table, prevDoc)
-	if err != nil {
-		return err
+	if !found {
+		return fmt.Errorf("storage: stored document missing indexed fields")
 	}
 	if err := undeleteRecord(table.Heap, targetHdr.PrevRecordID, originalLSN, clrLSN); err != nil {
 		return err
@@ -361,14 +400,16 @@ func (se *StorageEngine) undoMultiInsertEntry(originalLSN uint64, payload []byte
 		se.appliedLSN.MarkApplied(tableName, indexName, clrLSN)
 	}
 	for indexName, newKey := range newKeys {
-		oldKey, exists := oldKeys[indexName]
-		if exists && sameComparableKey(oldKey, newKey) {
-			continue
-		}
 		idx, ok := table.Indices[indexName]
 		if !ok {
 			continue
 		}
+		// Postings are per version, so the new one goes even when the key
+		// did not change.
+		oldKey, exists := oldKeys[indexName]
+		if exists && sameComparableKey(oldKey, newKey) && !idx.IsMultiValue() {
+			continue
+		}
 		if err := removeIndexKeyIfMatchesWithLSN(idx, newKey, targetRID, clrLSN); err != nil {
 			return err
 		}
@@ -422,15 +463,15 @@ func undeleteRecord(h interface{}, rid int64, expectedDeleteLSN uint64, pageLSN
 }
 
 func replaceIndexKeyWithLSN(index *Index, key types.Comparable, offset int64, lsn uint64) error {
-	if treeV2, ok := index.Tree.(*btreev2.BTreeV2); ok {
-		return treeV2.ReplaceWithLSN(key, offset, lsn)
+	if tree, ok := index.Tree.(lsnTree); ok {
+		return tree.ReplaceWithLSN(key, offset, lsn)
 	}
 	return index.Tree.Replace(key, offset)
 }
 
 func removeIndexKeyWithLSN(index *Index, key types.Comparable, lsn uint64) error {
-	if treeV2, ok := index.Tree.(*btreev2.BTreeV2); ok {
-		_, err := treeV2.DeleteWithLSN(key, lsn)
+	if tree, ok := index.Tree.(lsnTree); ok {
+		_, err := tree.DeleteWithLSN(key, lsn)
 		return err
 	}
 	_, err := index.Tree.Remove(key)
@@ -438,6 +479,10 @@ func removeIndexKeyWithLSN(index *Index, key types.Comparable, lsn uint64) error
 }
 
 func removeIndexKeyIfMatchesWithLSN(index *Index, key types.Comparable, expectedOffset int64, lsn uint64) error {
+	if postings, ok := index.postings(); ok {
+		_, err := removePostingWithLSN(postings, key, expectedOffset, lsn)
+		return err
+	}
 	current, found, err := index.Tree.Get(key)
 	if err != nil || !found {
 		return err
@@ -475,7 +520,7 @@ func keysFromStoredDocument(table *Table, docBytes []byte) (map[string]types.Com
 func keysFromBSONForIndexes(indexes []*Index, bsonDoc bson.D) (map[string]types.Comparable, bool, error) {
 	keys := make(map[string]types.Comparable)
 	for _, idx := range indexes {
-		key, err := GetValueFromBson(bsonDoc, idx.Name)
+		key, err := idx.keyFromBson(bsonDoc)
 		if err != nil {
 			return nil, false, nil
 		}
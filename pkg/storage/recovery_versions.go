@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"bytes"
+
+	v2 "github.com/bobboyms/storage-engine/pkg/heap/v2"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// redoVersionIndex finds the heap versions an interrupted recovery already
+// wrote. When a crash hits mid-recovery the heap page holding a replayed
+// version may reach disk while the index pages pointing to it do not, so
+// the next replay cannot see it through the indexes. Matching the version
+// by CreateLSN and document lets the replay reuse it instead of writing a
+// duplicate.
+//
+// The heap of a table is scanned once, on the first write its replay
+// cannot skip, and only versions created at or after floor are kept.
+type redoVersionIndex struct {
+	floor  uint64
+	tables map[*Table]map[uint64][]int64
+}
+
+func newRedoVersionIndex(floor uint64) *redoVersionIndex {
+	return &redoVersionIndex{floor: floor, tables: make(map[*Table]map[uint64][]int64)}
+}
+
+// take returns the version of table created at lsn holding doc, if the
+// heap has one, and forgets it so it is reused once. A nil index finds
+// nothing.
+func (rv *redoVersionIndex) take(table *Table, lsn uint64, doc []byte) (int64, bool, error) {
+	if rv == nil {
+		return 0, false, nil
+	}
+	byLSN, ok := rv.tables[table]
+	if !ok {
+		var err error
+		if byLSN, err = rv.scan(table); err != nil {
+			return 0, false, err
+		}
+		rv.tables[table] = byLSN
+	}
+
+	rids := byLSN[lsn]
+	for i, rid := range rids {
+		current, _, err := table.Heap.Read(rid)
+		if err != nil || !bytes.Equal(current, doc) {
+			continue
+		}
+		byLSN[lsn] = append(rids[:i:i], rids[i+1:]...)
+		return rid, true, nil
+	}
+	return 0, false, nil
+}
+
+func (rv *redoVersionIndex) scan(table *Table) (map[uint64][]int64, error) {
+	byLSN := make(map[uint64][]int64)
+	heapV2, ok := table.Heap.(*v2.HeapV2)
+	if !ok {
+		return byLSN, nil
+	}
+	err := heapV2.ScanRecords(func(rid int64, rh *v2.RecordHeader, err error) error {
+		// Unreadable or vacuumed slots cannot serve as reusable versions.
+		if err != nil || rh.CreateLSN < rv.floor {
+			return nil
+		}
+		byLSN[rh.CreateLSN] = append(byLSN[rh.CreateLSN], rid)
+		return nil
+	})
+	return byLSN, err
+}
+
+// redoWriteVersion writes a row version during redo, reusing the one an
+// interrupted recovery left in the heap (see redoVersionIndex).
+func (se *StorageEngine) redoWriteVersion(table *Table, keys map[string]types.Comparable, doc []byte, lsn uint64, prevOffset int64) (int64, error) {
+	if rid, ok, err := se.redoVersions.take(table, lsn, doc); err != nil || ok {
+		return rid, err
+	}
+	return writeRowVersion(table, keys, doc, lsn, prevOffset)
+}
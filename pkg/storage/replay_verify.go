@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+// ReplayMismatch is a primary key whose index entry does not point at the
+// version its last committed write produced.
+type ReplayMismatch struct {
+	Table   string
+	Key     types.Comparable
+	WantLSN uint64 // LSN of the last committed write of the key
+	GotLSN  uint64 // CreateLSN of the version the index points at; 0 if none
+	Deleted bool   // the last write was a delete
+	Detail  string
+}
+
+func (m ReplayMismatch) String() string {
+	return fmt.Sprintf("%s[%v]: %s (want LSN %d, got %d)", m.Table, m.Key, m.Detail, m.WantLSN, m.GotLSN)
+}
+
+// ReplayError is returned by ReplayVerifier.Verify, and by Recover with
+// RecoverOptions.Verify, when some key is not at its latest version.
+type ReplayError struct {
+	Mismatches []ReplayMismatch
+}
+
+func (e *ReplayError) Error() string {
+	lines := make([]string, 0, len(e.Mismatches))
+	for _, m := range e.Mismatches {
+		lines = append(lines, m.String())
+	}
+	return fmt.Sprintf("replay verification: %d keys off their latest version: %s", len(e.Mismatches), strings.Join(lines, "; "))
+}
+
+// replayWrite is the last committed write a ReplayVerifier saw for a key.
+type replayWrite struct {
+	key     types.Comparable
+	lsn     uint64
+	deleted bool
+}
+
+// ReplayVerifier checks that replaying a log leaves every primary key on
+// its newest version: the primary index must point at the version created
+// by the highest-LSN committed write of the key, or at no live version
+// when that write was a delete. Feed it the committed changes in LSN
+// order with Observe, then call Verify once they are applied.
+//
+// Writes that carry no primary key (a Put through a secondary index) are
+// not checked.
+type ReplayVerifier struct {
+	tables map[string]map[string]replayWrite
+}
+
+// NewReplayVerifier returns a verifier that has seen no writes.
+func NewReplayVerifier() *ReplayVerifier {
+	return &ReplayVerifier{tables: make(map[string]map[string]replayWrite)}
+}
+
+// Observe records a committed change. A truncate or drop forgets the
+// writes seen before it for the table.
+func (rv *ReplayVerifier) Observe(record LogicalRecord) {
+	switch record.Op {
+	case LogicalTruncate, LogicalDropTable:
+		delete(rv.tables, record.Table)
+		return
+	}
+	keys := rv.tables[record.Table]
+	if keys == nil {
+		keys = make(map[string]replayWrite)
+		rv.tables[record.Table] = keys
+	}
+	for indexName, key := range record.Keys {
+		id := indexName + "\x00" + fmt.Sprint(key)
+		if last, ok := keys[id]; ok && last.lsn > record.LSN {
+			continue
+		}
+		keys[id] = replayWrite{key: key, lsn: record.LSN, deleted: record.Op == LogicalDelete}
+	}
+}
+
+// Verify checks every key Observe saw against the engine. Keys of tables
+// that no longer exist, or of indexes that are not the primary one, are
+// ignored. It returns a *ReplayError listing the keys off their latest
+// version, or nil.
+func (rv *ReplayVerifier) Verify(se *StorageEngine) error {
+	var mismatches []ReplayMismatch
+	tableNames := make([]string, 0, len(rv.tables))
+	for name := range rv.tables {
+		tableNames = append(tableNames, name)
+	}
+	sort.Strings(tableNames)
+
+	for _, tableName := range tableNames {
+		table, err := se.TableMetaData.GetTableByName(tableName)
+		if err != nil {
+			continue
+		}
+		primary, err := primaryIndex(table)
+		if err != nil {
+			continue
+		}
+		prefix := primary.Name + "\x00"
+		for id, write := range rv.tables[tableName] {
+			if !strings.HasPrefix(id, prefix) {
+				continue
+			}
+			if m, ok := verifyLatestVersion(table, primary, write); !ok {
+				m.Table = tableName
+				mismatches = append(mismatches, m)
+			}
+		}
+	}
+	if len(mismatches) == 0 {
+		return nil
+	}
+	sort.Slice(mismatches, func(i, j int) bool {
+		if mismatches[i].Table != mismatches[j].Table {
+			return mismatches[i].Table < mismatches[j].Table
+		}
+		return mismatches[i].Key.Compare(mismatches[j].Key) < 0
+	})
+	return &ReplayError{Mismatches: mismatches}
+}
+
+func verifyLatestVersion(table *Table, primary *Index, write replayWrite) (ReplayMismatch, bool) {
+	m := ReplayMismatch{Key: write.key, WantLSN: write.lsn, Deleted: write.deleted}
+	rid, found, err := primary.Tree.Get(write.key)
+	if err != nil {
+		m.Detail = fmt.Sprintf("index lookup failed: %v", err)
+		return m, false
+	}
+	if !found {
+		if write.deleted {
+			return m, true
+		}
+		m.Detail = "key missing from the index"
+		return m, false
+	}
+	_, header, err := table.Heap.Read(rid)
+	if err != nil {
+		if write.deleted && isChainEndErr(err) {
+			return m, true
+		}
+		m.Detail = fmt.Sprintf("record %d unreadable: %v", rid, err)
+		return m, false
+	}
+	m.GotLSN = header.CreateLSN
+	switch {
+	case write.deleted && header.Valid:
+		m.Detail = fmt.Sprintf("record %d is live after the delete", rid)
+		return m, false
+	case !write.deleted && header.CreateLSN != write.lsn:
+		m.Detail = fmt.Sprintf("index points at record %d, not the newest version", rid)
+		return m, false
+	case !write.deleted && !header.Valid:
+		m.Detail = fmt.Sprintf("newest version, record %d, is deleted", rid)
+		return m, false
+	}
+	return m, true
+}
+
+// observeEntry feeds a committed data entry of the WAL to the verifier.
+// Entries before the checkpoint and writes of transactions that did not
+// commit are not observed; fenced entries are, since their effects are
+// already on disk.
+func (rv *ReplayVerifier) observeEntry(analysis *recoveryAnalysis, entry *wal.WALEntry) error {
+	if analysis.CheckpointLSN > 0 && entry.Header.LSN < analysis.CheckpointLSN {
+		return nil
+	}
+	txID, payload, transactional, err := unwrapTxPayload(entry.Header, entry.Payload)
+	if err != nil {
+		return err
+	}
+	if transactional {
+		if _, committed := analysis.CommittedTxs[txID]; !committed {
+			return nil
+		}
+	}
+	records, err := decodeLogicalRecords(entry.Header.EntryType, payload, entry.Header.LSN)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		rv.Observe(record)
+	}
+	return nil
+}
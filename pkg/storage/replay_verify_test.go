@@ -0,0 +1,158 @@
+package storage_test
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+// openReplayEngine opens table "t" (id INT primary, v VARCHAR
+// secondary) over the files of dir, as a restart would.
+func openReplayEngine(t *testing.T, dir string) (*storage.StorageEngine, *wal.WALWriter) {
+	t.Helper()
+	hm, err := storage.NewHeapForTable(storage.HeapFormatV2, filepath.Join(dir, "t.heap"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idTree, err := storage.NewBTreeForIndex(storage.BTreeFormatV2, true, storage.TypeInt, filepath.Join(dir, "id.btree"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vTree, err := storage.NewBTreeForIndex(storage.BTreeFormatV2, false, storage.TypeVarchar, filepath.Join(dir, "v.btree"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm := storage.NewTableMenager()
+	err = tm.NewTable("t", []storage.Index{
+		{Name: "id", Primary: true, Type: storage.TypeInt, Tree: idTree},
+		{Name: "v", Type: storage.TypeVarchar, Tree: vTree},
+	}, 3, hm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ww, err := wal.NewWALWriter(filepath.Join(dir, "wal.log"), wal.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	se, err := storage.NewStorageEngine(tm, ww)
+	if err != nil {
+		ww.Close()
+		t.Fatal(err)
+	}
+	return se, ww
+}
+
+// TestRecover_LatestVersionWins updates the same keys several times, in
+// different orders and on both sides of a checkpoint, "crashes" without
+// flushing the heap and trees and checks that the replay leaves every key
+// on its last committed version.
+func TestRecover_LatestVersionWins(t *testing.T) {
+	dir := t.TempDir()
+	want := make(map[int64]string)
+	{
+		se, ww := openReplayEngine(t, dir)
+		row := func(id int64, v string) {
+			t.Helper()
+			if err := se.UpsertRow("t", fmt.Sprintf(`{"id": %d, "v": %q}`, id, v), nil); err != nil {
+				t.Fatalf("UpsertRow(%d): %v", id, err)
+			}
+			want[id] = v
+		}
+		for id := int64(1); id <= 20; id++ {
+			row(id, fmt.Sprintf("a%d", id))
+		}
+		for id := int64(20); id >= 1; id -= 2 {
+			row(id, fmt.Sprintf("b%d", id))
+		}
+		if err := se.CreateCheckpoint(); err != nil {
+			t.Fatal(err)
+		}
+		for id := int64(1); id <= 20; id += 3 {
+			row(id, fmt.Sprintf("c%d", id))
+			if err := se.UpdateFields("t", "id", types.IntKey(id), map[string]interface{}{"v": fmt.Sprintf("d%d", id)}); err != nil {
+				t.Fatal(err)
+			}
+			want[id] = fmt.Sprintf("d%d", id)
+		}
+		for _, id := range []int64{5, 6} {
+			if _, err := se.DeleteRow("t", types.IntKey(id)); err != nil {
+				t.Fatal(err)
+			}
+			delete(want, id)
+		}
+		row(6, "e6")
+		row(2, "e2")
+
+		// Crash: only the WAL reaches the disk.
+		if err := ww.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	se, ww := openReplayEngine(t, dir)
+	defer ww.Close()
+	if err := se.Recover(filepath.Join(dir, "wal.log"), storage.RecoverOptions{Verify: true}); err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	for id := int64(1); id <= 20; id++ {
+		doc, found, err := se.Get("t", "id", types.IntKey(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, ok := want[id]
+		if found != ok || (ok && !strings.Contains(doc, fmt.Sprintf("%q", v))) {
+			t.Fatalf("Get(%d) = %s, %v; want v=%q, %v", id, doc, found, v, ok)
+		}
+		if ok {
+			docs, err := se.Scan("t", "v", query.Equal(types.VarcharKey(v)))
+			if err != nil || len(docs) != 1 {
+				t.Fatalf("Scan(v=%q) = %v, %v; want one row", v, docs, err)
+			}
+		}
+	}
+}
+
+// TestReplayVerifier_ReportsStaleVersion feeds the verifier a write newer
+// than the one the tree points at; deletes already applied and writes
+// without the primary key pass.
+func TestReplayVerifier_ReportsStaleVersion(t *testing.T) {
+	dir := t.TempDir()
+	se, ww := openReplayEngine(t, dir)
+	defer ww.Close()
+	if err := se.UpsertRow("t", `{"id": 1, "v": "x"}`, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := se.UpsertRow("t", `{"id": 2, "v": "y"}`, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := se.DeleteRow("t", types.IntKey(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	rv := storage.NewReplayVerifier()
+	rv.Observe(storage.LogicalRecord{LSN: 999, Op: storage.LogicalDelete, Table: "t", Keys: map[string]types.Comparable{"id": types.IntKey(2)}})
+	rv.Observe(storage.LogicalRecord{LSN: 999, Op: storage.LogicalPut, Table: "t", Keys: map[string]types.Comparable{"v": types.VarcharKey("q")}})
+	if err := rv.Verify(se); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	rv.Observe(storage.LogicalRecord{LSN: 1000, Op: storage.LogicalPut, Table: "t", Keys: map[string]types.Comparable{"id": types.IntKey(1), "v": types.VarcharKey("z")}})
+	err := rv.Verify(se)
+	var replayErr *storage.ReplayError
+	if !errors.As(err, &replayErr) {
+		t.Fatalf("Verify = %v, want a *ReplayError", err)
+	}
+	if len(replayErr.Mismatches) != 1 {
+		t.Fatalf("mismatches = %v, want one", replayErr.Mismatches)
+	}
+	if m := replayErr.Mismatches[0]; m.Key.Compare(types.IntKey(1)) != 0 || m.WantLSN != 1000 || m.GotLSN == 0 || m.GotLSN >= 1000 {
+		t.Fatalf("mismatch = %+v", m)
+	}
+}
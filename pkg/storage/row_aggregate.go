@@ -0,0 +1,64 @@
+package storage
+
+import (
+	goerrors "errors"
+	"fmt"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// Aggregate is Transaction.Aggregate on a snapshot taken for the call.
+func (se *StorageEngine) Aggregate(tableName string, indexName string, condition *query.ScanCondition, spec query.AggSpec) (query.AggResult, error) {
+	tx := se.BeginRead()
+	defer tx.Close()
+	return tx.Aggregate(tableName, indexName, condition, spec)
+}
+
+// Aggregate computes spec over the rows visible to tx that match condition
+// on the index.
+//
+// Aggregates of the index key never decode a document: MIN and MAX stop
+// at the first visible row from either end of the matching range, and
+// COUNT, SUM and AVG only check record headers for visibility. Aggregates
+// of a document field (spec.Field) read every matching row.
+func (tx *Transaction) Aggregate(tableName string, indexName string, condition *query.ScanCondition, spec query.AggSpec) (query.AggResult, error) {
+	agg := query.NewAggregator(spec)
+	firstOnly := spec.Field == "" && (spec.Func == query.AggMin || spec.Func == query.AggMax)
+	reverse := firstOnly && spec.Func == query.AggMax
+
+	err := tx.walkVisibleRows(tableName, indexName, condition, reverse, spec.Field != "", func(key types.Comparable, raw []byte) error {
+		value := key
+		if spec.Field != "" {
+			v, ok, err := documentField(raw, spec.Field)
+			if err != nil || !ok {
+				return err
+			}
+			value = v
+		}
+		if err := agg.Add(value); err != nil {
+			return err
+		}
+		if firstOnly {
+			return errStopVisibleWalk
+		}
+		return nil
+	})
+	if err != nil && !goerrors.Is(err, errStopVisibleWalk) {
+		return query.AggResult{}, err
+	}
+	return agg.Result(), nil
+}
+
+// documentField reads a top-level field of a stored document.
+func documentField(raw []byte, name string) (types.Comparable, bool, error) {
+	doc, err := storedDocumentBson(raw)
+	if err != nil {
+		return nil, false, fmt.Errorf("storage: aggregate: %w", err)
+	}
+	value, err := GetValueFromBson(doc, name)
+	if err != nil {
+		return nil, false, nil
+	}
+	return value, true, nil
+}
@@ -0,0 +1,81 @@
+package storage_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+func putSalary(t *testing.T, se *storage.StorageEngine, id int64, dept string, salary int) {
+	t.Helper()
+	doc := fmt.Sprintf(`{"id": %d, "department": "%s", "salary": %d}`, id, dept, salary)
+	if err := se.UpsertRow("employees", doc, nil); err != nil {
+		t.Fatalf("UpsertRow %d: %v", id, err)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+
+	putSalary(t, se, 1, "Eng", 100)
+	putSalary(t, se, 2, "Eng", 200)
+	putSalary(t, se, 3, "Sales", 50)
+	putSalary(t, se, 4, "Eng", 600)
+	putSalary(t, se, 5, "Ops", 10)
+
+	snapshot := se.BeginRead()
+	defer snapshot.Close()
+	if _, err := se.DeleteRow("employees", types.IntKey(5)); err != nil {
+		t.Fatalf("DeleteRow: %v", err)
+	}
+	if _, err := se.DeleteRow("employees", types.IntKey(1)); err != nil {
+		t.Fatalf("DeleteRow: %v", err)
+	}
+
+	eng := query.Equal(types.VarcharKey("Eng"))
+	cases := []struct {
+		name  string
+		index string
+		cond  *query.ScanCondition
+		spec  query.AggSpec
+		want  types.Comparable
+	}{
+		{"min id", "id", nil, query.Min(), types.IntKey(2)},
+		{"max id", "id", nil, query.Max(), types.IntKey(4)},
+		{"max id below 4", "id", query.LessThan(types.IntKey(4)), query.Max(), types.IntKey(3)},
+		{"count", "id", nil, query.Count(), types.IntKey(3)},
+		{"sum id", "id", nil, query.Sum(), types.IntKey(9)},
+		{"max department", "department", nil, query.Max(), types.VarcharKey("Sales")},
+		{"eng salary sum", "department", eng, query.Sum().OfField("salary"), types.IntKey(800)},
+		{"eng salary avg", "department", eng, query.Avg().OfField("salary"), types.FloatKey(400)},
+		{"min salary", "id", nil, query.Min().OfField("salary"), types.IntKey(50)},
+	}
+	for _, tc := range cases {
+		got, err := se.Aggregate("employees", tc.index, tc.cond, tc.spec)
+		if err != nil {
+			t.Fatalf("%s: Aggregate: %v", tc.name, err)
+		}
+		if got.Value != tc.want {
+			t.Fatalf("%s: expected %v, got %v", tc.name, tc.want, got.Value)
+		}
+	}
+
+	// The older snapshot still sees rows 1 and 5.
+	got, err := snapshot.Aggregate("employees", "id", nil, query.Min())
+	if err != nil || got.Value != types.IntKey(1) {
+		t.Fatalf("snapshot MIN: got %v err=%v", got.Value, err)
+	}
+	got, err = snapshot.Aggregate("employees", "id", nil, query.Min().OfField("salary"))
+	if err != nil || got.Value != types.IntKey(10) {
+		t.Fatalf("snapshot MIN salary: got %v err=%v", got.Value, err)
+	}
+
+	empty, err := se.Aggregate("employees", "department", query.Equal(types.VarcharKey("HR")), query.Avg())
+	if err != nil || empty.Value != nil || empty.Count != 0 {
+		t.Fatalf("empty AVG: got %+v err=%v", empty, err)
+	}
+}
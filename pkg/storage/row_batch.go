@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+// Row is one row of a batch write. Keys is optional, as in InsertRow: when
+// given it must match the document fields.
+type Row struct {
+	Document string
+	Keys     map[string]types.Comparable
+}
+
+// BatchOptions tunes InsertRows and UpsertRows. Sorted applies the rows in
+// primary key order, so consecutive index inserts land in the same leaves
+// instead of splitting pages all over the tree.
+type BatchOptions struct {
+	Sorted bool
+}
+
+type batchRow struct {
+	bsonData   []byte
+	keys       map[string]types.Comparable
+	primaryKey types.Comparable
+}
+
+// InsertRows inserts every row in one atomic write. The whole batch is
+// logged as a single EntryMultiBatch record and every row takes its LSN,
+// so a snapshot sees either all of the rows or none. Nothing is written
+// when a primary key repeats within the batch or already exists.
+func (se *StorageEngine) InsertRows(tableName string, rows []Row, opts ...BatchOptions) error {
+	return se.writeRowBatch(tableName, rows, rowInsert, batchOptions(opts))
+}
+
+// UpsertRows is InsertRows that replaces the rows whose primary key
+// already exists.
+func (se *StorageEngine) UpsertRows(tableName string, rows []Row, opts ...BatchOptions) error {
+	return se.writeRowBatch(tableName, rows, rowUpsert, batchOptions(opts))
+}
+
+// batchOptions returns the BatchOptions of a batch call; the last one
+// given wins.
+func batchOptions(opts []BatchOptions) BatchOptions {
+	var batch BatchOptions
+	for _, opt := range opts {
+		batch = opt
+	}
+	return batch
+}
+
+func (se *StorageEngine) writeRowBatch(tableName string, rows []Row, mode rowWriteMode, opts BatchOptions) error {
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return err
+	}
+
+	batch := make([]batchRow, len(rows))
+	lockSet := make(map[string]struct{})
+	for i, row := range rows {
+		bsonData, keys, err := prepareRowDocument(table, row.Document, row.Keys)
+		if err != nil {
+			return fmt.Errorf("batch row %d: %w", i, err)
+		}
+		_, primaryKey, err := primaryIndexAndKey(table, keys)
+		if err != nil {
+			return err
+		}
+		batch[i] = batchRow{bsonData: bsonData, keys: keys, primaryKey: primaryKey}
+
+		resources, err := lockResourcesForKeys(tableName, keys)
+		if err != nil {
+			return err
+		}
+		for _, resource := range resources {
+			lockSet[resource] = struct{}{}
+		}
+	}
+
+	// Sorting by primary key finds repeated keys and gives the Sorted
+	// apply order.
+	sorted := make([]int, len(batch))
+	for i := range sorted {
+		sorted[i] = i
+	}
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return batch[sorted[a]].primaryKey.Compare(batch[sorted[b]].primaryKey) < 0
+	})
+	for i := 1; i < len(sorted); i++ {
+		if batch[sorted[i-1]].primaryKey.Compare(batch[sorted[i]].primaryKey) == 0 {
+			return fmt.Errorf("storage: batch repeats primary key %v", batch[sorted[i]].primaryKey)
+		}
+	}
+	if opts.Sorted {
+		ordered := make([]batchRow, len(batch))
+		for i, idx := range sorted {
+			ordered[i] = batch[idx]
+		}
+		batch = ordered
+	}
+
+	resources := make([]string, 0, len(lockSet))
+	for resource := range lockSet {
+		resources = append(resources, resource)
+	}
+	slices.Sort(resources)
+
+	return se.withAutoCommitLocks(resources, func() error {
+		table.Lock()
+		defer table.Unlock()
+
+		if mode == rowInsert {
+			primary, _, err := primaryIndexAndKey(table, batch[0].keys)
+			if err != nil {
+				return err
+			}
+			for _, row := range batch {
+				offset, exists, err := primary.Tree.Get(row.primaryKey)
+				if err != nil {
+					return fmt.Errorf("primary index get failed: %w", err)
+				}
+				live, err := isLiveRecord(table, offset, exists)
+				if err != nil {
+					return err
+				}
+				if live {
+					return fmt.Errorf("duplicate key error: key %v already exists in index %s", row.primaryKey, primary.Name)
+				}
+			}
+		}
+
+		currentLSN := se.lsnTracker.Reserve()
+		defer se.lsnTracker.Release(currentLSN)
+		if se.WAL != nil {
+			payloads := make([][]byte, len(batch))
+			for i, row := range batch {
+				payload, err := SerializeMultiIndexEntry(tableName, row.keys, row.bsonData)
+				if err != nil {
+					return err
+				}
+				payloads[i] = payload
+			}
+			if err := se.writeAutoCommitWAL(wal.EntryMultiBatch, SerializeBatchEntry(payloads), currentLSN); err != nil {
+				return err
+			}
+		}
+
+		for i, row := range batch {
+			if err := se.applyRowVersion(context.Background(), table, row.keys, row.bsonData, currentLSN, nil); err != nil {
+				// Part of a logged batch is applied: stop writes until
+				// recovery replays it.
+				applyErr := fmt.Errorf("batch apply failed for %s at row %d/%d: %w", tableName, i+1, len(batch), err)
+				se.markDegraded(applyErr)
+				return applyErr
+			}
+		}
+		return nil
+	})
+}
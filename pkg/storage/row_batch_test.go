@@ -0,0 +1,117 @@
+package storage_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+func employeeRows(dept string, ids ...int64) []storage.Row {
+	rows := make([]storage.Row, len(ids))
+	for i, id := range ids {
+		rows[i] = storage.Row{Document: fmt.Sprintf(`{"id": %d, "department": "%s"}`, id, dept)}
+	}
+	return rows
+}
+
+func TestInsertRows_SingleWALEntry(t *testing.T) {
+	dir := t.TempDir()
+	se := openEmployeesEngine(t, dir)
+
+	if err := se.InsertRows("employees", employeeRows("Engineering", 3, 1, 2), storage.BatchOptions{Sorted: true}); err != nil {
+		t.Fatalf("InsertRows failed: %v", err)
+	}
+	docs, err := se.Scan("employees", "department", query.Equal(types.VarcharKey("Engineering")))
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	assertIDs(t, "Engineering", docs, "1", "2", "3")
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reader, err := wal.NewWALReader(filepath.Join(dir, "wal.log"))
+	if err != nil {
+		t.Fatalf("Failed to open WAL: %v", err)
+	}
+	defer reader.Close()
+
+	var entryTypes []uint8
+	for {
+		entry, err := reader.ReadEntry()
+		if err != nil {
+			break
+		}
+		if entry.Header.EntryType != wal.EntryPageRedo && entry.Header.EntryType != wal.EntryClock {
+			entryTypes = append(entryTypes, entry.Header.EntryType)
+		}
+		wal.ReleaseEntry(entry)
+	}
+	if len(entryTypes) != 1 || entryTypes[0] != wal.EntryMultiBatch {
+		t.Fatalf("expected [MultiBatch] in the WAL, got %v", entryTypes)
+	}
+}
+
+func TestInsertRows_AllOrNothing(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+	putEmployee(t, se, 2, "Sales")
+
+	if err := se.InsertRows("employees", employeeRows("Engineering", 1, 2)); err == nil {
+		t.Fatal("expected a duplicate key error for an existing row")
+	}
+	if err := se.InsertRows("employees", employeeRows("Engineering", 4, 5, 4)); err == nil {
+		t.Fatal("expected an error for a key repeated within the batch")
+	}
+	docs, _ := se.Scan("employees", "id", nil)
+	assertIDs(t, "after failed batches", docs, "2")
+
+	if err := se.UpsertRows("employees", employeeRows("Engineering", 1, 2)); err != nil {
+		t.Fatalf("UpsertRows failed: %v", err)
+	}
+	docs, _ = se.GetAll("employees", "department", types.VarcharKey("Engineering"))
+	assertIDs(t, "Engineering after UpsertRows", docs, "1", "2")
+	docs, _ = se.GetAll("employees", "department", types.VarcharKey("Sales"))
+	assertIDs(t, "Sales after UpsertRows", docs)
+}
+
+func TestInsertRows_Recovery(t *testing.T) {
+	dir := t.TempDir()
+	se := openEmployeesEngine(t, dir)
+	if err := se.InsertRows("employees", employeeRows("Engineering", 1, 2, 3)); err != nil {
+		t.Fatalf("InsertRows failed: %v", err)
+	}
+	if err := se.UpsertRows("employees", employeeRows("Sales", 3, 4)); err != nil {
+		t.Fatalf("UpsertRows failed: %v", err)
+	}
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Only the WAL survives: every row must come back from the batches.
+	replayDir := t.TempDir()
+	walData, err := os.ReadFile(filepath.Join(dir, "wal.log"))
+	if err != nil {
+		t.Fatalf("read WAL: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(replayDir, "wal.log"), walData, 0o644); err != nil {
+		t.Fatalf("copy WAL: %v", err)
+	}
+
+	se2 := openEmployeesEngine(t, replayDir)
+	defer se2.Close()
+	if err := se2.Recover(filepath.Join(replayDir, "wal.log")); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+
+	docs, _ := se2.GetAll("employees", "department", types.VarcharKey("Engineering"))
+	assertIDs(t, "recovered Engineering", docs, "1", "2")
+	docs, _ = se2.GetAll("employees", "department", types.VarcharKey("Sales"))
+	assertIDs(t, "recovered Sales", docs, "3", "4")
+}
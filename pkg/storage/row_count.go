@@ -0,0 +1,160 @@
+package storage
+
+import (
+	goerrors "errors"
+	"fmt"
+
+	"github.com/bobboyms/storage-engine/pkg/heap"
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// headerReader is implemented by heaps that can read a record header
+// without copying the document.
+type headerReader interface {
+	ReadHeader(recordID int64) (*heap.RecordHeader, error)
+}
+
+var errStopVisibleWalk = goerrors.New("storage: stop visible walk")
+
+// Count is Transaction.Count on a snapshot taken for the call.
+func (se *StorageEngine) Count(tableName string, indexName string, condition *query.ScanCondition) (int, error) {
+	tx := se.BeginRead()
+	defer tx.Close()
+	return tx.Count(tableName, indexName, condition)
+}
+
+// Exists is Transaction.Exists on a snapshot taken for the call.
+func (se *StorageEngine) Exists(tableName string, indexName string, key types.Comparable) (bool, error) {
+	tx := se.BeginRead()
+	defer tx.Close()
+	return tx.Exists(tableName, indexName, key)
+}
+
+// Count returns how many rows visible to tx match condition on the index.
+// Only the index and the record headers are read; documents are decoded
+// only when the condition filters document fields.
+func (tx *Transaction) Count(tableName string, indexName string, condition *query.ScanCondition) (int, error) {
+	count := 0
+	err := tx.walkVisibleRows(tableName, indexName, condition, false, false, func(types.Comparable, []byte) error {
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// Exists reports whether a row visible to tx has key on the index. It stops
+// at the first visible row and never decodes a document.
+func (tx *Transaction) Exists(tableName string, indexName string, key types.Comparable) (bool, error) {
+	found := false
+	err := tx.walkVisibleRows(tableName, indexName, query.Equal(key), false, false, func(types.Comparable, []byte) error {
+		found = true
+		return errStopVisibleWalk
+	})
+	if goerrors.Is(err, errStopVisibleWalk) {
+		err = nil
+	}
+	return found, err
+}
+
+// walkVisibleRows calls fn with the index key of every row visible to tx
+// that matches condition, in index order (descending when reverse). The
+// stored document is read and passed only when withDocument is set or the
+// condition filters document fields; otherwise raw is nil.
+func (tx *Transaction) walkVisibleRows(tableName string, indexName string, condition *query.ScanCondition, reverse, withDocument bool, fn func(key types.Comparable, raw []byte) error) error {
+	se := tx.engine
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return err
+	}
+	tx.refreshSnapshot()
+
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return err
+	}
+	index, err := table.GetIndex(indexName)
+	if err != nil {
+		return err
+	}
+	if condition != nil && condition.Field == "" && condition.Value != nil {
+		if err := validateKeyForIndex(index, condition.Value); err != nil {
+			return err
+		}
+	}
+	scanner, ok := index.Tree.(rangeScanner)
+	if !ok {
+		return fmt.Errorf("storage: index %s uses unsupported type %T", indexName, index.Tree)
+	}
+	condition = collateCondition(index, condition)
+
+	multiValue := index.IsMultiValue()
+	filterDocument := condition != nil && condition.NeedsDocument()
+	seen := multikeySeen(index)
+	return scanIndexRange(index, scanner, condition, reverse, func(key types.Comparable, recordID int64) error {
+		if condition != nil && !condition.Matches(key) {
+			return nil
+		}
+		if seen != nil && seen(recordID) {
+			return nil
+		}
+
+		if withDocument || filterDocument {
+			var record visibleRecord
+			var err error
+			if multiValue {
+				record, err = se.readVisiblePosting(tx, table, key, recordID)
+			} else {
+				record, err = se.readVisibleRecord(tx, table, key, recordID)
+			}
+			if err != nil || !record.Found {
+				return err
+			}
+			if filterDocument {
+				matches, err := matchesDocument(condition, key, record.Raw)
+				if err != nil || !matches {
+					return err
+				}
+			}
+			return fn(key, record.Raw)
+		}
+
+		visible, err := rowVisible(tx, table, recordID, multiValue)
+		if err != nil || !visible {
+			return err
+		}
+		return fn(key, nil)
+	})
+}
+
+// rowVisible reports whether tx sees a row through recordID, reading only
+// record headers. A posting names a single version; a primary entry heads
+// a version chain that is followed back to the version tx sees.
+func rowVisible(tx *Transaction, table *Table, recordID int64, posting bool) (bool, error) {
+	for recordID != -1 {
+		header, err := readRecordHeader(table.Heap, recordID)
+		if isChainEndErr(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("heap read failed: %w", err)
+		}
+		if tx.IsVisible(header.CreateLSN) {
+			return header.Valid || header.DeleteLSN > tx.SnapshotLSN, nil
+		}
+		if posting {
+			return false, nil
+		}
+		recordID = header.PrevRecordID
+	}
+	return false, nil
+}
+
+func readRecordHeader(h heap.Heap, recordID int64) (*heap.RecordHeader, error) {
+	if reader, ok := h.(headerReader); ok {
+		return reader.ReadHeader(recordID)
+	}
+	_, header, err := h.Read(recordID)
+	return header, err
+}
@@ -0,0 +1,86 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+func TestCount_HonoursSnapshots(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+
+	putEmployee(t, se, 1, "Engineering")
+	putEmployee(t, se, 2, "Engineering")
+	putEmployee(t, se, 3, "Sales")
+	putEmployee(t, se, 2, "Sales") // moves row 2, leaving an old posting behind
+
+	snapshot := se.BeginRead()
+	defer snapshot.Close()
+	if _, err := se.DeleteRow("employees", types.IntKey(1)); err != nil {
+		t.Fatalf("DeleteRow: %v", err)
+	}
+
+	cases := []struct {
+		name  string
+		index string
+		cond  *query.ScanCondition
+		want  int
+	}{
+		{"all rows", "id", nil, 2},
+		{"primary range", "id", query.GreaterOrEqual(types.IntKey(2)), 2},
+		{"Engineering", "department", query.Equal(types.VarcharKey("Engineering")), 0},
+		{"Sales", "department", query.Equal(types.VarcharKey("Sales")), 2},
+	}
+	for _, tc := range cases {
+		got, err := se.Count("employees", tc.index, tc.cond)
+		if err != nil {
+			t.Fatalf("%s: Count: %v", tc.name, err)
+		}
+		if got != tc.want {
+			t.Fatalf("%s: expected %d, got %d", tc.name, tc.want, got)
+		}
+	}
+
+	got, err := snapshot.Count("employees", "department", query.Equal(types.VarcharKey("Engineering")))
+	if err != nil || got != 1 {
+		t.Fatalf("snapshot Count Engineering: got %d err=%v, expected 1", got, err)
+	}
+	got, err = snapshot.Count("employees", "id", nil)
+	if err != nil || got != 3 {
+		t.Fatalf("snapshot Count all: got %d err=%v, expected 3", got, err)
+	}
+}
+
+func TestExists(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+
+	putEmployee(t, se, 1, "Engineering")
+	putEmployee(t, se, 1, "Sales")
+
+	checks := []struct {
+		index string
+		key   types.Comparable
+		want  bool
+	}{
+		{"id", types.IntKey(1), true},
+		{"id", types.IntKey(2), false},
+		{"department", types.VarcharKey("Sales"), true},
+		{"department", types.VarcharKey("Engineering"), false},
+	}
+	for _, c := range checks {
+		got, err := se.Exists("employees", c.index, c.key)
+		if err != nil {
+			t.Fatalf("Exists(%s, %v): %v", c.index, c.key, err)
+		}
+		if got != c.want {
+			t.Fatalf("Exists(%s, %v) = %v, expected %v", c.index, c.key, got, c.want)
+		}
+	}
+
+	if _, err := se.Exists("employees", "id", types.VarcharKey("1")); err == nil {
+		t.Fatal("expected an error for a key of the wrong type")
+	}
+}
@@ -0,0 +1,209 @@
+package storage
+
+import (
+	"fmt"
+
+	btreev2 "github.com/bobboyms/storage-engine/pkg/btree/v2"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// indexCursor is the cursor of an index tree: btreev2.Cursor,
+// btreev2.PartitionedCursor or btreev2.PostingCursor.
+type indexCursor interface {
+	Valid() bool
+	Key() types.Comparable
+	Value() int64
+	SeekFirst() error
+	Seek(key types.Comparable) error
+	SeekLast() error
+	SeekForPrev(key types.Comparable) error
+	Next() error
+	Prev() error
+}
+
+func newIndexCursor(index *Index) (indexCursor, error) {
+	switch tree := index.Tree.(type) {
+	case *btreev2.BTreeV2:
+		return tree.NewCursor(), nil
+	case *btreev2.PartitionedTree:
+		return tree.NewCursor(), nil
+	case *btreev2.PostingTree:
+		return tree.NewCursor(), nil
+	}
+	return nil, fmt.Errorf("storage: index %s of type %T has no cursor", index.Name, index.Tree)
+}
+
+// RowCursor walks the rows of a table in the order of one index, a row at
+// a time, as the snapshot it was opened with sees them.
+//
+// The index cursor underneath reads the live tree: it holds no latch
+// between moves, so it meets entries written after the snapshot. Each
+// entry is resolved through the row's version chain, and entries with no
+// version visible at SnapshotLSN (rows created later, or deleted before)
+// are stepped over, so walking the same range twice returns the same
+// rows even while writers insert into it.
+//
+// The snapshot is pinned for the life of the cursor, in ReadCommitted
+// too, and keeps Vacuum from freeing the versions it needs until Close.
+// On a multikey index a row comes once per element of the array, under
+// each element's key. A RowCursor is not safe for concurrent use.
+type RowCursor struct {
+	se         *StorageEngine
+	view       *Transaction
+	table      *Table
+	index      *Index
+	cur        indexCursor
+	multiValue bool
+	closed     bool
+
+	record visibleRecord
+}
+
+// OpenCursor opens a RowCursor on tableName ordered by indexName at the
+// transaction's snapshot. The cursor starts unpositioned; call one of the
+// Seek methods before reading it, and Close it when done.
+//
+// Unlike a cursor on Index.Tree, whose values are heap offsets, it reads
+// the heap itself: Value is the document of the version the snapshot
+// sees.
+func (tx *Transaction) OpenCursor(tableName string, indexName string) (*RowCursor, error) {
+	se := tx.engine
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return nil, err
+	}
+	tx.refreshSnapshot()
+
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return nil, err
+	}
+	index, err := table.GetIndex(indexName)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := newIndexCursor(index)
+	if err != nil {
+		return nil, err
+	}
+
+	view := &Transaction{SnapshotLSN: tx.SnapshotLSN, Level: RepeatableRead, engine: se}
+	se.TxRegistry.Register(view)
+	return &RowCursor{
+		se:         se,
+		view:       view,
+		table:      table,
+		index:      index,
+		cur:        cur,
+		multiValue: index.IsMultiValue(),
+	}, nil
+}
+
+// SnapshotLSN returns the LSN of the snapshot the cursor reads.
+func (c *RowCursor) SnapshotLSN() uint64 { return c.view.SnapshotLSN }
+
+// Valid reports whether the cursor is positioned on a row.
+func (c *RowCursor) Valid() bool { return !c.closed && c.cur.Valid() && c.record.Found }
+
+// Key returns the index key of the row under the cursor; on an index with
+// a collation, the collation key. Only meaningful when Valid.
+func (c *RowCursor) Key() types.Comparable { return c.cur.Key() }
+
+// Value returns the row under the cursor as JSON. Only meaningful when
+// Valid.
+func (c *RowCursor) Value() string { return c.record.Document() }
+
+// SeekFirst positions the cursor on the first visible row.
+func (c *RowCursor) SeekFirst() error {
+	return c.move(c.cur.SeekFirst, true)
+}
+
+// Seek positions the cursor on the first visible row whose key is >= key.
+func (c *RowCursor) Seek(key types.Comparable) error {
+	key = c.collate(key)
+	return c.move(func() error { return c.cur.Seek(key) }, true)
+}
+
+// SeekLast positions the cursor on the last visible row.
+func (c *RowCursor) SeekLast() error {
+	return c.move(c.cur.SeekLast, false)
+}
+
+// SeekForPrev positions the cursor on the last visible row whose key is
+// <= key.
+func (c *RowCursor) SeekForPrev(key types.Comparable) error {
+	key = c.collate(key)
+	return c.move(func() error { return c.cur.SeekForPrev(key) }, false)
+}
+
+// Next moves to the following visible row. Past the last one the cursor
+// becomes invalid; Next on an invalid cursor does nothing.
+func (c *RowCursor) Next() error {
+	if !c.Valid() {
+		return nil
+	}
+	return c.move(c.cur.Next, true)
+}
+
+// Prev moves to the preceding visible row. Before the first one the
+// cursor becomes invalid; Prev on an invalid cursor does nothing.
+func (c *RowCursor) Prev() error {
+	if !c.Valid() {
+		return nil
+	}
+	return c.move(c.cur.Prev, false)
+}
+
+// Close releases the snapshot. The cursor is invalid afterwards.
+func (c *RowCursor) Close() {
+	if c.closed {
+		return
+	}
+	c.closed = true
+	c.se.TxRegistry.Unregister(c.view)
+}
+
+// move runs step on the index cursor and then keeps stepping in the
+// given direction past the entries the snapshot does not see.
+func (c *RowCursor) move(step func() error, forward bool) error {
+	c.record = visibleRecord{}
+	if c.closed {
+		return fmt.Errorf("storage: cursor on %s is closed", c.table.Name)
+	}
+	c.se.opMu.RLock()
+	defer c.se.opMu.RUnlock()
+	if err := c.se.runtimeReadyError(); err != nil {
+		return err
+	}
+
+	if err := step(); err != nil {
+		return err
+	}
+	for c.cur.Valid() {
+		record, err := c.se.readVisibleEntry(c.view, c.table, c.multiValue, c.cur.Key(), c.cur.Value())
+		if err != nil {
+			return err
+		}
+		if record.Found {
+			c.record = record
+			return nil
+		}
+		if forward {
+			err = c.cur.Next()
+		} else {
+			err = c.cur.Prev()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *RowCursor) collate(key types.Comparable) types.Comparable {
+	if c.index.Collation == types.CollationBinary {
+		return key
+	}
+	return c.index.Collation.Apply(key)
+}
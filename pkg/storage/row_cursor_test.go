@@ -0,0 +1,225 @@
+package storage_test
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// walkCursor returns the documents from the cursor's position onwards.
+func walkCursor(t *testing.T, cur *storage.RowCursor, forward bool) []string {
+	t.Helper()
+	var docs []string
+	for cur.Valid() {
+		docs = append(docs, cur.Value())
+		var err error
+		if forward {
+			err = cur.Next()
+		} else {
+			err = cur.Prev()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return docs
+}
+
+// TestRowCursor_RepeatableAcrossWrites: rows inserted, updated and deleted
+// after the snapshot do not change what a cursor walk returns, in either
+// direction and on the primary as on a secondary index.
+func TestRowCursor_RepeatableAcrossWrites(t *testing.T) {
+	se, err := storage.Open(filepath.Join(t.TempDir(), "data"), storage.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer se.Close()
+	if err := se.CreateTable("t", []storage.Index{
+		{Name: "id", Primary: true, Type: storage.TypeInt},
+		{Name: "v", Type: storage.TypeInt},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for id := 0; id < 20; id += 2 {
+		if err := se.InsertRow("t", fmt.Sprintf(`{"id": %d, "v": %d}`, id, id), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tx := se.BeginRead()
+	defer tx.Close()
+	cursors := make(map[string]*storage.RowCursor)
+	for _, index := range []string{"id", "v"} {
+		cur, err := tx.OpenCursor("t", index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cur.Close()
+		cursors[index] = cur
+	}
+
+	check := func() {
+		t.Helper()
+		for index, cur := range cursors {
+			if err := cur.Seek(types.IntKey(5)); err != nil {
+				t.Fatal(err)
+			}
+			forward := walkCursor(t, cur, true)
+			if err := cur.SeekForPrev(types.IntKey(100)); err != nil {
+				t.Fatal(err)
+			}
+			backward := walkCursor(t, cur, false)
+			if len(forward) != 7 || len(backward) != 10 {
+				t.Fatalf("%s: forward %v, backward %v", index, forward, backward)
+			}
+			for i, doc := range forward {
+				if want := fmt.Sprintf(`"v":%d`, 6+2*i); !strings.Contains(doc, want) {
+					t.Fatalf("%s: forward[%d] = %s, want %s", index, i, doc, want)
+				}
+			}
+			if !strings.Contains(backward[0], `"v":18`) || !strings.Contains(backward[9], `"v":0`) {
+				t.Fatalf("%s: backward = %v", index, backward)
+			}
+		}
+	}
+	check()
+
+	for id := 1; id < 40; id += 2 {
+		if err := se.InsertRow("t", fmt.Sprintf(`{"id": %d, "v": %d}`, id, id), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := se.UpsertRow("t", `{"id": 8, "v": 1000}`, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := se.DeleteRow("t", types.IntKey(12)); err != nil {
+		t.Fatal(err)
+	}
+	check()
+
+	fresh := se.BeginRead()
+	defer fresh.Close()
+	cur, err := fresh.OpenCursor("t", "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cur.Close()
+	if err := cur.SeekFirst(); err != nil {
+		t.Fatal(err)
+	}
+	if docs := walkCursor(t, cur, true); len(docs) != 29 {
+		t.Fatalf("new snapshot sees %d rows, want 29", len(docs))
+	}
+}
+
+// TestRowCursor_PinsSnapshot: in ReadCommitted a cursor keeps the snapshot
+// it was opened with, and holds it in the registry until Close.
+func TestRowCursor_PinsSnapshot(t *testing.T) {
+	se, err := storage.Open(filepath.Join(t.TempDir(), "data"), storage.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer se.Close()
+	if err := se.CreateTable("t", []storage.Index{{Name: "id", Primary: true, Type: storage.TypeInt}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := se.InsertRow("t", `{"id": 1}`, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := se.BeginTransaction(storage.ReadCommitted)
+	tx.Close()
+	cur, err := tx.OpenCursor("t", "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := se.TxRegistry.GetMinActiveLSN(); got != cur.SnapshotLSN() {
+		t.Fatalf("min active LSN = %d, want the cursor snapshot %d", got, cur.SnapshotLSN())
+	}
+	if err := se.InsertRow("t", `{"id": 2}`, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := cur.SeekFirst(); err != nil {
+		t.Fatal(err)
+	}
+	if docs := walkCursor(t, cur, true); len(docs) != 1 {
+		t.Fatalf("cursor sees %v, want only the row before its snapshot", docs)
+	}
+
+	cur.Close()
+	if got := se.TxRegistry.GetMinActiveLSN(); got != math.MaxUint64 {
+		t.Fatalf("min active LSN after Close = %d", got)
+	}
+	if cur.SeekFirst() == nil || cur.Valid() {
+		t.Fatal("a closed cursor should fail to seek")
+	}
+}
+
+// TestRowCursor_PagesByValue: a page reader keeps one cursor and reads
+// documents straight from Value, the version its snapshot sees, not a
+// heap offset to resolve.
+func TestRowCursor_PagesByValue(t *testing.T) {
+	se, err := storage.Open(filepath.Join(t.TempDir(), "data"), storage.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer se.Close()
+	if err := se.CreateTable("t", []storage.Index{{Name: "id", Primary: true, Type: storage.TypeInt}}); err != nil {
+		t.Fatal(err)
+	}
+	for id := 0; id < 25; id++ {
+		if err := se.InsertRow("t", fmt.Sprintf(`{"id": %d, "rev": 1}`, id), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tx := se.BeginRead()
+	defer tx.Close()
+	cur, err := tx.OpenCursor("t", "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cur.Close()
+	if _, err := tx.OpenCursor("t", "missing"); err == nil {
+		t.Fatal("OpenCursor on an unknown index should fail")
+	}
+
+	const pageSize = 10
+	var pages [][]string
+	for err := cur.SeekFirst(); cur.Valid(); {
+		if err != nil {
+			t.Fatal(err)
+		}
+		var page []string
+		for ; cur.Valid() && len(page) < pageSize; err = cur.Next() {
+			if err != nil {
+				t.Fatal(err)
+			}
+			page = append(page, cur.Value())
+		}
+		pages = append(pages, page)
+		// Writes between pages, after the snapshot.
+		if err := se.UpsertRow("t", fmt.Sprintf(`{"id": %d, "rev": 2}`, 24-len(pages)), nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := se.DeleteRow("t", types.IntKey(int64(len(pages)*pageSize))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(pages) != 3 || len(pages[2]) != 5 {
+		t.Fatalf("pages = %d (last %d rows), want 10+10+5", len(pages), len(pages[len(pages)-1]))
+	}
+	for _, page := range pages {
+		for _, doc := range page {
+			if !strings.Contains(doc, `"rev":1`) {
+				t.Fatalf("cursor returned %s, a version newer than its snapshot", doc)
+			}
+		}
+	}
+}
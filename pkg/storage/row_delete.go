@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+// DeleteRow deletes the row stored under primaryKey from every index of
+// the table. The indexed keys are extracted from the stored document and
+// logged together in a single EntryMultiDelete, so recovery removes the
+// row from all indexes or from none. Returns false when there is no live
+// row under the key.
+//
+// Every index entry of a row points to the same heap version, so a single
+// tombstone hides the row from all of them while keeping it reachable for
+// older snapshots.
+func (se *StorageEngine) DeleteRow(tableName string, primaryKey types.Comparable) (bool, error) {
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return false, err
+	}
+
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return false, err
+	}
+	primary, err := primaryIndex(table)
+	if err != nil {
+		return false, err
+	}
+	if err := validateKeyForIndex(primary, primaryKey); err != nil {
+		return false, err
+	}
+
+	resource, err := lockResourceForKey(tableName, primary.Name, primaryKey)
+	if err != nil {
+		return false, err
+	}
+
+	var deleted bool
+	var triggers *rowTriggers
+	err = se.withAutoCommitLocks([]string{resource}, func() error {
+		table.Lock()
+		defer table.Unlock()
+
+		head, found, err := primary.Tree.Get(primaryKey)
+		if err != nil {
+			return fmt.Errorf("primary index get failed: %w", err)
+		}
+		live, err := isLiveRecord(table, head, found)
+		if err != nil || !live {
+			return err
+		}
+
+		keys, err := rowKeysAt(table, primary, primaryKey, head)
+		if err != nil {
+			return err
+		}
+		if triggers, err = se.newRowTriggers(table, primaryKey, head, true, nil, true); err != nil {
+			return err
+		}
+		if err := triggers.before(); err != nil {
+			return err
+		}
+
+		currentLSN := se.lsnTracker.Reserve()
+		defer se.lsnTracker.Release(currentLSN)
+		if se.WAL != nil {
+			if err := se.writeMultiIndexWAL(wal.EntryMultiDelete, tableName, keys, nil, currentLSN); err != nil {
+				return err
+			}
+		}
+
+		if err := table.Heap.Delete(head, currentLSN); err != nil && !isChainEndErr(err) {
+			return fmt.Errorf("heap delete failed: %w", err)
+		}
+		deleted = true
+
+		for indexName := range keys {
+			se.appliedLSN.MarkApplied(tableName, indexName, currentLSN)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return deleted, triggers.afterWrite()
+}
+
+func primaryIndex(table *Table) (*Index, error) {
+	for _, idx := range table.GetIndicesUnsafe() {
+		if idx.Primary {
+			return idx, nil
+		}
+	}
+	return nil, fmt.Errorf("storage: table %s has no primary key", table.Name)
+}
+
+// rowKeysAt returns the indexed keys of the row version at rid. Rows
+// written through the single-index Put path may not carry every indexed
+// field; those fall back to the primary key alone.
+func rowKeysAt(table *Table, primary *Index, primaryKey types.Comparable, rid int64) (map[string]types.Comparable, error) {
+	keys, found, err := storedRowKeys(table, rid)
+	if err != nil {
+		return nil, fmt.Errorf("heap read failed: %w", err)
+	}
+	if !found {
+		keys = make(map[string]types.Comparable, 1)
+	}
+	keys[primary.Name] = primaryKey
+	return keys, nil
+}
+
+// latestVersionBefore walks the version chain from head and returns the
+// newest version created before lsn; -1 when there is none.
+func latestVersionBefore(table *Table, head int64, lsn uint64) (int64, bool, error) {
+	for rid := head; rid != -1; {
+		_, hdr, err := table.Heap.Read(rid)
+		if isChainEndErr(err) {
+			return -1, false, nil
+		}
+		if err != nil {
+			return -1, false, err
+		}
+		if hdr.CreateLSN < lsn {
+			return rid, hdr.Valid, nil
+		}
+		rid = hdr.PrevRecordID
+	}
+	return -1, false, nil
+}
+
+func (se *StorageEngine) redoMultiDeleteEntry(entry *wal.WALEntry, payload []byte, loadedLSNs map[string]uint64) error {
+	tableName, keys, _, err := DeserializeMultiIndexEntry(payload)
+	if err != nil {
+		return err
+	}
+
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return nil
+	}
+	primary, primaryKey, err := primaryIndexAndKey(table, keys)
+	if err != nil {
+		return err
+	}
+
+	table.Lock()
+	defer table.Unlock()
+
+	head, found, err := primary.Tree.Get(primaryKey)
+	if err != nil {
+		return fmt.Errorf("primary index get failed during recovery: %w", err)
+	}
+	if found {
+		// A later insert may already sit on top of the deleted version.
+		rid, live, err := latestVersionBefore(table, head, entry.Header.LSN)
+		if err != nil {
+			return err
+		}
+		if live {
+			if err := table.Heap.Delete(rid, entry.Header.LSN); err != nil && !isChainEndErr(err) {
+				return fmt.Errorf("heap delete failed: %w", err)
+			}
+		}
+	}
+
+	for indexName := range keys {
+		loadedLSNs[appliedLSNKey(tableName, indexName)] = entry.Header.LSN
+		se.appliedLSN.MarkApplied(tableName, indexName, entry.Header.LSN)
+	}
+	return nil
+}
+
+func (se *StorageEngine) undoMultiDeleteEntry(originalLSN uint64, payload []byte, clrLSN uint64) error {
+	tableName, keys, _, err := DeserializeMultiIndexEntry(payload)
+	if err != nil {
+		return err
+	}
+
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return nil
+	}
+	primary, primaryKey, err := primaryIndexAndKey(table, keys)
+	if err != nil {
+		return err
+	}
+
+	table.Lock()
+	defer table.Unlock()
+
+	head, found, err := primary.Tree.Get(primaryKey)
+	if err != nil || !found {
+		return err
+	}
+	rid, _, err := findRecordByDeleteLSN(table, head, originalLSN)
+	if err != nil || rid == -1 {
+		return err
+	}
+	if err := undeleteRecord(table.Heap, rid, originalLSN, clrLSN); err != nil {
+		return err
+	}
+	for indexName := range keys {
+		se.appliedLSN.MarkApplied(tableName, indexName, clrLSN)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+// DeleteRangeBatchSize is how many rows DeleteRange tombstones under one
+// WAL record and one LSN.
+const DeleteRangeBatchSize = 512
+
+// DeleteRange deletes every live row whose key in indexName matches
+// condition (every row when condition is nil). The index range is walked
+// once; rows are then deleted in batches of DeleteRangeBatchSize, each
+// logged as a single EntryMultiDeleteBatch record and applied at one LSN,
+// so a snapshot sees a batch either fully deleted or untouched. Batches
+// are independent: a failure stops the operation with the earlier
+// batches already deleted. Each row is checked again under its lock, so
+// rows changed since the walk are skipped when they no longer match.
+//
+// Returns the number of rows deleted.
+func (se *StorageEngine) DeleteRange(tableName, indexName string, condition *query.ScanCondition) (int, error) {
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return 0, err
+	}
+
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return 0, err
+	}
+	index, err := table.GetIndex(indexName)
+	if err != nil {
+		return 0, err
+	}
+	if condition != nil && condition.Field == "" && condition.Value != nil {
+		if err := validateKeyForIndex(index, condition.Value); err != nil {
+			return 0, err
+		}
+	}
+	primary, err := primaryIndex(table)
+	if err != nil {
+		return 0, err
+	}
+	condition = collateCondition(index, condition)
+
+	candidates, err := se.deleteRangeCandidates(table, index, primary, condition)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for start := 0; start < len(candidates); start += DeleteRangeBatchSize {
+		end := min(start+DeleteRangeBatchSize, len(candidates))
+		n, err := se.deleteRangeBatch(table, index, primary, condition, candidates[start:end])
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// deleteRangeCandidates walks the index range and returns the primary keys
+// of the live rows it reaches, each once.
+func (se *StorageEngine) deleteRangeCandidates(table *Table, index, primary *Index, condition *query.ScanCondition) ([]types.Comparable, error) {
+	scanner, ok := index.Tree.(rangeScanner)
+	if !ok {
+		return nil, fmt.Errorf("storage: index %s uses unsupported type %T", index.Name, index.Tree)
+	}
+
+	seen := make(map[string]struct{})
+	var candidates []types.Comparable
+	err := scanIndexRange(index, scanner, condition, false, func(key types.Comparable, recordID int64) error {
+		if condition != nil && !condition.Matches(key) {
+			return nil
+		}
+		live, err := isLiveRecord(table, recordID, true)
+		if err != nil || !live {
+			return err
+		}
+
+		primaryKey := key
+		if !index.Primary {
+			keys, found, err := storedRowKeys(table, recordID)
+			if err != nil {
+				return fmt.Errorf("heap read failed: %w", err)
+			}
+			if primaryKey, ok = keys[primary.Name]; !found || !ok {
+				return fmt.Errorf("storage: row at %d has no primary key %s", recordID, primary.Name)
+			}
+		}
+		resource, err := lockResourceForKey(table.Name, primary.Name, primaryKey)
+		if err != nil {
+			return err
+		}
+		if _, dup := seen[resource]; !dup {
+			seen[resource] = struct{}{}
+			candidates = append(candidates, primaryKey)
+		}
+		return nil
+	})
+	return candidates, err
+}
+
+type rangeDeleteRow struct {
+	head int64
+	keys map[string]types.Comparable
+}
+
+// deleteRangeBatch locks and deletes the rows of one batch that are still
+// live and still match condition.
+func (se *StorageEngine) deleteRangeBatch(table *Table, index, primary *Index, condition *query.ScanCondition, primaryKeys []types.Comparable) (int, error) {
+	resources := make([]string, 0, len(primaryKeys))
+	for _, primaryKey := range primaryKeys {
+		resource, err := lockResourceForKey(table.Name, primary.Name, primaryKey)
+		if err != nil {
+			return 0, err
+		}
+		resources = append(resources, resource)
+	}
+
+	deleted := 0
+	err := se.withAutoCommitLocks(resources, func() error {
+		table.Lock()
+		defer table.Unlock()
+
+		rows := make([]rangeDeleteRow, 0, len(primaryKeys))
+		for _, primaryKey := range primaryKeys {
+			head, found, err := primary.Tree.Get(primaryKey)
+			if err != nil {
+				return fmt.Errorf("primary index get failed: %w", err)
+			}
+			live, err := isLiveRecord(table, head, found)
+			if err != nil {
+				return err
+			}
+			if !live {
+				continue
+			}
+			keys, err := rowKeysAt(table, primary, primaryKey, head)
+			if err != nil {
+				return err
+			}
+			matches, err := rowMatchesRange(table, index, condition, keys, head)
+			if err != nil {
+				return err
+			}
+			if matches {
+				rows = append(rows, rangeDeleteRow{head: head, keys: keys})
+			}
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		currentLSN := se.lsnTracker.Reserve()
+		defer se.lsnTracker.Release(currentLSN)
+		if se.WAL != nil {
+			payloads := make([][]byte, len(rows))
+			for i, row := range rows {
+				payload, err := SerializeMultiIndexEntry(table.Name, row.keys, nil)
+				if err != nil {
+					return err
+				}
+				payloads[i] = payload
+			}
+			if err := se.writeAutoCommitWAL(wal.EntryMultiDeleteBatch, SerializeBatchEntry(payloads), currentLSN); err != nil {
+				return err
+			}
+		}
+
+		for i, row := range rows {
+			if err := table.Heap.Delete(row.head, currentLSN); err != nil && !isChainEndErr(err) {
+				// Part of a logged batch is applied: stop writes until
+				// recovery replays it.
+				applyErr := fmt.Errorf("range delete apply failed for %s at row %d/%d: %w", table.Name, i+1, len(rows), err)
+				se.markDegraded(applyErr)
+				return applyErr
+			}
+			for indexName := range row.keys {
+				se.appliedLSN.MarkApplied(table.Name, indexName, currentLSN)
+			}
+		}
+		deleted = len(rows)
+		return nil
+	})
+	return deleted, err
+}
+
+// rowMatchesRange re-checks condition against the current version of a
+// row. A multikey row matches when any of its elements does.
+func rowMatchesRange(table *Table, index *Index, condition *query.ScanCondition, keys map[string]types.Comparable, head int64) (bool, error) {
+	if condition == nil {
+		return true, nil
+	}
+	key, ok := keys[index.Name]
+	if ok {
+		key = index.Collation.Apply(key)
+	}
+	if !ok || !keyMatches(condition, key) {
+		return false, nil
+	}
+	if !condition.NeedsDocument() {
+		return true, nil
+	}
+	raw, _, err := table.Heap.Read(head)
+	if err != nil {
+		return false, fmt.Errorf("heap read failed: %w", err)
+	}
+	for _, elem := range indexKeyElements(key) {
+		if !condition.Matches(elem) {
+			continue
+		}
+		if matches, err := matchesDocument(condition, elem, raw); err != nil || matches {
+			return matches, err
+		}
+	}
+	return false, nil
+}
+
+// redoMultiDeleteBatch replays an EntryMultiDeleteBatch row by row.
+func (se *StorageEngine) redoMultiDeleteBatch(entry *wal.WALEntry, payload []byte, loadedLSNs map[string]uint64) error {
+	rows, err := DeserializeBatchEntry(payload)
+	if err != nil {
+		return err
+	}
+	for i, row := range rows {
+		if err := se.redoMultiDeleteEntry(entry, row, loadedLSNs); err != nil {
+			return fmt.Errorf("batch row %d: %w", i, err)
+		}
+	}
+	return nil
+}
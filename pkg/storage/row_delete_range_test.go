@@ -0,0 +1,78 @@
+package storage_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+func TestDeleteRange_BatchesAndRecovers(t *testing.T) {
+	dir := t.TempDir()
+	se := openEmployeesEngine(t, dir)
+
+	const n = storage.DeleteRangeBatchSize + 100
+	for id := int64(1); id <= n; id++ {
+		putEmployee(t, se, id, fmt.Sprintf("dept-%d", id%2))
+	}
+	snapshot := se.BeginRead()
+
+	deleted, err := se.DeleteRange("employees", "id", query.Between(types.IntKey(1), types.IntKey(n-10)))
+	if err != nil || deleted != n-10 {
+		t.Fatalf("DeleteRange: deleted=%d err=%v", deleted, err)
+	}
+	if count, _ := se.Count("employees", "id", nil); count != 10 {
+		t.Fatalf("expected 10 rows left, got %d", count)
+	}
+	if count, _ := snapshot.Count("employees", "id", nil); count != n {
+		t.Fatalf("snapshot lost rows: %d", count)
+	}
+	snapshot.Close()
+
+	// Through a secondary index: only live rows of the key are removed.
+	deleted, err = se.DeleteRange("employees", "department", query.Equal(types.VarcharKey("dept-0")))
+	if err != nil || deleted != 5 {
+		t.Fatalf("DeleteRange by department: deleted=%d err=%v", deleted, err)
+	}
+	if docs, _ := se.GetAll("employees", "department", types.VarcharKey("dept-0")); len(docs) != 0 {
+		t.Fatalf("dept-0 rows left: %v", docs)
+	}
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reader, err := wal.NewWALReader(filepath.Join(dir, "wal.log"))
+	if err != nil {
+		t.Fatalf("Failed to open WAL: %v", err)
+	}
+	batches := 0
+	for {
+		entry, err := reader.ReadEntry()
+		if err != nil {
+			break
+		}
+		if entry.Header.EntryType == wal.EntryMultiDeleteBatch {
+			batches++
+		}
+		wal.ReleaseEntry(entry)
+	}
+	reader.Close()
+	if batches != 3 {
+		t.Fatalf("expected 3 delete batch records, got %d", batches)
+	}
+
+	se2 := openEmployeesEngine(t, dir)
+	defer se2.Close()
+	if err := se2.Recover(filepath.Join(dir, "wal.log")); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	docs, err := se2.Scan("employees", "id", nil)
+	if err != nil {
+		t.Fatalf("Scan after recovery: %v", err)
+	}
+	assertIDs(t, "rows after recovery", docs, "603", "605", "607", "609", "611")
+}
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// GroupBy is Transaction.GroupBy on a snapshot taken for the call.
+func (se *StorageEngine) GroupBy(tableName string, indexName string, condition *query.ScanCondition, groupField string, specs ...query.AggSpec) ([]query.Group, error) {
+	tx := se.BeginRead()
+	defer tx.Close()
+	return tx.GroupBy(tableName, indexName, condition, groupField, specs...)
+}
+
+// GroupBy scans the rows visible to tx that match condition on the index,
+// buckets them by the document field groupField and computes every spec
+// per bucket. Groups come back ordered by their value; rows without
+// groupField fall in a group with a nil Key. A spec without Field
+// aggregates the index key.
+func (tx *Transaction) GroupBy(tableName string, indexName string, condition *query.ScanCondition, groupField string, specs ...query.AggSpec) ([]query.Group, error) {
+	grouper := query.NewGroupAggregator(specs...)
+	err := tx.walkVisibleRows(tableName, indexName, condition, false, true, func(key types.Comparable, raw []byte) error {
+		groupKey, _, err := documentField(raw, groupField)
+		if err != nil {
+			return err
+		}
+		values := make([]types.Comparable, len(specs))
+		for i, spec := range specs {
+			if spec.Field == "" {
+				values[i] = key
+				continue
+			}
+			if values[i], _, err = documentField(raw, spec.Field); err != nil {
+				return err
+			}
+		}
+		return grouper.Add(groupKey, values)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return grouper.Groups(), nil
+}
@@ -0,0 +1,47 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+func TestGroupBy(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+
+	putSalary(t, se, 1, "Eng", 100)
+	putSalary(t, se, 2, "Eng", 300)
+	putSalary(t, se, 3, "Sales", 50)
+	putSalary(t, se, 4, "Ops", 70)
+	putSalary(t, se, 5, "Sales", 150)
+	if _, err := se.DeleteRow("employees", types.IntKey(4)); err != nil {
+		t.Fatalf("DeleteRow: %v", err)
+	}
+
+	groups, err := se.GroupBy("employees", "id", query.GreaterThan(types.IntKey(1)), "department",
+		query.Count(), query.Avg().OfField("salary"), query.Max())
+	if err != nil {
+		t.Fatalf("GroupBy: %v", err)
+	}
+
+	want := []struct {
+		dept  string
+		count types.Comparable
+		avg   types.Comparable
+		maxID types.Comparable
+	}{
+		{"Eng", types.IntKey(1), types.FloatKey(300), types.IntKey(2)},
+		{"Sales", types.IntKey(2), types.FloatKey(100), types.IntKey(5)},
+	}
+	if len(groups) != len(want) {
+		t.Fatalf("expected %d groups, got %+v", len(want), groups)
+	}
+	for i, w := range want {
+		g := groups[i]
+		if g.Key != types.VarcharKey(w.dept) || g.Results[0].Value != w.count || g.Results[1].Value != w.avg || g.Results[2].Value != w.maxID {
+			t.Fatalf("group %s: got key=%v results=%+v", w.dept, g.Key, g.Results)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bobboyms/storage-engine/pkg/errors"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type unsetField struct{}
+
+// Unset, used as a value in UpdateFields, removes the field from the row.
+var Unset = unsetField{}
+
+// UpdateFields changes some top-level fields of one row and writes the
+// result as a new version. Each entry of fields sets the field to the
+// value, or removes it when the value is Unset; fields not named keep
+// their current value.
+//
+// The row is found through indexName: the primary index, or a secondary
+// index whose key matches exactly one live row. The read, the change and
+// the write happen under the row lock and the table's exclusive lock.
+// Changing an indexed field moves the row in that index; the primary key
+// cannot be changed and indexed fields cannot be removed. Returns
+// *errors.RowNotFoundError when no live row matches.
+func (se *StorageEngine) UpdateFields(tableName, indexName string, key types.Comparable, fields map[string]interface{}) error {
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return err
+	}
+
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return err
+	}
+	index, err := table.GetIndex(indexName)
+	if err != nil {
+		return err
+	}
+	if err := validateKeyForIndex(index, key); err != nil {
+		return err
+	}
+
+	table.RLock()
+	primary, primaryKey, err := primaryKeyFor(table, index, key)
+	table.RUnlock()
+	if err != nil {
+		return err
+	}
+	resource, err := lockResourceForKey(tableName, primary.Name, primaryKey)
+	if err != nil {
+		return err
+	}
+
+	return se.withAutoCommitLocks([]string{resource}, func() error {
+		table.Lock()
+		defer table.Unlock()
+
+		notFound := &errors.RowNotFoundError{TableName: tableName, Key: fmt.Sprintf("%v", key)}
+		head, found, err := primary.Tree.Get(primaryKey)
+		if err != nil {
+			return fmt.Errorf("primary index get failed: %w", err)
+		}
+		live, err := isLiveRecord(table, head, found)
+		if err != nil {
+			return err
+		}
+		if !live {
+			return notFound
+		}
+
+		docBytes, _, err := table.Heap.Read(head)
+		if err != nil {
+			return fmt.Errorf("heap read failed: %w", err)
+		}
+		if !index.Primary {
+			// The row may have left the key between lookup and lock.
+			current, ok, err := indexKeyFromStoredDocument(index, docBytes)
+			if err != nil {
+				return err
+			}
+			if !ok || !sameComparableKey(current, key) {
+				return notFound
+			}
+		}
+
+		doc, err := storedDocumentBson(docBytes)
+		if err != nil {
+			return err
+		}
+		// A nested primary key is protected through its top-level field.
+		doc, err = applyFieldChanges(doc, fields, strings.SplitN(primary.FieldPath(), ".", 2)[0])
+		if err != nil {
+			return err
+		}
+		if err := validateDocument(table, doc); err != nil {
+			return err
+		}
+		keys, err := rowDocumentKeys(table, doc)
+		if err != nil {
+			return err
+		}
+		bsonData, err := MarshalBson(doc)
+		if err != nil {
+			return err
+		}
+
+		currentLSN := se.lsnTracker.Reserve()
+		defer se.lsnTracker.Release(currentLSN)
+		if se.WAL != nil {
+			if err := se.writeMultiIndexWAL(wal.EntryMultiInsert, tableName, keys, bsonData, currentLSN); err != nil {
+				return err
+			}
+		}
+		return se.applyRowVersion(context.Background(), table, keys, bsonData, currentLSN, nil)
+	})
+}
+
+// primaryKeyFor resolves key of index to the primary key of the row it
+// names. A secondary key must match exactly one live row. Caller must
+// hold the table lock.
+func primaryKeyFor(table *Table, index *Index, key types.Comparable) (*Index, types.Comparable, error) {
+	primary, err := primaryIndex(table)
+	if err != nil {
+		return nil, nil, err
+	}
+	if index.Primary {
+		return primary, key, nil
+	}
+
+	postings, ok := index.postings()
+	if !ok {
+		return nil, nil, fmt.Errorf("storage: index %s cannot locate rows", index.Name)
+	}
+	recordIDs, err := postings.GetAll(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("tree get: %w", err)
+	}
+
+	var primaryKey types.Comparable
+	for _, rid := range recordIDs {
+		docBytes, hdr, err := table.Heap.Read(rid)
+		if isChainEndErr(err) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("heap read failed: %w", err)
+		}
+		if !hdr.Valid {
+			continue
+		}
+		rowKey, ok, err := indexKeyFromStoredDocument(primary, docBytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			continue
+		}
+		if primaryKey != nil && !sameComparableKey(primaryKey, rowKey) {
+			return nil, nil, fmt.Errorf("storage: key %v of index %s matches more than one row", key, index.Name)
+		}
+		primaryKey = rowKey
+	}
+	if primaryKey == nil {
+		return nil, nil, &errors.RowNotFoundError{TableName: table.Name, Key: fmt.Sprintf("%v", key)}
+	}
+	return primary, primaryKey, nil
+}
+
+// applyFieldChanges sets or removes the named top-level fields of doc.
+// New fields are appended in name order.
+func applyFieldChanges(doc bson.D, fields map[string]interface{}, primaryField string) (bson.D, error) {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := fields[name]
+		if name == primaryField {
+			return nil, fmt.Errorf("storage: UpdateFields cannot change primary key field %s", name)
+		}
+
+		pos := -1
+		for i, elem := range doc {
+			if elem.Key == name {
+				pos = i
+				break
+			}
+		}
+		switch {
+		case value == Unset:
+			if pos != -1 {
+				doc = append(doc[:pos], doc[pos+1:]...)
+			}
+		case pos != -1:
+			doc[pos].Value = value
+		default:
+			doc = append(doc, bson.E{Key: name, Value: value})
+		}
+	}
+	return doc, nil
+}
+
+// storedDocumentBson decodes a heap document: BSON, or JSON for older
+// rows.
+func storedDocumentBson(docBytes []byte) (bson.D, error) {
+	doc, err := UnmarshalBson(docBytes)
+	if err == nil {
+		return doc, nil
+	}
+	return JsonToBson(string(docBytes))
+}
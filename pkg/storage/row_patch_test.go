@@ -0,0 +1,120 @@
+package storage_test
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/errors"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+func getEmployeeFields(t *testing.T, se *storage.StorageEngine, id int64) map[string]interface{} {
+	t.Helper()
+	doc, found, err := se.Get("employees", "id", types.IntKey(id))
+	if err != nil || !found {
+		t.Fatalf("Get %d: found=%v err=%v", id, found, err)
+	}
+	var row map[string]interface{}
+	if err := json.Unmarshal([]byte(doc), &row); err != nil {
+		t.Fatalf("invalid document %s: %v", doc, err)
+	}
+	return row
+}
+
+func TestUpdateFields_SetsAndUnsetsFields(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+
+	if err := se.InsertRow("employees", `{"id": 1, "department": "Engineering", "name": "Ana", "level": 2}`, nil); err != nil {
+		t.Fatalf("InsertRow: %v", err)
+	}
+	old := se.BeginRead()
+	defer old.Close()
+
+	err := se.UpdateFields("employees", "id", types.IntKey(1), map[string]interface{}{
+		"level":      3,
+		"name":       storage.Unset,
+		"department": "Sales",
+		"email":      "ana@example.com",
+	})
+	if err != nil {
+		t.Fatalf("UpdateFields: %v", err)
+	}
+
+	row := getEmployeeFields(t, se, 1)
+	if row["level"] != float64(3) || row["department"] != "Sales" || row["email"] != "ana@example.com" {
+		t.Fatalf("unexpected row after UpdateFields: %v", row)
+	}
+	if _, ok := row["name"]; ok {
+		t.Fatalf("name should be unset: %v", row)
+	}
+
+	docs, _ := se.GetAll("employees", "department", types.VarcharKey("Engineering"))
+	assertIDs(t, "Engineering", docs)
+	docs, _ = se.GetAll("employees", "department", types.VarcharKey("Sales"))
+	assertIDs(t, "Sales", docs, "1")
+
+	// The previous version stays visible to older snapshots.
+	docs, _ = old.GetAll("employees", "department", types.VarcharKey("Engineering"))
+	assertIDs(t, "snapshot Engineering", docs, "1")
+}
+
+func TestUpdateFields_BySecondaryIndex(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+
+	putEmployee(t, se, 1, "Engineering")
+	putEmployee(t, se, 2, "Sales")
+	putEmployee(t, se, 3, "Sales")
+
+	if err := se.UpdateFields("employees", "department", types.VarcharKey("Engineering"), map[string]interface{}{
+		"floor": 4,
+	}); err != nil {
+		t.Fatalf("UpdateFields by department: %v", err)
+	}
+	if row := getEmployeeFields(t, se, 1); row["floor"] != float64(4) {
+		t.Fatalf("floor not set: %v", row)
+	}
+
+	if err := se.UpdateFields("employees", "department", types.VarcharKey("Sales"), map[string]interface{}{
+		"floor": 1,
+	}); err == nil {
+		t.Fatal("expected an error for a key that matches several rows")
+	}
+}
+
+func TestUpdateFields_Errors(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+
+	putEmployee(t, se, 1, "Engineering")
+
+	var notFound *errors.RowNotFoundError
+	err := se.UpdateFields("employees", "id", types.IntKey(9), map[string]interface{}{"floor": 1})
+	if !stderrors.As(err, &notFound) {
+		t.Fatalf("expected RowNotFoundError, got %v", err)
+	}
+	if _, err := se.DeleteRow("employees", types.IntKey(1)); err != nil {
+		t.Fatalf("DeleteRow: %v", err)
+	}
+	err = se.UpdateFields("employees", "id", types.IntKey(1), map[string]interface{}{"floor": 1})
+	if !stderrors.As(err, &notFound) {
+		t.Fatalf("expected RowNotFoundError for a deleted row, got %v", err)
+	}
+
+	putEmployee(t, se, 2, "Sales")
+	if err := se.UpdateFields("employees", "id", types.IntKey(2), map[string]interface{}{"id": 3}); err == nil {
+		t.Fatal("expected an error changing the primary key")
+	}
+	if err := se.UpdateFields("employees", "id", types.IntKey(2), map[string]interface{}{"department": storage.Unset}); err == nil {
+		t.Fatal("expected an error removing an indexed field")
+	}
+	if err := se.UpdateFields("employees", "id", types.IntKey(2), map[string]interface{}{"department": 7}); err == nil {
+		t.Fatal("expected an error for a value of the wrong index type")
+	}
+	if row := getEmployeeFields(t, se, 2); row["department"] != "Sales" {
+		t.Fatalf("failed updates changed the row: %v", row)
+	}
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Upsert inserts doc, or, when a live row already exists under its
+// primary key, calls onConflict with that row (as JSON) and writes the
+// document it returns instead. A nil onConflict keeps doc, like UpsertRow.
+//
+// The read, the callback and the write happen under the row lock and the
+// table's exclusive lock, so concurrent upserts of the same row never lose
+// an update. onConflict must not call back into the engine. The merged
+// document must keep the primary key; its indexed keys are extracted from
+// it and every index is kept in sync. An error from onConflict aborts the
+// upsert before anything is logged.
+func (se *StorageEngine) Upsert(tableName string, doc string, keys map[string]types.Comparable, onConflict func(existing string) (string, error)) error {
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return err
+	}
+
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return err
+	}
+
+	bsonData, rowKeys, err := prepareRowDocument(table, doc, keys)
+	if err != nil {
+		return err
+	}
+	resources, err := lockResourcesForKeys(tableName, rowKeys)
+	if err != nil {
+		return err
+	}
+
+	return se.withAutoCommitLocks(resources, func() error {
+		table.Lock()
+		defer table.Unlock()
+
+		primary, primaryKey, err := primaryIndexAndKey(table, rowKeys)
+		if err != nil {
+			return err
+		}
+		head, found, err := primary.Tree.Get(primaryKey)
+		if err != nil {
+			return fmt.Errorf("primary index get failed: %w", err)
+		}
+		live, err := isLiveRecord(table, head, found)
+		if err != nil {
+			return err
+		}
+
+		if live && onConflict != nil {
+			existingBytes, _, err := table.Heap.Read(head)
+			if err != nil {
+				return fmt.Errorf("heap read failed: %w", err)
+			}
+			existing := string(existingBytes)
+			if jsonStr, err := BsonToJson(existingBytes); err == nil {
+				existing = jsonStr
+			}
+
+			merged, err := onConflict(existing)
+			if err != nil {
+				return err
+			}
+			bsonData, rowKeys, err = mergedRowDocument(table, merged)
+			if err != nil {
+				return fmt.Errorf("storage: upsert merged document: %w", err)
+			}
+			if !sameComparableKey(rowKeys[primary.Name], primaryKey) {
+				return fmt.Errorf("storage: upsert merged document changes primary key %s from %v to %v", primary.Name, primaryKey, rowKeys[primary.Name])
+			}
+		}
+
+		currentLSN := se.lsnTracker.Reserve()
+		defer se.lsnTracker.Release(currentLSN)
+		if se.WAL != nil {
+			if err := se.writeMultiIndexWAL(wal.EntryMultiInsert, tableName, rowKeys, bsonData, currentLSN); err != nil {
+				return err
+			}
+		}
+		return se.applyRowVersion(context.Background(), table, rowKeys, bsonData, currentLSN, nil)
+	})
+}
+
+// mergedRowDocument converts a document built under the table lock and
+// extracts the key of every index from it.
+func mergedRowDocument(table *Table, doc string) ([]byte, map[string]types.Comparable, error) {
+	bsonDoc, err := JsonToBson(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := validateDocument(table, bsonDoc); err != nil {
+		return nil, nil, err
+	}
+	keys, err := rowDocumentKeys(table, bsonDoc)
+	if err != nil {
+		return nil, nil, err
+	}
+	bsonData, err := MarshalBson(bsonDoc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return bsonData, keys, nil
+}
+
+// rowDocumentKeys returns the key of every index of table found in
+// bsonDoc. Unlike keysFromBSONForAllIndexes it does not take the table
+// lock, so callers must already hold it.
+func rowDocumentKeys(table *Table, bsonDoc bson.D) (map[string]types.Comparable, error) {
+	keys := make(map[string]types.Comparable, len(table.Indices))
+	for _, idx := range table.GetIndicesUnsafe() {
+		key, err := idx.keyFromBson(bsonDoc)
+		if err != nil {
+			return nil, fmt.Errorf("storage: document has no field for index %s", idx.Name)
+		}
+		if err := validateKeyForIndex(idx, key); err != nil {
+			return nil, err
+		}
+		keys[idx.Name] = key
+	}
+	return keys, nil
+}
@@ -0,0 +1,130 @@
+package storage_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// incrementVisits is an onConflict callback that bumps the "visits"
+// counter of the existing row.
+func incrementVisits(existing string) (string, error) {
+	var row map[string]interface{}
+	if err := json.Unmarshal([]byte(existing), &row); err != nil {
+		return "", err
+	}
+	visits, _ := row["visits"].(float64)
+	return fmt.Sprintf(`{"id": %v, "department": "%v", "visits": %d}`, row["id"], row["department"], int(visits)+1), nil
+}
+
+func visitsOf(t *testing.T, doc string) int {
+	t.Helper()
+	var row map[string]interface{}
+	if err := json.Unmarshal([]byte(doc), &row); err != nil {
+		t.Fatalf("invalid document %s: %v", doc, err)
+	}
+	visits, _ := row["visits"].(float64)
+	return int(visits)
+}
+
+func TestUpsert_InsertsThenMerges(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+
+	doc := `{"id": 1, "department": "Engineering", "visits": 1}`
+	if err := se.Upsert("employees", doc, nil, incrementVisits); err != nil {
+		t.Fatalf("Upsert insert: %v", err)
+	}
+	if err := se.Upsert("employees", doc, nil, incrementVisits); err != nil {
+		t.Fatalf("Upsert merge: %v", err)
+	}
+
+	got, found, err := se.Get("employees", "id", types.IntKey(1))
+	if err != nil || !found {
+		t.Fatalf("Get: found=%v err=%v", found, err)
+	}
+	if visits := visitsOf(t, got); visits != 2 {
+		t.Fatalf("expected visits=2, got %d (%s)", visits, got)
+	}
+
+	// The merged document decides the secondary keys.
+	err = se.Upsert("employees", doc, nil, func(string) (string, error) {
+		return `{"id": 1, "department": "Sales", "visits": 5}`, nil
+	})
+	if err != nil {
+		t.Fatalf("Upsert moving department: %v", err)
+	}
+	docs, _ := se.GetAll("employees", "department", types.VarcharKey("Engineering"))
+	assertIDs(t, "Engineering", docs)
+	docs, _ = se.GetAll("employees", "department", types.VarcharKey("Sales"))
+	assertIDs(t, "Sales", docs, "1")
+}
+
+func TestUpsert_CallbackErrorsAbort(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+
+	putEmployee(t, se, 1, "Engineering")
+
+	errStop := errors.New("stop")
+	err := se.Upsert("employees", `{"id": 1, "department": "Sales"}`, nil, func(string) (string, error) {
+		return "", errStop
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("expected callback error, got %v", err)
+	}
+
+	err = se.Upsert("employees", `{"id": 1, "department": "Sales"}`, nil, func(string) (string, error) {
+		return `{"id": 2, "department": "Sales"}`, nil
+	})
+	if err == nil {
+		t.Fatal("expected an error when the merged document changes the primary key")
+	}
+
+	docs, _ := se.GetAll("employees", "department", types.VarcharKey("Engineering"))
+	assertIDs(t, "Engineering after aborted upserts", docs, "1")
+	if _, found, _ := se.Get("employees", "id", types.IntKey(2)); found {
+		t.Fatal("aborted upsert wrote row 2")
+	}
+}
+
+func TestUpsert_ConcurrentUpdatesAreNotLost(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+
+	const workers = 8
+	const perWorker = 25
+	doc := `{"id": 1, "department": "Engineering", "visits": 1}`
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				if err := se.Upsert("employees", doc, nil, incrementVisits); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("concurrent Upsert: %v", err)
+	}
+
+	got, found, err := se.Get("employees", "id", types.IntKey(1))
+	if err != nil || !found {
+		t.Fatalf("Get: found=%v err=%v", found, err)
+	}
+	if visits := visitsOf(t, got); visits != workers*perWorker {
+		t.Fatalf("expected visits=%d, got %d", workers*perWorker, visits)
+	}
+}
@@ -1,10 +1,11 @@
 package storage
 
 import (
+	"context"
 	"fmt"
 
-	btreev2 "github.com/bobboyms/storage-engine/pkg/btree/v2"
 	"github.com/bobboyms/storage-engine/pkg/errors"
+	"github.com/bobboyms/storage-engine/pkg/tracing"
 	"github.com/bobboyms/storage-engine/pkg/types"
 	"github.com/bobboyms/storage-engine/pkg/wal"
 	"go.mongodb.org/mongo-driver/v2/bson"
@@ -16,19 +17,31 @@ type indexUpdateUndo struct {
 	old     int64
 	exists  bool
 	changed bool
+	posting int64
 }
 
-func (se *StorageEngine) writeRow(tableName string, doc string, providedKeys map[string]types.Comparable, insertOnly bool) error {
+// rowWriteMode selects how writeRow treats an existing primary key.
+type rowWriteMode int
+
+const (
+	rowUpsert rowWriteMode = iota
+	// rowInsert fails when the primary key already exists.
+	rowInsert
+	// rowUpdate fails when there is no live row under the primary key.
+	rowUpdate
+)
+
+func (se *StorageEngine) writeRow(tableName string, doc string, providedKeys map[string]types.Comparable, mode rowWriteMode) error {
 	se.opMu.RLock()
 	defer se.opMu.RUnlock()
 	if err := se.runtimeReadyError(); err != nil {
 		return err
 	}
 
-	return se.writeRowLocked(tableName, doc, providedKeys, insertOnly)
+	return se.writeRowLocked(context.Background(), tableName, doc, providedKeys, mode)
 }
 
-func (se *StorageEngine) writeRowLocked(tableName string, doc string, providedKeys map[string]types.Comparable, insertOnly bool) error {
+func (se *StorageEngine) writeRowLocked(ctx context.Context, tableName string, doc string, providedKeys map[string]types.Comparable, mode rowWriteMode) error {
 	table, err := se.TableMetaData.GetTableByName(tableName)
 	if err != nil {
 		return err
@@ -38,13 +51,50 @@ func (se *StorageEngine) writeRowLocked(tableName string, doc string, providedKe
 	if err != nil {
 		return err
 	}
+	return se.writePreparedRow(ctx, table, bsonData, keys, mode)
+}
+
+// writeBsonRow is writeRow for a document that is already BSON; every
+// index key is taken from the document.
+func (se *StorageEngine) writeBsonRow(tableName string, doc bson.D, mode rowWriteMode) error {
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return err
+	}
 
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return err
+	}
+	if err := validateDocument(table, doc); err != nil {
+		return err
+	}
+	keys, ok, err := keysFromBSONForAllIndexes(table, doc)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("storage: document does not contain every indexed field")
+	}
+	bsonData, err := MarshalBson(doc)
+	if err != nil {
+		return err
+	}
+	return se.writePreparedRow(context.Background(), table, bsonData, keys, mode)
+}
+
+// writePreparedRow writes an encoded row under its row locks and the
+// table's exclusive lock, applying mode to an existing primary key.
+func (se *StorageEngine) writePreparedRow(ctx context.Context, table *Table, bsonData []byte, keys map[string]types.Comparable, mode rowWriteMode) error {
+	tableName := table.Name
 	resources, err := lockResourcesForKeys(tableName, keys)
 	if err != nil {
 		return err
 	}
 
-	return se.withAutoCommitLocks(resources, func() error {
+	var triggers *rowTriggers
+	err = se.withAutoCommitLocks(resources, func() error {
 		table.Lock()
 		defer table.Unlock()
 
@@ -53,68 +103,154 @@ func (se *StorageEngine) writeRowLocked(tableName string, doc string, providedKe
 			return err
 		}
 
+		_, span := se.tracer.Start(ctx, "btree.get")
 		oldPrimaryOffset, primaryExists, err := primary.Tree.Get(primaryKey)
+		tracing.End(span, err)
 		if err != nil {
 			return fmt.Errorf("primary index get failed: %w", err)
 		}
-		if insertOnly && primaryExists {
-			return fmt.Errorf("duplicate key error: key %v already exists in index %s", primaryKey, primary.Name)
+		var live bool
+		if mode != rowUpsert || se.triggers.has(tableName, allTriggers) {
+			// A key whose head version was deleted (e.g. through Del) no
+			// longer names a row: it can be inserted again but not updated.
+			live, err = isLiveRecord(table, oldPrimaryOffset, primaryExists)
+			if err != nil {
+				return err
+			}
+			if mode == rowInsert && live {
+				return fmt.Errorf("duplicate key error: key %v already exists in index %s", primaryKey, primary.Name)
+			}
+			if mode == rowUpdate && !live {
+				return &errors.RowNotFoundError{TableName: tableName, Key: fmt.Sprintf("%v", primaryKey)}
+			}
+		}
+		if triggers, err = se.newRowTriggers(table, primaryKey, oldPrimaryOffset, live, bsonData, false); err != nil {
+			return err
+		}
+		if err := triggers.before(); err != nil {
+			return err
 		}
 
-		currentLSN := se.lsnTracker.Next()
+		currentLSN := se.lsnTracker.Reserve()
+		defer se.lsnTracker.Release(currentLSN)
 		if se.WAL != nil {
-			if err := se.writeMultiIndexWAL(tableName, keys, bsonData, currentLSN); err != nil {
+			if err := se.writeMultiIndexWALCtx(ctx, wal.EntryMultiInsert, tableName, keys, bsonData, currentLSN); err != nil {
 				return err
 			}
 		}
 
-		prevOffset := int64(-1)
-		if primaryExists {
-			prevOffset = oldPrimaryOffset
-		}
-		offset, err := table.Heap.Write(bsonData, currentLSN, prevOffset)
-		if err != nil {
-			return fmt.Errorf("heap write failed: %w", err)
-		}
+		return se.applyRowVersion(ctx, table, keys, bsonData, currentLSN, nil)
+	})
+	if err != nil {
+		return err
+	}
+	return triggers.afterWrite()
+}
+
+// applyRowVersion installs bsonData as the newest version of the row named
+// by keys: heap write chained to the current version, every index pointer
+// moved to it and the previous version tombstoned. The caller holds the
+// table lock and has already logged the write at lsn. afterHeap, when set,
+// runs right after the heap write. The heap write and the index updates
+// are traced as "heap.write" and "btree.upsert" under the span of ctx.
+func (se *StorageEngine) applyRowVersion(ctx context.Context, table *Table, keys map[string]types.Comparable, bsonData []byte, lsn uint64, afterHeap func() error) error {
+	primary, primaryKey, err := primaryIndexAndKey(table, keys)
+	if err != nil {
+		return err
+	}
+	oldPrimaryOffset, primaryExists, err := primary.Tree.Get(primaryKey)
+	if err != nil {
+		return fmt.Errorf("primary index get failed: %w", err)
+	}
 
-		if err := applyIndexPointersWithLSN(table, keys, offset, currentLSN); err != nil {
+	prevOffset := int64(-1)
+	if primaryExists {
+		prevOffset = oldPrimaryOffset
+	}
+	_, span := se.tracer.Start(ctx, "heap.write")
+	offset, err := writeRowVersion(table, keys, bsonData, lsn, prevOffset)
+	tracing.End(span, err)
+	if err != nil {
+		return fmt.Errorf("heap write failed: %w", err)
+	}
+	if afterHeap != nil {
+		if err := afterHeap(); err != nil {
 			return err
 		}
+	}
 
-		if primaryExists {
-			if err := table.Heap.Delete(oldPrimaryOffset, currentLSN); err != nil && !isChainEndErr(err) {
-				_ = applyIndexPointers(table, map[string]types.Comparable{primary.Name: primaryKey}, oldPrimaryOffset)
-				return fmt.Errorf("heap delete previous version failed: %w", err)
-			}
-		}
+	_, span = se.tracer.Start(ctx, "btree.upsert")
+	err = applyIndexPointersWithLSN(table, keys, offset, lsn)
+	tracing.End(span, err)
+	if err != nil {
+		return err
+	}
 
-		for indexName := range keys {
-			se.appliedLSN.MarkApplied(tableName, indexName, currentLSN)
+	if primaryExists {
+		if err := table.Heap.Delete(oldPrimaryOffset, lsn); err != nil && !isChainEndErr(err) {
+			_ = applyIndexPointers(table, map[string]types.Comparable{primary.Name: primaryKey}, oldPrimaryOffset)
+			return fmt.Errorf("heap delete previous version failed: %w", err)
 		}
-		return nil
-	})
+	}
+
+	for indexName := range keys {
+		se.appliedLSN.MarkApplied(table.Name, indexName, lsn)
+	}
+	return nil
+}
+
+// isLiveRecord reports whether the version at offset has not been deleted.
+func isLiveRecord(table *Table, offset int64, exists bool) (bool, error) {
+	if !exists {
+		return false, nil
+	}
+	_, header, err := table.Heap.Read(offset)
+	if isChainEndErr(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("heap read failed: %w", err)
+	}
+	return header.Valid, nil
 }
 
-func (se *StorageEngine) writeMultiIndexWAL(tableName string, keys map[string]types.Comparable, bsonData []byte, lsn uint64) error {
+func (se *StorageEngine) writeMultiIndexWAL(entryType uint8, tableName string, keys map[string]types.Comparable, bsonData []byte, lsn uint64) error {
+	return se.writeMultiIndexWALCtx(context.Background(), entryType, tableName, keys, bsonData, lsn)
+}
+
+func (se *StorageEngine) writeMultiIndexWALCtx(ctx context.Context, entryType uint8, tableName string, keys map[string]types.Comparable, bsonData []byte, lsn uint64) error {
 	payload, err := SerializeMultiIndexEntry(tableName, keys, bsonData)
 	if err != nil {
 		return err
 	}
+	return se.writeAutoCommitWALCtx(ctx, entryType, payload, lsn)
+}
 
+// writeAutoCommitWAL logs payload as a non-transactional entry.
+func (se *StorageEngine) writeAutoCommitWAL(entryType uint8, payload []byte, lsn uint64) error {
+	return se.writeAutoCommitWALCtx(context.Background(), entryType, payload, lsn)
+}
+
+// writeAutoCommitWALCtx is writeAutoCommitWAL under the span of ctx.
+func (se *StorageEngine) writeAutoCommitWALCtx(ctx context.Context, entryType uint8, payload []byte, lsn uint64) error {
+	if err := se.logWALClock(ctx, lsn); err != nil {
+		return err
+	}
 	entry := wal.AcquireEntry()
 	entry.Header.Magic = wal.WALMagic
 	entry.Header.Version = 1
-	entry.Header.EntryType = wal.EntryMultiInsert
+	entry.Header.EntryType = entryType
 	entry.Header.LSN = lsn
 	entry.Header.PayloadLen = uint32(len(payload))
 	entry.Header.CRC32 = wal.CalculateCRC32(payload)
 	entry.Payload = append(entry.Payload, payload...)
 
-	err = se.WAL.WriteEntry(entry)
+	err := se.WAL.WriteEntryCtx(ctx, entry)
 	wal.ReleaseEntry(entry)
 	if err != nil {
 		return fmt.Errorf("wal write failed: %w", err)
 	}
+	se.changes.publish(entryType, payload, lsn)
 	return nil
 }
 
@@ -125,6 +261,9 @@ func prepareRowDocument(table *Table, doc string, providedKeys map[string]types.
 
 	bsonDoc, err := JsonToBson(doc)
 	if err == nil {
+		if err := validateDocument(table, bsonDoc); err != nil {
+			return nil, nil, err
+		}
 		keys, ok, err := keysFromBSONForAllIndexes(table, bsonDoc)
 		if err != nil {
 			return nil, nil, err
@@ -137,7 +276,7 @@ func prepareRowDocument(table *Table, doc string, providedKeys map[string]types.
 			if !ok {
 				return nil, nil, &errors.IndexNotFoundError{Name: name}
 			}
-			if !sameComparableKey(derived, provided) {
+			if !sameComparableKey(derived, normalizeIndexKey(table.Indices[name], provided)) {
 				return nil, nil, fmt.Errorf("storage: key informada %s=%v diverge do documento (%v)", name, provided, derived)
 			}
 		}
@@ -148,12 +287,16 @@ func prepareRowDocument(table *Table, doc string, providedKeys map[string]types.
 		return bsonData, keys, nil
 	}
 
+	if err := validateOpaqueDocument(table); err != nil {
+		return nil, nil, err
+	}
 	keys := make(map[string]types.Comparable, len(providedKeys))
 	for name, key := range providedKeys {
 		idx, ok := table.Indices[name]
 		if !ok {
 			return nil, nil, &errors.IndexNotFoundError{Name: name}
 		}
+		key = normalizeIndexKey(idx, key)
 		if err := validateKeyForIndex(idx, key); err != nil {
 			return nil, nil, err
 		}
@@ -161,6 +304,10 @@ func prepareRowDocument(table *Table, doc string, providedKeys map[string]types.
 	}
 	for _, idx := range table.GetIndices() {
 		if _, ok := keys[idx.Name]; !ok {
+			if idx.Nullable {
+				keys[idx.Name] = normalizeIndexKey(idx, types.NullKey{})
+				continue
+			}
 			return nil, nil, fmt.Errorf("storage: key obrigatoria para indice %s ausente", idx.Name)
 		}
 	}
@@ -170,7 +317,7 @@ func prepareRowDocument(table *Table, doc string, providedKeys map[string]types.
 func keysFromBSONForAllIndexes(table *Table, bsonDoc bson.D) (map[string]types.Comparable, bool, error) {
 	keys := make(map[string]types.Comparable)
 	for _, idx := range table.GetIndices() {
-		key, err := GetValueFromBson(bsonDoc, idx.Name)
+		key, err := idx.keyFromBson(bsonDoc)
 		if err != nil {
 			return nil, false, nil
 		}
@@ -183,6 +330,15 @@ func keysFromBSONForAllIndexes(table *Table, bsonDoc bson.D) (map[string]types.C
 }
 
 func validateKeyForIndex(index *Index, key types.Comparable) error {
+	if elems, ok := key.(types.ArrayKey); ok {
+		return validateMultikey(index, elems)
+	}
+	if _, null := key.(types.NullKey); null {
+		if !index.Nullable {
+			return &errors.InvalidKeyTypeError{Name: index.Name, TypeName: "NULL"}
+		}
+		return nil
+	}
 	if getTypeFromKey(key) != index.Type {
 		return &errors.InvalidKeyTypeError{
 			Name:     index.Name,
@@ -228,14 +384,24 @@ func applyIndexPointersWithLSN(table *Table, keys map[string]types.Comparable, o
 			rollbackIndexPointers(undos)
 			return &errors.IndexNotFoundError{Name: indexName}
 		}
+		if postings, ok := idx.postings(); ok {
+			// Non-unique index: the new version gets its own posting and
+			// the old one stays behind its tombstone for older snapshots.
+			if err := insertPostingWithLSN(postings, key, offset, lsn); err != nil {
+				rollbackIndexPointers(undos)
+				return fmt.Errorf("failed to update index %s: %w", indexName, err)
+			}
+			undos = append(undos, indexUpdateUndo{index: idx, key: key, changed: true, posting: offset})
+			continue
+		}
 		old, exists, err := idx.Tree.Get(key)
 		if err != nil {
 			rollbackIndexPointers(undos)
 			return fmt.Errorf("index %s get failed: %w", indexName, err)
 		}
 		undo := indexUpdateUndo{index: idx, key: key, old: old, exists: exists}
-		if treeV2, ok := idx.Tree.(*btreev2.BTreeV2); ok {
-			if err := treeV2.ReplaceWithLSN(key, offset, lsn); err != nil {
+		if tree, ok := idx.Tree.(lsnTree); ok {
+			if err := tree.ReplaceWithLSN(key, offset, lsn); err != nil {
 				rollbackIndexPointers(undos)
 				return fmt.Errorf("failed to update index %s: %w", indexName, err)
 			}
@@ -255,6 +421,10 @@ func rollbackIndexPointers(undos []indexUpdateUndo) {
 		if !undo.changed {
 			continue
 		}
+		if postings, ok := undo.index.postings(); ok {
+			_, _ = removePostingWithLSN(postings, undo.key, undo.posting, 0)
+			continue
+		}
 		if undo.exists {
 			_ = undo.index.Tree.Replace(undo.key, undo.old)
 		} else {
@@ -0,0 +1,200 @@
+package storage
+
+import (
+	goerrors "errors"
+	"fmt"
+	"slices"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// ScanOptions selects one page of a Scan. Offset and Limit count visible
+// rows that match the condition; a zero Limit means no limit. Reverse
+// walks the index from the largest key down. The index walk stops as soon
+// as the page is full. Projection, when set, returns only those fields of
+// each document (dotted paths reach nested fields).
+//
+// OrderBy, when set, returns the rows sorted by a document field rather
+// than in index order; the index still picks the rows, and Reverse only
+// changes which of two rows with equal fields comes first. The whole
+// match is read before the first row comes back, in at most SortMemory
+// bytes of documents (DefaultSortMemory when zero) with the rest spilled
+// to temporary files.
+type ScanOptions struct {
+	Limit      int
+	Offset     int
+	Reverse    bool
+	Projection []string
+	OrderBy    *OrderBy
+	SortMemory int
+}
+
+// errScanPageFull stops an index walk once a page has been collected.
+var errScanPageFull = goerrors.New("storage: scan page full")
+
+type reverseRangeScanner interface {
+	ScanReverse(start, end types.Comparable, fn func(key types.Comparable, value int64) error) error
+	ScanAllReverse(fn func(key types.Comparable, value int64) error) error
+}
+
+// scanPage returns the ScanOptions of a Scan call; the last one given
+// wins.
+func scanPage(opts []ScanOptions) ScanOptions {
+	var page ScanOptions
+	for _, opt := range opts {
+		page = opt
+	}
+	if page.Offset < 0 {
+		page.Offset = 0
+	}
+	return page
+}
+
+// indexScanBounds is the part of an index a Scan walks: the keys of
+// points, one seek each, when pointSeek; otherwise [start, end], with a
+// nil start meaning the whole index. empty means nothing can match.
+type indexScanBounds struct {
+	pointSeek  bool
+	points     []types.Comparable
+	start, end types.Comparable
+	empty      bool
+}
+
+// scanBounds reduces condition to the index keys that can match it. An
+// index that is not nullable holds no NULL key, so seeks for NULL are
+// dropped.
+func scanBounds(index *Index, condition *query.ScanCondition) indexScanBounds {
+	var bounds indexScanBounds
+	if condition == nil {
+		return bounds
+	}
+	if points, ok := condition.KeyPoints(); ok {
+		if !index.Nullable {
+			points = slices.DeleteFunc(slices.Clone(points), isNullKey)
+		}
+		bounds.pointSeek, bounds.points = true, points
+		bounds.empty = len(points) == 0
+		return bounds
+	}
+	bounds.start, bounds.end, _ = condition.KeyRange()
+	bounds.empty = !index.Nullable && (isNullKey(bounds.start) || isNullKey(bounds.end))
+	return bounds
+}
+
+// seekScanner is a tree that can start an open-ended walk at a key, which
+// lets a resumed scan (ScanPage) seek to its cursor.
+type seekScanner interface {
+	ScanFrom(start types.Comparable, fn func(key types.Comparable, value int64) error) error
+	ScanReverseFrom(end types.Comparable, fn func(key types.Comparable, value int64) error) error
+}
+
+// scanIndexRange walks the part of the index that can match condition,
+// in ascending or descending key order. visit still has to filter with
+// condition.Matches, since only part of a condition becomes a range.
+func scanIndexRange(index *Index, scanner rangeScanner, condition *query.ScanCondition, reverse bool, visit func(key types.Comparable, value int64) error) error {
+	return scanIndexRangeFrom(index, scanner, condition, reverse, nil, visit)
+}
+
+// scanIndexRangeFrom is scanIndexRange starting at the key from (ending
+// at it when reverse); nil from walks the whole range. Entries before from
+// may still be visited when the tree cannot seek, so visit filters them.
+func scanIndexRangeFrom(index *Index, scanner rangeScanner, condition *query.ScanCondition, reverse bool, from types.Comparable, visit func(key types.Comparable, value int64) error) error {
+	bounds := scanBounds(index, condition)
+	switch {
+	case bounds.empty:
+		return nil
+	case bounds.pointSeek:
+		points := bounds.points
+		if from != nil {
+			points = slices.DeleteFunc(slices.Clone(points), func(point types.Comparable) bool {
+				return keyPassed(point, from, reverse)
+			})
+		}
+		return scanIndexPoints(scanner, points, reverse, visit)
+	}
+	start, end := bounds.start, bounds.end
+	if from != nil {
+		if !reverse && (start == nil || keyPassed(start, from, false)) {
+			start = from
+		}
+		if reverse && (end == nil || keyPassed(end, from, true)) {
+			end = from
+		}
+	}
+	seeker, canSeek := scanner.(seekScanner)
+
+	if !reverse {
+		switch {
+		case start != nil && end != nil:
+			return scanner.Scan(start, end, visit)
+		case start != nil && canSeek:
+			return seeker.ScanFrom(start, visit)
+		}
+		return scanner.ScanAll(visit)
+	}
+
+	backward, ok := scanner.(reverseRangeScanner)
+	if !ok {
+		return fmt.Errorf("storage: index type %T cannot be scanned in reverse", scanner)
+	}
+	switch {
+	case start != nil && end != nil:
+		return backward.ScanReverse(start, end, visit)
+	case end != nil && canSeek:
+		return seeker.ScanReverseFrom(end, visit)
+	}
+	return backward.ScanAllReverse(visit)
+}
+
+// keyPassed reports whether a walk in the given direction meets key
+// before from.
+func keyPassed(key, from types.Comparable, reverse bool) bool {
+	if reverse {
+		return key.Compare(from) > 0
+	}
+	return key.Compare(from) < 0
+}
+
+func isNullKey(key types.Comparable) bool {
+	_, null := key.(types.NullKey)
+	return null
+}
+
+// scanIndexPoints seeks each key of points (sorted ascending) in turn and
+// visits every entry stored under it, so a multi-value index reports every
+// row of each key.
+func scanIndexPoints(scanner rangeScanner, points []types.Comparable, reverse bool, visit func(key types.Comparable, value int64) error) error {
+	if !reverse {
+		for _, point := range points {
+			if err := scanner.Scan(point, point, visit); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	backward, ok := scanner.(reverseRangeScanner)
+	if !ok {
+		return fmt.Errorf("storage: index type %T cannot be scanned in reverse", scanner)
+	}
+	for i := len(points) - 1; i >= 0; i-- {
+		if err := backward.ScanReverse(points[i], points[i], visit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchesDocument evaluates the field conditions of condition against a
+// stored document.
+func matchesDocument(condition *query.ScanCondition, key types.Comparable, raw []byte) (bool, error) {
+	doc, err := storedDocumentBson(raw)
+	if err != nil {
+		return false, fmt.Errorf("storage: scan filter: %w", err)
+	}
+	return condition.MatchesDocument(key, func(name string) (types.Comparable, bool) {
+		value, err := GetValueFromBson(doc, name)
+		return value, err == nil
+	}), nil
+}
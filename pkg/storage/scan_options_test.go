@@ -0,0 +1,290 @@
+package storage_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+func openUsersEngine(t *testing.T, rows int) *storage.StorageEngine {
+	t.Helper()
+	hm, err := storage.NewHeapForTable(storage.HeapFormatV2, filepath.Join(t.TempDir(), "heap.data"))
+	if err != nil {
+		t.Fatalf("Failed to create heap: %v", err)
+	}
+	tableMgr := storage.NewTableMenager()
+	if err := tableMgr.NewTable("users", []storage.Index{
+		{Name: "id", Primary: true, Type: storage.TypeInt},
+	}, 3, hm); err != nil {
+		t.Fatalf("NewTable failed: %v", err)
+	}
+	se, err := storage.NewStorageEngine(tableMgr, nil)
+	if err != nil {
+		t.Fatalf("NewStorageEngine failed: %v", err)
+	}
+	for i := 1; i <= rows; i++ {
+		if err := se.Put("users", "id", types.IntKey(i), fmt.Sprintf("user_%d", i)); err != nil {
+			t.Fatalf("Put %d: %v", i, err)
+		}
+	}
+	return se
+}
+
+func TestScan_LimitOffset(t *testing.T) {
+	se := openUsersEngine(t, 50)
+	defer se.Close()
+
+	cases := []struct {
+		name string
+		cond *query.ScanCondition
+		opts storage.ScanOptions
+		want []string
+	}{
+		{"first page", nil, storage.ScanOptions{Limit: 3}, []string{"user_1", "user_2", "user_3"}},
+		{"second page", nil, storage.ScanOptions{Limit: 3, Offset: 3}, []string{"user_4", "user_5", "user_6"}},
+		{"offset past end", nil, storage.ScanOptions{Offset: 60}, []string{}},
+		{"reverse", nil, storage.ScanOptions{Limit: 2, Reverse: true}, []string{"user_50", "user_49"}},
+		{"reverse between", query.Between(types.IntKey(10), types.IntKey(20)), storage.ScanOptions{Limit: 3, Offset: 1, Reverse: true}, []string{"user_19", "user_18", "user_17"}},
+		{"reverse filter", query.LessThan(types.IntKey(5)), storage.ScanOptions{Reverse: true}, []string{"user_4", "user_3", "user_2", "user_1"}},
+	}
+	for _, tc := range cases {
+		got, err := se.Scan("users", "id", tc.cond, tc.opts)
+		if err != nil {
+			t.Fatalf("%s: Scan failed: %v", tc.name, err)
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Fatalf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestScan_OffsetCountsVisibleRows(t *testing.T) {
+	se := openUsersEngine(t, 10)
+	defer se.Close()
+
+	for _, id := range []int{2, 3} {
+		if _, err := se.Del("users", "id", types.IntKey(id)); err != nil {
+			t.Fatalf("Del %d: %v", id, err)
+		}
+	}
+	got, err := se.Scan("users", "id", nil, storage.ScanOptions{Limit: 2, Offset: 1})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if want := []string{"user_4", "user_5"}; !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestScan_ReverseSecondaryIndex(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+
+	putEmployee(t, se, 1, "Engineering")
+	putEmployee(t, se, 2, "Sales")
+	putEmployee(t, se, 3, "Marketing")
+	putEmployee(t, se, 4, "Sales")
+
+	docs, err := se.Scan("employees", "department", nil, storage.ScanOptions{Limit: 2, Reverse: true})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	assertIDs(t, "last two departments", docs, "2", "4")
+}
+
+func TestScan_CompositeConditionWithFieldFilter(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+
+	rows := []struct {
+		id     int64
+		dept   string
+		salary int
+	}{
+		{1, "Eng", 90000},
+		{2, "Eng", 70000},
+		{3, "Sales", 95000},
+		{4, "Eng", 85000},
+		{5, "Ops", 60000},
+	}
+	for _, r := range rows {
+		doc := fmt.Sprintf(`{"id": %d, "department": "%s", "salary": %d}`, r.id, r.dept, r.salary)
+		if err := se.InsertRow("employees", doc, nil); err != nil {
+			t.Fatalf("InsertRow %d: %v", r.id, err)
+		}
+	}
+
+	docs, err := se.Scan("employees", "department", query.And(
+		query.Equal(types.VarcharKey("Eng")),
+		query.Field("salary", query.GreaterThan(types.IntKey(80000))),
+	))
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	assertIDs(t, "Eng AND salary > 80000", docs, "1", "4")
+
+	docs, err = se.Scan("employees", "id", query.Or(
+		query.Field("department", query.Equal(types.VarcharKey("Ops"))),
+		query.Not(query.Field("salary", query.LessThan(types.IntKey(90000)))),
+	))
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	assertIDs(t, "Ops OR salary >= 90000", docs, "1", "3", "5")
+}
+
+func TestScan_InList(t *testing.T) {
+	se := openUsersEngine(t, 100)
+	defer se.Close()
+
+	in := query.In(types.IntKey(99), types.IntKey(3), types.IntKey(17), types.IntKey(500))
+	got, err := se.Scan("users", "id", in)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if want := []string{"user_3", "user_17", "user_99"}; !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	got, err = se.Scan("users", "id", in, storage.ScanOptions{Limit: 2, Reverse: true})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if want := []string{"user_99", "user_17"}; !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestScan_InListOnSecondaryIndexReturnsEveryRow(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+
+	putEmployee(t, se, 1, "Engineering")
+	putEmployee(t, se, 2, "Sales")
+	putEmployee(t, se, 3, "Engineering")
+	putEmployee(t, se, 4, "Marketing")
+	putEmployee(t, se, 5, "Ops")
+
+	docs, err := se.Scan("employees", "department", query.In(types.VarcharKey("Ops"), types.VarcharKey("Engineering")))
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	assertIDs(t, "Engineering or Ops", docs, "1", "3", "5")
+}
+
+func TestGetAndScan_Projection(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+
+	for id, city := range map[int]string{1: "Lisbon", 2: "Porto"} {
+		doc := fmt.Sprintf(`{"id": %d, "department": "Eng", "salary": 100, "address": {"city": "%s", "zip": "1000"}}`, id, city)
+		if err := se.InsertRow("employees", doc, nil); err != nil {
+			t.Fatalf("InsertRow %d: %v", id, err)
+		}
+	}
+
+	got, found, err := se.Get("employees", "id", types.IntKey(1), "id", "address.city")
+	if err != nil || !found {
+		t.Fatalf("Get: found=%v err=%v", found, err)
+	}
+	if want := `{"id":1,"address":{"city":"Lisbon"}}`; got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+
+	docs, err := se.Scan("employees", "department", query.Equal(types.VarcharKey("Eng")),
+		storage.ScanOptions{Projection: []string{"address.city"}})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	slices.Sort(docs)
+	if want := []string{`{"address":{"city":"Lisbon"}}`, `{"address":{"city":"Porto"}}`}; !slices.Equal(docs, want) {
+		t.Fatalf("expected %v, got %v", want, docs)
+	}
+}
+
+// TestScan_OrderBySpillsToDisk sorts by a field outside the index with a
+// budget small enough to spill many runs, and checks the order (missing
+// fields first, ties in index order), the page and the cleanup of tmp/.
+func TestScan_OrderBySpillsToDisk(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	se, err := storage.Open(dir, storage.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer se.Close()
+	if err := se.CreateTable("t", []storage.Index{{Name: "id", Primary: true, Type: storage.TypeInt}}); err != nil {
+		t.Fatal(err)
+	}
+	const rows = 300
+	scores := make(map[int]int)
+	for id := 0; id < rows; id++ {
+		doc := fmt.Sprintf(`{"id": %d}`, id)
+		if id%7 != 0 {
+			scores[id] = (id * 37) % 50
+			doc = fmt.Sprintf(`{"id": %d, "score": %d}`, id, scores[id])
+		}
+		if err := se.InsertRow("t", doc, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	order := func(desc bool) []string {
+		ids := make([]int, rows)
+		for i := range ids {
+			ids[i] = i
+		}
+		score := func(id int) int {
+			if s, ok := scores[id]; ok {
+				return s
+			}
+			return -1
+		}
+		slices.SortStableFunc(ids, func(a, b int) int {
+			if desc {
+				return score(b) - score(a)
+			}
+			return score(a) - score(b)
+		})
+		want := make([]string, rows)
+		for i, id := range ids {
+			want[i] = fmt.Sprintf(`{"id":%d}`, id)
+		}
+		return want
+	}
+
+	for _, desc := range []bool{false, true} {
+		opts := storage.ScanOptions{
+			OrderBy:    &storage.OrderBy{Field: "score", Desc: desc},
+			SortMemory: 256,
+			Projection: []string{"id"},
+		}
+		got, err := se.Scan("t", "id", nil, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := order(desc); !slices.Equal(got, want) {
+			t.Fatalf("desc=%v: got %v, want %v", desc, got, want)
+		}
+
+		opts.Offset, opts.Limit = 95, 10
+		got, err = se.Scan("t", "id", query.GreaterOrEqual(types.IntKey(0)), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := order(desc)[95:105]; !slices.Equal(got, want) {
+			t.Fatalf("desc=%v page: got %v, want %v", desc, got, want)
+		}
+	}
+
+	if entries, err := os.ReadDir(filepath.Join(dir, "tmp")); err != nil || len(entries) != 0 {
+		t.Fatalf("tmp/ after the sort = %v, %v", entries, err)
+	}
+	if _, _, err := se.ScanPage("t", "id", nil, nil, storage.ScanOptions{OrderBy: &storage.OrderBy{Field: "score"}}); err == nil {
+		t.Fatal("ScanPage with OrderBy should fail")
+	}
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	goerrors "errors"
+	"fmt"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/tracing"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// ScanCursor marks the index entry of the last row a ScanPage returned;
+// the next page starts right after it.
+type ScanCursor struct {
+	Key types.Comparable
+	// Offset is the heap offset the entry pointed to. It orders the rows
+	// of one key in an index that is not unique.
+	Offset int64
+}
+
+// ErrBadScanToken is returned for a page token ParseScanToken cannot read.
+var ErrBadScanToken = goerrors.New("storage: invalid scan page token")
+
+// Token encodes the cursor as an opaque, URL-safe page token: base64url
+// of the varint offset followed by the key as a Key message.
+func (c *ScanCursor) Token() (string, error) {
+	pk, err := KeyToProto(c.Key)
+	if err != nil {
+		return "", err
+	}
+	key, err := proto.Marshal(pk)
+	if err != nil {
+		return "", err
+	}
+	token := binary.AppendVarint(nil, c.Offset)
+	return base64.RawURLEncoding.EncodeToString(append(token, key...)), nil
+}
+
+// ParseScanToken is the inverse of ScanCursor.Token. It fails with
+// ErrBadScanToken.
+func ParseScanToken(token string) (*ScanCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrBadScanToken
+	}
+	offset, n := binary.Varint(data)
+	if n <= 0 {
+		return nil, ErrBadScanToken
+	}
+	var pk Key
+	if err := proto.Unmarshal(data[n:], &pk); err != nil {
+		return nil, ErrBadScanToken
+	}
+	key, err := KeyFromProto(&pk)
+	if err != nil || key == nil {
+		return nil, ErrBadScanToken
+	}
+	return &ScanCursor{Key: key, Offset: offset}, nil
+}
+
+// before reports whether the entry (key, offset) comes after the cursor in
+// the walk direction, i.e. was not returned by an earlier page. A unique
+// index holds one entry per key, so its cursor key is done as a whole.
+func (c *ScanCursor) before(key types.Comparable, offset int64, multiValue, reverse bool) bool {
+	cmp := key.Compare(c.Key)
+	if reverse {
+		cmp = -cmp
+	}
+	switch {
+	case cmp > 0:
+		return true
+	case cmp < 0 || !multiValue:
+		return false
+	}
+	if reverse {
+		return offset < c.Offset
+	}
+	return offset > c.Offset
+}
+
+// ScanPage is Scan with keyset pagination: it returns up to opts.Limit rows
+// after cursor (nil starts from the beginning) and the cursor of the next
+// page, nil once the scan is over. Unlike Offset, a page seeks straight to
+// its cursor, so deep pages cost the same as the first one, and rows
+// written between pages do not shift the pages already read. Each page is
+// a fresh snapshot. A multikey index may return a row again on a later
+// page under another of its keys.
+func (se *StorageEngine) ScanPage(tableName string, indexName string, condition *query.ScanCondition, cursor *ScanCursor, opts ScanOptions) ([]string, *ScanCursor, error) {
+	return se.ScanPageCtx(context.Background(), tableName, indexName, condition, cursor, opts)
+}
+
+// ScanPageCtx is ScanPage with ctx.
+func (se *StorageEngine) ScanPageCtx(ctx context.Context, tableName string, indexName string, condition *query.ScanCondition, cursor *ScanCursor, opts ScanOptions) (_ []string, _ *ScanCursor, err error) {
+	ctx, span := se.startSpan(ctx, "storage.ScanPage", tableName, indexName)
+	defer func() { tracing.End(span, err) }()
+	if opts.OrderBy != nil {
+		return nil, nil, fmt.Errorf("storage: ScanPage cannot sort by %q: a page cursor follows the index", opts.OrderBy.Field)
+	}
+
+	// BeginRead takes opMu, so it comes before the lock.
+	tx := se.BeginRead()
+	defer tx.Close()
+
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err = se.runtimeReadyError(); err != nil {
+		return nil, nil, err
+	}
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, next, err := tx.scanTableFrom(ctx, table, indexName, condition, cursor, opts)
+	if tracing.Recording(span) {
+		span.SetAttribute("storage.rows", len(rows))
+	}
+	return rows, next, err
+}
+
+// ScanPageToken is ScanPage with the cursor carried as a page token, for
+// APIs that hand it to clients: token "" starts from the beginning, and
+// the returned token is "" once the scan is over. The token holds only
+// the position of the last row, so the next page must repeat the index,
+// condition and options.
+func (se *StorageEngine) ScanPageToken(tableName string, indexName string, condition *query.ScanCondition, token string, opts ScanOptions) ([]string, string, error) {
+	return se.ScanPageTokenCtx(context.Background(), tableName, indexName, condition, token, opts)
+}
+
+// ScanPageTokenCtx is ScanPageToken with ctx.
+func (se *StorageEngine) ScanPageTokenCtx(ctx context.Context, tableName string, indexName string, condition *query.ScanCondition, token string, opts ScanOptions) ([]string, string, error) {
+	var cursor *ScanCursor
+	if token != "" {
+		var err error
+		if cursor, err = ParseScanToken(token); err != nil {
+			return nil, "", err
+		}
+	}
+	rows, next, err := se.ScanPageCtx(ctx, tableName, indexName, condition, cursor, opts)
+	if err != nil || next == nil {
+		return rows, "", err
+	}
+	nextToken, err := next.Token()
+	return rows, nextToken, err
+}
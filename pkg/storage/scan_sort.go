@@ -0,0 +1,318 @@
+package storage
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+
+	"github.com/bobboyms/storage-engine/pkg/crypto"
+	"github.com/bobboyms/storage-engine/pkg/pagestore"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// OrderBy sorts the rows of a Scan by a document field instead of by the
+// index key.
+type OrderBy struct {
+	// Field is the field to sort by; dotted paths reach nested fields.
+	Field string
+	Desc  bool
+}
+
+// DefaultSortMemory is the memory budget of an OrderBy sort when
+// ScanOptions.SortMemory is zero.
+const DefaultSortMemory = 64 << 20
+
+// An OrderBy scan reads every matching row before it returns the first
+// one. The rows go into a run kept in memory; once the documents in it
+// pass the budget, the run is sorted and written to a file in the data
+// directory's tmp/ (os.TempDir for an in-memory engine), encrypted with
+// the engine's cipher when there is one. At the end the runs are merged,
+// so only one row per run is held at a time. Rows that sort equal keep
+// the order of the index walk: within a run the sort is stable, and the
+// merge breaks ties by run, and runs are written in walk order.
+
+// externalSort sorts stored documents by one field, spilling sorted runs
+// to temporary files past its memory budget.
+type externalSort struct {
+	order  OrderBy
+	budget int
+	dir    string
+	cipher crypto.Cipher
+
+	rows []sortRow
+	size int
+	runs []*os.File
+}
+
+type sortRow struct {
+	key types.Comparable
+	raw []byte
+}
+
+func (se *StorageEngine) newExternalSort(page ScanOptions) *externalSort {
+	s := &externalSort{order: *page.OrderBy, budget: page.SortMemory, dir: os.TempDir(), cipher: se.cipher}
+	if s.budget <= 0 {
+		s.budget = DefaultSortMemory
+	}
+	if se.dataDir != nil && !pagestore.IsMemPath(se.dataDir.Root()) {
+		s.dir = se.dataDir.TempDir()
+	}
+	return s
+}
+
+// add takes the stored document raw; the sort keeps it.
+func (s *externalSort) add(raw []byte) error {
+	key, err := sortKey(raw, s.order.Field)
+	if err != nil {
+		return err
+	}
+	s.rows = append(s.rows, sortRow{key: key, raw: raw})
+	s.size += len(raw)
+	if s.size >= s.budget {
+		return s.spill()
+	}
+	return nil
+}
+
+func (s *externalSort) less(a, b types.Comparable) bool {
+	c := compareSortKeys(a, b)
+	if s.order.Desc {
+		return c > 0
+	}
+	return c < 0
+}
+
+// spill sorts the rows in memory and writes them as a new run.
+func (s *externalSort) spill() error {
+	s.sortRows()
+	f, err := os.CreateTemp(s.dir, "sort-*")
+	if err != nil {
+		return fmt.Errorf("storage: sort spill: %w", err)
+	}
+	s.runs = append(s.runs, f)
+	w := bufio.NewWriter(f)
+	var lenBuf [binary.MaxVarintLen64]byte
+	for _, row := range s.rows {
+		data := row.raw
+		if s.cipher != nil {
+			if data, err = s.cipher.Encrypt(data, nil); err != nil {
+				return fmt.Errorf("storage: sort spill: %w", err)
+			}
+		}
+		w.Write(lenBuf[:binary.PutUvarint(lenBuf[:], uint64(len(data)))])
+		w.Write(data)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("storage: sort spill: %w", err)
+	}
+	s.rows, s.size = nil, 0
+	return nil
+}
+
+func (s *externalSort) sortRows() {
+	sort.SliceStable(s.rows, func(i, j int) bool { return s.less(s.rows[i].key, s.rows[j].key) })
+}
+
+// spilledRuns returns how many runs were written to files.
+func (s *externalSort) spilledRuns() int { return len(s.runs) }
+
+// each calls fn with every document in sorted order until fn returns an
+// error; errScanPageFull stops it without error.
+func (s *externalSort) each(ctx context.Context, fn func(raw []byte) error) error {
+	s.sortRows()
+	merge := &sortMerge{less: s.less}
+	for i, f := range s.runs {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("storage: sort merge: %w", err)
+		}
+		src := &runReader{r: bufio.NewReader(f), cipher: s.cipher, field: s.order.Field}
+		if err := merge.push(i, src); err != nil {
+			return err
+		}
+	}
+	if len(s.rows) > 0 {
+		if err := merge.push(len(s.runs), &memoryRun{rows: s.rows}); err != nil {
+			return err
+		}
+	}
+
+	for merge.Len() > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		head := merge.heads[0]
+		if err := fn(head.row.raw); err != nil {
+			if err == errScanPageFull {
+				return nil
+			}
+			return err
+		}
+		row, ok, err := head.src.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			head.row = row
+			heap.Fix(merge, 0)
+		} else {
+			heap.Pop(merge)
+		}
+	}
+	return nil
+}
+
+// close removes the spilled runs.
+func (s *externalSort) close() {
+	for _, f := range s.runs {
+		f.Close()
+		os.Remove(f.Name())
+	}
+	s.runs, s.rows = nil, nil
+}
+
+// sortSource yields the rows of one sorted run.
+type sortSource interface {
+	next() (sortRow, bool, error)
+}
+
+type memoryRun struct {
+	rows []sortRow
+	pos  int
+}
+
+func (m *memoryRun) next() (sortRow, bool, error) {
+	if m.pos >= len(m.rows) {
+		return sortRow{}, false, nil
+	}
+	m.pos++
+	return m.rows[m.pos-1], true, nil
+}
+
+type runReader struct {
+	r      *bufio.Reader
+	cipher crypto.Cipher
+	field  string
+}
+
+func (r *runReader) next() (sortRow, bool, error) {
+	n, err := binary.ReadUvarint(r.r)
+	if err == io.EOF {
+		return sortRow{}, false, nil
+	}
+	if err != nil {
+		return sortRow{}, false, fmt.Errorf("storage: sort merge: %w", err)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return sortRow{}, false, fmt.Errorf("storage: sort merge: %w", err)
+	}
+	if r.cipher != nil {
+		if data, err = r.cipher.Decrypt(data, nil); err != nil {
+			return sortRow{}, false, fmt.Errorf("storage: sort merge: %w", err)
+		}
+	}
+	key, err := sortKey(data, r.field)
+	if err != nil {
+		return sortRow{}, false, err
+	}
+	return sortRow{key: key, raw: data}, true, nil
+}
+
+// sortMerge is a heap of the current row of every run.
+type sortMerge struct {
+	heads []*mergeHead
+	less  func(a, b types.Comparable) bool
+}
+
+type mergeHead struct {
+	row sortRow
+	run int
+	src sortSource
+}
+
+func (m *sortMerge) push(run int, src sortSource) error {
+	row, ok, err := src.next()
+	if err != nil || !ok {
+		return err
+	}
+	heap.Push(m, &mergeHead{row: row, run: run, src: src})
+	return nil
+}
+
+func (m *sortMerge) Len() int { return len(m.heads) }
+
+func (m *sortMerge) Less(i, j int) bool {
+	a, b := m.heads[i], m.heads[j]
+	if m.less(a.row.key, b.row.key) {
+		return true
+	}
+	if m.less(b.row.key, a.row.key) {
+		return false
+	}
+	return a.run < b.run
+}
+
+func (m *sortMerge) Swap(i, j int) { m.heads[i], m.heads[j] = m.heads[j], m.heads[i] }
+
+func (m *sortMerge) Push(x any) { m.heads = append(m.heads, x.(*mergeHead)) }
+
+func (m *sortMerge) Pop() any {
+	last := m.heads[len(m.heads)-1]
+	m.heads = m.heads[:len(m.heads)-1]
+	return last
+}
+
+// sortKey reads field from a stored document; a missing field reads as
+// NULL.
+func sortKey(raw []byte, field string) (types.Comparable, error) {
+	doc, err := storedDocumentBson(raw)
+	if err != nil {
+		return nil, fmt.Errorf("storage: order by: %w", err)
+	}
+	if value, err := GetValueFromBson(doc, field); err == nil {
+		return value, nil
+	}
+	return types.NullKey{}, nil
+}
+
+// compareSortKeys orders document values for OrderBy: NULL (or a missing
+// field) first, numbers by value, other values of one type by their key
+// order and values of different types by type name.
+func compareSortKeys(a, b types.Comparable) int {
+	_, aNull := a.(types.NullKey)
+	_, bNull := b.(types.NullKey)
+	if aNull || bNull {
+		return boolOrder(bNull) - boolOrder(aNull)
+	}
+	if x, ok := a.(types.IntKey); ok {
+		if y, ok := b.(types.FloatKey); ok {
+			return types.FloatKey(x).Compare(y)
+		}
+	}
+	if x, ok := a.(types.FloatKey); ok {
+		if y, ok := b.(types.IntKey); ok {
+			return x.Compare(types.FloatKey(y))
+		}
+	}
+	ta, tb := reflect.TypeOf(a).String(), reflect.TypeOf(b).String()
+	if ta != tb {
+		if ta < tb {
+			return -1
+		}
+		return 1
+	}
+	return a.Compare(b)
+}
+
+func boolOrder(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
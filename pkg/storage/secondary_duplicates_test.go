@@ -0,0 +1,169 @@
+package storage_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+func openEmployeesEngine(t *testing.T, dir string) *storage.StorageEngine {
+	t.Helper()
+	hm, err := storage.NewHeapForTable(storage.HeapFormatV2, filepath.Join(dir, "heap.data"))
+	if err != nil {
+		t.Fatalf("Failed to create heap: %v", err)
+	}
+	tableMgr := storage.NewTableMenager()
+	if err := tableMgr.NewTable("employees", []storage.Index{
+		{Name: "id", Primary: true, Type: storage.TypeInt},
+		{Name: "department", Primary: false, Type: storage.TypeVarchar},
+	}, 3, hm); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	walWriter, err := wal.NewWALWriter(filepath.Join(dir, "wal.log"), wal.DefaultOptions())
+	if err != nil {
+		t.Fatalf("Failed to create WAL: %v", err)
+	}
+	se, err := storage.NewStorageEngine(tableMgr, walWriter)
+	if err != nil {
+		walWriter.Close()
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	return se
+}
+
+func putEmployee(t *testing.T, se *storage.StorageEngine, id int64, dept string) {
+	t.Helper()
+	doc := fmt.Sprintf(`{"id": %d, "department": "%s"}`, id, dept)
+	err := se.UpsertRow("employees", doc, map[string]types.Comparable{
+		"id":         types.IntKey(id),
+		"department": types.VarcharKey(dept),
+	})
+	if err != nil {
+		t.Fatalf("UpsertRow %d: %v", id, err)
+	}
+}
+
+// employeeIDs extracts the sorted "id" values of the returned documents.
+func employeeIDs(t *testing.T, docs []string) []string {
+	t.Helper()
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		start := strings.Index(doc, `"id":`)
+		if start == -1 {
+			t.Fatalf("document without id: %s", doc)
+		}
+		rest := strings.TrimLeft(doc[start+len(`"id":`):], " ")
+		end := strings.IndexAny(rest, ",}")
+		ids = append(ids, strings.TrimSpace(rest[:end]))
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func assertIDs(t *testing.T, label string, docs []string, want ...string) {
+	t.Helper()
+	got := employeeIDs(t, docs)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("%s: expected ids %v, got %v", label, want, got)
+	}
+}
+
+func TestSecondaryIndex_DuplicateKeysReturnAllRows(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+
+	putEmployee(t, se, 1, "Engineering")
+	putEmployee(t, se, 2, "Engineering")
+	putEmployee(t, se, 3, "Sales")
+	putEmployee(t, se, 4, "Engineering")
+
+	docs, err := se.GetAll("employees", "department", types.VarcharKey("Engineering"))
+	if err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	assertIDs(t, "GetAll Engineering", docs, "1", "2", "4")
+
+	docs, err = se.Scan("employees", "department", query.Equal(types.VarcharKey("Engineering")))
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	assertIDs(t, "Scan Engineering", docs, "1", "2", "4")
+
+	if _, found, _ := se.Get("employees", "department", types.VarcharKey("Sales")); !found {
+		t.Fatal("Get on a secondary key should return one matching row")
+	}
+
+	// The primary index stays unique.
+	docs, err = se.GetAll("employees", "id", types.IntKey(3))
+	if err != nil {
+		t.Fatalf("GetAll on primary failed: %v", err)
+	}
+	assertIDs(t, "GetAll id=3", docs, "3")
+}
+
+func TestSecondaryIndex_DuplicateKeysFollowUpdatesAndDeletes(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+
+	putEmployee(t, se, 1, "Engineering")
+	putEmployee(t, se, 2, "Engineering")
+	putEmployee(t, se, 3, "Sales")
+
+	before := se.BeginRead()
+	defer before.Close()
+
+	// Moving a row between departments and rewriting a row in place.
+	putEmployee(t, se, 2, "Sales")
+	putEmployee(t, se, 1, "Engineering")
+
+	docs, _ := se.GetAll("employees", "department", types.VarcharKey("Engineering"))
+	assertIDs(t, "Engineering after update", docs, "1")
+	docs, _ = se.GetAll("employees", "department", types.VarcharKey("Sales"))
+	assertIDs(t, "Sales after update", docs, "2", "3")
+
+	// An older snapshot keeps its view.
+	docs, _ = before.GetAll("employees", "department", types.VarcharKey("Engineering"))
+	assertIDs(t, "snapshot Engineering", docs, "1", "2")
+
+	found, err := se.Del("employees", "department", types.VarcharKey("Sales"))
+	if err != nil || !found {
+		t.Fatalf("Del by department: found=%v err=%v", found, err)
+	}
+	docs, _ = se.GetAll("employees", "department", types.VarcharKey("Sales"))
+	assertIDs(t, "Sales after delete", docs)
+	docs, _ = before.GetAll("employees", "department", types.VarcharKey("Sales"))
+	assertIDs(t, "snapshot Sales", docs, "3")
+}
+
+func TestSecondaryIndex_DuplicateKeysSurviveRecovery(t *testing.T) {
+	dir := t.TempDir()
+	se := openEmployeesEngine(t, dir)
+	putEmployee(t, se, 1, "Engineering")
+	putEmployee(t, se, 2, "Engineering")
+	putEmployee(t, se, 3, "Engineering")
+	putEmployee(t, se, 3, "Sales")
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	se2 := openEmployeesEngine(t, dir)
+	defer se2.Close()
+	if err := se2.Recover(filepath.Join(dir, "wal.log")); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+
+	docs, err := se2.GetAll("employees", "department", types.VarcharKey("Engineering"))
+	if err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	assertIDs(t, "recovered Engineering", docs, "1", "2")
+	docs, _ = se2.GetAll("employees", "department", types.VarcharKey("Sales"))
+	assertIDs(t, "recovered Sales", docs, "3")
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+// SequenceCacheSize is how many values NextSequence reserves per WAL
+// record. A crash loses at most the unused part of one reservation per
+// sequence, so the gaps it leaves stay below this size.
+const SequenceCacheSize = 32
+
+// Sequences hand out dense auto-increment integers, e.g. for primary
+// keys. Values are reserved in blocks: an EntrySequence record holds the
+// high-water mark of a sequence and is fsynced before any value of the
+// block is returned, so no value is handed out twice across a crash.
+// Recovery restarts each sequence at its last high-water mark. Every
+// fuzzy checkpoint logs the marks again, so truncating the WAL behind it
+// does not lose them.
+
+type sequence struct {
+	next  uint64 // next value to return
+	limit uint64 // values below limit are reserved in the WAL
+}
+
+type sequenceSet struct {
+	mu   sync.Mutex
+	seqs map[string]*sequence
+}
+
+func newSequenceSet() *sequenceSet {
+	return &sequenceSet{seqs: make(map[string]*sequence)}
+}
+
+// NextSequence returns the next value of the sequence name, starting at 1.
+// Sequences are created on first use. Values grow by one; after a crash
+// the sequence resumes past the values reserved before it, so it may skip
+// up to SequenceCacheSize-1 values.
+func (se *StorageEngine) NextSequence(name string) (int64, error) {
+	if name == "" {
+		return 0, fmt.Errorf("storage: sequence name is required")
+	}
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return 0, err
+	}
+
+	ss := se.sequences
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	seq, ok := ss.seqs[name]
+	if !ok {
+		seq = &sequence{next: 1, limit: 1}
+		ss.seqs[name] = seq
+	}
+	if seq.next >= seq.limit {
+		limit := seq.next + SequenceCacheSize
+		if err := se.logSequence(name, limit); err != nil {
+			return 0, err
+		}
+		seq.limit = limit
+	}
+	value := seq.next
+	seq.next++
+	return int64(value), nil
+}
+
+// logSequence makes the high-water mark limit of name durable.
+func (se *StorageEngine) logSequence(name string, limit uint64) error {
+	if se.WAL == nil {
+		return nil
+	}
+	if err := se.writeAutoCommitWAL(wal.EntrySequence, serializeSequenceEntry(name, limit), se.lsnTracker.Next()); err != nil {
+		return err
+	}
+	return se.WAL.Sync()
+}
+
+// logAllSequences logs the high-water mark of every sequence again, so
+// the WAL kept after a checkpoint still holds them.
+func (se *StorageEngine) logAllSequences() error {
+	ss := se.sequences
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	for name, seq := range ss.seqs {
+		if err := se.logSequence(name, seq.limit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restore applies an EntrySequence found by recovery. Marks only move
+// forward, so replaying old records is harmless.
+func (ss *sequenceSet) restore(payload []byte) error {
+	name, limit, err := deserializeSequenceEntry(payload)
+	if err != nil {
+		return err
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	seq, ok := ss.seqs[name]
+	if !ok {
+		ss.seqs[name] = &sequence{next: limit, limit: limit}
+		return nil
+	}
+	if limit > seq.limit {
+		seq.next, seq.limit = limit, limit
+	}
+	return nil
+}
+
+// serializeSequenceEntry: limit uint64 followed by the name.
+func serializeSequenceEntry(name string, limit uint64) []byte {
+	buf := binary.LittleEndian.AppendUint64(make([]byte, 0, 8+len(name)), limit)
+	return append(buf, name...)
+}
+
+func deserializeSequenceEntry(data []byte) (string, uint64, error) {
+	if len(data) < 8 {
+		return "", 0, fmt.Errorf("sequence entry too short: %d", len(data))
+	}
+	return string(data[8:]), binary.LittleEndian.Uint64(data[:8]), nil
+}
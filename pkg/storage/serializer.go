@@ -86,6 +86,44 @@ func DeserializeMultiIndexEntry(data []byte) (tableName string, keys map[string]
 	return
 }
 
+// SerializeBatchEntry packs MultiIndexEntry payloads into one WAL entry: a
+// uint32 count followed by each payload prefixed with its length.
+func SerializeBatchEntry(rows [][]byte) []byte {
+	size := 4
+	for _, row := range rows {
+		size += 4 + len(row)
+	}
+	buf := make([]byte, 4, size)
+	binary.LittleEndian.PutUint32(buf, uint32(len(rows)))
+	for _, row := range rows {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(row)))
+		buf = append(buf, row...)
+	}
+	return buf
+}
+
+// DeserializeBatchEntry returns the MultiIndexEntry payloads of a batch.
+func DeserializeBatchEntry(data []byte) ([][]byte, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("batch entry too short: %d", len(data))
+	}
+	count := int(binary.LittleEndian.Uint32(data[:4]))
+	data = data[4:]
+	rows := make([][]byte, 0, min(count, len(data)/4))
+	for i := 0; i < count; i++ {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("batch entry truncated at row %d", i)
+		}
+		rowLen := int(binary.LittleEndian.Uint32(data[:4]))
+		if len(data) < 4+rowLen {
+			return nil, fmt.Errorf("batch row %d truncated: len=%d data=%d", i, rowLen, len(data)-4)
+		}
+		rows = append(rows, data[4:4+rowLen])
+		data = data[4+rowLen:]
+	}
+	return rows, nil
+}
+
 func SerializeCompensationEntry(originalLSN uint64, originalEntryType uint8, originalPayload []byte, undoNextLSN uint64) []byte {
 	buf := make([]byte, compensationEntryHeaderSize+len(originalPayload))
 	binary.LittleEndian.PutUint64(buf[0:8], originalLSN)
@@ -114,6 +152,17 @@ func DeserializeCompensationEntry(data []byte) (originalLSN uint64, originalEntr
 	return
 }
 
+// KeyToProto converts key into the Key message the WAL uses, so services
+// built on the engine (pkg/server) can carry keys on the wire.
+func KeyToProto(key types.Comparable) (*Key, error) {
+	return serializeKeyToProto(key)
+}
+
+// KeyFromProto is the inverse of KeyToProto.
+func KeyFromProto(pk *Key) (types.Comparable, error) {
+	return deserializeKeyFromProto(pk)
+}
+
 func serializeKeyToProto(key types.Comparable) (*Key, error) {
 	if key == nil {
 		return &Key{}, nil
@@ -130,6 +179,27 @@ func serializeKeyToProto(key types.Comparable) (*Key, error) {
 		pk.Value = &Key_FloatValue{FloatValue: float64(k)}
 	case types.DateKey:
 		pk.Value = &Key_DateValue{DateValue: time.Time(k).UnixNano()}
+	case types.DecimalKey:
+		pk.Value = &Key_DecimalValue{DecimalValue: int64(k)}
+	case types.UUIDKey:
+		pk.Value = &Key_UuidValue{UuidValue: k[:]}
+	case types.BytesKey:
+		pk.Value = &Key_BytesValue{BytesValue: []byte(k)}
+	case types.NullKey:
+		pk.Value = &Key_NullValue{NullValue: true}
+	case types.ArrayKey:
+		list := &KeyList{Values: make([]*Key, len(k))}
+		for i, elem := range k {
+			if _, nested := elem.(types.ArrayKey); nested {
+				return nil, fmt.Errorf("unsupported nested array key")
+			}
+			pe, err := serializeKeyToProto(elem)
+			if err != nil {
+				return nil, err
+			}
+			list.Values[i] = pe
+		}
+		pk.Value = &Key_ListValue{ListValue: list}
 	default:
 		return nil, fmt.Errorf("unsupported key type: %T", k)
 	}
@@ -152,6 +222,29 @@ func deserializeKeyFromProto(pk *Key) (types.Comparable, error) {
 		return types.FloatKey(v.FloatValue), nil
 	case *Key_DateValue:
 		return types.DateKey(time.Unix(0, v.DateValue)), nil
+	case *Key_DecimalValue:
+		return types.DecimalKey(v.DecimalValue), nil
+	case *Key_UuidValue:
+		var u types.UUIDKey
+		if len(v.UuidValue) != len(u) {
+			return nil, fmt.Errorf("invalid uuid key length %d", len(v.UuidValue))
+		}
+		copy(u[:], v.UuidValue)
+		return u, nil
+	case *Key_BytesValue:
+		return types.BytesKey(v.BytesValue), nil
+	case *Key_NullValue:
+		return types.NullKey{}, nil
+	case *Key_ListValue:
+		key := make(types.ArrayKey, len(v.ListValue.GetValues()))
+		for i, pe := range v.ListValue.GetValues() {
+			elem, err := deserializeKeyFromProto(pe)
+			if err != nil {
+				return nil, err
+			}
+			key[i] = elem
+		}
+		return key, nil
 	default:
 		return nil, fmt.Errorf("unsupported key type in protobuf")
 	}
@@ -50,6 +50,13 @@ func TestSerializeDocumentEntry_Protobuf(t *testing.T) {
 			key:       types.DateKey(time.Now().Truncate(time.Millisecond)),
 			document:  []byte(`{"type": "click"}`),
 		},
+		{
+			name:      "ArrayKey",
+			tableName: "articles",
+			indexName: "tags",
+			key:       types.ArrayKey{types.VarcharKey("db"), types.VarcharKey("go")},
+			document:  []byte(`{"tags": ["db", "go"]}`),
+		},
 	}
 
 	for _, tc := range testCases {
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/tracing"
+)
+
+// ErrSnapshotTooOld is returned by SnapshotScan for an LSN older than the
+// versions Vacuum, PruneVersions or TruncateTable kept.
+var ErrSnapshotTooOld = errors.New("storage: snapshot older than the versions kept by vacuum")
+
+// SnapshotScan returns the rows of an index range as they were at asOfLSN,
+// reading the MVCC version chains like a transaction whose snapshot is
+// that LSN. It serves time-travel queries such as audits.
+//
+// asOfLSN cannot be newer than the current LSN. It fails with
+// ErrSnapshotTooOld when vacuum may already have freed versions that
+// snapshot needs; vacuum does not run while the scan does. A table's
+// VersionRetention keeps a window of past snapshots readable. The vacuum
+// horizon is kept in memory, so vacuums from before the engine was opened
+// are not accounted for.
+//
+// With a tracer (see SetTracer) it runs as the span "storage.SnapshotScan".
+func (se *StorageEngine) SnapshotScan(tableName string, indexName string, asOfLSN uint64, condition *query.ScanCondition, opts ...ScanOptions) (results []string, err error) {
+	ctx, span := se.startSpan(context.Background(), "storage.SnapshotScan", tableName, indexName)
+	defer func() {
+		if tracing.Recording(span) {
+			span.SetAttribute("storage.as_of_lsn", asOfLSN)
+			span.SetAttribute("storage.rows", len(results))
+		}
+		tracing.End(span, err)
+	}()
+
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return nil, err
+	}
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return nil, err
+	}
+	// Vacuum and PruneVersions take the exclusive table lock.
+	table.RLock()
+	defer table.RUnlock()
+	if err := se.snapshotLSNError(table, asOfLSN); err != nil {
+		return nil, err
+	}
+
+	tx := &Transaction{SnapshotLSN: asOfLSN, Level: RepeatableRead, engine: se}
+	return tx.scanTable(ctx, table, indexName, condition, opts...)
+}
+
+// CurrentLSN returns the LSN of the last write, the snapshot a new
+// transaction would read; pass it to SnapshotScan later to read the
+// tables as they are now.
+func (se *StorageEngine) CurrentLSN() uint64 {
+	return se.lsnTracker.Current()
+}
+
+// snapshotLSNError reports why the versions of table at lsn cannot be
+// read: lsn is ahead of the current LSN, or vacuum may have freed them.
+func (se *StorageEngine) snapshotLSNError(table *Table, lsn uint64) error {
+	if current := se.lsnTracker.Current(); lsn > current {
+		return fmt.Errorf("storage: snapshot LSN %d is ahead of the current LSN %d", lsn, current)
+	}
+	if horizon := table.vacuumHorizon.Load(); lsn < horizon {
+		return fmt.Errorf("%w: table %s, LSN %d < %d", ErrSnapshotTooOld, table.Name, lsn, horizon)
+	}
+	return nil
+}
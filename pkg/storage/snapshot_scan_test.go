@@ -0,0 +1,66 @@
+package storage_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+func TestSnapshotScan_ReadsPastVersions(t *testing.T) {
+	se, err := storage.Open(t.TempDir(), storage.Options{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer se.Close()
+	if err := se.CreateTable("users", []storage.Index{
+		{Name: "id", Primary: true, Type: storage.TypeInt},
+		{Name: "name", Type: storage.TypeVarchar},
+	}); err != nil {
+		t.Fatalf("CreateTable: %v", err)
+	}
+	for _, doc := range []string{`{"id": 1, "name": "ana"}`, `{"id": 2, "name": "bia"}`} {
+		if err := se.InsertRow("users", doc, nil); err != nil {
+			t.Fatalf("InsertRow: %v", err)
+		}
+	}
+	asOf := se.CurrentLSN()
+
+	if err := se.UpdateRow("users", `{"id": 1, "name": "ana maria"}`, nil); err != nil {
+		t.Fatalf("UpdateRow: %v", err)
+	}
+	if _, err := se.DeleteRow("users", types.IntKey(2)); err != nil {
+		t.Fatalf("DeleteRow: %v", err)
+	}
+	if err := se.InsertRow("users", `{"id": 3, "name": "caio"}`, nil); err != nil {
+		t.Fatalf("InsertRow: %v", err)
+	}
+
+	rows, err := se.SnapshotScan("users", "id", asOf, nil)
+	if err != nil {
+		t.Fatalf("SnapshotScan: %v", err)
+	}
+	want := []string{`{"id":1,"name":"ana"}`, `{"id":2,"name":"bia"}`}
+	if len(rows) != len(want) || rows[0] != want[0] || rows[1] != want[1] {
+		t.Fatalf("SnapshotScan = %v, want %v", rows, want)
+	}
+	rows, err = se.SnapshotScan("users", "id", asOf, query.Equal(types.IntKey(2)))
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("SnapshotScan with a condition = %v, %v", rows, err)
+	}
+	if rows, err := se.SnapshotScan("users", "id", se.CurrentLSN(), nil); err != nil || len(rows) != 2 {
+		t.Fatalf("SnapshotScan at the current LSN = %v, %v", rows, err)
+	}
+	if _, err := se.SnapshotScan("users", "id", se.CurrentLSN()+1, nil); err == nil {
+		t.Fatal("SnapshotScan past the current LSN should fail")
+	}
+
+	if err := se.Vacuum("users"); err != nil {
+		t.Fatalf("Vacuum: %v", err)
+	}
+	if _, err := se.SnapshotScan("users", "id", asOf, nil); !errors.Is(err, storage.ErrSnapshotTooOld) {
+		t.Fatalf("SnapshotScan after Vacuum = %v, want ErrSnapshotTooOld", err)
+	}
+}
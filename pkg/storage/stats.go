@@ -0,0 +1,251 @@
+package storage
+
+import (
+	goerrors "errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	v2 "github.com/bobboyms/storage-engine/pkg/heap/v2"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// histogramBuckets is how many buckets Analyze builds per index.
+const histogramBuckets = 16
+
+// TableStats are the statistics Analyze collected for a table. Rows and
+// AvgDocumentSize describe the rows visible when it ran; DeadTuples counts
+// heap records no longer visible (old versions and deleted rows) that
+// Vacuum has not reclaimed yet, or is -1 when the heap is shared with
+// another table.
+type TableStats struct {
+	Table           string
+	AnalyzedAt      time.Time
+	Rows            int64
+	DeadTuples      int64
+	AvgDocumentSize float64
+	Indexes         map[string]*IndexStats
+}
+
+// DeadTupleRatio is the share of heap records that are dead; 0 when
+// DeadTuples is unknown.
+func (s *TableStats) DeadTupleRatio() float64 {
+	if s.DeadTuples < 0 {
+		return 0
+	}
+	if total := s.Rows + s.DeadTuples; total > 0 {
+		return float64(s.DeadTuples) / float64(total)
+	}
+	return 0
+}
+
+// IndexStats describe the keys of the visible rows in one index. Keys of
+// a collated index are collation keys. A multikey index counts each row
+// once, under its smallest element.
+type IndexStats struct {
+	Entries      int64
+	DistinctKeys int64
+	NullKeys     int64
+	Histogram    []HistogramBucket
+}
+
+// HistogramBucket holds the keys in [Lower, Upper]. Buckets are
+// equi-depth: each holds about the same number of entries, and all
+// entries of one key fall in the same bucket.
+type HistogramBucket struct {
+	Lower        types.Comparable
+	Upper        types.Comparable
+	Entries      int64
+	DistinctKeys int64
+}
+
+type statsSet struct {
+	mu      sync.RWMutex
+	byTable map[string]*TableStats
+}
+
+func newStatsSet() *statsSet {
+	return &statsSet{byTable: make(map[string]*TableStats)}
+}
+
+func (ss *statsSet) get(tableName string) *TableStats {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	return ss.byTable[tableName]
+}
+
+func (ss *statsSet) put(stats *TableStats) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.byTable[stats.Table] = stats
+}
+
+func (ss *statsSet) forget(tableName string) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	delete(ss.byTable, tableName)
+}
+
+// Stats returns the statistics of the last Analyze of the table, or nil
+// when it was never analyzed. They are kept in memory only and are not
+// refreshed by writes: run Analyze again after large changes.
+func (se *StorageEngine) Stats(tableName string) *TableStats {
+	return se.stats.get(tableName)
+}
+
+// Analyze reads the whole table and records its statistics, returned
+// also by Stats and used by Explain to estimate row counts. Rows and
+// index keys are read from one snapshot; dead tuples are counted from
+// the heap afterwards, so under concurrent writes the figures are
+// approximate.
+func (se *StorageEngine) Analyze(tableName string) (*TableStats, error) {
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return nil, err
+	}
+	primary, err := primaryIndex(table)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &TableStats{Table: tableName, AnalyzedAt: time.Now(), Indexes: make(map[string]*IndexStats)}
+	tx := se.BeginRead()
+	defer tx.Close()
+	var totalSize int64
+	err = tx.walkVisibleRows(tableName, primary.Name, nil, false, true, func(_ types.Comparable, raw []byte) error {
+		stats.Rows++
+		totalSize += int64(len(raw))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("storage: analyze %s: %w", tableName, err)
+	}
+	if stats.Rows > 0 {
+		stats.AvgDocumentSize = float64(totalSize) / float64(stats.Rows)
+	}
+
+	for _, index := range table.GetIndices() {
+		var keys []types.Comparable
+		err := tx.walkVisibleRows(tableName, index.Name, nil, false, false, func(key types.Comparable, _ []byte) error {
+			keys = append(keys, key)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("storage: analyze %s: index %s: %w", tableName, index.Name, err)
+		}
+		stats.Indexes[index.Name] = indexStatsFromKeys(keys)
+	}
+
+	stats.DeadTuples = -1
+	if !se.TableMetaData.heapShared(table) {
+		records, err := se.heapRecordCount(table)
+		if err != nil {
+			return nil, fmt.Errorf("storage: analyze %s: %w", tableName, err)
+		}
+		stats.DeadTuples = max(records-stats.Rows, 0)
+	}
+
+	se.stats.put(stats)
+	return stats, nil
+}
+
+// heapRecordCount counts the heap records of table that Vacuum has not
+// reclaimed.
+func (se *StorageEngine) heapRecordCount(table *Table) (int64, error) {
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return 0, err
+	}
+	heapV2, ok := table.Heap.(*v2.HeapV2)
+	if !ok {
+		return 0, fmt.Errorf("table %s must use HeapV2", table.Name)
+	}
+	table.RLock()
+	defer table.RUnlock()
+	var records int64
+	err := heapV2.ScanRecords(func(_ int64, _ *v2.RecordHeader, err error) error {
+		if !goerrors.Is(err, v2.ErrVacuumed) && !goerrors.Is(err, v2.ErrPageUnreadable) {
+			records++
+		}
+		return nil
+	})
+	return records, err
+}
+
+// indexStatsFromKeys builds the stats of an index from its keys, in
+// index order.
+func indexStatsFromKeys(keys []types.Comparable) *IndexStats {
+	stats := &IndexStats{Entries: int64(len(keys))}
+	depth := (len(keys) + histogramBuckets - 1) / histogramBuckets
+	var bucket *HistogramBucket
+	for i, key := range keys {
+		if isNullKey(key) {
+			stats.NullKeys++
+		}
+		newKey := i == 0 || !sameComparableKey(keys[i-1], key)
+		if newKey {
+			stats.DistinctKeys++
+		}
+		if bucket == nil || (newKey && bucket.Entries >= int64(depth)) {
+			stats.Histogram = append(stats.Histogram, HistogramBucket{Lower: key})
+			bucket = &stats.Histogram[len(stats.Histogram)-1]
+		}
+		bucket.Upper = key
+		bucket.Entries++
+		if newKey {
+			bucket.DistinctKeys++
+		}
+	}
+	return stats
+}
+
+// estimateEntries estimates how many index entries the seek of bounds
+// reads, from the histogram. ok is false when the bounds cannot be
+// compared with the keys in the histogram.
+func (s *IndexStats) estimateEntries(bounds indexScanBounds) (estimate int64, ok bool) {
+	switch {
+	case bounds.empty:
+		return 0, true
+	case bounds.pointSeek:
+		var total float64
+		for _, point := range bounds.points {
+			for _, b := range s.Histogram {
+				if !statsComparable(point, b.Lower) {
+					return 0, false
+				}
+				if point.Compare(b.Lower) >= 0 && point.Compare(b.Upper) <= 0 {
+					total += float64(b.Entries) / float64(b.DistinctKeys)
+					break
+				}
+			}
+		}
+		return int64(total + 0.5), true
+	case bounds.start == nil:
+		return s.Entries, true
+	}
+
+	// Buckets inside the range count whole; a bucket the range only
+	// overlaps counts half.
+	var total float64
+	for _, b := range s.Histogram {
+		if !statsComparable(bounds.start, b.Lower) || !statsComparable(bounds.end, b.Lower) {
+			return 0, false
+		}
+		if bounds.end.Compare(b.Lower) < 0 || bounds.start.Compare(b.Upper) > 0 {
+			continue
+		}
+		if bounds.start.Compare(b.Lower) <= 0 && bounds.end.Compare(b.Upper) >= 0 {
+			total += float64(b.Entries)
+		} else {
+			total += float64(b.Entries) / 2
+		}
+	}
+	return int64(total + 0.5), true
+}
+
+// statsComparable reports whether Compare can order a and b.
+func statsComparable(a, b types.Comparable) bool {
+	return isNullKey(a) || isNullKey(b) || reflect.TypeOf(a) == reflect.TypeOf(b)
+}
@@ -0,0 +1,129 @@
+package storage_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+func TestAnalyze_CollectsTableAndIndexStats(t *testing.T) {
+	se := openReportsEngine(t, t.TempDir())
+	defer se.Close()
+
+	if se.Stats("reports") != nil {
+		t.Fatal("Stats before Analyze should be nil")
+	}
+	depts := []string{"Eng", "Ops", "Sales", "Support"}
+	for i := 1; i <= 200; i++ {
+		manager := "null"
+		if i%4 != 0 {
+			manager = fmt.Sprint(i % 7)
+		}
+		doc := fmt.Sprintf(`{"id": %d, "manager": %s, "dept": %q}`, i, manager, depts[i%len(depts)])
+		if err := se.InsertRow("reports", doc, nil); err != nil {
+			t.Fatalf("InsertRow: %v", err)
+		}
+	}
+	for i := 1; i <= 20; i++ {
+		doc := fmt.Sprintf(`{"id": %d, "manager": 1, "dept": "Eng"}`, i)
+		if err := se.UpdateRow("reports", doc, nil); err != nil {
+			t.Fatalf("UpdateRow: %v", err)
+		}
+	}
+	for i := 191; i <= 200; i++ {
+		if _, err := se.DeleteRow("reports", types.IntKey(i)); err != nil {
+			t.Fatalf("DeleteRow: %v", err)
+		}
+	}
+
+	stats, err := se.Analyze("reports")
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if stats.Rows != 190 {
+		t.Fatalf("Rows = %d, want 190", stats.Rows)
+	}
+	if stats.DeadTuples < 30 || stats.DeadTupleRatio() <= 0 {
+		t.Fatalf("DeadTuples = %d (ratio %.2f), want the 20 old versions and 10 deleted rows", stats.DeadTuples, stats.DeadTupleRatio())
+	}
+	if stats.AvgDocumentSize <= 0 {
+		t.Fatalf("AvgDocumentSize = %f", stats.AvgDocumentSize)
+	}
+	if se.Stats("reports") != stats {
+		t.Fatal("Stats should return the last Analyze")
+	}
+
+	id := stats.Indexes["id"]
+	if id.Entries != 190 || id.DistinctKeys != 190 || id.NullKeys != 0 {
+		t.Fatalf("id stats = %+v", id)
+	}
+	dept := stats.Indexes["dept"]
+	if dept.Entries != 190 || dept.DistinctKeys != 4 {
+		t.Fatalf("dept stats = %+v", dept)
+	}
+	manager := stats.Indexes["manager"]
+	wantNulls, _ := se.Count("reports", "manager", query.IsNull())
+	if manager.NullKeys != int64(wantNulls) || manager.DistinctKeys != 8 {
+		t.Fatalf("manager stats = %+v, want %d NULL keys and 8 distinct", manager, wantNulls)
+	}
+	for name, index := range stats.Indexes {
+		var entries int64
+		for _, bucket := range index.Histogram {
+			entries += bucket.Entries
+		}
+		if entries != index.Entries || len(index.Histogram) > 16 {
+			t.Fatalf("%s histogram has %d buckets holding %d entries, want at most 16 holding %d", name, len(index.Histogram), entries, index.Entries)
+		}
+	}
+}
+
+func TestAnalyze_ExplainEstimatesRows(t *testing.T) {
+	se := openReportsEngine(t, t.TempDir())
+	defer se.Close()
+
+	for i := 1; i <= 400; i++ {
+		dept := "Eng"
+		if i%10 == 0 {
+			dept = "Ops"
+		}
+		doc := fmt.Sprintf(`{"id": %d, "dept": %q}`, i, dept)
+		if err := se.InsertRow("reports", doc, nil); err != nil {
+			t.Fatalf("InsertRow: %v", err)
+		}
+	}
+	if _, err := se.Analyze("reports"); err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+
+	cases := []struct {
+		index     string
+		condition *query.ScanCondition
+		min, max  int64
+	}{
+		{"id", nil, 400, 400},
+		{"id", query.Equal(types.IntKey(7)), 1, 1},
+		{"id", query.Between(types.IntKey(101), types.IntKey(300)), 175, 225},
+		{"dept", query.Equal(types.VarcharKey("Eng")), 360, 360},
+		{"dept", query.Equal(types.VarcharKey("Ops")), 40, 40},
+		{"dept", query.Equal(types.VarcharKey("HR")), 0, 0},
+		{"id", query.IsNull(), 0, 0},
+	}
+	for _, tc := range cases {
+		plan, err := se.Explain("reports", tc.index, tc.condition)
+		if err != nil {
+			t.Fatalf("Explain: %v", err)
+		}
+		if plan.EstimatedRows < tc.min || plan.EstimatedRows > tc.max {
+			t.Fatalf("%v: EstimatedRows = %d, want [%d, %d]", plan, plan.EstimatedRows, tc.min, tc.max)
+		}
+	}
+
+	if err := se.TruncateTable("reports"); err != nil {
+		t.Fatal(err)
+	}
+	if se.Stats("reports") != nil {
+		t.Fatal("TruncateTable should drop the stats of the table")
+	}
+}
@@ -2,8 +2,10 @@ package storage
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 
 	"github.com/bobboyms/storage-engine/pkg/btree"
 	btreev2 "github.com/bobboyms/storage-engine/pkg/btree/v2"
@@ -11,6 +13,7 @@ import (
 	"github.com/bobboyms/storage-engine/pkg/errors"
 	"github.com/bobboyms/storage-engine/pkg/heap"
 	v2 "github.com/bobboyms/storage-engine/pkg/heap/v2"
+	"github.com/bobboyms/storage-engine/pkg/types"
 )
 
 // HeapFormat seleciona a implementação de heap a ser usada por uma tabela.
@@ -31,13 +34,24 @@ func NewHeapForTable(format HeapFormat, path string, cipher ...crypto.Cipher) (h
 
 	switch format {
 	case HeapFormatV2:
-		// BufferPool default: 64 pages = 512KB de RAM por tabela.
-		return v2.NewHeapV2(path, 64, c)
+		// Default BufferPool: 64 pages = 512KB of RAM per table. The heap
+		// stays locked while open (ErrDatabaseLocked).
+		h, err := v2.NewHeapV2(path, 64, c)
+		if err != nil {
+			return nil, databaseLockedError(err)
+		}
+		return h, nil
 	default:
 		return nil, fmt.Errorf("heap format desconhecido: %d", format)
 	}
 }
 
+// DefaultIndexCachePages is the buffer pool size, in pages, of each index
+// tree. Index trees live in their own page files and only these pages are
+// kept in RAM, so an index may be larger than memory and opening it reads
+// just its meta page.
+const DefaultIndexCachePages = 16
+
 // BTreeFormat seleciona a implementação de B+ tree por index.
 type BTreeFormat int
 
@@ -46,25 +60,113 @@ const (
 	BTreeFormatV2 BTreeFormat = iota
 )
 
-// NewBTreeForIndex cria uma B+ tree da implementação escolhida.
-// Usa path + cipher. `keyType` determina o codec. TypeVarchar usa
-// layout variable-key; demais usam fixed-key.
+// NewBTreeForIndex creates a B+ tree of the chosen implementation.
+// It uses path + cipher. `keyType` picks the codec. TypeVarchar, TypeUUID
+// and TypeBytes use the variable-key layout; the others use fixed-key.
+//
+// Non-primary indexes are non-unique: they get a btreev2.PostingTree so
+// one key can map to every row that shares it.
 func NewBTreeForIndex(format BTreeFormat, primary bool, keyType DataType, path string, cipher crypto.Cipher) (btree.Tree, error) {
 	switch format {
 	case BTreeFormatV2:
-		if keyType == TypeVarchar {
-			return btreev2.NewBTreeV2Varchar(path, 16, cipher, btreev2.VarcharKeyCodec{})
+		if !primary {
+			codec, err := orderedCodecForDataType(keyType)
+			if err != nil {
+				return nil, err
+			}
+			return btreev2.NewPostingTree(path, DefaultIndexCachePages, cipher, codec)
+		}
+		if varCodec, ok := variableCodecForDataType(keyType); ok {
+			return btreev2.NewBTreeV2Varchar(path, DefaultIndexCachePages, cipher, varCodec)
 		}
 		codec, err := codecForDataType(keyType)
 		if err != nil {
 			return nil, err
 		}
-		return btreev2.NewBTreeV2Typed(path, 16, cipher, codec)
+		return btreev2.NewBTreeV2Typed(path, DefaultIndexCachePages, cipher, codec)
 	default:
 		return nil, fmt.Errorf("unknown btree format: %d", format)
 	}
 }
 
+// newIndexTree creates the tree of idx at path. A nullable index uses a
+// posting tree whose keys may be NULL; an index with a collation orders
+// its keys by their collation key; a primary index with Partitions > 1
+// uses a PartitionedTree.
+func newIndexTree(idx *Index, path string, cipher crypto.Cipher) (btree.Tree, error) {
+	if idx.Primary && idx.Partitions > 1 {
+		return btreev2.NewPartitionedTree(path, idx.Partitions, func(path string) (*btreev2.BTreeV2, error) {
+			tree, err := NewBTreeForIndex(BTreeFormatV2, true, idx.Type, path, cipher)
+			if err != nil {
+				return nil, err
+			}
+			return tree.(*btreev2.BTreeV2), nil
+		})
+	}
+	if idx.Primary || (!idx.Nullable && idx.Collation == types.CollationBinary) {
+		return NewBTreeForIndex(BTreeFormatV2, idx.Primary, idx.Type, path, cipher)
+	}
+	codec, err := orderedCodecForDataType(idx.Type)
+	if err != nil {
+		return nil, err
+	}
+	if idx.Collation != types.CollationBinary {
+		codec = btreev2.CollatedKeyCodec{Collation: idx.Collation}
+	}
+	if idx.Nullable {
+		codec = btreev2.NullableKeyCodec{Inner: codec}
+	}
+	return btreev2.NewPostingTree(path, DefaultIndexCachePages, cipher, codec)
+}
+
+// OpenIndexFile opens an existing index file, such as one copied out of
+// a data directory or a backup, with the tree layout idx would create.
+// The file must not be open elsewhere; the caller closes the tree.
+func OpenIndexFile(idx Index, path string, cipher crypto.Cipher) (btree.Tree, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	return newIndexTree(&idx, path, cipher)
+}
+
+// CreateIndexFile creates a new, empty index file at path with the tree
+// layout idx would create, for tools that rebuild an index outside an
+// engine. It fails if path already exists; the caller closes the tree.
+func CreateIndexFile(idx Index, path string, cipher crypto.Cipher) (btree.Tree, error) {
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("storage: index file %s already exists", path)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return newIndexTree(&idx, path, cipher)
+}
+
+// indexFileTrees returns the trees of an index that own a page file
+// each: the partitions of a partitioned index, otherwise the tree itself.
+func indexFileTrees(tree btree.Tree) []btree.Tree {
+	partitioned, ok := tree.(*btreev2.PartitionedTree)
+	if !ok {
+		return []btree.Tree{tree}
+	}
+	trees := make([]btree.Tree, 0, len(partitioned.Partitions()))
+	for _, part := range partitioned.Partitions() {
+		trees = append(trees, part)
+	}
+	return trees
+}
+
+// indexFilePaths returns every file of an index tree, or nil if the tree
+// has no file.
+func indexFilePaths(tree btree.Tree) []string {
+	var paths []string
+	for _, t := range indexFileTrees(tree) {
+		if provider, ok := t.(pathProvider); ok {
+			paths = append(paths, provider.Path())
+		}
+	}
+	return paths
+}
+
 func defaultV2IndexPath(heapPath, tableName, indexName string) string {
 	dir := filepath.Dir(heapPath)
 	base := filepath.Base(heapPath)
@@ -83,13 +185,55 @@ func codecForDataType(t DataType) (btreev2.KeyCodec, error) {
 		return btreev2.BoolKeyCodec{}, nil
 	case TypeDate:
 		return btreev2.DateKeyCodec{}, nil
+	case TypeDecimal:
+		return btreev2.DecimalKeyCodec{}, nil
+	case TypeVarchar, TypeUUID, TypeBytes:
+		return nil, fmt.Errorf("codecForDataType: %v is not accepted here - use NewBTreeV2Varchar", t)
+	default:
+		return nil, fmt.Errorf("unrecognized DataType: %d", t)
+	}
+}
+
+// orderedCodecForDataType maps a DataType to the order-preserving codec
+// used inside posting tree composite keys.
+func orderedCodecForDataType(t DataType) (btreev2.OrderedKeyCodec, error) {
+	switch t {
+	case TypeInt:
+		return btreev2.IntKeyCodec{}, nil
 	case TypeVarchar:
-		return nil, fmt.Errorf("codecForDataType: TypeVarchar is not accepted here - use NewBTreeV2Varchar")
+		return btreev2.VarcharKeyCodec{}, nil
+	case TypeFloat:
+		return btreev2.FloatKeyCodec{}, nil
+	case TypeBoolean:
+		return btreev2.BoolKeyCodec{}, nil
+	case TypeDate:
+		return btreev2.DateKeyCodec{}, nil
+	case TypeDecimal:
+		return btreev2.DecimalKeyCodec{}, nil
+	case TypeUUID:
+		return btreev2.UUIDKeyCodec{}, nil
+	case TypeBytes:
+		return btreev2.BytesKeyCodec{}, nil
 	default:
 		return nil, fmt.Errorf("unrecognized DataType: %d", t)
 	}
 }
 
+// variableCodecForDataType maps the variable-size DataTypes (or those
+// larger than 8 bytes) → btreev2.VariableKeyCodec.
+func variableCodecForDataType(t DataType) (btreev2.VariableKeyCodec, bool) {
+	switch t {
+	case TypeVarchar:
+		return btreev2.VarcharKeyCodec{}, true
+	case TypeUUID:
+		return btreev2.UUIDKeyCodec{}, true
+	case TypeBytes:
+		return btreev2.BytesKeyCodec{}, true
+	default:
+		return nil, false
+	}
+}
+
 type DataType int
 
 const (
@@ -98,21 +242,68 @@ const (
 	TypeBoolean                 // 2: Bool
 	TypeFloat                   // 3: Float64
 	TypeDate                    // 4: Timestamp
+	TypeDecimal                 // 5: Fixed-point decimal (types.DecimalKey)
+	TypeUUID                    // 6: 16-byte UUID
+	TypeBytes                   // 7: Opaque bytes
 )
 
 // Função auxiliar útil para debug
 func (d DataType) String() string {
-	return [...]string{"INT", "VARCHAR", "BOOL", "FLOAT", "DATE"}[d]
+	return [...]string{"INT", "VARCHAR", "BOOL", "FLOAT", "DATE", "DECIMAL", "UUID", "BYTES"}[d]
 }
 
 type Index struct {
 	Name    string
 	Primary bool
 	Type    DataType
+	// Field is the path of the document field the key is extracted from,
+	// with dots for subdocuments ("address.city"). Empty uses Name.
+	Field string
+	// Multikey indexes an array field with one entry per element (see
+	// multikey.go). Secondary indexes only.
+	Multikey bool
+	// Bitmap keeps a bitmap of rows per value, for low-cardinality
+	// columns (see bitmap_index.go). Secondary indexes only.
+	Bitmap bool
+	// Nullable accepts NULL (types.NullKey) as a key: a document without
+	// the field, or with null, is indexed under NULL, which sorts before
+	// every value. Secondary indexes only.
+	Nullable bool
+	// Collation defines how the keys of a secondary VARCHAR index are
+	// compared (see types.Collation). The document keeps the original
+	// value; the index keeps the collation key.
+	Collation types.Collation
+	// Partitions hash-partitions a primary index over that many
+	// sub-trees, each in its own file, so concurrent writers of
+	// increasing keys do not all queue on one tree (see
+	// btreev2.PartitionedTree). 0 or 1 keeps a single tree; the count
+	// cannot change after the index is created.
+	Partitions int
 	// Tree é a implementação page-based do index.
 	Tree btree.Tree
 }
 
+// FieldPath returns the path of the indexed field in the document.
+func (idx *Index) FieldPath() string {
+	if idx.Field == "" {
+		return idx.Name
+	}
+	return idx.Field
+}
+
+// postings returns the index tree as a posting list tree when the index
+// is non-unique (one key, many rows).
+func (idx *Index) postings() (btree.MultiValueTree, bool) {
+	tree, ok := idx.Tree.(btree.MultiValueTree)
+	return tree, ok
+}
+
+// IsMultiValue reports whether the index maps one key to many rows.
+func (idx *Index) IsMultiValue() bool {
+	_, ok := idx.postings()
+	return ok
+}
+
 // Table representa uma tabela no banco de dados com seu próprio lock
 // para permitir operações concurrent em tabelas diferentes.
 //
@@ -122,6 +313,31 @@ type Table struct {
 	Indices map[string]*Index
 	mu      sync.RWMutex // Lock por tabela para concurrency granular
 	Heap    heap.Heap
+
+	// ttlIndex is the TypeDate index holding the expiry of the rows (see
+	// SetTableTTL); empty when the table does not expire rows.
+	ttlIndex string
+
+	// validator checks documents before they are written (see
+	// TableMetaData.SetValidator).
+	validator atomic.Pointer[tableValidator]
+
+	// retention is the time-travel window (ver SetVersionRetention).
+	retention VersionRetention
+
+	// vacuumHorizon is the snapshot LSN below which Vacuum or
+	// PruneVersions may have freed versions (see SnapshotScan).
+	vacuumHorizon atomic.Uint64
+}
+
+// advanceVacuumHorizon raises the vacuum horizon to lsn.
+func (t *Table) advanceVacuumHorizon(lsn uint64) {
+	for {
+		current := t.vacuumHorizon.Load()
+		if lsn <= current || t.vacuumHorizon.CompareAndSwap(current, lsn) {
+			return
+		}
+	}
 }
 
 // Lock adquire write lock na tabela
@@ -144,6 +360,13 @@ func (t *Table) RUnlock() {
 	t.mu.RUnlock()
 }
 
+// TTLIndex returns the expiry index of the table, or "" without a TTL.
+func (t *Table) TTLIndex() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.ttlIndex
+}
+
 // GetIndex retorna o index pelo nome de forma thread-safe (Schema Lock)
 func (t *Table) GetIndex(indexName string) (*Index, error) {
 	t.mu.RLock()
@@ -181,11 +404,17 @@ type TableMetaData struct {
 	tables             map[string]*Table
 	defaultIndexCipher crypto.Cipher
 	mu                 sync.RWMutex // Protege acesso ao mapa de tabelas
+
+	// catalogPath, when set, persists the schema on every change (see
+	// NewCatalogTableMenager). degrees keeps the NewTable degree for it.
+	catalogPath string
+	degrees     map[string]int
 }
 
 func NewTableMenager() *TableMetaData {
 	return &TableMetaData{
-		tables: make(map[string]*Table),
+		tables:  make(map[string]*Table),
+		degrees: make(map[string]int),
 	}
 }
 
@@ -196,6 +425,7 @@ func NewEncryptedTableMenager(indexCipher crypto.Cipher) *TableMetaData {
 	return &TableMetaData{
 		tables:             make(map[string]*Table),
 		defaultIndexCipher: indexCipher,
+		degrees:            make(map[string]int),
 	}
 }
 
@@ -208,6 +438,12 @@ func (tb *TableMetaData) SetDefaultIndexCipher(indexCipher crypto.Cipher) {
 	tb.defaultIndexCipher = indexCipher
 }
 
+func (tb *TableMetaData) indexCipher() crypto.Cipher {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+	return tb.defaultIndexCipher
+}
+
 func (tb *TableMetaData) NewTable(tableName string, indices []Index, t int, hm heap.Heap) error {
 	tb.mu.Lock()
 	defer tb.mu.Unlock()
@@ -237,7 +473,7 @@ func (tb *TableMetaData) NewTable(tableName string, indices []Index, t int, hm h
 		} else if _, ok := hm.(*v2.HeapV2); ok {
 			treePath := defaultV2IndexPath(hm.Path(), tableName, value.Name)
 			var err error
-			tree, err = NewBTreeForIndex(BTreeFormatV2, value.Primary, value.Type, treePath, tb.defaultIndexCipher)
+			tree, err = newIndexTree(&value, treePath, tb.defaultIndexCipher)
 			if err != nil {
 				return err
 			}
@@ -248,12 +484,37 @@ func (tb *TableMetaData) NewTable(tableName string, indices []Index, t int, hm h
 		if value.Primary {
 			primaryCount++
 		}
+		if _, ok := tree.(btree.MultiValueTree); value.Multikey && (value.Primary || !ok) {
+			return fmt.Errorf("storage: multikey index %s.%s must be a non-unique secondary index", tableName, value.Name)
+		}
+		if value.Nullable && value.Primary {
+			return fmt.Errorf("storage: primary index %s.%s cannot be nullable", tableName, value.Name)
+		}
+		if value.Collation != types.CollationBinary && (value.Primary || value.Type != TypeVarchar || !value.Collation.Valid()) {
+			return fmt.Errorf("storage: collation %q is not valid for index %s.%s; it needs a secondary VARCHAR index", value.Collation, tableName, value.Name)
+		}
+		if value.Partitions > 1 && !value.Primary {
+			return fmt.Errorf("storage: index %s.%s cannot be partitioned; only primary indexes can", tableName, value.Name)
+		}
+		if value.Bitmap {
+			bitmapTree, err := newBitmapTree(tableName, &value, tree)
+			if err != nil {
+				return err
+			}
+			tree = bitmapTree
+		}
 
 		idxPtr := &Index{
-			Name:    value.Name,
-			Primary: value.Primary,
-			Type:    value.Type,
-			Tree:    tree,
+			Name:       value.Name,
+			Primary:    value.Primary,
+			Type:       value.Type,
+			Field:      value.Field,
+			Multikey:   value.Multikey,
+			Bitmap:     value.Bitmap,
+			Nullable:   value.Nullable,
+			Collation:  value.Collation,
+			Partitions: value.Partitions,
+			Tree:       tree,
 		}
 
 		tempIndices[value.Name] = idxPtr
@@ -277,7 +538,13 @@ func (tb *TableMetaData) NewTable(tableName string, indices []Index, t int, hm h
 		Indices: tempIndices,
 		Heap:    hm,
 	}
+	tb.degrees[tableName] = t
 
+	if err := tb.saveCatalogLocked(); err != nil {
+		delete(tb.tables, tableName)
+		delete(tb.degrees, tableName)
+		return err
+	}
 	return nil
 }
 
@@ -0,0 +1,256 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bobboyms/storage-engine/pkg/crypto"
+	"github.com/bobboyms/storage-engine/pkg/errors"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+// cipherProvider is implemented by page-based heaps and trees; it lets a
+// truncate recreate their files with the same encryption.
+type cipherProvider interface {
+	Cipher() crypto.Cipher
+}
+
+// TruncateTable removes every row of a table while keeping its schema.
+// The heap and every index file are recreated empty.
+//
+// An EntryTruncate record is made durable first; recovery replays it by
+// resetting the table again, so rows logged before it never come back and
+// rows logged after it are redone on the empty files. Every reader and
+// writer is drained first. Truncate is not MVCC: transactions that started
+// before it see the table empty afterwards.
+func (se *StorageEngine) TruncateTable(tableName string) error {
+	se.opMu.Lock()
+	defer se.opMu.Unlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return err
+	}
+
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return err
+	}
+	if se.TableMetaData.heapShared(table) {
+		return fmt.Errorf("storage: cannot truncate table %s: its heap is shared with another table", tableName)
+	}
+
+	lsn := se.lsnTracker.Next()
+	if se.WAL != nil {
+		if err := se.writeTableWAL(wal.EntryTruncate, tableName, lsn); err != nil {
+			return err
+		}
+	}
+
+	table.Lock()
+	err = resetTableFiles(table)
+	table.Unlock()
+	if err != nil {
+		// Some files may already be gone; only a replay of the truncate
+		// record brings the table back to a usable state.
+		se.markDegraded(err)
+		return fmt.Errorf("storage: truncate table %s: %w", tableName, err)
+	}
+	for _, idx := range table.GetIndices() {
+		se.appliedLSN.MarkApplied(tableName, idx.Name, lsn)
+	}
+	table.advanceVacuumHorizon(lsn)
+	se.stats.forget(tableName)
+	se.registerPageRedoHooks()
+
+	if se.WAL != nil {
+		if err := se.fuzzyCheckpointLocked(); err != nil {
+			return fmt.Errorf("storage: truncate table %s: %w", tableName, err)
+		}
+	}
+	return nil
+}
+
+// DropTable removes a table: its catalog entry, its index files and its
+// heap file (kept when another table shares the heap).
+//
+// An EntryDropTable record is made durable before anything is removed. A
+// table created later under the same name replays it like a truncate, so
+// rows of the dropped table logged before the drop are not redone into
+// the new one. A crash before the catalog is rewritten leaves the table in
+// the catalog, but empty.
+func (se *StorageEngine) DropTable(tableName string) error {
+	se.opMu.Lock()
+	defer se.opMu.Unlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return err
+	}
+
+	if _, err := se.TableMetaData.GetTableByName(tableName); err != nil {
+		return err
+	}
+
+	if se.WAL != nil {
+		if err := se.writeTableWAL(wal.EntryDropTable, tableName, se.lsnTracker.Next()); err != nil {
+			return err
+		}
+	}
+
+	table, err := se.TableMetaData.removeTable(tableName)
+	if err != nil {
+		return err
+	}
+	se.stats.forget(tableName)
+	keepHeap := se.TableMetaData.heapShared(table)
+
+	table.Lock()
+	defer table.Unlock()
+
+	paths := make([]string, 0, len(table.Indices)+1)
+	for _, idx := range table.Indices {
+		paths = append(paths, indexFilePaths(idx.Tree)...)
+		if err := idx.Tree.Close(); err != nil {
+			return err
+		}
+	}
+	if !keepHeap {
+		paths = append(paths, table.Heap.Path())
+		if err := table.Heap.Close(); err != nil {
+			return err
+		}
+	}
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTableWAL logs a table-level DDL record and syncs the WAL, so the
+// record is on disk before any file of the table is touched.
+func (se *StorageEngine) writeTableWAL(entryType uint8, tableName string, lsn uint64) error {
+	if err := se.writeMultiIndexWAL(entryType, tableName, nil, nil, lsn); err != nil {
+		return err
+	}
+	if err := se.WAL.Sync(); err != nil {
+		return fmt.Errorf("wal sync failed: %w", err)
+	}
+	return nil
+}
+
+// resetTableFiles closes the heap and every index of table, deletes their
+// files and reopens them empty at the same paths with the same cipher.
+// Caller must hold the table lock.
+func resetTableFiles(table *Table) error {
+	heapPath := table.Heap.Path()
+	heapCipher := cipherOf(table.Heap)
+	if err := table.Heap.Close(); err != nil {
+		return err
+	}
+	if err := os.Remove(heapPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	hm, err := NewHeapForTable(HeapFormatV2, heapPath, heapCipher)
+	if err != nil {
+		return err
+	}
+	table.Heap = hm
+
+	for _, idx := range table.Indices {
+		provider, ok := idx.Tree.(pathProvider)
+		if !ok {
+			return fmt.Errorf("storage: index %s.%s has no file path to reset", table.Name, idx.Name)
+		}
+		treePath := provider.Path()
+		treeCipher := cipherOf(idx.Tree)
+		treeFiles := indexFilePaths(idx.Tree)
+		if err := idx.Tree.Close(); err != nil {
+			return err
+		}
+		for _, path := range treeFiles {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		tree, err := newIndexTree(idx, treePath, treeCipher)
+		if err != nil {
+			return err
+		}
+		if idx.Bitmap {
+			if tree, err = newBitmapTree(table.Name, idx, tree); err != nil {
+				return err
+			}
+		}
+		idx.Tree = tree
+	}
+	return nil
+}
+
+func cipherOf(v any) crypto.Cipher {
+	if provider, ok := v.(cipherProvider); ok {
+		return provider.Cipher()
+	}
+	return nil
+}
+
+// redoTableResetEntry replays EntryTruncate and EntryDropTable. A table
+// that no longer exists is skipped; one that does (the truncated table, or
+// a table created again after the drop) is reset, dropping whatever
+// earlier entries redid into it.
+func (se *StorageEngine) redoTableResetEntry(entry *wal.WALEntry, payload []byte, loadedLSNs map[string]uint64) error {
+	tableName, _, _, err := DeserializeMultiIndexEntry(payload)
+	if err != nil {
+		return err
+	}
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return nil
+	}
+
+	table.Lock()
+	err = resetTableFiles(table)
+	table.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, idx := range table.GetIndices() {
+		loadedLSNs[appliedLSNKey(tableName, idx.Name)] = entry.Header.LSN
+		se.appliedLSN.MarkApplied(tableName, idx.Name, entry.Header.LSN)
+	}
+	se.registerPageRedoHooks()
+	return nil
+}
+
+// removeTable forgets tableName and rewrites the catalog without it. The
+// caller closes the returned table.
+func (tb *TableMetaData) removeTable(tableName string) (*Table, error) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	table, ok := tb.tables[tableName]
+	if !ok {
+		return nil, &errors.TableNotFoundError{Name: tableName}
+	}
+	degree := tb.degrees[tableName]
+	delete(tb.tables, tableName)
+	delete(tb.degrees, tableName)
+
+	if err := tb.saveCatalogLocked(); err != nil {
+		tb.tables[tableName] = table
+		tb.degrees[tableName] = degree
+		return nil, err
+	}
+	return table, nil
+}
+
+// heapShared reports whether another registered table writes to the heap
+// of table.
+func (tb *TableMetaData) heapShared(table *Table) bool {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+	for _, other := range tb.tables {
+		if other != table && other.Heap == table.Heap {
+			return true
+		}
+	}
+	return false
+}
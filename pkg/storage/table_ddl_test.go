@@ -0,0 +1,165 @@
+package storage_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	storageErrors "github.com/bobboyms/storage-engine/pkg/errors"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// newCatalogEmployees creates the employees table in a catalog-backed
+// table manager rooted at dir.
+func newCatalogEmployees(t *testing.T, dir string) *storage.TableMetaData {
+	t.Helper()
+	tableMgr, err := storage.NewCatalogTableMenager(filepath.Join(dir, "catalog.json"), nil)
+	if err != nil {
+		t.Fatalf("NewCatalogTableMenager: %v", err)
+	}
+	hm, err := storage.NewHeapForTable(storage.HeapFormatV2, filepath.Join(dir, "heap.data"))
+	if err != nil {
+		t.Fatalf("Failed to create heap: %v", err)
+	}
+	if err := tableMgr.NewTable("employees", []storage.Index{
+		{Name: "id", Primary: true, Type: storage.TypeInt},
+		{Name: "department", Primary: false, Type: storage.TypeVarchar},
+	}, 3, hm); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	return tableMgr
+}
+
+func TestTruncateTable(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+
+	putEmployee(t, se, 1, "Engineering")
+	putEmployee(t, se, 2, "Sales")
+
+	if err := se.TruncateTable("employees"); err != nil {
+		t.Fatalf("TruncateTable: %v", err)
+	}
+	if _, found, _ := se.Get("employees", "id", types.IntKey(1)); found {
+		t.Fatal("row 1 still visible after truncate")
+	}
+	docs, err := se.GetAll("employees", "department", types.VarcharKey("Engineering"))
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	assertIDs(t, "Engineering after truncate", docs)
+
+	putEmployee(t, se, 3, "Engineering")
+	docs, _ = se.GetAll("employees", "department", types.VarcharKey("Engineering"))
+	assertIDs(t, "Engineering after reinsert", docs, "3")
+
+	var notFound *storageErrors.TableNotFoundError
+	if err := se.TruncateTable("missing"); !errors.As(err, &notFound) {
+		t.Fatalf("expected TableNotFoundError, got %v", err)
+	}
+}
+
+func TestTruncateTable_SurvivesRecovery(t *testing.T) {
+	dir := t.TempDir()
+	se := openCatalogEngine(t, dir, newCatalogEmployees(t, dir))
+	putEmployee(t, se, 1, "Engineering")
+	putEmployee(t, se, 2, "Engineering")
+	if err := se.TruncateTable("employees"); err != nil {
+		t.Fatalf("TruncateTable: %v", err)
+	}
+	putEmployee(t, se, 3, "Engineering")
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened, err := storage.NewCatalogTableMenager(filepath.Join(dir, "catalog.json"), nil)
+	if err != nil {
+		t.Fatalf("reopen catalog: %v", err)
+	}
+	se2 := openCatalogEngine(t, dir, reopened)
+	defer se2.Close()
+	docs, err := se2.GetAll("employees", "department", types.VarcharKey("Engineering"))
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	assertIDs(t, "recovered Engineering", docs, "3")
+}
+
+func TestDropTable(t *testing.T) {
+	dir := t.TempDir()
+	se := openCatalogEngine(t, dir, newCatalogEmployees(t, dir))
+	putEmployee(t, se, 1, "Engineering")
+
+	files := []string{
+		filepath.Join(dir, "heap.data"),
+		filepath.Join(dir, "heap.data.employees.id.btree.v2"),
+		filepath.Join(dir, "heap.data.employees.department.btree.v2"),
+	}
+	if err := se.DropTable("employees"); err != nil {
+		t.Fatalf("DropTable: %v", err)
+	}
+	for _, path := range files {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("%s should be removed, stat err=%v", path, err)
+		}
+	}
+	var notFound *storageErrors.TableNotFoundError
+	if _, _, err := se.Get("employees", "id", types.IntKey(1)); !errors.As(err, &notFound) {
+		t.Fatalf("expected TableNotFoundError after drop, got %v", err)
+	}
+	if err := se.DropTable("employees"); !errors.As(err, &notFound) {
+		t.Fatalf("expected TableNotFoundError on second drop, got %v", err)
+	}
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened, err := storage.NewCatalogTableMenager(filepath.Join(dir, "catalog.json"), nil)
+	if err != nil {
+		t.Fatalf("reopen catalog: %v", err)
+	}
+	if tables := reopened.ListTables(); len(tables) != 0 {
+		t.Fatalf("catalog still lists %v", tables)
+	}
+	se2 := openCatalogEngine(t, dir, reopened)
+	se2.Close()
+}
+
+func TestDropTable_RecreatedTableDoesNotReplayOldRows(t *testing.T) {
+	dir := t.TempDir()
+	se := openCatalogEngine(t, dir, newCatalogEmployees(t, dir))
+	putEmployee(t, se, 1, "Engineering")
+	if err := se.DropTable("employees"); err != nil {
+		t.Fatalf("DropTable: %v", err)
+	}
+
+	// Same name, same file paths.
+	hm, err := storage.NewHeapForTable(storage.HeapFormatV2, filepath.Join(dir, "heap.data"))
+	if err != nil {
+		t.Fatalf("Failed to create heap: %v", err)
+	}
+	if err := se.TableMetaData.NewTable("employees", []storage.Index{
+		{Name: "id", Primary: true, Type: storage.TypeInt},
+		{Name: "department", Primary: false, Type: storage.TypeVarchar},
+	}, 3, hm); err != nil {
+		t.Fatalf("recreate table: %v", err)
+	}
+	putEmployee(t, se, 2, "Engineering")
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened, err := storage.NewCatalogTableMenager(filepath.Join(dir, "catalog.json"), nil)
+	if err != nil {
+		t.Fatalf("reopen catalog: %v", err)
+	}
+	se2 := openCatalogEngine(t, dir, reopened)
+	defer se2.Close()
+	docs, err := se2.GetAll("employees", "department", types.VarcharKey("Engineering"))
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	assertIDs(t, "recovered Engineering", docs, "2")
+}
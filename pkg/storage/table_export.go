@@ -0,0 +1,323 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// ExportFormat is the file format of ExportTable and ImportTable.
+type ExportFormat int
+
+const (
+	// FormatJSONL writes one JSON document per line.
+	FormatJSONL ExportFormat = iota
+	// FormatCSV writes a header with the top-level fields of the documents
+	// and one record per row. Strings are written bare, subdocuments,
+	// arrays and other values as JSON; a missing field is an empty cell.
+	// Imported rows hold their fields in column order.
+	FormatCSV
+)
+
+func (f ExportFormat) String() string {
+	return [...]string{"JSONL", "CSV"}[f]
+}
+
+// importBatchSize is how many rows ImportTable writes per InsertRows.
+const importBatchSize = 1000
+
+// ExportTable streams every row of the table visible to one snapshot to
+// w, in primary key order, and returns how many rows were written. CSV
+// reads the table twice under that snapshot: once for the header, once
+// for the rows.
+func (se *StorageEngine) ExportTable(tableName string, w io.Writer, format ExportFormat) (int, error) {
+	tx := se.BeginRead()
+	defer tx.Close()
+
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return 0, err
+	}
+	primary, err := primaryIndex(table)
+	if err != nil {
+		return 0, err
+	}
+	walk := func(fn func(doc string) error) error {
+		return tx.walkVisibleRows(tableName, primary.Name, nil, false, true, func(_ types.Comparable, raw []byte) error {
+			return fn(documentJSON(raw))
+		})
+	}
+
+	rows := 0
+	switch format {
+	case FormatJSONL:
+		out := bufio.NewWriter(w)
+		err = walk(func(doc string) error {
+			rows++
+			if _, err := out.WriteString(doc); err != nil {
+				return err
+			}
+			return out.WriteByte('\n')
+		})
+		if err == nil {
+			err = out.Flush()
+		}
+	case FormatCSV:
+		var header []string
+		columns := make(map[string]int)
+		err = walk(func(doc string) error {
+			fields, err := topLevelJSONFields(doc)
+			for _, field := range fields {
+				if _, ok := columns[field.name]; !ok {
+					columns[field.name] = len(header)
+					header = append(header, field.name)
+				}
+			}
+			return err
+		})
+		if err != nil || len(header) == 0 {
+			break
+		}
+		out := csv.NewWriter(w)
+		if err = out.Write(header); err != nil {
+			break
+		}
+		err = walk(func(doc string) error {
+			fields, err := topLevelJSONFields(doc)
+			if err != nil {
+				return err
+			}
+			record := make([]string, len(header))
+			for _, field := range fields {
+				record[columns[field.name]] = csvCell(field.value)
+			}
+			rows++
+			return out.Write(record)
+		})
+		if err == nil {
+			out.Flush()
+			err = out.Error()
+		}
+	default:
+		return 0, fmt.Errorf("storage: unknown export format %d", format)
+	}
+	if err != nil {
+		return rows, fmt.Errorf("storage: export %s: %w", tableName, err)
+	}
+	return rows, nil
+}
+
+// ImportTable inserts the rows read from r, in batches of InsertRows, and
+// returns how many were inserted. Index keys come from the document
+// fields, as in InsertRow. A CSV header names the fields; a cell of a
+// field indexed with a typed index is converted to that type, other cells
+// are read as JSON when they are a number, boolean, object or array, and
+// as a string otherwise. Empty cells are left out. A failing batch stops
+// the import: the rows of earlier batches stay inserted.
+func (se *StorageEngine) ImportTable(tableName string, r io.Reader, format ExportFormat) (int, error) {
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return 0, err
+	}
+
+	imported := 0
+	batch := make([]Row, 0, importBatchSize)
+	flush := func(line int) error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := se.InsertRows(tableName, batch); err != nil {
+			return fmt.Errorf("storage: import %s: batch ending at line %d: %w", tableName, line, err)
+		}
+		imported += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	line := 0
+	switch format {
+	case FormatJSONL:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, 64<<20)
+		for scanner.Scan() {
+			line++
+			doc := strings.TrimSpace(scanner.Text())
+			if doc == "" {
+				continue
+			}
+			batch = append(batch, Row{Document: doc})
+			if len(batch) == importBatchSize {
+				if err := flush(line); err != nil {
+					return imported, err
+				}
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return imported, fmt.Errorf("storage: import %s: line %d: %w", tableName, line+1, err)
+		}
+	case FormatCSV:
+		in := csv.NewReader(r)
+		in.FieldsPerRecord = -1
+		header, err := in.Read()
+		if err == io.EOF {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("storage: import %s: header: %w", tableName, err)
+		}
+		line = 1
+		columnTypes := csvColumnTypes(table, header)
+		for {
+			record, err := in.Read()
+			if err == io.EOF {
+				break
+			}
+			line++
+			if err != nil {
+				return imported, fmt.Errorf("storage: import %s: line %d: %w", tableName, line, err)
+			}
+			doc, err := csvDocument(header, columnTypes, record)
+			if err != nil {
+				return imported, fmt.Errorf("storage: import %s: line %d: %w", tableName, line, err)
+			}
+			batch = append(batch, Row{Document: doc})
+			if len(batch) == importBatchSize {
+				if err := flush(line); err != nil {
+					return imported, err
+				}
+			}
+		}
+	default:
+		return 0, fmt.Errorf("storage: unknown import format %d", format)
+	}
+	return imported, flush(line)
+}
+
+type jsonField struct {
+	name  string
+	value json.RawMessage
+}
+
+// topLevelJSONFields returns the fields of a JSON object in document
+// order.
+func topLevelJSONFields(doc string) ([]jsonField, error) {
+	dec := json.NewDecoder(strings.NewReader(doc))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("document is not a JSON object")
+	}
+	var fields []jsonField
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		fields = append(fields, jsonField{name: tok.(string), value: value})
+	}
+	return fields, nil
+}
+
+func csvCell(value json.RawMessage) string {
+	var s string
+	if value[0] == '"' && json.Unmarshal(value, &s) == nil {
+		return s
+	}
+	if string(value) == "null" {
+		return ""
+	}
+	return string(value)
+}
+
+// csvColumnTypes maps each CSV column to the type of the index on that
+// field; -1 marks a column without an index.
+func csvColumnTypes(table *Table, header []string) []DataType {
+	columnTypes := make([]DataType, len(header))
+	indices := table.GetIndices()
+	for i, column := range header {
+		columnTypes[i] = -1
+		for _, idx := range indices {
+			if idx.FieldPath() == column {
+				columnTypes[i] = idx.Type
+				break
+			}
+		}
+	}
+	return columnTypes
+}
+
+// csvDocument builds the JSON document of a CSV record.
+func csvDocument(header []string, columnTypes []DataType, record []string) (string, error) {
+	if len(record) > len(header) {
+		return "", fmt.Errorf("%d cells for %d columns", len(record), len(header))
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, cell := range record {
+		if cell == "" {
+			continue
+		}
+		value, err := csvValue(columnTypes[i], cell)
+		if err != nil {
+			return "", fmt.Errorf("column %s: %w", header[i], err)
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(header[i])
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.String(), nil
+}
+
+func csvValue(dataType DataType, cell string) ([]byte, error) {
+	switch dataType {
+	case TypeVarchar:
+		return json.Marshal(cell)
+	case TypeInt:
+		if _, err := strconv.ParseInt(cell, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid INT %q", cell)
+		}
+		return []byte(cell), nil
+	case TypeFloat:
+		if _, err := strconv.ParseFloat(cell, 64); err != nil {
+			return nil, fmt.Errorf("invalid FLOAT %q", cell)
+		}
+		return []byte(cell), nil
+	case TypeBoolean:
+		b, err := strconv.ParseBool(cell)
+		if err != nil {
+			return nil, fmt.Errorf("invalid BOOL %q", cell)
+		}
+		return []byte(strconv.FormatBool(b)), nil
+	case TypeDate:
+		if cell[0] == '{' {
+			return []byte(cell), nil
+		}
+		t, err := time.Parse(time.RFC3339Nano, cell)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DATE %q: want RFC 3339", cell)
+		}
+		return []byte(fmt.Sprintf(`{"$date":{"$numberLong":"%d"}}`, t.UnixMilli())), nil
+	}
+	switch c := cell[0]; {
+	case c == '{' || c == '[' || c == '-' || (c >= '0' && c <= '9') || cell == "true" || cell == "false":
+		if json.Valid([]byte(cell)) {
+			return []byte(cell), nil
+		}
+	}
+	return json.Marshal(cell)
+}
@@ -0,0 +1,90 @@
+package storage_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+func TestExportImport_RoundTripsJSONLAndCSV(t *testing.T) {
+	se := openReportsEngine(t, t.TempDir())
+	defer se.Close()
+
+	docs := map[int64]string{
+		1: `{"id":1,"dept":"Eng","tags":["a","b"],"score":1.5}`,
+		2: `{"id":2,"dept":"007","manager":1,"note":"says \"hi\", twice"}`,
+		4: `{"id":4,"dept":"Ops","profile":{"level":3}}`,
+	}
+	for _, doc := range docs {
+		if err := se.InsertRow("reports", doc, nil); err != nil {
+			t.Fatalf("InsertRow %s: %v", doc, err)
+		}
+	}
+	if err := se.InsertRow("reports", `{"id":3,"dept":"Gone"}`, nil); err != nil {
+		t.Fatalf("InsertRow: %v", err)
+	}
+	if _, err := se.DeleteRow("reports", types.IntKey(3)); err != nil {
+		t.Fatalf("DeleteRow: %v", err)
+	}
+
+	for _, format := range []storage.ExportFormat{storage.FormatJSONL, storage.FormatCSV} {
+		var buf bytes.Buffer
+		rows, err := se.ExportTable("reports", &buf, format)
+		if err != nil {
+			t.Fatalf("ExportTable %v: %v", format, err)
+		}
+		if rows != 3 {
+			t.Fatalf("%v: expected 3 rows, got %d:\n%s", format, rows, buf.String())
+		}
+		if format == storage.FormatCSV {
+			header := strings.SplitN(buf.String(), "\n", 2)[0]
+			if header != "id,dept,tags,score,manager,note,profile" {
+				t.Fatalf("unexpected CSV header %q", header)
+			}
+		}
+
+		target := openReportsEngine(t, t.TempDir())
+		imported, err := target.ImportTable("reports", &buf, format)
+		if err != nil {
+			t.Fatalf("ImportTable %v: %v", format, err)
+		}
+		if imported != 3 {
+			t.Fatalf("%v: expected 3 imported rows, got %d", format, imported)
+		}
+		for id, want := range docs {
+			got, found, err := target.Get("reports", "id", types.IntKey(id))
+			if err != nil || !found || got != want {
+				t.Fatalf("%v: row %d: expected %s, got %s (found=%v, err=%v)", format, id, want, got, found, err)
+			}
+		}
+		if ids, err := target.GetAll("reports", "dept", types.VarcharKey("007")); err != nil || len(ids) != 1 {
+			t.Fatalf("%v: expected the dept index to be filled, got %v (%v)", format, ids, err)
+		}
+		target.Close()
+	}
+}
+
+func TestImportTable_CSVConvertsIndexedColumns(t *testing.T) {
+	se := openReportsEngine(t, t.TempDir())
+	defer se.Close()
+
+	csv := "id,manager,dept,zip\n10,,Eng,01234\n11,10,42,99\n"
+	if n, err := se.ImportTable("reports", strings.NewReader(csv), storage.FormatCSV); err != nil || n != 2 {
+		t.Fatalf("ImportTable: n=%d err=%v", n, err)
+	}
+	for id, want := range map[int64]string{
+		10: `{"id":10,"dept":"Eng","zip":"01234"}`,
+		11: `{"id":11,"manager":10,"dept":"42","zip":99}`,
+	} {
+		if got, _, _ := se.Get("reports", "id", types.IntKey(id)); got != want {
+			t.Fatalf("row %d: expected %s, got %s", id, want, got)
+		}
+	}
+
+	if _, err := se.ImportTable("reports", strings.NewReader("id,dept\nx,Eng\n"), storage.FormatCSV); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected an INT conversion error at line 2, got %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/bobboyms/storage-engine/pkg/tracing"
+)
+
+// SetTracer installs t on the engine and its WAL; nil turns tracing off.
+// PutCtx, GetCtx, ScanCtx and CommitCtx then run as the spans
+// "storage.Put", "storage.Get", "storage.Scan" and "storage.Commit", under
+// the span in ctx; Vacuum and Recover as the root spans "storage.Vacuum"
+// and "storage.Recover". Tree traversal ("btree.*"), heap I/O ("heap.*"),
+// WAL appends and fsyncs ("wal.append", "wal.fsync") are their children.
+//
+// Recovery runs inside NewProductionStorageEngine; to trace it, set the
+// tracer on the WAL writer before opening the engine, which adopts it.
+func (se *StorageEngine) SetTracer(t tracing.Tracer) {
+	se.tracer.Set(t)
+	if se.WAL != nil {
+		se.WAL.SetTracer(t)
+	}
+}
+
+// startSpan starts the span name under ctx, tagged with the table and,
+// when not empty, the index.
+func (se *StorageEngine) startSpan(ctx context.Context, name string, tableName string, indexName string) (context.Context, tracing.Span) {
+	ctx, span := se.tracer.Start(ctx, name)
+	if tracing.Recording(span) {
+		span.SetAttribute("storage.table", tableName)
+		if indexName != "" {
+			span.SetAttribute("storage.index", indexName)
+		}
+	}
+	return ctx, span
+}
@@ -0,0 +1,162 @@
+package storage_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/tracing"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+// findSpan returns the first recorded span named name under parent.
+func findSpan(t *testing.T, spans []tracing.RecordedSpan, name, parent string) tracing.RecordedSpan {
+	t.Helper()
+	for _, span := range spans {
+		if span.Name == name && span.Parent == parent {
+			return span
+		}
+	}
+	t.Fatalf("no span %q under %q in %+v", name, parent, spans)
+	return tracing.RecordedSpan{}
+}
+
+func openTracedEngine(t *testing.T, dir string, tracer tracing.Tracer) *storage.StorageEngine {
+	t.Helper()
+	hm, err := storage.NewHeapForTable(storage.HeapFormatV2, filepath.Join(dir, "heap.data"))
+	if err != nil {
+		t.Fatalf("NewHeapForTable: %v", err)
+	}
+	tableMgr := storage.NewTableMenager()
+	if err := tableMgr.NewTable("users", []storage.Index{
+		{Name: "id", Primary: true, Type: storage.TypeInt},
+	}, 3, hm); err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	walWriter, err := wal.NewWALWriter(filepath.Join(dir, "wal.log"), wal.DefaultOptions())
+	if err != nil {
+		t.Fatalf("NewWALWriter: %v", err)
+	}
+	walWriter.SetTracer(tracer)
+	se, err := storage.NewProductionStorageEngine(tableMgr, walWriter)
+	if err != nil {
+		walWriter.Close()
+		t.Fatalf("NewProductionStorageEngine: %v", err)
+	}
+	return se
+}
+
+func TestTracing_OperationSpans(t *testing.T) {
+	rec := &tracing.Recorder{}
+	se := openTracedEngine(t, t.TempDir(), nil)
+	defer se.Close()
+	se.SetTracer(rec)
+	ctx, request := rec.Start(context.Background(), "request")
+
+	if err := se.PutCtx(ctx, "users", "id", types.IntKey(1), `{"id": 1, "name": "ana"}`); err != nil {
+		t.Fatalf("PutCtx: %v", err)
+	}
+	spans := rec.Spans()
+	put := findSpan(t, spans, "storage.Put", "request")
+	if put.Attributes["storage.table"] != "users" || put.Attributes["storage.index"] != "id" {
+		t.Fatalf("storage.Put attributes = %v", put.Attributes)
+	}
+	for _, name := range []string{"btree.get", "wal.append", "heap.write", "btree.upsert"} {
+		findSpan(t, spans, name, "storage.Put")
+	}
+	findSpan(t, spans, "wal.fsync", "wal.append")
+
+	rec.Reset()
+	if _, found, err := se.GetCtx(ctx, "users", "id", types.IntKey(1)); err != nil || !found {
+		t.Fatalf("GetCtx = %v, %v", found, err)
+	}
+	spans = rec.Spans()
+	if get := findSpan(t, spans, "storage.Get", "request"); get.Attributes["storage.found"] != true {
+		t.Fatalf("storage.Get attributes = %v", get.Attributes)
+	}
+	findSpan(t, spans, "btree.get", "storage.Get")
+	findSpan(t, spans, "heap.read", "storage.Get")
+
+	rec.Reset()
+	if _, err := se.ScanCtx(ctx, "users", "id", query.GreaterOrEqual(types.IntKey(0))); err != nil {
+		t.Fatalf("ScanCtx: %v", err)
+	}
+	spans = rec.Spans()
+	if scan := findSpan(t, spans, "storage.Scan", "request"); scan.Attributes["storage.rows"] != 1 {
+		t.Fatalf("storage.Scan attributes = %v", scan.Attributes)
+	}
+	findSpan(t, spans, "btree.scan", "storage.Scan")
+
+	rec.Reset()
+	tx := se.BeginWriteTransaction()
+	if err := tx.InsertRow("users", `{"id": 2, "name": "bia"}`, nil); err != nil {
+		t.Fatalf("InsertRow: %v", err)
+	}
+	if err := tx.CommitCtx(ctx); err != nil {
+		t.Fatalf("CommitCtx: %v", err)
+	}
+	spans = rec.Spans()
+	if commit := findSpan(t, spans, "storage.Commit", "request"); commit.Attributes["storage.ops"] != 1 {
+		t.Fatalf("storage.Commit attributes = %v", commit.Attributes)
+	}
+	findSpan(t, spans, "wal.append", "storage.Commit")
+	findSpan(t, spans, "commit.apply", "storage.Commit")
+	findSpan(t, spans, "heap.write", "commit.apply")
+
+	rec.Reset()
+	if err := se.Vacuum("users"); err != nil {
+		t.Fatalf("Vacuum: %v", err)
+	}
+	findSpan(t, rec.Spans(), "heap.vacuum", "storage.Vacuum")
+
+	request.End()
+	rec.Reset()
+	se.SetTracer(nil)
+	if err := se.Put("users", "id", types.IntKey(3), `{"id": 3}`); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if spans := rec.Spans(); len(spans) != 0 {
+		t.Fatalf("spans after SetTracer(nil) = %+v", spans)
+	}
+}
+
+func TestTracing_ErrorsAreRecorded(t *testing.T) {
+	rec := &tracing.Recorder{}
+	se := openTracedEngine(t, t.TempDir(), nil)
+	defer se.Close()
+	se.SetTracer(rec)
+
+	err := se.PutCtx(context.Background(), "missing", "id", types.IntKey(1), `{"id": 1}`)
+	if err == nil {
+		t.Fatal("PutCtx on a missing table should fail")
+	}
+	if put := findSpan(t, rec.Spans(), "storage.Put", ""); put.Err != err {
+		t.Fatalf("storage.Put error = %v, want %v", put.Err, err)
+	}
+}
+
+func TestTracing_RecoverAdoptsWALTracer(t *testing.T) {
+	dir := t.TempDir()
+	se := openTracedEngine(t, dir, nil)
+	if err := se.InsertRow("users", `{"id": 1, "name": "ana"}`, nil); err != nil {
+		t.Fatalf("InsertRow: %v", err)
+	}
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	rec := &tracing.Recorder{}
+	se = openTracedEngine(t, dir, rec)
+	defer se.Close()
+	spans := rec.Spans()
+	recovery := findSpan(t, spans, "storage.Recover", "")
+	if recovery.Err != nil {
+		t.Fatalf("storage.Recover error = %v", recovery.Err)
+	}
+	for _, phase := range []string{"recover.analysis", "recover.redo.physical", "recover.redo.logical", "recover.undo"} {
+		findSpan(t, spans, phase, "storage.Recover")
+	}
+}
@@ -1,11 +1,14 @@
 package storage
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
 
+	"github.com/bobboyms/storage-engine/pkg/btree"
 	storageerrors "github.com/bobboyms/storage-engine/pkg/errors"
+	"github.com/bobboyms/storage-engine/pkg/tracing"
 	"github.com/bobboyms/storage-engine/pkg/types"
 	"github.com/bobboyms/storage-engine/pkg/wal"
 )
@@ -26,6 +29,26 @@ func (e *SerializationConflictError) Unwrap() error {
 	return ErrSerializationConflict
 }
 
+// ErrWriteConflict is matched by every *WriteConflictError.
+var ErrWriteConflict = errors.New("storage: write conflict")
+
+// WriteConflictError aborts a commit whose write set holds a key another
+// transaction committed after this one's snapshot (first committer wins).
+// The transaction is finished; callers retry it from the start.
+type WriteConflictError struct {
+	TableName string
+	IndexName string
+	Key       types.Comparable
+}
+
+func (e *WriteConflictError) Error() string {
+	return fmt.Sprintf("storage: write conflict on %s.%s key %v", e.TableName, e.IndexName, e.Key)
+}
+
+func (e *WriteConflictError) Unwrap() error {
+	return ErrWriteConflict
+}
+
 // WriteTransaction accumulates operations for atomic commit
 type WriteTransaction struct {
 	engine    *StorageEngine
@@ -53,6 +76,10 @@ type writeOp struct {
 	key       types.Comparable
 	document  string
 	lsn       uint64
+	// keys and row are set for EntryMultiInsert ops only; indexName/key
+	// then name the primary index.
+	keys map[string]types.Comparable
+	row  []byte
 }
 
 // BeginWriteTransaction starts a new write transaction
@@ -100,6 +127,14 @@ func (tx *WriteTransaction) Put(tableName string, indexName string, key types.Co
 			TypeName: index.Type.String(),
 		}
 	}
+	if bsonDoc, errBson := JsonToBson(document); errBson == nil {
+		err = validateDocument(table, bsonDoc)
+	} else {
+		err = validateOpaqueDocument(table)
+	}
+	if err != nil {
+		return err
+	}
 
 	resource, err := lockResourceForKey(tableName, indexName, key)
 	if err != nil {
@@ -162,6 +197,105 @@ func (tx *WriteTransaction) Del(tableName string, indexName string, key types.Co
 	return nil
 }
 
+// InsertRow adds a whole-row insert to the transaction buffer. Like
+// StorageEngine.InsertRow it updates every index of the table and fails
+// when the primary key already holds a live row; the write is logged as a
+// single multi-index entry between the BEGIN and COMMIT markers.
+func (tx *WriteTransaction) InsertRow(tableName string, doc string, keys map[string]types.Comparable) error {
+	return tx.bufferRow(tableName, doc, keys, true)
+}
+
+// UpsertRow adds a whole-row write to the transaction buffer, replacing
+// the row under the same primary key if there is one. Like
+// StorageEngine.UpsertRow it updates every index of the table; the write
+// is logged between the BEGIN and COMMIT markers, so recovery discards it
+// unless the transaction committed.
+func (tx *WriteTransaction) UpsertRow(tableName string, doc string, keys map[string]types.Comparable) error {
+	return tx.bufferRow(tableName, doc, keys, false)
+}
+
+// bufferRow buffers a multi-index write of a whole row. With mustBeNew it
+// fails when the primary key already holds a live row.
+func (tx *WriteTransaction) bufferRow(tableName string, doc string, keys map[string]types.Comparable, mustBeNew bool) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if err := tx.ensureWritableLocked(); err != nil {
+		return err
+	}
+
+	table, err := tx.engine.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return err
+	}
+	bsonData, keys, err := prepareRowDocument(table, doc, keys)
+	if err != nil {
+		return err
+	}
+	primary, primaryKey, err := primaryIndexAndKey(table, keys)
+	if err != nil {
+		return err
+	}
+
+	resources, err := lockResourcesForKeys(tableName, keys)
+	if err != nil {
+		return err
+	}
+	for _, resource := range resources {
+		if err := tx.acquireLockLocked(resource); err != nil {
+			return err
+		}
+	}
+	for indexName, key := range keys {
+		resource, _ := lockResourceForKey(tableName, indexName, key)
+		if err := tx.checkReadWriteConflictLocked(resource, tableName, indexName, key); err != nil {
+			return err
+		}
+	}
+
+	if mustBeNew {
+		primaryResource, err := lockResourceForKey(tableName, primary.Name, primaryKey)
+		if err != nil {
+			return err
+		}
+		exists, err := tx.rowExistsLocked(primaryResource, tableName, primary.Name, primaryKey)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("duplicate key error: key %v already exists in index %s", primaryKey, primary.Name)
+		}
+	}
+
+	tx.writeSet = append(tx.writeSet, writeOp{
+		opType:    wal.EntryMultiInsert,
+		tableName: tableName,
+		indexName: primary.Name,
+		key:       primaryKey,
+		document:  doc,
+		keys:      keys,
+		row:       bsonData,
+	})
+	for _, resource := range resources {
+		tx.pending[resource] = len(tx.writeSet) - 1
+	}
+	return nil
+}
+
+// rowExistsLocked reports whether key names a live row as seen by this
+// transaction: its own buffered writes first, then the latest committed
+// state (the row lock is already held, so that state cannot change).
+func (tx *WriteTransaction) rowExistsLocked(resource string, tableName string, indexName string, key types.Comparable) (bool, error) {
+	if idx, ok := tx.pending[resource]; ok {
+		return tx.writeSet[idx].opType != wal.EntryDelete, nil
+	}
+	current, err := tx.currentCommittedObservationLocked(tableName, indexName, key)
+	if err != nil {
+		return false, err
+	}
+	return current.found, nil
+}
+
 func (tx *WriteTransaction) Get(tableName string, indexName string, key types.Comparable) (string, bool, error) {
 	tx.mu.Lock()
 	defer tx.mu.Unlock()
@@ -190,11 +324,78 @@ func (tx *WriteTransaction) Get(tableName string, indexName string, key types.Co
 		found:     record.Found,
 		createLSN: record.CreateLSN,
 	}
-	return record.Document, record.Found, nil
+	return record.Document(), record.Found, nil
+}
+
+// GetForUpdate reads the latest committed version of key and locks it
+// until Commit or Rollback, so no other transaction can write the key in
+// between (SELECT ... FOR UPDATE). Waiting follows the LockManager rules:
+// a deadlock aborts the youngest transaction with ErrDeadlockVictim and a
+// wait longer than the configured timeout fails with ErrLockWaitTimeout;
+// either way this transaction is finished.
+func (tx *WriteTransaction) GetForUpdate(tableName string, indexName string, key types.Comparable) (string, bool, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if err := tx.ensureWritableLocked(); err != nil {
+		return "", false, err
+	}
+
+	table, err := tx.engine.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return "", false, err
+	}
+	if _, err := table.GetIndex(indexName); err != nil {
+		return "", false, err
+	}
+
+	resource, err := lockResourceForKey(tableName, indexName, key)
+	if err != nil {
+		return "", false, err
+	}
+	if err := tx.acquireLockLocked(resource); err != nil {
+		return "", false, err
+	}
+	if idx, ok := tx.pending[resource]; ok {
+		op := tx.writeSet[idx]
+		if op.opType == wal.EntryDelete {
+			return "", false, nil
+		}
+		return op.document, true, nil
+	}
+
+	// With the lock held the latest committed version is stable, so it is
+	// read instead of the snapshot and recorded as what this tx observed.
+	record, err := tx.latestCommittedRecordLocked(tableName, indexName, key)
+	if err != nil {
+		return "", false, err
+	}
+	tx.readSet[resource] = readObservation{
+		found:     record.Found,
+		createLSN: record.CreateLSN,
+	}
+	return record.Document(), record.Found, nil
 }
 
 // Commit persists all operations atomically
-func (tx *WriteTransaction) Commit() (err error) {
+func (tx *WriteTransaction) Commit() error {
+	return tx.CommitCtx(context.Background())
+}
+
+// CommitCtx is Commit bounded by ctx. ctx is checked while waiting for
+// the engine and before each operation is logged; a cancelled commit
+// logs an ABORT and finishes the transaction as rolled back, returning
+// ctx.Err(). Once the COMMIT record is written the commit completes,
+// since recovery would replay it anyway.
+//
+// A commit that fails after that point, writing the COMMIT record or
+// applying the writes, leaves the engine degraded: every call returns
+// ErrEngineDegraded, so no partial effect is visible, until Recover
+// replays the WAL and applies the transaction in full or, without a
+// durable COMMIT, not at all.
+func (tx *WriteTransaction) CommitCtx(ctx context.Context) (err error) {
+	ctx, span := tx.engine.tracer.Start(ctx, "storage.Commit")
+	defer func() { tracing.End(span, err) }()
 	tx.mu.Lock()
 	defer tx.mu.Unlock()
 	if tx.engine.LockManager != nil {
@@ -211,6 +412,9 @@ func (tx *WriteTransaction) Commit() (err error) {
 	if err := tx.ensureWritableLocked(); err != nil {
 		return err
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
 	se := tx.engine
 	se.opMu.Lock()
@@ -218,17 +422,23 @@ func (tx *WriteTransaction) Commit() (err error) {
 	if err := se.runtimeReadyError(); err != nil {
 		return err
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := tx.checkWriteConflictsLocked(); err != nil {
+		return err
+	}
 
 	if len(tx.writeSet) == 0 {
 		if se.WAL != nil {
 			beginLSN := se.lsnTracker.Next()
-			if err := tx.writeWALMarker(wal.EntryBegin, beginLSN); err != nil {
+			if err := tx.writeWALMarker(ctx, wal.EntryBegin, beginLSN); err != nil {
 				return err
 			}
 			tx.walBegun = true
 
 			commitLSN := se.lsnTracker.Next()
-			if err := tx.writeWALMarker(wal.EntryCommit, commitLSN); err != nil {
+			if err := tx.writeWALMarker(ctx, wal.EntryCommit, commitLSN); err != nil {
 				return err
 			}
 		}
@@ -236,28 +446,40 @@ func (tx *WriteTransaction) Commit() (err error) {
 		return nil
 	}
 
+	if tracing.Recording(span) {
+		span.SetAttribute("storage.tx_id", tx.txID)
+		span.SetAttribute("storage.ops", len(tx.writeSet))
+	}
 	beginLSN := se.lsnTracker.Next()
 	for i := range tx.writeSet {
 		tx.writeSet[i].lsn = se.lsnTracker.Next()
 	}
 
 	// 1. WAL Writing (Phase 1: Persistence)
+	var payloads [][]byte
 	if se.WAL != nil {
 		// Write BEGIN
-		if err := tx.writeWALMarker(wal.EntryBegin, beginLSN); err != nil {
+		if err := tx.writeWALMarker(ctx, wal.EntryBegin, beginLSN); err != nil {
 			return err
 		}
 		tx.walBegun = true
 
 		// Write Ops
+		payloads = make([][]byte, len(tx.writeSet))
 		for i := range tx.writeSet {
+			if err := ctx.Err(); err != nil {
+				_ = tx.rollbackWAL()
+				return err
+			}
 			op := &tx.writeSet[i]
 			opLSN := op.lsn
 
 			var payload []byte
 			var err error
 
-			if op.opType == wal.EntryDelete {
+			if op.opType == wal.EntryMultiInsert {
+				payload, err = SerializeMultiIndexEntry(op.tableName, op.keys, op.row)
+			} else if op.opType == wal.EntryDelete {
 				payload, err = SerializeDocumentEntry(op.tableName, op.indexName, op.key, nil)
 			} else {
 				// Convert doc to bytes (BSON conversion logic duplicated from Put)
@@ -275,6 +497,7 @@ func (tx *WriteTransaction) Commit() (err error) {
 				_ = tx.rollbackWAL()
 				return err
 			}
+			payloads[i] = payload
 
 			entry := wal.AcquireEntry()
 			entry.Header.Magic = wal.WALMagic
@@ -286,7 +509,7 @@ func (tx *WriteTransaction) Commit() (err error) {
 			entry.Header.CRC32 = wal.CalculateCRC32(payload)
 			entry.Payload = append(entry.Payload, payload...)
 
-			if err := se.WAL.WriteEntry(entry); err != nil {
+			if err := se.WAL.WriteEntryCtx(ctx, entry); err != nil {
 				wal.ReleaseEntry(entry)
 				_ = tx.rollbackWAL()
 				return fmt.Errorf("wal write failed: %w", err)
@@ -296,22 +519,47 @@ func (tx *WriteTransaction) Commit() (err error) {
 
 		// Write COMMIT
 		commitLSN := se.lsnTracker.Next()
-		if err := tx.writeWALMarker(wal.EntryCommit, commitLSN); err != nil {
+		if err := se.logWALClock(ctx, commitLSN); err != nil {
+			_ = tx.rollbackWAL()
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			_ = tx.rollbackWAL()
+			return err
+		}
+		// Canceling in the middle of writing the COMMIT would leave the
+		// outcome open; from here on only an I/O error stops the commit.
+		if err := tx.writeWALMarker(context.WithoutCancel(ctx), wal.EntryCommit, commitLSN); err != nil {
+			// The COMMIT may or may not have reached the disk: only
+			// recovery, reading the WAL, decides whether the transaction
+			// holds. Until then nothing of it is visible.
+			commitErr := fmt.Errorf("commit record of tx %d failed, outcome decided by recovery: %w", tx.txID, err)
+			se.markDegraded(commitErr)
+			return commitErr
+		}
 	}
 	tx.committed = true
 
 	// 2. Memory Application (Phase 2: Visibility)
 	// Apply all changes to Heap and Trees under the engine-wide write barrier.
+	applyCtx, applySpan := se.tracer.Start(ctx, "commit.apply")
+	defer applySpan.End()
 	for i, op := range tx.writeSet {
-		if err := tx.applyCommittedWriteOp(i+1, len(tx.writeSet), op); err != nil {
+		if err := tx.applyCommittedWriteOp(applyCtx, i+1, len(tx.writeSet), op); err != nil {
 			applyErr := fmt.Errorf("post-commit apply failed for tx %d at op %d/%d (%s.%s): %w", tx.txID, i+1, len(tx.writeSet), op.tableName, op.indexName, err)
 			se.markDegraded(applyErr)
+			applySpan.RecordError(applyErr)
 			return applyErr
 		}
 	}
 
+	// Subscribers hear of the writes only once they are readable, so a
+	// consumer that reads back the row sees the new version.
+	if payloads != nil {
+		for i, op := range tx.writeSet {
+			se.changes.publish(op.opType, payloads[i], op.lsn)
+		}
+	}
 	return nil
 }
 
@@ -335,7 +583,7 @@ func (tx *WriteTransaction) Rollback() error {
 	if se.WAL != nil {
 		if !tx.walBegun {
 			beginLSN := se.lsnTracker.Next()
-			if err := tx.writeWALMarker(wal.EntryBegin, beginLSN); err != nil {
+			if err := tx.writeWALMarker(context.Background(), wal.EntryBegin, beginLSN); err != nil {
 				return err
 			}
 			tx.walBegun = true
@@ -418,6 +666,71 @@ func (tx *WriteTransaction) checkReadWriteConflictLocked(resource string, tableN
 	return nil
 }
 
+// checkWriteConflictsLocked enforces first-committer-wins for
+// RepeatableRead transactions: every unique key in the write set must
+// still hold the version this transaction saw — the one it read, or else
+// the one in its snapshot. Commit calls it under the exclusive opMu, so no
+// other write lands between the check and the apply. Non-unique indexes
+// only gain postings and never conflict; ReadCommitted relies on the
+// LockManager alone.
+func (tx *WriteTransaction) checkWriteConflictsLocked() error {
+	if tx.readView == nil || tx.readView.Level == ReadCommitted {
+		return nil
+	}
+	se := tx.engine
+	latest := &Transaction{
+		SnapshotLSN: se.lsnTracker.Current(),
+		Level:       RepeatableRead,
+		engine:      se,
+	}
+
+	checked := make(map[string]struct{}, len(tx.writeSet))
+	for _, op := range tx.writeSet {
+		table, err := se.TableMetaData.GetTableByName(op.tableName)
+		if err != nil {
+			return err
+		}
+		index, err := table.GetIndex(op.indexName)
+		if err != nil {
+			return err
+		}
+		if index.IsMultiValue() {
+			continue
+		}
+		resource, err := lockResourceForKey(op.tableName, op.indexName, op.key)
+		if err != nil {
+			return err
+		}
+		if _, ok := checked[resource]; ok {
+			continue
+		}
+		checked[resource] = struct{}{}
+
+		seen, ok := tx.readSet[resource]
+		if !ok {
+			record, err := se.visibleRecordForKey(context.Background(), tx.readView, op.tableName, op.indexName, op.key)
+			if err != nil {
+				return err
+			}
+			seen = readObservation{found: record.Found, createLSN: record.CreateLSN}
+		}
+		record, err := se.visibleRecordForKey(context.Background(), latest, op.tableName, op.indexName, op.key)
+		if err != nil {
+			return err
+		}
+		if seen != (readObservation{found: record.Found, createLSN: record.CreateLSN}) {
+			conflictErr := &WriteConflictError{
+				TableName: op.tableName,
+				IndexName: op.indexName,
+				Key:       op.key,
+			}
+			tx.abortErr = conflictErr
+			return conflictErr
+		}
+	}
+	return nil
+}
+
 func (tx *WriteTransaction) lockManagerAbortErrorLocked() error {
 	if tx.engine.LockManager == nil {
 		return nil
@@ -443,15 +756,15 @@ func (tx *WriteTransaction) readCommittedRecordLocked(tableName string, indexNam
 		return visibleRecord{}, fmt.Errorf("transaction already finished")
 	}
 	tx.readView.refreshSnapshot()
-	return se.visibleRecordForKey(tx.readView, tableName, indexName, key)
+	return se.visibleRecordForKey(context.Background(), tx.readView, tableName, indexName, key)
 }
 
-func (tx *WriteTransaction) currentCommittedObservationLocked(tableName string, indexName string, key types.Comparable) (readObservation, error) {
+func (tx *WriteTransaction) latestCommittedRecordLocked(tableName string, indexName string, key types.Comparable) (visibleRecord, error) {
 	se := tx.engine
 	se.opMu.RLock()
 	defer se.opMu.RUnlock()
 	if err := se.runtimeReadyError(); err != nil {
-		return readObservation{}, err
+		return visibleRecord{}, err
 	}
 
 	view := &Transaction{
@@ -459,7 +772,11 @@ func (tx *WriteTransaction) currentCommittedObservationLocked(tableName string,
 		Level:       RepeatableRead,
 		engine:      se,
 	}
-	record, err := se.visibleRecordForKey(view, tableName, indexName, key)
+	return se.visibleRecordForKey(context.Background(), view, tableName, indexName, key)
+}
+
+func (tx *WriteTransaction) currentCommittedObservationLocked(tableName string, indexName string, key types.Comparable) (readObservation, error) {
+	record, err := tx.latestCommittedRecordLocked(tableName, indexName, key)
 	if err != nil {
 		return readObservation{}, err
 	}
@@ -469,7 +786,7 @@ func (tx *WriteTransaction) currentCommittedObservationLocked(tableName string,
 	}, nil
 }
 
-func (tx *WriteTransaction) writeWALMarker(typeID uint8, lsn uint64) error {
+func (tx *WriteTransaction) writeWALMarker(ctx context.Context, typeID uint8, lsn uint64) error {
 	entry := wal.AcquireEntry()
 	entry.Header.Magic = wal.WALMagic
 	entry.Header.Version = txAwareWALVersion
@@ -484,7 +801,7 @@ func (tx *WriteTransaction) writeWALMarker(typeID uint8, lsn uint64) error {
 		return nil
 	}
 
-	err := tx.engine.WAL.WriteEntry(entry)
+	err := tx.engine.WAL.WriteEntryCtx(ctx, entry)
 	wal.ReleaseEntry(entry)
 	return err
 }
@@ -494,7 +811,7 @@ func (tx *WriteTransaction) rollbackWAL() error {
 		return nil
 	}
 	abortLSN := tx.engine.lsnTracker.Next()
-	return tx.writeWALMarker(wal.EntryAbort, abortLSN)
+	return tx.writeWALMarker(context.Background(), wal.EntryAbort, abortLSN)
 }
 
 func getTypeFromKey(k types.Comparable) DataType {
@@ -513,12 +830,18 @@ func getTypeFromKey(k types.Comparable) DataType {
 		return TypeFloat
 	case types.DateKey:
 		return TypeDate
+	case types.DecimalKey:
+		return TypeDecimal
+	case types.UUIDKey:
+		return TypeUUID
+	case types.BytesKey:
+		return TypeBytes
 	default:
 		return TypeVarchar // Fallback
 	}
 }
 
-func (tx *WriteTransaction) applyCommittedWriteOp(step int, total int, op writeOp) error {
+func (tx *WriteTransaction) applyCommittedWriteOp(ctx context.Context, step int, total int, op writeOp) error {
 	table, err := tx.engine.TableMetaData.GetTableByName(op.tableName)
 	if err != nil {
 		return err
@@ -541,7 +864,20 @@ func (tx *WriteTransaction) applyCommittedWriteOp(step int, total int, op writeO
 		return err
 	}
 
-	if op.opType == wal.EntryDelete {
+	if op.opType == wal.EntryMultiInsert {
+		table.Lock()
+		err = tx.engine.applyRowVersion(ctx, table, op.keys, op.row, op.lsn, func() error {
+			return tx.engine.runPostCommitApplyHook(withPostCommitStage(info, postCommitStageAfterHeapMutation))
+		})
+		table.Unlock()
+		if err != nil {
+			return err
+		}
+	} else if postings, ok := index.postings(); ok {
+		if err := tx.applyCommittedPostingOp(table, postings, op, info); err != nil {
+			return err
+		}
+	} else if op.opType == wal.EntryDelete {
 		err = index.Tree.Upsert(op.key, func(oldOffset int64, exists bool) (int64, error) {
 			if !exists {
 				return 0, nil
@@ -593,6 +929,30 @@ func (tx *WriteTransaction) applyCommittedWriteOp(step int, total int, op writeO
 	return nil
 }
 
+// applyCommittedPostingOp is applyCommittedWriteOp for non-unique indexes:
+// a put adds a fresh posting instead of chaining onto an existing row.
+func (tx *WriteTransaction) applyCommittedPostingOp(table *Table, postings btree.MultiValueTree, op writeOp, info postCommitApplyInfo) error {
+	if op.opType == wal.EntryDelete {
+		if _, err := tombstonePostings(table, postings, op.key, op.lsn); err != nil {
+			return err
+		}
+		return tx.engine.runPostCommitApplyHook(withPostCommitStage(info, postCommitStageAfterHeapMutation))
+	}
+
+	bsonData, err := tx.opDocumentBytes(op)
+	if err != nil {
+		return err
+	}
+	offset, err := table.Heap.Write(bsonData, op.lsn, -1)
+	if err != nil {
+		return err
+	}
+	if err := tx.engine.runPostCommitApplyHook(withPostCommitStage(info, postCommitStageAfterHeapMutation)); err != nil {
+		return err
+	}
+	return insertPostingWithLSN(postings, op.key, offset, op.lsn)
+}
+
 func (tx *WriteTransaction) opDocumentBytes(op writeOp) ([]byte, error) {
 	bsonDoc, errBson := JsonToBson(op.document)
 	if errBson == nil {
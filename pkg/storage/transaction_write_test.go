@@ -6,6 +6,7 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/bobboyms/storage-engine/pkg/query"
 	"github.com/bobboyms/storage-engine/pkg/types"
 	"github.com/bobboyms/storage-engine/pkg/wal"
 	"time"
@@ -621,3 +622,156 @@ func TestWriteTransaction_PostCommitApplyFailureDegradesRuntimeAndRecoveryConver
 		t.Fatalf("recovered key2 mismatch: found=%v doc=%q", found2, doc2)
 	}
 }
+
+// TestWriteTransaction_PartialRowApplyIsHiddenUntilRecover: applying a
+// two-row commit fails after the first row. Nothing of the transaction is
+// visible, not even through the secondary index, and Recover on the same
+// engine completes the commit.
+func TestWriteTransaction_PartialRowApplyIsHiddenUntilRecover(t *testing.T) {
+	tmpDir := t.TempDir()
+	walPath := filepath.Join(tmpDir, "wal.log")
+	hm, err := NewHeapForTable(HeapFormatV2, filepath.Join(tmpDir, "heap.data"))
+	if err != nil {
+		t.Fatalf("new heap: %v", err)
+	}
+	tableMgr := NewTableMenager()
+	err = tableMgr.NewTable("users", []Index{
+		{Name: "id", Primary: true, Type: TypeInt},
+		{Name: "email", Type: TypeVarchar},
+	}, 4, hm)
+	if err != nil {
+		t.Fatalf("new table: %v", err)
+	}
+	walWriter, err := wal.NewWALWriter(walPath, wal.DefaultOptions())
+	if err != nil {
+		t.Fatalf("new wal writer: %v", err)
+	}
+	se, err := NewStorageEngine(tableMgr, walWriter)
+	if err != nil {
+		walWriter.Close()
+		t.Fatalf("new storage engine: %v", err)
+	}
+	defer se.Close()
+
+	tx := se.BeginWriteTransaction()
+	if err := tx.InsertRow("users", `{"id": 1, "email": "ana@example.com"}`, nil); err != nil {
+		t.Fatalf("tx InsertRow 1: %v", err)
+	}
+	if err := tx.InsertRow("users", `{"id": 2, "email": "bia@example.com"}`, nil); err != nil {
+		t.Fatalf("tx InsertRow 2: %v", err)
+	}
+	injectedErr := errors.New("injected heap failure")
+	se.testHooks.onPostCommitApplyStage = func(info postCommitApplyInfo) error {
+		if info.Step == 2 && info.Stage == postCommitStageBeforeOp {
+			return injectedErr
+		}
+		return nil
+	}
+	if err := tx.Commit(); !errors.Is(err, injectedErr) {
+		t.Fatalf("Commit = %v, want the injected failure", err)
+	}
+	se.testHooks.onPostCommitApplyStage = nil
+
+	if _, found, err := se.Get("users", "email", types.VarcharKey("ana@example.com")); !errors.Is(err, ErrEngineDegraded) || found {
+		t.Fatalf("Get of the applied row = %v, %v; want it hidden behind ErrEngineDegraded", found, err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback after a failed commit: %v", err)
+	}
+
+	if err := se.Recover(walPath); err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	for _, email := range []string{"ana@example.com", "bia@example.com"} {
+		if _, found, err := se.Get("users", "email", types.VarcharKey(email)); err != nil || !found {
+			t.Fatalf("Get(email=%s) after Recover = %v, %v", email, found, err)
+		}
+	}
+	docs, err := se.Scan("users", "id", query.GreaterOrEqual(types.IntKey(0)))
+	if err != nil || len(docs) != 2 {
+		t.Fatalf("Scan after Recover = %v, %v; want both rows once", docs, err)
+	}
+}
+
+// TestWriteTransaction_ChangesPublishedAfterApply: change streams hear of
+// a committed transaction only once it is applied, and not at all when the
+// apply fails.
+func TestWriteTransaction_ChangesPublishedAfterApply(t *testing.T) {
+	tmpDir := t.TempDir()
+	hm, err := NewHeapForTable(HeapFormatV2, filepath.Join(tmpDir, "heap.data"))
+	if err != nil {
+		t.Fatalf("new heap: %v", err)
+	}
+	tableMgr := NewTableMenager()
+	if err := tableMgr.NewTable("users", []Index{{Name: "id", Primary: true, Type: TypeInt}}, 4, hm); err != nil {
+		t.Fatalf("new table: %v", err)
+	}
+	walWriter, err := wal.NewWALWriter(filepath.Join(tmpDir, "wal.log"), wal.DefaultOptions())
+	if err != nil {
+		t.Fatalf("new wal writer: %v", err)
+	}
+	se, err := NewStorageEngine(tableMgr, walWriter)
+	if err != nil {
+		_ = walWriter.Close()
+		t.Fatalf("new storage engine: %v", err)
+	}
+	defer se.Close()
+
+	cs, err := se.Watch("users", 0)
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	defer cs.Close()
+
+	publishedEarly := false
+	se.testHooks.onPostCommitApplyStage = func(info postCommitApplyInfo) error {
+		if info.Stage == postCommitStageAfterIndexInstall {
+			select {
+			case <-cs.C:
+				publishedEarly = true
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+		return nil
+	}
+	tx := se.BeginWriteTransaction()
+	if err := tx.Put("users", "id", types.IntKey(1), `{"id":1,"name":"Alice"}`); err != nil {
+		t.Fatalf("tx put: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if publishedEarly {
+		t.Fatal("change published before the transaction was applied")
+	}
+	select {
+	case ev := <-cs.C:
+		if ev.Op != ChangeInsert || ev.Key != types.IntKey(1) {
+			t.Fatalf("expected INSERT of 1, got %s of %v", ev.Op, ev.Key)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the committed change")
+	}
+
+	injectedErr := errors.New("injected post-commit apply failure")
+	se.testHooks.onPostCommitApplyStage = func(info postCommitApplyInfo) error {
+		if info.Stage == postCommitStageBeforeOp {
+			return injectedErr
+		}
+		return nil
+	}
+	failed := se.BeginWriteTransaction()
+	if err := failed.Put("users", "id", types.IntKey(2), `{"id":2,"name":"Bob"}`); err != nil {
+		t.Fatalf("tx put: %v", err)
+	}
+	if err := failed.Commit(); !errors.Is(err, injectedErr) {
+		t.Fatalf("expected injected commit error, got %v", err)
+	}
+	select {
+	case ev, ok := <-cs.C:
+		if ok {
+			t.Fatalf("expected no change for a failed apply, got %s of %v", ev.Op, ev.Key)
+		}
+	case <-time.After(100 * time.Millisecond):
+	}
+}
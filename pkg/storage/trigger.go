@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// TriggerEvent selects when a trigger runs. Events combine with |.
+type TriggerEvent uint8
+
+const (
+	BeforeInsert TriggerEvent = 1 << iota
+	BeforeUpdate
+	BeforeDelete
+	AfterInsert
+	AfterUpdate
+	AfterDelete
+)
+
+var triggerEventNames = []string{"BeforeInsert", "BeforeUpdate", "BeforeDelete", "AfterInsert", "AfterUpdate", "AfterDelete"}
+
+func (e TriggerEvent) String() string {
+	var names []string
+	for i, name := range triggerEventNames {
+		if e&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Sprintf("TriggerEvent(%d)", uint8(e))
+	}
+	return strings.Join(names, "|")
+}
+
+const (
+	beforeTriggers = BeforeInsert | BeforeUpdate | BeforeDelete
+	allTriggers    = beforeTriggers | AfterInsert | AfterUpdate | AfterDelete
+)
+
+// RowChange is the row write a trigger runs for. Key is the primary key.
+// Old is the live document being replaced or deleted, New the document
+// being written; each is empty when there is none.
+type RowChange struct {
+	Event TriggerEvent
+	Table string
+	Key   types.Comparable
+	Old   string
+	New   string
+}
+
+// TriggerFunc is a trigger. An error from a Before trigger vetoes the
+// write; an error from an After trigger is returned to the caller, but the
+// write has already been made durable.
+type TriggerFunc func(change RowChange) error
+
+type trigger struct {
+	events TriggerEvent
+	fn     TriggerFunc
+}
+
+type triggerSet struct {
+	mu      sync.RWMutex
+	byTable map[string][]trigger
+}
+
+func newTriggerSet() *triggerSet {
+	return &triggerSet{byTable: make(map[string][]trigger)}
+}
+
+// RegisterTrigger runs fn on the row writes of tableName matching events,
+// in registration order. Triggers run synchronously on the row API:
+// InsertRow, UpsertRow, UpdateRow, DeleteRow, a whole-row Put and the
+// typed collections. Before triggers run under the row's locks, after
+// the write is validated and before it is logged; After triggers run once
+// the write is applied and its locks are released. Triggers live in
+// memory only: register them again after opening the engine.
+//
+// A trigger runs inside the engine's write path and must not call back
+// into the engine.
+func (se *StorageEngine) RegisterTrigger(tableName string, events TriggerEvent, fn TriggerFunc) error {
+	if fn == nil {
+		return fmt.Errorf("storage: trigger function is required")
+	}
+	if events == 0 || events&^allTriggers != 0 {
+		return fmt.Errorf("storage: invalid trigger events %v", events)
+	}
+	if _, err := se.TableMetaData.GetTableByName(tableName); err != nil {
+		return err
+	}
+
+	ts := se.triggers
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.byTable[tableName] = append(ts.byTable[tableName], trigger{events: events, fn: fn})
+	return nil
+}
+
+// has reports whether tableName has a trigger for any of events.
+func (ts *triggerSet) has(tableName string, events TriggerEvent) bool {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	for _, t := range ts.byTable[tableName] {
+		if t.events&events != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// fire runs the triggers of change.Table for change.Event, stopping at the
+// first error.
+func (ts *triggerSet) fire(change RowChange) error {
+	ts.mu.RLock()
+	triggers := ts.byTable[change.Table]
+	ts.mu.RUnlock()
+	for _, t := range triggers {
+		if t.events&change.Event == 0 {
+			continue
+		}
+		if err := t.fn(change); err != nil {
+			return fmt.Errorf("storage: %v trigger on %s: %w", change.Event, change.Table, err)
+		}
+	}
+	return nil
+}
+
+// rowTriggers carries the before/after pair of one row write.
+type rowTriggers struct {
+	ts     *triggerSet
+	change RowChange
+	after  TriggerEvent
+}
+
+// newRowTriggers prepares the triggers of a write to the row under key,
+// or returns nil when the table has none for it. live tells an update
+// from an insert and oldOffset is then the live version; deleted marks a
+// delete. The caller holds the table lock.
+func (se *StorageEngine) newRowTriggers(table *Table, key types.Comparable, oldOffset int64, live bool, newRaw []byte, deleted bool) (*rowTriggers, error) {
+	before, after := BeforeUpdate, AfterUpdate
+	switch {
+	case deleted:
+		before, after = BeforeDelete, AfterDelete
+	case !live:
+		before, after = BeforeInsert, AfterInsert
+	}
+	if !se.triggers.has(table.Name, before|after) {
+		return nil, nil
+	}
+	rt := &rowTriggers{ts: se.triggers, after: after}
+	rt.change = RowChange{Event: before, Table: table.Name, Key: key}
+	if live {
+		oldRaw, _, err := table.Heap.Read(oldOffset)
+		if err != nil {
+			return nil, fmt.Errorf("heap read failed: %w", err)
+		}
+		rt.change.Old = visibleRecord{Raw: oldRaw, Found: true}.Document()
+	}
+	if !deleted {
+		rt.change.New = visibleRecord{Raw: newRaw, Found: true}.Document()
+	}
+	return rt, nil
+}
+
+func (rt *rowTriggers) before() error {
+	if rt == nil {
+		return nil
+	}
+	return rt.ts.fire(rt.change)
+}
+
+func (rt *rowTriggers) afterWrite() error {
+	if rt == nil {
+		return nil
+	}
+	change := rt.change
+	change.Event = rt.after
+	return rt.ts.fire(change)
+}
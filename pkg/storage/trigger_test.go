@@ -0,0 +1,88 @@
+package storage_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+func TestTrigger_BeforeVetoesAndAfterObserves(t *testing.T) {
+	se := openReportsEngine(t, t.TempDir())
+	defer se.Close()
+
+	errNoArchive := errors.New("dept Archive is read-only")
+	if err := se.RegisterTrigger("reports", storage.BeforeInsert|storage.BeforeUpdate, func(c storage.RowChange) error {
+		if strings.Contains(c.New, `"Archive"`) {
+			return errNoArchive
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("RegisterTrigger: %v", err)
+	}
+	var seen []string
+	if err := se.RegisterTrigger("reports", storage.AfterInsert|storage.AfterUpdate|storage.AfterDelete, func(c storage.RowChange) error {
+		seen = append(seen, c.Event.String()+" "+c.Key.(types.IntKey).String()+" "+c.Old+" -> "+c.New)
+		return nil
+	}); err != nil {
+		t.Fatalf("RegisterTrigger: %v", err)
+	}
+
+	if err := se.InsertRow("reports", `{"id": 1, "dept": "Eng"}`, nil); err != nil {
+		t.Fatalf("InsertRow: %v", err)
+	}
+	if err := se.UpsertRow("reports", `{"id": 1, "dept": "Ops"}`, nil); err != nil {
+		t.Fatalf("UpsertRow: %v", err)
+	}
+	if err := se.UpdateRow("reports", `{"id": 1, "dept": "Archive"}`, nil); !errors.Is(err, errNoArchive) {
+		t.Fatalf("expected the update to be vetoed, got %v", err)
+	}
+	if err := se.InsertRow("reports", `{"id": 2, "dept": "Archive"}`, nil); !errors.Is(err, errNoArchive) {
+		t.Fatalf("expected the insert to be vetoed, got %v", err)
+	}
+	if deleted, err := se.DeleteRow("reports", types.IntKey(1)); err != nil || !deleted {
+		t.Fatalf("DeleteRow: %v %v", deleted, err)
+	}
+
+	want := []string{
+		`AfterInsert 1  -> {"id":1,"dept":"Eng"}`,
+		`AfterUpdate 1 {"id":1,"dept":"Eng"} -> {"id":1,"dept":"Ops"}`,
+		`AfterDelete 1 {"id":1,"dept":"Ops"} -> `,
+	}
+	if strings.Join(seen, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("expected triggers\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(seen, "\n"))
+	}
+	if _, found, _ := se.Get("reports", "id", types.IntKey(2)); found {
+		t.Fatal("a vetoed insert wrote the row")
+	}
+}
+
+func TestTrigger_AfterErrorKeepsTheWrite(t *testing.T) {
+	se := openReportsEngine(t, t.TempDir())
+	defer se.Close()
+
+	errNotify := errors.New("notify failed")
+	if err := se.RegisterTrigger("reports", storage.AfterDelete, func(storage.RowChange) error {
+		return errNotify
+	}); err != nil {
+		t.Fatalf("RegisterTrigger: %v", err)
+	}
+	if err := se.InsertRow("reports", `{"id": 1, "dept": "Eng"}`, nil); err != nil {
+		t.Fatalf("InsertRow: %v", err)
+	}
+	if _, err := se.DeleteRow("reports", types.IntKey(1)); !errors.Is(err, errNotify) {
+		t.Fatalf("expected the trigger error, got %v", err)
+	}
+	if _, found, _ := se.Get("reports", "id", types.IntKey(1)); found {
+		t.Fatal("expected the delete to stay applied")
+	}
+
+	if err := se.RegisterTrigger("missing", storage.AfterInsert, func(storage.RowChange) error { return nil }); err == nil {
+		t.Fatal("expected an unknown table to be rejected")
+	}
+	if err := se.RegisterTrigger("reports", 0, func(storage.RowChange) error { return nil }); err == nil {
+		t.Fatal("expected empty events to be rejected")
+	}
+}
@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bobboyms/storage-engine/pkg/query"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// ErrTTLExpirerRunning is returned by Start when the expirer is already
+// running.
+var ErrTTLExpirerRunning = errors.New("storage: ttl expirer already running")
+
+// SetTableTTL makes the TypeDate index indexName the expiration index of
+// tableName: a row whose key in it is at or before the current time is
+// deleted by ExpireRows and by the TTLExpirer. An empty indexName turns
+// expiration off. The setting is recorded in the catalog when the schema
+// is persisted; dropping the index turns it off as well.
+func (se *StorageEngine) SetTableTTL(tableName, indexName string) error {
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return err
+	}
+
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return err
+	}
+	if indexName != "" {
+		index, err := table.GetIndex(indexName)
+		if err != nil {
+			return err
+		}
+		if index.Type != TypeDate {
+			return fmt.Errorf("storage: ttl index %s.%s must be TypeDate, got %v", tableName, indexName, index.Type)
+		}
+	}
+
+	table.Lock()
+	table.ttlIndex = indexName
+	table.Unlock()
+	return se.TableMetaData.saveCatalog()
+}
+
+// ExpireRows deletes the rows of tableName whose expiration key is at or
+// before now. Expired rows are removed through DeleteRange, so they are
+// logged in batches and replayed by recovery like any other delete.
+// Returns the number of rows deleted; a table without TTL deletes none.
+func (se *StorageEngine) ExpireRows(tableName string, now time.Time) (int, error) {
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return 0, err
+	}
+	indexName := table.TTLIndex()
+	if indexName == "" {
+		return 0, nil
+	}
+	return se.DeleteRange(tableName, indexName, query.LessOrEqual(types.DateKey(now)))
+}
+
+// TTLExpirerConfig selects how often the expirer runs.
+type TTLExpirerConfig struct {
+	// Interval is the time between expiration passes. Required.
+	Interval time.Duration
+
+	// Now returns the time rows are expired against. Defaults to
+	// time.Now.
+	Now func() time.Time
+
+	// OnError, when set, receives every failed pass. The expirer keeps
+	// running and tries again on the next tick.
+	OnError func(error)
+}
+
+// TTLExpirerStats reports what the expirer has done since the engine was
+// opened.
+type TTLExpirerStats struct {
+	Running   bool
+	Passes    uint64
+	Expired   uint64
+	Failures  uint64
+	LastPass  time.Time
+	LastError error
+}
+
+// TTLExpirer periodically runs ExpireRows on every table with a TTL
+// index. StorageEngine.Close stops it.
+type TTLExpirer struct {
+	engine *StorageEngine
+
+	mu      sync.Mutex
+	cfg     TTLExpirerConfig
+	stop    chan struct{}
+	done    chan struct{}
+	running bool
+
+	stats TTLExpirerStats
+}
+
+func newTTLExpirer(se *StorageEngine) *TTLExpirer {
+	return &TTLExpirer{engine: se}
+}
+
+// Start launches the background loop with cfg.
+func (te *TTLExpirer) Start(cfg TTLExpirerConfig) error {
+	if cfg.Interval <= 0 {
+		return errors.New("storage: ttl expirer needs an Interval")
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	te.mu.Lock()
+	defer te.mu.Unlock()
+	if te.running {
+		return ErrTTLExpirerRunning
+	}
+	te.cfg = cfg
+	te.stop = make(chan struct{})
+	te.done = make(chan struct{})
+	te.running = true
+	te.stats.Running = true
+	go te.loop(te.stop, te.done)
+	return nil
+}
+
+// Stop ends the background loop and waits for a pass in progress to
+// finish. Stopping an expirer that is not running is a no-op.
+func (te *TTLExpirer) Stop() {
+	te.mu.Lock()
+	if !te.running {
+		te.mu.Unlock()
+		return
+	}
+	stop, done := te.stop, te.done
+	te.running = false
+	te.stats.Running = false
+	te.mu.Unlock()
+
+	close(stop)
+	<-done
+}
+
+// Stats returns a snapshot of the expirer counters.
+func (te *TTLExpirer) Stats() TTLExpirerStats {
+	te.mu.Lock()
+	defer te.mu.Unlock()
+	return te.stats
+}
+
+func (te *TTLExpirer) loop(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	te.mu.Lock()
+	ticker := time.NewTicker(te.cfg.Interval)
+	te.mu.Unlock()
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			te.runPass()
+		}
+	}
+}
+
+// runPass expires every table with a TTL index. A failing table does not
+// keep the others from being expired.
+func (te *TTLExpirer) runPass() {
+	te.mu.Lock()
+	now, onError := te.cfg.Now(), te.cfg.OnError
+	te.mu.Unlock()
+
+	var expired uint64
+	var errs []error
+	for _, tableName := range te.engine.TableMetaData.ListTables() {
+		n, err := te.engine.ExpireRows(tableName, now)
+		expired += uint64(n)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("storage: expire %s: %w", tableName, err))
+		}
+	}
+	err := errors.Join(errs...)
+
+	te.mu.Lock()
+	te.stats.Passes++
+	te.stats.Expired += expired
+	te.stats.LastPass = now
+	te.stats.LastError = err
+	if err != nil {
+		te.stats.Failures++
+	}
+	te.mu.Unlock()
+
+	if err != nil && onError != nil {
+		onError(err)
+	}
+}
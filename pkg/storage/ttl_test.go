@@ -0,0 +1,133 @@
+package storage_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+func openSessionsEngine(t *testing.T, dir string) *storage.StorageEngine {
+	t.Helper()
+	hm, err := storage.NewHeapForTable(storage.HeapFormatV2, filepath.Join(dir, "heap.data"))
+	if err != nil {
+		t.Fatalf("Failed to create heap: %v", err)
+	}
+	tableMgr, err := storage.NewCatalogTableMenager(filepath.Join(dir, "catalog.json"), nil)
+	if err != nil {
+		t.Fatalf("Failed to open catalog: %v", err)
+	}
+	if _, err := tableMgr.GetTableByName("sessions"); err != nil {
+		if err := tableMgr.NewTable("sessions", []storage.Index{
+			{Name: "id", Primary: true, Type: storage.TypeInt},
+			{Name: "expires_at", Primary: false, Type: storage.TypeDate},
+		}, 3, hm); err != nil {
+			t.Fatalf("Failed to create table: %v", err)
+		}
+	} else {
+		hm.Close()
+	}
+	walWriter, err := wal.NewWALWriter(filepath.Join(dir, "wal.log"), wal.DefaultOptions())
+	if err != nil {
+		t.Fatalf("Failed to create WAL: %v", err)
+	}
+	se, err := storage.NewProductionStorageEngine(tableMgr, walWriter)
+	if err != nil {
+		walWriter.Close()
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	return se
+}
+
+func putSession(t *testing.T, se *storage.StorageEngine, id int64, expiresAt time.Time) {
+	t.Helper()
+	err := se.UpsertRow("sessions", fmt.Sprintf("session-%d", id), map[string]types.Comparable{
+		"id":         types.IntKey(id),
+		"expires_at": types.DateKey(expiresAt),
+	})
+	if err != nil {
+		t.Fatalf("UpsertRow %d: %v", id, err)
+	}
+}
+
+func TestTTL_ExpiresRowsAndRecovers(t *testing.T) {
+	dir := t.TempDir()
+	se := openSessionsEngine(t, dir)
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for id := int64(1); id <= 6; id++ {
+		putSession(t, se, id, base.Add(time.Duration(id)*time.Hour))
+	}
+
+	if err := se.SetTableTTL("sessions", "id"); err == nil {
+		t.Fatal("expected SetTableTTL to reject a non-date index")
+	}
+	if n, err := se.ExpireRows("sessions", base.Add(10*time.Hour)); err != nil || n != 0 {
+		t.Fatalf("ExpireRows without TTL: n=%d err=%v", n, err)
+	}
+	if err := se.SetTableTTL("sessions", "expires_at"); err != nil {
+		t.Fatalf("SetTableTTL: %v", err)
+	}
+
+	n, err := se.ExpireRows("sessions", base.Add(2*time.Hour))
+	if err != nil || n != 2 {
+		t.Fatalf("ExpireRows: n=%d err=%v", n, err)
+	}
+	// Renewing a session moves it out of the expired range.
+	putSession(t, se, 3, base.Add(24*time.Hour))
+
+	failures := make(chan error, 1)
+	if err := se.TTLExpirer.Start(storage.TTLExpirerConfig{
+		Interval: 5 * time.Millisecond,
+		Now:      func() time.Time { return base.Add(5 * time.Hour) },
+		OnError:  func(err error) { failures <- err },
+	}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := se.TTLExpirer.Start(storage.TTLExpirerConfig{Interval: time.Second}); err != storage.ErrTTLExpirerRunning {
+		t.Fatalf("expected ErrTTLExpirerRunning, got %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for se.TTLExpirer.Stats().Expired < 2 {
+		select {
+		case err := <-failures:
+			t.Fatalf("expirer failed: %v", err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expirer did not run: %+v", se.TTLExpirer.Stats())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	se.TTLExpirer.Stop()
+
+	docs, err := se.Scan("sessions", "id", nil)
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if got := strings.Join(docs, ","); got != "session-3,session-6" {
+		t.Fatalf("sessions after expiry: %s", got)
+	}
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	se2 := openSessionsEngine(t, dir)
+	defer se2.Close()
+	docs, err = se2.Scan("sessions", "id", nil)
+	if err != nil {
+		t.Fatalf("Scan after recovery: %v", err)
+	}
+	if got := strings.Join(docs, ","); got != "session-3,session-6" {
+		t.Fatalf("sessions after recovery: %s", got)
+	}
+	table, _ := se2.TableMetaData.GetTableByName("sessions")
+	if table.TTLIndex() != "expires_at" {
+		t.Fatalf("TTL index not restored from the catalog: %q", table.TTLIndex())
+	}
+}
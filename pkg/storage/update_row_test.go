@@ -0,0 +1,111 @@
+package storage_test
+
+import (
+	stderrors "errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/errors"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+func TestUpdateRow_MaintainsEveryIndex(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+
+	putEmployee(t, se, 1, "Engineering")
+	putEmployee(t, se, 2, "Engineering")
+
+	// Keys are derived from the document when none are given.
+	if err := se.UpdateRow("employees", `{"id": 1, "department": "Sales"}`, nil); err != nil {
+		t.Fatalf("UpdateRow failed: %v", err)
+	}
+
+	docs, _ := se.GetAll("employees", "department", types.VarcharKey("Engineering"))
+	assertIDs(t, "Engineering", docs, "2")
+	docs, _ = se.GetAll("employees", "department", types.VarcharKey("Sales"))
+	assertIDs(t, "Sales", docs, "1")
+
+	doc, found, err := se.Get("employees", "id", types.IntKey(1))
+	if err != nil || !found {
+		t.Fatalf("Get by id: found=%v err=%v", found, err)
+	}
+	assertIDs(t, "Get id=1", []string{doc}, "1")
+
+	// Explicit keys must agree with the document.
+	err = se.UpdateRow("employees", `{"id": 2, "department": "HR"}`, map[string]types.Comparable{
+		"department": types.VarcharKey("Sales"),
+	})
+	if err == nil {
+		t.Fatal("expected an error for keys that diverge from the document")
+	}
+}
+
+func TestUpdateRow_MissingRow(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+
+	err := se.UpdateRow("employees", `{"id": 9, "department": "Sales"}`, nil)
+	var notFound *errors.RowNotFoundError
+	if !stderrors.As(err, &notFound) {
+		t.Fatalf("expected RowNotFoundError, got %v", err)
+	}
+
+	putEmployee(t, se, 9, "Sales")
+	if _, err := se.Del("employees", "id", types.IntKey(9)); err != nil {
+		t.Fatalf("Del failed: %v", err)
+	}
+	err = se.UpdateRow("employees", `{"id": 9, "department": "HR"}`, nil)
+	if !stderrors.As(err, &notFound) {
+		t.Fatalf("expected RowNotFoundError after delete, got %v", err)
+	}
+}
+
+func TestDel_HidesRowFromEveryIndex(t *testing.T) {
+	dir := t.TempDir()
+	se := openEmployeesEngine(t, dir)
+
+	putEmployee(t, se, 1, "Engineering")
+	putEmployee(t, se, 2, "Engineering")
+	putEmployee(t, se, 3, "Sales")
+
+	// Deleting through the primary index removes the row from the
+	// secondary one as well.
+	if found, err := se.Del("employees", "id", types.IntKey(1)); err != nil || !found {
+		t.Fatalf("Del by id: found=%v err=%v", found, err)
+	}
+	docs, _ := se.GetAll("employees", "department", types.VarcharKey("Engineering"))
+	assertIDs(t, "Engineering after Del by id", docs, "2")
+
+	// And the other way round.
+	if found, err := se.Del("employees", "department", types.VarcharKey("Sales")); err != nil || !found {
+		t.Fatalf("Del by department: found=%v err=%v", found, err)
+	}
+	if _, found, _ := se.Get("employees", "id", types.IntKey(3)); found {
+		t.Fatal("row 3 still visible through the primary index")
+	}
+
+	// A deleted key can be inserted again.
+	if err := se.InsertRow("employees", `{"id": 1, "department": "HR"}`, nil); err != nil {
+		t.Fatalf("InsertRow after Del failed: %v", err)
+	}
+	if err := se.InsertRow("employees", `{"id": 1, "department": "HR"}`, nil); err == nil {
+		t.Fatal("expected duplicate key error for a live row")
+	}
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	se2 := openEmployeesEngine(t, dir)
+	defer se2.Close()
+	if err := se2.Recover(filepath.Join(dir, "wal.log")); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	docs, _ = se2.GetAll("employees", "department", types.VarcharKey("Engineering"))
+	assertIDs(t, "recovered Engineering", docs, "2")
+	docs, _ = se2.GetAll("employees", "department", types.VarcharKey("HR"))
+	assertIDs(t, "recovered HR", docs, "1")
+	if _, found, _ := se2.Get("employees", "id", types.IntKey(3)); found {
+		t.Fatal("row 3 reappeared after recovery")
+	}
+}
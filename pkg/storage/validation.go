@@ -0,0 +1,101 @@
+package storage
+
+import (
+	goerrors "errors"
+	"fmt"
+	"sort"
+
+	"github.com/bobboyms/storage-engine/pkg/errors"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// DocumentValidator checks a document before it is written to a table.
+// Returning an *errors.ValidationError lets it name the offending field;
+// any other error is wrapped in one.
+type DocumentValidator func(doc bson.D) error
+
+type tableValidator struct {
+	fn DocumentValidator
+}
+
+// SetValidator registers v for tableName; nil removes it. Every write of
+// a document to the table (Put, InsertRow, UpsertRow, UpdateRow,
+// UpdateFields, batches, bulk loads and transactional writes) is checked
+// before anything is logged. While a validator is set, documents that are
+// not JSON objects are rejected. Deletes are not validated.
+func (tb *TableMetaData) SetValidator(tableName string, v DocumentValidator) error {
+	table, err := tb.GetTableByName(tableName)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		table.validator.Store(nil)
+		return nil
+	}
+	table.validator.Store(&tableValidator{fn: v})
+	return nil
+}
+
+// RequireFields returns a validator that accepts documents holding every
+// field of fields with a value of its type.
+func RequireFields(fields map[string]DataType) DocumentValidator {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return func(doc bson.D) error {
+		for _, name := range names {
+			exists, dataType := DoesTheKeyExist(doc, name)
+			if !exists {
+				return &errors.ValidationError{Field: name, Reason: "required field is missing"}
+			}
+			if dataType != fields[name] && !coercesTo(doc, name, fields[name]) {
+				return &errors.ValidationError{
+					Field:  name,
+					Reason: fmt.Sprintf("expected %s, got %s", fields[name], dataType),
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// coercesTo reports whether the field converts to a key of type t, as a
+// decimal or UUID written as a string does.
+func coercesTo(doc bson.D, name string, t DataType) bool {
+	value, err := GetValueFromBson(doc, name)
+	return err == nil && getTypeFromKey(coerceKey(t, value)) == t
+}
+
+// validateOpaqueDocument rejects a document that is not JSON when table
+// has a validator.
+func validateOpaqueDocument(table *Table) error {
+	if table.validator.Load() == nil {
+		return nil
+	}
+	return &errors.ValidationError{TableName: table.Name, Reason: "document is not a JSON object"}
+}
+
+// validateDocument runs the validator of table, if any, on doc.
+func validateDocument(table *Table, doc bson.D) error {
+	v := table.validator.Load()
+	if v == nil {
+		return nil
+	}
+	err := v.fn(doc)
+	if err == nil {
+		return nil
+	}
+	var verr *errors.ValidationError
+	if goerrors.As(err, &verr) {
+		if verr.TableName == "" {
+			rejected := *verr
+			rejected.TableName = table.Name
+			return &rejected
+		}
+		return verr
+	}
+	return &errors.ValidationError{TableName: table.Name, Reason: err.Error(), Err: err}
+}
@@ -0,0 +1,78 @@
+package storage_test
+
+import (
+	goerrors "errors"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/errors"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func expectValidationError(t *testing.T, label string, err error, field string) {
+	t.Helper()
+	var verr *errors.ValidationError
+	if !goerrors.As(err, &verr) {
+		t.Fatalf("%s: expected a ValidationError, got %v", label, err)
+	}
+	if verr.TableName != "employees" || verr.Field != field {
+		t.Fatalf("%s: unexpected violation %+v", label, verr)
+	}
+}
+
+func TestValidator_RejectsDocumentsOnEveryWritePath(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+
+	if err := se.TableMetaData.SetValidator("employees", storage.RequireFields(map[string]storage.DataType{
+		"department": storage.TypeVarchar,
+		"name":       storage.TypeVarchar,
+	})); err != nil {
+		t.Fatalf("SetValidator: %v", err)
+	}
+	keys := map[string]types.Comparable{"id": types.IntKey(1), "department": types.VarcharKey("Sales")}
+
+	err := se.InsertRow("employees", `{"id": 1, "department": "Sales"}`, keys)
+	expectValidationError(t, "InsertRow", err, "name")
+	err = se.Put("employees", "id", types.IntKey(1), `{"id": 1, "department": "Sales", "name": 7}`)
+	expectValidationError(t, "Put", err, "name")
+	err = se.UpsertRow("employees", "opaque", keys)
+	expectValidationError(t, "opaque UpsertRow", err, "")
+
+	tx := se.BeginWriteTransaction()
+	err = tx.Put("employees", "id", types.IntKey(2), `{"id": 2, "department": "Sales"}`)
+	expectValidationError(t, "tx.Put", err, "name")
+	err = tx.InsertRow("employees", `{"id": 2, "department": "Sales"}`, nil)
+	expectValidationError(t, "tx.InsertRow", err, "name")
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+
+	if err := se.InsertRow("employees", `{"id": 1, "department": "Sales", "name": "Ana"}`, keys); err != nil {
+		t.Fatalf("valid InsertRow: %v", err)
+	}
+	err = se.UpdateFields("employees", "id", types.IntKey(1), map[string]interface{}{"name": 3})
+	expectValidationError(t, "UpdateFields", err, "name")
+	if count, _ := se.Count("employees", "id", nil); count != 1 {
+		t.Fatalf("rejected writes changed the table: %d rows", count)
+	}
+
+	// A callback error without a field is wrapped as is.
+	errCustom := goerrors.New("departments are closed")
+	if err := se.TableMetaData.SetValidator("employees", func(bson.D) error { return errCustom }); err != nil {
+		t.Fatalf("SetValidator: %v", err)
+	}
+	err = se.Put("employees", "id", types.IntKey(3), `{"id": 3, "department": "HR"}`)
+	expectValidationError(t, "callback", err, "")
+	if !goerrors.Is(err, errCustom) {
+		t.Fatalf("callback error not wrapped: %v", err)
+	}
+
+	if err := se.TableMetaData.SetValidator("employees", nil); err != nil {
+		t.Fatalf("SetValidator(nil): %v", err)
+	}
+	if err := se.Put("employees", "id", types.IntKey(3), `{"id": 3, "department": "HR"}`); err != nil {
+		t.Fatalf("Put without validator: %v", err)
+	}
+}
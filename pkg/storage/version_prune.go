@@ -0,0 +1,155 @@
+package storage
+
+import (
+	goerrors "errors"
+	"fmt"
+
+	v2 "github.com/bobboyms/storage-engine/pkg/heap/v2"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+// PruneVersions is a cheaper alternative to Vacuum for update-heavy
+// tables. Instead of compacting every heap page it walks the version
+// chain of each primary key and frees only the versions no snapshot can
+// reach anymore: those deleted or superseded at or before the oldest
+// active snapshot and outside the table's VersionRetention. The newest surviving version has its PrevRecordID cut,
+// rows whose head is such a tombstone leave the primary index, and index
+// entries pointing at freed versions are removed in place. Only the pages
+// holding freed versions are repacked.
+//
+// Returns the number of versions freed.
+func (se *StorageEngine) PruneVersions(tableName string) (int, error) {
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return 0, err
+	}
+
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return 0, err
+	}
+	table.Lock()
+	defer table.Unlock()
+
+	heapV2, ok := table.Heap.(*v2.HeapV2)
+	if !ok {
+		return 0, fmt.Errorf("PruneVersions: table %s must use HeapV2", tableName)
+	}
+	primary, err := primaryIndex(table)
+	if err != nil {
+		return 0, err
+	}
+	scanner, ok := primary.Tree.(rangeScanner)
+	if !ok {
+		return 0, fmt.Errorf("PruneVersions: index %s.%s cannot be scanned", tableName, primary.Name)
+	}
+
+	type chainHead struct {
+		key types.Comparable
+		rid int64
+	}
+	var heads []chainHead
+	if err := scanner.ScanAll(func(key types.Comparable, rid int64) error {
+		heads = append(heads, chainHead{key: key, rid: rid})
+		return nil
+	}); err != nil {
+		return 0, fmt.Errorf("primary index scan failed: %w", err)
+	}
+
+	minLSN := se.vacuumMinLSN(table)
+	table.advanceVacuumHorizon(min(minLSN, se.lsnTracker.Current()))
+	var freed []int64
+	for _, head := range heads {
+		dead, err := se.pruneChain(table, heapV2, primary, head.key, head.rid, minLSN)
+		if err != nil {
+			return 0, err
+		}
+		freed = append(freed, dead...)
+	}
+	if len(freed) == 0 {
+		return 0, nil
+	}
+	return heapV2.FreeVersions(freed, 0)
+}
+
+// pruneChain detaches the unreachable tail of the chain starting at head
+// and returns the versions to free. Every version older than a tombstone
+// deleted at or before minLSN is unreachable too: it was superseded no
+// later than that tombstone was created.
+func (se *StorageEngine) pruneChain(table *Table, heap *v2.HeapV2, primary *Index, primaryKey types.Comparable, head int64, minLSN uint64) ([]int64, error) {
+	keep := int64(-1)
+	rid := head
+	for rid != -1 {
+		hdr, err := heap.ReadHeader(rid)
+		if isChainEndErr(err) {
+			// Vacuum already freed the rest of the chain; only the
+			// dangling link is left.
+			if keep != -1 {
+				return nil, heap.SetPrevRecordID(keep, -1, 0)
+			}
+			return nil, removeIndexKeyIfMatchesWithLSN(primary, primaryKey, head, 0)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("heap read failed: %w", err)
+		}
+		if !hdr.Valid && hdr.DeleteLSN > 0 && hdr.DeleteLSN <= minLSN {
+			break
+		}
+		keep, rid = rid, hdr.PrevRecordID
+	}
+	if rid == -1 {
+		return nil, nil
+	}
+
+	var dead []int64
+	for rid != -1 {
+		keys, found, err := storedRowKeys(table, rid)
+		if isChainEndErr(err) {
+			break
+		}
+		switch {
+		case goerrors.Is(err, v2.ErrRecordChecksum):
+			// A corrupt dead version is freed anyway; its index keys
+			// cannot be trusted, so its entries stay until they are
+			// found dangling.
+		case err != nil:
+			return nil, fmt.Errorf("heap read failed: %w", err)
+		case found:
+			if err := removeVersionPointers(table, keys, rid); err != nil {
+				return nil, err
+			}
+		}
+		hdr, err := heap.ReadHeader(rid)
+		if err != nil {
+			return nil, fmt.Errorf("heap read failed: %w", err)
+		}
+		dead = append(dead, rid)
+		rid = hdr.PrevRecordID
+	}
+
+	if keep == -1 {
+		if err := removeIndexKeyIfMatchesWithLSN(primary, primaryKey, head, 0); err != nil {
+			return nil, fmt.Errorf("primary index remove failed: %w", err)
+		}
+	} else if err := heap.SetPrevRecordID(keep, -1, 0); err != nil {
+		return nil, err
+	}
+	return dead, nil
+}
+
+// removeVersionPointers drops the secondary index entries that still
+// point at the version stored at rid. Rows written through the
+// single-index Put path carry no secondary keys to clean up.
+func removeVersionPointers(table *Table, keys map[string]types.Comparable, rid int64) error {
+	for indexName, key := range keys {
+		idx, ok := table.Indices[indexName]
+		if !ok || idx.Primary {
+			continue
+		}
+		if err := removeIndexKeyIfMatchesWithLSN(idx, key, rid, 0); err != nil {
+			return fmt.Errorf("index %s remove failed: %w", indexName, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/btree"
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+func departmentPostings(t *testing.T, se *storage.StorageEngine, dept string) []int64 {
+	t.Helper()
+	table, err := se.TableMetaData.GetTableByName("employees")
+	if err != nil {
+		t.Fatal(err)
+	}
+	postings, err := table.Indices["department"].Tree.(btree.MultiValueTree).GetAll(types.VarcharKey(dept))
+	if err != nil {
+		t.Fatalf("GetAll postings: %v", err)
+	}
+	return postings
+}
+
+func TestPruneVersions_TrimsChainsBelowOldestSnapshot(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+
+	putEmployee(t, se, 1, "Engineering")
+	putEmployee(t, se, 1, "Sales")
+	putEmployee(t, se, 1, "HR")
+	putEmployee(t, se, 2, "Engineering")
+	if _, err := se.DeleteRow("employees", types.IntKey(2)); err != nil {
+		t.Fatalf("DeleteRow: %v", err)
+	}
+
+	snapshot := se.BeginRead()
+	putEmployee(t, se, 1, "Ops")
+
+	// Engineering and Sales of row 1 and all of row 2 are gone for every
+	// snapshot; HR is still what the open snapshot reads.
+	freed, err := se.PruneVersions("employees")
+	if err != nil {
+		t.Fatalf("PruneVersions: %v", err)
+	}
+	if freed != 3 {
+		t.Fatalf("expected 3 versions freed, got %d", freed)
+	}
+	for _, dept := range []string{"Engineering", "Sales"} {
+		if postings := departmentPostings(t, se, dept); len(postings) != 0 {
+			t.Fatalf("%s postings left behind: %v", dept, postings)
+		}
+	}
+	if _, found, _ := se.Get("employees", "id", types.IntKey(2)); found {
+		t.Fatal("deleted row 2 visible after prune")
+	}
+	doc, found, err := snapshot.Get("employees", "id", types.IntKey(1))
+	if err != nil || !found {
+		t.Fatalf("snapshot lost row 1: found=%v err=%v", found, err)
+	}
+	assertIDs(t, "snapshot HR", []string{doc}, "1")
+	docs, _ := snapshot.GetAll("employees", "department", types.VarcharKey("HR"))
+	assertIDs(t, "snapshot HR postings", docs, "1")
+	snapshot.Close()
+
+	freed, err = se.PruneVersions("employees")
+	if err != nil || freed != 1 {
+		t.Fatalf("second PruneVersions: freed=%d err=%v", freed, err)
+	}
+	if postings := departmentPostings(t, se, "HR"); len(postings) != 0 {
+		t.Fatalf("HR postings left behind: %v", postings)
+	}
+	docs, _ = se.GetAll("employees", "department", types.VarcharKey("Ops"))
+	assertIDs(t, "Ops after prune", docs, "1")
+
+	// Pruned space is reused and the trimmed chain keeps accepting versions.
+	putEmployee(t, se, 1, "Legal")
+	putEmployee(t, se, 3, "Legal")
+	docs, _ = se.GetAll("employees", "department", types.VarcharKey("Legal"))
+	assertIDs(t, "Legal", docs, "1", "3")
+	if freed, err := se.PruneVersions("employees"); err != nil || freed != 1 {
+		t.Fatalf("third PruneVersions: freed=%d err=%v", freed, err)
+	}
+}
+
+func TestPruneVersions_UsesKeyCatalogForOpaqueDocuments(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+
+	// Non-JSON documents: the index keys exist only in the key catalog.
+	put := func(doc, dept string) {
+		t.Helper()
+		if err := se.UpsertRow("employees", doc, map[string]types.Comparable{
+			"id":         types.IntKey(1),
+			"department": types.VarcharKey(dept),
+		}); err != nil {
+			t.Fatalf("UpsertRow: %v", err)
+		}
+	}
+	put("blob-a", "Engineering")
+	put("blob-b", "Sales")
+
+	if freed, err := se.PruneVersions("employees"); err != nil || freed != 1 {
+		t.Fatalf("PruneVersions: freed=%d err=%v", freed, err)
+	}
+	if postings := departmentPostings(t, se, "Engineering"); len(postings) != 0 {
+		t.Fatalf("Engineering postings left behind: %v", postings)
+	}
+
+	if _, err := se.DeleteRow("employees", types.IntKey(1)); err != nil {
+		t.Fatalf("DeleteRow: %v", err)
+	}
+	if freed, err := se.PruneVersions("employees"); err != nil || freed != 1 {
+		t.Fatalf("second PruneVersions: freed=%d err=%v", freed, err)
+	}
+	if postings := departmentPostings(t, se, "Sales"); len(postings) != 0 {
+		t.Fatalf("Sales postings left behind: %v", postings)
+	}
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// VersionRetention is the time-travel window of a table: Vacuum and
+// PruneVersions keep every version a snapshot inside it still needs, so
+// SnapshotScan can read that far back. A version is freed only once it
+// was superseded or deleted more than Duration ago and more than LSNs
+// LSNs ago, on top of the usual rule that no active transaction sees it.
+// The zero value keeps nothing beyond the active transactions.
+type VersionRetention struct {
+	Duration time.Duration `json:"duration,omitempty"`
+	LSNs     uint64        `json:"lsns,omitempty"`
+}
+
+// SetVersionRetention sets the time-travel window of tableName; the zero
+// VersionRetention turns it off. The setting is recorded in the catalog
+// when the schema is persisted.
+//
+// Duration is measured against clock marks the engine takes while it
+// runs, so right after Open nothing written before it is freed until
+// Duration has passed.
+func (se *StorageEngine) SetVersionRetention(tableName string, retention VersionRetention) error {
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+	if err := se.runtimeReadyError(); err != nil {
+		return err
+	}
+	if retention.Duration < 0 {
+		return fmt.Errorf("storage: negative version retention %v for table %s", retention.Duration, tableName)
+	}
+
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		return err
+	}
+	table.Lock()
+	table.retention = retention
+	table.Unlock()
+	return se.TableMetaData.saveCatalog()
+}
+
+// VersionRetention returns the time-travel window of the table.
+func (t *Table) VersionRetention() VersionRetention {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.retention
+}
+
+// vacuumMinLSN is the snapshot below which Vacuum and PruneVersions may
+// free versions of table: the oldest active snapshot, held back by the
+// table's VersionRetention. Caller holds the table lock.
+func (se *StorageEngine) vacuumMinLSN(table *Table) uint64 {
+	minLSN := se.TxRegistry.GetMinActiveLSN()
+	current := se.lsnTracker.Current()
+	se.lsnClock.observe(time.Now(), current)
+
+	retention := table.retention
+	if retention.LSNs > 0 {
+		keep := uint64(0)
+		if current > retention.LSNs {
+			keep = current - retention.LSNs
+		}
+		minLSN = min(minLSN, keep)
+	}
+	if retention.Duration > 0 {
+		minLSN = min(minLSN, se.lsnClock.lsnAt(time.Now().Add(-retention.Duration)))
+	}
+	return minLSN
+}
+
+// maxLSNClockMarks bounds lsnClock; when full, every other mark is
+// dropped, which only makes lsnAt more conservative.
+const maxLSNClockMarks = 4096
+
+// lsnClock maps wall-clock time to LSNs with marks taken at most every
+// WALClockInterval: at the WAL clock marks and whenever vacuum asks.
+type lsnClock struct {
+	mu    sync.Mutex
+	marks []clockMark
+}
+
+// observe records that lsn was the current LSN at now.
+func (c *lsnClock) observe(now time.Time, lsn uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n := len(c.marks); n > 0 && now.Sub(c.marks[n-1].time) < WALClockInterval {
+		return
+	}
+	if len(c.marks) == maxLSNClockMarks {
+		kept := c.marks[:0]
+		for i := 1; i < len(c.marks); i += 2 {
+			kept = append(kept, c.marks[i])
+		}
+		c.marks = kept
+	}
+	c.marks = append(c.marks, clockMark{lsn: lsn, time: now})
+}
+
+// lsnAt returns the LSN current at t, as seen by the latest mark at or
+// before t; 0 when no mark is that old.
+func (c *lsnClock) lsnAt(t time.Time) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lsn := uint64(0)
+	for _, mark := range c.marks {
+		if mark.time.After(t) {
+			break
+		}
+		lsn = mark.lsn
+	}
+	return lsn
+}
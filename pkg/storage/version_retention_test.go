@@ -0,0 +1,85 @@
+package storage_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bobboyms/storage-engine/pkg/storage"
+	"github.com/bobboyms/storage-engine/pkg/types"
+)
+
+func TestVersionRetention_VacuumKeepsWindow(t *testing.T) {
+	dir := t.TempDir()
+	se, err := storage.Open(dir, storage.Options{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := se.CreateTable("users", []storage.Index{{Name: "id", Primary: true, Type: storage.TypeInt}}); err != nil {
+		t.Fatalf("CreateTable: %v", err)
+	}
+	if err := se.SetVersionRetention("users", storage.VersionRetention{LSNs: 100}); err != nil {
+		t.Fatalf("SetVersionRetention: %v", err)
+	}
+	if err := se.InsertRow("users", `{"id": 1, "name": "ana"}`, nil); err != nil {
+		t.Fatalf("InsertRow: %v", err)
+	}
+	asOf := se.CurrentLSN()
+	if err := se.UpdateRow("users", `{"id": 1, "name": "bia"}`, nil); err != nil {
+		t.Fatalf("UpdateRow: %v", err)
+	}
+
+	if err := se.Vacuum("users"); err != nil {
+		t.Fatalf("Vacuum: %v", err)
+	}
+	if _, err := se.PruneVersions("users"); err != nil {
+		t.Fatalf("PruneVersions: %v", err)
+	}
+	rows, err := se.SnapshotScan("users", "id", asOf, nil)
+	if err != nil || len(rows) != 1 || rows[0] != `{"id":1,"name":"ana"}` {
+		t.Fatalf("SnapshotScan inside the window = %v, %v", rows, err)
+	}
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// The window is kept in the catalog.
+	se, err = storage.Open(dir, storage.Options{})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer se.Close()
+	table, err := se.TableMetaData.GetTableByName("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := table.VersionRetention(); got.LSNs != 100 {
+		t.Fatalf("VersionRetention after reopen = %+v", got)
+	}
+
+	if err := se.SetVersionRetention("users", storage.VersionRetention{Duration: time.Hour}); err != nil {
+		t.Fatalf("SetVersionRetention: %v", err)
+	}
+	if err := se.Vacuum("users"); err != nil {
+		t.Fatalf("Vacuum: %v", err)
+	}
+	if rows, err := se.SnapshotScan("users", "id", asOf, nil); err != nil || len(rows) != 1 {
+		t.Fatalf("SnapshotScan inside a time window = %v, %v", rows, err)
+	}
+
+	if err := se.SetVersionRetention("users", storage.VersionRetention{}); err != nil {
+		t.Fatalf("SetVersionRetention: %v", err)
+	}
+	if err := se.Vacuum("users"); err != nil {
+		t.Fatalf("Vacuum: %v", err)
+	}
+	if _, err := se.SnapshotScan("users", "id", asOf, nil); !errors.Is(err, storage.ErrSnapshotTooOld) {
+		t.Fatalf("SnapshotScan without retention = %v, want ErrSnapshotTooOld", err)
+	}
+	if _, found, err := se.Get("users", "id", types.IntKey(1)); err != nil || !found {
+		t.Fatalf("Get = %v, %v", found, err)
+	}
+	if err := se.SetVersionRetention("users", storage.VersionRetention{Duration: -time.Second}); err == nil {
+		t.Fatal("a negative retention should fail")
+	}
+}
@@ -0,0 +1,348 @@
+package storage
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+// ErrWritePipelineClosed is the result of a write submitted to a closed
+// WritePipeline.
+var ErrWritePipelineClosed = goerrors.New("storage: write pipeline closed")
+
+// WritePipelineOptions tunes a WritePipeline.
+type WritePipelineOptions struct {
+	// MaxBatch caps how many rows one batch writes. Zero means 256.
+	MaxBatch int
+	// QueueSize is how many writes may wait for the writer goroutine
+	// before InsertRow and UpsertRow block. Zero means 4 × MaxBatch.
+	QueueSize int
+}
+
+// WritePipelineStats counts the work of a WritePipeline.
+type WritePipelineStats struct {
+	Rows    uint64 // rows written
+	Batches uint64 // batches they were written in, one WAL record each
+}
+
+// WritePipeline funnels row writes through one writer goroutine. Every
+// autocommit write otherwise takes the row locks, the table lock, an LSN
+// and a WAL append (an fsync, under SyncEveryWrite) on its own. The
+// writer instead takes whatever has queued up while it was busy, up to
+// MaxBatch rows, and writes the rows of each table as InsertRows does:
+// one lock round, one EntryMultiBatch record, one fsync. The busier the
+// producers, the larger the batches.
+//
+// Unlike InsertRows, the rows of a batch keep separate results: a
+// duplicate key fails only its own write. A batch shares one LSN, so two
+// writes of the same primary key never share a batch; the later one
+// waits for the next. Writes to a table with triggers are written one
+// by one, so the triggers still run.
+//
+// Close the pipeline before the engine.
+type WritePipeline struct {
+	se       *StorageEngine
+	maxBatch int
+	queue    chan *pipelineWrite
+
+	mu     sync.RWMutex // closed, and sends on queue
+	closed bool
+	done   chan struct{}
+
+	rows    atomic.Uint64
+	batches atomic.Uint64
+}
+
+// WriteFuture is the pending result of a pipelined write.
+type WriteFuture struct {
+	done chan struct{}
+	err  error
+}
+
+// Done is closed once the write is durable or has failed.
+func (f *WriteFuture) Done() <-chan struct{} { return f.done }
+
+// Err returns the result of the write. Only meaningful after Done.
+func (f *WriteFuture) Err() error { return f.err }
+
+// Wait blocks until the write completes and returns its result.
+func (f *WriteFuture) Wait() error {
+	<-f.done
+	return f.err
+}
+
+type pipelineWrite struct {
+	table  string
+	doc    string
+	keys   map[string]types.Comparable
+	mode   rowWriteMode
+	future *WriteFuture
+
+	// Set while the writer batches the row.
+	bsonData   []byte
+	rowKeys    map[string]types.Comparable
+	primaryKey types.Comparable
+	finished   bool
+}
+
+func (w *pipelineWrite) finish(err error) {
+	if w.finished {
+		return
+	}
+	w.finished = true
+	w.future.err = err
+	close(w.future.done)
+}
+
+// NewWritePipeline starts a pipeline writing into se.
+func (se *StorageEngine) NewWritePipeline(opts WritePipelineOptions) *WritePipeline {
+	if opts.MaxBatch <= 0 {
+		opts.MaxBatch = 256
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 4 * opts.MaxBatch
+	}
+	p := &WritePipeline{
+		se:       se,
+		maxBatch: opts.MaxBatch,
+		queue:    make(chan *pipelineWrite, opts.QueueSize),
+		done:     make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// InsertRow queues se.InsertRow(tableName, doc, keys).
+func (p *WritePipeline) InsertRow(tableName string, doc string, keys map[string]types.Comparable) *WriteFuture {
+	return p.submit(tableName, doc, keys, rowInsert)
+}
+
+// UpsertRow queues se.UpsertRow(tableName, doc, keys).
+func (p *WritePipeline) UpsertRow(tableName string, doc string, keys map[string]types.Comparable) *WriteFuture {
+	return p.submit(tableName, doc, keys, rowUpsert)
+}
+
+func (p *WritePipeline) submit(tableName string, doc string, keys map[string]types.Comparable, mode rowWriteMode) *WriteFuture {
+	w := &pipelineWrite{table: tableName, doc: doc, keys: keys, mode: mode, future: &WriteFuture{done: make(chan struct{})}}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		w.finish(ErrWritePipelineClosed)
+		return w.future
+	}
+	p.queue <- w
+	return w.future
+}
+
+// Stats returns what the pipeline has written so far.
+func (p *WritePipeline) Stats() WritePipelineStats {
+	return WritePipelineStats{Rows: p.rows.Load(), Batches: p.batches.Load()}
+}
+
+// Close writes the queued rows and stops the writer. Writes submitted
+// after Close fail with ErrWritePipelineClosed.
+func (p *WritePipeline) Close() error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+	<-p.done
+	return nil
+}
+
+func (p *WritePipeline) run() {
+	defer close(p.done)
+	var deferred []*pipelineWrite
+	for {
+		batch := deferred
+		if len(batch) == 0 {
+			w, ok := <-p.queue
+			if !ok {
+				return
+			}
+			batch = append(batch, w)
+		}
+	drain:
+		for len(batch) < p.maxBatch {
+			select {
+			case w, ok := <-p.queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, w)
+			default:
+				break drain
+			}
+		}
+		deferred = p.writeBatch(batch)
+	}
+}
+
+// writeBatch writes batch table by table, in order of first appearance,
+// and returns the writes left for the next batch.
+func (p *WritePipeline) writeBatch(batch []*pipelineWrite) []*pipelineWrite {
+	var tables []string
+	byTable := make(map[string][]*pipelineWrite)
+	for _, w := range batch {
+		if _, ok := byTable[w.table]; !ok {
+			tables = append(tables, w.table)
+		}
+		byTable[w.table] = append(byTable[w.table], w)
+	}
+	var deferred []*pipelineWrite
+	for _, tableName := range tables {
+		deferred = append(deferred, p.writeTable(tableName, byTable[tableName])...)
+	}
+	return deferred
+}
+
+// writeTable writes the rows of one table under one WAL record and
+// returns the writes that repeat a primary key of an earlier one.
+func (p *WritePipeline) writeTable(tableName string, writes []*pipelineWrite) []*pipelineWrite {
+	se := p.se
+	se.opMu.RLock()
+	defer se.opMu.RUnlock()
+
+	failAll := func(err error) {
+		for _, w := range writes {
+			w.finish(err)
+		}
+	}
+	if err := se.runtimeReadyError(); err != nil {
+		failAll(err)
+		return nil
+	}
+	table, err := se.TableMetaData.GetTableByName(tableName)
+	if err != nil {
+		failAll(err)
+		return nil
+	}
+	if se.triggers.has(tableName, allTriggers) {
+		for _, w := range writes {
+			err := se.writeRowLocked(context.Background(), tableName, w.doc, w.keys, w.mode)
+			w.finish(err)
+			if err == nil {
+				p.rows.Add(1)
+				p.batches.Add(1)
+			}
+		}
+		return nil
+	}
+
+	var rows, deferred []*pipelineWrite
+	lockSet := make(map[string]struct{})
+	for _, w := range writes {
+		bsonData, keys, err := prepareRowDocument(table, w.doc, w.keys)
+		if err != nil {
+			w.finish(err)
+			continue
+		}
+		_, primaryKey, err := primaryIndexAndKey(table, keys)
+		if err != nil {
+			w.finish(err)
+			continue
+		}
+		if slices.ContainsFunc(rows, func(r *pipelineWrite) bool { return r.primaryKey.Compare(primaryKey) == 0 }) {
+			deferred = append(deferred, w)
+			continue
+		}
+		resources, err := lockResourcesForKeys(tableName, keys)
+		if err != nil {
+			w.finish(err)
+			continue
+		}
+		for _, resource := range resources {
+			lockSet[resource] = struct{}{}
+		}
+		w.bsonData, w.rowKeys, w.primaryKey = bsonData, keys, primaryKey
+		rows = append(rows, w)
+	}
+	if len(rows) == 0 {
+		return deferred
+	}
+
+	resources := make([]string, 0, len(lockSet))
+	for resource := range lockSet {
+		resources = append(resources, resource)
+	}
+	slices.Sort(resources)
+
+	err = se.withAutoCommitLocks(resources, func() error {
+		table.Lock()
+		defer table.Unlock()
+
+		primary, _, err := primaryIndexAndKey(table, rows[0].rowKeys)
+		if err != nil {
+			return err
+		}
+		accepted := rows[:0:0]
+		for _, w := range rows {
+			if w.mode == rowInsert {
+				offset, exists, err := primary.Tree.Get(w.primaryKey)
+				if err != nil {
+					w.finish(fmt.Errorf("primary index get failed: %w", err))
+					continue
+				}
+				live, err := isLiveRecord(table, offset, exists)
+				if err != nil {
+					w.finish(err)
+					continue
+				}
+				if live {
+					w.finish(fmt.Errorf("duplicate key error: key %v already exists in index %s", w.primaryKey, primary.Name))
+					continue
+				}
+			}
+			accepted = append(accepted, w)
+		}
+		if len(accepted) == 0 {
+			return nil
+		}
+
+		currentLSN := se.lsnTracker.Reserve()
+		defer se.lsnTracker.Release(currentLSN)
+		if se.WAL != nil {
+			payloads := make([][]byte, len(accepted))
+			for i, w := range accepted {
+				payload, err := SerializeMultiIndexEntry(tableName, w.rowKeys, w.bsonData)
+				if err != nil {
+					return err
+				}
+				payloads[i] = payload
+			}
+			if err := se.writeAutoCommitWAL(wal.EntryMultiBatch, SerializeBatchEntry(payloads), currentLSN); err != nil {
+				return err
+			}
+		}
+		p.batches.Add(1)
+
+		for i, w := range accepted {
+			if err := se.applyRowVersion(context.Background(), table, w.rowKeys, w.bsonData, currentLSN, nil); err != nil {
+				// The batch is logged but only part of it is applied:
+				// stop writes until recovery replays it.
+				applyErr := fmt.Errorf("pipeline apply failed for %s at row %d/%d: %w", tableName, i+1, len(accepted), err)
+				se.markDegraded(applyErr)
+				return applyErr
+			}
+		}
+		for _, w := range accepted {
+			w.finish(nil)
+		}
+		p.rows.Add(uint64(len(accepted)))
+		return nil
+	})
+	if err != nil {
+		for _, w := range rows {
+			w.finish(err)
+		}
+	}
+	return deferred
+}
@@ -174,3 +174,90 @@ func TestWriteTransaction_RepeatableReadStillAllowsWriteSkew(t *testing.T) {
 		t.Fatalf("expected write skew final state, got %q / %q", doc1a, doc1b)
 	}
 }
+
+func TestWriteTransaction_FirstCommitterWinsOnBlindWrites(t *testing.T) {
+	se := openIsolationTestEngine(t)
+	defer se.Close()
+
+	if err := se.Put("accounts", "id", types.IntKey(1), `{"id":1,"balance":100}`); err != nil {
+		t.Fatalf("seed put: %v", err)
+	}
+
+	tx1 := se.BeginWriteTransaction()
+	tx2 := se.BeginWriteTransaction()
+	if err := tx1.Put("accounts", "id", types.IntKey(1), `{"id":1,"balance":150}`); err != nil {
+		t.Fatalf("tx1 put: %v", err)
+	}
+	if err := tx1.Commit(); err != nil {
+		t.Fatalf("tx1 commit: %v", err)
+	}
+	if err := tx2.Put("accounts", "id", types.IntKey(1), `{"id":1,"balance":50}`); err != nil {
+		t.Fatalf("tx2 put: %v", err)
+	}
+
+	err := tx2.Commit()
+	var conflict *WriteConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, ErrWriteConflict) {
+		t.Fatalf("expected write conflict, got %v", err)
+	}
+	if conflict.TableName != "accounts" || conflict.Key != types.IntKey(1) {
+		t.Fatalf("unexpected conflict %+v", conflict)
+	}
+	if err := tx2.Put("accounts", "id", types.IntKey(2), `{"id":2}`); !errors.Is(err, ErrWriteConflict) {
+		t.Fatalf("expected the aborted tx to stay finished, got %v", err)
+	}
+
+	doc, found, err := se.Get("accounts", "id", types.IntKey(1))
+	if err != nil || !found || doc != `{"id":1,"balance":150}` {
+		t.Fatalf("final state mismatch: found=%v doc=%q err=%v", found, doc, err)
+	}
+
+	// A retry starts from a fresh snapshot and commits.
+	retry := se.BeginWriteTransaction()
+	if err := retry.Put("accounts", "id", types.IntKey(1), `{"id":1,"balance":50}`); err != nil {
+		t.Fatalf("retry put: %v", err)
+	}
+	if err := retry.Commit(); err != nil {
+		t.Fatalf("retry commit: %v", err)
+	}
+}
+
+func TestWriteTransaction_ConflictsWithConcurrentInsertAndDelete(t *testing.T) {
+	se := openIsolationTestEngine(t)
+	defer se.Close()
+
+	if err := se.Put("accounts", "id", types.IntKey(1), `{"id":1}`); err != nil {
+		t.Fatalf("seed put: %v", err)
+	}
+
+	txDel := se.BeginWriteTransaction()
+	txIns := se.BeginWriteTransaction()
+	if _, err := se.Del("accounts", "id", types.IntKey(1)); err != nil {
+		t.Fatalf("auto-commit delete: %v", err)
+	}
+	if err := se.Put("accounts", "id", types.IntKey(2), `{"id":2}`); err != nil {
+		t.Fatalf("auto-commit insert: %v", err)
+	}
+
+	if err := txDel.Del("accounts", "id", types.IntKey(1)); err != nil {
+		t.Fatalf("txDel del: %v", err)
+	}
+	if err := txDel.Commit(); !errors.Is(err, ErrWriteConflict) {
+		t.Fatalf("expected conflict on a key deleted after the snapshot, got %v", err)
+	}
+	if err := txIns.Put("accounts", "id", types.IntKey(2), `{"id":2,"dup":true}`); err != nil {
+		t.Fatalf("txIns put: %v", err)
+	}
+	if err := txIns.Commit(); !errors.Is(err, ErrWriteConflict) {
+		t.Fatalf("expected conflict on a key inserted after the snapshot, got %v", err)
+	}
+
+	// Keys nobody else touched still commit.
+	tx := se.BeginWriteTransaction()
+	if err := tx.Put("accounts", "id", types.IntKey(3), `{"id":3}`); err != nil {
+		t.Fatalf("tx put: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("tx commit: %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package storage_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/types"
+	"github.com/bobboyms/storage-engine/pkg/wal"
+)
+
+func TestWriteTransaction_InsertRowCommit(t *testing.T) {
+	dir := t.TempDir()
+	se := openEmployeesEngine(t, dir)
+
+	tx := se.BeginWriteTransaction()
+	if err := tx.InsertRow("employees", `{"id": 1, "department": "Engineering"}`, nil); err != nil {
+		t.Fatalf("InsertRow failed: %v", err)
+	}
+	if err := tx.InsertRow("employees", `{"id": 2, "department": "Engineering"}`, nil); err != nil {
+		t.Fatalf("InsertRow failed: %v", err)
+	}
+
+	// Nothing is visible before COMMIT.
+	if docs, _ := se.GetAll("employees", "department", types.VarcharKey("Engineering")); len(docs) != 0 {
+		t.Fatalf("uncommitted rows visible: %v", docs)
+	}
+	// The transaction sees its own buffered row.
+	if _, found, err := tx.Get("employees", "id", types.IntKey(1)); err != nil || !found {
+		t.Fatalf("tx.Get of a buffered row: found=%v err=%v", found, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	docs, _ := se.GetAll("employees", "department", types.VarcharKey("Engineering"))
+	assertIDs(t, "Engineering after commit", docs, "1", "2")
+	if _, found, _ := se.Get("employees", "id", types.IntKey(2)); !found {
+		t.Fatal("row 2 missing from the primary index")
+	}
+	if err := se.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reader, err := wal.NewWALReader(filepath.Join(dir, "wal.log"))
+	if err != nil {
+		t.Fatalf("Failed to open WAL: %v", err)
+	}
+	var entryTypes []uint8
+	for {
+		entry, err := reader.ReadEntry()
+		if err != nil {
+			break
+		}
+		if entry.Header.EntryType != wal.EntryPageRedo && entry.Header.EntryType != wal.EntryClock {
+			entryTypes = append(entryTypes, entry.Header.EntryType)
+		}
+		wal.ReleaseEntry(entry)
+	}
+	reader.Close()
+	want := []uint8{wal.EntryBegin, wal.EntryMultiInsert, wal.EntryMultiInsert, wal.EntryCommit}
+	if len(entryTypes) != len(want) {
+		t.Fatalf("expected WAL entries %v, got %v", want, entryTypes)
+	}
+	for i := range want {
+		if entryTypes[i] != want[i] {
+			t.Fatalf("expected WAL entries %v, got %v", want, entryTypes)
+		}
+	}
+
+	se2 := openEmployeesEngine(t, dir)
+	defer se2.Close()
+	if err := se2.Recover(filepath.Join(dir, "wal.log")); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	docs, _ = se2.GetAll("employees", "department", types.VarcharKey("Engineering"))
+	assertIDs(t, "recovered Engineering", docs, "1", "2")
+}
+
+func TestWriteTransaction_InsertRowRollback(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+
+	tx := se.BeginWriteTransaction()
+	if err := tx.InsertRow("employees", `{"id": 1, "department": "Sales"}`, nil); err != nil {
+		t.Fatalf("InsertRow failed: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	if _, found, _ := se.Get("employees", "id", types.IntKey(1)); found {
+		t.Fatal("rolled back row visible through the primary index")
+	}
+	if docs, _ := se.GetAll("employees", "department", types.VarcharKey("Sales")); len(docs) != 0 {
+		t.Fatalf("rolled back row visible through the secondary index: %v", docs)
+	}
+}
+
+func TestWriteTransaction_InsertRowDuplicateKey(t *testing.T) {
+	se := openEmployeesEngine(t, t.TempDir())
+	defer se.Close()
+
+	putEmployee(t, se, 1, "Sales")
+
+	tx := se.BeginWriteTransaction()
+	if err := tx.InsertRow("employees", `{"id": 1, "department": "HR"}`, nil); err == nil {
+		t.Fatal("expected duplicate key error for a committed row")
+	}
+	if err := tx.InsertRow("employees", `{"id": 2, "department": "HR"}`, nil); err != nil {
+		t.Fatalf("InsertRow failed: %v", err)
+	}
+	if err := tx.InsertRow("employees", `{"id": 2, "department": "HR"}`, nil); err == nil {
+		t.Fatal("expected duplicate key error for a row buffered in the same transaction")
+	}
+
+	// Deleting first frees the key within the transaction.
+	if err := tx.Del("employees", "id", types.IntKey(1)); err != nil {
+		t.Fatalf("Del failed: %v", err)
+	}
+	if err := tx.InsertRow("employees", `{"id": 1, "department": "HR"}`, nil); err != nil {
+		t.Fatalf("InsertRow after Del failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	docs, _ := se.GetAll("employees", "department", types.VarcharKey("HR"))
+	assertIDs(t, "HR after commit", docs, "1", "2")
+	if docs, _ := se.GetAll("employees", "department", types.VarcharKey("Sales")); len(docs) != 0 {
+		t.Fatalf("deleted row still visible: %v", docs)
+	}
+}
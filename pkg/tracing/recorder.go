@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+)
+
+// RecordedSpan is a span ended under a Recorder. Parent is the name of
+// the span it was started under, "" for a root span.
+type RecordedSpan struct {
+	Name       string
+	Parent     string
+	Attributes map[string]any
+	Err        error
+}
+
+// Recorder is a Tracer that keeps every ended span in memory, for tests
+// and debugging.
+type Recorder struct {
+	mu    sync.Mutex
+	spans []RecordedSpan
+}
+
+type recorderKey struct{}
+
+type recordingSpan struct {
+	recorder *Recorder
+	mu       sync.Mutex
+	span     RecordedSpan
+}
+
+// Start implements Tracer.
+func (r *Recorder) Start(ctx context.Context, name string) (context.Context, Span) {
+	span := &recordingSpan{recorder: r, span: RecordedSpan{Name: name, Attributes: make(map[string]any)}}
+	if parent, ok := ctx.Value(recorderKey{}).(*recordingSpan); ok && parent.recorder == r {
+		span.span.Parent = parent.span.Name
+	}
+	return context.WithValue(ctx, recorderKey{}, span), span
+}
+
+// Spans returns the ended spans, in the order they ended.
+func (r *Recorder) Spans() []RecordedSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RecordedSpan(nil), r.spans...)
+}
+
+// Reset forgets the recorded spans.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = nil
+}
+
+func (s *recordingSpan) SetAttribute(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.Attributes[key] = value
+}
+
+func (s *recordingSpan) RecordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.Err = err
+}
+
+func (s *recordingSpan) End() {
+	s.mu.Lock()
+	span := s.span
+	s.mu.Unlock()
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+	s.recorder.spans = append(s.recorder.spans, span)
+}
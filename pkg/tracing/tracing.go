@@ -0,0 +1,81 @@
+// Package tracing is the tracing hook of the storage engine. Tracer and
+// Span follow the shape of OpenTelemetry's trace API, so an adapter over
+// an OpenTelemetry tracer is a few lines, without the engine depending
+// on it. With no tracer set, spans are no-ops.
+package tracing
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Tracer starts spans. The returned context carries the new span, so
+// spans started from it are its children.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is one timed operation.
+type Span interface {
+	SetAttribute(key string, value any)
+	RecordError(err error)
+	End()
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(string, any) {}
+func (noopSpan) RecordError(error)        {}
+func (noopSpan) End()                     {}
+
+// Noop is the span returned when no tracer is set.
+var Noop Span = noopSpan{}
+
+type tracerBox struct{ tracer Tracer }
+
+// Slot holds the tracer of a component. The zero Slot has no tracer; Set
+// may run while spans are being started.
+type Slot struct {
+	box atomic.Pointer[tracerBox]
+}
+
+// Set installs t; nil turns tracing off.
+func (s *Slot) Set(t Tracer) {
+	if t == nil {
+		s.box.Store(nil)
+		return
+	}
+	s.box.Store(&tracerBox{tracer: t})
+}
+
+// Tracer returns the installed tracer, or nil.
+func (s *Slot) Tracer() Tracer {
+	if box := s.box.Load(); box != nil {
+		return box.tracer
+	}
+	return nil
+}
+
+// Start starts a span with the installed tracer, or returns ctx and Noop
+// when there is none.
+func (s *Slot) Start(ctx context.Context, name string) (context.Context, Span) {
+	if box := s.box.Load(); box != nil {
+		return box.tracer.Start(ctx, name)
+	}
+	return ctx, Noop
+}
+
+// Recording reports whether span is not Noop. Callers check it before
+// building attributes, which would otherwise cost allocations with
+// tracing off.
+func Recording(span Span) bool {
+	return span != Noop
+}
+
+// End records err on span, when not nil, and ends it.
+func End(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
@@ -0,0 +1,103 @@
+package types
+
+import "strings"
+
+// Collation defines how an index compares VarcharKeys. The comparison is
+// made on the collation key (Key) of the string: strings with the same key
+// are equal to the index and the order follows that of the keys.
+type Collation string
+
+const (
+	// CollationBinary compares bytewise (the default).
+	CollationBinary Collation = ""
+	// CollationCaseInsensitive ignores letter case.
+	CollationCaseInsensitive Collation = "ci"
+	// CollationAccentInsensitive ignores case and the accents of Latin
+	// letters, so "Álvaro", "ALVARO" and "alvaro" are equal and sort next
+	// to "alvo".
+	CollationAccentInsensitive Collation = "ci_ai"
+)
+
+// Valid reports whether c is a known collation.
+func (c Collation) Valid() bool {
+	switch c {
+	case CollationBinary, CollationCaseInsensitive, CollationAccentInsensitive:
+		return true
+	}
+	return false
+}
+
+// Key returns the collation key of s.
+func (c Collation) Key(s string) string {
+	switch c {
+	case CollationCaseInsensitive:
+		return strings.ToLower(s)
+	case CollationAccentInsensitive:
+		return strings.Map(foldAccent, strings.ToLower(s))
+	}
+	return s
+}
+
+// Apply replaces VarcharKeys (and the elements of an ArrayKey) with their
+// collation key; other keys come back unchanged.
+func (c Collation) Apply(k Comparable) Comparable {
+	if c == CollationBinary {
+		return k
+	}
+	switch v := k.(type) {
+	case VarcharKey:
+		return VarcharKey(c.Key(string(v)))
+	case ArrayKey:
+		out := make(ArrayKey, len(v))
+		for i, elem := range v {
+			out[i] = c.Apply(elem)
+		}
+		return out
+	}
+	return k
+}
+
+// Compare compares a and b under the collation.
+func (c Collation) Compare(a, b Comparable) int {
+	return c.Apply(a).Compare(c.Apply(b))
+}
+
+// accentBase maps the accented (lowercase) Latin letters to their base
+// letter.
+var accentBase = func() map[rune]rune {
+	groups := map[rune]string{
+		'a': "àáâãäåāăą",
+		'c': "çćĉċč",
+		'd': "ďđ",
+		'e': "èéêëēĕėęě",
+		'g': "ĝğġģ",
+		'h': "ĥħ",
+		'i': "ìíîïĩīĭįı",
+		'j': "ĵ",
+		'k': "ķ",
+		'l': "ĺļľŀł",
+		'n': "ñńņňŉ",
+		'o': "òóôõöøōŏő",
+		'r': "ŕŗř",
+		's': "śŝşš",
+		't': "ţťŧ",
+		'u': "ùúûüũūŭůűų",
+		'w': "ŵ",
+		'y': "ýÿŷ",
+		'z': "źżž",
+	}
+	m := make(map[rune]rune)
+	for base, accented := range groups {
+		for _, r := range accented {
+			m[r] = base
+		}
+	}
+	return m
+}()
+
+func foldAccent(r rune) rune {
+	if base, ok := accentBase[r]; ok {
+		return base
+	}
+	return r
+}
@@ -1,7 +1,12 @@
 package types
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"math"
+	"strings"
 	"time"
 )
 
@@ -12,10 +17,31 @@ type Comparable interface {
 
 // === Implementações de Chave ===
 
+// NullKey: NULL key of a nullable index. It sorts before any value: the
+// Compare of every key handles NullKey.
+type NullKey struct{}
+
+func (NullKey) Compare(other Comparable) int {
+	if isNull(other) {
+		return 0
+	}
+	return -1
+}
+
+func (NullKey) String() string { return "NULL" }
+
+func isNull(k Comparable) bool {
+	_, ok := k.(NullKey)
+	return ok
+}
+
 // IntKey: Chave de Inteiro
 type IntKey int
 
 func (k IntKey) Compare(other Comparable) int {
+	if isNull(other) {
+		return 1
+	}
 	o := other.(IntKey)
 	if k < o {
 		return -1
@@ -30,6 +56,9 @@ func (k IntKey) Compare(other Comparable) int {
 type VarcharKey string
 
 func (k VarcharKey) Compare(other Comparable) int {
+	if isNull(other) {
+		return 1
+	}
 	o := other.(VarcharKey)
 	if k < o {
 		return -1
@@ -44,6 +73,9 @@ func (k VarcharKey) Compare(other Comparable) int {
 type FloatKey float64
 
 func (k FloatKey) Compare(other Comparable) int {
+	if isNull(other) {
+		return 1
+	}
 	o := other.(FloatKey)
 	if k < o {
 		return -1
@@ -58,6 +90,9 @@ func (k FloatKey) Compare(other Comparable) int {
 type BoolKey bool
 
 func (k BoolKey) Compare(other Comparable) int {
+	if isNull(other) {
+		return 1
+	}
 	o := other.(BoolKey)
 	if k == o {
 		return 0
@@ -72,6 +107,9 @@ func (k BoolKey) Compare(other Comparable) int {
 type DateKey time.Time
 
 func (k DateKey) Compare(other Comparable) int {
+	if isNull(other) {
+		return 1
+	}
 	o := time.Time(other.(DateKey))
 	t := time.Time(k)
 	if t.Before(o) {
@@ -83,10 +121,170 @@ func (k DateKey) Compare(other Comparable) int {
 	return 0
 }
 
+// DecimalScale is the number of decimal places of DecimalKey.
+const DecimalScale = 4
+
+// DecimalKey: Exact fixed-point decimal key (monetary values). The value
+// is stored in units of 10^-DecimalScale: DecimalKey(123400) is 12.34.
+// Use ParseDecimal to read a literal without loss.
+type DecimalKey int64
+
+func (k DecimalKey) Compare(other Comparable) int {
+	if isNull(other) {
+		return 1
+	}
+	o := other.(DecimalKey)
+	if k < o {
+		return -1
+	}
+	if k > o {
+		return 1
+	}
+	return 0
+}
+
+// ParseDecimal reads a literal such as "-12.34". More than DecimalScale
+// decimal places is an error rather than rounding.
+func ParseDecimal(s string) (DecimalKey, error) {
+	digits, negative := s, false
+	if strings.HasPrefix(digits, "-") || strings.HasPrefix(digits, "+") {
+		negative, digits = digits[0] == '-', digits[1:]
+	}
+	whole, frac, _ := strings.Cut(digits, ".")
+	if whole == "" && frac == "" || len(frac) > DecimalScale {
+		return 0, fmt.Errorf("types: invalid decimal %q", s)
+	}
+	frac += strings.Repeat("0", DecimalScale-len(frac))
+
+	var units uint64
+	for _, c := range whole + frac {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("types: invalid decimal %q", s)
+		}
+		if units > (math.MaxInt64-uint64(c-'0'))/10 {
+			return 0, fmt.Errorf("types: decimal %q out of range", s)
+		}
+		units = units*10 + uint64(c-'0')
+	}
+	if negative {
+		return DecimalKey(-int64(units)), nil
+	}
+	return DecimalKey(units), nil
+}
+
+// UUIDKey: 16-byte UUID key. Compare is bytewise, which orders UUIDv7 by
+// creation time (the leading 48 bits are the timestamp in milliseconds).
+type UUIDKey [16]byte
+
+func (k UUIDKey) Compare(other Comparable) int {
+	if isNull(other) {
+		return 1
+	}
+	o := other.(UUIDKey)
+	return bytes.Compare(k[:], o[:])
+}
+
+// ParseUUID reads a UUID in canonical form (8-4-4-4-12) or as 32
+// hexadecimal digits.
+func ParseUUID(s string) (UUIDKey, error) {
+	var k UUIDKey
+	clean := s
+	if len(s) == 36 {
+		if s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+			return k, fmt.Errorf("types: invalid uuid %q", s)
+		}
+		clean = s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	}
+	if len(clean) != 32 {
+		return k, fmt.Errorf("types: invalid uuid %q", s)
+	}
+	if _, err := hex.Decode(k[:], []byte(clean)); err != nil {
+		return k, fmt.Errorf("types: invalid uuid %q: %w", s, err)
+	}
+	return k, nil
+}
+
+// Version returns the UUID version (7 for UUIDv7).
+func (k UUIDKey) Version() int {
+	return int(k[6] >> 4)
+}
+
+// Time returns the instant stored in a UUIDv7.
+func (k UUIDKey) Time() (time.Time, bool) {
+	if k.Version() != 7 {
+		return time.Time{}, false
+	}
+	var ms [8]byte
+	copy(ms[2:], k[:6])
+	return time.UnixMilli(int64(binary.BigEndian.Uint64(ms[:]))), true
+}
+
+// BytesKey: Opaque binary key, compared bytewise.
+type BytesKey []byte
+
+func (k BytesKey) Compare(other Comparable) int {
+	if isNull(other) {
+		return 1
+	}
+	return bytes.Compare(k, other.(BytesKey))
+}
+
+// ArrayKey: Multikey key holding the elements of an array field. The
+// index stores one entry per element; Compare is lexicographic.
+type ArrayKey []Comparable
+
+func (k ArrayKey) Compare(other Comparable) int {
+	if isNull(other) {
+		return 1
+	}
+	o := other.(ArrayKey)
+	for i := 0; i < len(k) && i < len(o); i++ {
+		if c := k[i].Compare(o[i]); c != 0 {
+			return c
+		}
+	}
+	if len(k) < len(o) {
+		return -1
+	}
+	if len(k) > len(o) {
+		return 1
+	}
+	return 0
+}
+
+func (k ArrayKey) String() string {
+	parts := make([]string, len(k))
+	for i, elem := range k {
+		parts[i] = fmt.Sprintf("%v", elem)
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
 func (k DateKey) String() string {
 	return time.Time(k).Format("2006-01-02 15:04:05")
 }
 
+func (k DecimalKey) String() string {
+	sign, units := "", uint64(k)
+	if k < 0 {
+		sign, units = "-", uint64(-k)
+	}
+	scale := uint64(math.Pow10(DecimalScale))
+	whole, frac := units/scale, units%scale
+	if frac == 0 {
+		return fmt.Sprintf("%s%d", sign, whole)
+	}
+	digits := strings.TrimRight(fmt.Sprintf("%0*d", DecimalScale, frac), "0")
+	return fmt.Sprintf("%s%d.%s", sign, whole, digits)
+}
+
+func (k UUIDKey) String() string {
+	h := hex.EncodeToString(k[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+func (k BytesKey) String() string { return hex.EncodeToString(k) }
+
 func (k IntKey) String() string     { return fmt.Sprintf("%d", k) }
 func (k VarcharKey) String() string { return string(k) }
 func (k FloatKey) String() string   { return fmt.Sprintf("%f", k) }
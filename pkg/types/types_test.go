@@ -240,3 +240,104 @@ func TestDateKey_Compare_DifferentTimes(t *testing.T) {
 		t.Errorf("Expected -1 for morning < evening, got %d", result)
 	}
 }
+
+// =============================================
+// TESTS FOR DecimalKey, UUIDKey AND BytesKey
+// =============================================
+
+func TestParseDecimal(t *testing.T) {
+	cases := map[string]DecimalKey{
+		"12.34":  123400,
+		"-0.5":   -5000,
+		"7":      70000,
+		"+.0001": 1,
+	}
+	for in, want := range cases {
+		got, err := ParseDecimal(in)
+		if err != nil || got != want {
+			t.Errorf("ParseDecimal(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "-", "1.23456", "1e3", "99999999999999999"} {
+		if _, err := ParseDecimal(in); err == nil {
+			t.Errorf("ParseDecimal(%q) should fail", in)
+		}
+	}
+	if s := DecimalKey(-123400).String(); s != "-12.34" {
+		t.Errorf("Expected -12.34, got %s", s)
+	}
+	if DecimalKey(100).Compare(DecimalKey(99)) != 1 {
+		t.Error("Expected 0.01 > 0.0099")
+	}
+}
+
+func TestUUIDKey_OrdersUUIDv7ByTime(t *testing.T) {
+	early, err := ParseUUID("01890a5d-ac96-774b-bcce-b302099a8057")
+	if err != nil {
+		t.Fatal(err)
+	}
+	late, err := ParseUUID("01890a5dac97774bbcceb302099a8057")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if early.Compare(late) != -1 {
+		t.Error("Expected the earlier UUIDv7 to sort first")
+	}
+	if ts, ok := early.Time(); !ok || ts.UnixMilli() != 0x01890a5dac96 {
+		t.Errorf("unexpected UUIDv7 time %v (ok=%v)", ts, ok)
+	}
+	if s := early.String(); s != "01890a5d-ac96-774b-bcce-b302099a8057" {
+		t.Errorf("unexpected uuid string %s", s)
+	}
+	if _, err := ParseUUID("01890a5d-ac96-774b-bcce"); err == nil {
+		t.Error("Expected a short uuid to be rejected")
+	}
+}
+
+func TestBytesKey_Compare(t *testing.T) {
+	if (BytesKey{0x01}).Compare(BytesKey{0x01, 0x00}) != -1 {
+		t.Error("Expected a prefix to sort first")
+	}
+	if (BytesKey{0xff}).String() != "ff" {
+		t.Error("Expected hex string")
+	}
+}
+
+func TestNullKey_SortsBeforeEveryValue(t *testing.T) {
+	values := []Comparable{
+		IntKey(-1), VarcharKey(""), FloatKey(-1e9), BoolKey(false), DateKey(time.Unix(0, 0)),
+		DecimalKey(-1), UUIDKey{}, BytesKey{}, ArrayKey{},
+	}
+	for _, v := range values {
+		if (NullKey{}).Compare(v) != -1 || v.Compare(NullKey{}) != 1 {
+			t.Errorf("%T: expected NULL to sort first", v)
+		}
+	}
+	if (NullKey{}).Compare(NullKey{}) != 0 {
+		t.Error("Expected NULL == NULL")
+	}
+}
+
+func TestCollation_Compare(t *testing.T) {
+	if CollationBinary.Compare(VarcharKey("a"), VarcharKey("A")) != 1 {
+		t.Error("Expected binary collation to compare bytewise")
+	}
+	if CollationCaseInsensitive.Compare(VarcharKey("Bob"), VarcharKey("bOB")) != 0 {
+		t.Error("Expected case-insensitive equality")
+	}
+	if CollationCaseInsensitive.Compare(VarcharKey("Álvaro"), VarcharKey("alvaro")) == 0 {
+		t.Error("Expected accents to matter in case-insensitive collation")
+	}
+	if CollationAccentInsensitive.Compare(VarcharKey("Álvaro"), VarcharKey("ALVARO")) != 0 {
+		t.Error("Expected accent-insensitive equality")
+	}
+	if CollationAccentInsensitive.Compare(VarcharKey("Álvaro"), VarcharKey("Bruno")) != -1 {
+		t.Error("Expected Álvaro to sort before Bruno")
+	}
+	if CollationAccentInsensitive.Compare(IntKey(1), IntKey(2)) != -1 {
+		t.Error("Expected non-string keys to compare as they are")
+	}
+	if Collation("pt_BR").Valid() {
+		t.Error("Expected unknown collation to be invalid")
+	}
+}
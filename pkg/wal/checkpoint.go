@@ -0,0 +1,125 @@
+package wal
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// CheckpointRecord is the payload of an EntryCheckpoint.
+//
+// Format: beginLSN (8 bytes) optionally followed by the fences: count
+// (4 bytes) and, for each one, keyLen (2 bytes), key and LSN (8 bytes);
+// after them, optionally, the endLSN (8 bytes). Older records hold only
+// the beginLSN, or only it and the fences, and decode with a zero EndLSN.
+type CheckpointRecord struct {
+	// BeginLSN: recovery skips every entry with LSN < BeginLSN. Every
+	// write with a lower LSN was already in the pages when the flush began.
+	BeginLSN uint64
+	// EndLSN is the last LSN allocated when the flush finished: the
+	// flushed pages may hold writes up to it, so the redo of
+	// [BeginLSN, EndLSN] must be idempotent. Zero in older records.
+	EndLSN uint64
+	// Fences hold, per key (storage uses "table.index"), the last LSN
+	// already durable for that target: entries with LSN <= fence need no
+	// redo.
+	Fences map[string]uint64
+}
+
+// EncodeCheckpoint serializes rec into the payload of an EntryCheckpoint.
+func EncodeCheckpoint(rec CheckpointRecord) []byte {
+	size := 8
+	if len(rec.Fences) > 0 || rec.EndLSN != 0 {
+		size += 4
+		for key := range rec.Fences {
+			size += 2 + len(key) + 8
+		}
+	}
+	if rec.EndLSN != 0 {
+		size += 8
+	}
+	buf := make([]byte, 8, size)
+	binary.LittleEndian.PutUint64(buf, rec.BeginLSN)
+	if len(rec.Fences) == 0 && rec.EndLSN == 0 {
+		return buf
+	}
+
+	keys := make([]string, 0, len(rec.Fences))
+	for key := range rec.Fences {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(keys)))
+	for _, key := range keys {
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(len(key)))
+		buf = append(buf, key...)
+		buf = binary.LittleEndian.AppendUint64(buf, rec.Fences[key])
+	}
+	if rec.EndLSN != 0 {
+		buf = binary.LittleEndian.AppendUint64(buf, rec.EndLSN)
+	}
+	return buf
+}
+
+// DecodeCheckpoint reads the payload of an EntryCheckpoint.
+func DecodeCheckpoint(payload []byte) (CheckpointRecord, error) {
+	var rec CheckpointRecord
+	if len(payload) < 8 {
+		return rec, fmt.Errorf("wal: checkpoint payload too short: %d bytes", len(payload))
+	}
+	rec.BeginLSN = binary.LittleEndian.Uint64(payload[:8])
+	rest := payload[8:]
+	if len(rest) == 0 {
+		return rec, nil
+	}
+	if len(rest) < 4 {
+		return rec, fmt.Errorf("wal: checkpoint fences truncated")
+	}
+	count := binary.LittleEndian.Uint32(rest[:4])
+	rest = rest[4:]
+	rec.Fences = make(map[string]uint64, count)
+	for i := uint32(0); i < count; i++ {
+		if len(rest) < 2 {
+			return rec, fmt.Errorf("wal: checkpoint fence %d truncated", i)
+		}
+		keyLen := int(binary.LittleEndian.Uint16(rest[:2]))
+		rest = rest[2:]
+		if len(rest) < keyLen+8 {
+			return rec, fmt.Errorf("wal: checkpoint fence %d truncated", i)
+		}
+		key := string(rest[:keyLen])
+		rec.Fences[key] = binary.LittleEndian.Uint64(rest[keyLen : keyLen+8])
+		rest = rest[keyLen+8:]
+	}
+	if len(rest) == 8 {
+		rec.EndLSN = binary.LittleEndian.Uint64(rest)
+		rest = rest[8:]
+	}
+	if len(rest) != 0 {
+		return rec, fmt.Errorf("wal: checkpoint payload has %d trailing bytes", len(rest))
+	}
+	return rec, nil
+}
+
+// WriteCheckpoint writes rec as an EntryCheckpoint and syncs.
+func (w *WALWriter) WriteCheckpoint(rec CheckpointRecord) error {
+	payload := EncodeCheckpoint(rec)
+
+	entry := AcquireEntry()
+	entry.Header.Magic = WALMagic
+	entry.Header.Version = WALVersion
+	entry.Header.EntryType = EntryCheckpoint
+	entry.Header.LSN = rec.BeginLSN
+	entry.Header.PayloadLen = uint32(len(payload))
+	entry.Header.CRC32 = CalculateCRC32(payload)
+	entry.Payload = append(entry.Payload[:0], payload...)
+
+	err := w.WriteEntry(entry)
+	ReleaseEntry(entry)
+
+	if err != nil {
+		return fmt.Errorf("wal: write checkpoint record: %w", err)
+	}
+	return w.Sync()
+}
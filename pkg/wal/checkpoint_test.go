@@ -0,0 +1,80 @@
+package wal
+
+import (
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpointRecord_RoundTrip(t *testing.T) {
+	rec := CheckpointRecord{
+		BeginLSN: 42,
+		Fences:   map[string]uint64{"users.id": 40, "users.email": 41, "orders.id": 7},
+	}
+	got, err := DecodeCheckpoint(EncodeCheckpoint(rec))
+	if err != nil {
+		t.Fatalf("DecodeCheckpoint: %v", err)
+	}
+	if got.BeginLSN != rec.BeginLSN || len(got.Fences) != len(rec.Fences) {
+		t.Fatalf("decoded %+v, want %+v", got, rec)
+	}
+	for key, lsn := range rec.Fences {
+		if got.Fences[key] != lsn {
+			t.Fatalf("fence %s = %d, want %d", key, got.Fences[key], lsn)
+		}
+	}
+
+	// Older records hold only the beginLSN.
+	legacy := make([]byte, 8)
+	binary.LittleEndian.PutUint64(legacy, 9)
+	got, err = DecodeCheckpoint(legacy)
+	if err != nil || got.BeginLSN != 9 || got.Fences != nil {
+		t.Fatalf("legacy decode = %+v, %v", got, err)
+	}
+
+	payload := EncodeCheckpoint(rec)
+	if _, err := DecodeCheckpoint(payload[:len(payload)-3]); err == nil {
+		t.Fatal("a truncated checkpoint payload should fail")
+	}
+
+	// The endLSN comes after the fences, with or without them.
+	for _, fences := range []map[string]uint64{rec.Fences, nil} {
+		ended := CheckpointRecord{BeginLSN: 42, EndLSN: 57, Fences: fences}
+		got, err = DecodeCheckpoint(EncodeCheckpoint(ended))
+		if err != nil || got.BeginLSN != 42 || got.EndLSN != 57 || len(got.Fences) != len(fences) {
+			t.Fatalf("decode with endLSN = %+v, %v", got, err)
+		}
+	}
+	if got, err := DecodeCheckpoint(payload); err != nil || got.EndLSN != 0 {
+		t.Fatalf("record without endLSN decoded as %+v, %v", got, err)
+	}
+}
+
+func TestWriteCheckpoint_ReadBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.log")
+	w, err := NewWALWriter(path, DefaultOptions())
+	if err != nil {
+		t.Fatalf("NewWALWriter: %v", err)
+	}
+	if err := w.WriteCheckpoint(CheckpointRecord{BeginLSN: 5, Fences: map[string]uint64{"t.i": 5}}); err != nil {
+		t.Fatalf("WriteCheckpoint: %v", err)
+	}
+	w.Close()
+
+	r, err := NewWALReader(path)
+	if err != nil {
+		t.Fatalf("NewWALReader: %v", err)
+	}
+	defer r.Close()
+	entry, err := r.ReadEntry()
+	if err != nil {
+		t.Fatalf("ReadEntry: %v", err)
+	}
+	if entry.Header.EntryType != EntryCheckpoint || entry.Header.LSN != 5 {
+		t.Fatalf("header = %+v", entry.Header)
+	}
+	rec, err := DecodeCheckpoint(entry.Payload)
+	if err != nil || rec.Fences["t.i"] != 5 {
+		t.Fatalf("DecodeCheckpoint = %+v, %v", rec, err)
+	}
+}
@@ -0,0 +1,62 @@
+package wal
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+)
+
+// FlagCompressed, in WALHeader.Flags, marks a payload written with
+// DEFLATE. PayloadLen and CRC32 apply to the written bytes; the reader
+// decompresses and returns the entry without the flag, with the original
+// payload.
+const FlagCompressed uint16 = 1 << 0
+
+// DefaultCompressMinSize is the smallest payload Options.Compression tries
+// to compress: below it the codec overhead eats the gain.
+const DefaultCompressMinSize = 256
+
+// compressPayload returns the compressed payload, or nil when it does not
+// pay off (too small or it does not shrink).
+func compressPayload(payload []byte, minSize int) ([]byte, error) {
+	if minSize <= 0 {
+		minSize = DefaultCompressMinSize
+	}
+	if len(payload) < minSize {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	if buf.Len() >= len(payload) {
+		return nil, nil
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressEntry undoes compressPayload on an entry read with
+// FlagCompressed; entries without the flag pass through untouched.
+func decompressEntry(entry *WALEntry) error {
+	if entry.Header.Flags&FlagCompressed == 0 {
+		return nil
+	}
+	r := flate.NewReader(bytes.NewReader(entry.Payload))
+	defer r.Close()
+	payload, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("wal: decompress entry LSN %d: %w", entry.Header.LSN, err)
+	}
+	entry.Payload = payload
+	entry.Header.PayloadLen = uint32(len(payload))
+	entry.Header.Flags &^= FlagCompressed
+	return nil
+}
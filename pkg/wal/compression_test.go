@@ -0,0 +1,98 @@
+package wal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWALWriter_CompressesPayloads(t *testing.T) {
+	dir := t.TempDir()
+	doc := func(lsn uint64) []byte {
+		return bytes.Repeat([]byte(fmt.Sprintf(`{"id": %d, "name": "ana"} `, lsn)), 400)
+	}
+	write := func(path string, opts Options, from, to uint64) {
+		t.Helper()
+		w, err := NewWALWriter(path, opts)
+		if err != nil {
+			t.Fatalf("NewWALWriter: %v", err)
+		}
+		for lsn := from; lsn <= to; lsn++ {
+			payload := doc(lsn)
+			if lsn == to {
+				payload = []byte("small") // below CompressMinSize
+			}
+			entry := lifecycleEntry(lsn, payload)
+			if err := w.WriteEntry(entry); err != nil {
+				t.Fatalf("WriteEntry %d: %v", lsn, err)
+			}
+			if entry.Header.PayloadLen != uint32(len(payload)) || entry.Header.Flags != 0 {
+				t.Fatalf("WriteEntry changed the caller's header: %+v", entry.Header)
+			}
+			ReleaseEntry(entry)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	}
+
+	plain := filepath.Join(dir, "plain.log")
+	write(plain, DefaultOptions(), 1, 20)
+	compressedOpts := DefaultOptions()
+	compressedOpts.Compression = true
+	compressed := filepath.Join(dir, "compressed.log")
+	write(compressed, compressedOpts, 1, 20)
+	// Reopening without compression mixes both kinds of entries in the log.
+	write(compressed, DefaultOptions(), 21, 22)
+
+	plainInfo, _ := os.Stat(plain)
+	compressedInfo, _ := os.Stat(compressed)
+	if compressedInfo.Size()*4 > plainInfo.Size() {
+		t.Fatalf("compressed WAL is %d bytes, plain %d", compressedInfo.Size(), plainInfo.Size())
+	}
+
+	want := func(lsn uint64) []byte {
+		if lsn == 20 || lsn == 22 {
+			return []byte("small")
+		}
+		return doc(lsn)
+	}
+	r, err := NewWALReader(compressed)
+	if err != nil {
+		t.Fatalf("NewWALReader: %v", err)
+	}
+	defer r.Close()
+	for lsn := uint64(1); lsn <= 22; lsn++ {
+		entry, err := r.ReadEntry()
+		if err != nil {
+			t.Fatalf("ReadEntry %d: %v", lsn, err)
+		}
+		if entry.Header.LSN != lsn || !bytes.Equal(entry.Payload, want(lsn)) {
+			t.Fatalf("entry %d: LSN %d, %d bytes", lsn, entry.Header.LSN, len(entry.Payload))
+		}
+		if entry.Header.Flags != 0 || entry.Header.PayloadLen != uint32(len(entry.Payload)) {
+			t.Fatalf("entry %d header after decompression: %+v", lsn, entry.Header)
+		}
+		ReleaseEntry(entry)
+	}
+	if _, err := r.ReadEntry(); err != io.EOF {
+		t.Fatalf("ReadEntry at the end = %v, want io.EOF", err)
+	}
+
+	follower, err := NewFollower(compressed, 1, FollowerOptions{PollInterval: time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewFollower: %v", err)
+	}
+	defer follower.Close()
+	for lsn := uint64(1); lsn <= 22; lsn++ {
+		entry := nextFollowed(t, follower)
+		if entry.Header.LSN != lsn || !bytes.Equal(entry.Payload, want(lsn)) {
+			t.Fatalf("followed entry %d: LSN %d", lsn, entry.Header.LSN)
+		}
+		ReleaseEntry(entry)
+	}
+}
@@ -0,0 +1,110 @@
+package wal
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func writeDumpEntry(t *testing.T, w *WALWriter, lsn uint64, entryType uint8, payload string, crc uint32) {
+	t.Helper()
+	e := AcquireEntry()
+	e.Header.Magic = WALMagic
+	e.Header.Version = 1
+	e.Header.EntryType = entryType
+	e.Header.LSN = lsn
+	e.Header.PayloadLen = uint32(len(payload))
+	e.Header.CRC32 = crc
+	e.Payload = append(e.Payload, payload...)
+	if err := w.WriteEntry(e); err != nil {
+		t.Fatalf("WriteEntry: %v", err)
+	}
+}
+
+// TestDump_ShowsBadCRCAndContinues: an entry with a bad CRC shows as BAD
+// and the following ones are still read; the LSN and table filters apply
+// to the intact entries.
+func TestDump_ShowsBadCRCAndContinues(t *testing.T) {
+	path := t.TempDir() + "/wal.log"
+	w, err := NewWALWriter(path, Options{SyncPolicy: SyncEveryWrite, BufferSize: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeDumpEntry(t, w, 1, EntryInsert, "users:1", CalculateCRC32([]byte("users:1")))
+	writeDumpEntry(t, w, 2, EntryUpdate, "users:2", 0xdeadbeef)
+	writeDumpEntry(t, w, 3, EntryDelete, "orders:9", CalculateCRC32([]byte("orders:9")))
+	w.Close()
+
+	describe := func(entry *WALEntry) (string, string) {
+		table, key, _ := strings.Cut(string(entry.Payload), ":")
+		return table, key
+	}
+
+	var out bytes.Buffer
+	summary, err := Dump(path, &out, DumpOptions{Describe: describe})
+	if err != nil {
+		t.Fatalf("Dump: %v", err)
+	}
+	if summary != (DumpSummary{Entries: 3, Shown: 3, BadCRC: 1}) {
+		t.Fatalf("summary = %+v", summary)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[0], "LSN") {
+		t.Fatalf("output = %q", out.String())
+	}
+	if f := strings.Fields(lines[1]); strings.Join(f, " ") != "1 INSERT users 1 7 ok" {
+		t.Fatalf("line 1 = %q", lines[1])
+	}
+	if f := strings.Fields(lines[2]); f[0] != "2" || f[len(f)-1] != "BAD" {
+		t.Fatalf("line 2 = %q", lines[2])
+	}
+	if lines[4] != "3 of 3 entries, 1 with a bad CRC" {
+		t.Fatalf("footer = %q", lines[4])
+	}
+
+	out.Reset()
+	summary, err = Dump(path, &out, DumpOptions{JSON: true, Table: "orders", Describe: describe})
+	if err != nil {
+		t.Fatalf("Dump JSON: %v", err)
+	}
+	var got []DumpEntry
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var e DumpEntry
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, e)
+	}
+	// The corrupted entry appears even with the table filter.
+	if len(got) != 2 || got[0].LSN != 2 || got[0].CRCOK || got[1] != (DumpEntry{LSN: 3, Type: "DELETE", Table: "orders", Key: "9", Size: 8, CRCOK: true}) {
+		t.Fatalf("JSON entries = %+v", got)
+	}
+
+	out.Reset()
+	if summary, err = Dump(path, &out, DumpOptions{FromLSN: 3, ToLSN: 3}); err != nil || summary.Shown != 1 {
+		t.Fatalf("LSN range: %+v, %v", summary, err)
+	}
+}
+
+// TestReadEntry_StillRejectsBadCRC: normal reads still reject corrupted
+// entries.
+func TestReadEntry_StillRejectsBadCRC(t *testing.T) {
+	path := t.TempDir() + "/wal.log"
+	w, err := NewWALWriter(path, Options{SyncPolicy: SyncEveryWrite, BufferSize: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeDumpEntry(t, w, 1, EntryInsert, "x", 1)
+	w.Close()
+
+	r, err := NewWALReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := r.ReadEntry(); err != ErrChecksumMismatch {
+		t.Fatalf("ReadEntry = %v, want ErrChecksumMismatch", err)
+	}
+}
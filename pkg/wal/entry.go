@@ -2,6 +2,7 @@ package wal
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io"
 )
 
@@ -14,26 +15,64 @@ const (
 	WALMagic = 0xDEADBEEF
 )
 
-// Tipos de Operação (EntryType)
+// Operation types (EntryType)
 const (
-	EntryInsert      uint8 = iota + 1 // 1: Insert
-	EntryUpdate                       // 2: Update
-	EntryDelete                       // 3: Delete
-	EntryBegin                        // 4: Begin Transaction
-	EntryCommit                       // 5: Commit
-	EntryAbort                        // 6: Rollback
-	EntryMultiInsert                  // 7: Insert with multiple indices
-	EntryCheckpoint                   // 8: Checkpoint record (fuzzy checkpoint begin LSN)
-	EntryPageRedo                     // 9: after-image físico de page para recovery
-	EntryCLR                          // 10: compensation log record for undo/recovery
+	EntryInsert           uint8 = iota + 1 // 1: Insert
+	EntryUpdate                            // 2: Update
+	EntryDelete                            // 3: Delete
+	EntryBegin                             // 4: Begin Transaction
+	EntryCommit                            // 5: Commit
+	EntryAbort                             // 6: Rollback
+	EntryMultiInsert                       // 7: Insert with multiple indices
+	EntryCheckpoint                        // 8: Checkpoint record (fuzzy checkpoint begin LSN)
+	EntryPageRedo                          // 9: physical page after-image for recovery
+	EntryCLR                               // 10: compensation log record for undo/recovery
+	EntryMultiDelete                       // 11: Delete of a whole row across every index
+	EntryTruncate                          // 12: Truncate table (every earlier row is gone)
+	EntryDropTable                         // 13: Drop table
+	EntryMultiBatch                        // 14: Batch of multi-index row writes sharing one LSN
+	EntryMultiDeleteBatch                  // 15: Batch of whole-row deletes sharing one LSN
+	EntrySequence                          // 16: Sequence high-water mark (values reserved up to it)
+	EntryClock                             // 17: Wall-clock mark (unix nanos) for point-in-time recovery
+	EntryDiscard                           // 18: LSN range cut off by a point-in-time recovery
 )
 
+var entryTypeNames = [...]string{
+	EntryInsert:           "INSERT",
+	EntryUpdate:           "UPDATE",
+	EntryDelete:           "DELETE",
+	EntryBegin:            "BEGIN",
+	EntryCommit:           "COMMIT",
+	EntryAbort:            "ABORT",
+	EntryMultiInsert:      "MULTI_INSERT",
+	EntryCheckpoint:       "CHECKPOINT",
+	EntryPageRedo:         "PAGE_REDO",
+	EntryCLR:              "CLR",
+	EntryMultiDelete:      "MULTI_DELETE",
+	EntryTruncate:         "TRUNCATE",
+	EntryDropTable:        "DROP_TABLE",
+	EntryMultiBatch:       "MULTI_BATCH",
+	EntryMultiDeleteBatch: "MULTI_DELETE_BATCH",
+	EntrySequence:         "SEQUENCE",
+	EntryClock:            "CLOCK",
+	EntryDiscard:          "DISCARD",
+}
+
+// EntryTypeName returns the name of an EntryType, e.g. "MULTI_INSERT".
+// Unknown types become "TYPE(n)".
+func EntryTypeName(t uint8) string {
+	if int(t) < len(entryTypeNames) && entryTypeNames[t] != "" {
+		return entryTypeNames[t]
+	}
+	return fmt.Sprintf("TYPE(%d)", t)
+}
+
 // WALHeader cabeçalho de 24 bytes para cada entrada
 type WALHeader struct {
 	Magic      uint32 // 4 bytes
 	Version    uint8  // 1 byte
 	EntryType  uint8  // 1 byte
-	Reserved   uint16 // 2 bytes (padding/alinhamento)
+	Flags      uint16 // 2 bytes (FlagCompressed)
 	LSN        uint64 // 8 bytes (Log Sequence Number)
 	PayloadLen uint32 // 4 bytes
 	CRC32      uint32 // 4 bytes
@@ -50,7 +89,7 @@ func (h *WALHeader) Encode(buf []byte) {
 	binary.LittleEndian.PutUint32(buf[0:4], h.Magic)
 	buf[4] = h.Version
 	buf[5] = h.EntryType
-	binary.LittleEndian.PutUint16(buf[6:8], h.Reserved)
+	binary.LittleEndian.PutUint16(buf[6:8], h.Flags)
 	binary.LittleEndian.PutUint64(buf[8:16], h.LSN)
 	binary.LittleEndian.PutUint32(buf[16:20], h.PayloadLen)
 	binary.LittleEndian.PutUint32(buf[20:24], h.CRC32)
@@ -61,7 +100,7 @@ func (h *WALHeader) Decode(buf []byte) {
 	h.Magic = binary.LittleEndian.Uint32(buf[0:4])
 	h.Version = buf[4]
 	h.EntryType = buf[5]
-	h.Reserved = binary.LittleEndian.Uint16(buf[6:8])
+	h.Flags = binary.LittleEndian.Uint16(buf[6:8])
 	h.LSN = binary.LittleEndian.Uint64(buf[8:16])
 	h.PayloadLen = binary.LittleEndian.Uint32(buf[16:20])
 	h.CRC32 = binary.LittleEndian.Uint32(buf[20:24])
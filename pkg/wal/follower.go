@@ -0,0 +1,279 @@
+package wal
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bobboyms/storage-engine/pkg/crypto"
+	"github.com/bobboyms/storage-engine/pkg/pagestore"
+)
+
+// ErrFollowerClosed is returned by Next after Close.
+var ErrFollowerClosed = errors.New("wal: follower closed")
+
+// ErrFollowerLost reports that the segment a follower was reading was
+// removed (e.g. by a checkpoint) before the follower got past it.
+var ErrFollowerLost = errors.New("wal: follower lost its segment")
+
+// DefaultFollowerPollInterval is the interval between checks of the end of the log.
+const DefaultFollowerPollInterval = 10 * time.Millisecond
+
+// FollowerOptions configures a Follower.
+type FollowerOptions struct {
+	// Cipher decrypts a WAL with TDE; nil reads it as plaintext.
+	Cipher crypto.Cipher
+	// PollInterval is how long Next waits before looking for new entries
+	// at the end of the log. Zero uses DefaultFollowerPollInterval.
+	PollInterval time.Duration
+}
+
+// Follower reads the WAL while it is written, possibly by another
+// process. It starts at the oldest archived segment, follows the rotations
+// up to the active WAL and, at the end of the log, waits for new entries.
+// It only sees what the writer has already written to the file (see
+// WALWriter.Sync).
+//
+// A Follower is not safe for concurrent use, except for Close.
+type Follower struct {
+	mu sync.Mutex // serializes Next and Close on the file
+
+	base         string
+	cipher       crypto.Cipher
+	fromLSN      uint64
+	pollInterval time.Duration
+
+	pf       *pagestore.PageFile
+	info     os.FileInfo // identity of the open file, survives a rename
+	pageID   pagestore.PageID
+	consumed int // bytes of page pageID already copied to buffer
+	buffer   []byte
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewFollower opens the WAL at path to read the entries with LSN >= fromLSN.
+func NewFollower(path string, fromLSN uint64, opts FollowerOptions) (*Follower, error) {
+	paths, err := SegmentPaths(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("wal: follower: %s: %w", path, os.ErrNotExist)
+	}
+	f := &Follower{
+		base:         path,
+		cipher:       opts.Cipher,
+		fromLSN:      fromLSN,
+		pollInterval: opts.PollInterval,
+		closed:       make(chan struct{}),
+	}
+	if f.pollInterval <= 0 {
+		f.pollInterval = DefaultFollowerPollInterval
+	}
+	if err := f.open(paths[0]); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *Follower) open(path string) error {
+	pf, err := pagestore.NewPageFileWithOptions(path, f.cipher, pagestore.PageFileOptions{ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("wal: follower: open %s: %w", path, err)
+	}
+	info, err := pf.Stat()
+	if err != nil {
+		pf.Close()
+		return err
+	}
+	if f.pf != nil {
+		f.pf.Close()
+	}
+	f.pf, f.info = pf, info
+	f.pageID = 1 // pageID 0 is reserved by the pagestore
+	f.consumed = 0
+	return nil
+}
+
+// Next returns the next entry with LSN >= fromLSN, waiting for it at the
+// end of the log. It returns ctx.Err() if ctx is cancelled and
+// ErrFollowerClosed after Close. The entry may be handed back with
+// ReleaseEntry.
+func (f *Follower) Next(ctx context.Context) (*WALEntry, error) {
+	for {
+		entry, err := f.poll()
+		if entry != nil || err != nil {
+			return entry, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-f.closed:
+			return nil, ErrFollowerClosed
+		case <-time.After(f.pollInterval):
+		}
+	}
+}
+
+// poll returns the next entry already written, or nil at the end of the log.
+func (f *Follower) poll() (*WALEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for {
+		select {
+		case <-f.closed:
+			return nil, ErrFollowerClosed
+		default:
+		}
+		entry, err := f.parseEntry()
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			if entry.Header.LSN < f.fromLSN {
+				ReleaseEntry(entry)
+				continue
+			}
+			return entry, nil
+		}
+		progressed, err := f.loadMore()
+		if err != nil || !progressed {
+			return nil, err
+		}
+	}
+}
+
+// parseEntry takes a complete entry off the buffer, or returns nil if the
+// buffer does not hold one yet.
+func (f *Follower) parseEntry() (*WALEntry, error) {
+	if len(f.buffer) < HeaderSize {
+		return nil, nil
+	}
+	var header WALHeader
+	header.Decode(f.buffer[:HeaderSize])
+	if header.Magic != WALMagic {
+		return nil, ErrInvalidMagic
+	}
+	if header.PayloadLen > 1024*1024*1024 {
+		return nil, ErrInvalidPayloadLen
+	}
+	total := HeaderSize + int(header.PayloadLen)
+	if len(f.buffer) < total {
+		return nil, nil
+	}
+	payload := f.buffer[HeaderSize:total]
+	if !ValidateCRC32(payload, header.CRC32) {
+		return nil, ErrChecksumMismatch
+	}
+	entry := AcquireEntry()
+	entry.Header = header
+	entry.Payload = append(entry.Payload[:0], payload...)
+	f.buffer = f.buffer[total:]
+	if err := decompressEntry(entry); err != nil {
+		ReleaseEntry(entry)
+		return nil, err
+	}
+	return entry, nil
+}
+
+// loadMore copies the new bytes of the log into the buffer. It returns
+// false when there is nothing new yet.
+func (f *Follower) loadMore() (bool, error) {
+	// Check for rotation before reading: if the file already left the
+	// active path, the read below sees its final content.
+	rotated, err := f.rotated()
+	if err != nil {
+		return false, err
+	}
+	numPages, err := f.pf.Refresh()
+	if err != nil {
+		return false, err
+	}
+
+	progressed := false
+	for uint64(f.pageID) < numPages {
+		// A page is only final once the writer has moved to the next one.
+		last := uint64(f.pageID)+1 >= numPages
+		page, err := f.pf.ReadPage(f.pageID)
+		if err != nil {
+			if last && !rotated {
+				// The last page is being rewritten: try again later.
+				return progressed, nil
+			}
+			return progressed, fmt.Errorf("wal: follower: read page %d: %w", f.pageID, err)
+		}
+		bytesUsed := int(binary.LittleEndian.Uint16(page.Body()[0:2]))
+		if bytesUsed > f.pf.UsableBodySize()-walPageHeaderSize {
+			return progressed, fmt.Errorf("wal: bytesUsed %d on page %d exceeds limit", bytesUsed, f.pageID)
+		}
+		if bytesUsed > f.consumed {
+			f.buffer = append(f.buffer, page.Body()[walPageHeaderSize+f.consumed:walPageHeaderSize+bytesUsed]...)
+			f.consumed = bytesUsed
+			progressed = true
+		}
+		if last {
+			break
+		}
+		f.pageID++
+		f.consumed = 0
+	}
+	if progressed || !rotated {
+		return progressed, nil
+	}
+	return f.openNextSegment()
+}
+
+// rotated reports whether the open file is no longer the active WAL.
+func (f *Follower) rotated() (bool, error) {
+	info, err := os.Stat(f.base)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !os.SameFile(info, f.info), nil
+}
+
+// openNextSegment moves to the file after the one just read to the end.
+// It returns false if that file does not exist yet.
+func (f *Follower) openNextSegment() (bool, error) {
+	paths, err := SegmentPaths(f.base)
+	if err != nil {
+		return false, err
+	}
+	for i, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !os.SameFile(info, f.info) {
+			continue
+		}
+		if i+1 == len(paths) {
+			// The active WAL has not been recreated yet.
+			return false, nil
+		}
+		if len(f.buffer) != 0 {
+			return false, io.ErrUnexpectedEOF
+		}
+		return true, f.open(paths[i+1])
+	}
+	return false, ErrFollowerLost
+}
+
+// Close interrupts a waiting Next and closes the file.
+func (f *Follower) Close() error {
+	var err error
+	f.closeOnce.Do(func() {
+		close(f.closed)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		err = f.pf.Close()
+	})
+	return err
+}
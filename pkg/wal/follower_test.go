@@ -0,0 +1,93 @@
+package wal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func nextFollowed(t *testing.T, f *Follower) *WALEntry {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	entry, err := f.Next(ctx)
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	return entry
+}
+
+func TestFollower_TailsLiveWALAcrossRotations(t *testing.T) {
+	for _, maxSegment := range []int64{0, 1} {
+		path := filepath.Join(t.TempDir(), "wal.log")
+		opts := DefaultOptions()
+		opts.MaxSegmentBytes = maxSegment
+		opts.RetentionSegments = 0
+		writer, err := NewWALWriter(path, opts)
+		if err != nil {
+			t.Fatalf("NewWALWriter: %v", err)
+		}
+		defer writer.Close()
+
+		// Payloads larger than a page cross page boundaries.
+		payload := func(lsn uint64) []byte {
+			return bytes.Repeat([]byte{byte(lsn)}, int(lsn%3)*6000+10)
+		}
+		write := func(from, to uint64) {
+			for lsn := from; lsn <= to; lsn++ {
+				entry := lifecycleEntry(lsn, payload(lsn))
+				if err := writer.WriteEntry(entry); err != nil {
+					t.Fatalf("WriteEntry %d: %v", lsn, err)
+				}
+				ReleaseEntry(entry)
+			}
+			if err := writer.Sync(); err != nil {
+				t.Fatalf("Sync: %v", err)
+			}
+		}
+		write(1, 5)
+
+		follower, err := NewFollower(path, 3, FollowerOptions{PollInterval: time.Millisecond})
+		if err != nil {
+			t.Fatalf("NewFollower: %v", err)
+		}
+		check := func(want uint64) {
+			t.Helper()
+			entry := nextFollowed(t, follower)
+			if entry.Header.LSN != want || !bytes.Equal(entry.Payload, payload(want)) {
+				t.Fatalf("segments %d: expected LSN %d, got %d (%d bytes)", maxSegment, want, entry.Header.LSN, len(entry.Payload))
+			}
+			ReleaseEntry(entry)
+		}
+		for lsn := uint64(3); lsn <= 5; lsn++ {
+			check(lsn)
+		}
+
+		// At the end of the log Next waits for the writer.
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			time.Sleep(20 * time.Millisecond)
+			write(6, 12)
+		}()
+		for lsn := uint64(6); lsn <= 12; lsn++ {
+			check(lsn)
+		}
+		<-done
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		if _, err := follower.Next(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected Next to wait for new entries, got %v", err)
+		}
+		cancel()
+		if err := follower.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		if _, err := follower.Next(context.Background()); !errors.Is(err, ErrFollowerClosed) {
+			t.Fatalf("expected ErrFollowerClosed, got %v", err)
+		}
+	}
+}
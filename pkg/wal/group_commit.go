@@ -0,0 +1,131 @@
+package wal
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// groupCommit coordinates SyncGroupCommit writers.
+//
+// Each WriteEntry takes a sequence number (writeSeq) while holding w.mu and
+// then waits, without w.mu, until durable reaches it. The first writer that
+// finds no leader becomes the leader: it waits up to GroupCommitMaxDelay (or
+// until GroupCommitMaxBatch entries are pending) so other writers can join
+// the batch, runs a single fsync and wakes everyone.
+//
+// Lock order: w.mu before group.mu. Never acquire w.mu while holding
+// group.mu.
+type groupCommit struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	syncing bool  // a leader is preparing or running the fsync
+	err     error // fsync failure; sticky, durability is unknown afterwards
+
+	// durable is the highest writeSeq known to be on disk.
+	durable atomic.Uint64
+	// wake ends the leader's delay early: the batch is full, or another
+	// fsync (explicit Sync, rotation, Close) already covered it.
+	wake chan struct{}
+}
+
+func (g *groupCommit) init() {
+	g.cond = sync.NewCond(&g.mu)
+	g.wake = make(chan struct{}, 1)
+}
+
+// markDurable records that everything up to seq was fsynced and wakes the
+// writers.
+func (g *groupCommit) markDurable(seq uint64) {
+	g.mu.Lock()
+	if seq > g.durable.Load() {
+		g.durable.Store(seq)
+	}
+	g.cond.Broadcast()
+	g.mu.Unlock()
+	g.kick()
+}
+
+// kick wakes the leader, if any, without blocking.
+func (g *groupCommit) kick() {
+	select {
+	case g.wake <- struct{}{}:
+	default:
+	}
+}
+
+// fail records an fsync error; every pending and future group commit
+// writer gets it back.
+func (g *groupCommit) fail(err error) {
+	g.mu.Lock()
+	if g.err == nil {
+		g.err = err
+	}
+	g.cond.Broadcast()
+	g.mu.Unlock()
+	g.kick()
+}
+
+// writeEntryGroup writes the entry under w.mu without an fsync and returns
+// the sequence number the caller must see durable.
+func (w *WALWriter) writeEntryGroup(ctx context.Context, entry *WALEntry) (uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.writeEntryLocked(ctx, entry); err != nil {
+		return 0, err
+	}
+	if limit := w.options.GroupCommitMaxBatch; limit > 0 && w.writeSeq-w.group.durable.Load() >= uint64(limit) {
+		w.group.kick()
+	}
+	return w.writeSeq, nil
+}
+
+// waitDurable blocks until seq is covered by an fsync, leading the batch
+// fsync when no other writer is doing it.
+func (w *WALWriter) waitDurable(seq uint64) error {
+	g := &w.group
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for g.durable.Load() < seq {
+		if g.err != nil {
+			return g.err
+		}
+		// After Close the final sync of Close covers the entries; wait for
+		// its broadcast.
+		if g.syncing || w.closed.Load() {
+			g.cond.Wait()
+			continue
+		}
+
+		g.syncing = true
+		g.mu.Unlock()
+		w.leadGroupSync(seq)
+		g.mu.Lock()
+		g.syncing = false
+		g.cond.Broadcast()
+	}
+	return nil
+}
+
+// leadGroupSync waits for the batch followers and runs the shared fsync.
+// Errors reach the writers through group.fail (inside syncLocked).
+func (w *WALWriter) leadGroupSync(seq uint64) {
+	if delay := w.options.GroupCommitMaxDelay; delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-w.group.wake:
+		}
+		timer.Stop()
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed.Load() || w.group.durable.Load() >= seq {
+		return
+	}
+	_ = w.syncLocked()
+}
@@ -0,0 +1,205 @@
+package wal
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bobboyms/storage-engine/pkg/tracing"
+)
+
+func newGroupCommitEntry(lsn uint64, payload []byte) *WALEntry {
+	entry := AcquireEntry()
+	entry.Header = WALHeader{
+		Magic:      WALMagic,
+		Version:    1,
+		EntryType:  EntryInsert,
+		PayloadLen: uint32(len(payload)),
+		CRC32:      CalculateCRC32(payload),
+		LSN:        lsn,
+	}
+	entry.Payload = append(entry.Payload, payload...)
+	return entry
+}
+
+func TestWALWriter_GroupCommitConcurrentWriters(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "test_wal_group.log")
+
+	opts := GroupCommitOptions()
+	opts.GroupCommitMaxDelay = 5 * time.Millisecond
+	opts.GroupCommitMaxBatch = 16
+	w, err := NewWALWriter(tmpFile, opts)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+
+	const writers = 32
+	const perWriter = 10
+	var wg sync.WaitGroup
+	errCh := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; j < perWriter; j++ {
+				lsn := uint64(id*perWriter + j + 1)
+				entry := newGroupCommitEntry(lsn, []byte("group commit payload"))
+				err := w.WriteEntry(entry)
+				ReleaseEntry(entry)
+				if err != nil {
+					errCh <- err
+					return
+				}
+				// This goroutine's own entries are all durable by now, so
+				// the durable sequence covers at least j+1 entries.
+				if durable := w.group.durable.Load(); durable < uint64(j+1) {
+					errCh <- fmt.Errorf("WriteEntry returned with durable=%d after %d writes", durable, j+1)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		t.Fatalf("writer failed: %v", err)
+	}
+
+	total := uint64(writers * perWriter)
+	if got := w.group.durable.Load(); got != total {
+		t.Fatalf("expected %d durable entries, got %d", total, got)
+	}
+	if syncs := w.syncs.Load(); syncs >= total {
+		t.Fatalf("expected fsyncs to be shared, got %d fsyncs for %d writes", syncs, total)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	r, err := NewWALReader(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to open reader: %v", err)
+	}
+	defer r.Close()
+	seen := make(map[uint64]bool)
+	for {
+		entry, err := r.ReadEntry()
+		if err != nil {
+			break
+		}
+		seen[entry.Header.LSN] = true
+		ReleaseEntry(entry)
+	}
+	if uint64(len(seen)) != total {
+		t.Fatalf("expected %d entries in the log, read %d", total, len(seen))
+	}
+}
+
+func TestWALWriter_GroupCommitBatchSizeCutsDelay(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "test_wal_group_batch.log")
+
+	opts := GroupCommitOptions()
+	opts.GroupCommitMaxDelay = time.Hour
+	opts.GroupCommitMaxBatch = 1
+	w, err := NewWALWriter(tmpFile, opts)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	defer w.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		entry := newGroupCommitEntry(1, []byte("x"))
+		defer ReleaseEntry(entry)
+		done <- w.WriteEntry(entry)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("WriteEntry failed: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("a full batch should not wait for GroupCommitMaxDelay")
+	}
+}
+
+func TestWALWriter_GroupCommitWokenBySync(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "test_wal_group_sync.log")
+
+	opts := GroupCommitOptions()
+	opts.GroupCommitMaxDelay = time.Hour
+	opts.GroupCommitMaxBatch = 0
+	w, err := NewWALWriter(tmpFile, opts)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		entry := newGroupCommitEntry(1, []byte("x"))
+		defer ReleaseEntry(entry)
+		done <- w.WriteEntry(entry)
+	}()
+	// Wait until the entry is written; its writer then sleeps as leader.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		w.mu.Lock()
+		written := w.writeSeq
+		w.mu.Unlock()
+		if written == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("entry was never written")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := w.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("WriteEntry failed: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("an explicit Sync should release group commit waiters")
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	entry := newGroupCommitEntry(2, []byte("y"))
+	defer ReleaseEntry(entry)
+	if err := w.WriteEntry(entry); err == nil {
+		t.Fatal("expected error writing to a closed writer")
+	}
+}
+
+func TestWALWriter_TracesAppendAndFsync(t *testing.T) {
+	for _, opts := range []Options{DefaultOptions(), GroupCommitOptions()} {
+		w, err := NewWALWriter(filepath.Join(t.TempDir(), "test_wal_trace.log"), opts)
+		if err != nil {
+			t.Fatalf("Failed to create writer: %v", err)
+		}
+		rec := &tracing.Recorder{}
+		w.SetTracer(rec)
+		if err := w.WriteEntryCtx(context.Background(), newGroupCommitEntry(7, []byte("traced"))); err != nil {
+			t.Fatalf("WriteEntryCtx failed: %v", err)
+		}
+		spans := rec.Spans()
+		if len(spans) != 2 || spans[0].Name != "wal.fsync" || spans[0].Parent != "wal.append" || spans[1].Name != "wal.append" {
+			t.Fatalf("policy %v: spans = %+v, want wal.fsync under wal.append", opts.SyncPolicy, spans)
+		}
+		if lsn := spans[1].Attributes["wal.lsn"]; lsn != uint64(7) {
+			t.Fatalf("policy %v: wal.lsn = %v, want 7", opts.SyncPolicy, lsn)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bobboyms/storage-engine/pkg/crypto"
 )
@@ -77,7 +78,10 @@ func SegmentPaths(base string) ([]string, error) {
 	return paths, nil
 }
 
-func nextSegmentPath(base string) (string, error) {
+// nextSegmentPath numbers the next segment past those in the WAL
+// directory and in archiveDir, so a segment removed locally after being
+// archived never has its number reused for a different one.
+func nextSegmentPath(base, archiveDir string) (string, error) {
 	paths, err := SegmentPaths(base)
 	if err != nil {
 		return "", err
@@ -88,6 +92,18 @@ func nextSegmentPath(base string) (string, error) {
 			maxSeq = seq
 		}
 	}
+	if archiveDir != "" {
+		archiveBase := filepath.Join(archiveDir, filepath.Base(base))
+		archived, err := SegmentPaths(archiveBase)
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		for _, path := range archived {
+			if seq, ok := parseSegmentSeq(archiveBase, path); ok && seq > maxSeq {
+				maxSeq = seq
+			}
+		}
+	}
 	return segmentPath(base, maxSeq+1), nil
 }
 
@@ -134,6 +150,17 @@ func scanSegmentRange(path string, cipher crypto.Cipher) (segmentRange, error) {
 	return result, nil
 }
 
+// SegmentMaxLSN returns the highest LSN of a single WAL file (a segment or
+// the active one), without following the other segments. ok is false for a
+// file without entries.
+func SegmentMaxLSN(path string, cipher crypto.Cipher) (maxLSN uint64, ok bool, err error) {
+	rng, err := scanSegmentRange(path, cipher)
+	if err != nil {
+		return 0, false, err
+	}
+	return rng.maxLSN, rng.hasLSN, nil
+}
+
 // ArchiveAndTruncate remove segmentos locais cujo max LSN já está coberto por
 // checkpointLSN. Se archiveDir estiver configurado, copia cada segmento para lá
 // antes de remover o arquivo ativo local.
@@ -182,45 +209,91 @@ func ArchiveAndTruncate(base string, cipher crypto.Cipher, archiveDir string, ch
 	return nil
 }
 
-func archiveSegment(path, archiveDir string) error {
-	if err := os.MkdirAll(archiveDir, 0700); err != nil {
-		return err
-	}
-	dst := filepath.Join(archiveDir, filepath.Base(path))
-	if _, err := os.Stat(dst); err == nil {
-		return nil
-	} else if !os.IsNotExist(err) {
-		return err
-	}
+// ArchiveRetention says which segments to keep in the archive: a segment
+// stays while it is among the KeepLast newest or was archived less than
+// MaxAge ago. Zero turns a rule off; with both off the archive is never
+// pruned.
+type ArchiveRetention struct {
+	KeepLast int
+	MaxAge   time.Duration
+}
+
+func (r ArchiveRetention) enabled() bool {
+	return r.KeepLast > 0 || r.MaxAge > 0
+}
 
-	src, err := os.Open(path)
+// PruneArchive removes from archiveDir the segments of base that keep no
+// longer retains, as of now, and returns their paths, oldest first. An
+// archived segment is already covered by a checkpoint: it only serves to
+// roll a restored backup forward, so keep must reach back to the oldest
+// backup that should stay restorable that way.
+func PruneArchive(base, archiveDir string, keep ArchiveRetention, now time.Time) ([]string, error) {
+	if archiveDir == "" || !keep.enabled() {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(archiveDir)
 	if err != nil {
-		return err
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
 	}
-	defer src.Close()
 
-	info, err := src.Stat()
-	if err != nil {
-		return err
+	type seg struct {
+		path       string
+		seq        uint64
+		archivedAt time.Time
 	}
+	archiveBase := filepath.Join(archiveDir, filepath.Base(base))
+	segments := make([]seg, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(archiveDir, entry.Name())
+		seq, ok := parseSegmentSeq(archiveBase, path)
+		if !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, seg{path: path, seq: seq, archivedAt: info.ModTime()})
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].seq < segments[j].seq })
 
-	tmp := dst + ".tmp"
-	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
-	if err != nil {
-		return err
+	var removed []string
+	for i, segment := range segments {
+		if keep.KeepLast > 0 && i >= len(segments)-keep.KeepLast {
+			break
+		}
+		if keep.MaxAge > 0 && now.Sub(segment.archivedAt) < keep.MaxAge {
+			continue
+		}
+		if err := os.Remove(segment.path); err != nil && !os.IsNotExist(err) {
+			return removed, fmt.Errorf("wal: prune archived segment %s: %w", segment.path, err)
+		}
+		removed = append(removed, segment.path)
 	}
-	if _, err := io.Copy(out, src); err != nil {
-		out.Close()
-		return err
+	if len(removed) > 0 {
+		return removed, fsyncDir(archiveDir)
 	}
-	if err := out.Sync(); err != nil {
-		out.Close()
+	return nil, nil
+}
+
+func archiveSegment(path, archiveDir string) error {
+	if err := os.MkdirAll(archiveDir, 0700); err != nil {
 		return err
 	}
-	if err := out.Close(); err != nil {
+	dst := filepath.Join(archiveDir, filepath.Base(path))
+	if _, err := os.Stat(dst); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
 		return err
 	}
-	if err := os.Rename(tmp, dst); err != nil {
+
+	if err := copyFile(path, dst); err != nil {
 		return err
 	}
 	return fsyncDir(archiveDir)
@@ -263,6 +336,10 @@ func RestoreArchivedSegments(base, archiveDir string) error {
 	return fsyncDir(activeDir)
 }
 
+// copyFile copies src to dst through a temp file + fsync + rename, so
+// a crash mid-copy never leaves a torn segment under the final name (the
+// ".tmp" leftover does not match a segment name and is overwritten by the
+// next attempt). The caller fsyncs the directory of dst.
 func copyFile(src, dst string) error {
 	in, err := os.Open(src)
 	if err != nil {
@@ -273,7 +350,9 @@ func copyFile(src, dst string) error {
 	if err != nil {
 		return err
 	}
-	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
 	if err != nil {
 		return err
 	}
@@ -285,5 +364,8 @@ func copyFile(src, dst string) error {
 		out.Close()
 		return err
 	}
-	return out.Close()
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
 }
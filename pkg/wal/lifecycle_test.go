@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func lifecycleEntry(lsn uint64, payload []byte) *WALEntry {
@@ -132,3 +133,173 @@ func TestWALLifecycle_ArchiveTruncateAndRestore(t *testing.T) {
 		t.Fatalf("expected restored full WAL, got %v", gotAfterRestore)
 	}
 }
+
+func TestWALLifecycle_RestoreIgnoresTornCopy(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "wal.log")
+	archiveDir := filepath.Join(dir, "archive")
+
+	opts := DefaultOptions()
+	opts.MaxSegmentBytes = 1
+	opts.RetentionSegments = 0
+	writer, err := NewWALWriter(path, opts)
+	if err != nil {
+		t.Fatalf("NewWALWriter: %v", err)
+	}
+	for i := uint64(1); i <= 3; i++ {
+		entry := lifecycleEntry(i, []byte("payload"))
+		if err := writer.WriteEntry(entry); err != nil {
+			t.Fatalf("WriteEntry %d: %v", i, err)
+		}
+		ReleaseEntry(entry)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := ArchiveAndTruncate(path, nil, archiveDir, 3, 0); err != nil {
+		t.Fatalf("ArchiveAndTruncate: %v", err)
+	}
+
+	// A restore that crashed mid-copy leaves only a partial temp file.
+	torn := segmentPath(path, 1) + ".tmp"
+	if err := os.WriteFile(torn, []byte("torn"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if got := readLifecycleLSNs(t, path); len(got) != 1 || got[0] != 3 {
+		t.Fatalf("temp file must not be read as a segment, got %v", got)
+	}
+
+	if err := RestoreArchivedSegments(path, archiveDir); err != nil {
+		t.Fatalf("RestoreArchivedSegments: %v", err)
+	}
+	if got := readLifecycleLSNs(t, path); len(got) != 3 {
+		t.Fatalf("expected restored full WAL, got %v", got)
+	}
+	if _, err := os.Stat(torn); !os.IsNotExist(err) {
+		t.Fatalf("temp file should be replaced by the restored segment, stat err=%v", err)
+	}
+}
+
+// archiveFixture writes one rotated segment per entry, LSNs 1..n, and
+// archives all but the active one.
+func archiveFixture(t *testing.T, n uint64) (path, archiveDir string) {
+	t.Helper()
+	dir := t.TempDir()
+	path = filepath.Join(dir, "wal.log")
+	archiveDir = filepath.Join(dir, "archive")
+
+	opts := DefaultOptions()
+	opts.MaxSegmentBytes = 1
+	opts.RetentionSegments = 0
+	writer, err := NewWALWriter(path, opts)
+	if err != nil {
+		t.Fatalf("NewWALWriter: %v", err)
+	}
+	for i := uint64(1); i <= n; i++ {
+		entry := lifecycleEntry(i, []byte("payload"))
+		if err := writer.WriteEntry(entry); err != nil {
+			t.Fatalf("WriteEntry %d: %v", i, err)
+		}
+		ReleaseEntry(entry)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := ArchiveAndTruncate(path, nil, archiveDir, n, 0); err != nil {
+		t.Fatalf("ArchiveAndTruncate: %v", err)
+	}
+	return path, archiveDir
+}
+
+func archivedSegments(t *testing.T, archiveDir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(archiveDir)
+	if err != nil {
+		t.Fatalf("ReadDir archive: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestWALLifecycle_PruneArchive(t *testing.T) {
+	path, archiveDir := archiveFixture(t, 5)
+	all := archivedSegments(t, archiveDir)
+	if len(all) != 4 {
+		t.Fatalf("expected 4 archived segments, got %v", all)
+	}
+	now := time.Now()
+
+	// No rule: nothing goes.
+	if removed, err := PruneArchive(path, archiveDir, ArchiveRetention{}, now); err != nil || len(removed) != 0 {
+		t.Fatalf("PruneArchive without retention: removed=%v err=%v", removed, err)
+	}
+
+	// MaxAge alone: only the segments archived before the cutoff go.
+	old := now.Add(-2 * time.Hour)
+	for _, name := range all[:2] {
+		if err := os.Chtimes(filepath.Join(archiveDir, name), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	removed, err := PruneArchive(path, archiveDir, ArchiveRetention{MaxAge: time.Hour}, now)
+	if err != nil {
+		t.Fatalf("PruneArchive MaxAge: %v", err)
+	}
+	if len(removed) != 2 || filepath.Base(removed[0]) != all[0] || filepath.Base(removed[1]) != all[1] {
+		t.Fatalf("PruneArchive MaxAge removed %v, want %v", removed, all[:2])
+	}
+	if got := archivedSegments(t, archiveDir); len(got) != 2 || got[0] != all[2] {
+		t.Fatalf("archive after MaxAge = %v, want %v", got, all[2:])
+	}
+
+	// KeepLast and MaxAge: a segment stays if either rule keeps it.
+	if err := os.Chtimes(filepath.Join(archiveDir, all[2]), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if removed, err := PruneArchive(path, archiveDir, ArchiveRetention{KeepLast: 2, MaxAge: time.Hour}, now); err != nil || len(removed) != 0 {
+		t.Fatalf("PruneArchive KeepLast 2: removed=%v err=%v", removed, err)
+	}
+	removed, err = PruneArchive(path, archiveDir, ArchiveRetention{KeepLast: 1}, now)
+	if err != nil {
+		t.Fatalf("PruneArchive KeepLast 1: %v", err)
+	}
+	if got := archivedSegments(t, archiveDir); len(removed) != 1 || len(got) != 1 || got[0] != all[3] {
+		t.Fatalf("KeepLast 1 removed %v, left %v, want only %s", removed, got, all[3])
+	}
+}
+
+func TestWALLifecycle_CheckpointPrunesArchive(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "wal.log")
+	archiveDir := filepath.Join(dir, "archive")
+
+	opts := DefaultOptions()
+	opts.RetentionSegments = 0
+	opts.ArchiveDir = archiveDir
+	opts.ArchiveRetention = ArchiveRetention{KeepLast: 2}
+	writer, err := NewWALWriter(path, opts)
+	if err != nil {
+		t.Fatalf("NewWALWriter: %v", err)
+	}
+	defer writer.Close()
+
+	// Each checkpoint rotates the segment written since the last one into
+	// the archive; the archive never holds more than KeepLast of them.
+	for i := uint64(1); i <= 5; i++ {
+		entry := lifecycleEntry(i, []byte("payload"))
+		if err := writer.WriteEntry(entry); err != nil {
+			t.Fatalf("WriteEntry %d: %v", i, err)
+		}
+		ReleaseEntry(entry)
+		if err := writer.CheckpointLifecycle(i + 1); err != nil {
+			t.Fatalf("CheckpointLifecycle %d: %v", i, err)
+		}
+	}
+	got := archivedSegments(t, archiveDir)
+	if len(got) != 2 || got[0] != filepath.Base(segmentPath(path, 4)) || got[1] != filepath.Base(segmentPath(path, 5)) {
+		t.Fatalf("archive after 5 checkpoints = %v, want the last 2 segments", got)
+	}
+}
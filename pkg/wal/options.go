@@ -21,6 +21,13 @@ const (
 	// SyncBatch chama fsync() quando o buffer atinge um tamanho ou contagem.
 	// Alta performance.
 	SyncBatch
+
+	// SyncGroupCommit keeps SyncEveryWrite's guarantee (WriteEntry only
+	// returns once its entry is on disk) but shares one fsync between
+	// concurrent writers. The first writer to wait becomes the leader,
+	// collects followers for up to GroupCommitMaxDelay or until
+	// GroupCommitMaxBatch entries are pending, and fsyncs for all of them.
+	SyncGroupCommit
 )
 
 // Options configura o WAL Writer
@@ -40,6 +47,15 @@ type Options struct {
 	// Tamanho acumulado em bytes para disparar Sync (apenas SyncBatch)
 	SyncBatchBytes int64
 
+	// GroupCommitMaxDelay is how long a group commit leader waits for more
+	// writers before it fsyncs (SyncGroupCommit only). Zero syncs at once,
+	// still batching whatever arrived during the previous fsync.
+	GroupCommitMaxDelay time.Duration
+
+	// GroupCommitMaxBatch cuts the wait short once this many entries are
+	// pending (SyncGroupCommit only). Zero or negative means no limit.
+	GroupCommitMaxBatch int
+
 	// Cipher opcional para TDE (Transparent Data Encryption).
 	// Se nil, o WAL é escrito em claro (comportamento padrão).
 	// Quando configurado, o body das pages do WAL é cifrado via
@@ -60,6 +76,18 @@ type Options struct {
 	// ArchiveDir, quando configurado, recebe uma cópia dos segmentos antes
 	// de eles serem removidos do diretório ativo.
 	ArchiveDir string
+
+	// ArchiveRetention bounds what ArchiveDir keeps; CheckpointLifecycle
+	// prunes it after archiving. The zero value keeps every segment.
+	ArchiveRetention ArchiveRetention
+
+	// Compression writes with DEFLATE the payloads of at least
+	// CompressMinSize bytes (zero uses DefaultCompressMinSize) that shrink
+	// when compressed, marked with FlagCompressed. Readers decompress on
+	// their own and logs may mix entries with and without compression, so
+	// the option may change between opens.
+	Compression     bool
+	CompressMinSize int
 }
 
 // DefaultOptions retorna uma configuração segura por padrão:
@@ -78,6 +106,8 @@ func DefaultOptions() Options {
 		SyncPolicy:           SyncEveryWrite,
 		SyncIntervalDuration: 200 * time.Millisecond, // só aplicável a SyncInterval
 		SyncBatchBytes:       1 * 1024 * 1024,        // só aplicável a SyncBatch
+		GroupCommitMaxDelay:  1 * time.Millisecond,   // SyncGroupCommit only
+		GroupCommitMaxBatch:  128,                    // SyncGroupCommit only
 		MaxSegmentBytes:      64 * 1024 * 1024,
 		RetentionSegments:    1,
 	}
@@ -93,7 +123,17 @@ func PerformanceOptions() Options {
 		SyncPolicy:           SyncInterval,
 		SyncIntervalDuration: 200 * time.Millisecond,
 		SyncBatchBytes:       1 * 1024 * 1024,
+		GroupCommitMaxDelay:  1 * time.Millisecond,
+		GroupCommitMaxBatch:  128,
 		MaxSegmentBytes:      64 * 1024 * 1024,
 		RetentionSegments:    1,
 	}
 }
+
+// GroupCommitOptions returns DefaultOptions with SyncGroupCommit: the same
+// per-entry durability, with one fsync shared by concurrent writers.
+func GroupCommitOptions() Options {
+	opts := DefaultOptions()
+	opts.SyncPolicy = SyncGroupCommit
+	return opts
+}
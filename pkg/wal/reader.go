@@ -86,18 +86,27 @@ func newWALReaderForPaths(paths []string, cipher crypto.Cipher) (*WALReader, err
 
 // ReadEntry lê a próxima entrada. Retorna io.EOF quando esgotou.
 func (r *WALReader) ReadEntry() (*WALEntry, error) {
+	entry, _, err := r.readEntry(false)
+	return entry, err
+}
+
+// readEntry reads the next entry. With raw, a payload with a bad CRC is
+// consumed and returned with crcOK = false instead of ErrChecksumMismatch,
+// and the payload comes as written (not decompressed); Dump uses this to
+// go on after a corrupted entry.
+func (r *WALReader) readEntry(raw bool) (entry *WALEntry, crcOK bool, err error) {
 	// 1. Garante que temos bytes suficientes pra um header (24 bytes)
 	for len(r.buffer) < HeaderSize {
 		loaded, err := r.loadNextPage()
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
 		if !loaded {
 			// Sem mais pages
 			if len(r.buffer) == 0 {
-				return nil, io.EOF
+				return nil, false, io.EOF
 			}
-			return nil, io.ErrUnexpectedEOF
+			return nil, false, io.ErrUnexpectedEOF
 		}
 	}
 
@@ -106,17 +115,17 @@ func (r *WALReader) ReadEntry() (*WALEntry, error) {
 	header.Decode(r.buffer[:HeaderSize])
 
 	if header.Magic != WALMagic {
-		return nil, ErrInvalidMagic
+		return nil, false, ErrInvalidMagic
 	}
 
 	// Proteção contra alocação absurda
 	if header.PayloadLen > 1024*1024*1024 {
-		return nil, ErrInvalidPayloadLen
+		return nil, false, ErrInvalidPayloadLen
 	}
 
 	if header.PayloadLen == 0 {
 		r.buffer = r.buffer[HeaderSize:]
-		return &WALEntry{Header: header}, nil
+		return &WALEntry{Header: header}, true, nil
 	}
 
 	total := HeaderSize + int(header.PayloadLen)
@@ -125,21 +134,22 @@ func (r *WALReader) ReadEntry() (*WALEntry, error) {
 	for len(r.buffer) < total {
 		loaded, err := r.loadNextPage()
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
 		if !loaded {
-			return nil, io.ErrUnexpectedEOF // payload truncado
+			return nil, false, io.ErrUnexpectedEOF // truncated payload
 		}
 	}
 
 	// 4. Valida checksum do payload
 	payload := r.buffer[HeaderSize:total]
-	if !ValidateCRC32(payload, header.CRC32) {
-		return nil, ErrChecksumMismatch
+	crcOK = ValidateCRC32(payload, header.CRC32)
+	if !crcOK && !raw {
+		return nil, false, ErrChecksumMismatch
 	}
 
 	// 5. Constrói entry (copia payload pra not compartilhar buffer interno)
-	entry := AcquireEntry()
+	entry = AcquireEntry()
 	entry.Header = header
 	if uint32(cap(entry.Payload)) < header.PayloadLen {
 		entry.Payload = make([]byte, header.PayloadLen)
@@ -150,7 +160,14 @@ func (r *WALReader) ReadEntry() (*WALEntry, error) {
 
 	// 6. Consome bytes do buffer
 	r.buffer = r.buffer[total:]
-	return entry, nil
+	if raw {
+		return entry, crcOK, nil
+	}
+	if err := decompressEntry(entry); err != nil {
+		ReleaseEntry(entry)
+		return nil, false, err
+	}
+	return entry, true, nil
 }
 
 // loadNextPage carrega a próxima page no buffer. Retorna (true, nil)
@@ -0,0 +1,126 @@
+package wal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/bobboyms/storage-engine/pkg/pagestore"
+)
+
+// isTornPageErr reports whether err, when reading the last page of the
+// log, is a torn write. A decryption failure is left out: that is a wrong
+// key, not a crash.
+func isTornPageErr(err error) bool {
+	return errors.Is(err, pagestore.ErrChecksumMismatch) || errors.Is(err, pagestore.ErrInvalidMagic)
+}
+
+// TornTail describes the torn tail NewWALWriter cut from the log. The
+// writer does not log it; the caller decides whether it is worth a
+// warning.
+type TornTail struct {
+	// Bytes is how many bytes of the stream were dropped.
+	Bytes int
+	// UnreadablePage is set when the last page itself could not be read,
+	// rather than only the last entry being short or failing its CRC.
+	UnreadablePage bool
+}
+
+// repairTornTail cuts from the active segment what comes after the last
+// complete and valid entry, when that is a torn tail: a short entry at the
+// end, a last entry with a bad CRC or an unreadable last page. Without the
+// cut, the writer would carry on after the garbage and the reader would
+// lose everything written after it. Corruption in the middle of the log
+// is not a tail and is left for the reader to report. It returns what was
+// dropped, the zero TornTail when nothing was.
+func (w *WALWriter) repairTornTail() (TornTail, error) {
+	numPages := w.pf.NumPages()
+	if numPages <= 1 {
+		return TornTail{}, nil
+	}
+
+	// starts[i]: stream position of the bytes of page i+1.
+	var starts []int
+	var buf []byte // stream bytes not parsed yet
+	bufPos := 0    // stream position of buf[0]
+	validEnd := 0  // end of the last valid entry
+	streamLen := 0
+	unreadable := false
+
+	for id := pagestore.PageID(1); uint64(id) < numPages; id++ {
+		last := uint64(id) == numPages-1
+		page, err := w.pf.ReadPage(id)
+		if err != nil {
+			if last && isTornPageErr(err) {
+				unreadable = true
+				break
+			}
+			return TornTail{}, nil // corruption or a wrong key: the reader reports it
+		}
+		n := int(binary.LittleEndian.Uint16(page.Body()[0:2]))
+		if n > w.usableBodySize-walPageHeaderSize {
+			if last {
+				unreadable = true
+				break
+			}
+			return TornTail{}, nil
+		}
+		starts = append(starts, streamLen)
+		streamLen += n
+		buf = append(buf, page.Body()[walPageHeaderSize:walPageHeaderSize+n]...)
+
+		for len(buf) >= HeaderSize {
+			var header WALHeader
+			header.Decode(buf[:HeaderSize])
+			if header.Magic != WALMagic || header.PayloadLen > 1024*1024*1024 {
+				return TornTail{}, nil // corruption, not a tail
+			}
+			total := HeaderSize + int(header.PayloadLen)
+			if len(buf) < total {
+				break
+			}
+			if !ValidateCRC32(buf[HeaderSize:total], header.CRC32) {
+				if !last || bufPos+total != streamLen {
+					return TornTail{}, nil // something follows: corruption, not a tail
+				}
+				break
+			}
+			buf = buf[total:]
+			bufPos += total
+			validEnd = bufPos
+		}
+		buf = append([]byte(nil), buf...) // compact away what was parsed
+	}
+	if validEnd == streamLen && !unreadable {
+		return TornTail{}, nil
+	}
+
+	// The page holding validEnd: the last one starting at or before it.
+	keep := 0
+	for i := range starts {
+		if starts[i] <= validEnd {
+			keep = i + 1
+		}
+	}
+	if keep > 0 {
+		id := pagestore.PageID(keep)
+		page, err := w.pf.ReadPage(id)
+		if err != nil {
+			return TornTail{}, fmt.Errorf("wal: read page %d: %w", id, err)
+		}
+		cut := validEnd - starts[keep-1]
+		body := page.Body()
+		clear(body[walPageHeaderSize+cut:])
+		binary.LittleEndian.PutUint16(body[0:2], uint16(cut))
+		if err := w.pf.WritePage(id, page); err != nil {
+			return TornTail{}, fmt.Errorf("wal: write page %d: %w", id, err)
+		}
+	}
+	if err := w.pf.Truncate(uint64(keep) + 1); err != nil {
+		return TornTail{}, fmt.Errorf("wal: truncate torn tail: %w", err)
+	}
+	if err := w.pf.Sync(); err != nil {
+		return TornTail{}, err
+	}
+	return TornTail{Bytes: streamLen - validEnd, UnreadablePage: unreadable}, nil
+}
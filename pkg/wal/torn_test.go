@@ -0,0 +1,141 @@
+package wal
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bobboyms/storage-engine/pkg/pagestore"
+)
+
+// writeTornFixture writes small LSNs 1-3 and LSN 4, which crosses pages,
+// and returns how many pages the file has.
+func writeTornFixture(t *testing.T, path string, badCRC bool) int64 {
+	t.Helper()
+	w, err := NewWALWriter(path, DefaultOptions())
+	if err != nil {
+		t.Fatalf("NewWALWriter: %v", err)
+	}
+	for lsn := uint64(1); lsn <= 4; lsn++ {
+		payload := bytes.Repeat([]byte{byte(lsn)}, 100)
+		if lsn == 4 && !badCRC {
+			payload = bytes.Repeat([]byte{4}, 20000)
+		}
+		entry := lifecycleEntry(lsn, payload)
+		if lsn == 4 && badCRC {
+			entry.Header.CRC32++
+		}
+		if err := w.WriteEntry(entry); err != nil {
+			t.Fatalf("WriteEntry %d: %v", lsn, err)
+		}
+		ReleaseEntry(entry)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size() / pagestore.PageSize
+}
+
+func TestWALWriter_TruncatesTornTail(t *testing.T) {
+	cases := []struct {
+		name   string
+		badCRC bool
+		tear   func(t *testing.T, path string, pages int64)
+	}{
+		{"short entry", false, func(t *testing.T, path string, pages int64) {
+			if err := os.Truncate(path, (pages-1)*pagestore.PageSize); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"unreadable last page", false, func(t *testing.T, path string, pages int64) {
+			f, err := os.OpenFile(path, os.O_RDWR, 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if _, err := f.WriteAt([]byte{0xFF, 0xFF}, (pages-1)*pagestore.PageSize+300); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"bad CRC on the last entry", true, func(*testing.T, string, int64) {}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "wal.log")
+			pages := writeTornFixture(t, path, tc.badCRC)
+			tc.tear(t, path, pages)
+
+			w, err := NewWALWriter(path, DefaultOptions())
+			if err != nil {
+				t.Fatalf("reopen: %v", err)
+			}
+			if w.TornTailBytes() == 0 {
+				t.Fatal("TornTailBytes = 0, want the torn entry cut off")
+			}
+			if got, want := w.TornTail().UnreadablePage, tc.name == "unreadable last page"; got != want {
+				t.Fatalf("TornTail().UnreadablePage = %v, want %v", got, want)
+			}
+			entry := lifecycleEntry(5, []byte("after the crash"))
+			if err := w.WriteEntry(entry); err != nil {
+				t.Fatalf("WriteEntry: %v", err)
+			}
+			ReleaseEntry(entry)
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			got := readLifecycleLSNs(t, path)
+			if len(got) != 4 || got[0] != 1 || got[2] != 3 || got[3] != 5 {
+				t.Fatalf("LSNs after repair = %v, want [1 2 3 5]", got)
+			}
+		})
+	}
+}
+
+func TestWALWriter_LeavesCleanAndCorruptLogsAlone(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.log")
+	writeTornFixture(t, path, false)
+	w, err := NewWALWriter(path, DefaultOptions())
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if n := w.TornTailBytes(); n != 0 {
+		t.Fatalf("TornTailBytes of a clean log = %d", n)
+	}
+	w.Close()
+
+	// Corruption on the first page, with pages after it, is not a tail:
+	// nothing is cut and the reader keeps reporting it.
+	f, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte{0xFF}, pagestore.PageSize+200)
+	f.Close()
+	before, _ := os.Stat(path)
+	w, err = NewWALWriter(path, DefaultOptions())
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if n := w.TornTailBytes(); n != 0 {
+		t.Fatalf("TornTailBytes of a corrupt log = %d", n)
+	}
+	w.Close()
+	if after, _ := os.Stat(path); after.Size() != before.Size() {
+		t.Fatalf("corrupt log resized from %d to %d", before.Size(), after.Size())
+	}
+	r, err := NewWALReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := r.ReadEntry(); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("ReadEntry = %v, want ErrChecksumMismatch", err)
+	}
+}
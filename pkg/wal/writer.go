@@ -1,6 +1,7 @@
 package wal
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"os"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/bobboyms/storage-engine/pkg/crypto"
 	"github.com/bobboyms/storage-engine/pkg/pagestore"
+	"github.com/bobboyms/storage-engine/pkg/tracing"
 )
 
 // Layout de uma page WAL (dentro do body de uma pagestore.Page):
@@ -34,6 +36,7 @@ const (
 //   - SyncEveryWrite: a cada WriteEntry
 //   - SyncInterval:   background ticker
 //   - SyncBatch:      quando N bytes de entries foram escritos
+//   - SyncGroupCommit: one fsync shared by concurrent writers
 type WALWriter struct {
 	mu      sync.Mutex
 	pf      *pagestore.PageFile
@@ -55,6 +58,20 @@ type WALWriter struct {
 	// Indica se o segmento ativo contém pelo menos uma entrada completa.
 	segmentHasEntries bool
 
+	// SyncGroupCommit state. writeSeq counts written entries (under mu);
+	// group tracks who waits and who leads the next fsync.
+	writeSeq uint64
+	group    groupCommit
+	// syncs counts completed fsyncs (observability and tests).
+	syncs atomic.Uint64
+	// bytesWritten counts entry bytes written since open, across segment
+	// rotations.
+	bytesWritten atomic.Uint64
+	// tracer traces appends and fsyncs (see SetTracer).
+	tracer tracing.Slot
+	// tornTail is what the open cut off as a torn tail.
+	tornTail TornTail
+
 	// Controle de threads
 	done   chan struct{}
 	ticker *time.Ticker
@@ -64,7 +81,7 @@ type WALWriter struct {
 // NewWALWriter cria um novo Writer. Abre o arquivo via pagestore
 // (aplicando cipher se configurado em `opts.Cipher`).
 func NewWALWriter(path string, opts Options) (*WALWriter, error) {
-	pf, err := pagestore.NewPageFile(path, opts.Cipher)
+	pf, err := pagestore.NewPageFileWithOptions(path, opts.Cipher, pagestore.PageFileOptions{Exclusive: true})
 	if err != nil {
 		return nil, fmt.Errorf("wal: open page file: %w", err)
 	}
@@ -75,6 +92,16 @@ func NewWALWriter(path string, opts Options) (*WALWriter, error) {
 		usableBodySize: pf.UsableBodySize(),
 		done:           make(chan struct{}),
 	}
+	w.group.init()
+
+	// A crash in the middle of an append leaves a torn entry at the end;
+	// cut it before writing on after it.
+	tornTail, err := w.repairTornTail()
+	if err != nil {
+		pf.Close()
+		return nil, err
+	}
+	w.tornTail = tornTail
 
 	// Detecta se estamos reabrindo arquivo existsnte ou criando novo.
 	// pf.NumPages() == 1 significa só o slot 0 reservado (arquivo empty).
@@ -113,39 +140,106 @@ func (w *WALWriter) Cipher() crypto.Cipher {
 	return w.options.Cipher
 }
 
+// BytesWritten returns how many entry bytes (header + payload) this writer
+// has written since it was opened. It only grows; segment rotation does
+// not reset it.
+func (w *WALWriter) BytesWritten() uint64 {
+	return w.bytesWritten.Load()
+}
+
+// TornTailBytes returns how many bytes of a torn tail, left by a crash
+// mid-append, the open truncated from the log; zero when the log ended
+// cleanly.
+func (w *WALWriter) TornTailBytes() int {
+	return w.tornTail.Bytes
+}
+
+// TornTail returns the torn tail the open cut off, the zero TornTail when
+// the log ended cleanly.
+func (w *WALWriter) TornTail() TornTail {
+	return w.tornTail
+}
+
 // WriteEntry serializa `entry` e escreve na page atual, alocando
 // novas pages quando necessário. Aplica a política de sync.
+//
+// With SyncGroupCommit it returns only after a shared fsync has covered
+// the entry (see waitDurable).
 func (w *WALWriter) WriteEntry(entry *WALEntry) error {
+	return w.WriteEntryCtx(context.Background(), entry)
+}
+
+// WriteEntryCtx is WriteEntry under the span of ctx: with a tracer, the
+// append is the span "wal.append" and the fsync the sync policy makes is
+// its child "wal.fsync".
+func (w *WALWriter) WriteEntryCtx(ctx context.Context, entry *WALEntry) (err error) {
+	ctx, span := w.tracer.Start(ctx, "wal.append")
+	defer func() { tracing.End(span, err) }()
+	if tracing.Recording(span) {
+		span.SetAttribute("wal.lsn", entry.Header.LSN)
+		span.SetAttribute("wal.bytes", HeaderSize+len(entry.Payload))
+	}
+
+	if w.options.SyncPolicy == SyncGroupCommit {
+		seq, err := w.writeEntryGroup(ctx, entry)
+		if err != nil {
+			return err
+		}
+		_, fsync := w.tracer.Start(ctx, "wal.fsync")
+		err = w.waitDurable(seq)
+		tracing.End(fsync, err)
+		return err
+	}
+
 	w.mu.Lock()
 	defer w.mu.Unlock()
+	return w.writeEntryLocked(ctx, entry)
+}
+
+// SetTracer installs the tracer of WriteEntryCtx and SyncCtx; nil turns
+// tracing off.
+func (w *WALWriter) SetTracer(t tracing.Tracer) {
+	w.tracer.Set(t)
+}
 
+// Tracer returns the tracer installed by SetTracer, or nil.
+func (w *WALWriter) Tracer() tracing.Tracer {
+	return w.tracer.Tracer()
+}
+
+// writeEntryLocked writes the entry and applies the sync policy. Caller
+// must hold w.mu.
+func (w *WALWriter) writeEntryLocked(ctx context.Context, entry *WALEntry) error {
 	if w.closed.Load() {
 		return fmt.Errorf("wal: writer fechado")
 	}
 
 	// Serializa header + payload num buffer (headerSize + payloadLen bytes)
-	buf := make([]byte, HeaderSize+len(entry.Payload))
-	entry.Header.Encode(buf[:HeaderSize])
-	copy(buf[HeaderSize:], entry.Payload)
+	buf, err := w.encodeEntry(entry)
+	if err != nil {
+		return err
+	}
 
 	// Escreve byte-a-byte, cruzando pages se preciso.
 	if err := w.appendBytes(buf); err != nil {
 		return err
 	}
 	w.segmentHasEntries = true
+	w.writeSeq++
+	w.bytesWritten.Add(uint64(len(buf)))
 
 	w.batchBytes += int64(len(buf))
 
 	// Política de sync
 	switch w.options.SyncPolicy {
 	case SyncEveryWrite:
-		if err := w.syncLocked(); err != nil {
+		if err := w.syncTracedLocked(ctx); err != nil {
 			return err
 		}
 		return w.maybeRotateLocked()
 	case SyncBatch:
 		if w.batchBytes >= w.options.SyncBatchBytes {
-			if err := w.syncLocked(); err != nil {
+			if err := w.syncTracedLocked(ctx); err != nil {
 				return err
 			}
 			return w.maybeRotateLocked()
@@ -154,6 +248,30 @@ func (w *WALWriter) WriteEntry(entry *WALEntry) error {
 	return w.maybeRotateLocked()
 }
 
+// encodeEntry serializes header + payload. With Options.Compression, a
+// payload that shrinks is written compressed, with FlagCompressed and the
+// PayloadLen and CRC32 of the written bytes; the caller's entry does not
+// change.
+func (w *WALWriter) encodeEntry(entry *WALEntry) ([]byte, error) {
+	header, payload := entry.Header, entry.Payload
+	if w.options.Compression {
+		compressed, err := compressPayload(payload, w.options.CompressMinSize)
+		if err != nil {
+			return nil, fmt.Errorf("wal: compress entry: %w", err)
+		}
+		if compressed != nil {
+			payload = compressed
+			header.Flags |= FlagCompressed
+			header.PayloadLen = uint32(len(payload))
+			header.CRC32 = CalculateCRC32(payload)
+		}
+	}
+	buf := make([]byte, HeaderSize+len(payload))
+	header.Encode(buf[:HeaderSize])
+	copy(buf[HeaderSize:], payload)
+	return buf, nil
+}
+
 // appendBytes escreve `data` na stream lógica, alocando pages conforme
 // necessário. Caller must segurar w.mu.
 func (w *WALWriter) appendBytes(data []byte) error {
@@ -264,7 +382,7 @@ func (w *WALWriter) rotateActiveLocked() error {
 	}
 
 	base := w.pf.Path()
-	nextPath, err := nextSegmentPath(base)
+	nextPath, err := nextSegmentPath(base, w.options.ArchiveDir)
 	if err != nil {
 		return err
 	}
@@ -278,7 +396,7 @@ func (w *WALWriter) rotateActiveLocked() error {
 		return err
 	}
 
-	pf, err := pagestore.NewPageFile(base, w.options.Cipher)
+	pf, err := pagestore.NewPageFileWithOptions(base, w.options.Cipher, pagestore.PageFileOptions{Exclusive: true})
 	if err != nil {
 		return fmt.Errorf("wal: abrir novo segmento ativo: %w", err)
 	}
@@ -291,9 +409,22 @@ func (w *WALWriter) rotateActiveLocked() error {
 
 // Sync força a persistência em disco: escreve a page atual + fsync.
 func (w *WALWriter) Sync() error {
+	return w.SyncCtx(context.Background())
+}
+
+// SyncCtx is Sync under the span of ctx, as the child "wal.fsync".
+func (w *WALWriter) SyncCtx(ctx context.Context) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	return w.syncLocked()
+	return w.syncTracedLocked(ctx)
+}
+
+// syncTracedLocked is syncLocked in the span "wal.fsync".
+func (w *WALWriter) syncTracedLocked(ctx context.Context) error {
+	_, span := w.tracer.Start(ctx, "wal.fsync")
+	err := w.syncLocked()
+	tracing.End(span, err)
+	return err
 }
 
 func (w *WALWriter) syncLocked() error {
@@ -301,9 +432,13 @@ func (w *WALWriter) syncLocked() error {
 		return err
 	}
 	if err := w.pf.Sync(); err != nil {
-		return fmt.Errorf("wal: fsync: %w", err)
+		err = fmt.Errorf("wal: fsync: %w", err)
+		w.group.fail(err)
+		return err
 	}
+	w.syncs.Add(1)
 	w.batchBytes = 0
+	w.group.markDurable(w.writeSeq)
 	return nil
 }
 
@@ -335,29 +470,12 @@ func (w *WALWriter) Close() error {
 // pular entradas com LSN < beginLSN porque as pages sujas naquele
 // momento foram garantidamente flushadas ao disco antes desta chamada.
 func (w *WALWriter) WriteCheckpointRecord(beginLSN uint64) error {
-	payload := make([]byte, 8)
-	binary.LittleEndian.PutUint64(payload, beginLSN)
-
-	entry := AcquireEntry()
-	entry.Header.Magic = WALMagic
-	entry.Header.Version = WALVersion
-	entry.Header.EntryType = EntryCheckpoint
-	entry.Header.LSN = beginLSN
-	entry.Header.PayloadLen = 8
-	entry.Header.CRC32 = CalculateCRC32(payload)
-	entry.Payload = append(entry.Payload[:0], payload...)
-
-	err := w.WriteEntry(entry)
-	ReleaseEntry(entry)
-
-	if err != nil {
-		return fmt.Errorf("wal: write checkpoint record: %w", err)
-	}
-	return w.Sync()
+	return w.WriteCheckpoint(CheckpointRecord{BeginLSN: beginLSN})
 }
 
 // CheckpointLifecycle rotaciona o WAL after um checkpoint e remove segmentos
 // antigos já cobertos por checkpointLSN, respeitando archive/retention.
+// The archive is then pruned by Options.ArchiveRetention.
 func (w *WALWriter) CheckpointLifecycle(checkpointLSN uint64) error {
 	w.mu.Lock()
 	base := w.pf.Path()
@@ -369,7 +487,23 @@ func (w *WALWriter) CheckpointLifecycle(checkpointLSN uint64) error {
 	if err != nil {
 		return err
 	}
-	return ArchiveAndTruncate(base, cipher, archiveDir, checkpointLSN, retentionSegments)
+	if err := ArchiveAndTruncate(base, cipher, archiveDir, checkpointLSN, retentionSegments); err != nil {
+		return err
+	}
+	_, err = w.PruneArchive()
+	return err
+}
+
+// PruneArchive applies Options.ArchiveRetention to Options.ArchiveDir now
+// and returns the archived segments it removed. Without an archive or a
+// retention it does nothing.
+func (w *WALWriter) PruneArchive() ([]string, error) {
+	w.mu.Lock()
+	base := w.pf.Path()
+	archiveDir := w.options.ArchiveDir
+	keep := w.options.ArchiveRetention
+	w.mu.Unlock()
+	return PruneArchive(base, archiveDir, keep, time.Now())
 }
 
 func (w *WALWriter) backgroundSync() {